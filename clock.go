@@ -0,0 +1,139 @@
+package watermill
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time and timers.
+//
+// Components that depend on time (timeouts, retries, schedulers) accept a Clock,
+// so it can be replaced in tests with FakeClock, instead of relying on real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is a Clock based on the time package.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// After returns time.After(d).
+func (RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// FakeClock is a Clock which time is controlled manually.
+// It's useful for testing components that depend on time, without waiting for real time to pass.
+//
+// Time of FakeClock is changed only by calling Advance or Set.
+type FakeClock struct {
+	now     time.Time
+	waiters []fakeClockWaiter
+
+	lock          sync.Mutex
+	waitersChange *sync.Cond
+}
+
+type fakeClockWaiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+// NewFakeClock creates a new FakeClock set to the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{
+		now: now,
+	}
+	c.waitersChange = sync.NewCond(&c.lock)
+
+	return c
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// After returns a channel which receives the clock's time once the clock is advanced by at least d.
+// If d is not positive, the channel receives the current time immediately.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, fakeClockWaiter{
+		until: c.now.Add(d),
+		ch:    ch,
+	})
+	c.waitersChange.Broadcast()
+
+	return ch
+}
+
+// Advance moves the clock forward by d, firing all timers that are due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.setTime(c.now.Add(d))
+}
+
+// Set sets the clock to t, firing all timers that are due.
+// Setting time in the past doesn't fire any timers.
+func (c *FakeClock) Set(t time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.setTime(t)
+}
+
+func (c *FakeClock) setTime(t time.Time) {
+	c.now = t
+
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.until.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+
+	c.waitersChange.Broadcast()
+}
+
+// Waiters returns the number of timers created with After that didn't fire yet.
+func (c *FakeClock) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntilWaiters blocks until there are at least n timers that didn't fire yet.
+// It's useful to ensure that the tested component started waiting before calling Advance.
+func (c *FakeClock) BlockUntilWaiters(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.waiters) < n {
+		c.waitersChange.Wait()
+	}
+}
@@ -0,0 +1,88 @@
+package watermill_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(start)
+
+	assert.Equal(t, start, clock.Now())
+
+	afterSecond := clock.After(time.Second)
+	afterMinute := clock.After(time.Minute)
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Millisecond * 500)
+	assertNotFired(t, afterSecond)
+
+	clock.Advance(time.Millisecond * 500)
+	assert.Equal(t, start.Add(time.Second), <-afterSecond)
+	assertNotFired(t, afterMinute)
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Set(start.Add(time.Hour))
+	assert.Equal(t, start.Add(time.Hour), <-afterMinute)
+	assert.Equal(t, 0, clock.Waiters())
+	assert.Equal(t, start.Add(time.Hour), clock.Now())
+}
+
+func TestFakeClock_After_non_positive(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(start)
+
+	assert.Equal(t, start, <-clock.After(0))
+	assert.Equal(t, start, <-clock.After(-time.Second))
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestFakeClock_BlockUntilWaiters(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	fired := make(chan struct{})
+	go func() {
+		<-clock.After(time.Second)
+		close(fired)
+	}()
+
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Second)
+
+	select {
+	case <-fired:
+		// ok
+	case <-time.After(time.Second):
+		t.Fatal("timer should fire")
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	clock := watermill.RealClock{}
+
+	before := time.Now()
+	assert.False(t, clock.Now().Before(before))
+
+	select {
+	case <-clock.After(time.Millisecond):
+		// ok
+	case <-time.After(time.Second):
+		t.Fatal("timer should fire")
+	}
+}
+
+func assertNotFired(t *testing.T, ch <-chan time.Time) {
+	t.Helper()
+
+	select {
+	case <-ch:
+		t.Fatal("timer should not fire")
+	default:
+		// ok
+	}
+}
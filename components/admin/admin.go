@@ -0,0 +1,295 @@
+package admin
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+// HandlerStats contains the statistics of the router's handler, collected since the Admin was created.
+type HandlerStats struct {
+	Name string `json:"name"`
+
+	// Processed is the number of messages handled by the handler, including failed ones.
+	Processed uint64 `json:"processed"`
+	// Errored is the number of messages for which the handler returned an error.
+	Errored uint64 `json:"errored"`
+	// InFlight is the number of messages being handled at the moment.
+	InFlight int64 `json:"in_flight"`
+	// AvgDuration is the average duration of handling the message.
+	AvgDuration time.Duration `json:"avg_duration"`
+
+	// DeadLettered is the number of messages sent to the dead letter (poison) queue
+	// with the publisher decorated by Admin.DecorateDeadLetterPublisher.
+	DeadLettered uint64 `json:"dead_lettered"`
+
+	// Paused is true if processing of new messages is paused with Admin.Pause.
+	Paused bool `json:"paused"`
+}
+
+// Config holds the Admin's configuration options.
+type Config struct {
+	// Authenticate is called for every HTTP request before it's handled.
+	// If it returns an error, the request is rejected with 401 Unauthorized.
+	// If nil, all requests are allowed, so make sure the endpoint is not exposed publicly.
+	Authenticate func(r *http.Request) error
+
+	// Clock is used to measure the duration of handling the messages.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *Config) setDefaults() {
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+type handlerStats struct {
+	processed     uint64
+	errored       uint64
+	inFlight      int64
+	totalDuration time.Duration
+	deadLettered  uint64
+
+	// resumed is not nil when the handler is paused, it's closed when the handler is resumed
+	resumed chan struct{}
+}
+
+// Admin collects the statistics of the router's handlers and allows to pause and resume them.
+// They are exposed over HTTP with Admin.Handler.
+//
+// Admin adds a router-level middleware, so it should be created before the handlers' middlewares are added.
+// Otherwise, the statistics include time spent in middlewares added earlier (for example, retries)
+// and pausing doesn't stop them.
+type Admin struct {
+	router *message.Router
+	config Config
+
+	stats     map[string]*handlerStats
+	statsLock sync.Mutex
+}
+
+// NewAdmin creates a new Admin and adds its middleware to the router.
+func NewAdmin(router *message.Router, config Config) (*Admin, error) {
+	if router == nil {
+		return nil, errors.New("missing router")
+	}
+
+	config.setDefaults()
+
+	a := &Admin{
+		router: router,
+		config: config,
+		stats:  map[string]*handlerStats{},
+	}
+
+	router.AddMiddleware(a.middleware)
+
+	return a, nil
+}
+
+// Stats returns the statistics of all router's handlers, sorted by name.
+func (a *Admin) Stats() []HandlerStats {
+	handlers := a.router.Handlers()
+
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	a.statsLock.Lock()
+	defer a.statsLock.Unlock()
+
+	stats := make([]HandlerStats, 0, len(names))
+	for _, name := range names {
+		stats = append(stats, a.handlerStatsLocked(name))
+	}
+
+	return stats
+}
+
+// HandlerStats returns the statistics of the handler.
+// If the router has no such handler, the error is message.HandlerNotFoundError.
+func (a *Admin) HandlerStats(handlerName string) (HandlerStats, error) {
+	if err := a.checkHandlerExists(handlerName); err != nil {
+		return HandlerStats{}, err
+	}
+
+	a.statsLock.Lock()
+	defer a.statsLock.Unlock()
+
+	return a.handlerStatsLocked(handlerName), nil
+}
+
+func (a *Admin) handlerStatsLocked(handlerName string) HandlerStats {
+	s := HandlerStats{Name: handlerName}
+
+	stats, ok := a.stats[handlerName]
+	if !ok {
+		return s
+	}
+
+	s.Processed = stats.processed
+	s.Errored = stats.errored
+	s.InFlight = stats.inFlight
+	s.DeadLettered = stats.deadLettered
+	s.Paused = stats.resumed != nil
+	if stats.processed > 0 {
+		s.AvgDuration = stats.totalDuration / time.Duration(stats.processed)
+	}
+
+	return s
+}
+
+// Pause pauses handling of new messages by the handler.
+// Messages received while the handler is paused wait (without being acked or nacked) until it's resumed.
+// Messages which are already being handled are not affected.
+func (a *Admin) Pause(handlerName string) error {
+	if err := a.checkHandlerExists(handlerName); err != nil {
+		return err
+	}
+
+	a.statsLock.Lock()
+	defer a.statsLock.Unlock()
+
+	stats := a.handlerStatsForUpdateLocked(handlerName)
+	if stats.resumed == nil {
+		stats.resumed = make(chan struct{})
+		a.config.Logger.Info("Handler paused", watermill.LogFields{"handler_name": handlerName})
+	}
+
+	return nil
+}
+
+// Resume resumes handling of messages by the handler paused with Pause.
+func (a *Admin) Resume(handlerName string) error {
+	if err := a.checkHandlerExists(handlerName); err != nil {
+		return err
+	}
+
+	a.statsLock.Lock()
+	defer a.statsLock.Unlock()
+
+	stats := a.handlerStatsForUpdateLocked(handlerName)
+	if stats.resumed != nil {
+		close(stats.resumed)
+		stats.resumed = nil
+		a.config.Logger.Info("Handler resumed", watermill.LogFields{"handler_name": handlerName})
+	}
+
+	return nil
+}
+
+// DecorateDeadLetterPublisher decorates the publisher passed to middleware.PoisonQueue,
+// so messages sent to the dead letter queue are counted in HandlerStats.DeadLettered.
+func (a *Admin) DecorateDeadLetterPublisher(pub message.Publisher) message.Publisher {
+	return deadLetterPublisher{Publisher: pub, admin: a}
+}
+
+type deadLetterPublisher struct {
+	message.Publisher
+	admin *Admin
+}
+
+func (p deadLetterPublisher) Publish(topic string, messages ...*message.Message) error {
+	if err := p.Publisher.Publish(topic, messages...); err != nil {
+		return err
+	}
+
+	p.admin.statsLock.Lock()
+	defer p.admin.statsLock.Unlock()
+
+	for _, msg := range messages {
+		handlerName := msg.Metadata.Get(middleware.PoisonedHandlerKey)
+		if handlerName == "" {
+			handlerName = message.HandlerNameFromCtx(msg.Context())
+		}
+
+		p.admin.handlerStatsForUpdateLocked(handlerName).deadLettered++
+	}
+
+	return nil
+}
+
+func (a *Admin) checkHandlerExists(handlerName string) error {
+	if _, ok := a.router.Handlers()[handlerName]; !ok {
+		return message.HandlerNotFoundError{HandlerName: handlerName}
+	}
+
+	return nil
+}
+
+func (a *Admin) handlerStatsForUpdateLocked(handlerName string) *handlerStats {
+	stats, ok := a.stats[handlerName]
+	if !ok {
+		stats = &handlerStats{}
+		a.stats[handlerName] = stats
+	}
+
+	return stats
+}
+
+func (a *Admin) waitIfPaused(msg *message.Message, handlerName string) error {
+	a.statsLock.Lock()
+	resumed := a.handlerStatsForUpdateLocked(handlerName).resumed
+	a.statsLock.Unlock()
+
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-msg.Context().Done():
+		return msg.Context().Err()
+	}
+}
+
+func (a *Admin) middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) (_ []*message.Message, err error) {
+		handlerName := message.HandlerNameFromCtx(msg.Context())
+
+		if err := a.waitIfPaused(msg, handlerName); err != nil {
+			return nil, err
+		}
+
+		a.statsLock.Lock()
+		a.handlerStatsForUpdateLocked(handlerName).inFlight++
+		a.statsLock.Unlock()
+
+		start := a.config.Clock.Now()
+		defer func() {
+			duration := a.config.Clock.Now().Sub(start)
+
+			a.statsLock.Lock()
+			defer a.statsLock.Unlock()
+
+			stats := a.handlerStatsForUpdateLocked(handlerName)
+			stats.inFlight--
+			stats.processed++
+			stats.totalDuration += duration
+			if err != nil {
+				stats.errored++
+			}
+		}()
+
+		return h(msg)
+	}
+}
@@ -0,0 +1,157 @@
+package admin_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/admin"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func runRouter(t *testing.T, router *message.Router) {
+	t.Helper()
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+}
+
+func publish(t *testing.T, pub message.Publisher, topic string, payloads ...string) {
+	t.Helper()
+
+	for _, payload := range payloads {
+		require.NoError(t, pub.Publish(topic, message.NewMessage(watermill.NewUUID(), []byte(payload))))
+	}
+}
+
+func TestAdmin_Stats(t *testing.T) {
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	adm, err := admin.NewAdmin(router, admin.Config{})
+	require.NoError(t, err)
+
+	deadLetters, err := pubSub.Subscribe(context.Background(), "dead_letter")
+	require.NoError(t, err)
+
+	poisonQueue, err := middleware.PoisonQueueWithFilter(
+		adm.DecorateDeadLetterPublisher(pubSub),
+		"dead_letter",
+		func(err error) bool {
+			return err.Error() == "poisoned"
+		},
+	)
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("handler_a", "topic_a", pubSub, func(msg *message.Message) error {
+		switch string(msg.Payload) {
+		case "error":
+			return errors.New("some error")
+		case "poison":
+			return errors.New("poisoned")
+		}
+		return nil
+	}).AddMiddleware(poisonQueue)
+
+	router.AddNoPublisherHandler("handler_b", "topic_b", pubSub, func(msg *message.Message) error {
+		return nil
+	})
+
+	runRouter(t, router)
+
+	publish(t, pubSub, "topic_a", "ok", "ok", "poison")
+	publish(t, pubSub, "topic_b", "ok")
+
+	_, all := subscriber.BulkRead(deadLetters, 1, time.Second)
+	require.True(t, all)
+
+	assert.Eventually(t, func() bool {
+		stats := adm.Stats()
+		return stats[0].Processed == 3 && stats[1].Processed == 1
+	}, time.Second, time.Millisecond*10)
+
+	stats := adm.Stats()
+	require.Len(t, stats, 2)
+
+	assert.Equal(t, "handler_a", stats[0].Name)
+	assert.EqualValues(t, 0, stats[0].Errored, "poisoned message is not an error of the handler with PoisonQueue")
+	assert.EqualValues(t, 1, stats[0].DeadLettered)
+	assert.EqualValues(t, 0, stats[0].InFlight)
+	assert.False(t, stats[0].Paused)
+
+	assert.Equal(t, "handler_b", stats[1].Name)
+	assert.EqualValues(t, 0, stats[1].DeadLettered)
+
+	publish(t, pubSub, "topic_a", "error")
+
+	assert.Eventually(t, func() bool {
+		stats, err := adm.HandlerStats("handler_a")
+		require.NoError(t, err)
+		return stats.Errored > 0
+	}, time.Second, time.Millisecond*10)
+
+	_, err = adm.HandlerStats("unknown")
+	assert.ErrorAs(t, err, &message.HandlerNotFoundError{})
+}
+
+func TestAdmin_Pause(t *testing.T) {
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	adm, err := admin.NewAdmin(router, admin.Config{})
+	require.NoError(t, err)
+
+	var handled int32
+
+	router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+
+	runRouter(t, router)
+
+	require.NoError(t, adm.Pause("handler"))
+
+	stats, err := adm.HandlerStats("handler")
+	require.NoError(t, err)
+	assert.True(t, stats.Paused)
+
+	publish(t, pubSub, "topic", "1")
+
+	time.Sleep(time.Millisecond * 50)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&handled), "paused handler should not handle messages")
+
+	require.NoError(t, adm.Resume("handler"))
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&handled) == 1
+	}, time.Second, time.Millisecond*10)
+
+	stats, err = adm.HandlerStats("handler")
+	require.NoError(t, err)
+	assert.False(t, stats.Paused)
+
+	assert.ErrorAs(t, adm.Pause("unknown"), &message.HandlerNotFoundError{})
+	assert.ErrorAs(t, adm.Resume("unknown"), &message.HandlerNotFoundError{})
+}
@@ -0,0 +1,122 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Handler returns the HTTP handler of the admin endpoint. It exposes:
+//
+//	GET  /handlers                - statistics of all handlers
+//	GET  /handlers/{name}         - statistics of the handler
+//	POST /handlers/{name}/pause   - pauses the handler
+//	POST /handlers/{name}/resume  - resumes the handler
+//
+// Requests are authenticated with Config.Authenticate.
+// The handler can be mounted under a prefix of your own HTTP server, for example with http.StripPrefix.
+func (a *Admin) Handler() http.Handler {
+	router := chi.NewRouter()
+	router.Use(a.authenticate)
+
+	router.Get("/handlers", func(w http.ResponseWriter, r *http.Request) {
+		a.writeJSON(w, http.StatusOK, a.Stats())
+	})
+	router.Get("/handlers/{name}", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := a.HandlerStats(chi.URLParam(r, "name"))
+		if err != nil {
+			a.writeError(w, err)
+			return
+		}
+
+		a.writeJSON(w, http.StatusOK, stats)
+	})
+	router.Post("/handlers/{name}/pause", a.handlerAction(a.Pause))
+	router.Post("/handlers/{name}/resume", a.handlerAction(a.Resume))
+
+	return router
+}
+
+// ListenAndServe serves the admin endpoint at the given address until the context is canceled.
+func (a *Admin) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: a.Handler(),
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := server.Close(); err != nil {
+			a.config.Logger.Error("Cannot close admin server", err, nil)
+		}
+	}()
+
+	a.config.Logger.Info("Starting admin server", watermill.LogFields{"addr": addr})
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Wrap(err, "admin server failed")
+	}
+
+	return nil
+}
+
+func (a *Admin) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.config.Authenticate != nil {
+			if err := a.config.Authenticate(r); err != nil {
+				a.config.Logger.Info("Admin request rejected", watermill.LogFields{
+					"path":  r.URL.Path,
+					"error": err.Error(),
+				})
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Admin) handlerAction(action func(handlerName string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handlerName := chi.URLParam(r, "name")
+
+		if err := action(handlerName); err != nil {
+			a.writeError(w, err)
+			return
+		}
+
+		stats, err := a.HandlerStats(handlerName)
+		if err != nil {
+			a.writeError(w, err)
+			return
+		}
+
+		a.writeJSON(w, http.StatusOK, stats)
+	}
+}
+
+func (a *Admin) writeError(w http.ResponseWriter, err error) {
+	if errors.As(err, &message.HandlerNotFoundError{}) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	a.config.Logger.Error("Admin request failed", err, nil)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func (a *Admin) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		a.config.Logger.Error("Cannot write admin response", err, nil)
+	}
+}
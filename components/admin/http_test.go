@@ -0,0 +1,111 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/admin"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	adm, err := admin.NewAdmin(router, admin.Config{
+		Authenticate: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return errors.New("invalid token")
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		return nil
+	})
+
+	server := httptest.NewServer(adm.Handler())
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func doRequest(t *testing.T, method string, url string, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+
+	return resp
+}
+
+func TestAdmin_Handler(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/handlers", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stats []admin.HandlerStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stats))
+	assert.Equal(t, []admin.HandlerStats{{Name: "handler"}}, stats)
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/handlers/handler/pause", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var handlerStats admin.HandlerStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&handlerStats))
+	assert.True(t, handlerStats.Paused)
+
+	resp = doRequest(t, http.MethodGet, server.URL+"/handlers/handler", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&handlerStats))
+	assert.True(t, handlerStats.Paused)
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/handlers/handler/resume", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&handlerStats))
+	assert.False(t, handlerStats.Paused)
+}
+
+func TestAdmin_Handler_not_found(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/handlers/unknown", "secret")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/handlers/unknown/pause", "secret")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAdmin_Handler_unauthorized(t *testing.T) {
+	server := newTestServer(t)
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/handlers", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = doRequest(t, http.MethodPost, server.URL+"/handlers/handler/pause", "invalid")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
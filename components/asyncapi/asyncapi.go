@@ -0,0 +1,275 @@
+package asyncapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+// Config holds the Generator's configuration options.
+type Config struct {
+	// Title is the title of the documented application.
+	// It is required.
+	Title string
+
+	// Version is the version of the documented application (not of the AsyncAPI specification).
+	// It is required.
+	Version string
+
+	// Description of the documented application.
+	//
+	// This option is not required.
+	Description string
+
+	// ContentType returns the content type of the messages marshaled with the marshaler.
+	// If it's not provided or it returns an empty string, "application/x-protobuf" is used for cqrs.ProtobufMarshaler
+	// and "application/json" for other marshalers.
+	//
+	// This option is not required.
+	ContentType func(marshaler cqrs.CommandEventMarshaler) string
+}
+
+func (c Config) Validate() error {
+	if c.Title == "" {
+		return errors.New("missing Title")
+	}
+	if c.Version == "" {
+		return errors.New("missing Version")
+	}
+
+	return nil
+}
+
+// Generator generates an AsyncAPI 3.0 document of the commands and events of the registered buses and processors,
+// so the API documentation is kept in sync with the code.
+//
+// Every topic is documented as a channel with the messages published to it or consumed from it.
+// Handlers of the processors are documented as receive operations, and the buses as send operations.
+// The message payload schemas are generated from the Go types, following the encoding/json rules.
+//
+// The processors should be registered after all handlers are added.
+// Event group processors are not supported.
+type Generator struct {
+	config  Config
+	schemas *schemaGenerator
+
+	channels   map[string]Channel
+	operations map[string]Operation
+	messages   map[string]Message
+}
+
+// NewGenerator creates a new Generator.
+func NewGenerator(config Config) (*Generator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Generator{
+		config:     config,
+		schemas:    newSchemaGenerator(),
+		channels:   map[string]Channel{},
+		operations: map[string]Operation{},
+		messages:   map[string]Message{},
+	}, nil
+}
+
+// AddCommandProcessor documents the commands consumed by the processor's handlers.
+// The operations are named after the handlers.
+func (g *Generator) AddCommandProcessor(processor *cqrs.CommandProcessor) error {
+	for _, handler := range processor.Handlers() {
+		topic, err := processor.HandlerTopic(handler)
+		if err != nil {
+			return err
+		}
+
+		messageID, err := g.addMessage(processor.Marshaler(), handler.NewCommand())
+		if err != nil {
+			return err
+		}
+
+		if err := g.addOperation(handler.HandlerName(), OperationActionReceive, topic, messageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddEventProcessor documents the events consumed by the processor's handlers.
+// The operations are named after the handlers, like the router's handlers.
+//
+// Polymorphic handlers (see cqrs.NewPolymorphicEventHandler) are documented with the schema of NewEvent,
+// as the events of the family can't be listed.
+func (g *Generator) AddEventProcessor(processor *cqrs.EventProcessor) error {
+	for _, handler := range processor.Handlers() {
+		topics, err := processor.HandlerTopics(handler)
+		if err != nil {
+			return err
+		}
+
+		messageID, err := g.addMessage(processor.Marshaler(), handler.NewEvent())
+		if err != nil {
+			return err
+		}
+
+		for _, topic := range topics {
+			operationID := handler.HandlerName()
+			if len(topics) > 1 {
+				operationID += "_" + topic
+			}
+
+			if err := g.addOperation(operationID, OperationActionReceive, topic, messageID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddCommandBus documents the commands sent with the bus.
+// The commands are not known by the bus, so they must be provided.
+// The operations are named "send_" followed by the command name.
+func (g *Generator) AddCommandBus(bus *cqrs.CommandBus, commands ...any) error {
+	for _, command := range commands {
+		topic, err := bus.CommandTopic(command)
+		if err != nil {
+			return err
+		}
+
+		messageID, err := g.addMessage(bus.Marshaler(), command)
+		if err != nil {
+			return err
+		}
+
+		if err := g.addOperation("send_"+messageID, OperationActionSend, topic, messageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddEventBus documents the events registered in cqrs.EventBusConfig.PublishedEvents.
+// If IntegrationEventsConfig is set, only the internal topics are documented.
+// The operations are named "publish_" followed by the event name.
+func (g *Generator) AddEventBus(bus *cqrs.EventBus) error {
+	for _, event := range bus.PublishedEvents() {
+		topic, err := bus.EventTopic(event)
+		if err != nil {
+			return err
+		}
+
+		messageID, err := g.addMessage(bus.Marshaler(), event)
+		if err != nil {
+			return err
+		}
+
+		if err := g.addOperation("publish_"+messageID, OperationActionSend, topic, messageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Document returns the generated document.
+func (g *Generator) Document() Document {
+	return Document{
+		AsyncAPI: Version,
+		Info: Info{
+			Title:       g.config.Title,
+			Version:     g.config.Version,
+			Description: g.config.Description,
+		},
+		Channels:   g.channels,
+		Operations: g.operations,
+		Components: Components{
+			Messages: g.messages,
+			Schemas:  g.schemas.schemas,
+		},
+	}
+}
+
+// JSON returns the generated document marshaled to JSON.
+func (g *Generator) JSON() ([]byte, error) {
+	return json.MarshalIndent(g.Document(), "", "  ")
+}
+
+func (g *Generator) addMessage(marshaler cqrs.CommandEventMarshaler, v any) (string, error) {
+	name := marshaler.Name(v)
+	messageID := componentKey(name)
+
+	msg, ok := g.messages[messageID]
+	if !ok {
+		g.messages[messageID] = Message{
+			Name:        name,
+			ContentType: g.contentType(marshaler),
+			Payload:     g.schemas.schemaOf(reflect.TypeOf(v)),
+		}
+		return messageID, nil
+	}
+	if msg.Name != name {
+		return "", errors.Errorf("messages %s and %s have the same message ID %s", msg.Name, name, messageID)
+	}
+
+	return messageID, nil
+}
+
+func (g *Generator) addOperation(operationID string, action OperationAction, topic string, messageID string) error {
+	operationID = componentKey(operationID)
+	if _, ok := g.operations[operationID]; ok {
+		return errors.Errorf("operation %s is documented more than once", operationID)
+	}
+
+	channelID := componentKey(topic)
+	channel, ok := g.channels[channelID]
+	if !ok {
+		channel = Channel{
+			Address:  topic,
+			Messages: map[string]Reference{},
+		}
+	}
+	if channel.Address != topic {
+		return errors.Errorf("topics %s and %s have the same channel ID %s", channel.Address, topic, channelID)
+	}
+
+	channel.Messages[messageID] = Reference{Ref: "#/components/messages/" + messageID}
+	g.channels[channelID] = channel
+
+	g.operations[operationID] = Operation{
+		Action:  action,
+		Channel: Reference{Ref: "#/channels/" + channelID},
+		Messages: []Reference{
+			{Ref: "#/channels/" + channelID + "/messages/" + messageID},
+		},
+	}
+
+	return nil
+}
+
+func (g *Generator) contentType(marshaler cqrs.CommandEventMarshaler) string {
+	if g.config.ContentType != nil {
+		if contentType := g.config.ContentType(marshaler); contentType != "" {
+			return contentType
+		}
+	}
+
+	switch marshaler.(type) {
+	case cqrs.ProtobufMarshaler, *cqrs.ProtobufMarshaler:
+		return "application/x-protobuf"
+	default:
+		return "application/json"
+	}
+}
+
+var invalidKeyCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// componentKey replaces the characters not allowed in the keys of the document's maps.
+func componentKey(name string) string {
+	return invalidKeyCharacters.ReplaceAllString(name, "_")
+}
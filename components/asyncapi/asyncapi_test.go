@@ -0,0 +1,217 @@
+package asyncapi_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/asyncapi"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type PlaceOrder struct {
+	OrderID string `json:"order_id"`
+}
+
+type OrderPlaced struct {
+	OrderID string `json:"order_id"`
+	Note    string `json:"note,omitempty"`
+}
+
+func TestGenerator(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	marshaler := cqrs.JSONMarshaler{}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(pubSub, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands." + params.CommandName, nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "commands." + params.CommandName, nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return pubSub, nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	err = commandProcessor.AddHandlers(cqrs.NewCommandHandler("PlaceOrderHandler", func(ctx context.Context, cmd *PlaceOrder) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	eventBus, err := cqrs.NewEventBusWithConfig(pubSub, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler:       marshaler,
+		PublishedEvents: []any{&OrderPlaced{}},
+	})
+	require.NoError(t, err)
+
+	eventProcessor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return pubSub, nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	err = eventProcessor.AddHandlers(cqrs.NewEventHandler("OrderPlacedHandler", func(ctx context.Context, event *OrderPlaced) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	generator, err := asyncapi.NewGenerator(asyncapi.Config{
+		Title:   "Orders",
+		Version: "1.0.0",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, generator.AddCommandBus(commandBus, &PlaceOrder{}))
+	require.NoError(t, generator.AddCommandProcessor(commandProcessor))
+	require.NoError(t, generator.AddEventBus(eventBus))
+	require.NoError(t, generator.AddEventProcessor(eventProcessor))
+
+	doc, err := generator.JSON()
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"asyncapi": "3.0.0",
+		"info": {"title": "Orders", "version": "1.0.0"},
+		"channels": {
+			"commands.asyncapi_test.PlaceOrder": {
+				"address": "commands.asyncapi_test.PlaceOrder",
+				"messages": {
+					"asyncapi_test.PlaceOrder": {"$ref": "#/components/messages/asyncapi_test.PlaceOrder"}
+				}
+			},
+			"events": {
+				"address": "events",
+				"messages": {
+					"asyncapi_test.OrderPlaced": {"$ref": "#/components/messages/asyncapi_test.OrderPlaced"}
+				}
+			}
+		},
+		"operations": {
+			"send_asyncapi_test.PlaceOrder": {
+				"action": "send",
+				"channel": {"$ref": "#/channels/commands.asyncapi_test.PlaceOrder"},
+				"messages": [{"$ref": "#/channels/commands.asyncapi_test.PlaceOrder/messages/asyncapi_test.PlaceOrder"}]
+			},
+			"PlaceOrderHandler": {
+				"action": "receive",
+				"channel": {"$ref": "#/channels/commands.asyncapi_test.PlaceOrder"},
+				"messages": [{"$ref": "#/channels/commands.asyncapi_test.PlaceOrder/messages/asyncapi_test.PlaceOrder"}]
+			},
+			"publish_asyncapi_test.OrderPlaced": {
+				"action": "send",
+				"channel": {"$ref": "#/channels/events"},
+				"messages": [{"$ref": "#/channels/events/messages/asyncapi_test.OrderPlaced"}]
+			},
+			"OrderPlacedHandler": {
+				"action": "receive",
+				"channel": {"$ref": "#/channels/events"},
+				"messages": [{"$ref": "#/channels/events/messages/asyncapi_test.OrderPlaced"}]
+			}
+		},
+		"components": {
+			"messages": {
+				"asyncapi_test.PlaceOrder": {
+					"name": "asyncapi_test.PlaceOrder",
+					"contentType": "application/json",
+					"payload": {"$ref": "#/components/schemas/asyncapi_test.PlaceOrder"}
+				},
+				"asyncapi_test.OrderPlaced": {
+					"name": "asyncapi_test.OrderPlaced",
+					"contentType": "application/json",
+					"payload": {"$ref": "#/components/schemas/asyncapi_test.OrderPlaced"}
+				}
+			},
+			"schemas": {
+				"asyncapi_test.PlaceOrder": {
+					"type": "object",
+					"properties": {"order_id": {"type": "string"}},
+					"required": ["order_id"]
+				},
+				"asyncapi_test.OrderPlaced": {
+					"type": "object",
+					"properties": {"order_id": {"type": "string"}, "note": {"type": "string"}},
+					"required": ["order_id"]
+				}
+			}
+		}
+	}`, string(doc))
+}
+
+func TestGenerator_duplicate_operation(t *testing.T) {
+	commandBus, err := cqrs.NewCommandBusWithConfig(
+		gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+		cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+		},
+	)
+	require.NoError(t, err)
+
+	generator, err := asyncapi.NewGenerator(asyncapi.Config{Title: "Orders", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	err = generator.AddCommandBus(commandBus, &PlaceOrder{}, &PlaceOrder{})
+	assert.ErrorContains(t, err, "operation send_asyncapi_test.PlaceOrder is documented more than once")
+}
+
+func TestGenerator_duplicate_message_id(t *testing.T) {
+	commandBus, err := cqrs.NewCommandBusWithConfig(
+		gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+		cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands." + params.CommandName, nil
+			},
+			Marshaler: cqrs.JSONMarshaler{
+				GenerateName: func(v interface{}) string {
+					switch v.(type) {
+					case *PlaceOrder:
+						return "orders/place"
+					default:
+						return "orders place"
+					}
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	generator, err := asyncapi.NewGenerator(asyncapi.Config{Title: "Orders", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	err = generator.AddCommandBus(commandBus, &PlaceOrder{}, &OrderPlaced{})
+	assert.ErrorContains(t, err, "messages orders/place and orders place have the same message ID orders_place")
+}
+
+func TestNewGenerator_invalid_config(t *testing.T) {
+	_, err := asyncapi.NewGenerator(asyncapi.Config{Version: "1.0.0"})
+	assert.ErrorContains(t, err, "missing Title")
+
+	_, err = asyncapi.NewGenerator(asyncapi.Config{Title: "Orders"})
+	assert.ErrorContains(t, err, "missing Version")
+}
@@ -0,0 +1,71 @@
+package asyncapi
+
+// Version is the version of the AsyncAPI specification of the generated documents.
+const Version = "3.0.0"
+
+// Document is an AsyncAPI 3.0 document.
+// Only the parts of the specification which can be generated from the code are supported.
+type Document struct {
+	AsyncAPI           string               `json:"asyncapi"`
+	Info               Info                 `json:"info"`
+	DefaultContentType string               `json:"defaultContentType,omitempty"`
+	Channels           map[string]Channel   `json:"channels,omitempty"`
+	Operations         map[string]Operation `json:"operations,omitempty"`
+	Components         Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Channel is a topic to which the messages are published.
+type Channel struct {
+	Address  string               `json:"address"`
+	Messages map[string]Reference `json:"messages"`
+}
+
+type OperationAction string
+
+const (
+	// OperationActionSend is the action of the buses, which publish the messages to the channel.
+	OperationActionSend OperationAction = "send"
+
+	// OperationActionReceive is the action of the processors' handlers, which consume the messages from the channel.
+	OperationActionReceive OperationAction = "receive"
+)
+
+type Operation struct {
+	Action   OperationAction `json:"action"`
+	Channel  Reference       `json:"channel"`
+	Messages []Reference     `json:"messages"`
+}
+
+// Reference is a JSON Reference to another part of the document.
+type Reference struct {
+	Ref string `json:"$ref"`
+}
+
+type Components struct {
+	Messages map[string]Message `json:"messages,omitempty"`
+	Schemas  map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Message struct {
+	// Name is the name of the command or event, as returned by the marshaler.
+	Name        string  `json:"name"`
+	ContentType string  `json:"contentType,omitempty"`
+	Payload     *Schema `json:"payload"`
+}
+
+// Schema is a JSON Schema of the message payload, generated from the Go type.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
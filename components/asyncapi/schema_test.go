@@ -0,0 +1,103 @@
+package asyncapi_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/asyncapi"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type Audit struct {
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrderLine struct {
+	SKU      string  `json:"sku"`
+	Quantity int32   `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+type Category struct {
+	Name   string    `json:"name"`
+	Parent *Category `json:"parent,omitempty"`
+}
+
+type UpdateOrder struct {
+	Audit
+
+	OrderID    string            `json:"order_id"`
+	Lines      []OrderLine       `json:"lines"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Category   Category          `json:"category"`
+	Paid       bool              `json:"paid"`
+	Attachment []byte            `json:"attachment,omitempty"`
+	Timeout    time.Duration     `json:"timeout"`
+	Extra      json.RawMessage   `json:"extra"`
+	Untagged   string
+	Ignored    string `json:"-"`
+	internal   string
+}
+
+func TestGenerator_schemas(t *testing.T) {
+	commandBus, err := cqrs.NewCommandBusWithConfig(
+		gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+		cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+		},
+	)
+	require.NoError(t, err)
+
+	generator, err := asyncapi.NewGenerator(asyncapi.Config{Title: "Orders", Version: "1.0.0"})
+	require.NoError(t, err)
+
+	require.NoError(t, generator.AddCommandBus(commandBus, &UpdateOrder{internal: "unused"}))
+
+	schemas, err := json.Marshal(generator.Document().Components.Schemas)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"asyncapi_test.UpdateOrder": {
+			"type": "object",
+			"properties": {
+				"created_at": {"type": "string", "format": "date-time"},
+				"order_id": {"type": "string"},
+				"lines": {"type": "array", "items": {"$ref": "#/components/schemas/asyncapi_test.OrderLine"}},
+				"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+				"category": {"$ref": "#/components/schemas/asyncapi_test.Category"},
+				"paid": {"type": "boolean"},
+				"attachment": {"type": "string", "format": "byte"},
+				"timeout": {"type": "integer", "format": "int64"},
+				"extra": {},
+				"Untagged": {"type": "string"}
+			},
+			"required": ["created_at", "order_id", "lines", "category", "paid", "timeout", "extra", "Untagged"]
+		},
+		"asyncapi_test.OrderLine": {
+			"type": "object",
+			"properties": {
+				"sku": {"type": "string"},
+				"quantity": {"type": "integer", "format": "int32"},
+				"price": {"type": "number", "format": "double"}
+			},
+			"required": ["sku", "quantity", "price"]
+		},
+		"asyncapi_test.Category": {
+			"type": "object",
+			"properties": {
+				"name": {"type": "string"},
+				"parent": {"$ref": "#/components/schemas/asyncapi_test.Category"}
+			},
+			"required": ["name"]
+		}
+	}`, string(schemas))
+}
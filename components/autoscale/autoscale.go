@@ -0,0 +1,304 @@
+package autoscale
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// windowBuckets is the number of buckets the Config.Window is split into.
+// The window slides by one bucket at a time.
+const windowBuckets = 10
+
+// HandlerSignals contains the signals for scaling the consumers of the router's handler,
+// measured in the last Config.Window.
+type HandlerSignals struct {
+	HandlerName string `json:"handler_name"`
+
+	// ConsumptionRate is the number of messages handled per second, including failed ones.
+	ConsumptionRate float64 `json:"consumption_rate"`
+
+	// ErrorRate is the fraction (from 0 to 1) of handled messages for which the handler returned an error.
+	ErrorRate float64 `json:"error_rate"`
+
+	// Backlog is the number of messages waiting to be consumed by the handler.
+	// If the subscriber can't tell it, but reports the consume delay, it's estimated as the number of messages
+	// consumed during the delay. It's -1 if it can't be estimated.
+	//
+	// See message.SubscriberWithLag and message.LagTrackingSubscriberDecorator.
+	Backlog int64 `json:"backlog"`
+
+	// BacklogEstimated is true if the Backlog is estimated from the consume delay.
+	BacklogEstimated bool `json:"backlog_estimated"`
+
+	// EstimatedDrainTime is the time of consuming the backlog at the current ConsumptionRate.
+	// It's 0 if there's no backlog, and -1 if the backlog is not known or the handler doesn't consume messages.
+	EstimatedDrainTime time.Duration `json:"estimated_drain_time"`
+}
+
+// SignalsProvider provides the signals for scaling the consumers.
+// It's implemented by Monitor, and it's used by NewPrometheusCollector and NewHTTPHandler.
+type SignalsProvider interface {
+	// Signals returns the signals of all handlers, sorted by the handler name.
+	Signals(ctx context.Context) []HandlerSignals
+
+	// HandlerSignals returns the signals of the handler.
+	// If there's no such handler, the error is message.HandlerNotFoundError.
+	HandlerSignals(ctx context.Context, handlerName string) (HandlerSignals, error)
+}
+
+// Config holds the Monitor's configuration options.
+type Config struct {
+	// Window is the period in which the rates are measured.
+	// If not provided, one minute is used.
+	Window time.Duration
+
+	// BacklogTimeout limits the time of getting the backlog of a handler from the subscriber.
+	// If not provided, 5 seconds is used.
+	BacklogTimeout time.Duration
+
+	// Clock is used to measure the rates.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *Config) setDefaults() {
+	if c.Window == 0 {
+		c.Window = time.Minute
+	}
+	if c.BacklogTimeout == 0 {
+		c.BacklogTimeout = time.Second * 5
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Window < time.Second {
+		return errors.Errorf("Window must be at least 1s, got %s", c.Window)
+	}
+	if c.BacklogTimeout < 0 {
+		return errors.New("BacklogTimeout must be positive")
+	}
+
+	return nil
+}
+
+// Monitor measures the consumption rate, the error rate, and the backlog of the router's handlers.
+// They are intended as the input signals for scaling the consumers (for example, with KEDA or HPA),
+// exposed with NewPrometheusCollector or NewHTTPHandler.
+//
+// Keep in mind that the signals of a single replica are measured.
+// When scaling multiple replicas, aggregate the rates (for example, with Prometheus).
+//
+// Monitor adds a router-level middleware, so it should be created before the handlers' middlewares are added.
+// Otherwise, the messages retried by the middlewares added earlier are counted once.
+type Monitor struct {
+	router *message.Router
+	config Config
+
+	startedAt time.Time
+
+	windows     map[string]*rateWindow
+	windowsLock sync.Mutex
+}
+
+// NewMonitor creates a new Monitor and adds its middleware to the router.
+func NewMonitor(router *message.Router, config Config) (*Monitor, error) {
+	if router == nil {
+		return nil, errors.New("missing router")
+	}
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	m := &Monitor{
+		router:    router,
+		config:    config,
+		startedAt: config.Clock.Now(),
+		windows:   map[string]*rateWindow{},
+	}
+
+	router.AddMiddleware(m.middleware)
+
+	return m, nil
+}
+
+// Signals returns the signals of all router's handlers, sorted by name.
+func (m *Monitor) Signals(ctx context.Context) []HandlerSignals {
+	handlers := m.router.Handlers()
+
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	signals := make([]HandlerSignals, 0, len(names))
+	for _, name := range names {
+		signals = append(signals, m.handlerSignals(ctx, name))
+	}
+
+	return signals
+}
+
+// HandlerSignals returns the signals of the handler.
+// If the router has no such handler, the error is message.HandlerNotFoundError.
+func (m *Monitor) HandlerSignals(ctx context.Context, handlerName string) (HandlerSignals, error) {
+	if _, ok := m.router.Handlers()[handlerName]; !ok {
+		return HandlerSignals{}, message.HandlerNotFoundError{HandlerName: handlerName}
+	}
+
+	return m.handlerSignals(ctx, handlerName), nil
+}
+
+func (m *Monitor) handlerSignals(ctx context.Context, handlerName string) HandlerSignals {
+	now := m.config.Clock.Now()
+	signals := HandlerSignals{
+		HandlerName: handlerName,
+		Backlog:     -1,
+	}
+
+	m.windowsLock.Lock()
+	window, ok := m.windows[handlerName]
+	var processed, errored uint64
+	if ok {
+		processed, errored = window.Sum(now)
+	}
+	m.windowsLock.Unlock()
+
+	measured := m.measuredDuration(now)
+	if measured > 0 {
+		signals.ConsumptionRate = float64(processed) / measured.Seconds()
+	}
+	if processed > 0 {
+		signals.ErrorRate = float64(errored) / float64(processed)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.config.BacklogTimeout)
+	lag, err := m.router.HandlerLag(ctx, handlerName)
+	cancel()
+
+	switch {
+	case errors.Is(err, message.ErrLagNotSupported) || errors.As(err, &message.HandlerNotFoundError{}):
+		// backlog is not known (or the handler was removed in the meantime)
+	case err != nil:
+		m.config.Logger.Error("Cannot get handler lag", err, watermill.LogFields{"handler_name": handlerName})
+	case lag.Messages >= 0:
+		signals.Backlog = lag.Messages
+	case lag.ConsumeDelay > 0:
+		signals.Backlog = int64(math.Round(signals.ConsumptionRate * lag.ConsumeDelay.Seconds()))
+		signals.BacklogEstimated = true
+	}
+
+	switch {
+	case signals.Backlog == 0:
+		signals.EstimatedDrainTime = 0
+	case signals.Backlog < 0 || signals.ConsumptionRate == 0:
+		signals.EstimatedDrainTime = -1
+	default:
+		signals.EstimatedDrainTime = time.Duration(float64(signals.Backlog) / signals.ConsumptionRate * float64(time.Second))
+	}
+
+	return signals
+}
+
+// measuredDuration returns the duration of the window, which is shorter just after the Monitor is created.
+func (m *Monitor) measuredDuration(now time.Time) time.Duration {
+	bucketDuration := m.config.Window / windowBuckets
+
+	measured := bucketDuration*(windowBuckets-1) + now.Sub(now.Truncate(bucketDuration))
+	if sinceStart := now.Sub(m.startedAt); sinceStart < measured {
+		measured = sinceStart
+	}
+
+	return measured
+}
+
+func (m *Monitor) middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) (_ []*message.Message, err error) {
+		handlerName := message.HandlerNameFromCtx(msg.Context())
+
+		defer func() {
+			m.windowsLock.Lock()
+			defer m.windowsLock.Unlock()
+
+			window, ok := m.windows[handlerName]
+			if !ok {
+				window = newRateWindow(m.config.Window / windowBuckets)
+				m.windows[handlerName] = window
+			}
+			window.Add(m.config.Clock.Now(), err != nil)
+		}()
+
+		return h(msg)
+	}
+}
+
+type rateBucket struct {
+	start     time.Time
+	processed uint64
+	errored   uint64
+}
+
+// rateWindow counts the handled messages in buckets, which are reused when the window slides.
+type rateWindow struct {
+	bucketDuration time.Duration
+	buckets        [windowBuckets]rateBucket
+}
+
+func newRateWindow(bucketDuration time.Duration) *rateWindow {
+	return &rateWindow{bucketDuration: bucketDuration}
+}
+
+func (w *rateWindow) bucket(now time.Time) *rateBucket {
+	start := now.Truncate(w.bucketDuration)
+	b := &w.buckets[(start.UnixNano()/int64(w.bucketDuration))%windowBuckets]
+
+	if !b.start.Equal(start) {
+		*b = rateBucket{start: start}
+	}
+
+	return b
+}
+
+func (w *rateWindow) Add(now time.Time, errored bool) {
+	b := w.bucket(now)
+
+	b.processed++
+	if errored {
+		b.errored++
+	}
+}
+
+func (w *rateWindow) Sum(now time.Time) (processed uint64, errored uint64) {
+	oldestStart := now.Truncate(w.bucketDuration).Add(-w.bucketDuration * (windowBuckets - 1))
+
+	for _, b := range w.buckets {
+		if b.start.Before(oldestStart) || b.start.After(now) {
+			continue
+		}
+
+		processed += b.processed
+		errored += b.errored
+	}
+
+	return processed, errored
+}
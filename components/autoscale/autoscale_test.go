@@ -0,0 +1,210 @@
+package autoscale_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/autoscale"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type lagSubscriber struct {
+	message.Subscriber
+	lag message.SubscriberLag
+}
+
+func (s lagSubscriber) Lag(ctx context.Context, topic string) (message.SubscriberLag, error) {
+	return s.lag, nil
+}
+
+func TestMonitor(t *testing.T) {
+	logger := watermill.NopLogger{}
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	monitor, err := autoscale.NewMonitor(router, autoscale.Config{
+		Window: time.Second * 10,
+		Clock:  clock,
+	})
+	require.NoError(t, err)
+
+	var handled int64
+	failed := map[string]bool{}
+	failedLock := sync.Mutex{}
+
+	router.AddNoPublisherHandler(
+		"handler_a",
+		"topic_a",
+		lagSubscriber{Subscriber: pubSub, lag: message.SubscriberLag{Messages: 30}},
+		func(msg *message.Message) error {
+			defer atomic.AddInt64(&handled, 1)
+
+			failedLock.Lock()
+			defer failedLock.Unlock()
+
+			// every "error" message fails once, so it's not redelivered forever
+			if string(msg.Payload) == "error" && !failed[msg.UUID] {
+				failed[msg.UUID] = true
+				return errors.New("some error")
+			}
+			return nil
+		},
+	)
+
+	router.AddNoPublisherHandler("handler_b", "topic_b", pubSub, func(msg *message.Message) error {
+		return nil
+	})
+
+	router.AddNoPublisherHandler(
+		"handler_c",
+		"topic_c",
+		lagSubscriber{Subscriber: pubSub, lag: message.SubscriberLag{Messages: -1, ConsumeDelay: time.Second * 2}},
+		func(msg *message.Message) error {
+			atomic.AddInt64(&handled, 1)
+			return nil
+		},
+	)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	for _, payload := range []string{"ok", "ok", "ok", "ok", "ok", "ok", "ok", "ok", "error", "error"} {
+		require.NoError(t, pubSub.Publish("topic_a", message.NewMessage(watermill.NewUUID(), []byte(payload))))
+	}
+	for i := 0; i < 4; i++ {
+		require.NoError(t, pubSub.Publish("topic_c", message.NewMessage(watermill.NewUUID(), nil)))
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&handled) == 16
+	}, time.Second, time.Millisecond*10)
+
+	clock.Advance(time.Second * 4)
+
+	assert.Equal(t, []autoscale.HandlerSignals{
+		{
+			HandlerName:     "handler_a",
+			ConsumptionRate: 3,
+			ErrorRate:       2.0 / 12.0,
+			Backlog:         30,
+			// 30 messages at 3 messages per second
+			EstimatedDrainTime: time.Second * 10,
+		},
+		{
+			HandlerName:        "handler_b",
+			Backlog:            -1,
+			EstimatedDrainTime: -1,
+		},
+		{
+			HandlerName:     "handler_c",
+			ConsumptionRate: 1,
+			// 2 seconds of the consume delay at 1 message per second
+			Backlog:            2,
+			BacklogEstimated:   true,
+			EstimatedDrainTime: time.Second * 2,
+		},
+	}, monitor.Signals(context.Background()))
+
+	// the handled messages are out of the window
+	clock.Advance(time.Second * 10)
+
+	signals, err := monitor.HandlerSignals(context.Background(), "handler_a")
+	require.NoError(t, err)
+	assert.Equal(t, autoscale.HandlerSignals{
+		HandlerName:        "handler_a",
+		Backlog:            30,
+		EstimatedDrainTime: -1,
+	}, signals)
+
+	_, err = monitor.HandlerSignals(context.Background(), "unknown")
+	assert.ErrorAs(t, err, &message.HandlerNotFoundError{})
+}
+
+func TestMonitor_window_slides(t *testing.T) {
+	logger := watermill.NopLogger{}
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	monitor, err := autoscale.NewMonitor(router, autoscale.Config{
+		Window: time.Second * 10,
+		Clock:  clock,
+	})
+	require.NoError(t, err)
+
+	var handled int64
+	router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	publishAndWait := func(count int) {
+		t.Helper()
+
+		expected := atomic.LoadInt64(&handled) + int64(count)
+		for i := 0; i < count; i++ {
+			require.NoError(t, pubSub.Publish("topic", message.NewMessage(watermill.NewUUID(), nil)))
+		}
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt64(&handled) == expected
+		}, time.Second, time.Millisecond*10)
+	}
+
+	rate := func() float64 {
+		t.Helper()
+
+		signals, err := monitor.HandlerSignals(context.Background(), "handler")
+		require.NoError(t, err)
+		return signals.ConsumptionRate
+	}
+
+	publishAndWait(9)
+	clock.Advance(time.Second * 5)
+	publishAndWait(18)
+	clock.Advance(time.Second * 4)
+
+	// 27 messages in 9 seconds
+	assert.Equal(t, 3.0, rate())
+
+	// the first 9 messages are out of the window, which is 9 full buckets and the current one
+	clock.Advance(time.Second)
+	assert.Equal(t, 2.0, rate())
+}
+
+func TestNewMonitor_invalid_config(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	_, err = autoscale.NewMonitor(router, autoscale.Config{Window: time.Millisecond})
+	assert.Error(t, err)
+
+	_, err = autoscale.NewMonitor(nil, autoscale.Config{})
+	assert.Error(t, err)
+}
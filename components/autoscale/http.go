@@ -0,0 +1,71 @@
+package autoscale
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// NewHTTPHandler returns the HTTP handler exposing the signals as JSON:
+//
+//	GET /handlers         - signals of all handlers
+//	GET /handlers/{name}  - signals of the handler
+//
+// The handler's endpoint can be used by the Metrics API scaler of KEDA, for example with
+// the "consumption_rate" or "backlog" value location.
+// It can be mounted under a prefix of your own HTTP server, for example with http.StripPrefix.
+//
+// If logger is nil, watermill.NopLogger is used.
+func NewHTTPHandler(provider SignalsProvider, logger watermill.LoggerAdapter) http.Handler {
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	h := httpHandler{
+		logger: logger,
+	}
+
+	router := chi.NewRouter()
+	router.Get("/handlers", func(w http.ResponseWriter, r *http.Request) {
+		h.writeJSON(w, http.StatusOK, provider.Signals(r.Context()))
+	})
+	router.Get("/handlers/{name}", func(w http.ResponseWriter, r *http.Request) {
+		signals, err := provider.HandlerSignals(r.Context(), chi.URLParam(r, "name"))
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+
+		h.writeJSON(w, http.StatusOK, signals)
+	})
+
+	return router
+}
+
+type httpHandler struct {
+	logger watermill.LoggerAdapter
+}
+
+func (h httpHandler) writeError(w http.ResponseWriter, err error) {
+	if errors.As(err, &message.HandlerNotFoundError{}) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	h.logger.Error("Autoscale signals request failed", err, nil)
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+func (h httpHandler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("Cannot write autoscale signals response", err, nil)
+	}
+}
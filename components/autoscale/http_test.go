@@ -0,0 +1,50 @@
+package autoscale_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/autoscale"
+)
+
+func TestNewHTTPHandler(t *testing.T) {
+	server := httptest.NewServer(autoscale.NewHTTPHandler(testSignals, nil))
+	t.Cleanup(server.Close)
+
+	get := func(path string) *http.Response {
+		t.Helper()
+
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = resp.Body.Close()
+		})
+
+		return resp
+	}
+
+	resp := get("/handlers")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var signals []autoscale.HandlerSignals
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&signals))
+	assert.Equal(t, []autoscale.HandlerSignals(testSignals), signals)
+
+	resp = get("/handlers/handler_a")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "handler_a", body["handler_name"])
+	assert.Equal(t, 3.0, body["consumption_rate"])
+	assert.Equal(t, 30.0, body["backlog"])
+
+	resp = get("/handlers/unknown")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
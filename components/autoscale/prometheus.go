@@ -0,0 +1,71 @@
+package autoscale
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const labelKeyHandlerName = "handler_name"
+
+type prometheusCollector struct {
+	provider SignalsProvider
+
+	consumptionRate    *prometheus.Desc
+	errorRate          *prometheus.Desc
+	backlog            *prometheus.Desc
+	estimatedDrainTime *prometheus.Desc
+}
+
+// NewPrometheusCollector creates a Prometheus collector of the signals, which are read when the metrics are collected.
+// Backlog and drain time metrics are skipped for handlers with unknown backlog.
+//
+// The metrics can be used by the Prometheus scaler of KEDA, or by HPA with Prometheus Adapter.
+func NewPrometheusCollector(provider SignalsProvider, namespace string, subsystem string) prometheus.Collector {
+	labels := []string{labelKeyHandlerName}
+
+	return prometheusCollector{
+		provider: provider,
+		consumptionRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "autoscale_consumption_rate"),
+			"The number of messages handled by the handler per second",
+			labels, nil,
+		),
+		errorRate: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "autoscale_error_rate"),
+			"The fraction of messages for which the handler returned an error",
+			labels, nil,
+		),
+		backlog: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "autoscale_backlog_messages"),
+			"The number of messages waiting to be consumed by the handler (may be estimated)",
+			labels, nil,
+		),
+		estimatedDrainTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "autoscale_estimated_drain_time_seconds"),
+			"The estimated time of consuming the backlog at the current consumption rate",
+			labels, nil,
+		),
+	}
+}
+
+func (c prometheusCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.consumptionRate
+	descs <- c.errorRate
+	descs <- c.backlog
+	descs <- c.estimatedDrainTime
+}
+
+func (c prometheusCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, s := range c.provider.Signals(context.Background()) {
+		metrics <- prometheus.MustNewConstMetric(c.consumptionRate, prometheus.GaugeValue, s.ConsumptionRate, s.HandlerName)
+		metrics <- prometheus.MustNewConstMetric(c.errorRate, prometheus.GaugeValue, s.ErrorRate, s.HandlerName)
+
+		if s.Backlog >= 0 {
+			metrics <- prometheus.MustNewConstMetric(c.backlog, prometheus.GaugeValue, float64(s.Backlog), s.HandlerName)
+		}
+		if s.EstimatedDrainTime >= 0 {
+			metrics <- prometheus.MustNewConstMetric(c.estimatedDrainTime, prometheus.GaugeValue, s.EstimatedDrainTime.Seconds(), s.HandlerName)
+		}
+	}
+}
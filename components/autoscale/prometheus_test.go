@@ -0,0 +1,72 @@
+package autoscale_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/autoscale"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type staticSignalsProvider []autoscale.HandlerSignals
+
+func (p staticSignalsProvider) Signals(ctx context.Context) []autoscale.HandlerSignals {
+	return p
+}
+
+func (p staticSignalsProvider) HandlerSignals(ctx context.Context, handlerName string) (autoscale.HandlerSignals, error) {
+	for _, s := range p {
+		if s.HandlerName == handlerName {
+			return s, nil
+		}
+	}
+
+	return autoscale.HandlerSignals{}, message.HandlerNotFoundError{HandlerName: handlerName}
+}
+
+var testSignals = staticSignalsProvider{
+	{
+		HandlerName:        "handler_a",
+		ConsumptionRate:    3,
+		ErrorRate:          0.5,
+		Backlog:            30,
+		EstimatedDrainTime: time.Second * 10,
+	},
+	{
+		HandlerName:        "handler_b",
+		ConsumptionRate:    1,
+		Backlog:            -1,
+		EstimatedDrainTime: -1,
+	},
+}
+
+func TestNewPrometheusCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(autoscale.NewPrometheusCollector(testSignals, "app", "")))
+
+	expected := `
+# HELP app_autoscale_backlog_messages The number of messages waiting to be consumed by the handler (may be estimated)
+# TYPE app_autoscale_backlog_messages gauge
+app_autoscale_backlog_messages{handler_name="handler_a"} 30
+# HELP app_autoscale_consumption_rate The number of messages handled by the handler per second
+# TYPE app_autoscale_consumption_rate gauge
+app_autoscale_consumption_rate{handler_name="handler_a"} 3
+app_autoscale_consumption_rate{handler_name="handler_b"} 1
+# HELP app_autoscale_error_rate The fraction of messages for which the handler returned an error
+# TYPE app_autoscale_error_rate gauge
+app_autoscale_error_rate{handler_name="handler_a"} 0.5
+app_autoscale_error_rate{handler_name="handler_b"} 0
+# HELP app_autoscale_estimated_drain_time_seconds The estimated time of consuming the backlog at the current consumption rate
+# TYPE app_autoscale_estimated_drain_time_seconds gauge
+app_autoscale_estimated_drain_time_seconds{handler_name="handler_a"} 10
+`
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
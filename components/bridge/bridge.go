@@ -0,0 +1,319 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// TransformFn transforms a message received from the source topic before it's published to the destination topic.
+// It can return multiple messages or no messages at all (to filter the message out).
+type TransformFn func(msg *message.Message) ([]*message.Message, error)
+
+// Route describes how messages are relayed from the source Pub/Sub to the destination Pub/Sub.
+type Route struct {
+	// Name identifies the route in logs and metrics.
+	// If empty, it's generated from SourceTopic and DestinationTopic.
+	Name string
+
+	// Subscriber is used to receive messages from SourceTopic.
+	Subscriber  message.Subscriber
+	SourceTopic string
+
+	// Publisher is used to publish messages to DestinationTopic.
+	// If DestinationTopic is empty, SourceTopic is used.
+	Publisher        message.Publisher
+	DestinationTopic string
+
+	// Transform is called for every received message. It's optional.
+	Transform TransformFn
+
+	// BatchSize is the maximum number of messages published with one Publish call.
+	// Received messages are acked after the whole batch is published.
+	//
+	// Keep in mind that many subscribers don't deliver the next message until the previous one is acked.
+	// In that case, batch is published after BatchTimeout, even if it's not full.
+	//
+	// Defaults to 1, which means that messages are not batched.
+	BatchSize int
+
+	// BatchTimeout is the maximum time the route waits to fill the batch.
+	// Defaults to 100ms. It's used only when BatchSize is greater than 1.
+	BatchTimeout time.Duration
+}
+
+func (r *Route) setDefaults() {
+	if r.DestinationTopic == "" {
+		r.DestinationTopic = r.SourceTopic
+	}
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("bridge_%s_to_%s", r.SourceTopic, r.DestinationTopic)
+	}
+	if r.BatchSize == 0 {
+		r.BatchSize = 1
+	}
+	if r.BatchTimeout == 0 {
+		r.BatchTimeout = time.Millisecond * 100
+	}
+}
+
+// Validate returns the route's error, if any.
+func (r Route) Validate() error {
+	var err error
+
+	if r.Subscriber == nil {
+		err = multierror.Append(err, errors.New("missing Subscriber"))
+	}
+	if r.SourceTopic == "" {
+		err = multierror.Append(err, errors.New("missing SourceTopic"))
+	}
+	if r.Publisher == nil {
+		err = multierror.Append(err, errors.New("missing Publisher"))
+	}
+	if r.BatchSize < 0 {
+		err = multierror.Append(err, errors.New("BatchSize must not be negative"))
+	}
+	if r.BatchTimeout < 0 {
+		err = multierror.Append(err, errors.New("BatchTimeout must not be negative"))
+	}
+
+	return err
+}
+
+// MessageTimestampFn returns the time when the message was originally published.
+// It returns false if the time is unknown.
+type MessageTimestampFn func(msg *message.Message) (time.Time, bool)
+
+type Config struct {
+	// Routes contains the relayed source and destination topics.
+	Routes []Route
+
+	// MessageTimestamp is used to calculate the lag of the relayed messages, reported by Metrics.
+	// If nil, the lag is not reported.
+	MessageTimestamp MessageTimestampFn
+
+	// Metrics is used to report relayed messages. It's optional.
+	// Use NewPrometheusMetrics for Prometheus metrics.
+	Metrics Metrics
+}
+
+func (c *Config) setDefaults() {
+	for i := range c.Routes {
+		c.Routes[i].setDefaults()
+	}
+	if c.Metrics == nil {
+		c.Metrics = noopMetrics{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c Config) Validate() error {
+	if len(c.Routes) == 0 {
+		return errors.New("routes must not be empty")
+	}
+
+	var err error
+	names := map[string]struct{}{}
+
+	for _, route := range c.Routes {
+		if routeErr := route.Validate(); routeErr != nil {
+			err = multierror.Append(err, errors.Wrapf(routeErr, "invalid route %s", route.Name))
+		}
+
+		if _, ok := names[route.Name]; ok {
+			err = multierror.Append(err, errors.Errorf("duplicated route name %s", route.Name))
+		}
+		names[route.Name] = struct{}{}
+	}
+
+	return err
+}
+
+// Bridge relays messages between Pub/Subs, for example, when migrating from one broker to another
+// or mirroring messages across regions.
+//
+// Messages are acked on the source only after they are published to the destination.
+type Bridge struct {
+	config Config
+	logger watermill.LoggerAdapter
+
+	running     chan struct{}
+	runningOnce sync.Once
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	routesWg  sync.WaitGroup
+}
+
+// NewBridge creates a new Bridge.
+func NewBridge(config Config, logger watermill.LoggerAdapter) (*Bridge, error) {
+	routes := make([]Route, len(config.Routes))
+	copy(routes, config.Routes)
+	config.Routes = routes
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return &Bridge{
+		config:  config,
+		logger:  logger,
+		running: make(chan struct{}),
+		closing: make(chan struct{}),
+	}, nil
+}
+
+// Run subscribes to all source topics and relays messages until the context is canceled or Close is called.
+func (b *Bridge) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, route := range b.config.Routes {
+		messages, err := route.Subscriber.Subscribe(ctx, route.SourceTopic)
+		if err != nil {
+			return errors.Wrapf(err, "cannot subscribe to %s for route %s", route.SourceTopic, route.Name)
+		}
+
+		b.routesWg.Add(1)
+		go func(route Route) {
+			defer b.routesWg.Done()
+			b.runRoute(ctx, route, messages)
+		}(route)
+	}
+
+	b.runningOnce.Do(func() {
+		close(b.running)
+	})
+
+	select {
+	case <-ctx.Done():
+	case <-b.closing:
+	}
+	cancel()
+
+	b.routesWg.Wait()
+
+	return nil
+}
+
+// Running is closed when Bridge is subscribed to all source topics.
+func (b *Bridge) Running() chan struct{} {
+	return b.running
+}
+
+// Close stops relaying messages.
+func (b *Bridge) Close() error {
+	b.closeOnce.Do(func() {
+		close(b.closing)
+	})
+
+	b.routesWg.Wait()
+
+	return nil
+}
+
+func (b *Bridge) runRoute(ctx context.Context, route Route, messages <-chan *message.Message) {
+	logger := b.logger.With(watermill.LogFields{
+		"route":             route.Name,
+		"source_topic":      route.SourceTopic,
+		"destination_topic": route.DestinationTopic,
+	})
+	logger.Debug("Starting bridge route", nil)
+
+	var batch []*message.Message
+	var batchTimeout <-chan time.Time
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				logger.Debug("Source subscriber closed, stopping bridge route", nil)
+				b.nackAll(batch)
+				return
+			}
+
+			if len(batch) == 0 && route.BatchSize > 1 {
+				batchTimeout = time.After(route.BatchTimeout)
+			}
+			batch = append(batch, msg)
+
+			if len(batch) < route.BatchSize {
+				continue
+			}
+		case <-batchTimeout:
+		case <-ctx.Done():
+			b.nackAll(batch)
+			return
+		}
+
+		b.relay(route, batch, logger)
+
+		batch = nil
+		batchTimeout = nil
+	}
+}
+
+func (b *Bridge) relay(route Route, batch []*message.Message, logger watermill.LoggerAdapter) {
+	start := time.Now()
+
+	toPublish, err := b.transform(route, batch)
+	if err == nil && len(toPublish) > 0 {
+		err = route.Publisher.Publish(route.DestinationTopic, toPublish...)
+	}
+
+	if err != nil {
+		logger.Error("Cannot relay messages", err, watermill.LogFields{
+			"messages_count": len(batch),
+		})
+		b.config.Metrics.MessagesRelayed(route.Name, len(batch), false)
+		b.nackAll(batch)
+		return
+	}
+
+	b.config.Metrics.MessagesRelayed(route.Name, len(batch), true)
+	b.config.Metrics.RelayDuration(route.Name, time.Since(start))
+
+	for _, msg := range batch {
+		if b.config.MessageTimestamp != nil {
+			if publishedAt, ok := b.config.MessageTimestamp(msg); ok {
+				b.config.Metrics.MessageLag(route.Name, time.Since(publishedAt))
+			}
+		}
+
+		msg.Ack()
+	}
+}
+
+func (b *Bridge) transform(route Route, batch []*message.Message) ([]*message.Message, error) {
+	if route.Transform == nil {
+		return batch, nil
+	}
+
+	var transformed []*message.Message
+	for _, msg := range batch {
+		msgs, err := route.Transform(msg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot transform message %s", msg.UUID)
+		}
+		transformed = append(transformed, msgs...)
+	}
+
+	return transformed, nil
+}
+
+func (b *Bridge) nackAll(batch []*message.Message) {
+	for _, msg := range batch {
+		msg.Nack()
+	}
+}
@@ -0,0 +1,252 @@
+package bridge_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/bridge"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestBridge(t *testing.T) {
+	logger := watermill.NewStdLogger(true, false)
+
+	source := gochannel.NewGoChannel(gochannel.Config{}, logger)
+	destination := gochannel.NewGoChannel(gochannel.Config{OutputChannelBuffer: 100}, logger)
+
+	b, err := bridge.NewBridge(bridge.Config{
+		Routes: []bridge.Route{
+			{
+				Subscriber:       source,
+				SourceTopic:      "orders",
+				Publisher:        destination,
+				DestinationTopic: "orders_mirror",
+			},
+			{
+				Subscriber:  source,
+				SourceTopic: "invoices",
+				Publisher:   destination,
+				Transform: func(msg *message.Message) ([]*message.Message, error) {
+					if string(msg.Payload) == "skip" {
+						return nil, nil
+					}
+
+					transformed := message.NewMessage(msg.UUID, []byte(strings.ToUpper(string(msg.Payload))))
+					return []*message.Message{transformed}, nil
+				},
+			},
+		},
+	}, logger)
+	require.NoError(t, err)
+
+	ordersMirror, err := destination.Subscribe(context.Background(), "orders_mirror")
+	require.NoError(t, err)
+	invoices, err := destination.Subscribe(context.Background(), "invoices")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, b.Run(ctx))
+	}()
+	<-b.Running()
+
+	require.NoError(t, source.Publish("orders", message.NewMessage("1", []byte("order"))))
+	require.NoError(t, source.Publish("invoices", message.NewMessage("2", []byte("skip"))))
+	require.NoError(t, source.Publish("invoices", message.NewMessage("3", []byte("invoice"))))
+
+	received, all := subscriber.BulkRead(ordersMirror, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, "1", received[0].UUID)
+	assert.Equal(t, "order", string(received[0].Payload))
+
+	received, all = subscriber.BulkRead(invoices, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, "3", received[0].UUID)
+	assert.Equal(t, "INVOICE", string(received[0].Payload))
+
+	require.NoError(t, b.Close())
+}
+
+type batchRecordingPublisher struct {
+	lock    sync.Mutex
+	batches [][]string
+}
+
+func (p *batchRecordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	var ids []string
+	for _, msg := range messages {
+		ids = append(ids, msg.UUID)
+	}
+	p.batches = append(p.batches, ids)
+
+	return nil
+}
+
+func (p *batchRecordingPublisher) Close() error {
+	return nil
+}
+
+func (p *batchRecordingPublisher) Batches() [][]string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.batches
+}
+
+type channelSubscriber struct {
+	messages chan *message.Message
+}
+
+func (s channelSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	return s.messages, nil
+}
+
+func (s channelSubscriber) Close() error {
+	return nil
+}
+
+func TestBridge_batching(t *testing.T) {
+	sub := channelSubscriber{messages: make(chan *message.Message, 10)}
+	pub := &batchRecordingPublisher{}
+
+	registry := prometheus.NewRegistry()
+	metrics, err := bridge.NewPrometheusMetrics(registry, "", "")
+	require.NoError(t, err)
+
+	publishedAt := time.Now().Add(-time.Minute)
+
+	b, err := bridge.NewBridge(bridge.Config{
+		Routes: []bridge.Route{
+			{
+				Name:         "batched",
+				Subscriber:   sub,
+				SourceTopic:  "source",
+				Publisher:    pub,
+				BatchSize:    3,
+				BatchTimeout: time.Millisecond * 50,
+			},
+		},
+		MessageTimestamp: func(msg *message.Message) (time.Time, bool) {
+			return publishedAt, true
+		},
+		Metrics: metrics,
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	var sent []*message.Message
+	for _, id := range []string{"1", "2", "3", "4"} {
+		msg := message.NewMessage(id, nil)
+		sent = append(sent, msg)
+		sub.messages <- msg
+	}
+
+	go func() {
+		assert.NoError(t, b.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, b.Close())
+	}()
+
+	for _, msg := range sent {
+		select {
+		case <-msg.Acked():
+		case <-time.After(time.Second):
+			t.Fatalf("message %s was not acked", msg.UUID)
+		}
+	}
+
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4"}}, pub.Batches())
+	expectedMetrics := `
+# HELP bridge_messages_relayed_total The total number of messages relayed by the bridge
+# TYPE bridge_messages_relayed_total counter
+bridge_messages_relayed_total{route="batched",success="true"} 4
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expectedMetrics), "bridge_messages_relayed_total"))
+
+	lagSamples, err := testutil.GatherAndCount(registry, "bridge_lag_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 1, lagSamples)
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(topic string, messages ...*message.Message) error {
+	return errors.New("publish failed")
+}
+
+func (failingPublisher) Close() error {
+	return nil
+}
+
+func TestBridge_nack_when_publish_fails(t *testing.T) {
+	sub := channelSubscriber{messages: make(chan *message.Message, 1)}
+
+	b, err := bridge.NewBridge(bridge.Config{
+		Routes: []bridge.Route{
+			{
+				Subscriber:  sub,
+				SourceTopic: "source",
+				Publisher:   failingPublisher{},
+			},
+		},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	msg := message.NewMessage("1", nil)
+	sub.messages <- msg
+
+	go func() {
+		assert.NoError(t, b.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, b.Close())
+	}()
+
+	select {
+	case <-msg.Nacked():
+	case <-msg.Acked():
+		t.Fatal("message should be nacked")
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := bridge.NewBridge(bridge.Config{}, nil)
+	assert.ErrorContains(t, err, "routes must not be empty")
+
+	_, err = bridge.NewBridge(bridge.Config{
+		Routes: []bridge.Route{
+			{SourceTopic: "topic"},
+		},
+	}, nil)
+	assert.ErrorContains(t, err, "missing Subscriber")
+	assert.ErrorContains(t, err, "missing Publisher")
+
+	_, err = bridge.NewBridge(bridge.Config{
+		Routes: []bridge.Route{
+			{Subscriber: pubSub, Publisher: pubSub, SourceTopic: "topic"},
+			{Subscriber: pubSub, Publisher: pubSub, SourceTopic: "topic"},
+		},
+	}, nil)
+	assert.ErrorContains(t, err, "duplicated route name bridge_topic_to_topic")
+}
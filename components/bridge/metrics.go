@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics receives the statistics of relayed messages.
+type Metrics interface {
+	// MessagesRelayed is called after relaying a batch of messages (a single message, when batching is disabled).
+	MessagesRelayed(route string, count int, success bool)
+
+	// RelayDuration is called with the time of transforming and publishing a batch of messages.
+	RelayDuration(route string, duration time.Duration)
+
+	// MessageLag is called with the time between the original publication and relaying a message.
+	// It's called only when Config.MessageTimestamp is set.
+	MessageLag(route string, lag time.Duration)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) MessagesRelayed(string, int, bool) {}
+
+func (noopMetrics) RelayDuration(string, time.Duration) {}
+
+func (noopMetrics) MessageLag(string, time.Duration) {}
+
+// PrometheusMetrics is a Metrics implementation based on Prometheus.
+type PrometheusMetrics struct {
+	messagesRelayedTotal *prometheus.CounterVec
+	relayTimeSeconds     *prometheus.HistogramVec
+	lagSeconds           *prometheus.HistogramVec
+}
+
+// NewPrometheusMetrics creates a new PrometheusMetrics and registers its metrics in the registry.
+// If registry is nil, the default Prometheus registerer is used.
+func NewPrometheusMetrics(registry prometheus.Registerer, namespace string, subsystem string) (*PrometheusMetrics, error) {
+	if registry == nil {
+		registry = prometheus.DefaultRegisterer
+	}
+
+	m := &PrometheusMetrics{
+		messagesRelayedTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bridge_messages_relayed_total",
+				Help:      "The total number of messages relayed by the bridge",
+			},
+			[]string{"route", "success"},
+		),
+		relayTimeSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bridge_relay_time_seconds",
+				Help:      "The time of transforming and publishing a batch of messages in seconds",
+			},
+			[]string{"route"},
+		),
+		lagSeconds: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bridge_lag_seconds",
+				Help:      "The time between the original publication and relaying a message in seconds",
+			},
+			[]string{"route"},
+		),
+	}
+
+	for _, c := range []prometheus.Collector{m.messagesRelayedTotal, m.relayTimeSeconds, m.lagSeconds} {
+		if err := registry.Register(c); err != nil {
+			return nil, errors.Wrap(err, "cannot register bridge metric")
+		}
+	}
+
+	return m, nil
+}
+
+func (m *PrometheusMetrics) MessagesRelayed(route string, count int, success bool) {
+	m.messagesRelayedTotal.
+		WithLabelValues(route, strconv.FormatBool(success)).
+		Add(float64(count))
+}
+
+func (m *PrometheusMetrics) RelayDuration(route string, duration time.Duration) {
+	m.relayTimeSeconds.WithLabelValues(route).Observe(duration.Seconds())
+}
+
+func (m *PrometheusMetrics) MessageLag(route string, lag time.Duration) {
+	m.lagSeconds.WithLabelValues(route).Observe(lag.Seconds())
+}
@@ -0,0 +1,222 @@
+// Package commandstatus records the processing status of commands, so it can be queried by the command UUID.
+//
+// It's useful for asynchronous commands when request-reply is not used: the caller returns the command UUID
+// (for example, in the HTTP response) and exposes the status endpoint, which is polled by the client.
+package commandstatus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+// ErrStatusNotFound is returned by Store.GetStatus when there is no status of the command.
+var ErrStatusNotFound = errors.New("command status not found")
+
+// Status is the processing status of the command.
+type Status string
+
+const (
+	// StatusReceived means that the command was accepted by the command bus and waits for processing.
+	StatusReceived Status = "received"
+
+	// StatusStarted means that the command handler started handling the command.
+	StatusStarted Status = "started"
+
+	// StatusSucceeded means that the command handler handled the command without an error.
+	StatusSucceeded Status = "succeeded"
+
+	// StatusFailed means that the command handler returned an error.
+	// The command may be still retried, depending on the configuration of the processor.
+	StatusFailed Status = "failed"
+)
+
+// IsFinal returns true if the command was handled, successfully or not.
+func (s Status) IsFinal() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// CommandStatus is the last recorded status of the command.
+type CommandStatus struct {
+	CommandUUID string
+	CommandName string
+
+	// HandlerName is the name of the command handler. It's empty for StatusReceived.
+	HandlerName string
+
+	Status Status
+
+	// Error is the error returned by the handler, for StatusFailed.
+	Error string
+
+	UpdatedAt time.Time
+}
+
+// Store persists command statuses keyed by the command UUID.
+type Store interface {
+	// SetStatus stores the status of the command, overwriting the previous one.
+	SetStatus(ctx context.Context, status CommandStatus) error
+
+	// GetStatus returns the last status of the command.
+	// If there is no status of the command, ErrStatusNotFound is returned.
+	GetStatus(ctx context.Context, commandUUID string) (CommandStatus, error)
+}
+
+// InMemoryStore is a Store that keeps statuses in memory.
+//
+// It's useful for tests and for a single-instance service.
+// Keep in mind that statuses are never removed and are lost when the process exits.
+type InMemoryStore struct {
+	statuses map[string]CommandStatus
+	lock     sync.RWMutex
+}
+
+// NewInMemoryStore creates a new InMemoryStore.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		statuses: map[string]CommandStatus{},
+	}
+}
+
+func (s *InMemoryStore) SetStatus(ctx context.Context, status CommandStatus) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.statuses[status.CommandUUID] = status
+
+	return nil
+}
+
+func (s *InMemoryStore) GetStatus(ctx context.Context, commandUUID string) (CommandStatus, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	status, ok := s.statuses[commandUUID]
+	if !ok {
+		return CommandStatus{}, ErrStatusNotFound
+	}
+
+	return status, nil
+}
+
+// Config configures Tracker.
+type Config struct {
+	// Store is used to persist the statuses. It is required.
+	Store Store
+
+	// Clock is used to set CommandStatus.UpdatedAt.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *Config) setDefaults() {
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Store == nil {
+		return errors.New("missing Store")
+	}
+
+	return nil
+}
+
+// Tracker records the statuses of commands with the hooks of cqrs.CommandBus and cqrs.CommandProcessor:
+//
+//	cqrs.CommandBusConfig{
+//		OnSend: tracker.OnSend,
+//		// ...
+//	}
+//
+//	cqrs.CommandProcessorConfig{
+//		OnHandle: tracker.OnHandle,
+//		// ...
+//	}
+//
+// If you already use the hooks, call Tracker's methods from them.
+//
+// Errors of the store are logged, and don't fail sending or handling the command,
+// so the status may be outdated when the store is unavailable.
+type Tracker struct {
+	config Config
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker(config Config) (*Tracker, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Tracker{
+		config: config,
+	}, nil
+}
+
+// OnSend records StatusReceived of the sent command. It's a cqrs.CommandBusOnSendFn.
+func (t *Tracker) OnSend(params cqrs.CommandBusOnSendParams) error {
+	t.setStatus(params.Message.Context(), CommandStatus{
+		CommandUUID: params.Message.UUID,
+		CommandName: params.CommandName,
+		Status:      StatusReceived,
+	})
+
+	return nil
+}
+
+// OnHandle records StatusStarted before handling the command, and StatusSucceeded or StatusFailed after.
+// It's a cqrs.CommandProcessorOnHandleFn.
+func (t *Tracker) OnHandle(params cqrs.CommandProcessorOnHandleParams) error {
+	ctx := params.Message.Context()
+
+	status := CommandStatus{
+		CommandUUID: params.Message.UUID,
+		CommandName: params.CommandName,
+		HandlerName: params.Handler.HandlerName(),
+		Status:      StatusStarted,
+	}
+	t.setStatus(ctx, status)
+
+	err := params.Handler.Handle(ctx, params.Command)
+
+	if err != nil {
+		status.Status = StatusFailed
+		status.Error = err.Error()
+	} else {
+		status.Status = StatusSucceeded
+	}
+	t.setStatus(ctx, status)
+
+	return err
+}
+
+// GetStatus returns the last status of the command.
+// If there is no status of the command, ErrStatusNotFound is returned.
+func (t *Tracker) GetStatus(ctx context.Context, commandUUID string) (CommandStatus, error) {
+	return t.config.Store.GetStatus(ctx, commandUUID)
+}
+
+func (t *Tracker) setStatus(ctx context.Context, status CommandStatus) {
+	status.UpdatedAt = t.config.Clock.Now()
+
+	if err := t.config.Store.SetStatus(ctx, status); err != nil {
+		t.config.Logger.Error("Cannot store command status", err, watermill.LogFields{
+			"command_uuid": status.CommandUUID,
+			"status":       status.Status,
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package commandstatus_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/commandstatus"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type PlaceOrder struct {
+	ID string
+}
+
+func TestTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(now)
+
+	tracker, err := commandstatus.NewTracker(commandstatus.Config{
+		Store: commandstatus.NewInMemoryStore(),
+		Clock: clock,
+	})
+	require.NoError(t, err)
+
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, logger)
+	marshaler := cqrs.JSONMarshaler{}
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(pubSub, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		OnSend: func(params cqrs.CommandBusOnSendParams) error {
+			// the command UUID is returned to the client
+			params.Message.UUID = params.Command.(*PlaceOrder).ID
+			return tracker.OnSend(params)
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "commands", nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return pubSub, nil
+		},
+		OnHandle:                 tracker.OnHandle,
+		AckCommandHandlingErrors: true,
+		Marshaler:                marshaler,
+	})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	err = commandProcessor.AddHandlers(cqrs.NewCommandHandler("place_order", func(ctx context.Context, cmd *PlaceOrder) error {
+		<-release
+		if cmd.ID == "failing" {
+			return errors.New("out of stock")
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+
+	_, err = tracker.GetStatus(context.Background(), "1")
+	assert.ErrorIs(t, err, commandstatus.ErrStatusNotFound)
+
+	// the command is sent before the processor is running, so it waits for processing
+	require.NoError(t, commandBus.Send(context.Background(), &PlaceOrder{ID: "1"}))
+	assertStatus(t, tracker, commandstatus.CommandStatus{
+		CommandUUID: "1",
+		CommandName: "commandstatus_test.PlaceOrder",
+		Status:      commandstatus.StatusReceived,
+		UpdatedAt:   now,
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	require.Eventually(t, func() bool {
+		status, err := tracker.GetStatus(context.Background(), "1")
+		return err == nil && status.Status == commandstatus.StatusStarted
+	}, time.Second, time.Millisecond*10)
+
+	clock.Advance(time.Second)
+	release <- struct{}{}
+
+	require.Eventually(t, func() bool {
+		status, err := tracker.GetStatus(context.Background(), "1")
+		return err == nil && status.Status.IsFinal()
+	}, time.Second, time.Millisecond*10)
+
+	assertStatus(t, tracker, commandstatus.CommandStatus{
+		CommandUUID: "1",
+		CommandName: "commandstatus_test.PlaceOrder",
+		HandlerName: "place_order",
+		Status:      commandstatus.StatusSucceeded,
+		UpdatedAt:   now.Add(time.Second),
+	})
+
+	require.NoError(t, commandBus.Send(context.Background(), &PlaceOrder{ID: "failing"}))
+	release <- struct{}{}
+
+	require.Eventually(t, func() bool {
+		status, err := tracker.GetStatus(context.Background(), "failing")
+		return err == nil && status.Status.IsFinal()
+	}, time.Second, time.Millisecond*10)
+
+	assertStatus(t, tracker, commandstatus.CommandStatus{
+		CommandUUID: "failing",
+		CommandName: "commandstatus_test.PlaceOrder",
+		HandlerName: "place_order",
+		Status:      commandstatus.StatusFailed,
+		Error:       "out of stock",
+		UpdatedAt:   now.Add(time.Second),
+	})
+}
+
+func TestTracker_store_error(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+
+	tracker, err := commandstatus.NewTracker(commandstatus.Config{
+		Store:  failingStore{},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	handled := false
+	err = tracker.OnHandle(cqrs.CommandProcessorOnHandleParams{
+		Handler: cqrs.NewCommandHandler("place_order", func(ctx context.Context, cmd *PlaceOrder) error {
+			handled = true
+			return nil
+		}),
+		CommandName: "PlaceOrder",
+		Command:     &PlaceOrder{ID: "1"},
+		Message:     message.NewMessage("1", nil),
+	})
+	require.NoError(t, err)
+
+	assert.True(t, handled)
+	assert.Len(t, logger.Captured()[watermill.ErrorLogLevel], 2)
+}
+
+func TestNewTracker_missing_store(t *testing.T) {
+	_, err := commandstatus.NewTracker(commandstatus.Config{})
+	assert.Error(t, err)
+}
+
+func assertStatus(t *testing.T, tracker *commandstatus.Tracker, expected commandstatus.CommandStatus) {
+	t.Helper()
+
+	status, err := tracker.GetStatus(context.Background(), expected.CommandUUID)
+	require.NoError(t, err)
+	assert.Equal(t, expected, status)
+}
+
+type failingStore struct{}
+
+func (failingStore) SetStatus(ctx context.Context, status commandstatus.CommandStatus) error {
+	return errors.New("store unavailable")
+}
+
+func (failingStore) GetStatus(ctx context.Context, commandUUID string) (commandstatus.CommandStatus, error) {
+	return commandstatus.CommandStatus{}, errors.New("store unavailable")
+}
@@ -0,0 +1,28 @@
+package cqrs
+
+import (
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func handlerAtMostOnce(defaultAtMostOnce bool, overrides map[string]bool, handlerName string) bool {
+	if atMostOnce, ok := overrides[handlerName]; ok {
+		return atMostOnce
+	}
+
+	return defaultAtMostOnce
+}
+
+// handleAtMostOnce acks the message before calling handle, and doesn't return handle's error,
+// so the message is neither redelivered nor retried by the router's middlewares.
+func handleAtMostOnce(msg *message.Message, logger watermill.LoggerAdapter, handle func() error) error {
+	msg.Ack()
+
+	if err := handle(); err != nil {
+		logger.Error("Error when handling message, not retrying (at-most-once delivery)", err, watermill.LogFields{
+			"message_uuid": msg.UUID,
+		})
+	}
+
+	return nil
+}
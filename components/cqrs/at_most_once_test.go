@@ -0,0 +1,119 @@
+package cqrs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ackedBeforeHandling reports if the handled message was acked before the handler was called.
+func ackedBeforeHandling(ctx context.Context) bool {
+	select {
+	case <-cqrs.OriginalMessageFromCtx(ctx).Acked():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestEventProcessor_AtMostOnce(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	msg, err := marshaler.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+
+	ackedBefore := make(chan bool, 1)
+	handler := cqrs.NewEventHandler("handler", func(ctx context.Context, event *TestEvent) error {
+		ackedBefore <- ackedBeforeHandling(ctx)
+		return errors.New("push notification failed")
+	})
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{AtMostOnce: true}, handler, msg)
+
+	requireAcked(t, msg)
+	requireAckedBeforeHandling(t, ackedBefore)
+}
+
+func TestEventProcessor_AtMostOnceHandlers_override(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	msg, err := marshaler.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+
+	handler := cqrs.NewEventHandler("handler", func(ctx context.Context, event *TestEvent) error {
+		return errors.New("handler failed")
+	})
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{
+		AtMostOnce:         true,
+		AtMostOnceHandlers: map[string]bool{"handler": false},
+	}, handler, msg)
+
+	requireNacked(t, msg)
+}
+
+func TestCommandProcessor_AtMostOnce(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	msg, err := marshaler.Marshal(&TestCommand{ID: "1"})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+		router,
+		cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return &mockSubscriber{MessagesToSend: []*message.Message{msg}}, nil
+			},
+			Marshaler:                marshaler,
+			AtMostOnceHandlers:       map[string]bool{"handler": true},
+			AckCommandHandlingErrors: false,
+		},
+	)
+	require.NoError(t, err)
+
+	ackedBefore := make(chan bool, 1)
+
+	err = commandProcessor.AddHandlers(
+		cqrs.NewCommandHandler("handler", func(ctx context.Context, cmd *TestCommand) error {
+			ackedBefore <- ackedBeforeHandling(ctx)
+			return errors.New("handler failed")
+		}),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	requireAcked(t, msg)
+	requireAckedBeforeHandling(t, ackedBefore)
+}
+
+func requireAckedBeforeHandling(t *testing.T, ackedBefore <-chan bool) {
+	t.Helper()
+
+	select {
+	case acked := <-ackedBefore:
+		assert.True(t, acked, "message should be acked before handling")
+	case <-time.After(time.Second):
+		t.Fatal("message not handled")
+	}
+}
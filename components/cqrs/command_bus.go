@@ -3,6 +3,7 @@ package cqrs
 import (
 	"context"
 	stdErrors "errors"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/pkg/errors"
@@ -24,15 +25,88 @@ type CommandBusConfig struct {
 	// It is required.
 	Marshaler CommandEventMarshaler
 
+	// NewUUID is used to generate the UUID of the sent message, instead of the UUID generated by Marshaler.
+	// It allows, for example, sortable ULIDs (watermill.NewULID) or deterministic UUIDs derived from
+	// the command's business key (watermill.NewDeterministicUUID), so the duplicates can be detected by the consumers.
+	//
+	// This option is not required.
+	NewUUID CommandBusNewUUIDFn
+
+	// StandardMetadata enables setting the standard metadata (produced_at, producer name and instance ID, schema name)
+	// on every published message, before OnSend is called.
+	//
+	// This option is not required.
+	StandardMetadata *StandardMetadataConfig
+
+	// TTL if set makes every published message expire after TTL since publishing (see message.SetTTL),
+	// unless the message already has the expiry set. Expired messages are dropped by the consumers
+	// using middleware.DropExpired, or by Pub/Subs with native message TTL.
+	//
+	// This option is not required.
+	TTL time.Duration
+
+	// MessageSize if not nil enables measuring the size of marshaled payloads and enforcing the maximum size,
+	// with an error, compression, or claim-check offload. See MessageSizeConfig.
+	// The size is checked just before publishing, after all modifications of the message.
+	// Commands recorded by DryRunSink or dispatched to LocalCommandProcessor are not checked.
+	//
+	// This option is not required.
+	MessageSize *MessageSizeConfig
+
+	// Retry if not nil enables retrying publishing of commands when the publisher returns an error.
+	// See SendRetryConfig.
+	//
+	// This option is not required.
+	Retry *SendRetryConfig
+
+	// DryRunSink if not nil enables the dry-run mode: the command is marshaled and OnSend is called,
+	// but the message is recorded to the sink instead of being published.
+	// In the dry-run mode, commands are not dispatched to LocalCommandProcessor either.
+	//
+	// This option is not required.
+	DryRunSink DryRunSink
+
+	// Shadow if not nil enables the shadow mode: the message is published to its topic
+	// and then to the shadow topic. See ShadowPublishConfig.
+	// It can't be used together with DryRunSink.
+	//
+	// This option is not required.
+	Shadow *ShadowPublishConfig
+
 	// Logger instance used to log.
 	// If not provided, watermill.NopLogger is used.
 	Logger watermill.LoggerAdapter
+
+	// LocalCommandProcessor makes CommandBus dispatch commands directly to the handlers of the processor
+	// running in the same process, instead of publishing them.
+	// Commands without a handler in LocalCommandProcessor are published as usual.
+	//
+	// Locally dispatched commands still go through OnSend, the router's middlewares, and CommandProcessorConfig.OnHandle.
+	// Keep in mind that Send blocks until the command is handled and returns the handler's error.
+	//
+	// This option is not required.
+	LocalCommandProcessor *CommandProcessor
 }
 
 func (c *CommandBusConfig) setDefaults() {
 	if c.Logger == nil {
 		c.Logger = watermill.NopLogger{}
 	}
+	if c.StandardMetadata != nil {
+		standardMetadata := *c.StandardMetadata
+		standardMetadata.setDefaults()
+		c.StandardMetadata = &standardMetadata
+	}
+	if c.MessageSize != nil {
+		messageSize := *c.MessageSize
+		messageSize.setDefaults()
+		c.MessageSize = &messageSize
+	}
+	if c.Retry != nil {
+		retry := *c.Retry
+		retry.setDefaults()
+		c.Retry = &retry
+	}
 }
 
 func (c CommandBusConfig) Validate() error {
@@ -46,6 +120,31 @@ func (c CommandBusConfig) Validate() error {
 		err = stdErrors.Join(err, errors.New("missing GeneratePublishTopic"))
 	}
 
+	if c.TTL < 0 {
+		err = stdErrors.Join(err, errors.New("TTL must not be negative"))
+	}
+
+	if c.MessageSize != nil {
+		if messageSizeErr := c.MessageSize.Validate(); messageSizeErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(messageSizeErr, "invalid MessageSize config"))
+		}
+	}
+
+	if c.Retry != nil {
+		if retryErr := c.Retry.Validate(); retryErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(retryErr, "invalid Retry config"))
+		}
+	}
+
+	if c.DryRunSink != nil && c.Shadow != nil {
+		err = stdErrors.Join(err, errors.New("DryRunSink and Shadow can't be used together"))
+	}
+	if c.Shadow != nil {
+		if shadowErr := c.Shadow.Validate(); shadowErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(shadowErr, "invalid Shadow config"))
+		}
+	}
+
 	return err
 }
 
@@ -56,6 +155,13 @@ type CommandBusGeneratePublishTopicParams struct {
 	Command     any
 }
 
+type CommandBusNewUUIDFn func(params CommandBusNewUUIDParams) (string, error)
+
+type CommandBusNewUUIDParams struct {
+	CommandName string
+	Command     any
+}
+
 type CommandBusOnSendFn func(params CommandBusOnSendParams) error
 
 type CommandBusOnSendParams struct {
@@ -64,6 +170,9 @@ type CommandBusOnSendParams struct {
 
 	// Message is never nil and can be modified.
 	Message *message.Message
+
+	// PayloadSize is the size of the marshaled payload in bytes.
+	PayloadSize int
 }
 
 // CommandBus transports commands to command handlers.
@@ -129,11 +238,54 @@ func (c CommandBus) SendWithModifiedMessage(ctx context.Context, cmd any, modify
 		}
 	}
 
-	if err := c.publisher.Publish(topicName, msg); err != nil {
+	if c.config.LocalCommandProcessor != nil && c.config.DryRunSink == nil {
+		handled, err := c.config.LocalCommandProcessor.handleLocally(msg)
+		if handled {
+			return err
+		}
+	}
+
+	if c.config.Retry != nil {
+		c.config.Retry.setIdempotencyKey(msg)
+	}
+
+	if err := resealPayload(c.config.Marshaler, msg); err != nil {
 		return err
 	}
 
-	return nil
+	if c.config.MessageSize != nil && c.config.DryRunSink == nil {
+		if err := c.config.MessageSize.apply(msg, c.config.Marshaler.Name(cmd)); err != nil {
+			return err
+		}
+	}
+
+	publish := func() error {
+		return publishWithMode(c.publisher, topicName, msg, c.config.DryRunSink, c.config.Shadow, c.config.Logger)
+	}
+
+	if c.config.Retry == nil {
+		return publish()
+	}
+
+	return c.config.Retry.retry(msg, c.config.Logger, publish)
+}
+
+// CommandTopic returns the topic to which the command is sent.
+func (c CommandBus) CommandTopic(command any) (string, error) {
+	topicName, err := c.config.GeneratePublishTopic(CommandBusGeneratePublishTopicParams{
+		CommandName: c.config.Marshaler.Name(command),
+		Command:     command,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "cannot generate topic name")
+	}
+
+	return topicName, nil
+}
+
+// Marshaler returns the marshaler used to marshal the commands.
+func (c CommandBus) Marshaler() CommandEventMarshaler {
+	return c.config.Marshaler
 }
 
 func (c CommandBus) newMessage(ctx context.Context, command any) (*message.Message, string, error) {
@@ -143,21 +295,36 @@ func (c CommandBus) newMessage(ctx context.Context, command any) (*message.Messa
 	}
 
 	commandName := c.config.Marshaler.Name(command)
-	topicName, err := c.config.GeneratePublishTopic(CommandBusGeneratePublishTopicParams{
-		CommandName: commandName,
-		Command:     command,
-	})
+	topicName, err := c.CommandTopic(command)
 	if err != nil {
-		return nil, "", errors.Wrap(err, "cannot generate topic name")
+		return nil, "", err
+	}
+
+	if c.config.NewUUID != nil {
+		msg.UUID, err = c.config.NewUUID(CommandBusNewUUIDParams{
+			CommandName: commandName,
+			Command:     command,
+		})
+		if err != nil {
+			return nil, "", errors.Wrap(err, "cannot generate message UUID")
+		}
 	}
 
 	msg.SetContext(ctx)
 
+	if c.config.StandardMetadata != nil {
+		c.config.StandardMetadata.apply(msg, commandName)
+	}
+	if c.config.TTL > 0 {
+		applyTTL(msg, c.config.TTL)
+	}
+
 	if c.config.OnSend != nil {
 		err := c.config.OnSend(CommandBusOnSendParams{
 			CommandName: commandName,
 			Command:     command,
 			Message:     msg,
+			PayloadSize: len(msg.Payload),
 		})
 		if err != nil {
 			return nil, "", errors.Wrap(err, "cannot execute OnSend")
@@ -0,0 +1,125 @@
+package cqrs
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// IdempotencyKeyMetadataKey is the metadata key of the idempotency key set by CommandBus with SendRetryConfig.
+const IdempotencyKeyMetadataKey = "idempotency_key"
+
+// SendRetryConfig makes CommandBus retry publishing the command when the publisher returns an error,
+// for example, when the broker is temporarily unavailable.
+//
+// Every retried command has the same idempotency key in the IdempotencyKeyMetadataKey metadata,
+// so the command handled more than once (because the publisher returned an error after the command was published)
+// can be deduplicated by the consumer, for example, with middleware.Deduplicator and IdempotencyKeyHasher.
+type SendRetryConfig struct {
+	// MaxRetries is the maximum number of retries (not counting the first attempt). Defaults to 3.
+	MaxRetries int
+
+	// InitialInterval is the delay before the first retry. It's doubled with every retry. Defaults to 100ms.
+	InitialInterval time.Duration
+
+	// MaxInterval is the maximum delay between retries. Defaults to 5s.
+	MaxInterval time.Duration
+
+	// IsRetryable decides if publishing should be retried after the error.
+	// If nil, all errors are retried.
+	IsRetryable func(err error) bool
+
+	// GenerateIdempotencyKey generates the idempotency key of the command's message.
+	// It's not called if the message already has the idempotency key (for example, set in OnSend).
+	// If not provided, the message's UUID is used.
+	GenerateIdempotencyKey func(msg *message.Message) string
+
+	// Clock is used to wait between retries.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (c *SendRetryConfig) setDefaults() {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.InitialInterval == 0 {
+		c.InitialInterval = time.Millisecond * 100
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = time.Second * 5
+	}
+	if c.GenerateIdempotencyKey == nil {
+		c.GenerateIdempotencyKey = func(msg *message.Message) string {
+			return msg.UUID
+		}
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+}
+
+func (c SendRetryConfig) Validate() error {
+	if c.MaxRetries < 0 {
+		return errors.New("MaxRetries must not be negative")
+	}
+	if c.InitialInterval < 0 || c.MaxInterval < 0 {
+		return errors.New("InitialInterval and MaxInterval must not be negative")
+	}
+
+	return nil
+}
+
+// IdempotencyKeyHasher returns the idempotency key of the message, or its UUID if the key is missing.
+// It can be used as middleware.Deduplicator.KeyFactory to deduplicate retried commands.
+func IdempotencyKeyHasher(msg *message.Message) (string, error) {
+	if key := msg.Metadata.Get(IdempotencyKeyMetadataKey); key != "" {
+		return key, nil
+	}
+
+	return msg.UUID, nil
+}
+
+func (c SendRetryConfig) setIdempotencyKey(msg *message.Message) {
+	if msg.Metadata.Get(IdempotencyKeyMetadataKey) != "" {
+		return
+	}
+	msg.Metadata.Set(IdempotencyKeyMetadataKey, c.GenerateIdempotencyKey(msg))
+}
+
+func (c SendRetryConfig) retry(msg *message.Message, logger watermill.LoggerAdapter, publish func() error) error {
+	interval := c.InitialInterval
+
+	for retryNum := 1; ; retryNum++ {
+		err := publish()
+		if err == nil {
+			return nil
+		}
+
+		if retryNum > c.MaxRetries || (c.IsRetryable != nil && !c.IsRetryable(err)) {
+			return err
+		}
+
+		logger.Info("Cannot send command, retrying", watermill.LogFields{
+			"message_uuid":    msg.UUID,
+			"idempotency_key": msg.Metadata.Get(IdempotencyKeyMetadataKey),
+			"retry_no":        retryNum,
+			"wait_time":       interval,
+			"err":             err.Error(),
+		})
+
+		select {
+		case <-msg.Context().Done():
+			return errors.Wrap(err, "context done while retrying send")
+		case <-c.Clock.After(interval):
+		}
+
+		interval *= 2
+		if interval > c.MaxInterval {
+			interval = c.MaxInterval
+		}
+	}
+}
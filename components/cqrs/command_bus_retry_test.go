@@ -0,0 +1,132 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type flakyPublisher struct {
+	failures int
+	attempts []*message.Message
+}
+
+func (p *flakyPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.attempts = append(p.attempts, messages...)
+	if len(p.attempts) <= p.failures {
+		return errors.New("broker unavailable")
+	}
+	return nil
+}
+
+func (p *flakyPublisher) Close() error {
+	return nil
+}
+
+func newRetryingCommandBus(t *testing.T, pub message.Publisher, retry cqrs.SendRetryConfig) *cqrs.CommandBus {
+	t.Helper()
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(pub, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		Retry:     &retry,
+	})
+	require.NoError(t, err)
+
+	return commandBus
+}
+
+func TestCommandBus_Retry(t *testing.T) {
+	pub := &flakyPublisher{failures: 2}
+
+	commandBus := newRetryingCommandBus(t, pub, cqrs.SendRetryConfig{
+		InitialInterval: time.Millisecond,
+	})
+
+	require.NoError(t, commandBus.Send(context.Background(), &TestCommand{ID: "1"}))
+
+	require.Len(t, pub.attempts, 3)
+
+	key := pub.attempts[0].Metadata.Get(cqrs.IdempotencyKeyMetadataKey)
+	assert.Equal(t, pub.attempts[0].UUID, key)
+	for _, msg := range pub.attempts {
+		assert.Equal(t, key, msg.Metadata.Get(cqrs.IdempotencyKeyMetadataKey))
+
+		hash, err := cqrs.IdempotencyKeyHasher(msg)
+		require.NoError(t, err)
+		assert.Equal(t, key, hash)
+	}
+}
+
+func TestCommandBus_Retry_max_retries(t *testing.T) {
+	pub := &flakyPublisher{failures: 10}
+
+	commandBus := newRetryingCommandBus(t, pub, cqrs.SendRetryConfig{
+		MaxRetries:      2,
+		InitialInterval: time.Millisecond,
+	})
+
+	err := commandBus.Send(context.Background(), &TestCommand{ID: "1"})
+	assert.ErrorContains(t, err, "broker unavailable")
+	assert.Len(t, pub.attempts, 3)
+}
+
+func TestCommandBus_Retry_not_retryable(t *testing.T) {
+	pub := &flakyPublisher{failures: 10}
+
+	commandBus := newRetryingCommandBus(t, pub, cqrs.SendRetryConfig{
+		InitialInterval: time.Millisecond,
+		IsRetryable: func(err error) bool {
+			return false
+		},
+	})
+
+	err := commandBus.Send(context.Background(), &TestCommand{ID: "1"})
+	assert.Error(t, err)
+	assert.Len(t, pub.attempts, 1)
+}
+
+func TestCommandBus_Retry_custom_idempotency_key(t *testing.T) {
+	pub := &flakyPublisher{}
+
+	commandBus := newRetryingCommandBus(t, pub, cqrs.SendRetryConfig{
+		GenerateIdempotencyKey: func(msg *message.Message) string {
+			return "custom"
+		},
+	})
+
+	require.NoError(t, commandBus.Send(context.Background(), &TestCommand{ID: "1"}))
+	err := commandBus.SendWithModifiedMessage(context.Background(), &TestCommand{ID: "2"}, func(msg *message.Message) error {
+		msg.Metadata.Set(cqrs.IdempotencyKeyMetadataKey, "explicit")
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, pub.attempts, 2)
+	assert.Equal(t, "custom", pub.attempts[0].Metadata.Get(cqrs.IdempotencyKeyMetadataKey))
+	assert.Equal(t, "explicit", pub.attempts[1].Metadata.Get(cqrs.IdempotencyKeyMetadataKey))
+}
+
+func TestCommandBus_Retry_context_canceled(t *testing.T) {
+	pub := &flakyPublisher{failures: 10}
+
+	commandBus := newRetryingCommandBus(t, pub, cqrs.SendRetryConfig{
+		InitialInterval: time.Hour,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+
+	err := commandBus.Send(ctx, &TestCommand{ID: "1"})
+	assert.ErrorContains(t, err, "context done while retrying send")
+	assert.Len(t, pub.attempts, 1)
+}
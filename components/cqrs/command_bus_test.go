@@ -2,8 +2,10 @@ package cqrs_test
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
+	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -222,3 +224,194 @@ func TestCommandBus_Send_OnSend_error(t *testing.T) {
 	err = cb.Send(context.Background(), TestCommand{})
 	require.EqualError(t, err, "cannot execute OnSend: some error")
 }
+
+func TestCommandBus_Send_NewUUID(t *testing.T) {
+	publisher := newPublisherStub()
+
+	cb, err := cqrs.NewCommandBusWithConfig(
+		publisher,
+		cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			NewUUID: func(params cqrs.CommandBusNewUUIDParams) (string, error) {
+				return watermill.NewDeterministicUUID(params.CommandName + "/" + params.Command.(TestCommand).ID), nil
+			},
+			OnSend: func(params cqrs.CommandBusOnSendParams) error {
+				assert.NotEmpty(t, params.Message.UUID, "UUID should be set before OnSend")
+				return nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, cb.Send(context.Background(), TestCommand{ID: "1"}))
+	require.NoError(t, cb.Send(context.Background(), TestCommand{ID: "1"}))
+	require.NoError(t, cb.Send(context.Background(), TestCommand{ID: "2"}))
+
+	messages := publisher.messages["whatever"]
+	require.Len(t, messages, 3)
+
+	assert.Equal(t, watermill.NewDeterministicUUID("cqrs_test.TestCommand/1"), messages[0].UUID)
+	assert.Equal(t, messages[0].UUID, messages[1].UUID)
+	assert.NotEqual(t, messages[0].UUID, messages[2].UUID)
+}
+
+func TestCommandBus_Send_NewUUID_error(t *testing.T) {
+	cb, err := cqrs.NewCommandBusWithConfig(
+		newPublisherStub(),
+		cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			NewUUID: func(params cqrs.CommandBusNewUUIDParams) (string, error) {
+				return "", errors.New("missing business key")
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	err = cb.Send(context.Background(), TestCommand{})
+	require.EqualError(t, err, "cannot generate message UUID: missing business key")
+}
+
+func TestCommandBus_LocalCommandProcessor(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	var middlewareCalls []string
+	router.AddMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			middlewareCalls = append(middlewareCalls, message.HandlerNameFromCtx(msg.Context()))
+			return h(msg)
+		}
+	})
+
+	onHandleCalled := false
+
+	cp, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.CommandsPubSub, nil
+		},
+		OnHandle: func(params cqrs.CommandProcessorOnHandleParams) error {
+			onHandleCalled = true
+			return params.Handler.Handle(params.Message.Context(), params.Command)
+		},
+		Marshaler: ts.Marshaler,
+		Logger:    ts.Logger,
+	})
+	require.NoError(t, err)
+
+	handlerErr := errors.New("handler error")
+
+	err = cp.AddHandlers(cqrs.NewCommandHandler("local_handler", func(ctx context.Context, cmd *TestCommand) error {
+		if cmd.ID == "fail" {
+			return handlerErr
+		}
+		return nil
+	}))
+	require.NoError(t, err)
+
+	publisher := newPublisherStub()
+
+	onSendCalled := false
+
+	cb, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		OnSend: func(params cqrs.CommandBusOnSendParams) error {
+			onSendCalled = true
+			return nil
+		},
+		Marshaler:             ts.Marshaler,
+		LocalCommandProcessor: cp,
+	})
+	require.NoError(t, err)
+
+	err = cb.Send(context.Background(), &TestCommand{ID: "1"})
+	require.NoError(t, err)
+
+	assert.True(t, onSendCalled)
+	assert.True(t, onHandleCalled)
+	assert.Equal(t, []string{"local_handler"}, middlewareCalls)
+	assert.Empty(t, publisher.messages, "command handled locally should not be published")
+
+	err = cb.Send(context.Background(), &TestCommand{ID: "fail"})
+	assert.ErrorIs(t, err, handlerErr)
+
+	// commands without local handlers are published
+	err = cb.Send(context.Background(), &TestEvent{})
+	require.NoError(t, err)
+	assert.Len(t, publisher.messages["cqrs_test.TestEvent"], 1)
+}
+
+func TestCommandBus_LocalCommandProcessor_concurrent_AddHandlers(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	cp, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.CommandsPubSub, nil
+		},
+		Marshaler: ts.Marshaler,
+		Logger:    ts.Logger,
+	})
+	require.NoError(t, err)
+
+	err = cp.AddHandlers(cqrs.NewCommandHandler("local_handler", func(ctx context.Context, cmd *TestCommand) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	publisher := newPublisherStub()
+
+	cb, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		Marshaler:             ts.Marshaler,
+		LocalCommandProcessor: cp,
+	})
+	require.NoError(t, err)
+
+	const handlersCount = 50
+
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+
+		for i := 0; i < handlersCount; i++ {
+			err := cp.AddHandlers(cqrs.NewCommandHandler(fmt.Sprintf("added_handler_%d", i), func(ctx context.Context, cmd *TestEvent) error {
+				return nil
+			}))
+			assert.NoError(t, err)
+		}
+	}()
+
+	// sending until all handlers are added, to send while they are being added
+	sending := true
+	for i := 0; sending; i++ {
+		require.NoError(t, cb.Send(context.Background(), &TestCommand{ID: fmt.Sprint(i)}))
+
+		select {
+		case <-added:
+			sending = false
+		default:
+		}
+	}
+
+	assert.Empty(t, publisher.messages, "commands handled locally should not be published")
+}
@@ -0,0 +1,193 @@
+package cqrs
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	// DomainErrorMetadataKey is the metadata key of the DomainError's message, set on the failure event.
+	DomainErrorMetadataKey = "_watermill_domain_error"
+
+	// DomainErrorCommandNameMetadataKey is the metadata key of the rejected command's name, set on the failure event.
+	DomainErrorCommandNameMetadataKey = "_watermill_domain_error_command_name"
+
+	// DomainErrorCommandUUIDMetadataKey is the metadata key of the rejected command message's UUID,
+	// set on the failure event.
+	DomainErrorCommandUUIDMetadataKey = "_watermill_domain_error_command_uuid"
+)
+
+// DomainError is returned by a command handler when the command is rejected by the domain logic,
+// for example, because it violates a business rule, so retrying it makes no sense.
+//
+// If CommandProcessorConfig.DomainErrors is set, FailureEvent is published and the command is acked,
+// instead of nacking it. The error can be wrapped, and it can be returned as a value or a pointer.
+type DomainError struct {
+	// Err is the reason of the rejection. It is required.
+	Err error
+
+	// FailureEvent is the event published when the command is rejected, for example, OrderRejected.
+	// It is required.
+	FailureEvent any
+}
+
+func (e DomainError) Error() string {
+	if e.Err == nil {
+		return "domain error"
+	}
+
+	return e.Err.Error()
+}
+
+func (e DomainError) Unwrap() error {
+	return e.Err
+}
+
+// asDomainError finds the first DomainError (value or pointer) in err's chain.
+func asDomainError(err error) (DomainError, bool) {
+	var domainErr DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+
+	var domainErrPtr *DomainError
+	if errors.As(err, &domainErrPtr) && domainErrPtr != nil {
+		return *domainErrPtr, true
+	}
+
+	return DomainError{}, false
+}
+
+// DomainErrorParams are the params of CommandDomainErrorsConfig's functions.
+type DomainErrorParams struct {
+	CommandName    string
+	CommandMessage *message.Message
+	DomainError    DomainError
+}
+
+// CommandDomainErrorsConfig configures publishing the failure events of DomainError returned by the command handlers.
+//
+// The failure events are published with the EventBus, like any other events, so all options of EventBusConfig apply
+// (for example, NewUUID, StandardMetadata, or MessageSize).
+//
+// If EventBus is *EventBus, DomainErrorMetadataKey, DomainErrorCommandNameMetadataKey,
+// and DomainErrorCommandUUIDMetadataKey are set on the failure events. Other EventPublisher implementations
+// publish the failure events as they are.
+type CommandDomainErrorsConfig struct {
+	// EventBus publishes the failure events.
+	// If not provided, CommandProcessorConfig.EventBus is used. One of them is required.
+	EventBus EventPublisher
+
+	// GeneratePublishTopic overrides the topic of the failure events generated by EventBusConfig.GeneratePublishTopic.
+	// The topics of integration events are not overridden.
+	//
+	// This option is not required. It's supported only if EventBus is *EventBus.
+	GeneratePublishTopic func(params DomainErrorParams) (string, error)
+
+	// Metadata returns additional metadata of the failure event, set along with DomainErrorMetadataKey,
+	// DomainErrorCommandNameMetadataKey, and DomainErrorCommandUUIDMetadataKey.
+	// The metadata is set before EventBusConfig.OnPublish is called.
+	//
+	// This option is not required. It's supported only if EventBus is *EventBus.
+	Metadata func(params DomainErrorParams) message.Metadata
+}
+
+func (c CommandDomainErrorsConfig) Validate() error {
+	if c.EventBus == nil {
+		return errors.New("missing EventBus, and CommandProcessorConfig.EventBus is not set")
+	}
+
+	if _, ok := c.EventBus.(eventPublisherWithOverrides); !ok {
+		if c.GeneratePublishTopic != nil {
+			return errors.New("GeneratePublishTopic is supported only if EventBus is *EventBus")
+		}
+		if c.Metadata != nil {
+			return errors.New("Metadata is supported only if EventBus is *EventBus")
+		}
+	}
+
+	return nil
+}
+
+// handleDomainError publishes the failure event if err is a DomainError, and returns nil, so the command is acked.
+// If publishing fails, the error is returned, so the command is nacked and handled again.
+// Other errors are returned as they are.
+func (c *CommandDomainErrorsConfig) handleDomainError(
+	ctx context.Context,
+	cmdMsg *message.Message,
+	commandName string,
+	err error,
+	logger watermill.LoggerAdapter,
+) error {
+	if c == nil {
+		return err
+	}
+
+	domainErr, ok := asDomainError(err)
+	if !ok {
+		return err
+	}
+
+	params := DomainErrorParams{
+		CommandName:    commandName,
+		CommandMessage: cmdMsg,
+		DomainError:    domainErr,
+	}
+
+	if publishErr := c.publishFailureEvent(ctx, params); publishErr != nil {
+		return errors.Wrapf(publishErr, "cannot publish failure event of command rejected with: %s", err)
+	}
+
+	logger.Debug("Command rejected, failure event published", watermill.LogFields{
+		"message_uuid": cmdMsg.UUID,
+		"err":          err,
+	})
+
+	return nil
+}
+
+func (c *CommandDomainErrorsConfig) publishFailureEvent(ctx context.Context, params DomainErrorParams) error {
+	if params.DomainError.FailureEvent == nil {
+		return errors.New("missing FailureEvent")
+	}
+
+	overrides := eventPublishOverrides{
+		metadata: message.Metadata{
+			DomainErrorMetadataKey:            params.DomainError.Error(),
+			DomainErrorCommandNameMetadataKey: params.CommandName,
+			DomainErrorCommandUUIDMetadataKey: params.CommandMessage.UUID,
+		},
+	}
+	if c.Metadata != nil {
+		for k, v := range c.Metadata(params) {
+			overrides.metadata.Set(k, v)
+		}
+	}
+	if c.GeneratePublishTopic != nil {
+		overrides.generateTopic = func() (string, error) {
+			return c.GeneratePublishTopic(params)
+		}
+	}
+
+	publisher, ok := c.EventBus.(eventPublisherWithOverrides)
+	if !ok {
+		return c.EventBus.Publish(ctx, params.DomainError.FailureEvent)
+	}
+
+	return publisher.publishWithOverrides(ctx, params.DomainError.FailureEvent, overrides)
+}
+
+// eventPublisherWithOverrides is implemented by EventBus.
+type eventPublisherWithOverrides interface {
+	publishWithOverrides(ctx context.Context, event any, overrides eventPublishOverrides) error
+}
+
+// eventPublishOverrides overrides the topic and adds metadata of the event published by EventBus.
+type eventPublishOverrides struct {
+	generateTopic func() (string, error)
+	metadata      message.Metadata
+}
@@ -0,0 +1,373 @@
+package cqrs_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type failingPublisher struct{}
+
+func (failingPublisher) Publish(topic string, messages ...*message.Message) error {
+	return errors.New("publish failed")
+}
+
+func (failingPublisher) Close() error {
+	return nil
+}
+
+func TestCommandProcessor_DomainErrors(t *testing.T) {
+	errOutOfStock := errors.New("out of stock")
+
+	testCases := []struct {
+		Name        string
+		Publisher   message.Publisher
+		HandlerErr  error
+		ExpectedAck bool
+	}{
+		{
+			Name:      "domain_error",
+			Publisher: &capturingPublisher{},
+			HandlerErr: cqrs.DomainError{
+				Err:          errOutOfStock,
+				FailureEvent: &TestEvent{ID: "1"},
+			},
+			ExpectedAck: true,
+		},
+		{
+			Name:      "wrapped_domain_error",
+			Publisher: &capturingPublisher{},
+			HandlerErr: fmt.Errorf("cannot place order: %w", cqrs.DomainError{
+				Err:          errOutOfStock,
+				FailureEvent: &TestEvent{ID: "1"},
+			}),
+			ExpectedAck: true,
+		},
+		{
+			Name:      "domain_error_pointer",
+			Publisher: &capturingPublisher{},
+			HandlerErr: &cqrs.DomainError{
+				Err:          errOutOfStock,
+				FailureEvent: &TestEvent{ID: "1"},
+			},
+			ExpectedAck: true,
+		},
+		{
+			Name:        "other_error",
+			Publisher:   &capturingPublisher{},
+			HandlerErr:  errOutOfStock,
+			ExpectedAck: false,
+		},
+		{
+			Name:        "missing_failure_event",
+			Publisher:   &capturingPublisher{},
+			HandlerErr:  cqrs.DomainError{Err: errOutOfStock},
+			ExpectedAck: false,
+		},
+		{
+			Name:      "publish_failed",
+			Publisher: failingPublisher{},
+			HandlerErr: cqrs.DomainError{
+				Err:          errOutOfStock,
+				FailureEvent: &TestEvent{ID: "1"},
+			},
+			ExpectedAck: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			marshaler := cqrs.JSONMarshaler{}
+
+			msgToSend, err := marshaler.Marshal(&TestCommand{ID: "1"})
+			require.NoError(t, err)
+
+			mockSub := &mockSubscriber{
+				MessagesToSend: []*message.Message{
+					msgToSend,
+				},
+			}
+
+			router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+			require.NoError(t, err)
+
+			eventBus, err := cqrs.NewEventBusWithConfig(tc.Publisher, cqrs.EventBusConfig{
+				GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+					return "events", nil
+				},
+				NewUUID: func(params cqrs.EventBusNewUUIDParams) (string, error) {
+					return "event-uuid", nil
+				},
+				Marshaler: marshaler,
+			})
+			require.NoError(t, err)
+
+			commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+				router,
+				cqrs.CommandProcessorConfig{
+					GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+						return "commands", nil
+					},
+					SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+						return mockSub, nil
+					},
+					Marshaler:    marshaler,
+					EventBus:     eventBus,
+					DomainErrors: &cqrs.CommandDomainErrorsConfig{},
+				},
+			)
+			require.NoError(t, err)
+
+			err = commandProcessor.AddHandlers(cqrs.NewCommandHandler(
+				"handler", func(ctx context.Context, cmd *TestCommand) error {
+					return tc.HandlerErr
+				}),
+			)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go func() {
+				err := router.Run(ctx)
+				assert.NoError(t, err)
+			}()
+
+			<-router.Running()
+
+			select {
+			case <-msgToSend.Acked():
+				assert.True(t, tc.ExpectedAck, "message should be nacked")
+			case <-msgToSend.Nacked():
+				assert.False(t, tc.ExpectedAck, "message should be acked")
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for ack")
+			}
+
+			if !tc.ExpectedAck {
+				return
+			}
+
+			publisher := tc.Publisher.(*capturingPublisher)
+			require.Len(t, publisher.messages, 1)
+
+			failureEvent := publisher.messages[0]
+			assert.Equal(t, "event-uuid", failureEvent.UUID, "failure event should be published with the EventBus")
+			assert.Equal(t, "out of stock", failureEvent.Metadata.Get(cqrs.DomainErrorMetadataKey))
+			assert.Equal(t, "cqrs_test.TestCommand", failureEvent.Metadata.Get(cqrs.DomainErrorCommandNameMetadataKey))
+			assert.Equal(t, msgToSend.UUID, failureEvent.Metadata.Get(cqrs.DomainErrorCommandUUIDMetadataKey))
+
+			event := &TestEvent{}
+			require.NoError(t, marshaler.Unmarshal(failureEvent, event))
+			assert.Equal(t, "1", event.ID)
+
+			originalMsg := cqrs.OriginalMessageFromCtx(failureEvent.Context())
+			assert.Equal(t, msgToSend.UUID, originalMsg.UUID)
+		})
+	}
+}
+
+func TestCommandProcessor_DomainErrors_topic_and_metadata(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	msgToSend, err := marshaler.Marshal(&TestCommand{ID: "1"})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	publisher := &topicsPublisher{}
+	var published *message.Message
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		OnPublish: func(params cqrs.OnEventSendParams) error {
+			published = params.Message
+			return nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+		router,
+		cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return &mockSubscriber{MessagesToSend: []*message.Message{msgToSend}}, nil
+			},
+			Marshaler: marshaler,
+			DomainErrors: &cqrs.CommandDomainErrorsConfig{
+				EventBus: eventBus,
+				GeneratePublishTopic: func(params cqrs.DomainErrorParams) (string, error) {
+					return "failures." + params.CommandName, nil
+				},
+				Metadata: func(params cqrs.DomainErrorParams) message.Metadata {
+					return message.Metadata{"reason": params.DomainError.Error()}
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	err = commandProcessor.AddHandlers(cqrs.NewCommandHandler(
+		"handler", func(ctx context.Context, cmd *TestCommand) error {
+			return cqrs.DomainError{Err: errors.New("out of stock"), FailureEvent: &TestEvent{ID: "1"}}
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		err := router.Run(ctx)
+		assert.NoError(t, err)
+	}()
+
+	<-router.Running()
+
+	select {
+	case <-msgToSend.Acked():
+		// ok
+	case <-msgToSend.Nacked():
+		t.Fatal("nack received, message should be acked")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ack")
+	}
+
+	assert.Equal(t, []string{"failures.cqrs_test.TestCommand"}, publisher.Topics())
+	require.NotNil(t, published)
+	assert.Equal(t, "out of stock", published.Metadata.Get("reason"))
+	assert.Equal(t, "out of stock", published.Metadata.Get(cqrs.DomainErrorMetadataKey), "metadata should be set before OnPublish")
+}
+
+func TestCommandProcessorConfig_Validate_domain_errors(t *testing.T) {
+	_, err := cqrs.NewCommandProcessorWithConfig(nil, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "commands", nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return nil, nil
+		},
+		Marshaler:    cqrs.JSONMarshaler{},
+		DomainErrors: &cqrs.CommandDomainErrorsConfig{},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid DomainErrors config")
+	assert.Contains(t, err.Error(), "missing EventBus")
+}
+
+func TestCommandProcessor_DomainErrors_nested_publish(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	msgToSend, err := marshaler.Marshal(&TestCommand{ID: "1"})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	publisher := &topicsPublisher{}
+	var eventBus *cqrs.EventBus
+
+	eventBus, err = cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		OnPublish: func(params cqrs.OnEventSendParams) error {
+			if params.Message.Metadata.Get(cqrs.DomainErrorMetadataKey) == "" {
+				return nil
+			}
+			// the nested event is published with the failure event's context
+			return eventBus.Publish(params.Message.Context(), &TestEvent{ID: "nested"})
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+		router,
+		cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return &mockSubscriber{MessagesToSend: []*message.Message{msgToSend}}, nil
+			},
+			Marshaler: marshaler,
+			DomainErrors: &cqrs.CommandDomainErrorsConfig{
+				EventBus: eventBus,
+				GeneratePublishTopic: func(params cqrs.DomainErrorParams) (string, error) {
+					return "failures", nil
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	err = commandProcessor.AddHandlers(cqrs.NewCommandHandler(
+		"handler", func(ctx context.Context, cmd *TestCommand) error {
+			return cqrs.DomainError{Err: errors.New("out of stock"), FailureEvent: &TestEvent{ID: "1"}}
+		}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		err := router.Run(ctx)
+		assert.NoError(t, err)
+	}()
+
+	<-router.Running()
+
+	select {
+	case <-msgToSend.Acked():
+		// ok
+	case <-msgToSend.Nacked():
+		t.Fatal("nack received, message should be acked")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ack")
+	}
+
+	assert.Equal(t, []string{"events", "failures"}, publisher.Topics(), "overrides should not apply to the nested event")
+}
+
+type eventPublisherStub struct{}
+
+func (eventPublisherStub) Publish(ctx context.Context, event any) error {
+	return nil
+}
+
+func TestCommandDomainErrorsConfig_Validate_not_event_bus(t *testing.T) {
+	config := cqrs.CommandDomainErrorsConfig{EventBus: eventPublisherStub{}}
+	assert.NoError(t, config.Validate())
+
+	config.GeneratePublishTopic = func(params cqrs.DomainErrorParams) (string, error) {
+		return "failures", nil
+	}
+	assert.ErrorContains(t, config.Validate(), "GeneratePublishTopic is supported only if EventBus is *EventBus")
+
+	config = cqrs.CommandDomainErrorsConfig{
+		EventBus: eventPublisherStub{},
+		Metadata: func(params cqrs.DomainErrorParams) message.Metadata {
+			return nil
+		},
+	}
+	assert.ErrorContains(t, config.Validate(), "Metadata is supported only if EventBus is *EventBus")
+}
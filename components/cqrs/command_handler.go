@@ -2,6 +2,8 @@ package cqrs
 
 import (
 	"context"
+
+	"github.com/pkg/errors"
 )
 
 // CommandHandler receives a command defined by NewCommand and handles it with the Handle method.
@@ -57,3 +59,95 @@ func (c genericCommandHandler[Command]) Handle(ctx context.Context, cmd any) err
 	command := cmd.(*Command)
 	return c.handleFunc(ctx, command)
 }
+
+// CommandHandlerWithEvents is a CommandHandler which returns events that should be published
+// after the command is handled successfully.
+//
+// CommandHandlerWithEvents should be added to a CommandProcessor with CommandProcessorConfig.EventBus set.
+// The CommandProcessor will publish returned events using this EventBus, only if HandleWithEvents doesn't
+// return an error.
+type CommandHandlerWithEvents interface {
+	CommandHandler
+
+	// HandleWithEvents handles the command and returns events to publish.
+	HandleWithEvents(ctx context.Context, cmd any) ([]any, error)
+}
+
+// EventPublisher publishes events.
+// It's implemented by EventBus.
+type EventPublisher interface {
+	Publish(ctx context.Context, event any) error
+}
+
+type genericCommandHandlerWithEvents[Command any] struct {
+	handleFunc  func(ctx context.Context, cmd *Command) ([]any, error)
+	handlerName string
+}
+
+// NewCommandHandlerWithEvents creates a new CommandHandlerWithEvents implementation based on provided function
+// and command type inferred from function argument.
+//
+// Events returned by the function are published by the CommandProcessor after the command was handled without an error.
+func NewCommandHandlerWithEvents[Command any](
+	handlerName string,
+	handleFunc func(ctx context.Context, cmd *Command) ([]any, error),
+) CommandHandlerWithEvents {
+	return &genericCommandHandlerWithEvents[Command]{
+		handleFunc:  handleFunc,
+		handlerName: handlerName,
+	}
+}
+
+func (c genericCommandHandlerWithEvents[Command]) HandlerName() string {
+	return c.handlerName
+}
+
+func (c genericCommandHandlerWithEvents[Command]) NewCommand() any {
+	tVar := new(Command)
+	return tVar
+}
+
+func (c genericCommandHandlerWithEvents[Command]) HandleWithEvents(ctx context.Context, cmd any) ([]any, error) {
+	command := cmd.(*Command)
+	return c.handleFunc(ctx, command)
+}
+
+// Handle handles the command without publishing the events.
+// If any events are returned, an error is returned, because they would be lost.
+func (c genericCommandHandlerWithEvents[Command]) Handle(ctx context.Context, cmd any) error {
+	events, err := c.HandleWithEvents(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		return errors.Errorf(
+			"handler %s returned %d events which were not published, add it to CommandProcessor with EventBus configured",
+			c.handlerName,
+			len(events),
+		)
+	}
+
+	return nil
+}
+
+// commandHandlerPublishingEvents adapts CommandHandlerWithEvents to CommandHandler
+// by publishing returned events with the eventBus.
+type commandHandlerPublishingEvents struct {
+	CommandHandlerWithEvents
+	eventBus EventPublisher
+}
+
+func (c commandHandlerPublishingEvents) Handle(ctx context.Context, cmd any) error {
+	events, err := c.HandleWithEvents(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err := c.eventBus.Publish(ctx, event); err != nil {
+			return errors.Wrapf(err, "cannot publish event %T returned by handler %s", event, c.HandlerName())
+		}
+	}
+
+	return nil
+}
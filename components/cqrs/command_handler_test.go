@@ -30,3 +30,25 @@ func TestNewCommandHandler(t *testing.T) {
 	err := ch.Handle(context.Background(), cmdToSend)
 	assert.EqualError(t, err, "some error")
 }
+
+func TestNewCommandHandlerWithEvents(t *testing.T) {
+	cmdToSend := &SomeCommand{"bar"}
+
+	ch := cqrs.NewCommandHandlerWithEvents(
+		"some_handler",
+		func(ctx context.Context, cmd *SomeCommand) ([]any, error) {
+			assert.Equal(t, cmdToSend, cmd)
+			return []any{&TestEvent{ID: cmd.Foo}}, nil
+		},
+	)
+
+	assert.Equal(t, "some_handler", ch.HandlerName())
+	assert.Equal(t, &SomeCommand{}, ch.NewCommand())
+
+	events, err := ch.HandleWithEvents(context.Background(), cmdToSend)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{&TestEvent{ID: "bar"}}, events)
+
+	err = ch.Handle(context.Background(), cmdToSend)
+	assert.ErrorContains(t, err, "returned 1 events which were not published")
+}
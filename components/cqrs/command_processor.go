@@ -1,8 +1,11 @@
 package cqrs
 
 import (
+	"context"
 	stdErrors "errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -54,6 +57,50 @@ type CommandProcessorConfig struct {
 	// When you are using requestreply, you should use requestreply.PubSubBackendConfig.AckCommandErrors.
 	AckCommandHandlingErrors bool
 
+	// HandlerTimeout is the maximum duration of handling a single command by a handler.
+	// When it's exceeded, the context passed to the handler is canceled and HandlerTimeoutError is returned
+	// (so the message is nacked, unless AckCommandHandlingErrors is enabled), without waiting for the handler to return.
+	// The handler keeps running in the background until it returns, so it should respect the context cancellation.
+	// Disabled if 0.
+	HandlerTimeout time.Duration
+
+	// HandlerTimeouts overrides HandlerTimeout for the handlers with the given names.
+	HandlerTimeouts map[string]time.Duration
+
+	// AtMostOnce enables the at-most-once delivery: the message is acked before the handler is called,
+	// so it's never redelivered, even if the handler returns an error or the service crashes.
+	// It's useful for handlers for which duplicated side effects are worse than occasional losses
+	// (for example, sending push notifications).
+	//
+	// Handler errors are logged and not returned, so the message is not retried by the router's middlewares either.
+	// Keep in mind that the subscriber may deliver the next message before the handler returns.
+	// It takes precedence over AckCommandHandlingErrors.
+	AtMostOnce bool
+
+	// AtMostOnceHandlers overrides AtMostOnce for the handlers with the given names.
+	AtMostOnceHandlers map[string]bool
+
+	// EventBus is used to publish events returned by CommandHandlerWithEvents handlers
+	// (for example, created with NewCommandHandlerWithEvents).
+	// Events are published after the handler returns without an error.
+	// If publishing any of the events fails, the command is nacked.
+	//
+	// Publishing multiple events is not atomic. If you need atomicity, you can use an EventBus
+	// with a publisher that is part of the transaction (for example, an outbox publisher).
+	//
+	// This option is required only when CommandHandlerWithEvents handlers are added.
+	EventBus EventPublisher
+
+	// DomainErrors if not nil makes the processor publish the failure event of DomainError returned by the handler,
+	// and ack the command instead of nacking it (see DomainError). Other errors are handled as usual.
+	//
+	// This option is not required.
+	DomainErrors *CommandDomainErrorsConfig
+
+	// RouterHandlersOwner if not empty enables the upsert mode of adding handlers to the router.
+	// See EventProcessorConfig.RouterHandlersOwner for details.
+	RouterHandlersOwner string
+
 	// disableRouterAutoAddHandlers is used to keep backwards compatibility.
 	// it is set when CommandProcessor is created by NewCommandProcessor.
 	// Deprecated: please migrate to NewCommandProcessorWithConfig.
@@ -64,6 +111,13 @@ func (c *CommandProcessorConfig) setDefaults() {
 	if c.Logger == nil {
 		c.Logger = watermill.NopLogger{}
 	}
+	if c.DomainErrors != nil {
+		domainErrors := *c.DomainErrors
+		if domainErrors.EventBus == nil {
+			domainErrors.EventBus = c.EventBus
+		}
+		c.DomainErrors = &domainErrors
+	}
 }
 
 func (c CommandProcessorConfig) Validate() error {
@@ -80,6 +134,12 @@ func (c CommandProcessorConfig) Validate() error {
 		err = stdErrors.Join(err, errors.New("missing SubscriberConstructor"))
 	}
 
+	if c.DomainErrors != nil {
+		if domainErrorsErr := c.DomainErrors.Validate(); domainErrorsErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(domainErrorsErr, "invalid DomainErrors config"))
+		}
+	}
+
 	return err
 }
 
@@ -117,7 +177,13 @@ type CommandProcessor struct {
 
 	handlers []CommandHandler
 
+	// localHandlers are the handlers keyed by the command name, used by handleLocally
+	localHandlers *localCommandHandlers
+
 	config CommandProcessorConfig
+
+	// upsert is nil if the upsert mode is disabled
+	upsert *routerHandlersUpsert
 }
 
 func NewCommandProcessorWithConfig(router *message.Router, config CommandProcessorConfig) (*CommandProcessor, error) {
@@ -132,8 +198,10 @@ func NewCommandProcessorWithConfig(router *message.Router, config CommandProcess
 	}
 
 	return &CommandProcessor{
-		router: router,
-		config: config,
+		router:        router,
+		localHandlers: newLocalCommandHandlers(),
+		config:        config,
+		upsert:        newRouterHandlersUpsert(config.RouterHandlersOwner),
 	}, nil
 }
 
@@ -190,8 +258,12 @@ func NewCommandProcessor(
 type CommandsSubscriberConstructor func(handlerName string) (message.Subscriber, error)
 
 // AddHandlers adds a new CommandHandler to the CommandProcessor and adds it to the router.
+//
+// If a handler implements CommandHandlerWithEvents, events returned by it are published with
+// CommandProcessorConfig.EventBus.
 func (p *CommandProcessor) AddHandlers(handlers ...CommandHandler) error {
 	handledCommands := map[string]struct{}{}
+	handlersToAdd := make([]CommandHandler, 0, len(handlers))
 	for _, handler := range handlers {
 		commandName := p.config.Marshaler.Name(handler.NewCommand())
 		if _, ok := handledCommands[commandName]; ok {
@@ -199,19 +271,36 @@ func (p *CommandProcessor) AddHandlers(handlers ...CommandHandler) error {
 		}
 
 		handledCommands[commandName] = struct{}{}
+
+		if handlerWithEvents, ok := handler.(CommandHandlerWithEvents); ok {
+			if p.config.EventBus == nil {
+				return errors.Errorf("handler %s returns events, but EventBus is not configured", handler.HandlerName())
+			}
+
+			handler = commandHandlerPublishingEvents{
+				CommandHandlerWithEvents: handlerWithEvents,
+				eventBus:                 p.config.EventBus,
+			}
+		}
+
+		handlersToAdd = append(handlersToAdd, handler)
 	}
 
 	if p.config.disableRouterAutoAddHandlers {
-		p.handlers = append(p.handlers, handlers...)
+		p.handlers = append(p.handlers, handlersToAdd...)
+		for _, handler := range handlersToAdd {
+			p.localHandlers.add(p.config.Marshaler.Name(handler.NewCommand()), handler)
+		}
 		return nil
 	}
 
-	for _, handler := range handlers {
+	for _, handler := range handlersToAdd {
 		if err := p.addHandlerToRouter(p.router, handler); err != nil {
 			return err
 		}
 
 		p.handlers = append(p.handlers, handler)
+		p.localHandlers.add(p.config.Marshaler.Name(handler.NewCommand()), handler)
 	}
 
 	return nil
@@ -249,14 +338,18 @@ func (p CommandProcessor) AddHandlersToRouter(r *message.Router) error {
 
 func (p CommandProcessor) addHandlerToRouter(r *message.Router, handler CommandHandler) error {
 	handlerName := handler.HandlerName()
-	commandName := p.config.Marshaler.Name(handler.NewCommand())
 
-	topicName, err := p.config.GenerateSubscribeTopic(CommandProcessorGenerateSubscribeTopicParams{
-		CommandName:    commandName,
-		CommandHandler: handler,
-	})
+	exists, err := p.upsert.register(r, handlerName)
 	if err != nil {
-		return errors.Wrapf(err, "cannot generate topic for command handler %s", handlerName)
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	topicName, err := p.HandlerTopic(handler)
+	if err != nil {
+		return err
 	}
 
 	logger := p.config.Logger.With(watermill.LogFields{
@@ -279,21 +372,99 @@ func (p CommandProcessor) addHandlerToRouter(r *message.Router, handler CommandH
 		return errors.Wrap(err, "cannot create subscriber for command processor")
 	}
 
-	r.AddNoPublisherHandler(
+	routerHandler := r.AddNoPublisherHandler(
 		handlerName,
 		topicName,
 		subscriber,
 		handlerFunc,
 	)
+	p.upsert.added(routerHandler, handlerName)
 
 	return nil
 }
 
+// SyncRouterHandlers removes the router's handlers owned by CommandProcessorConfig.RouterHandlersOwner,
+// which are not registered in the processor, and returns the changes made since the processor was created.
+// See EventProcessor.SyncRouterHandlers for details.
+func (p *CommandProcessor) SyncRouterHandlers() (RouterHandlersDiff, error) {
+	return p.upsert.sync(p.router)
+}
+
 // Handlers returns the CommandProcessor's handlers.
 func (p CommandProcessor) Handlers() []CommandHandler {
 	return p.handlers
 }
 
+// HandlerTopic returns the topic from which the handler consumes commands.
+func (p CommandProcessor) HandlerTopic(handler CommandHandler) (string, error) {
+	topicName, err := p.config.GenerateSubscribeTopic(CommandProcessorGenerateSubscribeTopicParams{
+		CommandName:    p.config.Marshaler.Name(handler.NewCommand()),
+		CommandHandler: handler,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot generate topic for command handler %s", handler.HandlerName())
+	}
+
+	return topicName, nil
+}
+
+// Marshaler returns the marshaler used to unmarshal the commands.
+func (p CommandProcessor) Marshaler() CommandEventMarshaler {
+	return p.config.Marshaler
+}
+
+// handleLocally passes the command message directly to the router's handler of the command, if it exists.
+func (p *CommandProcessor) handleLocally(msg *message.Message) (bool, error) {
+	if p.router == nil {
+		// handlers are added to the router with AddHandlersToRouter
+		return false, nil
+	}
+
+	commandName := p.config.Marshaler.NameFromMessage(msg)
+
+	handler, ok := p.localHandlers.get(commandName)
+	if !ok {
+		return false, nil
+	}
+
+	if err := p.router.HandleMessage(handler.HandlerName(), msg); err != nil {
+		return true, errors.Wrapf(err, "cannot handle command %s locally", commandName)
+	}
+
+	return true, nil
+}
+
+// localCommandHandlers are the CommandProcessor's handlers keyed by the command name.
+// They are safe to use while handlers are added concurrently with AddHandlers.
+type localCommandHandlers struct {
+	lock     sync.RWMutex
+	handlers map[string]CommandHandler
+}
+
+func newLocalCommandHandlers() *localCommandHandlers {
+	return &localCommandHandlers{
+		handlers: map[string]CommandHandler{},
+	}
+}
+
+func (h *localCommandHandlers) add(commandName string, handler CommandHandler) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.handlers[commandName]; !ok {
+		// the command is handled locally by the first added handler
+		h.handlers[commandName] = handler
+	}
+}
+
+func (h *localCommandHandlers) get(commandName string) (CommandHandler, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	handler, ok := h.handlers[commandName]
+	return handler, ok
+}
+
 func (p CommandProcessor) routerHandlerFunc(handler CommandHandler, logger watermill.LoggerAdapter) (message.NoPublishHandlerFunc, error) {
 	cmd := handler.NewCommand()
 	cmdName := p.config.Marshaler.Name(cmd)
@@ -327,19 +498,34 @@ func (p CommandProcessor) routerHandlerFunc(handler CommandHandler, logger water
 			return err
 		}
 
-		handle := func(params CommandProcessorOnHandleParams) (err error) {
-			return params.Handler.Handle(ctx, params.Command)
+		handlerName := handler.HandlerName()
+		timeout := handlerTimeout(p.config.HandlerTimeout, p.config.HandlerTimeouts, handlerName)
+
+		handleCommand := func() error {
+			err := handleWithTimeout(msg, handlerName, timeout, func(ctx context.Context) error {
+				handle := func(params CommandProcessorOnHandleParams) (err error) {
+					return params.Handler.Handle(ctx, params.Command)
+				}
+				if p.config.OnHandle != nil {
+					handle = p.config.OnHandle
+				}
+
+				return handle(CommandProcessorOnHandleParams{
+					Handler:     handler,
+					CommandName: messageCmdName,
+					Command:     cmd,
+					Message:     msg,
+				})
+			})
+
+			return p.config.DomainErrors.handleDomainError(ctx, msg, messageCmdName, err, logger)
 		}
-		if p.config.OnHandle != nil {
-			handle = p.config.OnHandle
+
+		if handlerAtMostOnce(p.config.AtMostOnce, p.config.AtMostOnceHandlers, handlerName) {
+			return handleAtMostOnce(msg, logger, handleCommand)
 		}
 
-		err := handle(CommandProcessorOnHandleParams{
-			Handler:     handler,
-			CommandName: messageCmdName,
-			Command:     cmd,
-			Message:     msg,
-		})
+		err := handleCommand()
 
 		if p.config.AckCommandHandlingErrors && err != nil {
 			logger.Error("Error when handling command, acking (AckCommandHandlingErrors is enabled)", err, nil)
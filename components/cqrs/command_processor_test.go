@@ -514,3 +514,133 @@ func TestCommandProcessor_original_msg_set_to_ctx(t *testing.T) {
 	require.NotNil(t, msgFromCtx)
 	assert.Equal(t, msgToSend, msgFromCtx)
 }
+
+func TestCommandProcessor_handler_with_events(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		HandlerErr     error
+		ExpectedEvents int
+		ExpectedAck    bool
+	}{
+		{
+			Name:           "success",
+			HandlerErr:     nil,
+			ExpectedEvents: 2,
+			ExpectedAck:    true,
+		},
+		{
+			Name:           "handler_error",
+			HandlerErr:     errors.New("test error"),
+			ExpectedEvents: 0,
+			ExpectedAck:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			logger := watermill.NewStdLogger(false, false)
+			marshaler := cqrs.JSONMarshaler{}
+
+			msgToSend, err := marshaler.Marshal(&TestCommand{ID: "1"})
+			require.NoError(t, err)
+
+			mockSub := &mockSubscriber{
+				MessagesToSend: []*message.Message{
+					msgToSend,
+				},
+			}
+
+			eventsPublisher := newPublisherStub()
+			eventBus, err := cqrs.NewEventBusWithConfig(eventsPublisher, cqrs.EventBusConfig{
+				GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+					return "events", nil
+				},
+				Marshaler: marshaler,
+			})
+			require.NoError(t, err)
+
+			router, err := message.NewRouter(message.RouterConfig{}, logger)
+			require.NoError(t, err)
+
+			commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+				router,
+				cqrs.CommandProcessorConfig{
+					GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+						return "commands", nil
+					},
+					SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+						return mockSub, nil
+					},
+					Marshaler: marshaler,
+					Logger:    logger,
+					EventBus:  eventBus,
+				},
+			)
+			require.NoError(t, err)
+
+			err = commandProcessor.AddHandlers(cqrs.NewCommandHandlerWithEvents(
+				"handler", func(ctx context.Context, cmd *TestCommand) ([]any, error) {
+					return []any{&TestEvent{ID: cmd.ID}, &AnotherTestEvent{ID: cmd.ID}}, tc.HandlerErr
+				}),
+			)
+			require.NoError(t, err)
+
+			go func() {
+				err := router.Run(context.Background())
+				assert.NoError(t, err)
+			}()
+			defer func() {
+				assert.NoError(t, router.Close())
+			}()
+
+			<-router.Running()
+
+			select {
+			case <-msgToSend.Acked():
+				assert.True(t, tc.ExpectedAck, "ack received, message should be nacked")
+			case <-msgToSend.Nacked():
+				assert.False(t, tc.ExpectedAck, "nack received, message should be acked")
+			case <-time.After(1 * time.Second):
+				t.Fatal("timeout waiting for ack")
+			}
+
+			eventsPublisher.mu.Lock()
+			defer eventsPublisher.mu.Unlock()
+
+			require.Len(t, eventsPublisher.messages["events"], tc.ExpectedEvents)
+			if tc.ExpectedEvents > 0 {
+				assert.Equal(t, "cqrs_test.TestEvent", marshaler.NameFromMessage(eventsPublisher.messages["events"][0]))
+				assert.Equal(t, "cqrs_test.AnotherTestEvent", marshaler.NameFromMessage(eventsPublisher.messages["events"][1]))
+			}
+		})
+	}
+}
+
+func TestCommandProcessor_handler_with_events_missing_event_bus(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(
+		router,
+		cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return ts.CommandsPubSub, nil
+			},
+			Marshaler: ts.Marshaler,
+			Logger:    ts.Logger,
+		},
+	)
+	require.NoError(t, err)
+
+	err = commandProcessor.AddHandlers(cqrs.NewCommandHandlerWithEvents(
+		"handler", func(ctx context.Context, cmd *TestCommand) ([]any, error) {
+			return nil, nil
+		}),
+	)
+	assert.EqualError(t, err, "handler handler returns events, but EventBus is not configured")
+}
@@ -3,6 +3,7 @@ package cqrs
 import (
 	"context"
 	stdErrors "errors"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -23,6 +24,70 @@ type EventBusConfig struct {
 	// It is required.
 	Marshaler CommandEventMarshaler
 
+	// NewUUID is used to generate the UUID of the published message, instead of the UUID generated by Marshaler.
+	// It allows, for example, sortable ULIDs (watermill.NewULID) or deterministic UUIDs derived from
+	// the event's business key (watermill.NewDeterministicUUID), so the duplicates can be detected by the consumers.
+	// It's used for the integration events as well.
+	//
+	// This option is not required.
+	NewUUID EventBusNewUUIDFn
+
+	// GeneratePartitionKey is used to generate the partition key of the published message,
+	// for example, the ID of the event's aggregate. The key is set with message.SetPartitionKey,
+	// so the Pub/Sub can keep the order of the aggregate's events. An empty key is not set.
+	// It's called before OnPublish, so OnPublish can override the key.
+	// It's used for the integration events as well.
+	//
+	// This option is not required.
+	GeneratePartitionKey EventBusGeneratePartitionKeyFn
+
+	// PublishedEvents are the events published by the service with this EventBus.
+	// They are used by ValidateEventRouting to detect events that no local processor subscribes to,
+	// and by the asyncapi component to document the published events.
+	//
+	// This option is not required.
+	PublishedEvents []any
+
+	// StandardMetadata enables setting the standard metadata (produced_at, producer name and instance ID, schema name)
+	// on every published message, before OnPublish is called.
+	//
+	// This option is not required.
+	StandardMetadata *StandardMetadataConfig
+
+	// TTL if set makes every published message expire after TTL since publishing (see message.SetTTL),
+	// unless the message already has the expiry set. Expired messages are dropped by the consumers
+	// using middleware.DropExpired, or by Pub/Subs with native message TTL.
+	//
+	// This option is not required.
+	TTL time.Duration
+
+	// MessageSize if not nil enables measuring the size of marshaled payloads and enforcing the maximum size,
+	// with an error, compression, or claim-check offload. See MessageSizeConfig.
+	// The size is checked just before publishing, after all modifications of the message.
+	// Events recorded by DryRunSink are not checked.
+	//
+	// This option is not required.
+	MessageSize *MessageSizeConfig
+
+	// DryRunSink if not nil enables the dry-run mode: the event is marshaled and OnPublish is called,
+	// but the message is recorded to the sink instead of being published.
+	//
+	// This option is not required.
+	DryRunSink DryRunSink
+
+	// Shadow if not nil enables the shadow mode: the message is published to its topic
+	// and then to the shadow topic. See ShadowPublishConfig.
+	// It can't be used together with DryRunSink.
+	//
+	// This option is not required.
+	Shadow *ShadowPublishConfig
+
+	// Integration if not nil enables publishing the integration events, registered with their visibility,
+	// to separate topics. See IntegrationEventsConfig.
+	//
+	// This option is not required.
+	Integration *IntegrationEventsConfig
+
 	// Logger instance used to log.
 	// If not provided, watermill.NopLogger is used.
 	Logger watermill.LoggerAdapter
@@ -32,6 +97,21 @@ func (c *EventBusConfig) setDefaults() {
 	if c.Logger == nil {
 		c.Logger = watermill.NopLogger{}
 	}
+	if c.StandardMetadata != nil {
+		standardMetadata := *c.StandardMetadata
+		standardMetadata.setDefaults()
+		c.StandardMetadata = &standardMetadata
+	}
+	if c.MessageSize != nil {
+		messageSize := *c.MessageSize
+		messageSize.setDefaults()
+		c.MessageSize = &messageSize
+	}
+	if c.Integration != nil {
+		integration := *c.Integration
+		integration.setDefaults()
+		c.Integration = &integration
+	}
 }
 
 func (c EventBusConfig) Validate() error {
@@ -45,6 +125,31 @@ func (c EventBusConfig) Validate() error {
 		err = stdErrors.Join(err, errors.New("missing GenerateHandlerTopic"))
 	}
 
+	if c.TTL < 0 {
+		err = stdErrors.Join(err, errors.New("TTL must not be negative"))
+	}
+
+	if c.MessageSize != nil {
+		if messageSizeErr := c.MessageSize.Validate(); messageSizeErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(messageSizeErr, "invalid MessageSize config"))
+		}
+	}
+
+	if c.Integration != nil {
+		if integrationErr := c.Integration.Validate(); integrationErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(integrationErr, "invalid Integration config"))
+		}
+	}
+
+	if c.DryRunSink != nil && c.Shadow != nil {
+		err = stdErrors.Join(err, errors.New("DryRunSink and Shadow can't be used together"))
+	}
+	if c.Shadow != nil {
+		if shadowErr := c.Shadow.Validate(); shadowErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(shadowErr, "invalid Shadow config"))
+		}
+	}
+
 	return err
 }
 
@@ -55,6 +160,20 @@ type GenerateEventPublishTopicParams struct {
 	Event     any
 }
 
+type EventBusNewUUIDFn func(params EventBusNewUUIDParams) (string, error)
+
+type EventBusNewUUIDParams struct {
+	EventName string
+	Event     any
+}
+
+type EventBusGeneratePartitionKeyFn func(params EventBusGeneratePartitionKeyParams) (string, error)
+
+type EventBusGeneratePartitionKeyParams struct {
+	EventName string
+	Event     any
+}
+
 type OnEventSendFn func(params OnEventSendParams) error
 
 type OnEventSendParams struct {
@@ -63,12 +182,18 @@ type OnEventSendParams struct {
 
 	// Message is never nil and can be modified.
 	Message *message.Message
+
+	// PayloadSize is the size of the marshaled payload in bytes.
+	PayloadSize int
 }
 
 // EventBus transports events to event handlers.
 type EventBus struct {
 	publisher message.Publisher
 	config    EventBusConfig
+
+	// visibility of the events registered in IntegrationEventsConfig.Events, by the event name
+	visibility map[string]EventVisibility
 }
 
 // NewEventBus creates a new CommandBus.
@@ -110,37 +235,190 @@ func NewEventBusWithConfig(publisher message.Publisher, config EventBusConfig) (
 		return nil, errors.Wrap(err, "invalid config")
 	}
 
-	return &EventBus{publisher, config}, nil
+	visibility := map[string]EventVisibility{}
+	if config.Integration != nil {
+		for _, registration := range config.Integration.Events {
+			visibility[config.Marshaler.Name(registration.Event)] = registration.Visibility
+		}
+	}
+
+	return &EventBus{
+		publisher:  publisher,
+		config:     config,
+		visibility: visibility,
+	}, nil
 }
 
 // Publish sends event to the event bus.
+//
+// If IntegrationEventsConfig is set, the event is published to the internal topic,
+// the integration topic, or both, depending on its visibility.
 func (c EventBus) Publish(ctx context.Context, event any) error {
+	return c.publishWithOverrides(ctx, event, eventPublishOverrides{})
+}
+
+// publishWithOverrides works like Publish, but overrides the topic and adds metadata of the published event
+// (see CommandDomainErrorsConfig).
+func (c EventBus) publishWithOverrides(ctx context.Context, event any, overrides eventPublishOverrides) error {
+	visibility := c.EventVisibility(event)
+
+	if visibility.internal() {
+		if err := c.publishInternalEvent(ctx, event, overrides); err != nil {
+			return err
+		}
+	}
+
+	if visibility.integration() {
+		if err := c.publishIntegrationEvent(ctx, event, overrides); err != nil {
+			return errors.Wrap(err, "cannot publish integration event")
+		}
+	}
+
+	return nil
+}
+
+func (c EventBus) publishInternalEvent(ctx context.Context, event any, overrides eventPublishOverrides) error {
 	msg, err := c.config.Marshaler.Marshal(event)
 	if err != nil {
 		return err
 	}
 
 	eventName := c.config.Marshaler.Name(event)
-	topicName, err := c.config.GeneratePublishTopic(GenerateEventPublishTopicParams{
-		EventName: eventName,
-		Event:     event,
-	})
+	topicName, err := c.generatePublishTopic(eventName, event, overrides)
 	if err != nil {
 		return errors.Wrap(err, "cannot generate topic")
 	}
 
+	if err := c.setMessageUUID(msg, eventName, event); err != nil {
+		return err
+	}
+	if err := c.setPartitionKey(msg, eventName, event); err != nil {
+		return err
+	}
+
 	msg.SetContext(ctx)
 
+	if c.config.StandardMetadata != nil {
+		c.config.StandardMetadata.apply(msg, eventName)
+	}
+	if c.config.TTL > 0 {
+		applyTTL(msg, c.config.TTL)
+	}
+	for k, v := range overrides.metadata {
+		msg.Metadata.Set(k, v)
+	}
+
 	if c.config.OnPublish != nil {
 		err := c.config.OnPublish(OnEventSendParams{
-			EventName: eventName,
-			Event:     event,
-			Message:   msg,
+			EventName:   eventName,
+			Event:       event,
+			Message:     msg,
+			PayloadSize: len(msg.Payload),
 		})
 		if err != nil {
 			return errors.Wrap(err, "cannot execute OnPublish")
 		}
 	}
 
-	return c.publisher.Publish(topicName, msg)
+	if err := resealPayload(c.config.Marshaler, msg); err != nil {
+		return err
+	}
+
+	if c.config.MessageSize != nil && c.config.DryRunSink == nil {
+		if err := c.config.MessageSize.apply(msg, eventName); err != nil {
+			return err
+		}
+	}
+
+	return publishWithMode(c.publisher, topicName, msg, c.config.DryRunSink, c.config.Shadow, c.config.Logger)
+}
+
+func (c EventBus) generatePublishTopic(eventName string, event any, overrides eventPublishOverrides) (string, error) {
+	if overrides.generateTopic != nil {
+		return overrides.generateTopic()
+	}
+
+	return c.config.GeneratePublishTopic(GenerateEventPublishTopicParams{
+		EventName: eventName,
+		Event:     event,
+	})
+}
+
+func (c EventBus) setMessageUUID(msg *message.Message, eventName string, event any) error {
+	if c.config.NewUUID == nil {
+		return nil
+	}
+
+	uuid, err := c.config.NewUUID(EventBusNewUUIDParams{
+		EventName: eventName,
+		Event:     event,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot generate message UUID")
+	}
+
+	msg.UUID = uuid
+
+	return nil
+}
+
+func (c EventBus) setPartitionKey(msg *message.Message, eventName string, event any) error {
+	if c.config.GeneratePartitionKey == nil {
+		return nil
+	}
+
+	key, err := c.config.GeneratePartitionKey(EventBusGeneratePartitionKeyParams{
+		EventName: eventName,
+		Event:     event,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot generate partition key")
+	}
+
+	message.SetPartitionKey(msg, key)
+
+	return nil
+}
+
+// PublishedEventsTopics returns the topics of events registered in EventBusConfig.PublishedEvents,
+// keyed by the event name.
+func (c EventBus) PublishedEventsTopics() (map[string]string, error) {
+	topics := make(map[string]string, len(c.config.PublishedEvents))
+
+	for _, event := range c.config.PublishedEvents {
+		topicName, err := c.EventTopic(event)
+		if err != nil {
+			return nil, err
+		}
+
+		topics[c.config.Marshaler.Name(event)] = topicName
+	}
+
+	return topics, nil
+}
+
+// EventTopic returns the topic to which the event is published.
+// If IntegrationEventsConfig is set, it's the internal topic.
+func (c EventBus) EventTopic(event any) (string, error) {
+	eventName := c.config.Marshaler.Name(event)
+
+	topicName, err := c.config.GeneratePublishTopic(GenerateEventPublishTopicParams{
+		EventName: eventName,
+		Event:     event,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot generate topic for event %s", eventName)
+	}
+
+	return topicName, nil
+}
+
+// PublishedEvents returns the events registered in EventBusConfig.PublishedEvents.
+func (c EventBus) PublishedEvents() []any {
+	return c.config.PublishedEvents
+}
+
+// Marshaler returns the marshaler used to marshal the events.
+func (c EventBus) Marshaler() CommandEventMarshaler {
+	return c.config.Marshaler
 }
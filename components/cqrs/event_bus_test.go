@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -166,3 +168,92 @@ func TestEventBus_Send_OnPublish_error(t *testing.T) {
 	err = eb.Publish(context.Background(), TestEvent{})
 	require.EqualError(t, err, "cannot execute OnPublish: some error")
 }
+
+func TestEventBus_Publish_NewUUID(t *testing.T) {
+	publisher := newPublisherStub()
+
+	eb, err := cqrs.NewEventBusWithConfig(
+		publisher,
+		cqrs.EventBusConfig{
+			GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			NewUUID: func(params cqrs.EventBusNewUUIDParams) (string, error) {
+				return watermill.NewDeterministicUUID(params.EventName + "/" + params.Event.(TestEvent).ID), nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, eb.Publish(context.Background(), TestEvent{ID: "1"}))
+
+	messages := publisher.messages["whatever"]
+	require.Len(t, messages, 1)
+	assert.Equal(t, watermill.NewDeterministicUUID("cqrs_test.TestEvent/1"), messages[0].UUID)
+}
+
+func TestEventBus_Publish_NewUUID_error(t *testing.T) {
+	eb, err := cqrs.NewEventBusWithConfig(
+		newPublisherStub(),
+		cqrs.EventBusConfig{
+			GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			NewUUID: func(params cqrs.EventBusNewUUIDParams) (string, error) {
+				return "", errors.New("missing business key")
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	err = eb.Publish(context.Background(), TestEvent{})
+	require.EqualError(t, err, "cannot generate message UUID: missing business key")
+}
+
+func TestEventBus_Publish_GeneratePartitionKey(t *testing.T) {
+	publisher := newPublisherStub()
+
+	eb, err := cqrs.NewEventBusWithConfig(
+		publisher,
+		cqrs.EventBusConfig{
+			GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			GeneratePartitionKey: func(params cqrs.EventBusGeneratePartitionKeyParams) (string, error) {
+				assert.Equal(t, "cqrs_test.TestEvent", params.EventName)
+				return params.Event.(TestEvent).ID, nil
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, eb.Publish(context.Background(), TestEvent{ID: "1"}))
+	require.NoError(t, eb.Publish(context.Background(), TestEvent{}))
+
+	messages := publisher.messages["whatever"]
+	require.Len(t, messages, 2)
+	assert.Equal(t, "1", message.PartitionKey(messages[0]))
+	assert.NotContains(t, messages[1].Metadata, message.PartitionKeyMetadataKey, "empty key should not be set")
+}
+
+func TestEventBus_Publish_GeneratePartitionKey_error(t *testing.T) {
+	eb, err := cqrs.NewEventBusWithConfig(
+		newPublisherStub(),
+		cqrs.EventBusConfig{
+			GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+				return "whatever", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			GeneratePartitionKey: func(params cqrs.EventBusGeneratePartitionKeyParams) (string, error) {
+				return "", errors.New("missing aggregate ID")
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	err = eb.Publish(context.Background(), TestEvent{})
+	require.EqualError(t, err, "cannot generate partition key: missing aggregate ID")
+}
@@ -0,0 +1,118 @@
+package cqrs
+
+import (
+	"context"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DeprecatedEventHandlerConfig configures the handler returned by NewDeprecatedEventHandler.
+type DeprecatedEventHandlerConfig struct {
+	// Reason is logged with every event received by the deprecated handler,
+	// for example "replaced by OrderProjectionV2".
+	Reason string
+
+	// OnEvent is called for every event (or tombstone) received by the deprecated handler.
+	// It can be used to record a metric, so it's known when the handler can be removed.
+	OnEvent func(params DeprecatedEventParams)
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *DeprecatedEventHandlerConfig) setDefaults() {
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// DeprecatedEventParams are the parameters of DeprecatedEventHandlerConfig.OnEvent.
+type DeprecatedEventParams struct {
+	HandlerName string
+
+	// Event is the unmarshaled event, or nil for tombstones.
+	Event any
+
+	Message *message.Message
+}
+
+type deprecatedEventHandler struct {
+	handler EventHandler
+	config  DeprecatedEventHandlerConfig
+}
+
+// NewDeprecatedEventHandler returns a handler which keeps the name and the event type of the handler,
+// but acks the received events without handling them. Every occurrence is logged and passed to
+// DeprecatedEventHandlerConfig.OnEvent.
+//
+// It allows removing a handler gracefully: the subscription (and the consumer group) is kept,
+// so the messages don't pile up, while it's observed if the events are still published.
+// When no events are received anymore, the handler can be removed.
+//
+// EventHandlerSubscriberOptions of the handler are kept, as well as the events resolved by PolymorphicEventHandler.
+func NewDeprecatedEventHandler(handler EventHandler, config DeprecatedEventHandlerConfig) EventHandler {
+	config.setDefaults()
+
+	deprecated := deprecatedEventHandler{
+		handler: handler,
+		config:  config,
+	}
+
+	var deprecatedHandler EventHandler = deprecated
+	if polymorphicHandler, ok := polymorphicEventHandlerOf(handler); ok {
+		deprecatedHandler = deprecatedPolymorphicEventHandler{
+			deprecatedEventHandler:  deprecated,
+			PolymorphicEventHandler: polymorphicHandler,
+		}
+	}
+
+	if withOptions, ok := handler.(EventHandlerWithSubscriberOptions); ok {
+		return NewEventHandlerWithSubscriberOptions(deprecatedHandler, withOptions.SubscriberOptions())
+	}
+
+	return deprecatedHandler
+}
+
+func (h deprecatedEventHandler) HandlerName() string {
+	return h.handler.HandlerName()
+}
+
+func (h deprecatedEventHandler) NewEvent() any {
+	return h.handler.NewEvent()
+}
+
+func (h deprecatedEventHandler) Handle(ctx context.Context, event any) error {
+	h.skip(OriginalMessageFromCtx(ctx), event)
+	return nil
+}
+
+func (h deprecatedEventHandler) HandleTombstone(ctx context.Context, tombstone Tombstone) error {
+	h.skip(tombstone.Message, nil)
+	return nil
+}
+
+type deprecatedPolymorphicEventHandler struct {
+	deprecatedEventHandler
+	PolymorphicEventHandler
+}
+
+func (h deprecatedEventHandler) skip(msg *message.Message, event any) {
+	fields := watermill.LogFields{
+		"handler_name": h.HandlerName(),
+		"reason":       h.config.Reason,
+	}
+	if msg != nil {
+		fields["message_uuid"] = msg.UUID
+	}
+	h.config.Logger.Info("Deprecated event handler received event, skipping", fields)
+
+	if h.config.OnEvent != nil {
+		h.config.OnEvent(DeprecatedEventParams{
+			HandlerName: h.HandlerName(),
+			Event:       event,
+			Message:     msg,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+func TestNewDeprecatedEventHandler(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+
+	var occurrences []cqrs.DeprecatedEventParams
+	lock := sync.Mutex{}
+
+	handler := cqrs.NewDeprecatedEventHandler(
+		cqrs.NewEventHandler("old_projection", func(ctx context.Context, event *TestEvent) error {
+			t.Fatal("deprecated handler should not be called")
+			return nil
+		}),
+		cqrs.DeprecatedEventHandlerConfig{
+			Reason: "replaced by new_projection",
+			OnEvent: func(params cqrs.DeprecatedEventParams) {
+				lock.Lock()
+				defer lock.Unlock()
+
+				occurrences = append(occurrences, params)
+			},
+			Logger: logger,
+		},
+	)
+
+	assert.Equal(t, "old_projection", handler.HandlerName())
+	assert.Equal(t, &TestEvent{}, handler.NewEvent())
+
+	eventMsg, err := cqrs.JSONMarshaler{}.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+	tombstoneMsg := cqrs.NewTombstoneMessage("1")
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{}, handler, eventMsg, tombstoneMsg)
+
+	requireAcked(t, eventMsg)
+	requireAcked(t, tombstoneMsg)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	require.Len(t, occurrences, 2)
+	for _, o := range occurrences {
+		assert.Equal(t, "old_projection", o.HandlerName)
+		require.NotNil(t, o.Message)
+
+		if o.Message.UUID == eventMsg.UUID {
+			assert.Equal(t, &TestEvent{ID: "1"}, o.Event)
+		} else {
+			assert.Equal(t, tombstoneMsg.UUID, o.Message.UUID)
+			assert.Nil(t, o.Event)
+		}
+	}
+
+	assert.True(t, logger.Has(watermill.CapturedMessage{
+		Level: watermill.InfoLogLevel,
+		Fields: watermill.LogFields{
+			"handler_name": "old_projection",
+			"reason":       "replaced by new_projection",
+			"message_uuid": eventMsg.UUID,
+		},
+		Msg: "Deprecated event handler received event, skipping",
+	}))
+}
+
+func TestNewDeprecatedEventHandler_keeps_subscriber_options(t *testing.T) {
+	options := cqrs.EventHandlerSubscriberOptions{ConsumerGroup: "old_group"}
+
+	handler := cqrs.NewDeprecatedEventHandler(
+		cqrs.NewEventHandlerWithSubscriberOptions(
+			cqrs.NewEventHandler("old_projection", func(ctx context.Context, event *TestEvent) error {
+				return nil
+			}),
+			options,
+		),
+		cqrs.DeprecatedEventHandlerConfig{},
+	)
+
+	withOptions, ok := handler.(cqrs.EventHandlerWithSubscriberOptions)
+	require.True(t, ok)
+	assert.Equal(t, options, withOptions.SubscriberOptions())
+	assert.Equal(t, "old_projection", handler.HandlerName())
+}
@@ -0,0 +1,68 @@
+package cqrs
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/pkg/errors"
+)
+
+// EventGroupPartitioning splits the handlers of every handlers group between multiple processes,
+// so heavy projections can be scaled horizontally, while every event type is still handled in order by one process.
+//
+// Every process adds the same handlers groups (with all handlers), and is configured with a different Partition.
+// Events are assigned to partitions by their name: a process handles only events of its partition,
+// and acks other events without handling them, because they are handled by the process owning their partition.
+//
+// All partitions must receive all events of the group, so each of them needs its own offsets.
+// Use EventGroupProcessorSubscriberConstructorParams.ConsumerGroup (for example, "projections_partition_1")
+// as the consumer group of the subscriber.
+//
+// WARNING: Changing Partitions (or AssignPartition) moves event types between partitions.
+// The new partition's consumer group may start from a different offset, so events may be handled again or skipped.
+type EventGroupPartitioning struct {
+	// Partitions is the total number of partitions (processes) sharing the handlers groups.
+	Partitions int
+
+	// Partition is the partition handled by this process, from 0 to Partitions-1.
+	Partition int
+
+	// AssignPartition returns the partition of the event name, from 0 to partitions-1.
+	// If nil, the FNV-1a hash of the event name is used.
+	AssignPartition func(eventName string, partitions int) int
+}
+
+func (p EventGroupPartitioning) Validate() error {
+	var err error
+
+	if p.Partitions <= 0 {
+		err = stdErrors.Join(err, errors.New("Partitions must be greater than 0"))
+	}
+	if p.Partition < 0 || p.Partition >= p.Partitions {
+		err = stdErrors.Join(err, errors.Errorf("Partition must be between 0 and %d", p.Partitions-1))
+	}
+
+	return err
+}
+
+// PartitionOfEvent returns the partition of the event name.
+func (p EventGroupPartitioning) PartitionOfEvent(eventName string) int {
+	if p.AssignPartition != nil {
+		return p.AssignPartition(eventName, p.Partitions)
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(eventName))
+
+	return int(h.Sum32() % uint32(p.Partitions))
+}
+
+// OwnsEvent returns true if the event name is handled by this process.
+func (p EventGroupPartitioning) OwnsEvent(eventName string) bool {
+	return p.PartitionOfEvent(eventName) == p.Partition
+}
+
+func (p EventGroupPartitioning) consumerGroup(groupName string) string {
+	return fmt.Sprintf("%s_partition_%d", groupName, p.Partition)
+}
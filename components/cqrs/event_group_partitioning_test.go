@@ -0,0 +1,137 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestEventGroupPartitioning(t *testing.T) {
+	partitioning := cqrs.EventGroupPartitioning{Partitions: 3, Partition: 1}
+	require.NoError(t, partitioning.Validate())
+
+	partition := partitioning.PartitionOfEvent("cqrs_test.TestEvent")
+	assert.Equal(t, partition, partitioning.PartitionOfEvent("cqrs_test.TestEvent"))
+	assert.True(t, partition >= 0 && partition < 3)
+	assert.Equal(t, partition == 1, partitioning.OwnsEvent("cqrs_test.TestEvent"))
+
+	err := cqrs.EventGroupPartitioning{Partitions: 2, Partition: 2}.Validate()
+	assert.EqualError(t, err, "Partition must be between 0 and 1")
+
+	err = cqrs.EventGroupPartitioning{}.Validate()
+	assert.ErrorContains(t, err, "Partitions must be greater than 0")
+}
+
+func TestEventGroupProcessor_partitioning(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	marshaler := cqrs.JSONMarshaler{}
+
+	// every subscription receives all events, like subscriptions with different consumer groups
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	eventBus, err := cqrs.NewEventBusWithConfig(pubSub, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler: marshaler,
+		Logger:    logger,
+	})
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	handled := map[int][]string{}
+	consumerGroups := map[int]string{}
+
+	assignPartition := func(eventName string, partitions int) int {
+		if eventName == "cqrs_test.TestEvent" {
+			return 0
+		}
+		return 1
+	}
+
+	for partition := 0; partition < 2; partition++ {
+		partition := partition
+
+		router, err := message.NewRouter(message.RouterConfig{}, logger)
+		require.NoError(t, err)
+
+		processor, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "events", nil
+			},
+			SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				consumerGroups[partition] = params.ConsumerGroup
+				return pubSub, nil
+			},
+			Partitioning: &cqrs.EventGroupPartitioning{
+				Partitions:      2,
+				Partition:       partition,
+				AssignPartition: assignPartition,
+			},
+			Marshaler: marshaler,
+			Logger:    logger,
+		})
+		require.NoError(t, err)
+
+		record := func(id string) {
+			lock.Lock()
+			defer lock.Unlock()
+			handled[partition] = append(handled[partition], id)
+		}
+
+		err = processor.AddHandlersGroup(
+			"projections",
+			cqrs.NewGroupEventHandler(func(ctx context.Context, event *TestEvent) error {
+				record("TestEvent:" + event.ID)
+				return nil
+			}),
+			cqrs.NewGroupEventHandler(func(ctx context.Context, event *AnotherTestEvent) error {
+				record("AnotherTestEvent:" + event.ID)
+				return nil
+			}),
+		)
+		require.NoError(t, err)
+
+		go func() {
+			assert.NoError(t, router.Run(context.Background()))
+		}()
+		<-router.Running()
+
+		t.Cleanup(func() {
+			assert.NoError(t, router.Close())
+		})
+	}
+
+	assert.Equal(t, map[int]string{
+		0: "projections_partition_0",
+		1: "projections_partition_1",
+	}, consumerGroups)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+	require.NoError(t, eventBus.Publish(context.Background(), &AnotherTestEvent{ID: "2"}))
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "3"}))
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(handled[0])+len(handled[1]) == 3
+	}, time.Second, time.Millisecond*10)
+
+	// events which are not owned by the partition are acked, so they are not redelivered
+	time.Sleep(time.Millisecond * 50)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.ElementsMatch(t, []string{"TestEvent:1", "TestEvent:3"}, handled[0])
+	assert.Equal(t, []string{"AnotherTestEvent:2"}, handled[1])
+}
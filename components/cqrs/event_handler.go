@@ -59,6 +59,43 @@ func (c genericEventHandler[T]) Handle(ctx context.Context, e any) error {
 	return c.handleFunc(ctx, event)
 }
 
+// EventHandlerSubscriberOptions overrides how EventProcessor creates the subscriber for a single EventHandler.
+type EventHandlerSubscriberOptions struct {
+	// SubscriberConstructor is used instead of EventProcessorConfig.SubscriberConstructor, if not nil.
+	SubscriberConstructor EventProcessorSubscriberConstructorFn
+
+	// ConsumerGroup is passed to the subscriber constructor as EventProcessorSubscriberConstructorParams.ConsumerGroup.
+	// If empty, the handler name is used.
+	ConsumerGroup string
+}
+
+// EventHandlerWithSubscriberOptions is an EventHandler that overrides the subscriber options of EventProcessor.
+//
+// It allows handlers with different delivery requirements (for example, ordered and parallel processing,
+// or different consumer groups) to be added to one EventProcessor.
+type EventHandlerWithSubscriberOptions interface {
+	EventHandler
+
+	SubscriberOptions() EventHandlerSubscriberOptions
+}
+
+type eventHandlerWithSubscriberOptions struct {
+	EventHandler
+	options EventHandlerSubscriberOptions
+}
+
+// NewEventHandlerWithSubscriberOptions wraps the handler with EventHandlerSubscriberOptions.
+func NewEventHandlerWithSubscriberOptions(handler EventHandler, options EventHandlerSubscriberOptions) EventHandlerWithSubscriberOptions {
+	return eventHandlerWithSubscriberOptions{
+		EventHandler: handler,
+		options:      options,
+	}
+}
+
+func (h eventHandlerWithSubscriberOptions) SubscriberOptions() EventHandlerSubscriberOptions {
+	return h.options
+}
+
 type GroupEventHandler interface {
 	NewEvent() interface{}
 	Handle(ctx context.Context, event interface{}) error
@@ -0,0 +1,308 @@
+package cqrs
+
+import (
+	stdErrors "errors"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// eventLagSLOWindowBuckets is the number of buckets the burn rate window is split into.
+const eventLagSLOWindowBuckets = 60
+
+// EventLagSLO is the service level objective of the lag of handling an event type:
+// Objective of events must be handled within MaxLag since they were produced.
+type EventLagSLO struct {
+	// MaxLag is the maximum time between producing and handling the event. It is required.
+	MaxLag time.Duration
+
+	// Objective is the fraction of events which must be handled within MaxLag, for example 0.99.
+	// It must be greater than 0 and less than 1.
+	Objective float64
+}
+
+func (s EventLagSLO) Validate() error {
+	var err error
+
+	if s.MaxLag <= 0 {
+		err = stdErrors.Join(err, errors.New("MaxLag must be positive"))
+	}
+	if s.Objective <= 0 || s.Objective >= 1 {
+		err = stdErrors.Join(err, errors.New("Objective must be between 0 and 1"))
+	}
+
+	return err
+}
+
+// EventLagSLOReport describes the lag of the handled event against its SLO.
+type EventLagSLOReport struct {
+	HandlerName string
+	EventName   string
+
+	// Lag is the time between producing and handling the event.
+	Lag time.Duration
+
+	// WithinSLO is true if Lag didn't exceed EventLagSLO.MaxLag.
+	WithinSLO bool
+
+	// Events and Violations are the numbers of handled events and events exceeding MaxLag in the window.
+	Events     int
+	Violations int
+
+	// BurnRate is the rate at which the error budget (1 - Objective) is consumed in the window.
+	// 1 means the budget is consumed exactly at the allowed rate, and values above 1 mean the handler is falling behind.
+	BurnRate float64
+}
+
+// EventLagSLOTrackerConfig configures EventLagSLOTracker.
+type EventLagSLOTrackerConfig struct {
+	// SLOs are the objectives by event name (as returned by Marshaler.Name).
+	SLOs map[string]EventLagSLO
+
+	// DefaultSLO is used for the events without an objective in SLOs.
+	// If nil, such events are not tracked.
+	DefaultSLO *EventLagSLO
+
+	// Window is the time window in which the burn rate is computed. Defaults to 1 hour.
+	Window time.Duration
+
+	// Marshaler is used to get the names of events. It is required.
+	Marshaler CommandEventMarshaler
+
+	// ProducedAt returns the time when the event was produced.
+	// If not provided, the standard metadata is used (see StandardMetadataConfig.ProducedAt).
+	// Events without the time are not tracked.
+	ProducedAt func(msg *message.Message) (time.Time, bool)
+
+	// OnReport is called after every tracked event is handled successfully, for example, to record the burn rate
+	// (see metrics.PrometheusMetricsBuilder.NewEventLagSLOMetrics) or to alert. This option is not required.
+	OnReport func(msg *message.Message, report EventLagSLOReport)
+
+	// Clock is used to measure the lag.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (c *EventLagSLOTrackerConfig) setDefaults() {
+	if c.Window == 0 {
+		c.Window = time.Hour
+	}
+	if c.ProducedAt == nil {
+		c.ProducedAt = StandardMetadataConfig{}.ProducedAt
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+}
+
+func (c EventLagSLOTrackerConfig) Validate() error {
+	var err error
+
+	if c.Marshaler == nil {
+		err = stdErrors.Join(err, errors.New("missing Marshaler"))
+	}
+	if c.Window < 0 {
+		err = stdErrors.Join(err, errors.New("Window must not be negative"))
+	}
+	if len(c.SLOs) == 0 && c.DefaultSLO == nil {
+		err = stdErrors.Join(err, errors.New("missing SLOs"))
+	}
+	for eventName, slo := range c.SLOs {
+		if sloErr := slo.Validate(); sloErr != nil {
+			err = stdErrors.Join(err, errors.Wrapf(sloErr, "invalid SLO of %s", eventName))
+		}
+	}
+	if c.DefaultSLO != nil {
+		if sloErr := c.DefaultSLO.Validate(); sloErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(sloErr, "invalid DefaultSLO"))
+		}
+	}
+
+	return err
+}
+
+// EventLagSLOTracker tracks the lag of handling events against the objectives configured per event type,
+// so teams can alert on specific projections falling behind.
+//
+// The lag is the time between producing the event and handling it successfully.
+// It's tracked separately for every handler and event type.
+type EventLagSLOTracker struct {
+	config EventLagSLOTrackerConfig
+
+	windows     map[eventLagSLOKey]*sloWindow
+	windowsLock sync.Mutex
+}
+
+type eventLagSLOKey struct {
+	handlerName string
+	eventName   string
+}
+
+// NewEventLagSLOTracker creates a new EventLagSLOTracker.
+func NewEventLagSLOTracker(config EventLagSLOTrackerConfig) (*EventLagSLOTracker, error) {
+	config.setDefaults()
+
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid EventLagSLOTracker config")
+	}
+
+	return &EventLagSLOTracker{
+		config:  config,
+		windows: map[eventLagSLOKey]*sloWindow{},
+	}, nil
+}
+
+// Middleware tracks the lag of events handled by the router's handlers.
+// Add it to the router or to the handlers of EventProcessor and EventGroupProcessor.
+func (t *EventLagSLOTracker) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		producedMessages, err := h(msg)
+		if err != nil {
+			return producedMessages, err
+		}
+
+		t.track(msg)
+
+		return producedMessages, nil
+	}
+}
+
+// Report returns the current report of the handler and the event type, without the lag of a specific event.
+// It returns false if no event of the type was tracked for the handler.
+func (t *EventLagSLOTracker) Report(handlerName string, eventName string) (EventLagSLOReport, bool) {
+	slo, ok := t.slo(eventName)
+	if !ok {
+		return EventLagSLOReport{}, false
+	}
+
+	key := eventLagSLOKey{handlerName: handlerName, eventName: eventName}
+
+	t.windowsLock.Lock()
+	defer t.windowsLock.Unlock()
+
+	window, ok := t.windows[key]
+	if !ok {
+		return EventLagSLOReport{}, false
+	}
+
+	report := EventLagSLOReport{HandlerName: handlerName, EventName: eventName}
+	report.Events, report.Violations = window.totals(t.config.Clock.Now())
+	report.BurnRate = burnRate(report.Events, report.Violations, slo)
+
+	return report, true
+}
+
+func (t *EventLagSLOTracker) slo(eventName string) (EventLagSLO, bool) {
+	if slo, ok := t.config.SLOs[eventName]; ok {
+		return slo, true
+	}
+	if t.config.DefaultSLO != nil {
+		return *t.config.DefaultSLO, true
+	}
+
+	return EventLagSLO{}, false
+}
+
+func (t *EventLagSLOTracker) track(msg *message.Message) {
+	eventName := t.config.Marshaler.NameFromMessage(msg)
+
+	slo, ok := t.slo(eventName)
+	if !ok {
+		return
+	}
+
+	producedAt, ok := t.config.ProducedAt(msg)
+	if !ok {
+		return
+	}
+
+	now := t.config.Clock.Now()
+	report := EventLagSLOReport{
+		HandlerName: message.HandlerNameFromCtx(msg.Context()),
+		EventName:   eventName,
+		Lag:         now.Sub(producedAt),
+	}
+	report.WithinSLO = report.Lag <= slo.MaxLag
+
+	key := eventLagSLOKey{handlerName: report.HandlerName, eventName: eventName}
+
+	t.windowsLock.Lock()
+	window, ok := t.windows[key]
+	if !ok {
+		window = newSLOWindow(t.config.Window)
+		t.windows[key] = window
+	}
+	window.add(now, !report.WithinSLO)
+	report.Events, report.Violations = window.totals(now)
+	t.windowsLock.Unlock()
+
+	report.BurnRate = burnRate(report.Events, report.Violations, slo)
+
+	if t.config.OnReport != nil {
+		t.config.OnReport(msg, report)
+	}
+}
+
+func burnRate(events int, violations int, slo EventLagSLO) float64 {
+	if events == 0 {
+		return 0
+	}
+
+	return (float64(violations) / float64(events)) / (1 - slo.Objective)
+}
+
+// sloWindow counts the events in a sliding time window, split into buckets.
+type sloWindow struct {
+	bucketDuration time.Duration
+	buckets        []sloBucket
+}
+
+type sloBucket struct {
+	start      time.Time
+	events     int
+	violations int
+}
+
+func newSLOWindow(window time.Duration) *sloWindow {
+	bucketDuration := window / eventLagSLOWindowBuckets
+	if bucketDuration <= 0 {
+		bucketDuration = 1
+	}
+
+	return &sloWindow{
+		bucketDuration: bucketDuration,
+		buckets:        make([]sloBucket, eventLagSLOWindowBuckets),
+	}
+}
+
+func (w *sloWindow) add(now time.Time, violation bool) {
+	start := now.Truncate(w.bucketDuration)
+	bucket := &w.buckets[(start.UnixNano()/int64(w.bucketDuration))%int64(len(w.buckets))]
+
+	if !bucket.start.Equal(start) {
+		*bucket = sloBucket{start: start}
+	}
+
+	bucket.events++
+	if violation {
+		bucket.violations++
+	}
+}
+
+func (w *sloWindow) totals(now time.Time) (events int, violations int) {
+	oldestStart := now.Truncate(w.bucketDuration).Add(-w.bucketDuration * time.Duration(len(w.buckets)-1))
+
+	for _, bucket := range w.buckets {
+		if bucket.start.Before(oldestStart) {
+			continue
+		}
+		events += bucket.events
+		violations += bucket.violations
+	}
+
+	return events, violations
+}
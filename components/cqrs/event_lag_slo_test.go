@@ -0,0 +1,150 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEventLagSLOTracker(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	marshaler := cqrs.JSONMarshaler{}
+
+	var lock sync.Mutex
+	var reports []cqrs.EventLagSLOReport
+
+	tracker, err := cqrs.NewEventLagSLOTracker(cqrs.EventLagSLOTrackerConfig{
+		SLOs: map[string]cqrs.EventLagSLO{
+			"cqrs_test.TestEvent": {MaxLag: time.Second, Objective: 0.9},
+		},
+		Marshaler: marshaler,
+		OnReport: func(msg *message.Message, report cqrs.EventLagSLOReport) {
+			lock.Lock()
+			defer lock.Unlock()
+			reports = append(reports, report)
+		},
+		Clock: clock,
+	})
+	require.NoError(t, err)
+
+	newMessage := func(event any, lag time.Duration) *message.Message {
+		msg, err := marshaler.Marshal(event)
+		require.NoError(t, err)
+		msg.Metadata.Set(
+			cqrs.DefaultStandardMetadataKeys.ProducedAt,
+			clock.Now().Add(-lag).Format(time.RFC3339Nano),
+		)
+		return msg
+	}
+
+	messages := []*message.Message{
+		newMessage(&TestEvent{ID: "1"}, time.Millisecond*500),
+		newMessage(&TestEvent{ID: "2"}, time.Second*2),
+		newMessage(&TestEvent{ID: "3"}, time.Second*3),
+		// events without the SLO are not tracked
+		newMessage(&TestCommand{ID: "4"}, time.Hour),
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+	router.AddMiddleware(tracker.Middleware)
+
+	router.AddNoPublisherHandler(
+		"projection",
+		"events",
+		&mockSubscriber{MessagesToSend: messages},
+		func(msg *message.Message) error {
+			return nil
+		},
+	)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	for _, msg := range messages {
+		select {
+		case <-msg.Acked():
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for ack")
+		}
+	}
+
+	lock.Lock()
+	require.Len(t, reports, 3)
+	var violations int
+	for _, report := range reports {
+		assert.Equal(t, "projection", report.HandlerName)
+		assert.Equal(t, "cqrs_test.TestEvent", report.EventName)
+		assert.Equal(t, report.Lag <= time.Second, report.WithinSLO)
+		if !report.WithinSLO {
+			violations++
+		}
+	}
+	lock.Unlock()
+	assert.Equal(t, 2, violations)
+
+	report, ok := tracker.Report("projection", "cqrs_test.TestEvent")
+	require.True(t, ok)
+	assert.Equal(t, 3, report.Events)
+	assert.Equal(t, 2, report.Violations)
+	assert.InDelta(t, (2.0/3.0)/0.1, report.BurnRate, 0.0001)
+
+	_, ok = tracker.Report("projection", "cqrs_test.TestCommand")
+	assert.False(t, ok)
+
+	// the events are out of the window after an hour
+	clock.Advance(time.Hour)
+
+	report, ok = tracker.Report("projection", "cqrs_test.TestEvent")
+	require.True(t, ok)
+	assert.Equal(t, 0, report.Events)
+	assert.Equal(t, float64(0), report.BurnRate)
+}
+
+func TestEventLagSLOTracker_handler_error_not_tracked(t *testing.T) {
+	tracker, err := cqrs.NewEventLagSLOTracker(cqrs.EventLagSLOTrackerConfig{
+		DefaultSLO: &cqrs.EventLagSLO{MaxLag: time.Second, Objective: 0.99},
+		Marshaler:  cqrs.JSONMarshaler{},
+		OnReport: func(msg *message.Message, report cqrs.EventLagSLOReport) {
+			t.Fatal("failed events should not be tracked")
+		},
+	})
+	require.NoError(t, err)
+
+	msg, err := cqrs.JSONMarshaler{}.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+	msg.Metadata.Set(cqrs.DefaultStandardMetadataKeys.ProducedAt, time.Now().Format(time.RFC3339Nano))
+
+	_, err = tracker.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, assert.AnError
+	})(msg)
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestEventLagSLOTrackerConfig_Validate(t *testing.T) {
+	testCases := []cqrs.EventLagSLOTrackerConfig{
+		{DefaultSLO: &cqrs.EventLagSLO{MaxLag: time.Second, Objective: 0.9}},
+		{Marshaler: cqrs.JSONMarshaler{}},
+		{Marshaler: cqrs.JSONMarshaler{}, DefaultSLO: &cqrs.EventLagSLO{Objective: 0.9}},
+		{Marshaler: cqrs.JSONMarshaler{}, SLOs: map[string]cqrs.EventLagSLO{"event": {MaxLag: time.Second, Objective: 1}}},
+		{Marshaler: cqrs.JSONMarshaler{}, DefaultSLO: &cqrs.EventLagSLO{MaxLag: time.Second, Objective: 0.9}, Window: -1},
+	}
+
+	for _, config := range testCases {
+		_, err := cqrs.NewEventLagSLOTracker(config)
+		assert.ErrorContains(t, err, "invalid EventLagSLOTracker config")
+	}
+}
@@ -0,0 +1,123 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// EventTypeResolver maps the event name (from CommandEventMarshaler.NameFromMessage) to a new instance
+// of the concrete event type, used to unmarshal the event.
+// It returns false if the event is not a part of the handled family of events.
+type EventTypeResolver func(eventName string) (event any, ok bool)
+
+// NewEventTypeResolver creates an EventTypeResolver of the events, named with the marshaler.
+// The marshaler should be the same as the one used by EventProcessor.
+//
+// The events must be pointers, for example:
+//
+//	resolver, err := cqrs.NewEventTypeResolver(marshaler, &OrderPlaced{}, &OrderShipped{}, &OrderCanceled{})
+func NewEventTypeResolver(marshaler CommandEventMarshaler, events ...any) (EventTypeResolver, error) {
+	types := make(map[string]reflect.Type, len(events))
+
+	for _, event := range events {
+		if err := isPointer(event); err != nil {
+			return nil, err
+		}
+
+		name := marshaler.Name(event)
+		if _, ok := types[name]; ok {
+			return nil, errors.Errorf("event %s is registered more than once", name)
+		}
+
+		types[name] = reflect.TypeOf(event).Elem()
+	}
+
+	return func(eventName string) (any, bool) {
+		eventType, ok := types[eventName]
+		if !ok {
+			return nil, false
+		}
+
+		return reflect.New(eventType).Interface(), true
+	}, nil
+}
+
+// PolymorphicEventHandler is an optional interface of EventHandler.
+// EventProcessor unmarshals the events to the types resolved by ResolveEvent instead of NewEvent,
+// so a single handler can handle a family of related events (for example, all Order* events).
+//
+// NewEvent is still used for the name passed to the topic generators and the handler validation.
+// Keep in mind that the events of the family must be delivered to the handler's topics.
+//
+// Use NewPolymorphicEventHandler to create it from a function.
+// It's not supported by EventGroupProcessor.
+type PolymorphicEventHandler interface {
+	ResolveEvent(eventName string) (event any, ok bool)
+}
+
+type polymorphicEventHandler[T any] struct {
+	handlerName string
+	resolver    EventTypeResolver
+	handleFunc  func(ctx context.Context, event T) error
+}
+
+// NewPolymorphicEventHandler creates a new EventHandler handling all events resolved by the resolver,
+// which implement the T interface.
+//
+// The name of the T type is used as the event name passed to the topic generators,
+// so GenerateSubscribeTopic (or GenerateSubscribeTopics) should return the topics of the whole family.
+//
+//	cqrs.NewPolymorphicEventHandler[OrderEvent](
+//		"OrderProjection",
+//		resolver,
+//		func(ctx context.Context, event OrderEvent) error {
+//			switch e := event.(type) {
+//			case *OrderPlaced:
+//				// ...
+//			}
+//		},
+//	)
+func NewPolymorphicEventHandler[T any](
+	handlerName string,
+	resolver EventTypeResolver,
+	handleFunc func(ctx context.Context, event T) error,
+) EventHandler {
+	return polymorphicEventHandler[T]{
+		handlerName: handlerName,
+		resolver:    resolver,
+		handleFunc:  handleFunc,
+	}
+}
+
+func (h polymorphicEventHandler[T]) HandlerName() string {
+	return h.handlerName
+}
+
+func (h polymorphicEventHandler[T]) NewEvent() any {
+	return new(T)
+}
+
+func (h polymorphicEventHandler[T]) ResolveEvent(eventName string) (any, bool) {
+	return h.resolver(eventName)
+}
+
+func (h polymorphicEventHandler[T]) Handle(ctx context.Context, e any) error {
+	event, ok := e.(T)
+	if !ok {
+		return fmt.Errorf("event %T doesn't implement %s", e, reflect.TypeOf(new(T)).Elem())
+	}
+
+	return h.handleFunc(ctx, event)
+}
+
+func polymorphicEventHandlerOf(handler EventHandler) (PolymorphicEventHandler, bool) {
+	if withOptions, ok := handler.(eventHandlerWithSubscriberOptions); ok {
+		handler = withOptions.EventHandler
+	}
+
+	polymorphicHandler, ok := handler.(PolymorphicEventHandler)
+	return polymorphicHandler, ok
+}
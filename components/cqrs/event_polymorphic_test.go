@@ -0,0 +1,144 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type OrderEvent interface {
+	OrderID() string
+}
+
+func (o OrderPlaced) OrderID() string {
+	return o.ID
+}
+
+func (o OrderShipped) OrderID() string {
+	return o.ID
+}
+
+func TestEventProcessor_polymorphic_handler(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	resolver, err := cqrs.NewEventTypeResolver(marshaler, &OrderPlaced{}, &OrderShipped{})
+	require.NoError(t, err)
+
+	var events []OrderEvent
+	lock := sync.Mutex{}
+
+	handler := cqrs.NewPolymorphicEventHandler[OrderEvent](
+		"orders",
+		resolver,
+		func(ctx context.Context, event OrderEvent) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			events = append(events, event)
+			return nil
+		},
+	)
+
+	placedMsg, err := marshaler.Marshal(&OrderPlaced{ID: "1"})
+	require.NoError(t, err)
+	shippedMsg, err := marshaler.Marshal(&OrderShipped{ID: "1"})
+	require.NoError(t, err)
+	unknownMsg, err := marshaler.Marshal(&TestEvent{ID: "2"})
+	require.NoError(t, err)
+
+	var topicEventName string
+	runEventProcessor(t, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			topicEventName = params.EventName
+			return "orders", nil
+		},
+		AckOnUnknownEvent: true,
+	}, handler, placedMsg, shippedMsg, unknownMsg)
+
+	requireAcked(t, placedMsg)
+	requireAcked(t, shippedMsg)
+	requireAcked(t, unknownMsg)
+
+	assert.Equal(t, "cqrs_test.OrderEvent", topicEventName)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the subscriber doesn't wait for acks, so the events can be handled in any order
+	assert.ElementsMatch(t, []OrderEvent{&OrderPlaced{ID: "1"}, &OrderShipped{ID: "1"}}, events)
+}
+
+func TestEventProcessor_polymorphic_handler_unknown_event(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	resolver, err := cqrs.NewEventTypeResolver(marshaler, &OrderPlaced{})
+	require.NoError(t, err)
+
+	handler := cqrs.NewPolymorphicEventHandler[OrderEvent](
+		"orders",
+		resolver,
+		func(ctx context.Context, event OrderEvent) error {
+			t.Fatal("unknown event should not be handled")
+			return nil
+		},
+	)
+
+	msg, err := marshaler.Marshal(&OrderShipped{ID: "1"})
+	require.NoError(t, err)
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{}, handler, msg)
+
+	requireNacked(t, msg)
+}
+
+func TestEventProcessor_polymorphic_handler_event_not_implementing_interface(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	resolver, err := cqrs.NewEventTypeResolver(marshaler, &TestEvent{})
+	require.NoError(t, err)
+
+	handler := cqrs.NewPolymorphicEventHandler[OrderEvent](
+		"orders",
+		resolver,
+		func(ctx context.Context, event OrderEvent) error {
+			t.Fatal("event not implementing the interface should not be handled")
+			return nil
+		},
+	)
+
+	msg, err := marshaler.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{}, handler, msg)
+
+	requireNacked(t, msg)
+}
+
+func TestNewEventTypeResolver(t *testing.T) {
+	marshaler := cqrs.JSONMarshaler{}
+
+	resolver, err := cqrs.NewEventTypeResolver(marshaler, &OrderPlaced{}, &OrderShipped{})
+	require.NoError(t, err)
+
+	event, ok := resolver("cqrs_test.OrderPlaced")
+	require.True(t, ok)
+	assert.IsType(t, &OrderPlaced{}, event)
+
+	// new instance is returned every time
+	otherEvent, _ := resolver("cqrs_test.OrderPlaced")
+	assert.NotSame(t, event, otherEvent)
+
+	_, ok = resolver("cqrs_test.TestEvent")
+	assert.False(t, ok)
+
+	_, err = cqrs.NewEventTypeResolver(marshaler, OrderPlaced{})
+	assert.Error(t, err)
+
+	_, err = cqrs.NewEventTypeResolver(marshaler, &OrderPlaced{}, &OrderPlaced{})
+	assert.Error(t, err)
+}
@@ -1,8 +1,10 @@
 package cqrs
 
 import (
+	"context"
 	stdErrors "errors"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -15,10 +17,22 @@ type EventProcessorConfig struct {
 	// If event processor is using handler groups, GenerateSubscribeTopic is used instead.
 	GenerateSubscribeTopic EventProcessorGenerateSubscribeTopicFn
 
+	// GenerateSubscribeTopics can be used instead of GenerateSubscribeTopic to consume the event from multiple topics
+	// (for example, when the same event type is published by multiple services to their own topics).
+	//
+	// One router handler is added per topic, all sharing the same EventHandler.
+	// If more than one topic is returned, the router handlers are named "<handler name>_<topic>".
+	//
+	// Only one of GenerateSubscribeTopic and GenerateSubscribeTopics can be set.
+	GenerateSubscribeTopics EventProcessorGenerateSubscribeTopicsFn
+
 	// SubscriberConstructor is used to create subscriber for EventHandler.
 	//
 	// This function is called for every EventHandler instance.
 	// If you want to re-use one subscriber for multiple handlers, use GroupEventProcessor instead.
+	//
+	// Individual handlers can use their own subscriber constructor or consumer group
+	// by implementing EventHandlerWithSubscriberOptions (see NewEventHandlerWithSubscriberOptions).
 	SubscriberConstructor EventProcessorSubscriberConstructorFn
 
 	// OnHandle is called before handling event.
@@ -44,6 +58,37 @@ type EventProcessorConfig struct {
 	// AckOnUnknownEvent is used to decide if message should be acked if event has no handler defined.
 	AckOnUnknownEvent bool
 
+	// IsTombstone decides if the message is a tombstone (see Tombstone).
+	// Tombstones are passed to handlers implementing TombstoneEventHandler instead of being unmarshaled.
+	// If not provided, IsTombstone is used.
+	IsTombstone func(msg *message.Message) bool
+
+	// AckOnTombstone is used to decide if tombstones should be acked by handlers not implementing
+	// TombstoneEventHandler. If false, they are unmarshaled to the event as other messages.
+	AckOnTombstone bool
+
+	// HandlerTimeout is the maximum duration of handling a single event by a handler.
+	// When it's exceeded, the context passed to the handler is canceled and HandlerTimeoutError is returned
+	// (so the message is nacked), without waiting for the handler to return.
+	// The handler keeps running in the background until it returns, so it should respect the context cancellation.
+	// Disabled if 0.
+	HandlerTimeout time.Duration
+
+	// HandlerTimeouts overrides HandlerTimeout for the handlers with the given names.
+	HandlerTimeouts map[string]time.Duration
+
+	// AtMostOnce enables the at-most-once delivery: the message is acked before the handler is called,
+	// so it's never redelivered, even if the handler returns an error or the service crashes.
+	// It's useful for handlers for which duplicated side effects are worse than occasional losses
+	// (for example, sending push notifications).
+	//
+	// Handler errors are logged and not returned, so the message is not retried by the router's middlewares either.
+	// Keep in mind that the subscriber may deliver the next message before the handler returns.
+	AtMostOnce bool
+
+	// AtMostOnceHandlers overrides AtMostOnce for the handlers with the given names.
+	AtMostOnceHandlers map[string]bool
+
 	// Marshaler is used to marshal and unmarshal events.
 	// It is required.
 	Marshaler CommandEventMarshaler
@@ -52,6 +97,16 @@ type EventProcessorConfig struct {
 	// If not provided, watermill.NopLogger is used.
 	Logger watermill.LoggerAdapter
 
+	// RouterHandlersOwner if not empty enables the upsert mode of adding handlers to the router,
+	// so the processor can be reconstructed with the same router, for example, when the configuration is reloaded.
+	//
+	// The router's handlers are marked as owned by RouterHandlersOwner. Handlers already added to the router
+	// by a processor with the same owner are kept, instead of panicking with message.DuplicateHandlerNameError.
+	// Handlers which are not registered in the processor anymore are removed with SyncRouterHandlers.
+	//
+	// Handlers are matched by name, so a kept handler is not updated.
+	RouterHandlersOwner string
+
 	// disableRouterAutoAddHandlers is used to keep backwards compatibility.
 	// it is set when EventProcessor is created by NewEventProcessor.
 	// Deprecated: please migrate to NewEventProcessorWithConfig.
@@ -62,6 +117,9 @@ func (c *EventProcessorConfig) setDefaults() {
 	if c.Logger == nil {
 		c.Logger = watermill.NopLogger{}
 	}
+	if c.IsTombstone == nil {
+		c.IsTombstone = IsTombstone
+	}
 }
 
 func (c EventProcessorConfig) Validate() error {
@@ -71,9 +129,12 @@ func (c EventProcessorConfig) Validate() error {
 		err = stdErrors.Join(err, errors.New("missing Marshaler"))
 	}
 
-	if c.GenerateSubscribeTopic == nil {
+	if c.GenerateSubscribeTopic == nil && c.GenerateSubscribeTopics == nil {
 		err = stdErrors.Join(err, errors.New("missing GenerateHandlerTopic"))
 	}
+	if c.GenerateSubscribeTopic != nil && c.GenerateSubscribeTopics != nil {
+		err = stdErrors.Join(err, errors.New("only one of GenerateSubscribeTopic and GenerateSubscribeTopics can be set"))
+	}
 	if c.SubscriberConstructor == nil {
 		err = stdErrors.Join(err, errors.New("missing SubscriberConstructor"))
 	}
@@ -83,6 +144,8 @@ func (c EventProcessorConfig) Validate() error {
 
 type EventProcessorGenerateSubscribeTopicFn func(EventProcessorGenerateSubscribeTopicParams) (string, error)
 
+type EventProcessorGenerateSubscribeTopicsFn func(EventProcessorGenerateSubscribeTopicParams) ([]string, error)
+
 type EventProcessorGenerateSubscribeTopicParams struct {
 	EventName    string
 	EventHandler EventHandler
@@ -93,6 +156,15 @@ type EventProcessorSubscriberConstructorFn func(EventProcessorSubscriberConstruc
 type EventProcessorSubscriberConstructorParams struct {
 	HandlerName  string
 	EventHandler EventHandler
+
+	// ConsumerGroup is the consumer group requested by the handler with EventHandlerSubscriberOptions.
+	// It's equal to HandlerName, if the handler doesn't specify it.
+	ConsumerGroup string
+
+	// Topic is the topic to which the subscriber subscribes.
+	// When the handler consumes multiple topics (see EventProcessorConfig.GenerateSubscribeTopics),
+	// the constructor is called once per topic.
+	Topic string
 }
 
 type EventProcessorOnHandleFn func(params EventProcessorOnHandleParams) error
@@ -112,6 +184,9 @@ type EventProcessor struct {
 	router   *message.Router
 	handlers []EventHandler
 	config   EventProcessorConfig
+
+	// upsert is nil if the upsert mode is disabled
+	upsert *routerHandlersUpsert
 }
 
 // NewEventProcessorWithConfig creates a new EventProcessor.
@@ -128,6 +203,7 @@ func NewEventProcessorWithConfig(router *message.Router, config EventProcessorCo
 	return &EventProcessor{
 		router: router,
 		config: config,
+		upsert: newRouterHandlersUpsert(config.RouterHandlersOwner),
 	}, nil
 }
 
@@ -236,50 +312,146 @@ func (p EventProcessor) addHandlerToRouter(r *message.Router, handler EventHandl
 	}
 
 	handlerName := handler.HandlerName()
-	eventName := p.config.Marshaler.Name(handler.NewEvent())
 
-	topicName, err := p.config.GenerateSubscribeTopic(EventProcessorGenerateSubscribeTopicParams{
-		EventName:    eventName,
-		EventHandler: handler,
-	})
+	topicNames, err := p.subscribeTopics(handler)
 	if err != nil {
-		return errors.Wrapf(err, "cannot generate topic name for handler %s", handlerName)
+		return err
 	}
 
-	logger := p.config.Logger.With(watermill.LogFields{
-		"event_handler_name": handlerName,
-		"topic":              topicName,
-	})
+	subscriberConstructor := p.config.SubscriberConstructor
+	consumerGroup := handlerName
 
-	handlerFunc, err := p.routerHandlerFunc(handler, logger)
-	if err != nil {
-		return err
+	if handlerWithOptions, ok := handler.(EventHandlerWithSubscriberOptions); ok {
+		options := handlerWithOptions.SubscriberOptions()
+
+		if options.SubscriberConstructor != nil {
+			subscriberConstructor = options.SubscriberConstructor
+		}
+		if options.ConsumerGroup != "" {
+			consumerGroup = options.ConsumerGroup
+		}
 	}
 
-	if p.config.SubscriberConstructor == nil {
+	if subscriberConstructor == nil {
 		return errors.New("missing SubscriberConstructor config option")
 	}
 
-	subscriber, err := p.config.SubscriberConstructor(EventProcessorSubscriberConstructorParams{
-		HandlerName:  handlerName,
+	for _, topicName := range topicNames {
+		routerHandlerName := handlerName
+		if len(topicNames) > 1 {
+			routerHandlerName = fmt.Sprintf("%s_%s", handlerName, topicName)
+		}
+
+		exists, err := p.upsert.register(r, routerHandlerName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		logger := p.config.Logger.With(watermill.LogFields{
+			"event_handler_name": handlerName,
+			"topic":              topicName,
+		})
+
+		handlerFunc, err := p.routerHandlerFunc(handler, logger)
+		if err != nil {
+			return err
+		}
+
+		subscriber, err := subscriberConstructor(EventProcessorSubscriberConstructorParams{
+			HandlerName:   handlerName,
+			EventHandler:  handler,
+			ConsumerGroup: consumerGroup,
+			Topic:         topicName,
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot create subscriber for event processor")
+		}
+
+		if err := addHandlerToRouter(p.config.Logger, r, p.upsert, routerHandlerName, topicName, handlerFunc, subscriber); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p EventProcessor) subscribeTopics(handler EventHandler) ([]string, error) {
+	params := EventProcessorGenerateSubscribeTopicParams{
+		EventName:    p.config.Marshaler.Name(handler.NewEvent()),
 		EventHandler: handler,
-	})
-	if err != nil {
-		return errors.Wrap(err, "cannot create subscriber for event processor")
 	}
 
-	if err := addHandlerToRouter(p.config.Logger, r, handlerName, topicName, handlerFunc, subscriber); err != nil {
-		return err
+	if p.config.GenerateSubscribeTopics != nil {
+		topicNames, err := p.config.GenerateSubscribeTopics(params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot generate topic names for handler %s", handler.HandlerName())
+		}
+		if len(topicNames) == 0 {
+			return nil, errors.Errorf("no topics generated for handler %s", handler.HandlerName())
+		}
+
+		return topicNames, nil
 	}
 
-	return nil
+	topicName, err := p.config.GenerateSubscribeTopic(params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot generate topic name for handler %s", handler.HandlerName())
+	}
+
+	return []string{topicName}, nil
+}
+
+// SyncRouterHandlers removes the router's handlers owned by EventProcessorConfig.RouterHandlersOwner,
+// which are not registered in the processor, and returns the changes made since the processor was created.
+// It should be called after all handlers were added, for example, after reconstructing the processor.
+//
+// It returns an error, if RouterHandlersOwner is not set.
+func (p *EventProcessor) SyncRouterHandlers() (RouterHandlersDiff, error) {
+	return p.upsert.sync(p.router)
 }
 
 func (p EventProcessor) Handlers() []EventHandler {
 	return p.handlers
 }
 
-func addHandlerToRouter(logger watermill.LoggerAdapter, r *message.Router, handlerName string, topicName string, handlerFunc message.NoPublishHandlerFunc, subscriber message.Subscriber) error {
+// HandlerTopics returns the topics from which the handler consumes events.
+func (p EventProcessor) HandlerTopics(handler EventHandler) ([]string, error) {
+	return p.subscribeTopics(handler)
+}
+
+// Marshaler returns the marshaler used to unmarshal the events.
+func (p EventProcessor) Marshaler() CommandEventMarshaler {
+	return p.config.Marshaler
+}
+
+// SubscribedTopics returns the topics to which the EventProcessor's handlers subscribe.
+func (p EventProcessor) SubscribedTopics() ([]string, error) {
+	var topics []string
+
+	for _, handler := range p.handlers {
+		topicNames, err := p.subscribeTopics(handler)
+		if err != nil {
+			return nil, err
+		}
+
+		topics = append(topics, topicNames...)
+	}
+
+	return topics, nil
+}
+
+func addHandlerToRouter(
+	logger watermill.LoggerAdapter,
+	r *message.Router,
+	upsert *routerHandlersUpsert,
+	handlerName string,
+	topicName string,
+	handlerFunc message.NoPublishHandlerFunc,
+	subscriber message.Subscriber,
+) error {
 	logger = logger.With(watermill.LogFields{
 		"event_handler_name": handlerName,
 		"topic":              topicName,
@@ -287,12 +459,13 @@ func addHandlerToRouter(logger watermill.LoggerAdapter, r *message.Router, handl
 
 	logger.Debug("Adding CQRS event handler to router", nil)
 
-	r.AddNoPublisherHandler(
+	handler := r.AddNoPublisherHandler(
 		handlerName,
 		topicName,
 		subscriber,
 		handlerFunc,
 	)
+	upsert.added(handler, handlerName)
 
 	return nil
 }
@@ -305,11 +478,35 @@ func (p EventProcessor) routerHandlerFunc(handler EventHandler, logger watermill
 		return nil, err
 	}
 
+	polymorphicHandler, isPolymorphic := polymorphicEventHandlerOf(handler)
+
 	return func(msg *message.Message) error {
-		event := handler.NewEvent()
 		messageEventName := p.config.Marshaler.NameFromMessage(msg)
 
-		if messageEventName != expectedEventName {
+		var event any
+		isExpectedEvent := false
+		if isPolymorphic {
+			event, isExpectedEvent = polymorphicHandler.ResolveEvent(messageEventName)
+		} else {
+			event = handler.NewEvent()
+			isExpectedEvent = messageEventName == expectedEventName
+		}
+
+		if p.config.IsTombstone(msg) && (messageEventName == "" || isExpectedEvent) {
+			tombstoneHandler, ok := tombstoneEventHandler(handler)
+			if ok {
+				return p.handleTombstone(tombstoneHandler, handler.HandlerName(), messageEventName, msg, logger)
+			}
+			if p.config.AckOnTombstone {
+				logger.Trace("Received tombstone, but handler doesn't handle tombstones, ignoring", watermill.LogFields{
+					"message_uuid": msg.UUID,
+					"handler_name": handler.HandlerName(),
+				})
+				return nil
+			}
+		}
+
+		if !isExpectedEvent {
 			if !p.config.AckOnUnknownEvent {
 				return fmt.Errorf("received unexpected event type %s, expected %s", messageEventName, expectedEventName)
 			} else {
@@ -334,19 +531,32 @@ func (p EventProcessor) routerHandlerFunc(handler EventHandler, logger watermill
 			return err
 		}
 
-		handle := func(params EventProcessorOnHandleParams) error {
-			return params.Handler.Handle(ctx, params.Event)
+		handlerName := handler.HandlerName()
+		timeout := handlerTimeout(p.config.HandlerTimeout, p.config.HandlerTimeouts, handlerName)
+
+		handleEvent := func() error {
+			return handleWithTimeout(msg, handlerName, timeout, func(ctx context.Context) error {
+				handle := func(params EventProcessorOnHandleParams) error {
+					return params.Handler.Handle(ctx, params.Event)
+				}
+				if p.config.OnHandle != nil {
+					handle = p.config.OnHandle
+				}
+
+				return handle(EventProcessorOnHandleParams{
+					Handler:   handler,
+					Event:     event,
+					EventName: messageEventName,
+					Message:   msg,
+				})
+			})
 		}
-		if p.config.OnHandle != nil {
-			handle = p.config.OnHandle
+
+		if handlerAtMostOnce(p.config.AtMostOnce, p.config.AtMostOnceHandlers, handlerName) {
+			return handleAtMostOnce(msg, logger, handleEvent)
 		}
 
-		err := handle(EventProcessorOnHandleParams{
-			Handler:   handler,
-			Event:     event,
-			EventName: messageEventName,
-			Message:   msg,
-		})
+		err := handleEvent()
 		if err != nil {
 			logger.Debug("Error when handling event", watermill.LogFields{"err": err})
 			return err
@@ -356,6 +566,32 @@ func (p EventProcessor) routerHandlerFunc(handler EventHandler, logger watermill
 	}, nil
 }
 
+func (p EventProcessor) handleTombstone(
+	handler TombstoneEventHandler,
+	handlerName string,
+	eventName string,
+	msg *message.Message,
+	logger watermill.LoggerAdapter,
+) error {
+	logger.Debug("Handling tombstone", watermill.LogFields{
+		"message_uuid": msg.UUID,
+		"handler_name": handlerName,
+	})
+
+	ctx := CtxWithOriginalMessage(msg.Context(), msg)
+	msg.SetContext(ctx)
+
+	timeout := handlerTimeout(p.config.HandlerTimeout, p.config.HandlerTimeouts, handlerName)
+
+	return handleWithTimeout(msg, handlerName, timeout, func(ctx context.Context) error {
+		return handler.HandleTombstone(ctx, Tombstone{
+			Key:       msg.Metadata.Get(TombstoneKeyMetadataKey),
+			EventName: eventName,
+			Message:   msg,
+		})
+	})
+}
+
 func validateEvent(event interface{}) error {
 	// EventHandler's NewEvent must return a pointer, because it is used to unmarshal
 	if err := isPointer(event); err != nil {
@@ -1,8 +1,10 @@
 package cqrs
 
 import (
+	"context"
 	stdErrors "errors"
 	"fmt"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -42,6 +44,20 @@ type EventGroupProcessorConfig struct {
 	// AckOnUnknownEvent is used to decide if message should be acked if event has no handler defined.
 	AckOnUnknownEvent bool
 
+	// HandlerTimeout is the maximum duration of handling a single event by a handlers group.
+	// When it's exceeded, the context passed to the handler is canceled and HandlerTimeoutError is returned
+	// (so the message is nacked), without waiting for the handler to return.
+	// The handler keeps running in the background until it returns, so it should respect the context cancellation.
+	// Disabled if 0.
+	HandlerTimeout time.Duration
+
+	// HandlerTimeouts overrides HandlerTimeout for the handler groups with the given names.
+	HandlerTimeouts map[string]time.Duration
+
+	// Partitioning if not nil splits the handlers of all groups between multiple processes, by event name.
+	// See EventGroupPartitioning for details.
+	Partitioning *EventGroupPartitioning
+
 	// Marshaler is used to marshal and unmarshal events.
 	// It is required.
 	Marshaler CommandEventMarshaler
@@ -49,6 +65,10 @@ type EventGroupProcessorConfig struct {
 	// Logger instance used to log.
 	// If not provided, watermill.NopLogger is used.
 	Logger watermill.LoggerAdapter
+
+	// RouterHandlersOwner if not empty enables the upsert mode of adding handler groups to the router.
+	// See EventProcessorConfig.RouterHandlersOwner for details.
+	RouterHandlersOwner string
 }
 
 func (c *EventGroupProcessorConfig) setDefaults() {
@@ -70,6 +90,11 @@ func (c EventGroupProcessorConfig) Validate() error {
 	if c.SubscriberConstructor == nil {
 		err = stdErrors.Join(err, errors.New("missing SubscriberConstructor"))
 	}
+	if c.Partitioning != nil {
+		if partitioningErr := c.Partitioning.Validate(); partitioningErr != nil {
+			err = stdErrors.Join(err, errors.Wrap(partitioningErr, "invalid Partitioning"))
+		}
+	}
 
 	return err
 }
@@ -86,6 +111,10 @@ type EventGroupProcessorSubscriberConstructorFn func(EventGroupProcessorSubscrib
 type EventGroupProcessorSubscriberConstructorParams struct {
 	EventGroupName     string
 	EventGroupHandlers []GroupEventHandler
+
+	// ConsumerGroup is the suggested consumer group of the subscriber.
+	// It's EventGroupName, or "<EventGroupName>_partition_<Partition>" when EventGroupProcessorConfig.Partitioning is set.
+	ConsumerGroup string
 }
 
 type EventGroupProcessorOnHandleFn func(params EventGroupProcessorOnHandleParams) error
@@ -107,8 +136,12 @@ type EventGroupProcessor struct {
 	router *message.Router
 
 	groupEventHandlers map[string][]GroupEventHandler
+	replays            map[string]*groupReplay
 
 	config EventGroupProcessorConfig
+
+	// upsert is nil if the upsert mode is disabled
+	upsert *routerHandlersUpsert
 }
 
 // NewEventGroupProcessorWithConfig creates a new EventGroupProcessor.
@@ -125,7 +158,9 @@ func NewEventGroupProcessorWithConfig(router *message.Router, config EventGroupP
 	return &EventGroupProcessor{
 		router:             router,
 		groupEventHandlers: map[string][]GroupEventHandler{},
+		replays:            map[string]*groupReplay{},
 		config:             config,
+		upsert:             newRouterHandlersUpsert(config.RouterHandlersOwner),
 	}, nil
 }
 
@@ -144,7 +179,7 @@ func (p *EventGroupProcessor) AddHandlersGroup(groupName string, handlers ...Gro
 		return fmt.Errorf("event handler group '%s' already exists", groupName)
 	}
 
-	if err := p.addHandlerToRouter(p.router, groupName, handlers); err != nil {
+	if err := p.addHandlerToRouter(p.router, groupName, handlers, nil); err != nil {
 		return err
 	}
 
@@ -153,7 +188,20 @@ func (p *EventGroupProcessor) AddHandlersGroup(groupName string, handlers ...Gro
 	return nil
 }
 
-func (p EventGroupProcessor) addHandlerToRouter(r *message.Router, groupName string, handlersGroup []GroupEventHandler) error {
+func (p EventGroupProcessor) addHandlerToRouter(
+	r *message.Router,
+	groupName string,
+	handlersGroup []GroupEventHandler,
+	replay *groupReplay,
+) error {
+	exists, err := p.upsert.register(r, groupName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
 	for i, handler := range handlersGroup {
 		if err := validateEvent(handler.NewEvent()); err != nil {
 			return errors.Wrapf(
@@ -184,21 +232,61 @@ func (p EventGroupProcessor) addHandlerToRouter(r *message.Router, groupName str
 		return err
 	}
 
+	consumerGroup := groupName
+	if p.config.Partitioning != nil {
+		consumerGroup = p.config.Partitioning.consumerGroup(groupName)
+	}
+
 	subscriber, err := p.config.SubscriberConstructor(EventGroupProcessorSubscriberConstructorParams{
 		EventGroupName:     groupName,
 		EventGroupHandlers: handlersGroup,
+		ConsumerGroup:      consumerGroup,
 	})
 	if err != nil {
 		return errors.Wrap(err, "cannot create subscriber for event processor")
 	}
 
-	if err := addHandlerToRouter(p.config.Logger, r, groupName, topicName, handlerFunc, subscriber); err != nil {
+	if replay != nil {
+		subscriber, err = replay.subscriber(subscriber)
+		if err != nil {
+			return errors.Wrapf(err, "cannot replay handler group %s", groupName)
+		}
+		handlerFunc = replay.countProcessed(handlerFunc, logger)
+	}
+
+	if err := addHandlerToRouter(p.config.Logger, r, p.upsert, groupName, topicName, handlerFunc, subscriber); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// SyncRouterHandlers removes the router's handlers owned by EventGroupProcessorConfig.RouterHandlersOwner,
+// which are not registered in the processor, and returns the changes made since the processor was created.
+// See EventProcessor.SyncRouterHandlers for details.
+func (p *EventGroupProcessor) SyncRouterHandlers() (RouterHandlersDiff, error) {
+	return p.upsert.sync(p.router)
+}
+
+// SubscribedTopics returns the topics to which the EventGroupProcessor's handler groups subscribe.
+func (p EventGroupProcessor) SubscribedTopics() ([]string, error) {
+	var topics []string
+
+	for groupName, handlers := range p.groupEventHandlers {
+		topicName, err := p.config.GenerateSubscribeTopic(EventGroupProcessorGenerateSubscribeTopicParams{
+			EventGroupName:     groupName,
+			EventGroupHandlers: handlers,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot generate topic name for handler group %s", groupName)
+		}
+
+		topics = append(topics, topicName)
+	}
+
+	return topics, nil
+}
+
 func (p EventGroupProcessor) routerHandlerGroupFunc(handlers []GroupEventHandler, groupName string, logger watermill.LoggerAdapter) (message.NoPublishHandlerFunc, error) {
 	return func(msg *message.Message) error {
 		messageEventName := p.config.Marshaler.NameFromMessage(msg)
@@ -218,6 +306,15 @@ func (p EventGroupProcessor) routerHandlerGroupFunc(handlers []GroupEventHandler
 				continue
 			}
 
+			if p.config.Partitioning != nil && !p.config.Partitioning.OwnsEvent(messageEventName) {
+				logger.Trace("Event is handled by another partition, ignoring", watermill.LogFields{
+					"message_uuid":        msg.UUID,
+					"received_event_type": messageEventName,
+					"event_partition":     p.config.Partitioning.PartitionOfEvent(messageEventName),
+				})
+				return nil
+			}
+
 			logger.Debug("Handling event", watermill.LogFields{
 				"message_uuid":        msg.UUID,
 				"received_event_type": messageEventName,
@@ -230,19 +327,23 @@ func (p EventGroupProcessor) routerHandlerGroupFunc(handlers []GroupEventHandler
 				return err
 			}
 
-			handle := func(params EventGroupProcessorOnHandleParams) error {
-				return params.Handler.Handle(ctx, params.Event)
-			}
-			if p.config.OnHandle != nil {
-				handle = p.config.OnHandle
-			}
-
-			err := handle(EventGroupProcessorOnHandleParams{
-				GroupName: groupName,
-				Handler:   handler,
-				EventName: messageEventName,
-				Event:     event,
-				Message:   msg,
+			timeout := handlerTimeout(p.config.HandlerTimeout, p.config.HandlerTimeouts, groupName)
+
+			err := handleWithTimeout(msg, groupName, timeout, func(ctx context.Context) error {
+				handle := func(params EventGroupProcessorOnHandleParams) error {
+					return params.Handler.Handle(ctx, params.Event)
+				}
+				if p.config.OnHandle != nil {
+					handle = p.config.OnHandle
+				}
+
+				return handle(EventGroupProcessorOnHandleParams{
+					GroupName: groupName,
+					Handler:   handler,
+					EventName: messageEventName,
+					Event:     event,
+					Message:   msg,
+				})
 			})
 			if err != nil {
 				logger.Debug("Error when handling event", watermill.LogFields{"err": err})
@@ -0,0 +1,177 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// EventGroupReplayConfig configures replaying the historical events of a handler group (see AddHandlersGroupReplay).
+type EventGroupReplayConfig struct {
+	// From is the position from which the events are replayed.
+	// The zero value replays all events available in the transport.
+	From message.ReplayPosition
+
+	// OnProgress is called when the replay starts, and after every replayed event processed by the group,
+	// until the group caught up. This option is not required.
+	OnProgress func(EventGroupReplayProgress)
+}
+
+// EventGroupReplayProgress describes the progress of replaying the historical events of a handler group.
+type EventGroupReplayProgress struct {
+	GroupName string
+
+	// Processed is the number of historical events processed by the handler group.
+	Processed int64
+
+	// Total is the number of historical events to replay, reported by the subscriber when the replay started.
+	Total int64
+
+	// CaughtUp is true when all historical events were processed, and the group handles live events.
+	CaughtUp bool
+}
+
+// AddHandlersGroupReplay works like AddHandlersGroup, but the handler group is started in the replay mode:
+// it processes the historical events from config.From until it caught up, and then it continues with live events.
+// It standardizes rebuilding projections, which are usually added as a new handler group with the replay.
+//
+// The subscriber created by SubscriberConstructor must implement message.SubscriberWithReplay.
+// The progress is reported to config.OnProgress and by ReplayProgress. It's reset when the handler subscribes again,
+// for example, after the router is restarted.
+//
+// Only events processed without an error are counted as processed, so events acked by middlewares
+// after a failure (like the poison queue) are not counted.
+func (p *EventGroupProcessor) AddHandlersGroupReplay(groupName string, config EventGroupReplayConfig, handlers ...GroupEventHandler) error {
+	if len(handlers) == 0 {
+		return errors.New("no handlers provided")
+	}
+	if _, ok := p.groupEventHandlers[groupName]; ok {
+		return fmt.Errorf("event handler group '%s' already exists", groupName)
+	}
+
+	replay := &groupReplay{
+		config:   config,
+		progress: EventGroupReplayProgress{GroupName: groupName},
+	}
+
+	if err := p.addHandlerToRouter(p.router, groupName, handlers, replay); err != nil {
+		return err
+	}
+
+	p.groupEventHandlers[groupName] = handlers
+	p.replays[groupName] = replay
+
+	return nil
+}
+
+// ReplayProgress returns the progress of replaying the handler group added with AddHandlersGroupReplay.
+func (p EventGroupProcessor) ReplayProgress(groupName string) (EventGroupReplayProgress, error) {
+	replay, ok := p.replays[groupName]
+	if !ok {
+		return EventGroupReplayProgress{}, errors.Errorf("event handler group '%s' is not replayed", groupName)
+	}
+
+	return replay.currentProgress(), nil
+}
+
+type groupReplay struct {
+	config EventGroupReplayConfig
+
+	progress     EventGroupReplayProgress
+	progressLock sync.Mutex
+}
+
+func (r *groupReplay) subscriber(sub message.Subscriber) (message.Subscriber, error) {
+	withReplay, ok := sub.(message.SubscriberWithReplay)
+	if !ok {
+		return nil, message.ErrReplayNotSupported
+	}
+
+	return replaySubscriber{SubscriberWithReplay: withReplay, replay: r}, nil
+}
+
+func (r *groupReplay) currentProgress() EventGroupReplayProgress {
+	r.progressLock.Lock()
+	defer r.progressLock.Unlock()
+
+	return r.progress
+}
+
+func (r *groupReplay) start(info message.ReplayInfo) {
+	r.progressLock.Lock()
+	r.progress = EventGroupReplayProgress{
+		GroupName: r.progress.GroupName,
+		Total:     info.Messages,
+		CaughtUp:  info.Messages == 0,
+	}
+	progress := r.progress
+	r.progressLock.Unlock()
+
+	r.report(progress)
+}
+
+// processed counts the processed event, and returns false if the group already caught up.
+func (r *groupReplay) processed() (EventGroupReplayProgress, bool) {
+	r.progressLock.Lock()
+	defer r.progressLock.Unlock()
+
+	if r.progress.CaughtUp {
+		return r.progress, false
+	}
+
+	r.progress.Processed++
+	r.progress.CaughtUp = r.progress.Processed >= r.progress.Total
+
+	return r.progress, true
+}
+
+func (r *groupReplay) report(progress EventGroupReplayProgress) {
+	if r.config.OnProgress != nil {
+		r.config.OnProgress(progress)
+	}
+}
+
+func (r *groupReplay) countProcessed(h message.NoPublishHandlerFunc, logger watermill.LoggerAdapter) message.NoPublishHandlerFunc {
+	return func(msg *message.Message) error {
+		if err := h(msg); err != nil {
+			return err
+		}
+
+		progress, replaying := r.processed()
+		if !replaying {
+			return nil
+		}
+
+		r.report(progress)
+
+		if progress.CaughtUp {
+			logger.Info("Replay of handler group caught up, handling live events", watermill.LogFields{
+				"replayed_events": progress.Processed,
+			})
+		}
+
+		return nil
+	}
+}
+
+// replaySubscriber subscribes from the replay position, so the router's handler starts with the historical events.
+type replaySubscriber struct {
+	message.SubscriberWithReplay
+	replay *groupReplay
+}
+
+func (s replaySubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	messages, info, err := s.SubscribeFrom(ctx, topic, s.replay.config.From)
+	if err != nil {
+		return nil, err
+	}
+
+	s.replay.start(info)
+
+	return messages, nil
+}
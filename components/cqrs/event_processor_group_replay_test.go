@@ -0,0 +1,165 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func newReplayTestProcessor(t *testing.T, sub message.Subscriber) (*message.Router, *cqrs.EventGroupProcessor) {
+	t.Helper()
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	processor, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		},
+		SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return sub, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+	})
+	require.NoError(t, err)
+
+	return router, processor
+}
+
+func publishTestEvents(t *testing.T, pubSub message.Publisher, ids ...string) {
+	t.Helper()
+
+	for _, id := range ids {
+		msg, err := cqrs.JSONMarshaler{}.Marshal(&TestEvent{ID: id})
+		require.NoError(t, err)
+		require.NoError(t, pubSub.Publish("events", msg))
+	}
+}
+
+func TestEventGroupProcessor_AddHandlersGroupReplay(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		Persistent:            true,
+		DeterministicDelivery: true,
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	publishTestEvents(t, pubSub, "1", "2", "3")
+
+	router, processor := newReplayTestProcessor(t, pubSub)
+
+	var lock sync.Mutex
+	var handled []string
+	var progress []cqrs.EventGroupReplayProgress
+
+	err := processor.AddHandlersGroupReplay(
+		"projection",
+		cqrs.EventGroupReplayConfig{
+			From: message.ReplayPosition{Offset: 1},
+			OnProgress: func(p cqrs.EventGroupReplayProgress) {
+				lock.Lock()
+				defer lock.Unlock()
+				progress = append(progress, p)
+			},
+		},
+		cqrs.NewGroupEventHandler(func(ctx context.Context, event *TestEvent) error {
+			lock.Lock()
+			defer lock.Unlock()
+			handled = append(handled, event.ID)
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	require.Eventually(t, func() bool {
+		p, err := processor.ReplayProgress("projection")
+		require.NoError(t, err)
+		return p.CaughtUp
+	}, time.Second, time.Millisecond)
+
+	publishTestEvents(t, pubSub, "4")
+
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(handled) == 3
+	}, time.Second, time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{"2", "3", "4"}, handled)
+	assert.Equal(t, []cqrs.EventGroupReplayProgress{
+		{GroupName: "projection", Total: 2},
+		{GroupName: "projection", Total: 2, Processed: 1},
+		{GroupName: "projection", Total: 2, Processed: 2, CaughtUp: true},
+	}, progress, "live events should not be reported")
+
+	finalProgress, err := processor.ReplayProgress("projection")
+	require.NoError(t, err)
+	assert.Equal(t, cqrs.EventGroupReplayProgress{GroupName: "projection", Total: 2, Processed: 2, CaughtUp: true}, finalProgress)
+}
+
+func TestEventGroupProcessor_AddHandlersGroupReplay_nothing_to_replay(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	router, processor := newReplayTestProcessor(t, pubSub)
+
+	err := processor.AddHandlersGroupReplay(
+		"projection",
+		cqrs.EventGroupReplayConfig{},
+		cqrs.NewGroupEventHandler(func(ctx context.Context, event *TestEvent) error {
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	p, err := processor.ReplayProgress("projection")
+	require.NoError(t, err)
+	assert.True(t, p.CaughtUp)
+}
+
+func TestEventGroupProcessor_AddHandlersGroupReplay_not_supported(t *testing.T) {
+	_, processor := newReplayTestProcessor(t, &mockSubscriber{})
+
+	err := processor.AddHandlersGroupReplay(
+		"projection",
+		cqrs.EventGroupReplayConfig{},
+		cqrs.NewGroupEventHandler(func(ctx context.Context, event *TestEvent) error {
+			return nil
+		}),
+	)
+	assert.ErrorIs(t, err, message.ErrReplayNotSupported)
+
+	_, err = processor.ReplayProgress("projection")
+	assert.Error(t, err)
+}
@@ -48,6 +48,25 @@ func TestEventProcessorConfig_Validate(t *testing.T) {
 			},
 			ExpectedErr: fmt.Errorf("missing SubscriberConstructor"),
 		},
+		{
+			Name: "GenerateSubscribeTopics_instead_of_GenerateSubscribeTopic",
+			ModifyValidConfig: func(config *cqrs.EventProcessorConfig) {
+				config.GenerateSubscribeTopic = nil
+				config.GenerateSubscribeTopics = func(params cqrs.EventProcessorGenerateSubscribeTopicParams) ([]string, error) {
+					return nil, nil
+				}
+			},
+			ExpectedErr: nil,
+		},
+		{
+			Name: "both_GenerateSubscribeTopic_and_GenerateSubscribeTopics",
+			ModifyValidConfig: func(config *cqrs.EventProcessorConfig) {
+				config.GenerateSubscribeTopics = func(params cqrs.EventProcessorGenerateSubscribeTopicParams) ([]string, error) {
+					return nil, nil
+				}
+			},
+			ExpectedErr: fmt.Errorf("only one of GenerateSubscribeTopic and GenerateSubscribeTopics can be set"),
+		},
 	}
 	for i := range testCases {
 		tc := testCases[i]
@@ -537,3 +556,166 @@ func TestEventProcessor_original_msg_set_to_ctx(t *testing.T) {
 	require.NotNil(t, msgFromCtx)
 	assert.Equal(t, msg, msgFromCtx)
 }
+
+func TestEventProcessor_handler_subscriber_options(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	defaultSubscriberParams := map[string]cqrs.EventProcessorSubscriberConstructorParams{}
+	overriddenSubscriberParams := map[string]cqrs.EventProcessorSubscriberConstructorParams{}
+
+	ep, err := cqrs.NewEventProcessorWithConfig(
+		router,
+		cqrs.EventProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "events", nil
+			},
+			SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				defaultSubscriberParams[params.HandlerName] = params
+				return &mockSubscriber{}, nil
+			},
+			Marshaler: ts.Marshaler,
+			Logger:    ts.Logger,
+		},
+	)
+	require.NoError(t, err)
+
+	handleFunc := func(ctx context.Context, event *TestEvent) error {
+		return nil
+	}
+
+	err = ep.AddHandlers(
+		cqrs.NewEventHandler("default", handleFunc),
+		cqrs.NewEventHandlerWithSubscriberOptions(
+			cqrs.NewEventHandler("custom_consumer_group", handleFunc),
+			cqrs.EventHandlerSubscriberOptions{
+				ConsumerGroup: "some_group",
+			},
+		),
+		cqrs.NewEventHandlerWithSubscriberOptions(
+			cqrs.NewEventHandler("custom_subscriber", handleFunc),
+			cqrs.EventHandlerSubscriberOptions{
+				SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+					overriddenSubscriberParams[params.HandlerName] = params
+					return &mockSubscriber{}, nil
+				},
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, defaultSubscriberParams, 2)
+	assert.Equal(t, "default", defaultSubscriberParams["default"].ConsumerGroup)
+	assert.Equal(t, "some_group", defaultSubscriberParams["custom_consumer_group"].ConsumerGroup)
+
+	require.Len(t, overriddenSubscriberParams, 1)
+	assert.Equal(t, "custom_subscriber", overriddenSubscriberParams["custom_subscriber"].ConsumerGroup)
+
+	var handlerNames []string
+	for _, h := range ep.Handlers() {
+		handlerNames = append(handlerNames, h.HandlerName())
+	}
+	assert.Equal(t, []string{"default", "custom_consumer_group", "custom_subscriber"}, handlerNames)
+}
+
+func TestEventProcessor_multiple_subscribe_topics(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	var subscribedTopics []string
+
+	ep, err := cqrs.NewEventProcessorWithConfig(
+		router,
+		cqrs.EventProcessorConfig{
+			GenerateSubscribeTopics: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) ([]string, error) {
+				return []string{"service_a." + params.EventName, "service_b." + params.EventName}, nil
+			},
+			SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				assert.Equal(t, "handler", params.HandlerName)
+				subscribedTopics = append(subscribedTopics, params.Topic)
+				return ts.EventsPubSub, nil
+			},
+			Marshaler: ts.Marshaler,
+			Logger:    ts.Logger,
+		},
+	)
+	require.NoError(t, err)
+
+	receivedEvents := make(chan string, 2)
+
+	err = ep.AddHandlers(cqrs.NewEventHandler(
+		"handler", func(ctx context.Context, event *TestEvent) error {
+			receivedEvents <- event.ID
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"service_a.cqrs_test.TestEvent", "service_b.cqrs_test.TestEvent"}, subscribedTopics)
+
+	topics, err := ep.SubscribedTopics()
+	require.NoError(t, err)
+	assert.Equal(t, subscribedTopics, topics)
+
+	assert.Contains(t, router.Handlers(), "handler_service_a.cqrs_test.TestEvent")
+	assert.Contains(t, router.Handlers(), "handler_service_b.cqrs_test.TestEvent")
+
+	go func() {
+		err := router.Run(context.Background())
+		assert.NoError(t, err)
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	for _, topic := range topics {
+		msg, err := ts.Marshaler.Marshal(&TestEvent{ID: topic})
+		require.NoError(t, err)
+		require.NoError(t, ts.EventsPubSub.Publish(topic, msg))
+	}
+
+	var received []string
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-receivedEvents:
+			received = append(received, id)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+	}
+	assert.ElementsMatch(t, topics, received)
+}
+
+func TestEventProcessor_no_subscribe_topics(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	ep, err := cqrs.NewEventProcessorWithConfig(
+		router,
+		cqrs.EventProcessorConfig{
+			GenerateSubscribeTopics: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) ([]string, error) {
+				return nil, nil
+			},
+			SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return ts.EventsPubSub, nil
+			},
+			Marshaler: ts.Marshaler,
+			Logger:    ts.Logger,
+		},
+	)
+	require.NoError(t, err)
+
+	err = ep.AddHandlers(cqrs.NewEventHandler(
+		"handler", func(ctx context.Context, event *TestEvent) error {
+			return nil
+		}),
+	)
+	assert.EqualError(t, err, "no topics generated for handler handler")
+}
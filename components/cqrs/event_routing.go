@@ -0,0 +1,128 @@
+package cqrs
+
+import (
+	stdErrors "errors"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+type EventRoutingValidationConfig struct {
+	// EventBus with the published events registered in EventBusConfig.PublishedEvents.
+	// It is required.
+	EventBus *EventBus
+
+	// EventProcessors and EventGroupProcessors are the local processors that should
+	// subscribe to the published events.
+	EventProcessors      []*EventProcessor
+	EventGroupProcessors []*EventGroupProcessor
+
+	// Strict makes ValidateEventRouting return an error for unroutable events.
+	// Otherwise, unroutable events are only logged as warnings.
+	Strict bool
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *EventRoutingValidationConfig) setDefaults() {
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c EventRoutingValidationConfig) Validate() error {
+	var err error
+
+	if c.EventBus == nil {
+		err = stdErrors.Join(err, errors.New("missing EventBus"))
+	}
+	if len(c.EventProcessors) == 0 && len(c.EventGroupProcessors) == 0 {
+		err = stdErrors.Join(err, errors.New("missing EventProcessors or EventGroupProcessors"))
+	}
+
+	return err
+}
+
+// UnroutableEventError is returned by ValidateEventRouting in strict mode
+// when an event is published to a topic that no local processor subscribes to.
+type UnroutableEventError struct {
+	EventName string
+	Topic     string
+}
+
+func (e UnroutableEventError) Error() string {
+	return "event " + e.EventName + " is published to topic " + e.Topic + ", but no local processor subscribes to it"
+}
+
+// ValidateEventRouting checks if all events published with the EventBus (registered in EventBusConfig.PublishedEvents)
+// are published to topics that the provided processors subscribe to.
+// It helps to catch mismatches between the publish and subscribe topic generators at startup.
+//
+// It should be called after all handlers are added to the processors.
+//
+// Keep in mind that events handled only by other services are reported as well,
+// so it's useful only when the published events are handled by the same service.
+func ValidateEventRouting(config EventRoutingValidationConfig) error {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return errors.Wrap(err, "invalid config")
+	}
+
+	publishedTopics, err := config.EventBus.PublishedEventsTopics()
+	if err != nil {
+		return err
+	}
+
+	subscribedTopics := map[string]struct{}{}
+
+	for _, processor := range config.EventProcessors {
+		topics, err := processor.SubscribedTopics()
+		if err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			subscribedTopics[topic] = struct{}{}
+		}
+	}
+
+	for _, processor := range config.EventGroupProcessors {
+		topics, err := processor.SubscribedTopics()
+		if err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			subscribedTopics[topic] = struct{}{}
+		}
+	}
+
+	eventNames := make([]string, 0, len(publishedTopics))
+	for eventName := range publishedTopics {
+		eventNames = append(eventNames, eventName)
+	}
+	sort.Strings(eventNames)
+
+	var validationErr error
+
+	for _, eventName := range eventNames {
+		topic := publishedTopics[eventName]
+		if _, ok := subscribedTopics[topic]; ok {
+			continue
+		}
+
+		if config.Strict {
+			validationErr = stdErrors.Join(validationErr, UnroutableEventError{EventName: eventName, Topic: topic})
+			continue
+		}
+
+		config.Logger.Info("Event is published to topic that no local processor subscribes to", watermill.LogFields{
+			"event_name": eventName,
+			"topic":      topic,
+		})
+	}
+
+	return validationErr
+}
@@ -0,0 +1,121 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestValidateEventRouting(t *testing.T) {
+	ts := NewTestServices()
+
+	eventBus, err := cqrs.NewEventBusWithConfig(ts.EventsPubSub, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events." + params.EventName, nil
+		},
+		Marshaler:       ts.Marshaler,
+		PublishedEvents: []any{&TestEvent{}, &AnotherTestEvent{}},
+	})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	eventProcessor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events." + params.EventName, nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		Marshaler: ts.Marshaler,
+	})
+	require.NoError(t, err)
+
+	err = eventProcessor.AddHandlers(cqrs.NewEventHandler("test", func(ctx context.Context, event *TestEvent) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	groupProcessor, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+			// mismatch with the publish topic generator
+			return "events_group." + params.EventGroupName, nil
+		},
+		SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		Marshaler: ts.Marshaler,
+	})
+	require.NoError(t, err)
+
+	err = groupProcessor.AddHandlersGroup("cqrs_test.AnotherTestEvent", cqrs.NewGroupEventHandler(func(ctx context.Context, event *AnotherTestEvent) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	config := cqrs.EventRoutingValidationConfig{
+		EventBus:             eventBus,
+		EventProcessors:      []*cqrs.EventProcessor{eventProcessor},
+		EventGroupProcessors: []*cqrs.EventGroupProcessor{groupProcessor},
+		Strict:               true,
+	}
+
+	err = cqrs.ValidateEventRouting(config)
+	require.Error(t, err)
+
+	var unroutableErr cqrs.UnroutableEventError
+	require.ErrorAs(t, err, &unroutableErr)
+	assert.Equal(t, "cqrs_test.AnotherTestEvent", unroutableErr.EventName)
+	assert.Equal(t, "events.cqrs_test.AnotherTestEvent", unroutableErr.Topic)
+
+	t.Run("not_strict", func(t *testing.T) {
+		logger := watermill.NewCaptureLogger()
+
+		notStrictConfig := config
+		notStrictConfig.Strict = false
+		notStrictConfig.Logger = logger
+
+		require.NoError(t, cqrs.ValidateEventRouting(notStrictConfig))
+		assert.True(t, logger.Has(watermill.CapturedMessage{
+			Level: watermill.InfoLogLevel,
+			Fields: watermill.LogFields{
+				"event_name": "cqrs_test.AnotherTestEvent",
+				"topic":      "events.cqrs_test.AnotherTestEvent",
+			},
+			Msg: "Event is published to topic that no local processor subscribes to",
+		}))
+	})
+
+	t.Run("all_routed", func(t *testing.T) {
+		router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+		require.NoError(t, err)
+
+		groupProcessor, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "events." + params.EventGroupName, nil
+			},
+			SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return ts.EventsPubSub, nil
+			},
+			Marshaler: ts.Marshaler,
+		})
+		require.NoError(t, err)
+
+		err = groupProcessor.AddHandlersGroup("cqrs_test.AnotherTestEvent", cqrs.NewGroupEventHandler(func(ctx context.Context, event *AnotherTestEvent) error {
+			return nil
+		}))
+		require.NoError(t, err)
+
+		routedConfig := config
+		routedConfig.EventGroupProcessors = []*cqrs.EventGroupProcessor{groupProcessor}
+
+		assert.NoError(t, cqrs.ValidateEventRouting(routedConfig))
+	})
+}
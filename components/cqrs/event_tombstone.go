@@ -0,0 +1,84 @@
+package cqrs
+
+import (
+	"context"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// TombstoneKeyMetadataKey is the metadata key of the key deleted by the tombstone.
+//
+// Transports delivering native tombstones (like Kafka's messages with a null value on compacted topics)
+// should put the message key into this metadata key (usually in their unmarshaler),
+// so the handlers know which entity to clean up.
+const TombstoneKeyMetadataKey = "tombstone_key"
+
+// Tombstone is a message without payload, marking that all values of the key were deleted.
+// It's used with compacted topics, where the tombstone eventually replaces all messages with the same key.
+type Tombstone struct {
+	// Key is the deleted key, read from TombstoneKeyMetadataKey.
+	Key string
+
+	// EventName is the name of the event, if the tombstone has it in the metadata.
+	// It's empty for tombstones without metadata.
+	EventName string
+
+	Message *message.Message
+}
+
+// TombstoneEventHandler is an optional interface of EventHandler.
+// EventProcessor calls HandleTombstone for tombstones instead of unmarshaling them to the event.
+//
+// Use NewEventHandlerWithTombstones to create it from functions.
+type TombstoneEventHandler interface {
+	HandleTombstone(ctx context.Context, tombstone Tombstone) error
+}
+
+// IsTombstone returns true if the message has no payload.
+// It's the default EventProcessorConfig.IsTombstone.
+//
+// Note: empty events marshaled with protobuf have no payload too.
+// Provide a custom EventProcessorConfig.IsTombstone if such events are published.
+func IsTombstone(msg *message.Message) bool {
+	return len(msg.Payload) == 0
+}
+
+// NewTombstoneMessage creates a tombstone of the key, to be published to a compacted topic.
+func NewTombstoneMessage(key string) *message.Message {
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	msg.Metadata.Set(TombstoneKeyMetadataKey, key)
+
+	return msg
+}
+
+type genericEventHandlerWithTombstones[T any] struct {
+	EventHandler
+	handleTombstoneFunc func(ctx context.Context, tombstone Tombstone) error
+}
+
+// NewEventHandlerWithTombstones creates a new EventHandler implementation based on provided functions,
+// which handles tombstones with handleTombstoneFunc.
+func NewEventHandlerWithTombstones[T any](
+	handlerName string,
+	handleFunc func(ctx context.Context, event *T) error,
+	handleTombstoneFunc func(ctx context.Context, tombstone Tombstone) error,
+) EventHandler {
+	return genericEventHandlerWithTombstones[T]{
+		EventHandler:        NewEventHandler(handlerName, handleFunc),
+		handleTombstoneFunc: handleTombstoneFunc,
+	}
+}
+
+func (h genericEventHandlerWithTombstones[T]) HandleTombstone(ctx context.Context, tombstone Tombstone) error {
+	return h.handleTombstoneFunc(ctx, tombstone)
+}
+
+func tombstoneEventHandler(handler EventHandler) (TombstoneEventHandler, bool) {
+	if withOptions, ok := handler.(eventHandlerWithSubscriberOptions); ok {
+		handler = withOptions.EventHandler
+	}
+
+	tombstoneHandler, ok := handler.(TombstoneEventHandler)
+	return tombstoneHandler, ok
+}
@@ -0,0 +1,223 @@
+package cqrs_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEventProcessor_tombstone(t *testing.T) {
+	var tombstones []cqrs.Tombstone
+	var events []*TestEvent
+	lock := sync.Mutex{}
+
+	handler := cqrs.NewEventHandlerWithTombstones(
+		"test",
+		func(ctx context.Context, event *TestEvent) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			events = append(events, event)
+			return nil
+		},
+		func(ctx context.Context, tombstone cqrs.Tombstone) error {
+			assert.Equal(t, tombstone.Message, cqrs.OriginalMessageFromCtx(ctx))
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			tombstones = append(tombstones, tombstone)
+			return nil
+		},
+	)
+
+	eventMsg, err := cqrs.JSONMarshaler{}.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+	tombstoneMsg := cqrs.NewTombstoneMessage("1")
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{}, handler, eventMsg, tombstoneMsg)
+
+	requireAcked(t, eventMsg)
+	requireAcked(t, tombstoneMsg)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "1", events[0].ID)
+
+	require.Len(t, tombstones, 1)
+	assert.Equal(t, "1", tombstones[0].Key)
+	assert.Empty(t, tombstones[0].EventName)
+	assert.Equal(t, tombstoneMsg.UUID, tombstones[0].Message.UUID)
+}
+
+func TestEventProcessor_tombstone_of_other_event(t *testing.T) {
+	handler := cqrs.NewEventHandlerWithTombstones(
+		"test",
+		func(ctx context.Context, event *TestEvent) error {
+			return nil
+		},
+		func(ctx context.Context, tombstone cqrs.Tombstone) error {
+			t.Fatal("tombstone of other event should not be handled")
+			return nil
+		},
+	)
+
+	tombstoneMsg := cqrs.NewTombstoneMessage("1")
+	tombstoneMsg.Metadata.Set("name", "cqrs_test.UnknownEvent")
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{AckOnUnknownEvent: true}, handler, tombstoneMsg)
+
+	requireAcked(t, tombstoneMsg)
+}
+
+func TestEventProcessor_AckOnTombstone(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		AckOnTombstone bool
+		ExpectedAck    bool
+	}{
+		{
+			Name:           "enabled",
+			AckOnTombstone: true,
+			ExpectedAck:    true,
+		},
+		{
+			Name:           "disabled",
+			AckOnTombstone: false,
+			ExpectedAck:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			handler := cqrs.NewEventHandler("test", func(ctx context.Context, event *TestEvent) error {
+				t.Fatal("tombstone should not be handled as event")
+				return nil
+			})
+
+			tombstoneMsg := cqrs.NewTombstoneMessage("1")
+
+			runEventProcessor(t, cqrs.EventProcessorConfig{AckOnTombstone: tc.AckOnTombstone}, handler, tombstoneMsg)
+
+			if tc.ExpectedAck {
+				requireAcked(t, tombstoneMsg)
+			} else {
+				requireNacked(t, tombstoneMsg)
+			}
+		})
+	}
+}
+
+func TestEventProcessor_custom_IsTombstone(t *testing.T) {
+	var tombstones []cqrs.Tombstone
+
+	handler := cqrs.NewEventHandlerWithTombstones(
+		"test",
+		func(ctx context.Context, event *TestEvent) error {
+			return nil
+		},
+		func(ctx context.Context, tombstone cqrs.Tombstone) error {
+			tombstones = append(tombstones, tombstone)
+			return nil
+		},
+	)
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte("null"))
+	msg.Metadata.Set(cqrs.TombstoneKeyMetadataKey, "1")
+
+	runEventProcessor(t, cqrs.EventProcessorConfig{
+		IsTombstone: func(msg *message.Message) bool {
+			return string(msg.Payload) == "null"
+		},
+	}, handler, msg)
+
+	requireAcked(t, msg)
+	require.Len(t, tombstones, 1)
+	assert.Equal(t, "1", tombstones[0].Key)
+}
+
+func TestIsTombstone(t *testing.T) {
+	assert.True(t, cqrs.IsTombstone(message.NewMessage(watermill.NewUUID(), nil)))
+	assert.True(t, cqrs.IsTombstone(message.NewMessage(watermill.NewUUID(), []byte{})))
+	assert.False(t, cqrs.IsTombstone(message.NewMessage(watermill.NewUUID(), []byte("{}"))))
+}
+
+// runEventProcessor runs the router with the handler, which receives the messages in the order.
+// Missing required fields of the config are set to the defaults of the test.
+func runEventProcessor(t *testing.T, config cqrs.EventProcessorConfig, handler cqrs.EventHandler, messages ...*message.Message) {
+	t.Helper()
+
+	mockSub := &mockSubscriber{
+		MessagesToSend: messages,
+	}
+
+	if config.GenerateSubscribeTopic == nil {
+		config.GenerateSubscribeTopic = func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		}
+	}
+	if config.SubscriberConstructor == nil {
+		config.SubscriberConstructor = func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return mockSub, nil
+		}
+	}
+	if config.Marshaler == nil {
+		config.Marshaler = cqrs.JSONMarshaler{}
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	ep, err := cqrs.NewEventProcessorWithConfig(router, config)
+	require.NoError(t, err)
+
+	err = ep.AddHandlers(handler)
+	require.NoError(t, err)
+
+	go func() {
+		err := router.Run(context.Background())
+		assert.NoError(t, err)
+	}()
+	t.Cleanup(func() {
+		_ = router.Close()
+	})
+
+	<-router.Running()
+}
+
+func requireAcked(t *testing.T, msg *message.Message) {
+	t.Helper()
+
+	select {
+	case <-msg.Acked():
+		// ok
+	case <-msg.Nacked():
+		t.Fatal("message should be acked")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for ack")
+	}
+}
+
+func requireNacked(t *testing.T, msg *message.Message) {
+	t.Helper()
+
+	select {
+	case <-msg.Nacked():
+		// ok
+	case <-msg.Acked():
+		t.Fatal("message should be nacked")
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for nack")
+	}
+}
@@ -0,0 +1,138 @@
+package cqrs
+
+import (
+	"github.com/pkg/errors"
+)
+
+// EventTopicStrategy decides to which topic an event is published.
+//
+// The same strategy should be used on the publisher side (EventBusConfig.GeneratePublishTopic)
+// and on the subscriber side (EventProcessorConfig.GenerateSubscribeTopic,
+// EventGroupProcessorConfig.GenerateSubscribeTopic), so they can't get out of sync.
+// Use PublishTopicFn, SubscribeTopicFn, and GroupSubscribeTopicFn to adapt it to the configs.
+type EventTopicStrategy interface {
+	EventTopic(eventName string, event any) (string, error)
+}
+
+// EventTopicStrategyFunc is a function implementing EventTopicStrategy.
+type EventTopicStrategyFunc func(eventName string, event any) (string, error)
+
+func (f EventTopicStrategyFunc) EventTopic(eventName string, event any) (string, error) {
+	return f(eventName, event)
+}
+
+// TopicPerEventType publishes every event type to its own topic, named "<Prefix><event name>".
+type TopicPerEventType struct {
+	Prefix string
+}
+
+func (s TopicPerEventType) EventTopic(eventName string, event any) (string, error) {
+	if eventName == "" {
+		return "", errors.New("empty event name")
+	}
+
+	return s.Prefix + eventName, nil
+}
+
+// FirehoseTopic publishes all events to a single topic.
+// Handlers receive all events and skip the ones they don't handle.
+type FirehoseTopic struct {
+	Topic string
+}
+
+func (s FirehoseTopic) EventTopic(eventName string, event any) (string, error) {
+	if s.Topic == "" {
+		return "", errors.New("missing Topic")
+	}
+
+	return s.Topic, nil
+}
+
+// AggregateEvent is implemented by events that belong to an aggregate (or a stream), to be used with TopicPerAggregate.
+type AggregateEvent interface {
+	// AggregateType returns the type of the aggregate, for example "order".
+	// It must not depend on the values of the event's fields, as it's called on empty events on the subscriber side.
+	AggregateType() string
+}
+
+// TopicPerAggregate publishes the events of every aggregate type to its own topic, named "<Prefix><aggregate type>",
+// so the events of the aggregate are kept on one topic.
+type TopicPerAggregate struct {
+	Prefix string
+
+	// AggregateType returns the aggregate type of the event.
+	// If not provided, events must implement AggregateEvent.
+	AggregateType func(eventName string, event any) (string, error)
+}
+
+func (s TopicPerAggregate) EventTopic(eventName string, event any) (string, error) {
+	aggregateType, err := s.aggregateType(eventName, event)
+	if err != nil {
+		return "", err
+	}
+	if aggregateType == "" {
+		return "", errors.Errorf("empty aggregate type of event %s", eventName)
+	}
+
+	return s.Prefix + aggregateType, nil
+}
+
+func (s TopicPerAggregate) aggregateType(eventName string, event any) (string, error) {
+	if s.AggregateType != nil {
+		return s.AggregateType(eventName, event)
+	}
+
+	aggregateEvent, ok := event.(AggregateEvent)
+	if !ok {
+		return "", errors.Errorf("event %s (%T) doesn't implement AggregateEvent", eventName, event)
+	}
+
+	return aggregateEvent.AggregateType(), nil
+}
+
+// PublishTopicFn adapts the strategy to EventBusConfig.GeneratePublishTopic.
+func PublishTopicFn(strategy EventTopicStrategy) GenerateEventPublishTopicFn {
+	return func(params GenerateEventPublishTopicParams) (string, error) {
+		return strategy.EventTopic(params.EventName, params.Event)
+	}
+}
+
+// SubscribeTopicFn adapts the strategy to EventProcessorConfig.GenerateSubscribeTopic.
+func SubscribeTopicFn(strategy EventTopicStrategy) EventProcessorGenerateSubscribeTopicFn {
+	return func(params EventProcessorGenerateSubscribeTopicParams) (string, error) {
+		return strategy.EventTopic(params.EventName, params.EventHandler.NewEvent())
+	}
+}
+
+// GroupSubscribeTopicFn adapts the strategy to EventGroupProcessorConfig.GenerateSubscribeTopic.
+//
+// The events of all handlers of the group must be published to the same topic.
+// Otherwise, an error is returned, as the group would miss some events.
+// marshaler is used to get the names of the events and should be the marshaler of the processor.
+func GroupSubscribeTopicFn(strategy EventTopicStrategy, marshaler CommandEventMarshaler) EventGroupProcessorGenerateSubscribeTopicFn {
+	return func(params EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+		if len(params.EventGroupHandlers) == 0 {
+			return "", errors.Errorf("event group %s has no handlers", params.EventGroupName)
+		}
+
+		var topic string
+		for _, handler := range params.EventGroupHandlers {
+			event := handler.NewEvent()
+
+			handlerTopic, err := strategy.EventTopic(marshaler.Name(event), event)
+			if err != nil {
+				return "", err
+			}
+
+			if topic != "" && handlerTopic != topic {
+				return "", errors.Errorf(
+					"events of group %s are published to different topics: %s and %s",
+					params.EventGroupName, topic, handlerTopic,
+				)
+			}
+			topic = handlerTopic
+		}
+
+		return topic, nil
+	}
+}
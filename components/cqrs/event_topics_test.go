@@ -0,0 +1,138 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type InvoiceIssued struct {
+	InvoiceID string
+}
+
+func (InvoiceIssued) AggregateType() string {
+	return "invoice"
+}
+
+type InvoicePaid struct {
+	InvoiceID string
+}
+
+func (InvoicePaid) AggregateType() string {
+	return "invoice"
+}
+
+func TestTopicPerEventType(t *testing.T) {
+	topic, err := cqrs.TopicPerEventType{Prefix: "events."}.EventTopic("InvoiceIssued", InvoiceIssued{})
+	require.NoError(t, err)
+	assert.Equal(t, "events.InvoiceIssued", topic)
+
+	_, err = cqrs.TopicPerEventType{}.EventTopic("", InvoiceIssued{})
+	assert.Error(t, err)
+}
+
+func TestFirehoseTopic(t *testing.T) {
+	topic, err := cqrs.FirehoseTopic{Topic: "events"}.EventTopic("InvoiceIssued", InvoiceIssued{})
+	require.NoError(t, err)
+	assert.Equal(t, "events", topic)
+
+	_, err = cqrs.FirehoseTopic{}.EventTopic("InvoiceIssued", InvoiceIssued{})
+	assert.EqualError(t, err, "missing Topic")
+}
+
+func TestTopicPerAggregate(t *testing.T) {
+	strategy := cqrs.TopicPerAggregate{Prefix: "aggregate."}
+
+	topic, err := strategy.EventTopic("InvoiceIssued", &InvoiceIssued{})
+	require.NoError(t, err)
+	assert.Equal(t, "aggregate.invoice", topic)
+
+	_, err = strategy.EventTopic("TestEvent", &TestEvent{})
+	assert.EqualError(t, err, "event TestEvent (*cqrs_test.TestEvent) doesn't implement AggregateEvent")
+
+	strategy.AggregateType = func(eventName string, event any) (string, error) {
+		return "test", nil
+	}
+	topic, err = strategy.EventTopic("TestEvent", &TestEvent{})
+	require.NoError(t, err)
+	assert.Equal(t, "aggregate.test", topic)
+}
+
+func TestEventTopicStrategy_publisher_and_subscribers_in_sync(t *testing.T) {
+	ts := NewTestServices()
+	strategy := cqrs.TopicPerAggregate{Prefix: "aggregate."}
+
+	eventBus, err := cqrs.NewEventBusWithConfig(ts.EventsPubSub, cqrs.EventBusConfig{
+		GeneratePublishTopic: cqrs.PublishTopicFn(strategy),
+		Marshaler:            ts.Marshaler,
+		PublishedEvents:      []any{&InvoiceIssued{}, &InvoicePaid{}},
+	})
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	eventProcessor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: cqrs.SubscribeTopicFn(strategy),
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		Marshaler: ts.Marshaler,
+	})
+	require.NoError(t, err)
+
+	err = eventProcessor.AddHandlers(cqrs.NewEventHandler("on_invoice_issued", func(ctx context.Context, event *InvoiceIssued) error {
+		return nil
+	}))
+	require.NoError(t, err)
+
+	groupProcessor, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+		GenerateSubscribeTopic: cqrs.GroupSubscribeTopicFn(strategy, ts.Marshaler),
+		SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		Marshaler: ts.Marshaler,
+	})
+	require.NoError(t, err)
+
+	err = groupProcessor.AddHandlersGroup(
+		"invoices",
+		cqrs.NewGroupEventHandler(func(ctx context.Context, event *InvoiceIssued) error {
+			return nil
+		}),
+		cqrs.NewGroupEventHandler(func(ctx context.Context, event *InvoicePaid) error {
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	err = cqrs.ValidateEventRouting(cqrs.EventRoutingValidationConfig{
+		EventBus:             eventBus,
+		EventProcessors:      []*cqrs.EventProcessor{eventProcessor},
+		EventGroupProcessors: []*cqrs.EventGroupProcessor{groupProcessor},
+		Strict:               true,
+	})
+	assert.NoError(t, err)
+}
+
+func TestGroupSubscribeTopicFn_events_on_different_topics(t *testing.T) {
+	generateTopic := cqrs.GroupSubscribeTopicFn(cqrs.TopicPerEventType{}, cqrs.JSONMarshaler{})
+
+	_, err := generateTopic(cqrs.EventGroupProcessorGenerateSubscribeTopicParams{
+		EventGroupName: "group",
+		EventGroupHandlers: []cqrs.GroupEventHandler{
+			cqrs.NewGroupEventHandler(func(ctx context.Context, event *InvoiceIssued) error {
+				return nil
+			}),
+			cqrs.NewGroupEventHandler(func(ctx context.Context, event *InvoicePaid) error {
+				return nil
+			}),
+		},
+	})
+	assert.EqualError(t, err, "events of group group are published to different topics: cqrs_test.InvoiceIssued and cqrs_test.InvoicePaid")
+}
@@ -0,0 +1,229 @@
+package cqrs
+
+import (
+	"context"
+	stdErrors "errors"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// EventVisibility tells if the event is published to the internal topics of the service,
+// to the public topics of integration events, or to both.
+type EventVisibility int
+
+const (
+	// EventVisibilityInternal events are published only to the internal topics (EventBusConfig.GeneratePublishTopic).
+	// Events not registered in IntegrationEventsConfig.Events are internal.
+	EventVisibilityInternal EventVisibility = iota
+
+	// EventVisibilityIntegration events are published only to the integration topics
+	// (IntegrationEventsConfig.GeneratePublishTopic).
+	EventVisibilityIntegration
+
+	// EventVisibilityInternalAndIntegration events are published both to the internal and the integration topics.
+	EventVisibilityInternalAndIntegration
+)
+
+func (v EventVisibility) String() string {
+	switch v {
+	case EventVisibilityInternal:
+		return "internal"
+	case EventVisibilityIntegration:
+		return "integration"
+	case EventVisibilityInternalAndIntegration:
+		return "internal_and_integration"
+	default:
+		return "unknown"
+	}
+}
+
+func (v EventVisibility) internal() bool {
+	return v == EventVisibilityInternal || v == EventVisibilityInternalAndIntegration
+}
+
+func (v EventVisibility) integration() bool {
+	return v == EventVisibilityIntegration || v == EventVisibilityInternalAndIntegration
+}
+
+// EventRegistration registers the visibility of the event.
+type EventRegistration struct {
+	// Event is an instance of the event, used to get its name.
+	Event any
+
+	Visibility EventVisibility
+}
+
+// VersionedEvent can be implemented by integration events to provide their schema version.
+type VersionedEvent interface {
+	EventVersion() string
+}
+
+// DefaultEventVersionMetadataKey is the default IntegrationEventsConfig.VersionMetadataKey.
+const DefaultEventVersionMetadataKey = "event_version"
+
+// IntegrationEventsConfig configures publishing the integration events with EventBus.
+//
+// Integration events are the public contract of the service, consumed by other services,
+// while the internal (domain) events are free to change together with the service.
+// That's why integration events are published to separate topics, with a separate (usually stricter, schema-based)
+// marshaler, and are required to have a version.
+type IntegrationEventsConfig struct {
+	// Events are the events published to the integration topics. Other events are internal.
+	// It is required.
+	Events []EventRegistration
+
+	// GeneratePublishTopic is used to generate the topic of integration events.
+	// It is required.
+	GeneratePublishTopic GenerateEventPublishTopicFn
+
+	// Marshaler is used to marshal integration events.
+	// It is required.
+	Marshaler CommandEventMarshaler
+
+	// Publisher is used to publish integration events.
+	// If not provided, the EventBus's publisher is used.
+	Publisher message.Publisher
+
+	// Version returns the version of the integration event.
+	// If not provided, events must implement VersionedEvent.
+	// Publishing an integration event without a version fails.
+	Version func(event any) (string, error)
+
+	// VersionMetadataKey is the metadata key of the event's version.
+	// If empty, DefaultEventVersionMetadataKey is used.
+	VersionMetadataKey string
+
+	// OnPublish is called before publishing the integration event, like EventBusConfig.OnPublish.
+	// EventBusConfig.OnPublish is not called for integration events.
+	//
+	// This option is not required.
+	OnPublish OnEventSendFn
+}
+
+func (c *IntegrationEventsConfig) setDefaults() {
+	if c.VersionMetadataKey == "" {
+		c.VersionMetadataKey = DefaultEventVersionMetadataKey
+	}
+}
+
+func (c IntegrationEventsConfig) Validate() error {
+	var err error
+
+	if len(c.Events) == 0 {
+		err = stdErrors.Join(err, errors.New("missing Events"))
+	}
+	if c.GeneratePublishTopic == nil {
+		err = stdErrors.Join(err, errors.New("missing GeneratePublishTopic"))
+	}
+	if c.Marshaler == nil {
+		err = stdErrors.Join(err, errors.New("missing Marshaler"))
+	}
+	for _, registration := range c.Events {
+		if registration.Event == nil {
+			err = stdErrors.Join(err, errors.New("event in Events is nil"))
+		}
+		if registration.Visibility < EventVisibilityInternal || registration.Visibility > EventVisibilityInternalAndIntegration {
+			err = stdErrors.Join(err, errors.Errorf("invalid visibility %d", registration.Visibility))
+		}
+	}
+
+	return err
+}
+
+func (c IntegrationEventsConfig) version(event any) (string, error) {
+	var version string
+	if c.Version != nil {
+		var err error
+		version, err = c.Version(event)
+		if err != nil {
+			return "", errors.Wrap(err, "cannot get event version")
+		}
+	} else if versioned, ok := event.(VersionedEvent); ok {
+		version = versioned.EventVersion()
+	}
+
+	if version == "" {
+		return "", errors.New("integration event has no version")
+	}
+
+	return version, nil
+}
+
+// EventVisibility returns the visibility of the event.
+func (c EventBus) EventVisibility(event any) EventVisibility {
+	return c.visibility[c.config.Marshaler.Name(event)]
+}
+
+func (c EventBus) publishIntegrationEvent(ctx context.Context, event any, overrides eventPublishOverrides) error {
+	integration := c.config.Integration
+
+	version, err := integration.version(event)
+	if err != nil {
+		return err
+	}
+
+	msg, err := integration.Marshaler.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal integration event")
+	}
+
+	eventName := integration.Marshaler.Name(event)
+	topicName, err := integration.GeneratePublishTopic(GenerateEventPublishTopicParams{
+		EventName: eventName,
+		Event:     event,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot generate integration event topic")
+	}
+
+	if err := c.setMessageUUID(msg, eventName, event); err != nil {
+		return err
+	}
+	if err := c.setPartitionKey(msg, eventName, event); err != nil {
+		return err
+	}
+
+	msg.SetContext(ctx)
+	msg.Metadata.Set(integration.VersionMetadataKey, version)
+
+	if c.config.StandardMetadata != nil {
+		c.config.StandardMetadata.apply(msg, eventName)
+	}
+	if c.config.TTL > 0 {
+		applyTTL(msg, c.config.TTL)
+	}
+	for k, v := range overrides.metadata {
+		msg.Metadata.Set(k, v)
+	}
+
+	if integration.OnPublish != nil {
+		err := integration.OnPublish(OnEventSendParams{
+			EventName:   eventName,
+			Event:       event,
+			Message:     msg,
+			PayloadSize: len(msg.Payload),
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot execute integration OnPublish")
+		}
+	}
+
+	if err := resealPayload(integration.Marshaler, msg); err != nil {
+		return err
+	}
+
+	if c.config.MessageSize != nil && c.config.DryRunSink == nil {
+		if err := c.config.MessageSize.apply(msg, eventName); err != nil {
+			return err
+		}
+	}
+
+	publisher := integration.Publisher
+	if publisher == nil {
+		publisher = c.publisher
+	}
+
+	return publishWithMode(publisher, topicName, msg, c.config.DryRunSink, nil, c.config.Logger)
+}
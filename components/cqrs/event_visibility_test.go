@@ -0,0 +1,125 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type topicCapturingPublisher struct {
+	topics   []string
+	messages []*message.Message
+}
+
+func (p *topicCapturingPublisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		p.topics = append(p.topics, topic)
+		p.messages = append(p.messages, msg)
+	}
+	return nil
+}
+
+func (p *topicCapturingPublisher) Close() error {
+	return nil
+}
+
+type OrderPlaced struct {
+	ID string `json:"id"`
+}
+
+func (OrderPlaced) EventVersion() string {
+	return "v2"
+}
+
+type OrderShipped struct {
+	ID string `json:"id"`
+}
+
+type OrderRecalculated struct {
+	ID string `json:"id"`
+}
+
+func newEventBusWithIntegration(t *testing.T, internal, integration message.Publisher, version func(any) (string, error)) *cqrs.EventBus {
+	t.Helper()
+
+	eventBus, err := cqrs.NewEventBusWithConfig(internal, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "internal." + params.EventName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		Integration: &cqrs.IntegrationEventsConfig{
+			Events: []cqrs.EventRegistration{
+				{Event: OrderPlaced{}, Visibility: cqrs.EventVisibilityInternalAndIntegration},
+				{Event: OrderShipped{}, Visibility: cqrs.EventVisibilityIntegration},
+			},
+			GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+				return "public." + params.EventName, nil
+			},
+			Marshaler: cqrs.JSONMarshaler{
+				GenerateName: cqrs.StructName,
+			},
+			Publisher: integration,
+			Version:   version,
+		},
+	})
+	require.NoError(t, err)
+
+	return eventBus
+}
+
+func TestEventBus_integration_events(t *testing.T) {
+	internal := &topicCapturingPublisher{}
+	integration := &topicCapturingPublisher{}
+
+	eventBus := newEventBusWithIntegration(t, internal, integration, nil)
+
+	assert.Equal(t, cqrs.EventVisibilityInternalAndIntegration, eventBus.EventVisibility(OrderPlaced{}))
+	assert.Equal(t, cqrs.EventVisibilityIntegration, eventBus.EventVisibility(&OrderShipped{}))
+	assert.Equal(t, cqrs.EventVisibilityInternal, eventBus.EventVisibility(OrderRecalculated{}))
+
+	require.NoError(t, eventBus.Publish(context.Background(), OrderPlaced{ID: "1"}))
+	require.NoError(t, eventBus.Publish(context.Background(), OrderRecalculated{ID: "1"}))
+
+	assert.Equal(t, []string{"internal.cqrs_test.OrderPlaced", "internal.cqrs_test.OrderRecalculated"}, internal.topics)
+	assert.Equal(t, []string{"public.OrderPlaced"}, integration.topics)
+	assert.Equal(t, "v2", integration.messages[0].Metadata.Get(cqrs.DefaultEventVersionMetadataKey))
+	assert.Empty(t, internal.messages[0].Metadata.Get(cqrs.DefaultEventVersionMetadataKey))
+
+	err := eventBus.Publish(context.Background(), OrderShipped{ID: "1"})
+	assert.ErrorContains(t, err, "integration event has no version")
+	assert.Len(t, internal.messages, 2, "integration-only event should not be published to internal topics")
+}
+
+func TestEventBus_integration_events_version_func(t *testing.T) {
+	internal := &topicCapturingPublisher{}
+	integration := &topicCapturingPublisher{}
+
+	eventBus := newEventBusWithIntegration(t, internal, integration, func(event any) (string, error) {
+		return "v1", nil
+	})
+
+	require.NoError(t, eventBus.Publish(context.Background(), OrderShipped{ID: "1"}))
+
+	assert.Empty(t, internal.messages)
+	require.Len(t, integration.messages, 1)
+	assert.Equal(t, "public.OrderShipped", integration.topics[0])
+	assert.Equal(t, "v1", integration.messages[0].Metadata.Get(cqrs.DefaultEventVersionMetadataKey))
+}
+
+func TestEventBusConfig_invalid_integration(t *testing.T) {
+	_, err := cqrs.NewEventBusWithConfig(&topicCapturingPublisher{}, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return params.EventName, nil
+		},
+		Marshaler:   cqrs.JSONMarshaler{},
+		Integration: &cqrs.IntegrationEventsConfig{},
+	})
+	assert.ErrorContains(t, err, "invalid Integration config")
+	assert.ErrorContains(t, err, "missing GeneratePublishTopic")
+	assert.ErrorContains(t, err, "missing Events")
+}
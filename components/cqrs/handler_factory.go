@@ -0,0 +1,161 @@
+package cqrs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// HandlerScope defines how long a handler constructed by HandlerFactory is used.
+type HandlerScope int
+
+const (
+	// HandlerScopeMessage constructs a new handler for every handled message.
+	// It's useful for dependencies scoped to a single message, like a database transaction
+	// or a client of the message's tenant.
+	HandlerScopeMessage HandlerScope = iota
+
+	// HandlerScopeProcessor constructs the handler once, when the first message is handled,
+	// and uses it for all following messages. If constructing fails, it's retried with the next message.
+	HandlerScopeProcessor
+)
+
+// HandlerFactory constructs handlers with their dependencies lazily, instead of requiring them
+// to be constructed when the handler is added to the processor.
+// It can be used to integrate a dependency injection container.
+type HandlerFactory[Handler any] interface {
+	// NewHandler constructs the handler.
+	// For HandlerScopeMessage, ctx is the context of the handled message
+	// (so values like the original message or the tenant can be read from it).
+	//
+	// release, if not nil, is called when the handler is no longer used:
+	// after the message is handled for HandlerScopeMessage. Handlers with HandlerScopeProcessor are never released.
+	NewHandler(ctx context.Context) (handler Handler, release func(), err error)
+}
+
+// HandlerFactoryFunc is a function implementing HandlerFactory.
+type HandlerFactoryFunc[Handler any] func(ctx context.Context) (handler Handler, release func(), err error)
+
+func (f HandlerFactoryFunc[Handler]) NewHandler(ctx context.Context) (Handler, func(), error) {
+	return f(ctx)
+}
+
+// factoryHandler handles messages with handlers constructed by the factory, according to the scope.
+type factoryHandler[Handler any] struct {
+	handlerName string
+	scope       HandlerScope
+	factory     HandlerFactory[Handler]
+
+	handler      Handler
+	handlerBuilt bool
+	lock         sync.Mutex
+}
+
+func (f *factoryHandler[Handler]) withHandler(ctx context.Context, handle func(handler Handler) error) error {
+	if f.scope == HandlerScopeProcessor {
+		handler, err := f.processorHandler(ctx)
+		if err != nil {
+			return err
+		}
+
+		return handle(handler)
+	}
+
+	handler, release, err := f.factory.NewHandler(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "cannot construct handler %s", f.handlerName)
+	}
+	if release != nil {
+		defer release()
+	}
+
+	return handle(handler)
+}
+
+func (f *factoryHandler[Handler]) processorHandler(ctx context.Context) (Handler, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.handlerBuilt {
+		return f.handler, nil
+	}
+
+	handler, _, err := f.factory.NewHandler(ctx)
+	if err != nil {
+		return handler, errors.Wrapf(err, "cannot construct handler %s", f.handlerName)
+	}
+
+	f.handler = handler
+	f.handlerBuilt = true
+
+	return handler, nil
+}
+
+type genericFactoryCommandHandler[Command any] struct {
+	*factoryHandler[func(ctx context.Context, cmd *Command) error]
+}
+
+// NewFactoryCommandHandler creates a new CommandHandler, which handles commands with the handler functions
+// constructed by the factory, according to the scope.
+func NewFactoryCommandHandler[Command any](
+	handlerName string,
+	scope HandlerScope,
+	factory HandlerFactory[func(ctx context.Context, cmd *Command) error],
+) CommandHandler {
+	return genericFactoryCommandHandler[Command]{
+		&factoryHandler[func(ctx context.Context, cmd *Command) error]{
+			handlerName: handlerName,
+			scope:       scope,
+			factory:     factory,
+		},
+	}
+}
+
+func (c genericFactoryCommandHandler[Command]) HandlerName() string {
+	return c.handlerName
+}
+
+func (c genericFactoryCommandHandler[Command]) NewCommand() any {
+	return new(Command)
+}
+
+func (c genericFactoryCommandHandler[Command]) Handle(ctx context.Context, cmd any) error {
+	return c.withHandler(ctx, func(handle func(ctx context.Context, cmd *Command) error) error {
+		return handle(ctx, cmd.(*Command))
+	})
+}
+
+type genericFactoryEventHandler[Event any] struct {
+	*factoryHandler[func(ctx context.Context, event *Event) error]
+}
+
+// NewFactoryEventHandler creates a new EventHandler, which handles events with the handler functions
+// constructed by the factory, according to the scope.
+func NewFactoryEventHandler[Event any](
+	handlerName string,
+	scope HandlerScope,
+	factory HandlerFactory[func(ctx context.Context, event *Event) error],
+) EventHandler {
+	return genericFactoryEventHandler[Event]{
+		&factoryHandler[func(ctx context.Context, event *Event) error]{
+			handlerName: handlerName,
+			scope:       scope,
+			factory:     factory,
+		},
+	}
+}
+
+func (c genericFactoryEventHandler[Event]) HandlerName() string {
+	return c.handlerName
+}
+
+func (c genericFactoryEventHandler[Event]) NewEvent() any {
+	return new(Event)
+}
+
+func (c genericFactoryEventHandler[Event]) Handle(ctx context.Context, event any) error {
+	return c.withHandler(ctx, func(handle func(ctx context.Context, event *Event) error) error {
+		return handle(ctx, event.(*Event))
+	})
+}
@@ -0,0 +1,80 @@
+package cqrs_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type tenantCtxKey struct{}
+
+func TestNewFactoryCommandHandler_message_scope(t *testing.T) {
+	var constructed, released atomic.Int32
+
+	factory := cqrs.HandlerFactoryFunc[func(ctx context.Context, cmd *SomeCommand) error](
+		func(ctx context.Context) (func(ctx context.Context, cmd *SomeCommand) error, func(), error) {
+			constructed.Add(1)
+			tenant := ctx.Value(tenantCtxKey{}).(string)
+
+			return func(ctx context.Context, cmd *SomeCommand) error {
+				assert.Equal(t, "bar", cmd.Foo)
+				assert.Equal(t, "acme", tenant)
+				return nil
+			}, func() { released.Add(1) }, nil
+		},
+	)
+
+	h := cqrs.NewFactoryCommandHandler("handler", cqrs.HandlerScopeMessage, factory)
+	assert.Equal(t, "handler", h.HandlerName())
+	assert.Equal(t, &SomeCommand{}, h.NewCommand())
+
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "acme")
+
+	require.NoError(t, h.Handle(ctx, &SomeCommand{Foo: "bar"}))
+	require.NoError(t, h.Handle(ctx, &SomeCommand{Foo: "bar"}))
+
+	assert.Equal(t, int32(2), constructed.Load())
+	assert.Equal(t, int32(2), released.Load())
+}
+
+func TestNewFactoryEventHandler_processor_scope(t *testing.T) {
+	var constructed atomic.Int32
+	var handled atomic.Int32
+	failConstructing := true
+
+	factory := cqrs.HandlerFactoryFunc[func(ctx context.Context, event *SomeEvent) error](
+		func(ctx context.Context) (func(ctx context.Context, event *SomeEvent) error, func(), error) {
+			if failConstructing {
+				return nil, nil, errors.New("database not ready")
+			}
+			constructed.Add(1)
+
+			return func(ctx context.Context, event *SomeEvent) error {
+				handled.Add(1)
+				return nil
+			}, nil, nil
+		},
+	)
+
+	h := cqrs.NewFactoryEventHandler("handler", cqrs.HandlerScopeProcessor, factory)
+	assert.Equal(t, "handler", h.HandlerName())
+	assert.Equal(t, &SomeEvent{}, h.NewEvent())
+
+	err := h.Handle(context.Background(), &SomeEvent{})
+	assert.EqualError(t, err, "cannot construct handler handler: database not ready")
+
+	failConstructing = false
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, h.Handle(context.Background(), &SomeEvent{}))
+	}
+
+	assert.Equal(t, int32(1), constructed.Load())
+	assert.Equal(t, int32(3), handled.Load())
+}
@@ -0,0 +1,255 @@
+package cqrs
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// ScannedHandlerNameParams are passed to ScanHandlersConfig.GenerateHandlerName.
+type ScannedHandlerNameParams struct {
+	// StructName is the name of the scanned struct's type, without the package.
+	StructName string
+
+	// MethodName is the name of the method handling the command or event.
+	MethodName string
+}
+
+// ScanHandlersConfig holds the configuration of ScanCommandHandlers and ScanEventHandlers.
+type ScanHandlersConfig struct {
+	// GenerateHandlerName generates the handler's name.
+	// If nil, handlers are named "<StructName>.<MethodName>", for example, "OrdersService.PlaceOrder".
+	//
+	// WARNING: If HandlerName is used for generating consumer groups, changing the name of the struct or the method
+	// changes the consumer group, and it may result with **reconsuming all messages**!
+	GenerateHandlerName func(params ScannedHandlerNameParams) string
+}
+
+func (c *ScanHandlersConfig) setDefaults() {
+	if c.GenerateHandlerName == nil {
+		c.GenerateHandlerName = func(params ScannedHandlerNameParams) string {
+			return params.StructName + "." + params.MethodName
+		}
+	}
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	eventsType  = reflect.TypeOf([]any(nil))
+)
+
+// ScanCommandHandlers returns CommandHandlers for all exported methods of the handlers with the signature:
+//
+//	func(ctx context.Context, cmd *Command) error
+//
+// Methods with the signature below are returned as CommandHandlerWithEvents:
+//
+//	func(ctx context.Context, cmd *Command) ([]any, error)
+//
+// Other methods are ignored. The command type is taken from the method's argument, in the same way as in NewCommandHandler.
+// Use a pointer to the struct to include methods with pointer receivers.
+//
+// Returned handlers can be added with CommandProcessor.AddHandlers.
+func ScanCommandHandlers(handlers any, config ScanHandlersConfig) ([]CommandHandler, error) {
+	methods, err := scanHandlerMethods(handlers, config, true)
+	if err != nil {
+		return nil, err
+	}
+
+	commandHandlers := make([]CommandHandler, 0, len(methods))
+	for _, method := range methods {
+		if method.returnsEvents {
+			commandHandlers = append(commandHandlers, scannedCommandHandlerWithEvents{method})
+		} else {
+			commandHandlers = append(commandHandlers, scannedCommandHandler{method})
+		}
+	}
+
+	return commandHandlers, nil
+}
+
+// ScanEventHandlers returns EventHandlers for all exported methods of the handlers with the signature:
+//
+//	func(ctx context.Context, event *Event) error
+//
+// Other methods are ignored. The event type is taken from the method's argument, in the same way as in NewEventHandler.
+// Use a pointer to the struct to include methods with pointer receivers.
+//
+// Returned handlers can be added with EventProcessor.AddHandlers.
+func ScanEventHandlers(handlers any, config ScanHandlersConfig) ([]EventHandler, error) {
+	methods, err := scanHandlerMethods(handlers, config, false)
+	if err != nil {
+		return nil, err
+	}
+
+	eventHandlers := make([]EventHandler, 0, len(methods))
+	for _, method := range methods {
+		eventHandlers = append(eventHandlers, scannedEventHandler{method})
+	}
+
+	return eventHandlers, nil
+}
+
+type scannedMethod struct {
+	handlerName   string
+	argType       reflect.Type
+	method        reflect.Value
+	returnsEvents bool
+}
+
+func (m scannedMethod) newArg() any {
+	return reflect.New(m.argType).Interface()
+}
+
+func (m scannedMethod) call(ctx context.Context, arg any) ([]any, error) {
+	results := m.method.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(arg)})
+
+	errResult := results[len(results)-1]
+	if !errResult.IsNil() {
+		return nil, errResult.Interface().(error)
+	}
+	if m.returnsEvents {
+		return results[0].Interface().([]any), nil
+	}
+
+	return nil, nil
+}
+
+func scanHandlerMethods(handlers any, config ScanHandlersConfig, allowEvents bool) ([]scannedMethod, error) {
+	if handlers == nil {
+		return nil, errors.New("missing handlers")
+	}
+
+	config.setDefaults()
+
+	value := reflect.ValueOf(handlers)
+	structType := value.Type()
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("handlers must be a struct or a pointer to a struct, got %T", handlers)
+	}
+
+	var methods []scannedMethod
+
+	for i := 0; i < value.NumMethod(); i++ {
+		methodType := value.Type().Method(i)
+
+		argType, returnsEvents, ok := handlerMethodArg(methodType.Type, allowEvents)
+		if !ok {
+			continue
+		}
+
+		methods = append(methods, scannedMethod{
+			handlerName: config.GenerateHandlerName(ScannedHandlerNameParams{
+				StructName: structType.Name(),
+				MethodName: methodType.Name,
+			}),
+			argType:       argType,
+			method:        value.Method(i),
+			returnsEvents: returnsEvents,
+		})
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.Errorf("no handler methods found in %T", handlers)
+	}
+
+	return methods, nil
+}
+
+// handlerMethodArg returns the type of the command or event handled by the method, if it's a handler.
+// methodType includes the receiver as the first argument.
+func handlerMethodArg(methodType reflect.Type, allowEvents bool) (reflect.Type, bool, bool) {
+	if methodType.NumIn() != 3 || methodType.IsVariadic() {
+		return nil, false, false
+	}
+	if methodType.In(1) != contextType {
+		return nil, false, false
+	}
+
+	argType := methodType.In(2)
+	if argType.Kind() != reflect.Pointer || argType.Elem().Kind() != reflect.Struct {
+		return nil, false, false
+	}
+
+	switch {
+	case methodType.NumOut() == 1 && methodType.Out(0) == errorType:
+		return argType.Elem(), false, true
+	case allowEvents && methodType.NumOut() == 2 && methodType.Out(0) == eventsType && methodType.Out(1) == errorType:
+		return argType.Elem(), true, true
+	default:
+		return nil, false, false
+	}
+}
+
+type scannedCommandHandler struct {
+	scannedMethod
+}
+
+func (h scannedCommandHandler) HandlerName() string {
+	return h.handlerName
+}
+
+func (h scannedCommandHandler) NewCommand() any {
+	return h.newArg()
+}
+
+func (h scannedCommandHandler) Handle(ctx context.Context, cmd any) error {
+	_, err := h.call(ctx, cmd)
+	return err
+}
+
+type scannedCommandHandlerWithEvents struct {
+	scannedMethod
+}
+
+func (h scannedCommandHandlerWithEvents) HandlerName() string {
+	return h.handlerName
+}
+
+func (h scannedCommandHandlerWithEvents) NewCommand() any {
+	return h.newArg()
+}
+
+func (h scannedCommandHandlerWithEvents) HandleWithEvents(ctx context.Context, cmd any) ([]any, error) {
+	return h.call(ctx, cmd)
+}
+
+// Handle handles the command without publishing the events.
+// If any events are returned, an error is returned, because they would be lost.
+func (h scannedCommandHandlerWithEvents) Handle(ctx context.Context, cmd any) error {
+	events, err := h.HandleWithEvents(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		return errors.Errorf(
+			"handler %s returned %d events which were not published, add it to CommandProcessor with EventBus configured",
+			h.handlerName,
+			len(events),
+		)
+	}
+
+	return nil
+}
+
+type scannedEventHandler struct {
+	scannedMethod
+}
+
+func (h scannedEventHandler) HandlerName() string {
+	return h.handlerName
+}
+
+func (h scannedEventHandler) NewEvent() any {
+	return h.newArg()
+}
+
+func (h scannedEventHandler) Handle(ctx context.Context, event any) error {
+	_, err := h.call(ctx, event)
+	return err
+}
@@ -0,0 +1,122 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+)
+
+type scannedHandlers struct {
+	handled []any
+}
+
+func (h *scannedHandlers) HandleSomeCommand(ctx context.Context, cmd *SomeCommand) error {
+	h.handled = append(h.handled, cmd)
+	if cmd.Foo == "fail" {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (h *scannedHandlers) HandleTestCommand(ctx context.Context, cmd *TestCommand) ([]any, error) {
+	h.handled = append(h.handled, cmd)
+	return []any{&TestEvent{ID: cmd.ID}}, nil
+}
+
+// methods with other signatures are ignored
+func (h *scannedHandlers) Handled() []any {
+	return h.handled
+}
+
+func (h *scannedHandlers) NotHandler(ctx context.Context, id string) error {
+	return nil
+}
+
+type scannedEventHandlers struct {
+	handled []any
+}
+
+func (h *scannedEventHandlers) OnTestEvent(ctx context.Context, event *TestEvent) error {
+	h.handled = append(h.handled, event)
+	return nil
+}
+
+func (h *scannedEventHandlers) OnAnotherTestEvent(ctx context.Context, event *AnotherTestEvent) error {
+	h.handled = append(h.handled, event)
+	return nil
+}
+
+func (h *scannedEventHandlers) ReturnsEvents(ctx context.Context, event *TestEvent) ([]any, error) {
+	return nil, nil
+}
+
+func TestScanCommandHandlers(t *testing.T) {
+	svc := &scannedHandlers{}
+
+	handlers, err := cqrs.ScanCommandHandlers(svc, cqrs.ScanHandlersConfig{})
+	require.NoError(t, err)
+	require.Len(t, handlers, 2)
+
+	assert.Equal(t, "scannedHandlers.HandleSomeCommand", handlers[0].HandlerName())
+	assert.Equal(t, &SomeCommand{}, handlers[0].NewCommand())
+	assert.NoError(t, handlers[0].Handle(context.Background(), &SomeCommand{Foo: "bar"}))
+	assert.EqualError(t, handlers[0].Handle(context.Background(), &SomeCommand{Foo: "fail"}), "failed")
+
+	assert.Equal(t, "scannedHandlers.HandleTestCommand", handlers[1].HandlerName())
+	assert.Equal(t, &TestCommand{}, handlers[1].NewCommand())
+
+	handlerWithEvents, ok := handlers[1].(cqrs.CommandHandlerWithEvents)
+	require.True(t, ok)
+
+	events, err := handlerWithEvents.HandleWithEvents(context.Background(), &TestCommand{ID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{&TestEvent{ID: "1"}}, events)
+
+	err = handlers[1].Handle(context.Background(), &TestCommand{ID: "2"})
+	assert.ErrorContains(t, err, "returned 1 events which were not published")
+
+	assert.Equal(t, []any{
+		&SomeCommand{Foo: "bar"},
+		&SomeCommand{Foo: "fail"},
+		&TestCommand{ID: "1"},
+		&TestCommand{ID: "2"},
+	}, svc.Handled())
+}
+
+func TestScanEventHandlers(t *testing.T) {
+	svc := &scannedEventHandlers{}
+
+	handlers, err := cqrs.ScanEventHandlers(svc, cqrs.ScanHandlersConfig{
+		GenerateHandlerName: func(params cqrs.ScannedHandlerNameParams) string {
+			return "read_model." + params.MethodName
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, handlers, 2)
+
+	assert.Equal(t, "read_model.OnAnotherTestEvent", handlers[0].HandlerName())
+	assert.Equal(t, &AnotherTestEvent{}, handlers[0].NewEvent())
+
+	assert.Equal(t, "read_model.OnTestEvent", handlers[1].HandlerName())
+	assert.Equal(t, &TestEvent{}, handlers[1].NewEvent())
+
+	require.NoError(t, handlers[1].Handle(context.Background(), &TestEvent{ID: "1"}))
+	assert.Equal(t, []any{&TestEvent{ID: "1"}}, svc.handled)
+}
+
+func TestScanCommandHandlers_errors(t *testing.T) {
+	_, err := cqrs.ScanCommandHandlers(nil, cqrs.ScanHandlersConfig{})
+	assert.EqualError(t, err, "missing handlers")
+
+	_, err = cqrs.ScanCommandHandlers("not a struct", cqrs.ScanHandlersConfig{})
+	assert.EqualError(t, err, "handlers must be a struct or a pointer to a struct, got string")
+
+	// methods with pointer receivers are not found on the struct value
+	_, err = cqrs.ScanCommandHandlers(scannedHandlers{}, cqrs.ScanHandlersConfig{})
+	assert.EqualError(t, err, "no handler methods found in cqrs_test.scannedHandlers")
+}
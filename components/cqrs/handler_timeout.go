@@ -0,0 +1,83 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// HandlerTimeoutError is returned by the processors when a handler doesn't finish handling the message
+// within its timeout (see HandlerTimeout and HandlerTimeouts config options).
+type HandlerTimeoutError struct {
+	HandlerName string
+	Timeout     time.Duration
+}
+
+func (e HandlerTimeoutError) Error() string {
+	return fmt.Sprintf("handler %s timed out after %s", e.HandlerName, e.Timeout)
+}
+
+func handlerTimeout(defaultTimeout time.Duration, timeouts map[string]time.Duration, handlerName string) time.Duration {
+	if timeout, ok := timeouts[handlerName]; ok {
+		return timeout
+	}
+
+	return defaultTimeout
+}
+
+// handleWithTimeout calls handle with the message's context canceled after the timeout.
+// If handle doesn't return before the timeout, HandlerTimeoutError is returned without waiting for it,
+// so a handler ignoring the context can't block the subscription.
+// Such a handler keeps running in the background until it returns, and its result is discarded.
+// If the message's context is canceled before the timeout, the context's error is returned in the same way.
+func handleWithTimeout(
+	msg *message.Message,
+	handlerName string,
+	timeout time.Duration,
+	handle func(ctx context.Context) error,
+) error {
+	if timeout <= 0 {
+		return handle(msg.Context())
+	}
+
+	msgCtx := msg.Context()
+	ctx, cancel := context.WithTimeout(msgCtx, timeout)
+	defer cancel()
+	msg.SetContext(ctx)
+
+	type handleResult struct {
+		err       error
+		panicked  bool
+		recovered any
+	}
+
+	done := make(chan handleResult, 1)
+	go func() {
+		result := handleResult{panicked: true}
+		defer func() {
+			if result.panicked {
+				result.recovered = recover()
+			}
+			done <- result
+		}()
+
+		result.err = handle(ctx)
+		result.panicked = false
+	}()
+
+	select {
+	case result := <-done:
+		if result.panicked {
+			// re-panicking in the handler's goroutine, so it can be recovered by the router's middlewares
+			panic(result.recovered)
+		}
+		return result.err
+	case <-ctx.Done():
+		if err := msgCtx.Err(); err != nil {
+			return err
+		}
+		return HandlerTimeoutError{HandlerName: handlerName, Timeout: timeout}
+	}
+}
@@ -0,0 +1,157 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEventProcessor_handler_timeout(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	ep, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return params.EventName, nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		HandlerTimeout: time.Hour,
+		HandlerTimeouts: map[string]time.Duration{
+			"slow": time.Millisecond * 10,
+		},
+		Marshaler: ts.Marshaler,
+		Logger:    ts.Logger,
+	})
+	require.NoError(t, err)
+
+	slowHandlerCtxDone := make(chan struct{})
+	blockSlowHandler := make(chan struct{})
+	defer close(blockSlowHandler)
+
+	err = ep.AddHandlers(
+		cqrs.NewEventHandler("slow", func(ctx context.Context, event *TestEvent) error {
+			<-ctx.Done()
+			close(slowHandlerCtxDone)
+
+			// ignoring the context, the processor shouldn't wait
+			<-blockSlowHandler
+			return nil
+		}),
+		cqrs.NewEventHandler("fast", func(ctx context.Context, event *TestEvent) error {
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	msg, err := ts.Marshaler.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+
+	err = router.HandleMessage("slow", msg)
+	var timeoutErr cqrs.HandlerTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, "slow", timeoutErr.HandlerName)
+	assert.Equal(t, time.Millisecond*10, timeoutErr.Timeout)
+
+	select {
+	case <-slowHandlerCtxDone:
+	case <-time.After(time.Second):
+		t.Fatal("context of the slow handler should be canceled")
+	}
+
+	msg, err = ts.Marshaler.Marshal(&TestEvent{ID: "2"})
+	require.NoError(t, err)
+
+	require.NoError(t, router.HandleMessage("fast", msg))
+}
+
+func TestEventGroupProcessor_handler_timeout(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	ep, err := cqrs.NewEventGroupProcessorWithConfig(router, cqrs.EventGroupProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventGroupProcessorGenerateSubscribeTopicParams) (string, error) {
+			return params.EventGroupName, nil
+		},
+		SubscriberConstructor: func(params cqrs.EventGroupProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.EventsPubSub, nil
+		},
+		HandlerTimeouts: map[string]time.Duration{
+			"group": time.Millisecond * 10,
+		},
+		Marshaler: ts.Marshaler,
+		Logger:    ts.Logger,
+	})
+	require.NoError(t, err)
+
+	err = ep.AddHandlersGroup("group", cqrs.NewGroupEventHandler(func(ctx context.Context, event *TestEvent) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	require.NoError(t, err)
+
+	msg, err := ts.Marshaler.Marshal(&TestEvent{ID: "1"})
+	require.NoError(t, err)
+
+	err = router.HandleMessage("group", msg)
+	assert.ErrorAs(t, err, &cqrs.HandlerTimeoutError{})
+}
+
+func TestCommandProcessor_handler_timeout(t *testing.T) {
+	ts := NewTestServices()
+
+	router, err := message.NewRouter(message.RouterConfig{}, ts.Logger)
+	require.NoError(t, err)
+
+	cp, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return ts.CommandsPubSub, nil
+		},
+		HandlerTimeout: time.Millisecond * 10,
+		Marshaler:      ts.Marshaler,
+		Logger:         ts.Logger,
+	})
+	require.NoError(t, err)
+
+	err = cp.AddHandlers(
+		cqrs.NewCommandHandler("slow", func(ctx context.Context, cmd *TestCommand) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		cqrs.NewCommandHandler("panicking", func(ctx context.Context, cmd *SomeCommand) error {
+			panic("boom")
+		}),
+	)
+	require.NoError(t, err)
+
+	msg, err := ts.Marshaler.Marshal(&TestCommand{ID: "1"})
+	require.NoError(t, err)
+
+	err = router.HandleMessage("slow", msg)
+	assert.EqualError(t, err, "handler slow timed out after 10ms")
+
+	msg, err = ts.Marshaler.Marshal(&SomeCommand{})
+	require.NoError(t, err)
+
+	// panics are propagated to the router's goroutine, so they can be recovered by middlewares
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = router.HandleMessage("panicking", msg)
+	})
+}
@@ -0,0 +1,107 @@
+package cqrs
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// EncodingMarshaler encodes the payloads of messages marshaled by Marshaler (for example, compresses them with gzip),
+// and decodes them before unmarshaling, based on message.ContentEncodingMetadataKey.
+//
+// Messages with encodings not registered in Encodings fail to unmarshal with message.UnsupportedContentEncodingError,
+// so producers and consumers with mismatched encodings fail loudly instead of unmarshaling garbage.
+// Messages without encoding are unmarshaled as they are, so producers can be migrated one by one.
+type EncodingMarshaler struct {
+	// Marshaler marshals the payload of commands and events. It is required.
+	Marshaler CommandEventMarshaler
+
+	// Encoding is the encoding of published messages, for example message.ContentEncodingGzip.
+	// If empty, payloads are not encoded, but encoded messages are still decoded.
+	Encoding string
+
+	// Encodings is the registry of encodings. If not provided, message.DefaultContentEncodings is used.
+	Encodings *message.ContentEncodings
+}
+
+func (m EncodingMarshaler) encodings() *message.ContentEncodings {
+	if m.Encodings != nil {
+		return m.Encodings
+	}
+
+	return message.DefaultContentEncodings
+}
+
+func (m EncodingMarshaler) Marshal(v any) (*message.Message, error) {
+	msg, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Encoding == "" || m.Encoding == message.ContentEncodingIdentity {
+		return msg, nil
+	}
+
+	if err := m.encodings().Encode(msg, m.Encoding); err != nil {
+		return nil, errors.Wrap(err, "cannot encode payload")
+	}
+
+	return msg, nil
+}
+
+func (m EncodingMarshaler) Unmarshal(msg *message.Message, v any) error {
+	if message.ContentEncoding(msg) == message.ContentEncodingIdentity {
+		return m.Marshaler.Unmarshal(msg, v)
+	}
+
+	payload, err := m.encodings().DecodedPayload(msg)
+	if err != nil {
+		return err
+	}
+
+	decoded := msg.Copy()
+	decoded.Payload = payload
+	decoded.SetContext(msg.Context())
+	message.SetContentEncoding(decoded, message.ContentEncodingIdentity)
+
+	return m.Marshaler.Unmarshal(decoded, v)
+}
+
+func (m EncodingMarshaler) Name(v any) string {
+	return m.Marshaler.Name(v)
+}
+
+func (m EncodingMarshaler) NameFromMessage(msg *message.Message) string {
+	return m.Marshaler.NameFromMessage(msg)
+}
+
+// Reseal implements MarshalerWithReseal. The payload is decoded, resealed by Marshaler, and encoded again.
+func (m EncodingMarshaler) Reseal(msg *message.Message) error {
+	if _, ok := m.Marshaler.(MarshalerWithReseal); !ok {
+		return nil
+	}
+
+	encoding := message.ContentEncoding(msg)
+	if encoding == message.ContentEncodingIdentity {
+		return resealPayload(m.Marshaler, msg)
+	}
+
+	payload, err := m.encodings().DecodedPayload(msg)
+	if err != nil {
+		return err
+	}
+
+	msg.Payload = payload
+	// the encoding is not known when Marshaler marshals the message
+	message.SetContentEncoding(msg, message.ContentEncodingIdentity)
+
+	if err := resealPayload(m.Marshaler, msg); err != nil {
+		return err
+	}
+
+	if err := m.encodings().Encode(msg, encoding); err != nil {
+		return errors.Wrap(err, "cannot encode payload")
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package cqrs_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEncodingMarshaler(t *testing.T) {
+	marshaler := cqrs.EncodingMarshaler{
+		Marshaler: cqrs.JSONMarshaler{},
+		Encoding:  message.ContentEncodingGzip,
+	}
+
+	eventToMarshal := TestEvent{ID: "1"}
+
+	msg, err := marshaler.Marshal(eventToMarshal)
+	require.NoError(t, err)
+	assert.Equal(t, message.ContentEncodingGzip, message.ContentEncoding(msg))
+	assert.Equal(t, cqrs.JSONMarshaler{}.Name(eventToMarshal), marshaler.NameFromMessage(msg))
+
+	var unmarshaledEvent TestEvent
+	require.NoError(t, marshaler.Unmarshal(msg, &unmarshaledEvent))
+	assert.Equal(t, eventToMarshal, unmarshaledEvent)
+
+	// the message is not modified by unmarshaling
+	assert.Equal(t, message.ContentEncodingGzip, message.ContentEncoding(msg))
+
+	// consumers not able to decode the payload fail loudly
+	err = cqrs.JSONMarshaler{}.Unmarshal(msg, &unmarshaledEvent)
+	assert.Equal(t, message.UnsupportedContentEncodingError{Encoding: message.ContentEncodingGzip}, err)
+}
+
+func TestEncodingMarshaler_not_encoded_message(t *testing.T) {
+	marshaler := cqrs.EncodingMarshaler{
+		Marshaler: cqrs.JSONMarshaler{},
+		Encoding:  message.ContentEncodingGzip,
+	}
+
+	eventToMarshal := TestEvent{ID: "1"}
+
+	msg, err := cqrs.JSONMarshaler{}.Marshal(eventToMarshal)
+	require.NoError(t, err)
+
+	var unmarshaledEvent TestEvent
+	require.NoError(t, marshaler.Unmarshal(msg, &unmarshaledEvent))
+	assert.Equal(t, eventToMarshal, unmarshaledEvent)
+}
+
+func TestEncodingMarshaler_unsupported_encoding(t *testing.T) {
+	marshaler := cqrs.EncodingMarshaler{
+		Marshaler: cqrs.JSONMarshaler{},
+		Encoding:  message.ContentEncodingZstd,
+	}
+
+	_, err := marshaler.Marshal(TestEvent{ID: "1"})
+	assert.ErrorAs(t, err, &message.UnsupportedContentEncodingError{})
+
+	msg, err := cqrs.JSONMarshaler{}.Marshal(TestEvent{ID: "1"})
+	require.NoError(t, err)
+	message.SetContentEncoding(msg, message.ContentEncodingZstd)
+
+	var unmarshaledEvent TestEvent
+	err = cqrs.EncodingMarshaler{Marshaler: cqrs.JSONMarshaler{}}.Unmarshal(msg, &unmarshaledEvent)
+	assert.ErrorAs(t, err, &message.UnsupportedContentEncodingError{})
+}
@@ -0,0 +1,68 @@
+package cqrs
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/components/envelope"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// EnvelopeHeadersProvider can be implemented by commands and events to provide their envelope headers.
+type EnvelopeHeadersProvider interface {
+	EnvelopeHeaders() envelope.Headers
+}
+
+// EnvelopeMarshaler adds the typed envelope headers (see the envelope package) to messages marshaled by Marshaler.
+//
+// Headers are taken from commands and events implementing EnvelopeHeadersProvider, and from the Headers function.
+// Headers returned by the Headers function take precedence.
+// On the consumer side, the headers can be read with envelope.FromMessage.
+type EnvelopeMarshaler struct {
+	// Marshaler marshals the payload of commands and events. It is required.
+	Marshaler CommandEventMarshaler
+
+	// Headers returns the headers of the command or event. It's optional.
+	Headers func(v any) (envelope.Headers, error)
+}
+
+func (m EnvelopeMarshaler) Marshal(v any) (*message.Message, error) {
+	msg, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if provider, ok := v.(EnvelopeHeadersProvider); ok {
+		if err := provider.EnvelopeHeaders().Apply(msg); err != nil {
+			return nil, errors.Wrap(err, "cannot apply envelope headers")
+		}
+	}
+
+	if m.Headers != nil {
+		headers, err := m.Headers(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get envelope headers")
+		}
+		if err := headers.Apply(msg); err != nil {
+			return nil, errors.Wrap(err, "cannot apply envelope headers")
+		}
+	}
+
+	return msg, nil
+}
+
+func (m EnvelopeMarshaler) Unmarshal(msg *message.Message, v any) error {
+	return m.Marshaler.Unmarshal(msg, v)
+}
+
+func (m EnvelopeMarshaler) Name(v any) string {
+	return m.Marshaler.Name(v)
+}
+
+func (m EnvelopeMarshaler) NameFromMessage(msg *message.Message) string {
+	return m.Marshaler.NameFromMessage(msg)
+}
+
+// Reseal implements MarshalerWithReseal, forwarding it to Marshaler.
+func (m EnvelopeMarshaler) Reseal(msg *message.Message) error {
+	return resealPayload(m.Marshaler, msg)
+}
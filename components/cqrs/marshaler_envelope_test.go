@@ -0,0 +1,63 @@
+package cqrs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/envelope"
+)
+
+type eventWithEnvelopeHeaders struct {
+	ID string `json:"id"`
+}
+
+func (e eventWithEnvelopeHeaders) EnvelopeHeaders() envelope.Headers {
+	return envelope.Headers{
+		"version": envelope.Int(2),
+		"tenant":  envelope.String("from-event"),
+	}
+}
+
+func TestEnvelopeMarshaler(t *testing.T) {
+	occurredAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	marshaler := cqrs.EnvelopeMarshaler{
+		Marshaler: cqrs.JSONMarshaler{},
+		Headers: func(v any) (envelope.Headers, error) {
+			return envelope.Headers{
+				"tenant":      envelope.String("acme"),
+				"occurred_at": envelope.Time(occurredAt),
+			}, nil
+		},
+	}
+
+	event := eventWithEnvelopeHeaders{ID: "1"}
+
+	msg, err := marshaler.Marshal(event)
+	require.NoError(t, err)
+	assert.Equal(t, "cqrs_test.eventWithEnvelopeHeaders", marshaler.NameFromMessage(msg))
+	assert.Equal(t, marshaler.Name(event), marshaler.NameFromMessage(msg))
+
+	e, err := envelope.FromMessage(msg)
+	require.NoError(t, err)
+
+	version, ok := e.Headers.GetInt("version")
+	require.True(t, ok)
+	assert.EqualValues(t, 2, version)
+
+	tenant, ok := e.Headers.GetString("tenant")
+	require.True(t, ok)
+	assert.Equal(t, "acme", tenant)
+
+	gotOccurredAt, ok := e.Headers.GetTime("occurred_at")
+	require.True(t, ok)
+	assert.True(t, occurredAt.Equal(gotOccurredAt))
+
+	var unmarshaled eventWithEnvelopeHeaders
+	require.NoError(t, marshaler.Unmarshal(msg, &unmarshaled))
+	assert.Equal(t, event, unmarshaled)
+}
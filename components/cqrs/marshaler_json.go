@@ -36,7 +36,14 @@ func (m JSONMarshaler) newUUID() string {
 	return watermill.NewUUID()
 }
 
+// Unmarshal unmarshals the message's payload.
+// It returns message.UnsupportedContentEncodingError if the payload is encoded,
+// use EncodingMarshaler to decode such payloads.
 func (JSONMarshaler) Unmarshal(msg *message.Message, v interface{}) (err error) {
+	if err := message.RequireIdentityEncoding(msg); err != nil {
+		return err
+	}
+
 	return json.Unmarshal(msg.Payload, v)
 }
 
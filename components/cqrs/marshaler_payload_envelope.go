@@ -0,0 +1,166 @@
+package cqrs
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// PayloadEnvelopeMarshaler encodes the metadata together with the payload of messages marshaled by Marshaler
+// into a single envelope payload, so the metadata (for example, the command or event name, the correlation ID,
+// or the schema version) survives transports and bridges that don't support or strip the message headers.
+//
+// The envelope is a JSON object with the metadata and the original payload. The metadata is kept in the message
+// headers as well. CommandBus and EventBus encode the envelope again right before publishing (see MarshalerWithReseal),
+// so it contains the metadata set by StandardMetadata, OnSend, OnPublish, and SendWithModifiedMessage.
+// The metadata set later, for example by publisher decorators, is sent only in the headers.
+//
+// It can be wrapped with EncodingMarshaler, ClaimCheckMarshaler, and EnvelopeMarshaler, which forward Reseal.
+// Custom marshalers wrapping it should implement MarshalerWithReseal as well.
+//
+// On the consumer side, NameFromMessage and Unmarshal decode the envelope, and Unmarshal restores the metadata
+// missing in the received message. Messages that are not envelopes are passed to Marshaler as they are,
+// so producers and consumers can be migrated one by one.
+//
+// To use it, set it as the Marshaler of the bus and the processor, for example:
+//
+//	marshaler := cqrs.PayloadEnvelopeMarshaler{Marshaler: cqrs.JSONMarshaler{}}
+type PayloadEnvelopeMarshaler struct {
+	// Marshaler marshals the payload of commands and events. It is required.
+	Marshaler CommandEventMarshaler
+}
+
+// payloadEnvelopeVersion identifies envelopes created by PayloadEnvelopeMarshaler.
+const payloadEnvelopeVersion = "1"
+
+type payloadEnvelope struct {
+	Version  string            `json:"_watermill_envelope"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Payload  []byte            `json:"payload"`
+}
+
+func (m PayloadEnvelopeMarshaler) Marshal(v any) (*message.Message, error) {
+	msg, err := m.Marshaler.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := encodePayloadEnvelope(msg, msg.Payload); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (m PayloadEnvelopeMarshaler) Unmarshal(msg *message.Message, v any) error {
+	env, ok := decodePayloadEnvelope(msg)
+	if !ok {
+		return m.Marshaler.Unmarshal(msg, v)
+	}
+
+	for key, value := range env.Metadata {
+		if _, ok := msg.Metadata[key]; !ok {
+			msg.Metadata.Set(key, value)
+		}
+	}
+
+	return m.Marshaler.Unmarshal(unwrappedMessage(msg, env), v)
+}
+
+func (m PayloadEnvelopeMarshaler) Name(v any) string {
+	return m.Marshaler.Name(v)
+}
+
+func (m PayloadEnvelopeMarshaler) NameFromMessage(msg *message.Message) string {
+	env, ok := decodePayloadEnvelope(msg)
+	if !ok {
+		return m.Marshaler.NameFromMessage(msg)
+	}
+
+	return m.Marshaler.NameFromMessage(unwrappedMessage(msg, env))
+}
+
+// UnwrapPayloadEnvelope returns a copy of the message encoded by PayloadEnvelopeMarshaler, with the original payload
+// and the metadata restored from the envelope. Metadata present in msg takes precedence.
+// It's useful for consumers that don't use the CQRS component, for example with a router middleware.
+//
+// If msg is not an envelope, it is returned as it is.
+func UnwrapPayloadEnvelope(msg *message.Message) *message.Message {
+	env, ok := decodePayloadEnvelope(msg)
+	if !ok {
+		return msg
+	}
+
+	return unwrappedMessage(msg, env)
+}
+
+// Reseal implements MarshalerWithReseal. It encodes the envelope again with the current metadata.
+func (m PayloadEnvelopeMarshaler) Reseal(msg *message.Message) error {
+	env, ok := decodePayloadEnvelope(msg)
+	if !ok {
+		return nil
+	}
+
+	return encodePayloadEnvelope(msg, env.Payload)
+}
+
+// MarshalerWithReseal is an optional interface of CommandEventMarshaler, implemented by marshalers
+// which encode the metadata into the payload, like PayloadEnvelopeMarshaler.
+// Marshalers wrapping other marshalers should forward Reseal to the wrapped marshaler, if it implements it.
+type MarshalerWithReseal interface {
+	// Reseal updates the payload of the message created by Marshal after its metadata was modified.
+	Reseal(msg *message.Message) error
+}
+
+// resealPayload calls Reseal of marshaler, if it implements MarshalerWithReseal.
+func resealPayload(marshaler CommandEventMarshaler, msg *message.Message) error {
+	resealer, ok := marshaler.(MarshalerWithReseal)
+	if !ok {
+		return nil
+	}
+
+	return resealer.Reseal(msg)
+}
+
+func encodePayloadEnvelope(msg *message.Message, payload []byte) error {
+	b, err := json.Marshal(payloadEnvelope{
+		Version:  payloadEnvelopeVersion,
+		Metadata: msg.Metadata,
+		Payload:  payload,
+	})
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal payload envelope")
+	}
+
+	msg.Payload = b
+
+	return nil
+}
+
+func decodePayloadEnvelope(msg *message.Message) (payloadEnvelope, bool) {
+	var env payloadEnvelope
+	if err := json.Unmarshal(msg.Payload, &env); err != nil {
+		return payloadEnvelope{}, false
+	}
+	if env.Version != payloadEnvelopeVersion {
+		return payloadEnvelope{}, false
+	}
+
+	return env, true
+}
+
+func unwrappedMessage(msg *message.Message, env payloadEnvelope) *message.Message {
+	unwrapped := message.NewMessage(msg.UUID, env.Payload)
+	unwrapped.SetContext(msg.Context())
+
+	for key, value := range env.Metadata {
+		unwrapped.Metadata.Set(key, value)
+	}
+	for key, value := range msg.Metadata {
+		unwrapped.Metadata.Set(key, value)
+	}
+
+	return unwrapped
+}
@@ -0,0 +1,169 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// stripHeaders simulates a transport that doesn't support the message headers.
+func stripHeaders(msg *message.Message) *message.Message {
+	return message.NewMessage(msg.UUID, msg.Payload)
+}
+
+func TestPayloadEnvelopeMarshaler(t *testing.T) {
+	marshaler := cqrs.PayloadEnvelopeMarshaler{Marshaler: cqrs.JSONMarshaler{}}
+
+	event := &TestEvent{ID: "1"}
+
+	msg, err := marshaler.Marshal(event)
+	require.NoError(t, err)
+	msg.Metadata.Set("correlation_id", "from-headers")
+
+	assert.Equal(t, "cqrs_test.TestEvent", msg.Metadata.Get("name"), "metadata should be kept in the headers")
+
+	received := stripHeaders(msg)
+	received.Metadata.Set("correlation_id", "set-by-transport")
+
+	assert.Equal(t, marshaler.Name(event), marshaler.NameFromMessage(received))
+
+	var unmarshaled TestEvent
+	require.NoError(t, marshaler.Unmarshal(received, &unmarshaled))
+	assert.Equal(t, *event, unmarshaled)
+
+	assert.Equal(t, "cqrs_test.TestEvent", received.Metadata.Get("name"), "missing metadata should be restored")
+	assert.Equal(t, "set-by-transport", received.Metadata.Get("correlation_id"), "received metadata takes precedence")
+
+	unwrapped := cqrs.UnwrapPayloadEnvelope(stripHeaders(msg))
+	assert.Equal(t, "cqrs_test.TestEvent", unwrapped.Metadata.Get("name"))
+	assert.JSONEq(t, `{"ID":"1","When":"0001-01-01T00:00:00Z"}`, string(unwrapped.Payload))
+}
+
+func TestPayloadEnvelopeMarshaler_not_envelope(t *testing.T) {
+	marshaler := cqrs.PayloadEnvelopeMarshaler{Marshaler: cqrs.JSONMarshaler{}}
+
+	event := &TestEvent{ID: "1"}
+
+	// message published before migrating the producer
+	msg, err := cqrs.JSONMarshaler{}.Marshal(event)
+	require.NoError(t, err)
+
+	assert.Equal(t, marshaler.Name(event), marshaler.NameFromMessage(msg))
+
+	var unmarshaled TestEvent
+	require.NoError(t, marshaler.Unmarshal(msg, &unmarshaled))
+	assert.Equal(t, *event, unmarshaled)
+
+	assert.Same(t, msg, cqrs.UnwrapPayloadEnvelope(msg))
+}
+
+func TestPayloadEnvelopeMarshaler_buses(t *testing.T) {
+	marshaler := cqrs.PayloadEnvelopeMarshaler{Marshaler: cqrs.JSONMarshaler{}}
+	publisher := &capturingPublisher{}
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		OnSend: func(params cqrs.CommandBusOnSendParams) error {
+			params.Message.Metadata.Set("correlation_id", "123")
+			return nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		OnPublish: func(params cqrs.OnEventSendParams) error {
+			params.Message.Metadata.Set("correlation_id", "123")
+			return nil
+		},
+		Marshaler: marshaler,
+	})
+	require.NoError(t, err)
+
+	err = commandBus.SendWithModifiedMessage(context.Background(), &TestCommand{ID: "1"}, func(msg *message.Message) error {
+		msg.Metadata.Set("schema", "v2")
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "2"}))
+
+	require.Len(t, publisher.messages, 2)
+
+	command := stripHeaders(publisher.messages[0])
+	assert.Equal(t, "cqrs_test.TestCommand", marshaler.NameFromMessage(command))
+
+	var cmd TestCommand
+	require.NoError(t, marshaler.Unmarshal(command, &cmd))
+	assert.Equal(t, "1", cmd.ID)
+	assert.Equal(t, "123", command.Metadata.Get("correlation_id"))
+	assert.Equal(t, "v2", command.Metadata.Get("schema"))
+
+	event := stripHeaders(publisher.messages[1])
+	assert.Equal(t, "cqrs_test.TestEvent", marshaler.NameFromMessage(event))
+
+	var e TestEvent
+	require.NoError(t, marshaler.Unmarshal(event, &e))
+	assert.Equal(t, "2", e.ID)
+	assert.Equal(t, "123", event.Metadata.Get("correlation_id"))
+}
+
+func TestPayloadEnvelopeMarshaler_decorated(t *testing.T) {
+	envelopeMarshaler := cqrs.PayloadEnvelopeMarshaler{Marshaler: cqrs.JSONMarshaler{}}
+
+	testCases := []struct {
+		Name      string
+		Marshaler cqrs.CommandEventMarshaler
+	}{
+		{
+			Name:      "encoding",
+			Marshaler: cqrs.EncodingMarshaler{Marshaler: envelopeMarshaler, Encoding: message.ContentEncodingGzip},
+		},
+		{
+			Name:      "claim_check",
+			Marshaler: cqrs.ClaimCheckMarshaler{Marshaler: envelopeMarshaler, Store: &claimCheckStoreStub{}},
+		},
+		{
+			Name:      "envelope",
+			Marshaler: &cqrs.EnvelopeMarshaler{Marshaler: &envelopeMarshaler},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			publisher := &capturingPublisher{}
+
+			eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+				GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+					return "events", nil
+				},
+				OnPublish: func(params cqrs.OnEventSendParams) error {
+					params.Message.Metadata.Set("correlation_id", "123")
+					return nil
+				},
+				Marshaler: tc.Marshaler,
+			})
+			require.NoError(t, err)
+
+			require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+			require.Len(t, publisher.messages, 1)
+
+			payload, err := message.DefaultContentEncodings.DecodedPayload(publisher.messages[0])
+			require.NoError(t, err)
+
+			event := message.NewMessage("1", payload)
+			assert.Equal(t, "123", cqrs.UnwrapPayloadEnvelope(event).Metadata.Get("correlation_id"))
+		})
+	}
+}
@@ -61,7 +61,13 @@ func (m ProtobufMarshaler) newUUID() string {
 }
 
 // Unmarshal unmarshals given watermill's Message into protobuf's message.
+// It returns message.UnsupportedContentEncodingError if the payload is encoded,
+// use EncodingMarshaler to decode such payloads.
 func (ProtobufMarshaler) Unmarshal(msg *message.Message, v interface{}) (err error) {
+	if err := message.RequireIdentityEncoding(msg); err != nil {
+		return err
+	}
+
 	return proto.Unmarshal(msg.Payload, v.(proto.Message))
 }
 
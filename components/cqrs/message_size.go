@@ -0,0 +1,192 @@
+package cqrs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ClaimCheckKeyMetadataKey is the metadata key of the claim check of the payload offloaded to ClaimCheckStore.
+const ClaimCheckKeyMetadataKey = "_watermill_claim_check_key"
+
+// MessageSizeAction is the action taken when the marshaled payload exceeds MessageSizeConfig.MaxPayloadSize.
+type MessageSizeAction string
+
+const (
+	// MessageSizeReject returns MessageTooLargeError, so the message is not published.
+	MessageSizeReject MessageSizeAction = "reject"
+
+	// MessageSizeCompress encodes the payload with MessageSizeConfig.Encoding.
+	// If the encoded payload still exceeds the limit, MessageTooLargeError is returned.
+	// Consumers must decode the payload, for example with EncodingMarshaler.
+	MessageSizeCompress MessageSizeAction = "compress"
+
+	// MessageSizeClaimCheck stores the payload in MessageSizeConfig.ClaimCheckStore, and publishes the message
+	// with an empty payload and the claim check in ClaimCheckKeyMetadataKey.
+	// Consumers must load the payload, for example with ClaimCheckMarshaler.
+	MessageSizeClaimCheck MessageSizeAction = "claim_check"
+)
+
+// MessageTooLargeError is returned when the marshaled payload exceeds MessageSizeConfig.MaxPayloadSize.
+type MessageTooLargeError struct {
+	Name    string
+	Size    int
+	MaxSize int
+}
+
+func (e MessageTooLargeError) Error() string {
+	return fmt.Sprintf("payload of %s is too large: %d bytes, max %d bytes", e.Name, e.Size, e.MaxSize)
+}
+
+// ClaimCheckStore stores the payloads offloaded from messages exceeding the size limit,
+// for example in an object storage.
+type ClaimCheckStore interface {
+	Store(ctx context.Context, key string, payload []byte) error
+	Load(ctx context.Context, key string) ([]byte, error)
+}
+
+// MessageSizeConfig configures measuring the size of marshaled payloads and enforcing the maximum size,
+// so messages exceeding the broker's limit fail (or are handled) before publishing.
+type MessageSizeConfig struct {
+	// MaxPayloadSize is the maximum size of the marshaled payload in bytes.
+	// If 0, the size is measured, but not enforced.
+	MaxPayloadSize int
+
+	// Action is the action taken for payloads exceeding MaxPayloadSize. Defaults to MessageSizeReject.
+	Action MessageSizeAction
+
+	// Encoding is the encoding used with MessageSizeCompress. Defaults to message.ContentEncodingGzip.
+	Encoding string
+
+	// Encodings is the registry of encodings. If not provided, message.DefaultContentEncodings is used.
+	Encodings *message.ContentEncodings
+
+	// ClaimCheckStore stores the offloaded payloads. It is required with MessageSizeClaimCheck.
+	ClaimCheckStore ClaimCheckStore
+
+	// OnMeasured is an optional function called with the size of every marshaled payload,
+	// for example, to record it (see metrics.PrometheusMetricsBuilder.NewMarshaledPayloadSizeHistogram).
+	OnMeasured func(msg *message.Message, name string, size int)
+}
+
+func (c *MessageSizeConfig) setDefaults() {
+	if c.Action == "" {
+		c.Action = MessageSizeReject
+	}
+	if c.Encoding == "" {
+		c.Encoding = message.ContentEncodingGzip
+	}
+	if c.Encodings == nil {
+		c.Encodings = message.DefaultContentEncodings
+	}
+}
+
+func (c MessageSizeConfig) Validate() error {
+	if c.MaxPayloadSize < 0 {
+		return errors.New("MaxPayloadSize must not be negative")
+	}
+
+	switch c.Action {
+	case MessageSizeReject:
+	case MessageSizeCompress:
+		if !c.Encodings.Supports(c.Encoding) {
+			return message.UnsupportedContentEncodingError{Encoding: c.Encoding}
+		}
+	case MessageSizeClaimCheck:
+		if c.ClaimCheckStore == nil {
+			return errors.New("ClaimCheckStore is required with MessageSizeClaimCheck")
+		}
+	default:
+		return errors.Errorf("unknown Action %s", c.Action)
+	}
+
+	return nil
+}
+
+// apply measures the payload and applies the Action if it exceeds MaxPayloadSize.
+func (c MessageSizeConfig) apply(msg *message.Message, name string) error {
+	size := len(msg.Payload)
+
+	if c.OnMeasured != nil {
+		c.OnMeasured(msg, name, size)
+	}
+
+	if c.MaxPayloadSize == 0 || size <= c.MaxPayloadSize {
+		return nil
+	}
+
+	tooLarge := MessageTooLargeError{Name: name, Size: size, MaxSize: c.MaxPayloadSize}
+
+	switch c.Action {
+	case MessageSizeCompress:
+		if err := c.Encodings.Encode(msg, c.Encoding); err != nil {
+			return errors.Wrap(err, "cannot compress payload")
+		}
+		if len(msg.Payload) > c.MaxPayloadSize {
+			tooLarge.Size = len(msg.Payload)
+			return errors.Wrap(tooLarge, "payload is too large after compression")
+		}
+	case MessageSizeClaimCheck:
+		key := watermill.NewUUID()
+		if err := c.ClaimCheckStore.Store(msg.Context(), key, msg.Payload); err != nil {
+			return errors.Wrap(err, "cannot store payload in claim check store")
+		}
+
+		msg.Payload = message.Payload{}
+		msg.Metadata.Set(ClaimCheckKeyMetadataKey, key)
+	default:
+		return tooLarge
+	}
+
+	return nil
+}
+
+// ClaimCheckMarshaler loads the payloads offloaded by MessageSizeClaimCheck from Store before unmarshaling.
+// Messages without the claim check are unmarshaled as they are.
+type ClaimCheckMarshaler struct {
+	// Marshaler marshals the payload of commands and events. It is required.
+	Marshaler CommandEventMarshaler
+
+	// Store is the store of the offloaded payloads. It is required.
+	Store ClaimCheckStore
+}
+
+func (m ClaimCheckMarshaler) Marshal(v any) (*message.Message, error) {
+	return m.Marshaler.Marshal(v)
+}
+
+func (m ClaimCheckMarshaler) Unmarshal(msg *message.Message, v any) error {
+	key := msg.Metadata.Get(ClaimCheckKeyMetadataKey)
+	if key == "" {
+		return m.Marshaler.Unmarshal(msg, v)
+	}
+
+	payload, err := m.Store.Load(msg.Context(), key)
+	if err != nil {
+		return errors.Wrapf(err, "cannot load payload of claim check %s", key)
+	}
+
+	loaded := msg.Copy()
+	loaded.Payload = payload
+	loaded.SetContext(msg.Context())
+	delete(loaded.Metadata, ClaimCheckKeyMetadataKey)
+
+	return m.Marshaler.Unmarshal(loaded, v)
+}
+
+func (m ClaimCheckMarshaler) Name(v any) string {
+	return m.Marshaler.Name(v)
+}
+
+func (m ClaimCheckMarshaler) NameFromMessage(msg *message.Message) string {
+	return m.Marshaler.NameFromMessage(msg)
+}
+
+// Reseal implements MarshalerWithReseal, forwarding it to Marshaler.
+func (m ClaimCheckMarshaler) Reseal(msg *message.Message) error {
+	return resealPayload(m.Marshaler, msg)
+}
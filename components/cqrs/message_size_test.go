@@ -0,0 +1,232 @@
+package cqrs_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type claimCheckStoreStub struct {
+	payloads map[string][]byte
+	lock     sync.Mutex
+}
+
+func (s *claimCheckStoreStub) Store(ctx context.Context, key string, payload []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.payloads == nil {
+		s.payloads = map[string][]byte{}
+	}
+	s.payloads[key] = payload
+
+	return nil
+}
+
+func (s *claimCheckStoreStub) Load(ctx context.Context, key string) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	payload, ok := s.payloads[key]
+	if !ok {
+		return nil, errors.Errorf("payload %s not found", key)
+	}
+
+	return payload, nil
+}
+
+func incompressibleString(length int) string {
+	var s strings.Builder
+	for s.Len() < length {
+		s.WriteString(watermill.NewUUID())
+	}
+	return s.String()
+}
+
+func newSizeLimitedCommandBus(t *testing.T, publisher message.Publisher, config cqrs.MessageSizeConfig) *cqrs.CommandBus {
+	t.Helper()
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		Marshaler:   cqrs.JSONMarshaler{},
+		MessageSize: &config,
+	})
+	require.NoError(t, err)
+
+	return commandBus
+}
+
+func TestMessageSize_reject(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	var measured []int
+	commandBus := newSizeLimitedCommandBus(t, publisher, cqrs.MessageSizeConfig{
+		MaxPayloadSize: 100,
+		OnMeasured: func(msg *message.Message, name string, size int) {
+			assert.Equal(t, "cqrs_test.TestCommand", name)
+			measured = append(measured, size)
+		},
+	})
+
+	require.NoError(t, commandBus.Send(context.Background(), &TestCommand{ID: "1"}))
+
+	err := commandBus.Send(context.Background(), &TestCommand{ID: strings.Repeat("a", 100)})
+
+	var tooLarge cqrs.MessageTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	assert.Equal(t, cqrs.MessageTooLargeError{Name: "cqrs_test.TestCommand", Size: 109, MaxSize: 100}, tooLarge)
+
+	assert.Len(t, publisher.messages, 1, "too large message should not be published")
+	assert.Equal(t, []int{10, 109}, measured)
+}
+
+func TestMessageSize_compress(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	commandBus := newSizeLimitedCommandBus(t, publisher, cqrs.MessageSizeConfig{
+		MaxPayloadSize: 100,
+		Action:         cqrs.MessageSizeCompress,
+	})
+
+	cmd := &TestCommand{ID: strings.Repeat("a", 1000)}
+	require.NoError(t, commandBus.Send(context.Background(), cmd))
+
+	require.Len(t, publisher.messages, 1)
+	msg := publisher.messages[0]
+
+	assert.LessOrEqual(t, len(msg.Payload), 100)
+	assert.Equal(t, message.ContentEncodingGzip, message.ContentEncoding(msg))
+
+	var unmarshaled TestCommand
+	require.NoError(t, cqrs.EncodingMarshaler{Marshaler: cqrs.JSONMarshaler{}}.Unmarshal(msg, &unmarshaled))
+	assert.Equal(t, *cmd, unmarshaled)
+
+	err := commandBus.Send(context.Background(), &TestCommand{ID: incompressibleString(1000)})
+	assert.ErrorAs(t, err, &cqrs.MessageTooLargeError{}, "incompressible payload should be rejected")
+}
+
+func TestMessageSize_claim_check(t *testing.T) {
+	publisher := &capturingPublisher{}
+	store := &claimCheckStoreStub{}
+
+	commandBus := newSizeLimitedCommandBus(t, publisher, cqrs.MessageSizeConfig{
+		MaxPayloadSize:  100,
+		Action:          cqrs.MessageSizeClaimCheck,
+		ClaimCheckStore: store,
+	})
+
+	small := &TestCommand{ID: "1"}
+	large := &TestCommand{ID: strings.Repeat("a", 1000)}
+
+	require.NoError(t, commandBus.Send(context.Background(), small))
+	require.NoError(t, commandBus.Send(context.Background(), large))
+
+	require.Len(t, publisher.messages, 2)
+	assert.Empty(t, publisher.messages[0].Metadata.Get(cqrs.ClaimCheckKeyMetadataKey))
+	assert.NotEmpty(t, publisher.messages[1].Metadata.Get(cqrs.ClaimCheckKeyMetadataKey))
+	assert.Empty(t, publisher.messages[1].Payload)
+
+	marshaler := cqrs.ClaimCheckMarshaler{Marshaler: cqrs.JSONMarshaler{}, Store: store}
+
+	for i, expected := range []*TestCommand{small, large} {
+		msg := publisher.messages[i]
+		assert.Equal(t, "cqrs_test.TestCommand", marshaler.NameFromMessage(msg))
+
+		var unmarshaled TestCommand
+		require.NoError(t, marshaler.Unmarshal(msg, &unmarshaled))
+		assert.Equal(t, *expected, unmarshaled)
+	}
+}
+
+func TestMessageSize_checked_after_modify(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	commandBus := newSizeLimitedCommandBus(t, publisher, cqrs.MessageSizeConfig{
+		MaxPayloadSize: 100,
+	})
+
+	err := commandBus.SendWithModifiedMessage(context.Background(), &TestCommand{ID: "1"}, func(msg *message.Message) error {
+		msg.Payload = []byte(strings.Repeat("a", 101))
+		return nil
+	})
+	assert.ErrorAs(t, err, &cqrs.MessageTooLargeError{}, "payload grown by modify should be rejected")
+	assert.Empty(t, publisher.messages)
+}
+
+func TestMessageSize_dry_run(t *testing.T) {
+	store := &claimCheckStoreStub{}
+	sink := cqrs.NewInMemoryDryRunSink()
+
+	eventBus, err := cqrs.NewEventBusWithConfig(&capturingPublisher{}, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		MessageSize: &cqrs.MessageSizeConfig{
+			MaxPayloadSize:  1,
+			Action:          cqrs.MessageSizeClaimCheck,
+			ClaimCheckStore: store,
+		},
+		DryRunSink: sink,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+
+	require.Len(t, sink.Messages(), 1)
+	assert.NotEmpty(t, sink.Messages()[0].Message.Payload)
+	assert.Empty(t, store.payloads, "nothing should be offloaded in the dry-run mode")
+}
+
+func TestMessageSize_payload_size_in_params(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	var payloadSize int
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		OnPublish: func(params cqrs.OnEventSendParams) error {
+			payloadSize = params.PayloadSize
+			return nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+
+	require.Len(t, publisher.messages, 1)
+	assert.Equal(t, len(publisher.messages[0].Payload), payloadSize)
+}
+
+func TestMessageSizeConfig_Validate(t *testing.T) {
+	testCases := []cqrs.MessageSizeConfig{
+		{MaxPayloadSize: -1},
+		{Action: cqrs.MessageSizeClaimCheck},
+		{Action: cqrs.MessageSizeCompress, Encoding: "unknown"},
+		{Action: "drop"},
+	}
+
+	for _, config := range testCases {
+		_, err := cqrs.NewCommandBusWithConfig(&capturingPublisher{}, cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands", nil
+			},
+			Marshaler:   cqrs.JSONMarshaler{},
+			MessageSize: &config,
+		})
+		assert.ErrorContains(t, err, "invalid MessageSize config")
+	}
+}
@@ -0,0 +1,105 @@
+package cqrs
+
+import (
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// StandardMetadataKeys are the metadata keys used by StandardMetadataConfig.
+// Empty key disables setting the value.
+type StandardMetadataKeys struct {
+	ProducedAt         string
+	ProducerName       string
+	ProducerInstanceID string
+	SchemaName         string
+}
+
+// DefaultStandardMetadataKeys are the keys used when StandardMetadataConfig.Keys is not set.
+var DefaultStandardMetadataKeys = StandardMetadataKeys{
+	ProducedAt:         "produced_at",
+	ProducerName:       "producer_name",
+	ProducerInstanceID: "producer_instance_id",
+	SchemaName:         "schema_name",
+}
+
+// StandardMetadataConfig configures the standard metadata set by EventBus and CommandBus on every published message:
+// the time when the message was produced (in RFC3339Nano format), the producer's service name and instance ID,
+// and the schema name (the event or command name returned by Marshaler).
+//
+// Values already present in the message's metadata are not overwritten.
+type StandardMetadataConfig struct {
+	// ProducerName is the name of the service publishing messages.
+	// If empty, the producer name is not set.
+	ProducerName string
+
+	// ProducerInstanceID identifies the instance of the service publishing messages.
+	// If empty, a random ID is generated once, in setDefaults.
+	ProducerInstanceID string
+
+	// Keys are the metadata keys used for the standard metadata.
+	// If not provided, DefaultStandardMetadataKeys are used.
+	Keys *StandardMetadataKeys
+
+	// Clock is used to get the produced_at time.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (c *StandardMetadataConfig) setDefaults() {
+	if c.ProducerInstanceID == "" {
+		c.ProducerInstanceID = watermill.NewShortUUID()
+	}
+	if c.Keys == nil {
+		keys := DefaultStandardMetadataKeys
+		c.Keys = &keys
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+}
+
+func (c StandardMetadataConfig) apply(msg *message.Message, schemaName string) {
+	setIfEmpty := func(key string, value string) {
+		if key == "" || value == "" || msg.Metadata.Get(key) != "" {
+			return
+		}
+		msg.Metadata.Set(key, value)
+	}
+
+	setIfEmpty(c.Keys.ProducedAt, c.Clock.Now().UTC().Format(time.RFC3339Nano))
+	setIfEmpty(c.Keys.ProducerName, c.ProducerName)
+	setIfEmpty(c.Keys.ProducerInstanceID, c.ProducerInstanceID)
+	setIfEmpty(c.Keys.SchemaName, schemaName)
+}
+
+// ProducedAt returns the time when the message was produced, read from the standard metadata.
+// It returns false if the metadata is missing or invalid.
+//
+// It can be used, for example, as bridge.Config.MessageTimestamp to report the lag of messages.
+func (c StandardMetadataConfig) ProducedAt(msg *message.Message) (time.Time, bool) {
+	key := DefaultStandardMetadataKeys.ProducedAt
+	if c.Keys != nil {
+		key = c.Keys.ProducedAt
+	}
+
+	value := msg.Metadata.Get(key)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	producedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return producedAt, true
+}
+
+func applyTTL(msg *message.Message, ttl time.Duration) {
+	if msg.Metadata.Get(message.ExpiresAtMetadataKey) != "" {
+		return
+	}
+	message.SetTTL(msg, ttl)
+}
@@ -0,0 +1,136 @@
+package cqrs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type capturingPublisher struct {
+	messages []*message.Message
+}
+
+func (p *capturingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *capturingPublisher) Close() error {
+	return nil
+}
+
+func TestStandardMetadata(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	standardMetadata := &cqrs.StandardMetadataConfig{
+		ProducerName:       "orders-service",
+		ProducerInstanceID: "instance-1",
+		Clock:              watermill.NewFakeClock(now),
+	}
+
+	publisher := &capturingPublisher{}
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return params.EventName, nil
+		},
+		Marshaler:        cqrs.JSONMarshaler{},
+		StandardMetadata: standardMetadata,
+	})
+	require.NoError(t, err)
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		StandardMetadata: &cqrs.StandardMetadataConfig{
+			ProducerName: "orders-service",
+			Keys: &cqrs.StandardMetadataKeys{
+				ProducedAt:   "x-produced-at",
+				ProducerName: "x-producer",
+			},
+			Clock: watermill.NewFakeClock(now),
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+	require.NoError(t, commandBus.Send(context.Background(), &TestCommand{ID: "1"}))
+
+	require.Len(t, publisher.messages, 2)
+
+	event := publisher.messages[0]
+	assert.Equal(t, "2024-01-02T03:04:05.000000006Z", event.Metadata.Get("produced_at"))
+	assert.Equal(t, "orders-service", event.Metadata.Get("producer_name"))
+	assert.Equal(t, "instance-1", event.Metadata.Get("producer_instance_id"))
+	assert.Equal(t, "cqrs_test.TestEvent", event.Metadata.Get("schema_name"))
+
+	producedAt, ok := standardMetadata.ProducedAt(event)
+	require.True(t, ok)
+	assert.True(t, now.Equal(producedAt))
+
+	command := publisher.messages[1]
+	assert.Equal(t, "2024-01-02T03:04:05.000000006Z", command.Metadata.Get("x-produced-at"))
+	assert.Equal(t, "orders-service", command.Metadata.Get("x-producer"))
+	assert.Empty(t, command.Metadata.Get("producer_instance_id"))
+	assert.Empty(t, command.Metadata.Get("schema_name"))
+}
+
+func TestStandardMetadataConfig_ProducedAt_missing(t *testing.T) {
+	_, ok := cqrs.StandardMetadataConfig{}.ProducedAt(message.NewMessage("1", nil))
+	assert.False(t, ok)
+}
+
+func TestTTL(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return params.EventName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		TTL:       time.Minute,
+	})
+	require.NoError(t, err)
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return params.CommandName, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		TTL:       time.Hour,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+	require.NoError(t, commandBus.Send(context.Background(), &TestCommand{ID: "1"}))
+
+	explicitExpiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = commandBus.SendWithModifiedMessage(context.Background(), &TestCommand{ID: "2"}, func(msg *message.Message) error {
+		message.SetExpiresAt(msg, explicitExpiry)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, publisher.messages, 3)
+
+	expiresAt, ok := message.ExpiresAt(publisher.messages[0])
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Second)
+
+	expiresAt, ok = message.ExpiresAt(publisher.messages[1])
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+
+	expiresAt, ok = message.ExpiresAt(publisher.messages[2])
+	require.True(t, ok)
+	assert.True(t, explicitExpiry.Equal(expiresAt))
+}
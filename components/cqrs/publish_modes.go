@@ -0,0 +1,160 @@
+package cqrs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DryRunSink records messages which would be published by EventBus or CommandBus in dry-run mode.
+type DryRunSink interface {
+	Record(ctx context.Context, topic string, msg *message.Message) error
+}
+
+// DryRunSinkFunc is a function implementing DryRunSink.
+type DryRunSinkFunc func(ctx context.Context, topic string, msg *message.Message) error
+
+func (f DryRunSinkFunc) Record(ctx context.Context, topic string, msg *message.Message) error {
+	return f(ctx, topic, msg)
+}
+
+// RecordedMessage is a message recorded by InMemoryDryRunSink.
+type RecordedMessage struct {
+	Topic   string
+	Message *message.Message
+}
+
+// InMemoryDryRunSink is a DryRunSink keeping the recorded messages in memory.
+type InMemoryDryRunSink struct {
+	messages []RecordedMessage
+	lock     sync.Mutex
+}
+
+// NewInMemoryDryRunSink creates a new InMemoryDryRunSink.
+func NewInMemoryDryRunSink() *InMemoryDryRunSink {
+	return &InMemoryDryRunSink{}
+}
+
+func (s *InMemoryDryRunSink) Record(ctx context.Context, topic string, msg *message.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.messages = append(s.messages, RecordedMessage{Topic: topic, Message: msg})
+
+	return nil
+}
+
+// Messages returns the recorded messages, in the order in which they were recorded.
+func (s *InMemoryDryRunSink) Messages() []RecordedMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	messages := make([]RecordedMessage, len(s.messages))
+	copy(messages, s.messages)
+
+	return messages
+}
+
+// ShadowPublishConfig enables the shadow mode of EventBus or CommandBus:
+// every message is published to its topic and then to the shadow topic.
+// It allows to validate a new topology (for example, new topics or a new Pub/Sub) with the real traffic before cutover.
+//
+// The message is published to the shadow topic only if publishing to its topic succeeded.
+// Unless FailOnError is set, it's published in the background, so the shadow topology doesn't slow down publishing.
+type ShadowPublishConfig struct {
+	// GenerateShadowTopic returns the shadow topic of the topic to which the message is published.
+	// It is required.
+	GenerateShadowTopic func(topic string) (string, error)
+
+	// Publisher is used to publish messages to the shadow topics.
+	// If not provided, the bus's publisher is used.
+	Publisher message.Publisher
+
+	// FailOnError makes publishing wait for the shadow topic and fail when publishing to it fails
+	// (including generating the shadow topic). The message is already published to its topic then.
+	// By default, the error is only logged, so the shadow topology doesn't affect the production traffic.
+	FailOnError bool
+}
+
+func (c ShadowPublishConfig) Validate() error {
+	if c.GenerateShadowTopic == nil {
+		return errors.New("missing GenerateShadowTopic")
+	}
+
+	return nil
+}
+
+func publishWithMode(
+	publisher message.Publisher,
+	topic string,
+	msg *message.Message,
+	dryRunSink DryRunSink,
+	shadow *ShadowPublishConfig,
+	logger watermill.LoggerAdapter,
+) error {
+	if dryRunSink != nil {
+		logger.Debug("Dry-run mode, recording message instead of publishing", watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"topic":        topic,
+		})
+
+		if err := dryRunSink.Record(msg.Context(), topic, msg); err != nil {
+			return errors.Wrap(err, "cannot record message in dry-run sink")
+		}
+		return nil
+	}
+
+	if shadow == nil {
+		return publisher.Publish(topic, msg)
+	}
+
+	// copied before publishing, as the publisher may modify the message
+	shadowMsg := msg.Copy()
+	shadowMsg.SetContext(msg.Context())
+
+	if err := publisher.Publish(topic, msg); err != nil {
+		return err
+	}
+
+	if shadow.FailOnError {
+		return publishToShadowTopic(publisher, topic, shadowMsg, shadow)
+	}
+
+	go func() {
+		if err := publishToShadowTopic(publisher, topic, shadowMsg, shadow); err != nil {
+			logger.Error("Cannot publish message to shadow topic", err, watermill.LogFields{
+				"message_uuid": msg.UUID,
+				"topic":        topic,
+			})
+		}
+	}()
+
+	return nil
+}
+
+func publishToShadowTopic(
+	publisher message.Publisher,
+	topic string,
+	msg *message.Message,
+	shadow *ShadowPublishConfig,
+) error {
+	shadowTopic, err := shadow.GenerateShadowTopic(topic)
+	if err != nil {
+		return errors.Wrap(err, "cannot generate shadow topic")
+	}
+
+	shadowPublisher := shadow.Publisher
+	if shadowPublisher == nil {
+		shadowPublisher = publisher
+	}
+
+	if err := shadowPublisher.Publish(shadowTopic, msg); err != nil {
+		return errors.Wrapf(err, "cannot publish message to shadow topic %s", shadowTopic)
+	}
+
+	return nil
+}
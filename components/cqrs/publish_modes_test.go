@@ -0,0 +1,196 @@
+package cqrs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type topicsPublisher struct {
+	topics []string
+	err    error
+	lock   sync.Mutex
+}
+
+func (p *topicsPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	for range messages {
+		p.topics = append(p.topics, topic)
+	}
+
+	return nil
+}
+
+func (p *topicsPublisher) Close() error {
+	return nil
+}
+
+func (p *topicsPublisher) Topics() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.topics
+}
+
+func TestEventBus_dry_run(t *testing.T) {
+	publisher := &topicsPublisher{}
+	sink := cqrs.NewInMemoryDryRunSink()
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		OnPublish: func(params cqrs.OnEventSendParams) error {
+			params.Message.Metadata.Set("on_publish", "called")
+			return nil
+		},
+		Marshaler:  cqrs.JSONMarshaler{},
+		DryRunSink: sink,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+
+	assert.Empty(t, publisher.Topics())
+
+	recorded := sink.Messages()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, "events", recorded[0].Topic)
+	assert.Equal(t, "called", recorded[0].Message.Metadata.Get("on_publish"))
+	assert.Equal(t, "cqrs_test.TestEvent", cqrs.JSONMarshaler{}.NameFromMessage(recorded[0].Message))
+}
+
+func TestCommandBus_dry_run(t *testing.T) {
+	publisher := &topicsPublisher{}
+	sinkErr := errors.New("sink error")
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		DryRunSink: cqrs.DryRunSinkFunc(func(ctx context.Context, topic string, msg *message.Message) error {
+			return sinkErr
+		}),
+	})
+	require.NoError(t, err)
+
+	err = commandBus.Send(context.Background(), &TestCommand{ID: "1"})
+	assert.ErrorIs(t, err, sinkErr)
+	assert.Empty(t, publisher.Topics())
+}
+
+func TestEventBus_shadow(t *testing.T) {
+	publisher := &topicsPublisher{}
+	shadowPublisher := &topicsPublisher{}
+
+	eventBus, err := cqrs.NewEventBusWithConfig(publisher, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		Shadow: &cqrs.ShadowPublishConfig{
+			GenerateShadowTopic: func(topic string) (string, error) {
+				return "shadow." + topic, nil
+			},
+			Publisher: shadowPublisher,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), &TestEvent{ID: "1"}))
+
+	assert.Equal(t, []string{"events"}, publisher.Topics())
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"shadow.events"}, shadowPublisher.Topics())
+	}, time.Second, time.Millisecond, "message should be published to the shadow topic in the background")
+}
+
+func TestCommandBus_shadow_error(t *testing.T) {
+	shadowErr := errors.New("shadow error")
+
+	newCommandBus := func(publisher message.Publisher, failOnError bool) *cqrs.CommandBus {
+		commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			Shadow: &cqrs.ShadowPublishConfig{
+				GenerateShadowTopic: func(topic string) (string, error) {
+					return "shadow." + topic, nil
+				},
+				Publisher:   &topicsPublisher{err: shadowErr},
+				FailOnError: failOnError,
+			},
+		})
+		require.NoError(t, err)
+
+		return commandBus
+	}
+
+	t.Run("ignored", func(t *testing.T) {
+		publisher := &topicsPublisher{}
+
+		err := newCommandBus(publisher, false).Send(context.Background(), &TestCommand{ID: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"commands"}, publisher.Topics())
+	})
+
+	t.Run("shadow_topic_error_ignored", func(t *testing.T) {
+		publisher := &topicsPublisher{}
+
+		commandBus, err := cqrs.NewCommandBusWithConfig(publisher, cqrs.CommandBusConfig{
+			GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+				return "commands", nil
+			},
+			Marshaler: cqrs.JSONMarshaler{},
+			Shadow: &cqrs.ShadowPublishConfig{
+				GenerateShadowTopic: func(topic string) (string, error) {
+					return "", errors.New("no shadow topic")
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		err = commandBus.Send(context.Background(), &TestCommand{ID: "1"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"commands"}, publisher.Topics(), "message should be published to the primary topic")
+	})
+
+	t.Run("fail_on_error", func(t *testing.T) {
+		publisher := &topicsPublisher{}
+
+		err := newCommandBus(publisher, true).Send(context.Background(), &TestCommand{ID: "1"})
+		assert.ErrorIs(t, err, shadowErr)
+		assert.Equal(t, []string{"commands"}, publisher.Topics(), "message should be published to the primary topic")
+	})
+}
+
+func TestEventBusConfig_Validate_publish_modes(t *testing.T) {
+	config := cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler:  cqrs.JSONMarshaler{},
+		DryRunSink: cqrs.NewInMemoryDryRunSink(),
+		Shadow:     &cqrs.ShadowPublishConfig{},
+	}
+
+	err := config.Validate()
+	assert.ErrorContains(t, err, "DryRunSink and Shadow can't be used together")
+	assert.ErrorContains(t, err, "invalid Shadow config: missing GenerateShadowTopic")
+}
@@ -0,0 +1,124 @@
+package cqrs
+
+import (
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// RouterHandlersDiff describes the changes of the router's handlers made by the processor in the upsert mode
+// (see EventProcessorConfig.RouterHandlersOwner).
+type RouterHandlersDiff struct {
+	// Added are the handlers added to the router.
+	Added []string
+
+	// Kept are the handlers which were already added to the router by a processor with the same owner.
+	Kept []string
+
+	// Removed are the handlers of the owner, which are not registered in the processor anymore.
+	Removed []string
+}
+
+// routerHandlersUpsert tracks the router's handlers registered by the processor in the upsert mode.
+type routerHandlersUpsert struct {
+	owner string
+
+	lock       sync.Mutex
+	router     *message.Router
+	registered map[string]struct{}
+	diff       RouterHandlersDiff
+}
+
+func newRouterHandlersUpsert(owner string) *routerHandlersUpsert {
+	if owner == "" {
+		return nil
+	}
+
+	return &routerHandlersUpsert{
+		owner:      owner,
+		registered: map[string]struct{}{},
+	}
+}
+
+// register registers the router's handler, and returns true if it's already added to the router by the owner,
+// so it should not be added again.
+// It's a no-op returning false, if the upsert mode is disabled.
+func (u *routerHandlersUpsert) register(r *message.Router, handlerName string) (bool, error) {
+	if u == nil {
+		return false, nil
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.router = r
+	u.registered[handlerName] = struct{}{}
+
+	owner, ok := r.HandlerOwner(handlerName)
+	if !ok {
+		return false, nil
+	}
+	if owner != u.owner {
+		return false, errors.Errorf("handler %s is already added to the router, but not by %s", handlerName, u.owner)
+	}
+
+	u.diff.Kept = append(u.diff.Kept, handlerName)
+
+	return true, nil
+}
+
+func (u *routerHandlersUpsert) added(handler *message.Handler, handlerName string) {
+	if u == nil {
+		return
+	}
+
+	handler.SetOwner(u.owner)
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.diff.Added = append(u.diff.Added, handlerName)
+}
+
+// sync removes the owner's handlers, which are not registered, from the router.
+// r is used if no handler was registered yet.
+func (u *routerHandlersUpsert) sync(r *message.Router) (RouterHandlersDiff, error) {
+	if u == nil {
+		return RouterHandlersDiff{}, errors.New("RouterHandlersOwner is not set")
+	}
+
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.router != nil {
+		r = u.router
+	}
+	if r == nil {
+		return RouterHandlersDiff{}, errors.New("missing router")
+	}
+
+	var err error
+	for _, handlerName := range r.HandlersOwnedBy(u.owner) {
+		if _, ok := u.registered[handlerName]; ok {
+			continue
+		}
+
+		if removeErr := r.RemoveHandler(handlerName); removeErr != nil {
+			err = multierror.Append(err, errors.Wrapf(removeErr, "cannot remove handler %s", handlerName))
+			continue
+		}
+
+		u.diff.Removed = append(u.diff.Removed, handlerName)
+	}
+
+	diff := RouterHandlersDiff{
+		Added:   append([]string(nil), u.diff.Added...),
+		Kept:    append([]string(nil), u.diff.Kept...),
+		Removed: append([]string(nil), u.diff.Removed...),
+	}
+
+	return diff, err
+}
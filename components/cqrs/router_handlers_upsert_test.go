@@ -0,0 +1,151 @@
+package cqrs_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func newUpsertEventProcessor(t *testing.T, router *message.Router, handlerNames ...string) *cqrs.EventProcessor {
+	t.Helper()
+
+	processor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return &mockSubscriber{}, nil
+		},
+		Marshaler:           cqrs.JSONMarshaler{},
+		RouterHandlersOwner: "orders",
+	})
+	require.NoError(t, err)
+
+	for _, name := range handlerNames {
+		err := processor.AddHandlers(cqrs.NewEventHandler(name, func(ctx context.Context, event *TestEvent) error {
+			return nil
+		}))
+		require.NoError(t, err)
+	}
+
+	return processor
+}
+
+func routerHandlerNames(router *message.Router) []string {
+	var names []string
+	for name := range router.Handlers() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestEventProcessor_RouterHandlersOwner(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, nil)
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("unrelated", "topic", &mockSubscriber{}, func(msg *message.Message) error {
+		return nil
+	})
+
+	processor := newUpsertEventProcessor(t, router, "a", "b")
+
+	diff, err := processor.SyncRouterHandlers()
+	require.NoError(t, err)
+	assert.Equal(t, cqrs.RouterHandlersDiff{Added: []string{"a", "b"}}, diff)
+
+	// reconstructed, for example, after reloading the configuration
+	processor = newUpsertEventProcessor(t, router, "b", "c")
+
+	diff, err = processor.SyncRouterHandlers()
+	require.NoError(t, err)
+	assert.Equal(t, cqrs.RouterHandlersDiff{Added: []string{"c"}, Kept: []string{"b"}, Removed: []string{"a"}}, diff)
+
+	assert.Equal(t, []string{"b", "c", "unrelated"}, routerHandlerNames(router))
+	assert.Len(t, processor.Handlers(), 2)
+}
+
+func TestEventProcessor_RouterHandlersOwner_conflict(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, nil)
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("a", "topic", &mockSubscriber{}, func(msg *message.Message) error {
+		return nil
+	})
+
+	processor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return &mockSubscriber{}, nil
+		},
+		Marshaler:           cqrs.JSONMarshaler{},
+		RouterHandlersOwner: "orders",
+	})
+	require.NoError(t, err)
+
+	err = processor.AddHandlers(cqrs.NewEventHandler("a", func(ctx context.Context, event *TestEvent) error {
+		return nil
+	}))
+	assert.ErrorContains(t, err, "handler a is already added to the router, but not by orders")
+}
+
+func TestCommandProcessor_RouterHandlersOwner(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, nil)
+	require.NoError(t, err)
+
+	newProcessor := func() *cqrs.CommandProcessor {
+		processor, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return &mockSubscriber{}, nil
+			},
+			Marshaler:           cqrs.JSONMarshaler{},
+			RouterHandlersOwner: "orders",
+		})
+		require.NoError(t, err)
+
+		err = processor.AddHandlers(cqrs.NewCommandHandler("command_handler", func(ctx context.Context, cmd *TestCommand) error {
+			return nil
+		}))
+		require.NoError(t, err)
+
+		return processor
+	}
+
+	newProcessor()
+	diff, err := newProcessor().SyncRouterHandlers()
+	require.NoError(t, err)
+
+	assert.Equal(t, cqrs.RouterHandlersDiff{Kept: []string{"command_handler"}}, diff)
+	assert.Equal(t, []string{"command_handler"}, routerHandlerNames(router))
+}
+
+func TestEventProcessor_SyncRouterHandlers_upsert_disabled(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, nil)
+	require.NoError(t, err)
+
+	processor, err := cqrs.NewEventProcessorWithConfig(router, cqrs.EventProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.EventProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "events", nil
+		},
+		SubscriberConstructor: func(params cqrs.EventProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return &mockSubscriber{}, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+	})
+	require.NoError(t, err)
+
+	_, err = processor.SyncRouterHandlers()
+	assert.ErrorContains(t, err, "RouterHandlersOwner is not set")
+}
@@ -0,0 +1,90 @@
+// Package delay standardizes how the delay of a message is stored in its metadata,
+// and provides Scheduler, which delays messages on Pub/Subs without native delayed delivery.
+package delay
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const (
+	// DelayedUntilKey is the metadata key of the time until which the message is delayed, in RFC 3339 format.
+	DelayedUntilKey = "_watermill_delayed_until"
+
+	// DelayedForKey is the metadata key of the delay of the message, in time.Duration format (for example, "1m30s").
+	// It's informational: DelayedUntilKey is the source of truth.
+	DelayedForKey = "_watermill_delayed_for"
+)
+
+// Delay is the delay of a message.
+// Create it with For or Until.
+type Delay struct {
+	until    time.Time
+	duration time.Duration
+}
+
+// For returns a Delay of the given duration from now.
+func For(duration time.Duration) Delay {
+	return Delay{
+		until:    time.Now().UTC().Add(duration),
+		duration: duration,
+	}
+}
+
+// Until returns a Delay until the given time.
+func Until(until time.Time) Delay {
+	return Delay{
+		until:    until.UTC(),
+		duration: time.Until(until),
+	}
+}
+
+// IsZero returns true if the Delay is not set.
+func (d Delay) IsZero() bool {
+	return d.until.IsZero()
+}
+
+// Until returns the time until which the message is delayed.
+func (d Delay) Until() time.Time {
+	return d.until
+}
+
+// Message sets the delay in the message's metadata.
+func Message(msg *message.Message, delay Delay) {
+	msg.Metadata.Set(DelayedUntilKey, delay.until.Format(time.RFC3339Nano))
+	msg.Metadata.Set(DelayedForKey, delay.duration.String())
+}
+
+// DelayedUntil returns the time until which the message is delayed.
+// It returns false if the message has no delay.
+func DelayedUntil(msg *message.Message) (time.Time, bool, error) {
+	value := msg.Metadata.Get(DelayedUntilKey)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+
+	until, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "invalid %s metadata", DelayedUntilKey)
+	}
+
+	return until, true, nil
+}
+
+type delayContextKey struct{}
+
+// WithContext returns a new context with the delay.
+// Publisher sets the delay of published messages with this context.
+func WithContext(ctx context.Context, delay Delay) context.Context {
+	return context.WithValue(ctx, delayContextKey{}, delay)
+}
+
+// FromContext returns the delay from the context. It's zero if the context has no delay.
+func FromContext(ctx context.Context) Delay {
+	delay, _ := ctx.Value(delayContextKey{}).(Delay)
+	return delay
+}
@@ -0,0 +1,62 @@
+package delay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/delay"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestMessage(t *testing.T) {
+	until := time.Date(2030, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	delay.Message(msg, delay.Until(until))
+
+	delayedUntil, ok, err := delay.DelayedUntil(msg)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, until.Equal(delayedUntil))
+	assert.NotEmpty(t, msg.Metadata.Get(delay.DelayedForKey))
+}
+
+func TestMessage_for(t *testing.T) {
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	delay.Message(msg, delay.For(time.Minute))
+
+	delayedUntil, ok, err := delay.DelayedUntil(msg)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), delayedUntil, time.Second)
+	assert.Equal(t, "1m0s", msg.Metadata.Get(delay.DelayedForKey))
+}
+
+func TestDelayedUntil_no_delay(t *testing.T) {
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+
+	_, ok, err := delay.DelayedUntil(msg)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDelayedUntil_invalid(t *testing.T) {
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	msg.Metadata.Set(delay.DelayedUntilKey, "tomorrow")
+
+	_, _, err := delay.DelayedUntil(msg)
+	assert.ErrorContains(t, err, "invalid _watermill_delayed_until metadata")
+}
+
+func TestWithContext(t *testing.T) {
+	assert.True(t, delay.FromContext(context.Background()).IsZero())
+
+	d := delay.For(time.Second)
+	ctx := delay.WithContext(context.Background(), d)
+	assert.Equal(t, d, delay.FromContext(ctx))
+}
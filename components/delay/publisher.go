@@ -0,0 +1,63 @@
+package delay
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// PublisherConfig holds the Publisher's configuration options.
+type PublisherConfig struct {
+	// DefaultDelay is the delay of messages without a delay in the context or metadata.
+	// If zero, such messages are published without a delay.
+	DefaultDelay time.Duration
+}
+
+// Publisher sets the delay metadata of published messages from their context (see WithContext),
+// or the DefaultDelay. Messages which already have the delay metadata are not changed.
+//
+// It doesn't delay messages on its own: the underlying Pub/Sub should support delayed delivery,
+// or the messages should be published with Scheduler.
+type Publisher struct {
+	pub    message.Publisher
+	config PublisherConfig
+}
+
+// NewPublisher creates a new Publisher.
+func NewPublisher(pub message.Publisher, config PublisherConfig) (*Publisher, error) {
+	if pub == nil {
+		return nil, errors.New("missing publisher")
+	}
+	if config.DefaultDelay < 0 {
+		return nil, errors.New("DefaultDelay must not be negative")
+	}
+
+	return &Publisher{
+		pub:    pub,
+		config: config,
+	}, nil
+}
+
+// Publish sets the delay metadata and publishes the messages with the underlying publisher.
+func (p *Publisher) Publish(topic string, messages ...*message.Message) error {
+	for _, msg := range messages {
+		if msg.Metadata.Get(DelayedUntilKey) != "" {
+			continue
+		}
+
+		if delay := FromContext(msg.Context()); !delay.IsZero() {
+			Message(msg, delay)
+		} else if p.config.DefaultDelay > 0 {
+			Message(msg, For(p.config.DefaultDelay))
+		}
+	}
+
+	return p.pub.Publish(topic, messages...)
+}
+
+// Close closes the underlying publisher.
+func (p *Publisher) Close() error {
+	return p.pub.Close()
+}
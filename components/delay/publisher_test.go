@@ -0,0 +1,75 @@
+package delay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/delay"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type publishedMessages struct {
+	messages []*message.Message
+}
+
+func (p *publishedMessages) Publish(topic string, messages ...*message.Message) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *publishedMessages) Close() error {
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	published := &publishedMessages{}
+
+	pub, err := delay.NewPublisher(published, delay.PublisherConfig{DefaultDelay: time.Minute})
+	require.NoError(t, err)
+
+	fromContext := message.NewMessage(watermill.NewUUID(), nil)
+	fromContext.SetContext(delay.WithContext(context.Background(), delay.For(time.Hour)))
+
+	withMetadata := message.NewMessage(watermill.NewUUID(), nil)
+	metadataUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	delay.Message(withMetadata, delay.Until(metadataUntil))
+
+	withDefault := message.NewMessage(watermill.NewUUID(), nil)
+
+	require.NoError(t, pub.Publish("topic", fromContext, withMetadata, withDefault))
+	require.Len(t, published.messages, 3)
+
+	until, ok, err := delay.DelayedUntil(fromContext)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), until, time.Second)
+
+	until, ok, err = delay.DelayedUntil(withMetadata)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, metadataUntil.Equal(until))
+
+	until, ok, err = delay.DelayedUntil(withDefault)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), until, time.Second)
+}
+
+func TestPublisher_no_default_delay(t *testing.T) {
+	published := &publishedMessages{}
+
+	pub, err := delay.NewPublisher(published, delay.PublisherConfig{})
+	require.NoError(t, err)
+
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	require.NoError(t, pub.Publish("topic", msg))
+
+	_, ok, err := delay.DelayedUntil(msg)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
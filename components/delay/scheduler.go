@@ -0,0 +1,285 @@
+package delay
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// SchedulerConfig holds the Scheduler's configuration options.
+type SchedulerConfig struct {
+	// Store keeps the scheduled messages.
+	// If not provided, NewMemoryStore is used.
+	Store Store
+
+	// MaxWait is the maximum time between checks of the Store for due messages.
+	// Scheduler wakes up earlier when the next message is due, but the Store may be shared with other instances,
+	// which add messages that this instance doesn't know about. Defaults to 1s.
+	//
+	// When publishing of due messages fails, Scheduler waits MaxWait before trying again.
+	MaxWait time.Duration
+
+	// BatchSize is the maximum number of due messages read from the Store at once. Defaults to 100.
+	BatchSize int
+
+	// Clock is used to decide when messages are due.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *SchedulerConfig) setDefaults() {
+	if c.Store == nil {
+		c.Store = NewMemoryStore()
+	}
+	if c.MaxWait == 0 {
+		c.MaxWait = time.Second
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = 100
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c SchedulerConfig) Validate() error {
+	if c.MaxWait < 0 {
+		return errors.New("MaxWait must not be negative")
+	}
+	if c.BatchSize < 0 {
+		return errors.New("BatchSize must not be negative")
+	}
+
+	return nil
+}
+
+// Scheduler is a publisher which delays messages on Pub/Subs without native delayed delivery.
+//
+// Messages delayed to the future (see DelayedUntilKey) are saved in the Store and published
+// with the underlying publisher when they are due, by Run. Other messages are published right away.
+//
+// Messages are removed from the Store after they are published, so a message may be published more than once,
+// if the service is stopped between publishing and removing it.
+type Scheduler struct {
+	pub    message.Publisher
+	config SchedulerConfig
+
+	wakeUp chan struct{}
+
+	running     chan struct{}
+	runningOnce sync.Once
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewScheduler creates a new Scheduler.
+func NewScheduler(pub message.Publisher, config SchedulerConfig) (*Scheduler, error) {
+	if pub == nil {
+		return nil, errors.New("missing publisher")
+	}
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Scheduler{
+		pub:     pub,
+		config:  config,
+		wakeUp:  make(chan struct{}, 1),
+		running: make(chan struct{}),
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}, nil
+}
+
+// Publish schedules delayed messages and publishes the other ones with the underlying publisher.
+func (s *Scheduler) Publish(topic string, messages ...*message.Message) error {
+	now := s.config.Clock.Now()
+
+	var immediate []*message.Message
+
+	for _, msg := range messages {
+		until, ok, err := DelayedUntil(msg)
+		if err != nil {
+			return err
+		}
+		if !ok || !until.After(now) {
+			immediate = append(immediate, msg)
+			continue
+		}
+
+		err = s.config.Store.Add(msg.Context(), ScheduledMessage{
+			ID:        watermill.NewUUID(),
+			Topic:     topic,
+			Message:   msg,
+			PublishAt: until,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cannot schedule message %s", msg.UUID)
+		}
+
+		s.config.Logger.Trace("Message scheduled", watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"topic":        topic,
+			"publish_at":   until,
+		})
+	}
+
+	select {
+	case s.wakeUp <- struct{}{}:
+	default:
+	}
+
+	if len(immediate) == 0 {
+		return nil
+	}
+
+	return s.pub.Publish(topic, immediate...)
+}
+
+// Run publishes the scheduled messages when they are due, until the context is canceled or Close is called.
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer close(s.closed)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	s.runningOnce.Do(func() {
+		close(s.running)
+	})
+
+	for {
+		wakeUp := s.wakeUp
+		var wait time.Duration
+
+		if err := s.publishDue(ctx); err != nil && ctx.Err() == nil {
+			s.config.Logger.Error("Cannot publish scheduled messages", err, nil)
+
+			// the failed messages are still due, so they would be retried right away
+			wakeUp = nil
+			wait = s.config.MaxWait
+		} else {
+			wait = s.nextWait(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wakeUp:
+		case <-s.config.Clock.After(wait):
+		}
+	}
+}
+
+// publishDue publishes all due messages. Messages which fail to publish are kept in the Store,
+// and don't block publishing the other messages of the batch.
+func (s *Scheduler) publishDue(ctx context.Context) error {
+	for {
+		due, err := s.config.Store.Due(ctx, s.config.Clock.Now(), s.config.BatchSize)
+		if err != nil {
+			return errors.Wrap(err, "cannot get due messages")
+		}
+
+		var lastErr error
+		failed := 0
+
+		for _, scheduled := range due {
+			msg := scheduled.Message
+			msg.SetContext(ctx)
+
+			if err := s.publishScheduled(ctx, scheduled); err != nil {
+				s.config.Logger.Error("Cannot publish scheduled message", err, watermill.LogFields{
+					"message_uuid": msg.UUID,
+					"topic":        scheduled.Topic,
+				})
+				lastErr = err
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			// the failed messages would be returned by Due again
+			return errors.Wrapf(lastErr, "cannot publish %d of %d scheduled messages", failed, len(due))
+		}
+		if len(due) < s.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (s *Scheduler) publishScheduled(ctx context.Context, scheduled ScheduledMessage) error {
+	if err := s.pub.Publish(scheduled.Topic, scheduled.Message); err != nil {
+		return errors.Wrapf(err, "cannot publish scheduled message %s", scheduled.Message.UUID)
+	}
+
+	if err := s.config.Store.Remove(ctx, scheduled.ID); err != nil {
+		return errors.Wrapf(err, "cannot remove scheduled message %s", scheduled.Message.UUID)
+	}
+
+	return nil
+}
+
+func (s *Scheduler) nextWait(ctx context.Context) time.Duration {
+	next, ok, err := s.config.Store.Next(ctx)
+	if err != nil {
+		s.config.Logger.Error("Cannot get next scheduled message", err, nil)
+		return s.config.MaxWait
+	}
+	if !ok {
+		return s.config.MaxWait
+	}
+
+	wait := next.Sub(s.config.Clock.Now())
+	if wait < 0 {
+		return 0
+	}
+	if wait > s.config.MaxWait {
+		return s.config.MaxWait
+	}
+
+	return wait
+}
+
+// Running is closed when Scheduler is running.
+func (s *Scheduler) Running() chan struct{} {
+	return s.running
+}
+
+// Close stops Run and closes the underlying publisher. Scheduled messages are kept in the Store.
+func (s *Scheduler) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+
+	select {
+	case <-s.running:
+		<-s.closed
+	default:
+	}
+
+	return s.pub.Close()
+}
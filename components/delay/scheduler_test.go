@@ -0,0 +1,201 @@
+package delay_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/delay"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func runScheduler(t *testing.T, scheduler *delay.Scheduler) {
+	t.Helper()
+
+	go func() {
+		assert.NoError(t, scheduler.Run(context.Background()))
+	}()
+	<-scheduler.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, scheduler.Close())
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(now)
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	store := delay.NewMemoryStore()
+
+	scheduler, err := delay.NewScheduler(pubSub, delay.SchedulerConfig{
+		Store:   store,
+		MaxWait: time.Hour,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	immediate := message.NewMessage("immediate", nil)
+	past := message.NewMessage("past", nil)
+	delay.Message(past, delay.Until(now.Add(-time.Second)))
+	later := message.NewMessage("later", nil)
+	delay.Message(later, delay.Until(now.Add(time.Minute*2)))
+	soon := message.NewMessage("soon", nil)
+	delay.Message(soon, delay.Until(now.Add(time.Minute)))
+
+	require.NoError(t, scheduler.Publish("topic", immediate, past, later, soon))
+	assert.Equal(t, 2, store.Len())
+
+	received, all := subscriber.BulkRead(messages, 2, time.Second)
+	require.True(t, all)
+	assert.ElementsMatch(t, []string{"immediate", "past"}, messageUUIDs(received))
+
+	runScheduler(t, scheduler)
+
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Minute)
+
+	received, all = subscriber.BulkRead(messages, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"soon"}, messageUUIDs(received))
+
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Minute)
+
+	received, all = subscriber.BulkRead(messages, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"later"}, messageUUIDs(received))
+
+	assert.Eventually(t, func() bool {
+		return store.Len() == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestScheduler_wakes_up_on_publish(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(now)
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	scheduler, err := delay.NewScheduler(pubSub, delay.SchedulerConfig{
+		MaxWait: time.Hour,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	runScheduler(t, scheduler)
+
+	clock.BlockUntilWaiters(1)
+
+	msg := message.NewMessage("delayed", nil)
+	delay.Message(msg, delay.Until(now.Add(time.Second)))
+	require.NoError(t, scheduler.Publish("topic", msg))
+
+	// the scheduler should start waiting for the new message instead of MaxWait
+	clock.BlockUntilWaiters(2)
+	clock.Advance(time.Second)
+
+	received, all := subscriber.BulkRead(messages, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"delayed"}, messageUUIDs(received))
+}
+
+type failingPublisher struct {
+	lock      sync.Mutex
+	attempted []string
+}
+
+func (p *failingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, msg := range messages {
+		p.attempted = append(p.attempted, msg.UUID)
+	}
+
+	return errors.New("broker unavailable")
+}
+
+func (p *failingPublisher) Attempted() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string{}, p.attempted...)
+}
+
+func (p *failingPublisher) Close() error {
+	return nil
+}
+
+func TestScheduler_publish_error(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := watermill.NewFakeClock(now)
+
+	publisher := &failingPublisher{}
+	store := delay.NewMemoryStore()
+
+	scheduler, err := delay.NewScheduler(publisher, delay.SchedulerConfig{
+		Store:   store,
+		MaxWait: time.Minute,
+		Clock:   clock,
+	})
+	require.NoError(t, err)
+
+	first := message.NewMessage("first", nil)
+	delay.Message(first, delay.Until(now.Add(time.Second)))
+	second := message.NewMessage("second", nil)
+	delay.Message(second, delay.Until(now.Add(time.Second*2)))
+	require.NoError(t, scheduler.Publish("topic", first, second))
+
+	clock.Advance(time.Second * 2)
+
+	runScheduler(t, scheduler)
+
+	// the failed messages are not retried until MaxWait passes
+	clock.BlockUntilWaiters(1)
+	assert.Equal(t, []string{"first", "second"}, publisher.Attempted(), "failing message should not block the batch")
+
+	clock.Advance(time.Second * 30)
+	assert.Len(t, publisher.Attempted(), 2)
+
+	clock.Advance(time.Second * 30)
+	assert.Eventually(t, func() bool {
+		return len(publisher.Attempted()) == 4
+	}, time.Second, time.Millisecond*10)
+
+	clock.BlockUntilWaiters(1)
+	assert.Len(t, publisher.Attempted(), 4)
+	assert.Equal(t, 2, store.Len(), "failed messages should be kept in the store")
+}
+
+func TestNewScheduler_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := delay.NewScheduler(pubSub, delay.SchedulerConfig{BatchSize: -1})
+	assert.ErrorContains(t, err, "BatchSize must not be negative")
+
+	_, err = delay.NewScheduler(nil, delay.SchedulerConfig{})
+	assert.ErrorContains(t, err, "missing publisher")
+}
+
+func messageUUIDs(messages message.Messages) []string {
+	var uuids []string
+	for _, msg := range messages {
+		uuids = append(uuids, msg.UUID)
+	}
+	return uuids
+}
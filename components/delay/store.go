@@ -0,0 +1,120 @@
+package delay
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ScheduledMessage is a message waiting in Store to be published by Scheduler.
+type ScheduledMessage struct {
+	// ID identifies the scheduled message in the Store.
+	ID string
+
+	Topic   string
+	Message *message.Message
+
+	// PublishAt is the time when the message should be published.
+	PublishAt time.Time
+}
+
+// Store keeps messages scheduled by Scheduler until they are published.
+//
+// Use a persistent implementation (for example, backed by a SQL table) to keep scheduled messages across restarts.
+// All operations must be safe for concurrent use.
+type Store interface {
+	// Add stores the scheduled message.
+	Add(ctx context.Context, msg ScheduledMessage) error
+
+	// Due returns up to limit scheduled messages with PublishAt not later than now, the oldest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]ScheduledMessage, error)
+
+	// Remove removes the scheduled message, after it was published.
+	Remove(ctx context.Context, id string) error
+
+	// Next returns the earliest PublishAt of all scheduled messages. It returns false if there are no messages.
+	Next(ctx context.Context) (time.Time, bool, error)
+}
+
+// MemoryStore is a Store keeping scheduled messages in memory.
+// Scheduled messages are lost when the service is restarted.
+type MemoryStore struct {
+	messages map[string]ScheduledMessage
+	lock     sync.Mutex
+}
+
+// NewMemoryStore creates a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages: map[string]ScheduledMessage{},
+	}
+}
+
+func (s *MemoryStore) Add(ctx context.Context, msg ScheduledMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msg.Message = msg.Message.Copy()
+	s.messages[msg.ID] = msg
+
+	return nil
+}
+
+func (s *MemoryStore) Due(ctx context.Context, now time.Time, limit int) ([]ScheduledMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var due []ScheduledMessage
+	for _, msg := range s.messages {
+		if !msg.PublishAt.After(now) {
+			due = append(due, msg)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].PublishAt.Before(due[j].PublishAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		due[i].Message = due[i].Message.Copy()
+	}
+
+	return due, nil
+}
+
+func (s *MemoryStore) Remove(ctx context.Context, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.messages, id)
+
+	return nil
+}
+
+func (s *MemoryStore) Next(ctx context.Context) (time.Time, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var next time.Time
+	for _, msg := range s.messages {
+		if next.IsZero() || msg.PublishAt.Before(next) {
+			next = msg.PublishAt
+		}
+	}
+
+	return next, !next.IsZero(), nil
+}
+
+// Len returns the number of scheduled messages.
+func (s *MemoryStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.messages)
+}
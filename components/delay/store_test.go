@@ -0,0 +1,53 @@
+package delay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/delay"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := delay.NewMemoryStore()
+
+	_, ok, err := store.Next(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for i, publishAt := range []time.Time{now.Add(time.Second), now.Add(-time.Second), now, now.Add(-time.Minute)} {
+		require.NoError(t, store.Add(ctx, delay.ScheduledMessage{
+			ID:        string(rune('a' + i)),
+			Topic:     "topic",
+			Message:   message.NewMessage(string(rune('a'+i)), nil),
+			PublishAt: publishAt,
+		}))
+	}
+
+	next, ok, err := store.Next(ctx)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(-time.Minute), next)
+
+	due, err := store.Due(ctx, now, 2)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, "d", due[0].ID)
+	assert.Equal(t, "b", due[1].ID)
+
+	require.NoError(t, store.Remove(ctx, "d"))
+	require.NoError(t, store.Remove(ctx, "b"))
+
+	due, err = store.Due(ctx, now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "c", due[0].ID)
+	assert.Equal(t, 2, store.Len())
+}
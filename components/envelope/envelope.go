@@ -0,0 +1,163 @@
+// Package envelope provides Envelope, a view of message.Message with typed headers separated from the body.
+//
+// Headers are encoded in the message's metadata, so they are transported by every Pub/Sub:
+// string headers are stored as they are (so they are readable as plain metadata),
+// other headers are stored in their canonical text encoding:
+//   - int: base 10,
+//   - bytes: standard base64 with padding,
+//   - time: RFC 3339 with nanoseconds, in UTC.
+//
+// Kinds of non-string headers are stored in the KindsMetadataKey metadata, as a JSON object.
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// KindsMetadataKey is the metadata key of the kinds of non-string headers.
+const KindsMetadataKey = "_watermill_envelope_kinds"
+
+// Envelope is a message with typed headers.
+type Envelope struct {
+	UUID    string
+	Headers Headers
+	Body    []byte
+}
+
+// New creates a new Envelope without headers.
+func New(uuid string, body []byte) *Envelope {
+	return &Envelope{
+		UUID:    uuid,
+		Headers: Headers{},
+		Body:    body,
+	}
+}
+
+// FromMessage decodes the Envelope from the message.
+// Metadata without a kind in KindsMetadataKey is decoded as string headers.
+func FromMessage(msg *message.Message) (*Envelope, error) {
+	kinds := map[string]Kind{}
+	if encodedKinds := msg.Metadata.Get(KindsMetadataKey); encodedKinds != "" {
+		if err := json.Unmarshal([]byte(encodedKinds), &kinds); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s metadata", KindsMetadataKey)
+		}
+	}
+
+	e := New(msg.UUID, msg.Payload)
+
+	for key, encoded := range msg.Metadata {
+		if key == KindsMetadataKey {
+			continue
+		}
+
+		value, err := decodeValue(kinds[key], encoded)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid header %s", key)
+		}
+		e.Headers[key] = value
+	}
+
+	return e, nil
+}
+
+// Message encodes the Envelope to a new message.
+func (e *Envelope) Message() (*message.Message, error) {
+	msg := message.NewMessage(e.UUID, e.Body)
+
+	if err := e.Headers.Apply(msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Apply encodes the headers to the message's metadata, keeping other metadata of the message.
+func (h Headers) Apply(msg *message.Message) error {
+	kinds := map[string]Kind{}
+	if encodedKinds := msg.Metadata.Get(KindsMetadataKey); encodedKinds != "" {
+		if err := json.Unmarshal([]byte(encodedKinds), &kinds); err != nil {
+			return errors.Wrapf(err, "invalid %s metadata", KindsMetadataKey)
+		}
+	}
+
+	for key, value := range h {
+		if key == KindsMetadataKey {
+			return errors.Errorf("header %s is reserved", key)
+		}
+
+		encoded, err := encodeValue(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid header %s", key)
+		}
+		msg.Metadata.Set(key, encoded)
+
+		if value.kind == KindString {
+			delete(kinds, key)
+		} else {
+			kinds[key] = value.kind
+		}
+	}
+
+	if len(kinds) == 0 {
+		delete(msg.Metadata, KindsMetadataKey)
+		return nil
+	}
+
+	// encoding/json sorts map keys, so the encoding is stable
+	encodedKinds, err := json.Marshal(kinds)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode header kinds")
+	}
+	msg.Metadata.Set(KindsMetadataKey, string(encodedKinds))
+
+	return nil
+}
+
+func encodeValue(v Value) (string, error) {
+	switch v.kind {
+	case KindString:
+		return v.str, nil
+	case KindInt:
+		return strconv.FormatInt(v.int, 10), nil
+	case KindBytes:
+		return base64.StdEncoding.EncodeToString(v.bytes), nil
+	case KindTime:
+		return v.time.Format(time.RFC3339Nano), nil
+	default:
+		return "", errors.Errorf("unknown kind %q", v.kind)
+	}
+}
+
+func decodeValue(kind Kind, encoded string) (Value, error) {
+	switch kind {
+	case "", KindString:
+		return String(encoded), nil
+	case KindInt:
+		v, err := strconv.ParseInt(encoded, 10, 64)
+		if err != nil {
+			return Value{}, err
+		}
+		return Int(v), nil
+	case KindBytes:
+		v, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return Value{}, err
+		}
+		return Bytes(v), nil
+	case KindTime:
+		v, err := time.Parse(time.RFC3339Nano, encoded)
+		if err != nil {
+			return Value{}, err
+		}
+		return Time(v), nil
+	default:
+		return Value{}, errors.Errorf("unknown kind %q", kind)
+	}
+}
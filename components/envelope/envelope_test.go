@@ -0,0 +1,90 @@
+package envelope_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/envelope"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEnvelope_round_trip(t *testing.T) {
+	sentAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+
+	e := envelope.New("uuid", []byte("body"))
+	e.Headers.Set("tenant", envelope.String("acme"))
+	e.Headers.Set("attempt", envelope.Int(-3))
+	e.Headers.Set("signature", envelope.Bytes([]byte{0, 1, 2, 255}))
+	e.Headers.Set("sent_at", envelope.Time(sentAt))
+
+	msg, err := e.Message()
+	require.NoError(t, err)
+
+	assert.Equal(t, "uuid", msg.UUID)
+	assert.Equal(t, message.Payload("body"), msg.Payload)
+	assert.Equal(t, message.Metadata{
+		"tenant":                  "acme",
+		"attempt":                 "-3",
+		"signature":               "AAEC/w==",
+		"sent_at":                 "2024-01-02T02:04:05.000000006Z",
+		envelope.KindsMetadataKey: `{"attempt":"int","sent_at":"time","signature":"bytes"}`,
+	}, msg.Metadata)
+
+	decoded, err := envelope.FromMessage(msg)
+	require.NoError(t, err)
+
+	assert.Equal(t, e.UUID, decoded.UUID)
+	assert.Equal(t, e.Body, decoded.Body)
+	assert.Equal(t, e.Headers, decoded.Headers)
+
+	gotSentAt, ok := decoded.Headers.GetTime("sent_at")
+	require.True(t, ok)
+	assert.True(t, sentAt.Equal(gotSentAt))
+
+	_, ok = decoded.Headers.GetInt("tenant")
+	assert.False(t, ok, "string header should not be read as int")
+}
+
+func TestFromMessage_plain_metadata(t *testing.T) {
+	msg := message.NewMessage("uuid", nil)
+	msg.Metadata.Set("name", "OrderPlaced")
+
+	e, err := envelope.FromMessage(msg)
+	require.NoError(t, err)
+
+	name, ok := e.Headers.GetString("name")
+	require.True(t, ok)
+	assert.Equal(t, "OrderPlaced", name)
+}
+
+func TestFromMessage_invalid(t *testing.T) {
+	msg := message.NewMessage("uuid", nil)
+	msg.Metadata.Set("attempt", "not a number")
+	msg.Metadata.Set(envelope.KindsMetadataKey, `{"attempt":"int"}`)
+
+	_, err := envelope.FromMessage(msg)
+	assert.ErrorContains(t, err, "invalid header attempt")
+
+	msg.Metadata.Set(envelope.KindsMetadataKey, `{`)
+	_, err = envelope.FromMessage(msg)
+	assert.ErrorContains(t, err, "invalid _watermill_envelope_kinds metadata")
+}
+
+func TestHeaders_Apply(t *testing.T) {
+	msg := message.NewMessage("uuid", nil)
+	msg.Metadata.Set("existing", "value")
+
+	require.NoError(t, envelope.Headers{"attempt": envelope.Int(1)}.Apply(msg))
+	require.NoError(t, envelope.Headers{"attempt": envelope.String("first")}.Apply(msg))
+
+	assert.Equal(t, message.Metadata{
+		"existing": "value",
+		"attempt":  "first",
+	}, msg.Metadata)
+
+	err := envelope.Headers{envelope.KindsMetadataKey: envelope.String("x")}.Apply(msg)
+	assert.ErrorContains(t, err, "is reserved")
+}
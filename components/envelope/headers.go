@@ -0,0 +1,105 @@
+package envelope
+
+import (
+	"time"
+)
+
+// Kind is the type of a header value.
+type Kind string
+
+const (
+	KindString Kind = "string"
+	KindInt    Kind = "int"
+	KindBytes  Kind = "bytes"
+	KindTime   Kind = "time"
+)
+
+// Value is a typed header value.
+// Create it with String, Int, Bytes or Time.
+type Value struct {
+	kind Kind
+
+	str   string
+	int   int64
+	bytes []byte
+	time  time.Time
+}
+
+// String returns a string header value.
+func String(v string) Value {
+	return Value{kind: KindString, str: v}
+}
+
+// Int returns an integer header value.
+func Int(v int64) Value {
+	return Value{kind: KindInt, int: v}
+}
+
+// Bytes returns a binary header value.
+func Bytes(v []byte) Value {
+	return Value{kind: KindBytes, bytes: v}
+}
+
+// Time returns a time header value. The time is kept in UTC, with nanosecond precision.
+func Time(v time.Time) Value {
+	return Value{kind: KindTime, time: v.UTC()}
+}
+
+// Kind returns the type of the value.
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+// AsString returns the string value. It returns false if the value is not a string.
+func (v Value) AsString() (string, bool) {
+	return v.str, v.kind == KindString
+}
+
+// AsInt returns the integer value. It returns false if the value is not an integer.
+func (v Value) AsInt() (int64, bool) {
+	return v.int, v.kind == KindInt
+}
+
+// AsBytes returns the binary value. It returns false if the value is not binary.
+func (v Value) AsBytes() ([]byte, bool) {
+	return v.bytes, v.kind == KindBytes
+}
+
+// AsTime returns the time value. It returns false if the value is not a time.
+func (v Value) AsTime() (time.Time, bool) {
+	return v.time, v.kind == KindTime
+}
+
+// Headers are the typed headers of Envelope.
+type Headers map[string]Value
+
+// Set sets the header.
+func (h Headers) Set(key string, value Value) {
+	h[key] = value
+}
+
+// Get returns the header. It returns false if the header is not set.
+func (h Headers) Get(key string) (Value, bool) {
+	v, ok := h[key]
+	return v, ok
+}
+
+// GetString returns the string header. It returns false if the header is not set or is not a string.
+func (h Headers) GetString(key string) (string, bool) {
+	return h[key].AsString()
+}
+
+// GetInt returns the integer header. It returns false if the header is not set or is not an integer.
+func (h Headers) GetInt(key string) (int64, bool) {
+	return h[key].AsInt()
+}
+
+// GetBytes returns the binary header. It returns false if the header is not set or is not binary.
+func (h Headers) GetBytes(key string) ([]byte, bool) {
+	return h[key].AsBytes()
+}
+
+// GetTime returns the time header. It returns false if the header is not set or is not a time.
+func (h Headers) GetTime(key string) (time.Time, bool) {
+	return h[key].AsTime()
+}
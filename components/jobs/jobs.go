@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// JobTypeMetadataKey is the metadata key containing the job's type.
+const JobTypeMetadataKey = "job_type"
+
+// JobType returns the type name of the Job, for example, "main.SendEmail".
+func JobType[Job any]() string {
+	var job Job
+	return fmt.Sprintf("%T", job)
+}
+
+// DefaultTopic returns the default topic of the Job, used when the topic is not configured.
+func DefaultTopic[Job any]() string {
+	return "jobs." + JobType[Job]()
+}
+
+// PermanentError marks the error returned by the job handler as permanent, so the job is not retried.
+// If the dead letter topic is configured, the job is sent there right away.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps the error with PermanentError.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return PermanentError{Err: err}
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// QueueConfig holds the JobQueue's configuration options.
+type QueueConfig struct {
+	// Topic is the topic to which the jobs are published.
+	// If empty, DefaultTopic is used.
+	Topic string
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+// JobQueue enqueues jobs of the Job type to be processed by a Worker.
+// Jobs are marshaled to JSON.
+type JobQueue[Job any] struct {
+	publisher message.Publisher
+	topic     string
+	logger    watermill.LoggerAdapter
+}
+
+// NewJobQueue creates a new JobQueue.
+func NewJobQueue[Job any](publisher message.Publisher, config QueueConfig) (*JobQueue[Job], error) {
+	if publisher == nil {
+		return nil, errors.New("missing publisher")
+	}
+
+	if config.Topic == "" {
+		config.Topic = DefaultTopic[Job]()
+	}
+	if config.Logger == nil {
+		config.Logger = watermill.NopLogger{}
+	}
+
+	return &JobQueue[Job]{
+		publisher: publisher,
+		topic:     config.Topic,
+		logger:    config.Logger,
+	}, nil
+}
+
+// Enqueue publishes the job. It returns when the job is published, not when it's processed.
+func (q *JobQueue[Job]) Enqueue(ctx context.Context, job Job) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal job")
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.Metadata.Set(JobTypeMetadataKey, JobType[Job]())
+	msg.SetContext(ctx)
+
+	q.logger.Trace("Enqueueing job", watermill.LogFields{
+		"job_uuid": msg.UUID,
+		"topic":    q.topic,
+	})
+
+	if err := q.publisher.Publish(q.topic, msg); err != nil {
+		return errors.Wrap(err, "cannot publish job")
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package jobs_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/jobs"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type SendEmail struct {
+	To string `json:"to"`
+}
+
+func runRouter(t *testing.T, router *message.Router) {
+	t.Helper()
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+}
+
+func TestWorker(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	var lock sync.Mutex
+	var processed []string
+	var running int32
+	var maxRunning int32
+
+	worker, err := jobs.NewWorker[SendEmail](
+		router,
+		pubSub.ConsumerGroupSubscriber("workers"),
+		func(ctx context.Context, job *SendEmail) error {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+
+			for {
+				previous := atomic.LoadInt32(&maxRunning)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond * 20)
+
+			lock.Lock()
+			processed = append(processed, job.To)
+			lock.Unlock()
+
+			return nil
+		},
+		jobs.WorkerConfig{
+			Concurrency: 2,
+			Logger:      logger,
+		},
+	)
+	require.NoError(t, err)
+	assert.Len(t, worker.HandlerNames(), 2)
+
+	runRouter(t, router)
+
+	queue, err := jobs.NewJobQueue[SendEmail](pubSub, jobs.QueueConfig{})
+	require.NoError(t, err)
+
+	for _, to := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		require.NoError(t, queue.Enqueue(context.Background(), SendEmail{To: to}))
+	}
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(processed) == 4
+	}, time.Second, time.Millisecond*10)
+
+	assert.ElementsMatch(t, []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}, processed)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&maxRunning), "jobs should be processed concurrently")
+}
+
+func TestWorker_retries_and_dead_letter(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	deadLetters, err := pubSub.Subscribe(context.Background(), "jobs_dead_letter")
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	attempts := map[string]int{}
+	var lock sync.Mutex
+
+	_, err = jobs.NewWorker[SendEmail](
+		router,
+		pubSub,
+		func(ctx context.Context, job *SendEmail) error {
+			lock.Lock()
+			attempts[job.To]++
+			attempt := attempts[job.To]
+			lock.Unlock()
+
+			switch job.To {
+			case "flaky":
+				if attempt < 3 {
+					return errors.New("temporary error")
+				}
+				return nil
+			case "permanent":
+				return jobs.Permanent(errors.New("invalid address"))
+			case "slow":
+				<-ctx.Done()
+				return ctx.Err()
+			default:
+				return errors.New("always failing")
+			}
+		},
+		jobs.WorkerConfig{
+			Topic:               "emails",
+			MaxRetries:          2,
+			RetryBaseDelay:      time.Millisecond,
+			RetryMaxDelay:       time.Millisecond,
+			JobTimeout:          time.Millisecond * 10,
+			DeadLetterTopic:     "jobs_dead_letter",
+			DeadLetterPublisher: pubSub,
+			Logger:              logger,
+		},
+	)
+	require.NoError(t, err)
+
+	runRouter(t, router)
+
+	queue, err := jobs.NewJobQueue[SendEmail](pubSub, jobs.QueueConfig{Topic: "emails"})
+	require.NoError(t, err)
+
+	for _, to := range []string{"flaky", "permanent", "failing", "slow"} {
+		require.NoError(t, queue.Enqueue(context.Background(), SendEmail{To: to}))
+	}
+
+	received, all := subscriber.BulkRead(deadLetters, 3, time.Second*5)
+	require.True(t, all)
+
+	var deadJobs []string
+	for _, msg := range received {
+		deadJobs = append(deadJobs, string(msg.Payload))
+	}
+	assert.ElementsMatch(t, []string{`{"to":"permanent"}`, `{"to":"failing"}`, `{"to":"slow"}`}, deadJobs)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, map[string]int{
+		"flaky":     3,
+		"permanent": 1,
+		"failing":   3,
+		"slow":      3,
+	}, attempts)
+}
+
+func TestNewWorker_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	_, err = jobs.NewWorker[SendEmail](
+		router,
+		pubSub,
+		func(ctx context.Context, job *SendEmail) error { return nil },
+		jobs.WorkerConfig{DeadLetterTopic: "dead_letter"},
+	)
+	assert.ErrorContains(t, err, "DeadLetterPublisher is required when DeadLetterTopic is set")
+}
+
+func TestDefaultTopic(t *testing.T) {
+	assert.Equal(t, "jobs.jobs_test.SendEmail", jobs.DefaultTopic[SendEmail]())
+}
@@ -0,0 +1,209 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+// Handler processes a single job.
+// Return an error wrapped with Permanent to skip the retries.
+type Handler[Job any] func(ctx context.Context, job *Job) error
+
+// WorkerConfig holds the Worker's configuration options.
+type WorkerConfig struct {
+	// Topic is the topic from which the jobs are consumed.
+	// If empty, DefaultTopic is used.
+	Topic string
+
+	// Concurrency is the number of jobs processed at the same time. Defaults to 1.
+	//
+	// Worker subscribes to Topic Concurrency times, so the subscriber must deliver messages to its subscriptions
+	// as competing consumers (for example, with one consumer group, or gochannel.GoChannel.ConsumerGroupSubscriber).
+	Concurrency int
+
+	// MaxRetries is the number of retries of a failed job, after the first attempt. Disabled if 0.
+	MaxRetries int
+	// RetryBaseDelay is the minimum delay between retries. Defaults to 100ms.
+	RetryBaseDelay time.Duration
+	// RetryMaxDelay is the maximum delay between retries. Defaults to 10s.
+	RetryMaxDelay time.Duration
+
+	// JobTimeout is the maximum duration of a single attempt of processing the job.
+	// When it's exceeded, the context passed to the Handler is canceled. Disabled if 0.
+	JobTimeout time.Duration
+
+	// DeadLetterTopic is the topic to which jobs are published when all retries failed
+	// or the handler returned a permanent error. DeadLetterPublisher is required if it's set.
+	//
+	// If empty, failed jobs are nacked and redelivered by the Pub/Sub.
+	DeadLetterTopic     string
+	DeadLetterPublisher message.Publisher
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *WorkerConfig) setDefaults(topic string) {
+	if c.Topic == "" {
+		c.Topic = topic
+	}
+	if c.Concurrency == 0 {
+		c.Concurrency = 1
+	}
+	if c.RetryBaseDelay == 0 {
+		c.RetryBaseDelay = time.Millisecond * 100
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = time.Second * 10
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c WorkerConfig) Validate() error {
+	var err error
+
+	if c.Concurrency < 0 {
+		err = multierror.Append(err, errors.New("Concurrency must not be negative"))
+	}
+	if c.MaxRetries < 0 {
+		err = multierror.Append(err, errors.New("MaxRetries must not be negative"))
+	}
+	if c.JobTimeout < 0 {
+		err = multierror.Append(err, errors.New("JobTimeout must not be negative"))
+	}
+	if c.DeadLetterTopic != "" && c.DeadLetterPublisher == nil {
+		err = multierror.Append(err, errors.New("DeadLetterPublisher is required when DeadLetterTopic is set"))
+	}
+
+	return err
+}
+
+// Worker consumes jobs of the Job type and processes them with the Handler.
+//
+// It's built from the router's primitives: every attempt runs with the Timeout middleware,
+// failed attempts are retried with middleware.RetryPolicy, and jobs which failed permanently
+// are sent to the dead letter topic with the PoisonQueue middleware.
+type Worker[Job any] struct {
+	config       WorkerConfig
+	handler      Handler[Job]
+	handlerNames []string
+}
+
+// NewWorker creates a new Worker and adds its handlers to the router.
+// Jobs are processed after the router is started.
+func NewWorker[Job any](
+	router *message.Router,
+	subscriber message.Subscriber,
+	handler Handler[Job],
+	config WorkerConfig,
+) (*Worker[Job], error) {
+	if router == nil {
+		return nil, errors.New("missing router")
+	}
+	if subscriber == nil {
+		return nil, errors.New("missing subscriber")
+	}
+	if handler == nil {
+		return nil, errors.New("missing handler")
+	}
+
+	config.setDefaults(DefaultTopic[Job]())
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	w := &Worker[Job]{
+		config:  config,
+		handler: handler,
+	}
+
+	middlewares, err := w.middlewares()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < config.Concurrency; i++ {
+		handlerName := "jobs_worker_" + config.Topic
+		if config.Concurrency > 1 {
+			handlerName = fmt.Sprintf("%s_%d", handlerName, i)
+		}
+
+		router.AddNoPublisherHandler(
+			handlerName,
+			config.Topic,
+			subscriber,
+			w.handleMessage,
+		).AddMiddleware(middlewares...)
+
+		w.handlerNames = append(w.handlerNames, handlerName)
+	}
+
+	return w, nil
+}
+
+// HandlerNames returns the names of the router's handlers added by the Worker.
+func (w *Worker[Job]) HandlerNames() []string {
+	return w.handlerNames
+}
+
+func (w *Worker[Job]) middlewares() ([]message.HandlerMiddleware, error) {
+	var middlewares []message.HandlerMiddleware
+
+	// the first middleware is the outermost one
+	if w.config.DeadLetterTopic != "" {
+		poisonQueue, err := middleware.PoisonQueue(w.config.DeadLetterPublisher, w.config.DeadLetterTopic)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create dead letter middleware")
+		}
+		middlewares = append(middlewares, poisonQueue)
+	}
+
+	if w.config.MaxRetries > 0 {
+		middlewares = append(middlewares, middleware.RetryPolicy{
+			MaxRetries: w.config.MaxRetries,
+			BaseDelay:  w.config.RetryBaseDelay,
+			MaxDelay:   w.config.RetryMaxDelay,
+			IsRetryable: func(err error) bool {
+				return !errors.As(err, &PermanentError{})
+			},
+			Logger: w.config.Logger,
+		}.Middleware)
+	}
+
+	if w.config.JobTimeout > 0 {
+		middlewares = append(middlewares, middleware.Timeout(w.config.JobTimeout))
+	}
+
+	return middlewares, nil
+}
+
+func (w *Worker[Job]) handleMessage(msg *message.Message) error {
+	if jobType := msg.Metadata.Get(JobTypeMetadataKey); jobType != JobType[Job]() {
+		return Permanent(errors.Errorf("unexpected job type %s, expected %s", jobType, JobType[Job]()))
+	}
+
+	job := new(Job)
+	if err := json.Unmarshal(msg.Payload, job); err != nil {
+		return Permanent(errors.Wrap(err, "cannot unmarshal job"))
+	}
+
+	w.config.Logger.Trace("Processing job", watermill.LogFields{
+		"job_uuid": msg.UUID,
+		"topic":    w.config.Topic,
+	})
+
+	return w.handler(msg.Context(), job)
+}
@@ -0,0 +1,561 @@
+// Package jsonschema validates JSON documents against a subset of JSON Schema (draft 2020-12).
+//
+// It has no dependencies, and covers the keywords commonly used in message contracts.
+// Schema implements middleware.SchemaValidator, so it can be used with middleware.SchemaGuard.
+// If you need full JSON Schema support, implement middleware.SchemaValidator with a dedicated library.
+//
+// Supported keywords:
+//   - type (a string or an array of strings),
+//   - enum and const (numbers are compared by value, so 1 and 1.0 are equal),
+//   - properties, required, and additionalProperties (patternProperties are not supported),
+//   - items (only a single schema, as in draft 2020-12),
+//   - minItems and maxItems,
+//   - minLength and maxLength (counted in Unicode code points),
+//   - pattern (with Go's RE2 syntax, not ECMA-262: for example, lookarounds and backreferences are not supported),
+//   - minimum, maximum, exclusiveMinimum, and exclusiveMaximum (only as numbers, as in draft 6 and later),
+//   - allOf, anyOf, oneOf, and not,
+//   - boolean schemas (true and false).
+//
+// Annotations (for example, title, description, default, or format) and unknown keywords are ignored, as the spec requires.
+// Schemas with other validation keywords (for example, $ref, patternProperties, or if) are rejected by Compile,
+// so they don't silently accept invalid documents.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Schema is a compiled JSON Schema. See the package documentation for the supported keywords.
+type Schema struct {
+	types      []string
+	enum       []any
+	constValue *any
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+
+	items    *Schema
+	minItems *int
+	maxItems *int
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *big.Float
+	maximum          *big.Float
+	exclusiveMinimum *big.Float
+	exclusiveMaximum *big.Float
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+// Compile parses the JSON Schema. See the package documentation for the supported keywords.
+func Compile(schema []byte) (*Schema, error) {
+	var raw any
+	if err := unmarshalJSONNumbers(schema, &raw); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal schema")
+	}
+
+	return compile(raw, "$")
+}
+
+// MustCompile works like Compile, but panics on error.
+func MustCompile(schema []byte) *Schema {
+	s, err := Compile(schema)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Validate returns an error listing the violations, if the payload is not valid JSON or doesn't match the schema.
+// It implements middleware.SchemaValidator.
+func (s *Schema) Validate(payload []byte) error {
+	var value any
+	if err := unmarshalJSONNumbers(payload, &value); err != nil {
+		return errors.Wrap(err, "invalid JSON")
+	}
+
+	var violations []string
+	s.validate(value, "$", &violations)
+
+	if len(violations) > 0 {
+		return errors.New(strings.Join(violations, "; "))
+	}
+
+	return nil
+}
+
+func unmarshalJSONNumbers(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after the JSON value")
+	}
+
+	return nil
+}
+
+func compile(raw any, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		// true accepts everything, false rejects everything
+		if b {
+			return &Schema{}, nil
+		}
+		return &Schema{not: &Schema{}}, nil
+	}
+
+	obj, ok := raw.(map[string]any)
+	if !ok {
+		return nil, errors.Errorf("%s: schema must be an object or a boolean", path)
+	}
+
+	for _, keyword := range unsupportedKeywords {
+		if _, ok := obj[keyword]; ok {
+			return nil, errors.Errorf("%s: %s is not supported", path, keyword)
+		}
+	}
+
+	s := &Schema{}
+	var err error
+
+	switch t := obj["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, item := range t {
+			typeName, ok := item.(string)
+			if !ok {
+				return nil, errors.Errorf("%s: type must be a string or an array of strings", path)
+			}
+			s.types = append(s.types, typeName)
+		}
+	default:
+		return nil, errors.Errorf("%s: type must be a string or an array of strings", path)
+	}
+	for _, typeName := range s.types {
+		if !validTypes[typeName] {
+			return nil, errors.Errorf("%s: unknown type %s", path, typeName)
+		}
+	}
+
+	if enum, ok := obj["enum"]; ok {
+		values, ok := enum.([]any)
+		if !ok {
+			return nil, errors.Errorf("%s: enum must be an array", path)
+		}
+		s.enum = values
+	}
+	if constValue, ok := obj["const"]; ok {
+		s.constValue = &constValue
+	}
+
+	if properties, ok := obj["properties"]; ok {
+		propertiesObj, ok := properties.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("%s: properties must be an object", path)
+		}
+
+		s.properties = map[string]*Schema{}
+		for name, propertySchema := range propertiesObj {
+			s.properties[name], err = compile(propertySchema, path+"."+name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if required, ok := obj["required"]; ok {
+		requiredList, ok := required.([]any)
+		if !ok {
+			return nil, errors.Errorf("%s: required must be an array of strings", path)
+		}
+		for _, item := range requiredList {
+			name, ok := item.(string)
+			if !ok {
+				return nil, errors.Errorf("%s: required must be an array of strings", path)
+			}
+			s.required = append(s.required, name)
+		}
+	}
+
+	switch additional := obj["additionalProperties"].(type) {
+	case nil:
+	case bool:
+		s.noAdditional = !additional
+	default:
+		s.additionalProperties, err = compile(additional, path+".additionalProperties")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if items, ok := obj["items"]; ok {
+		s.items, err = compile(items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, target := range map[string]**int{
+		"minItems":  &s.minItems,
+		"maxItems":  &s.maxItems,
+		"minLength": &s.minLength,
+		"maxLength": &s.maxLength,
+	} {
+		if *target, err = schemaInt(obj, keyword, path); err != nil {
+			return nil, err
+		}
+	}
+
+	for keyword, target := range map[string]**big.Float{
+		"minimum":          &s.minimum,
+		"maximum":          &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum,
+		"exclusiveMaximum": &s.exclusiveMaximum,
+	} {
+		if *target, err = schemaNumber(obj, keyword, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if pattern, ok := obj["pattern"]; ok {
+		patternStr, ok := pattern.(string)
+		if !ok {
+			return nil, errors.Errorf("%s: pattern must be a string", path)
+		}
+		s.pattern, err = regexp.Compile(patternStr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: invalid pattern", path)
+		}
+	}
+
+	for keyword, target := range map[string]*[]*Schema{
+		"allOf": &s.allOf,
+		"anyOf": &s.anyOf,
+		"oneOf": &s.oneOf,
+	} {
+		subschemas, ok := obj[keyword]
+		if !ok {
+			continue
+		}
+
+		subschemasList, ok := subschemas.([]any)
+		if !ok {
+			return nil, errors.Errorf("%s: %s must be an array", path, keyword)
+		}
+		for i, subschema := range subschemasList {
+			compiled, err := compile(subschema, fmt.Sprintf("%s.%s[%d]", path, keyword, i))
+			if err != nil {
+				return nil, err
+			}
+			*target = append(*target, compiled)
+		}
+	}
+
+	if not, ok := obj["not"]; ok {
+		s.not, err = compile(not, path+".not")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// unsupportedKeywords are the validation keywords of draft 2020-12 (and the earlier drafts) that are not supported.
+var unsupportedKeywords = []string{
+	"$ref", "$dynamicRef", "$recursiveRef",
+	"patternProperties", "propertyNames", "minProperties", "maxProperties",
+	"dependentRequired", "dependentSchemas", "dependencies", "unevaluatedProperties",
+	"prefixItems", "additionalItems", "contains", "minContains", "maxContains", "uniqueItems", "unevaluatedItems",
+	"multipleOf", "if", "then", "else",
+}
+
+var validTypes = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"number":  true,
+	"string":  true,
+	"integer": true,
+}
+
+func schemaInt(obj map[string]any, keyword string, path string) (*int, error) {
+	raw, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	number, ok := raw.(json.Number)
+	if !ok {
+		return nil, errors.Errorf("%s: %s must be a non-negative integer", path, keyword)
+	}
+	// integers with a zero fractional part (for example, 2.0) are integers as well
+	f, ok := new(big.Float).SetString(number.String())
+	if !ok || !f.IsInt() || f.Sign() < 0 {
+		return nil, errors.Errorf("%s: %s must be a non-negative integer", path, keyword)
+	}
+
+	value, accuracy := f.Int64()
+	if accuracy != big.Exact {
+		return nil, errors.Errorf("%s: %s is too big", path, keyword)
+	}
+	i := int(value)
+	return &i, nil
+}
+
+func schemaNumber(obj map[string]any, keyword string, path string) (*big.Float, error) {
+	raw, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+
+	number, ok := raw.(json.Number)
+	if !ok {
+		return nil, errors.Errorf("%s: %s must be a number", path, keyword)
+	}
+
+	f, ok := new(big.Float).SetString(number.String())
+	if !ok {
+		return nil, errors.Errorf("%s: %s must be a number", path, keyword)
+	}
+
+	return f, nil
+}
+
+func (s *Schema) validate(value any, path string, violations *[]string) {
+	addViolation := func(format string, args ...any) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.types) > 0 && !s.matchesType(value) {
+		addViolation("expected %s, got %s", strings.Join(s.types, " or "), jsonTypeName(value))
+		// other keywords would report confusing errors for a value of the wrong type
+		return
+	}
+
+	if s.enum != nil && !containsJSONValue(s.enum, value) {
+		addViolation("value is not one of the allowed values")
+	}
+	if s.constValue != nil && !jsonValuesEqual(*s.constValue, value) {
+		addViolation("value is not equal to the constant")
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(v, path, violations)
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			addViolation("expected at least %d items, got %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			addViolation("expected at most %d items, got %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			addViolation("expected at least %d characters, got %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			addViolation("expected at most %d characters, got %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			addViolation("value doesn't match pattern %s", s.pattern)
+		}
+	case json.Number:
+		s.validateNumber(v, addViolation)
+	}
+
+	for _, subschema := range s.allOf {
+		subschema.validate(value, path, violations)
+	}
+	if len(s.anyOf) > 0 && countMatching(s.anyOf, value) == 0 {
+		addViolation("value doesn't match any of the schemas (anyOf)")
+	}
+	if len(s.oneOf) > 0 {
+		if matching := countMatching(s.oneOf, value); matching != 1 {
+			addViolation("value must match exactly one schema (oneOf), matches %d", matching)
+		}
+	}
+	if s.not != nil && countMatching([]*Schema{s.not}, value) == 1 {
+		addViolation("value must not match the schema (not)")
+	}
+}
+
+func (s *Schema) validateObject(obj map[string]any, path string, violations *[]string) {
+	for _, name := range s.required {
+		if _, ok := obj[name]; !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: missing required property %s", path, name))
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	// sorted to keep the errors deterministic
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyPath := path + "." + name
+
+		if propertySchema, ok := s.properties[name]; ok {
+			propertySchema.validate(obj[name], propertyPath, violations)
+			continue
+		}
+
+		if s.noAdditional {
+			*violations = append(*violations, fmt.Sprintf("%s: additional property is not allowed", propertyPath))
+		} else if s.additionalProperties != nil {
+			s.additionalProperties.validate(obj[name], propertyPath, violations)
+		}
+	}
+}
+
+func (s *Schema) validateNumber(number json.Number, addViolation func(format string, args ...any)) {
+	value, ok := new(big.Float).SetString(number.String())
+	if !ok {
+		addViolation("invalid number %s", number)
+		return
+	}
+
+	if s.minimum != nil && value.Cmp(s.minimum) < 0 {
+		addViolation("expected at least %s, got %s", s.minimum.String(), number)
+	}
+	if s.maximum != nil && value.Cmp(s.maximum) > 0 {
+		addViolation("expected at most %s, got %s", s.maximum.String(), number)
+	}
+	if s.exclusiveMinimum != nil && value.Cmp(s.exclusiveMinimum) <= 0 {
+		addViolation("expected more than %s, got %s", s.exclusiveMinimum.String(), number)
+	}
+	if s.exclusiveMaximum != nil && value.Cmp(s.exclusiveMaximum) >= 0 {
+		addViolation("expected less than %s, got %s", s.exclusiveMaximum.String(), number)
+	}
+}
+
+func (s *Schema) matchesType(value any) bool {
+	valueType := jsonTypeName(value)
+
+	for _, t := range s.types {
+		if t == valueType {
+			return true
+		}
+		if t == "number" && valueType == "integer" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func countMatching(schemas []*Schema, value any) int {
+	matching := 0
+	for _, schema := range schemas {
+		var violations []string
+		schema.validate(value, "$", &violations)
+		if len(violations) == 0 {
+			matching++
+		}
+	}
+
+	return matching
+}
+
+func jsonTypeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if f, ok := new(big.Float).SetString(v.String()); ok && f.IsInt() {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsJSONValue(values []any, value any) bool {
+	for _, v := range values {
+		if jsonValuesEqual(v, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonValuesEqual compares the JSON values, comparing numbers by value, also in arrays and objects.
+func jsonValuesEqual(a, b any) bool {
+	switch aValue := a.(type) {
+	case json.Number:
+		bNumber, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		aFloat, aOk := new(big.Float).SetString(aValue.String())
+		bFloat, bOk := new(big.Float).SetString(bNumber.String())
+		return aOk && bOk && aFloat.Cmp(bFloat) == 0
+	case []any:
+		bArray, ok := b.([]any)
+		if !ok || len(aValue) != len(bArray) {
+			return false
+		}
+		for i := range aValue {
+			if !jsonValuesEqual(aValue[i], bArray[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		bObject, ok := b.(map[string]any)
+		if !ok || len(aValue) != len(bObject) {
+			return false
+		}
+		for key, value := range aValue {
+			bValue, ok := bObject[key]
+			if !ok || !jsonValuesEqual(value, bValue) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,220 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/jsonschema"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+var _ middleware.SchemaValidator = (*jsonschema.Schema)(nil)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "amount", "items"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "string", "pattern": "^ord_[0-9]+$"},
+		"amount": {"type": "number", "exclusiveMinimum": 0, "maximum": 1000},
+		"currency": {"enum": ["EUR", "USD"]},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["sku"],
+				"properties": {
+					"sku": {"type": "string", "minLength": 3},
+					"quantity": {"type": "integer", "minimum": 1}
+				}
+			}
+		},
+		"note": {"type": ["string", "null"], "maxLength": 5}
+	}
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := jsonschema.Compile([]byte(orderSchema))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Name          string
+		Payload       string
+		ExpectedError string
+	}{
+		{
+			Name:    "valid",
+			Payload: `{"id": "ord_1", "amount": 10.5, "currency": "EUR", "items": [{"sku": "abc", "quantity": 2}], "note": null}`,
+		},
+		{
+			Name:          "invalid_json",
+			Payload:       `{"id": `,
+			ExpectedError: "invalid JSON: unexpected EOF",
+		},
+		{
+			Name:          "wrong_root_type",
+			Payload:       `[]`,
+			ExpectedError: "$: expected object, got array",
+		},
+		{
+			Name:          "missing_required",
+			Payload:       `{"id": "ord_1", "amount": 1}`,
+			ExpectedError: "$: missing required property items",
+		},
+		{
+			Name:          "additional_property",
+			Payload:       `{"id": "ord_1", "amount": 1, "items": [{"sku": "abc"}], "unknown": 1}`,
+			ExpectedError: "$.unknown: additional property is not allowed",
+		},
+		{
+			Name:    "multiple_violations",
+			Payload: `{"id": "1", "amount": 0, "currency": "PLN", "items": [{"sku": "a", "quantity": 1.5}], "note": "too long"}`,
+			ExpectedError: "$.amount: expected more than 0, got 0; " +
+				"$.currency: value is not one of the allowed values; " +
+				"$.id: value doesn't match pattern ^ord_[0-9]+$; " +
+				"$.items[0].quantity: expected integer, got number; " +
+				"$.items[0].sku: expected at least 3 characters, got 1; " +
+				"$.note: expected at most 5 characters, got 8",
+		},
+		{
+			Name:          "empty_array",
+			Payload:       `{"id": "ord_1", "amount": 1001, "items": []}`,
+			ExpectedError: "$.amount: expected at most 1000, got 1001; $.items: expected at least 1 items, got 0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := schema.Validate([]byte(tc.Payload))
+			if tc.ExpectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestSchema_Validate_combinators(t *testing.T) {
+	schema := jsonschema.MustCompile([]byte(`{
+		"oneOf": [
+			{"type": "integer"},
+			{"type": "string", "const": "none"}
+		],
+		"not": {"const": 13}
+	}`))
+
+	assert.NoError(t, schema.Validate([]byte(`7`)))
+	assert.NoError(t, schema.Validate([]byte(`"none"`)))
+	assert.EqualError(t, schema.Validate([]byte(`"some"`)), "$: value must match exactly one schema (oneOf), matches 0")
+	assert.EqualError(t, schema.Validate([]byte(`13`)), "$: value must not match the schema (not)")
+
+	anyOf := jsonschema.MustCompile([]byte(`{"anyOf": [{"minimum": 10}, {"maximum": 0}]}`))
+	assert.NoError(t, anyOf.Validate([]byte(`11`)))
+	assert.EqualError(t, anyOf.Validate([]byte(`5`)), "$: value doesn't match any of the schemas (anyOf)")
+}
+
+func TestCompile_invalid(t *testing.T) {
+	_, err := jsonschema.Compile([]byte(`{"properties": {"a": {"$ref": "#/definitions/a"}}}`))
+	assert.EqualError(t, err, "$.a: $ref is not supported")
+
+	_, err = jsonschema.Compile([]byte(`{"pattern": "("}`))
+	assert.ErrorContains(t, err, "$: invalid pattern")
+
+	_, err = jsonschema.Compile([]byte(`"string"`))
+	assert.EqualError(t, err, "$: schema must be an object or a boolean")
+
+	_, err = jsonschema.Compile([]byte(`{"type": "object", "patternProperties": {"^a": {}}}`))
+	assert.EqualError(t, err, "$: patternProperties is not supported")
+
+	_, err = jsonschema.Compile([]byte(`{"type": "float"}`))
+	assert.EqualError(t, err, "$: unknown type float")
+
+	_, err = jsonschema.Compile([]byte(`{"minItems": -1}`))
+	assert.EqualError(t, err, "$: minItems must be a non-negative integer")
+}
+
+// suiteTestGroup is a group of test cases in the format of the JSON-Schema-Test-Suite
+// (https://github.com/json-schema-org/JSON-Schema-Test-Suite).
+type suiteTestGroup struct {
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Tests       []struct {
+		Description string          `json:"description"`
+		Data        json.RawMessage `json:"data"`
+		Valid       bool            `json:"valid"`
+	} `json:"tests"`
+}
+
+// suiteKeywords are the test files of the JSON-Schema-Test-Suite covering the supported keywords.
+var suiteKeywords = []string{
+	"type", "enum", "const",
+	"properties", "required", "additionalProperties",
+	"items", "minItems", "maxItems",
+	"minLength", "maxLength", "pattern",
+	"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"allOf", "anyOf", "oneOf", "not",
+	"boolean_schema",
+}
+
+func TestSchema_test_suite(t *testing.T) {
+	for _, keyword := range suiteKeywords {
+		keyword := keyword
+		t.Run(keyword, func(t *testing.T) {
+			runSuiteFile(t, filepath.Join("testdata", keyword+".json"))
+		})
+	}
+}
+
+// TestSchema_official_test_suite runs the test cases of the supported keywords from a checkout of
+// the JSON-Schema-Test-Suite, set with the JSON_SCHEMA_TEST_SUITE environment variable.
+// Test groups using unsupported keywords are skipped.
+func TestSchema_official_test_suite(t *testing.T) {
+	suiteDir := os.Getenv("JSON_SCHEMA_TEST_SUITE")
+	if suiteDir == "" {
+		t.Skip("JSON_SCHEMA_TEST_SUITE is not set")
+	}
+
+	for _, keyword := range suiteKeywords {
+		keyword := keyword
+		t.Run(keyword, func(t *testing.T) {
+			runSuiteFile(t, filepath.Join(suiteDir, "tests", "draft2020-12", keyword+".json"))
+		})
+	}
+}
+
+func runSuiteFile(t *testing.T, path string) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var groups []suiteTestGroup
+	require.NoError(t, json.Unmarshal(data, &groups))
+	require.NotEmpty(t, groups)
+
+	for _, group := range groups {
+		group := group
+		t.Run(group.Description, func(t *testing.T) {
+			schema, err := jsonschema.Compile(group.Schema)
+			if err != nil && strings.HasSuffix(err.Error(), "is not supported") {
+				t.Skip(err.Error())
+			}
+			require.NoError(t, err)
+
+			for _, test := range group.Tests {
+				err := schema.Validate(test.Data)
+				if test.Valid {
+					assert.NoError(t, err, test.Description)
+				} else {
+					assert.Error(t, err, test.Description)
+				}
+			}
+		})
+	}
+}
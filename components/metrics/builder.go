@@ -22,6 +22,45 @@ type PrometheusMetricsBuilder struct {
 
 	Namespace string
 	Subsystem string
+
+	// CustomLabelKeys are the keys of custom labels added to all metrics, for example, "tenant" or "message_type".
+	// Values of the labels are returned by CustomLabels.
+	//
+	// Keep in mind that every distinct value of the label creates a new time series,
+	// so the labels must not have unbounded values (like message UUIDs).
+	CustomLabelKeys []string
+
+	// CustomLabels extracts the values of CustomLabelKeys from the message. It's optional.
+	// For the publisher metrics, labels are extracted from the first published message.
+	CustomLabels MessageLabelsFn
+
+	// Exemplar extracts the exemplar (for example, the trace ID) attached to histogram and counter observations.
+	// It's optional. Exemplars are exposed only in the OpenMetrics format (see promhttp.HandlerOpts.EnableOpenMetrics).
+	Exemplar MessageExemplarFn
+}
+
+func (b PrometheusMetricsBuilder) labeler() (messageLabeler, error) {
+	builtinKeys := map[string]struct{}{
+		labelKeyHandlerName:    {},
+		labelKeyPublisherName:  {},
+		labelKeySubscriberName: {},
+		labelKeySender:         {},
+		labelKeyMessageName:    {},
+		labelSuccess:           {},
+		labelAcked:             {},
+	}
+
+	for _, key := range b.CustomLabelKeys {
+		if _, ok := builtinKeys[key]; ok {
+			return messageLabeler{}, errors.Errorf("custom label %s conflicts with a built-in label", key)
+		}
+	}
+
+	return messageLabeler{
+		keys:     b.CustomLabelKeys,
+		labels:   b.CustomLabels,
+		exemplar: b.Exemplar,
+	}, nil
 }
 
 // AddPrometheusRouterMetrics is a convenience function that acts on the message router to add the metrics middleware
@@ -34,10 +73,15 @@ func (b PrometheusMetricsBuilder) AddPrometheusRouterMetrics(r *message.Router)
 
 // DecoratePublisher wraps the underlying publisher with Prometheus metrics.
 func (b PrometheusMetricsBuilder) DecoratePublisher(pub message.Publisher) (message.Publisher, error) {
-	var err error
+	labeler, err := b.labeler()
+	if err != nil {
+		return nil, err
+	}
+
 	d := PublisherPrometheusMetricsDecorator{
 		pub:           pub,
 		publisherName: internal.StructName(pub),
+		labeler:       labeler,
 	}
 
 	d.publishTimeSeconds, err = b.registerHistogramVec(prometheus.NewHistogramVec(
@@ -47,7 +91,7 @@ func (b PrometheusMetricsBuilder) DecoratePublisher(pub message.Publisher) (mess
 			Name:      "publish_time_seconds",
 			Help:      "The time that a publishing attempt (success or not) took in seconds",
 		},
-		publisherLabelKeys,
+		labeler.labelKeys(publisherLabelKeys...),
 	))
 	if err != nil {
 		return nil, errors.Wrap(err, "could not register publish time metric")
@@ -57,10 +101,15 @@ func (b PrometheusMetricsBuilder) DecoratePublisher(pub message.Publisher) (mess
 
 // DecorateSubscriber wraps the underlying subscriber with Prometheus metrics.
 func (b PrometheusMetricsBuilder) DecorateSubscriber(sub message.Subscriber) (message.Subscriber, error) {
-	var err error
+	labeler, err := b.labeler()
+	if err != nil {
+		return nil, err
+	}
+
 	d := &SubscriberPrometheusMetricsDecorator{
 		closing:        make(chan struct{}),
 		subscriberName: internal.StructName(sub),
+		labeler:        labeler,
 	}
 
 	d.subscriberMessagesReceivedTotal, err = b.registerCounterVec(prometheus.NewCounterVec(
@@ -70,12 +119,38 @@ func (b PrometheusMetricsBuilder) DecorateSubscriber(sub message.Subscriber) (me
 			Name:      "subscriber_messages_received_total",
 			Help:      "The total number of messages received by the subscriber",
 		},
-		append(subscriberLabelKeys, labelAcked),
+		labeler.labelKeys(append(subscriberLabelKeys, labelAcked)...),
 	))
 	if err != nil {
 		return nil, errors.Wrap(err, "could not register time to ack metric")
 	}
 
+	d.subscriberAckLatencySeconds, err = b.registerHistogramVec(prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "subscriber_ack_latency_seconds",
+			Help:      "The time between receiving the message and acking or nacking it in seconds",
+		},
+		labeler.labelKeys(append(subscriberLabelKeys, labelAcked)...),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not register ack latency metric")
+	}
+
+	d.subscriberMessagesUnacked, err = b.registerGaugeVec(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "subscriber_messages_unacked",
+			Help:      "The number of messages received by the subscriber that are not acked or nacked yet",
+		},
+		labeler.labelKeys(subscriberLabelKeys...),
+	))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not register unacked messages metric")
+	}
+
 	d.Subscriber, err = message.MessageTransformSubscriberDecorator(d.recordMetrics)(sub)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not decorate subscriber with metrics decorator")
@@ -105,6 +180,14 @@ func (b PrometheusMetricsBuilder) registerCounterVec(c *prometheus.CounterVec) (
 	return col.(*prometheus.CounterVec), nil
 }
 
+func (b PrometheusMetricsBuilder) registerGaugeVec(g *prometheus.GaugeVec) (*prometheus.GaugeVec, error) {
+	col, err := b.register(g)
+	if err != nil {
+		return nil, err
+	}
+	return col.(*prometheus.GaugeVec), nil
+}
+
 func (b PrometheusMetricsBuilder) registerHistogramVec(h *prometheus.HistogramVec) (*prometheus.HistogramVec, error) {
 	col, err := b.register(h)
 	if err != nil {
@@ -0,0 +1,172 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func gaugeValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+		var value float64
+		for _, m := range family.GetMetric() {
+			value += m.GetGauge().GetValue()
+		}
+		return value
+	}
+
+	return 0
+}
+
+func TestHandlerPrometheusMetricsMiddleware_in_flight(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	handlerStarted := make(chan struct{})
+	releaseHandler := make(chan struct{})
+
+	h := builder.NewRouterMiddleware().Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		close(handlerStarted)
+		<-releaseHandler
+		return nil, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		_, err := h(message.NewMessage("1", nil))
+		assert.NoError(t, err)
+		close(done)
+	}()
+
+	<-handlerStarted
+	assert.Equal(t, float64(1), gaugeValue(t, registry, "handler_messages_in_flight"))
+
+	close(releaseHandler)
+	<-done
+	assert.Equal(t, float64(0), gaugeValue(t, registry, "handler_messages_in_flight"))
+}
+
+func TestSubscriberPrometheusMetricsDecorator_unacked_and_ack_latency(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	sub, err := builder.DecorateSubscriber(pubSub)
+	require.NoError(t, err)
+
+	messages, err := sub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	}()
+
+	msg := <-messages
+
+	assert.Eventually(t, func() bool {
+		return gaugeValue(t, registry, "subscriber_messages_unacked") == 1
+	}, time.Second, time.Millisecond*10)
+
+	msg.Ack()
+
+	assert.Eventually(t, func() bool {
+		return gaugeValue(t, registry, "subscriber_messages_unacked") == 0
+	}, time.Second, time.Millisecond*10)
+
+	assert.Eventually(t, func() bool {
+		count, err := testutil.GatherAndCount(registry, "subscriber_ack_latency_seconds")
+		return err == nil && count == 1
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestPrometheusMetricsBuilder_custom_labels_and_exemplar(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+	builder.CustomLabelKeys = []string{"tenant", "priority"}
+	builder.CustomLabels = func(msg *message.Message) map[string]string {
+		return map[string]string{
+			"tenant": msg.Metadata.Get("tenant"),
+			// keys not listed in CustomLabelKeys are ignored
+			"unknown": "value",
+		}
+	}
+	builder.Exemplar = func(msg *message.Message) prometheus.Labels {
+		return prometheus.Labels{"trace_id": msg.Metadata.Get("trace_id")}
+	}
+
+	h := builder.NewRouterMiddleware().Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	msg.Metadata.Set("tenant", "acme")
+	msg.Metadata.Set("trace_id", "abc123")
+
+	_, err := h(msg)
+	require.NoError(t, err)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	var found bool
+	for _, family := range families {
+		if family.GetName() != "handler_execution_time_seconds" {
+			continue
+		}
+		found = true
+
+		require.Len(t, family.GetMetric(), 1)
+		metric := family.GetMetric()[0]
+
+		labels := map[string]string{}
+		for _, label := range metric.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		assert.Equal(t, map[string]string{
+			"handler_name": "",
+			"success":      "true",
+			"tenant":       "acme",
+			"priority":     "",
+		}, labels)
+
+		var exemplarLabels []string
+		for _, bucket := range metric.GetHistogram().GetBucket() {
+			for _, label := range bucket.GetExemplar().GetLabel() {
+				exemplarLabels = append(exemplarLabels, label.GetName()+"="+label.GetValue())
+			}
+		}
+		assert.Equal(t, []string{"trace_id=abc123"}, exemplarLabels)
+	}
+	assert.True(t, found)
+}
+
+func TestPrometheusMetricsBuilder_custom_label_conflict(t *testing.T) {
+	builder := metrics.NewPrometheusMetricsBuilder(prometheus.NewRegistry(), "", "")
+	builder.CustomLabelKeys = []string{"handler_name"}
+
+	_, err := builder.DecoratePublisher(gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}))
+	assert.EqualError(t, err, "custom label handler_name conflicts with a built-in label")
+}
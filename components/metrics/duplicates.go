@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+var duplicateDeliveriesLabelKeys = []string{
+	labelKeyHandlerName,
+}
+
+// DuplicateDeliveriesCounter counts the duplicate deliveries detected by the handlers.
+// Use OnDuplicate as middleware.DuplicateDetector.OnDuplicate.
+type DuplicateDeliveriesCounter struct {
+	duplicateDeliveries *prometheus.CounterVec
+	labeler             messageLabeler
+}
+
+// OnDuplicate records the duplicate delivery.
+func (c DuplicateDeliveriesCounter) OnDuplicate(msg *message.Message, _ string) {
+	labels := c.labeler.addLabels(prometheus.Labels{
+		labelKeyHandlerName: message.HandlerNameFromCtx(msg.Context()),
+	}, msg)
+
+	c.labeler.inc(c.duplicateDeliveries.With(labels), msg)
+}
+
+// NewDuplicateDeliveriesCounter returns a new DuplicateDeliveriesCounter.
+func (b PrometheusMetricsBuilder) NewDuplicateDeliveriesCounter() (DuplicateDeliveriesCounter, error) {
+	labeler, err := b.labeler()
+	if err != nil {
+		return DuplicateDeliveriesCounter{}, err
+	}
+
+	c := DuplicateDeliveriesCounter{
+		labeler: labeler,
+	}
+
+	c.duplicateDeliveries, err = b.registerCounterVec(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "handler_duplicate_deliveries_total",
+			Help:      "The total number of duplicate deliveries detected by the handler",
+		},
+		labeler.labelKeys(duplicateDeliveriesLabelKeys...),
+	))
+	if err != nil {
+		return DuplicateDeliveriesCounter{}, errors.Wrap(err, "could not register duplicate deliveries metric")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,41 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestDuplicateDeliveriesCounter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	counter, err := builder.NewDuplicateDeliveriesCounter()
+	require.NoError(t, err)
+
+	h := middleware.DuplicateDetector{
+		OnDuplicate: counter.OnDuplicate,
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	for _, uuid := range []string{"1", "1", "2", "1"} {
+		_, err := h(message.NewMessage(uuid, nil))
+		require.NoError(t, err)
+	}
+
+	expected := `
+# HELP handler_duplicate_deliveries_total The total number of duplicate deliveries detected by the handler
+# TYPE handler_duplicate_deliveries_total counter
+handler_duplicate_deliveries_total{handler_name=""} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "handler_duplicate_deliveries_total"))
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+var eventLagSLOLabelKeys = []string{
+	labelKeyHandlerName,
+	labelKeyMessageName,
+}
+
+var eventHandlingLagBuckets = prometheus.ExponentialBuckets(0.01, 4, 10)
+
+// EventLagSLOMetrics records the lag of handling events and the burn rate of their SLOs, by handler and event name.
+// Use OnReport as cqrs.EventLagSLOTrackerConfig.OnReport.
+type EventLagSLOMetrics struct {
+	lag        *prometheus.HistogramVec
+	violations *prometheus.CounterVec
+	burnRate   *prometheus.GaugeVec
+	labeler    messageLabeler
+}
+
+// OnReport records the report of the handled event.
+func (m EventLagSLOMetrics) OnReport(msg *message.Message, report cqrs.EventLagSLOReport) {
+	labels := m.labeler.addLabels(prometheus.Labels{
+		labelKeyHandlerName: report.HandlerName,
+		labelKeyMessageName: report.EventName,
+	}, msg)
+
+	m.labeler.observe(m.lag.With(labels), report.Lag.Seconds(), msg)
+	if !report.WithinSLO {
+		m.labeler.inc(m.violations.With(labels), msg)
+	}
+	m.burnRate.With(labels).Set(report.BurnRate)
+}
+
+// NewEventLagSLOMetrics returns a new EventLagSLOMetrics.
+func (b PrometheusMetricsBuilder) NewEventLagSLOMetrics() (EventLagSLOMetrics, error) {
+	labeler, err := b.labeler()
+	if err != nil {
+		return EventLagSLOMetrics{}, err
+	}
+
+	m := EventLagSLOMetrics{
+		labeler: labeler,
+	}
+
+	m.lag, err = b.registerHistogramVec(prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "event_handling_lag_seconds",
+			Help:      "The time between producing and handling the event",
+			Buckets:   eventHandlingLagBuckets,
+		},
+		labeler.labelKeys(eventLagSLOLabelKeys...),
+	))
+	if err != nil {
+		return EventLagSLOMetrics{}, errors.Wrap(err, "could not register event handling lag metric")
+	}
+
+	m.violations, err = b.registerCounterVec(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "event_lag_slo_violations_total",
+			Help:      "The total number of events handled later than the maximum lag of their SLO",
+		},
+		labeler.labelKeys(eventLagSLOLabelKeys...),
+	))
+	if err != nil {
+		return EventLagSLOMetrics{}, errors.Wrap(err, "could not register event lag SLO violations metric")
+	}
+
+	m.burnRate, err = b.registerGaugeVec(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "event_lag_slo_burn_rate",
+			Help:      "The rate at which the error budget of the event lag SLO is consumed in the tracker's window",
+		},
+		labeler.labelKeys(eventLagSLOLabelKeys...),
+	))
+	if err != nil {
+		return EventLagSLOMetrics{}, errors.Wrap(err, "could not register event lag SLO burn rate metric")
+	}
+
+	return m, nil
+}
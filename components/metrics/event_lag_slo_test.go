@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEventLagSLOMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	sloMetrics, err := builder.NewEventLagSLOMetrics()
+	require.NoError(t, err)
+
+	msg := message.NewMessage("1", nil)
+
+	sloMetrics.OnReport(msg, cqrs.EventLagSLOReport{
+		HandlerName: "projection",
+		EventName:   "OrderPlaced",
+		Lag:         time.Millisecond * 500,
+		WithinSLO:   true,
+		BurnRate:    0,
+	})
+	sloMetrics.OnReport(msg, cqrs.EventLagSLOReport{
+		HandlerName: "projection",
+		EventName:   "OrderPlaced",
+		Lag:         time.Second * 2,
+		WithinSLO:   false,
+		BurnRate:    5,
+	})
+
+	expected := `
+# HELP event_lag_slo_burn_rate The rate at which the error budget of the event lag SLO is consumed in the tracker's window
+# TYPE event_lag_slo_burn_rate gauge
+event_lag_slo_burn_rate{handler_name="projection",message_name="OrderPlaced"} 5
+# HELP event_lag_slo_violations_total The total number of events handled later than the maximum lag of their SLO
+# TYPE event_lag_slo_violations_total counter
+event_lag_slo_violations_total{handler_name="projection",message_name="OrderPlaced"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(
+		registry,
+		strings.NewReader(expected),
+		"event_lag_slo_burn_rate",
+		"event_lag_slo_violations_total",
+	))
+
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "event_handling_lag_seconds"))
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+var expiredMessagesLabelKeys = []string{
+	labelKeyHandlerName,
+}
+
+// ExpiredMessagesCounter counts the expired messages dropped by the handlers.
+// Use OnExpired as middleware.DropExpired.OnExpired.
+type ExpiredMessagesCounter struct {
+	expiredMessages *prometheus.CounterVec
+	labeler         messageLabeler
+}
+
+// OnExpired records the dropped message.
+func (c ExpiredMessagesCounter) OnExpired(msg *message.Message, _ time.Duration) {
+	labels := c.labeler.addLabels(prometheus.Labels{
+		labelKeyHandlerName: message.HandlerNameFromCtx(msg.Context()),
+	}, msg)
+
+	c.labeler.inc(c.expiredMessages.With(labels), msg)
+}
+
+// NewExpiredMessagesCounter returns a new ExpiredMessagesCounter.
+func (b PrometheusMetricsBuilder) NewExpiredMessagesCounter() (ExpiredMessagesCounter, error) {
+	labeler, err := b.labeler()
+	if err != nil {
+		return ExpiredMessagesCounter{}, err
+	}
+
+	c := ExpiredMessagesCounter{
+		labeler: labeler,
+	}
+
+	c.expiredMessages, err = b.registerCounterVec(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "handler_expired_messages_total",
+			Help:      "The total number of expired messages dropped by the handler",
+		},
+		labeler.labelKeys(expiredMessagesLabelKeys...),
+	))
+	if err != nil {
+		return ExpiredMessagesCounter{}, errors.Wrap(err, "could not register expired messages metric")
+	}
+
+	return c, nil
+}
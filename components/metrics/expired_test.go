@@ -0,0 +1,48 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestExpiredMessagesCounter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	counter, err := builder.NewExpiredMessagesCounter()
+	require.NoError(t, err)
+
+	h := middleware.DropExpired{
+		OnExpired: counter.OnExpired,
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		msg := message.NewMessage("1", nil)
+		message.SetExpiresAt(msg, time.Now().Add(-time.Second))
+
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	_, err = h(message.NewMessage("2", nil))
+	require.NoError(t, err)
+
+	expected := `
+# HELP handler_expired_messages_total The total number of expired messages dropped by the handler
+# TYPE handler_expired_messages_total counter
+handler_expired_messages_total{handler_name=""} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "handler_expired_messages_total"))
+}
@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+const labelKeyTopic = "topic"
+
+type goChannelCollector struct {
+	pubSub gochannel.StatsProvider
+
+	subscribers       *prometheus.Desc
+	bufferedMessages  *prometheus.Desc
+	persistedMessages *prometheus.Desc
+	published         *prometheus.Desc
+	delivered         *prometheus.Desc
+}
+
+func (c goChannelCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.subscribers
+	descs <- c.bufferedMessages
+	descs <- c.persistedMessages
+	descs <- c.published
+	descs <- c.delivered
+}
+
+func (c goChannelCollector) Collect(metrics chan<- prometheus.Metric) {
+	for topic, stats := range c.pubSub.Stats().Topics {
+		metrics <- prometheus.MustNewConstMetric(c.subscribers, prometheus.GaugeValue, float64(stats.Subscribers), topic)
+		metrics <- prometheus.MustNewConstMetric(c.bufferedMessages, prometheus.GaugeValue, float64(stats.BufferedMessages), topic)
+		metrics <- prometheus.MustNewConstMetric(c.persistedMessages, prometheus.GaugeValue, float64(stats.PersistedMessages), topic)
+		metrics <- prometheus.MustNewConstMetric(c.published, prometheus.CounterValue, float64(stats.Published), topic)
+		metrics <- prometheus.MustNewConstMetric(c.delivered, prometheus.CounterValue, float64(stats.Delivered), topic)
+	}
+}
+
+// RegisterGoChannelMetrics registers the metrics of the in-memory Pub/Sub by topic (see gochannel.GoChannel.Stats).
+// The statistics are read when the metrics are collected, and the publish and deliver rates
+// can be computed from the counters.
+//
+// The metrics of one Pub/Sub can be registered with the builder.
+// Use builders with different Subsystems to register the metrics of multiple Pub/Subs.
+func (b PrometheusMetricsBuilder) RegisterGoChannelMetrics(pubSub gochannel.StatsProvider) error {
+	labels := []string{labelKeyTopic}
+
+	c := goChannelCollector{
+		pubSub: pubSub,
+		subscribers: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "gochannel_subscribers"),
+			"The number of active subscribers of the topic",
+			labels, nil,
+		),
+		bufferedMessages: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "gochannel_buffered_messages"),
+			"The number of messages waiting in the output channels of the topic's subscribers",
+			labels, nil,
+		),
+		persistedMessages: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "gochannel_persisted_messages"),
+			"The number of messages persisted for the topic",
+			labels, nil,
+		),
+		published: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "gochannel_published_messages_total"),
+			"The total number of messages published to the topic",
+			labels, nil,
+		),
+		delivered: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "gochannel_delivered_messages_total"),
+			"The total number of messages delivered to the topic's subscribers, including redeliveries",
+			labels, nil,
+		),
+	}
+
+	if _, err := b.register(c); err != nil {
+		return errors.Wrap(err, "could not register gochannel metrics")
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestPrometheusMetricsBuilder_RegisterGoChannelMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{OutputChannelBuffer: 10}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	require.NoError(t, builder.RegisterGoChannelMetrics(pubSub))
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	require.NoError(t, pubSub.Publish("other_topic", message.NewMessage("2", nil)))
+
+	require.Eventually(t, func() bool {
+		return pubSub.Stats().Topics["topic"].Delivered == 1
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, float64(1), gaugeValue(t, registry, "gochannel_subscribers"))
+	assert.Equal(t, float64(1), gaugeValue(t, registry, "gochannel_buffered_messages"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	published := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "gochannel_published_messages_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			published[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"topic": 1, "other_topic": 1}, published)
+
+	(<-messages).Ack()
+}
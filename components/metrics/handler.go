@@ -35,6 +35,8 @@ var (
 // HandlerPrometheusMetricsMiddleware is a middleware that captures Prometheus metrics.
 type HandlerPrometheusMetricsMiddleware struct {
 	handlerExecutionTimeSeconds *prometheus.HistogramVec
+	handlerMessagesInFlight     *prometheus.GaugeVec
+	labeler                     messageLabeler
 }
 
 // Middleware returns the middleware ready to be used with watermill's Router.
@@ -42,9 +44,13 @@ func (m HandlerPrometheusMetricsMiddleware) Middleware(h message.HandlerFunc) me
 	return func(msg *message.Message) (msgs []*message.Message, err error) {
 		now := time.Now()
 		ctx := msg.Context()
-		labels := prometheus.Labels{
+		labels := m.labeler.addLabels(prometheus.Labels{
 			labelKeyHandlerName: message.HandlerNameFromCtx(ctx),
-		}
+		}, msg)
+
+		inFlight := m.handlerMessagesInFlight.With(labels)
+		inFlight.Inc()
+		defer inFlight.Dec()
 
 		defer func() {
 			if err != nil {
@@ -52,7 +58,7 @@ func (m HandlerPrometheusMetricsMiddleware) Middleware(h message.HandlerFunc) me
 			} else {
 				labels[labelSuccess] = "true"
 			}
-			m.handlerExecutionTimeSeconds.With(labels).Observe(time.Since(now).Seconds())
+			m.labeler.observe(m.handlerExecutionTimeSeconds.With(labels), time.Since(now).Seconds(), msg)
 		}()
 
 		return h(msg)
@@ -61,8 +67,14 @@ func (m HandlerPrometheusMetricsMiddleware) Middleware(h message.HandlerFunc) me
 
 // NewRouterMiddleware returns new middleware.
 func (b PrometheusMetricsBuilder) NewRouterMiddleware() HandlerPrometheusMetricsMiddleware {
-	var err error
-	m := HandlerPrometheusMetricsMiddleware{}
+	labeler, err := b.labeler()
+	if err != nil {
+		panic(err)
+	}
+
+	m := HandlerPrometheusMetricsMiddleware{
+		labeler: labeler,
+	}
 
 	m.handlerExecutionTimeSeconds, err = b.registerHistogramVec(prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -72,11 +84,24 @@ func (b PrometheusMetricsBuilder) NewRouterMiddleware() HandlerPrometheusMetrics
 			Help:      "The total time elapsed while executing the handler function in seconds",
 			Buckets:   handlerExecutionTimeBuckets,
 		},
-		handlerLabelKeys,
+		labeler.labelKeys(handlerLabelKeys...),
 	))
 	if err != nil {
 		panic(errors.Wrap(err, "could not register handler execution time metric"))
 	}
 
+	m.handlerMessagesInFlight, err = b.registerGaugeVec(prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "handler_messages_in_flight",
+			Help:      "The number of messages currently processed by the handler",
+		},
+		labeler.labelKeys(labelKeyHandlerName),
+	))
+	if err != nil {
+		panic(errors.Wrap(err, "could not register handler messages in flight metric"))
+	}
+
 	return m
 }
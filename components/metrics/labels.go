@@ -46,3 +46,71 @@ func labelsFromCtx(ctx context.Context, labels ...string) prometheus.Labels {
 
 	return ctxLabels
 }
+
+// MessageLabelsFn returns custom labels extracted from the message, for example, the tenant or the message type.
+// Labels with keys not listed in PrometheusMetricsBuilder.CustomLabelKeys are ignored, missing labels are empty.
+type MessageLabelsFn func(msg *message.Message) map[string]string
+
+// MessageExemplarFn returns the exemplar labels of the message, for example, the trace ID.
+// If it returns no labels, the observation is recorded without an exemplar.
+type MessageExemplarFn func(msg *message.Message) prometheus.Labels
+
+// messageLabeler adds custom labels and exemplars to the metrics of the message.
+type messageLabeler struct {
+	keys     []string
+	labels   MessageLabelsFn
+	exemplar MessageExemplarFn
+}
+
+func (l messageLabeler) labelKeys(builtinKeys ...string) []string {
+	keys := make([]string, 0, len(builtinKeys)+len(l.keys))
+	keys = append(keys, builtinKeys...)
+	return append(keys, l.keys...)
+}
+
+func (l messageLabeler) addLabels(labels prometheus.Labels, msg *message.Message) prometheus.Labels {
+	if len(l.keys) == 0 {
+		return labels
+	}
+
+	var msgLabels map[string]string
+	if l.labels != nil && msg != nil {
+		msgLabels = l.labels(msg)
+	}
+
+	for _, key := range l.keys {
+		labels[key] = msgLabels[key]
+	}
+
+	return labels
+}
+
+func (l messageLabeler) observe(observer prometheus.Observer, value float64, msg *message.Message) {
+	if exemplar := l.messageExemplar(msg); len(exemplar) > 0 {
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+			exemplarObserver.ObserveWithExemplar(value, exemplar)
+			return
+		}
+	}
+
+	observer.Observe(value)
+}
+
+func (l messageLabeler) inc(counter prometheus.Counter, msg *message.Message) {
+	if exemplar := l.messageExemplar(msg); len(exemplar) > 0 {
+		if exemplarAdder, ok := counter.(prometheus.ExemplarAdder); ok {
+			exemplarAdder.AddWithExemplar(1, exemplar)
+			return
+		}
+	}
+
+	counter.Inc()
+}
+
+func (l messageLabeler) messageExemplar(msg *message.Message) prometheus.Labels {
+	if l.exemplar == nil || msg == nil {
+		return nil
+	}
+
+	return l.exemplar(msg)
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// handlerLagTimeout limits the time of getting the lag of a handler, when the metrics are collected.
+const handlerLagTimeout = time.Second * 5
+
+type handlerLagCollector struct {
+	router *message.Router
+
+	messages         *prometheus.Desc
+	oldestUnackedAge *prometheus.Desc
+	consumeDelay     *prometheus.Desc
+}
+
+func (c handlerLagCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- c.messages
+	descs <- c.oldestUnackedAge
+	descs <- c.consumeDelay
+}
+
+func (c handlerLagCollector) Collect(metrics chan<- prometheus.Metric) {
+	for handlerName := range c.router.Handlers() {
+		ctx, cancel := context.WithTimeout(context.Background(), handlerLagTimeout)
+		lag, err := c.router.HandlerLag(ctx, handlerName)
+		cancel()
+		if err != nil {
+			// not supported by the subscriber, or the handler was stopped
+			continue
+		}
+
+		if lag.Messages >= 0 {
+			metrics <- prometheus.MustNewConstMetric(c.messages, prometheus.GaugeValue, float64(lag.Messages), handlerName)
+		}
+		metrics <- prometheus.MustNewConstMetric(c.oldestUnackedAge, prometheus.GaugeValue, lag.OldestUnackedMessageAge.Seconds(), handlerName)
+		metrics <- prometheus.MustNewConstMetric(c.consumeDelay, prometheus.GaugeValue, lag.ConsumeDelay.Seconds(), handlerName)
+	}
+}
+
+// RegisterHandlerLagMetrics registers the metrics of the lag of the router's handlers (see message.Router.HandlerLag).
+// The lag is read when the metrics are collected.
+//
+// Handlers with subscribers not supporting the lag reporting are skipped.
+// Use message.LagTrackingSubscriberDecorator to track the lag on the client side.
+func (b PrometheusMetricsBuilder) RegisterHandlerLagMetrics(r *message.Router) error {
+	labels := []string{labelKeyHandlerName}
+
+	c := handlerLagCollector{
+		router: r,
+		messages: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "subscriber_lag_messages"),
+			"The number of messages not consumed by the handler's subscriber yet",
+			labels, nil,
+		),
+		oldestUnackedAge: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "subscriber_oldest_unacked_message_age_seconds"),
+			"The age of the oldest message received by the handler's subscriber and not acked yet",
+			labels, nil,
+		),
+		consumeDelay: prometheus.NewDesc(
+			prometheus.BuildFQName(b.Namespace, b.Subsystem, "subscriber_consume_delay_seconds"),
+			"The time between producing and receiving the last message received by the handler's subscriber",
+			labels, nil,
+		),
+	}
+
+	if _, err := b.register(c); err != nil {
+		return errors.Wrap(err, "could not register handler lag metrics")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package metrics_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestPrometheusMetricsBuilder_RegisterHandlerLagMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	// the metrics decorator is applied on top of the lag tracking decorator
+	router.AddSubscriberDecorators(
+		message.LagTrackingSubscriberDecorator(message.LagTrackingConfig{
+			MessageTimestamp: func(msg *message.Message) (time.Time, bool) {
+				return clock.Now().Add(-time.Second), true
+			},
+			Clock: clock,
+		}),
+		builder.DecorateSubscriber,
+	)
+
+	handlerStarted := make(chan struct{})
+	releaseHandler := make(chan struct{})
+
+	router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		close(handlerStarted)
+		<-releaseHandler
+		return nil
+	})
+
+	require.NoError(t, builder.RegisterHandlerLagMetrics(router))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		close(releaseHandler)
+		assert.NoError(t, router.Close())
+	}()
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	<-handlerStarted
+	clock.Advance(time.Second)
+
+	assert.Equal(t, float64(2), gaugeValue(t, registry, "subscriber_oldest_unacked_message_age_seconds"))
+	assert.Equal(t, float64(1), gaugeValue(t, registry, "subscriber_consume_delay_seconds"))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		// the number of messages is unknown for the client-side tracking
+		assert.NotEqual(t, "subscriber_lag_messages", family.GetName())
+
+		if family.GetName() == "subscriber_oldest_unacked_message_age_seconds" {
+			require.Len(t, family.GetMetric(), 1)
+			require.Len(t, family.GetMetric()[0].GetLabel(), 1)
+			assert.Equal(t, "handler", family.GetMetric()[0].GetLabel()[0].GetValue())
+		}
+	}
+}
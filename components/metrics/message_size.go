@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const labelKeyMessageName = "message_name"
+
+var marshaledPayloadSizeLabelKeys = []string{
+	labelKeyMessageName,
+}
+
+var marshaledPayloadSizeBuckets = prometheus.ExponentialBuckets(256, 4, 8)
+
+// MarshaledPayloadSizeHistogram records the size of the payloads marshaled by the CQRS buses, by the message name.
+// Use OnMeasured as cqrs.MessageSizeConfig.OnMeasured.
+type MarshaledPayloadSizeHistogram struct {
+	payloadSize *prometheus.HistogramVec
+	labeler     messageLabeler
+}
+
+// OnMeasured records the size of the payload.
+func (h MarshaledPayloadSizeHistogram) OnMeasured(msg *message.Message, name string, size int) {
+	labels := h.labeler.addLabels(prometheus.Labels{
+		labelKeyMessageName: name,
+	}, msg)
+
+	h.labeler.observe(h.payloadSize.With(labels), float64(size), msg)
+}
+
+// NewMarshaledPayloadSizeHistogram returns a new MarshaledPayloadSizeHistogram.
+func (b PrometheusMetricsBuilder) NewMarshaledPayloadSizeHistogram() (MarshaledPayloadSizeHistogram, error) {
+	labeler, err := b.labeler()
+	if err != nil {
+		return MarshaledPayloadSizeHistogram{}, err
+	}
+
+	h := MarshaledPayloadSizeHistogram{
+		labeler: labeler,
+	}
+
+	h.payloadSize, err = b.registerHistogramVec(prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "marshaled_payload_size_bytes",
+			Help:      "The size of the marshaled payloads of commands and events in bytes",
+			Buckets:   marshaledPayloadSizeBuckets,
+		},
+		labeler.labelKeys(marshaledPayloadSizeLabelKeys...),
+	))
+	if err != nil {
+		return MarshaledPayloadSizeHistogram{}, errors.Wrap(err, "could not register marshaled payload size metric")
+	}
+
+	return h, nil
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+)
+
+type sizedEvent struct {
+	Data string `json:"data"`
+}
+
+func TestMarshaledPayloadSizeHistogram(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	histogram, err := builder.NewMarshaledPayloadSizeHistogram()
+	require.NoError(t, err)
+
+	eventBus, err := cqrs.NewEventBusWithConfig(nopPublisher{}, cqrs.EventBusConfig{
+		GeneratePublishTopic: func(params cqrs.GenerateEventPublishTopicParams) (string, error) {
+			return "events", nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		MessageSize: &cqrs.MessageSizeConfig{
+			OnMeasured: histogram.OnMeasured,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, eventBus.Publish(context.Background(), sizedEvent{Data: strings.Repeat("a", 500)}))
+	require.NoError(t, eventBus.Publish(context.Background(), sizedEvent{Data: "a"}))
+
+	expected := `
+# HELP marshaled_payload_size_bytes The size of the marshaled payloads of commands and events in bytes
+# TYPE marshaled_payload_size_bytes histogram
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="256"} 1
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="1024"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="4096"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="16384"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="65536"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="262144"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="1.048576e+06"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="4.194304e+06"} 2
+marshaled_payload_size_bytes_bucket{message_name="metrics_test.sizedEvent",le="+Inf"} 2
+marshaled_payload_size_bytes_sum{message_name="metrics_test.sizedEvent"} 523
+marshaled_payload_size_bytes_count{message_name="metrics_test.sizedEvent"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "marshaled_payload_size_bytes"))
+}
@@ -20,6 +20,7 @@ type PublisherPrometheusMetricsDecorator struct {
 	pub                message.Publisher
 	publisherName      string
 	publishTimeSeconds *prometheus.HistogramVec
+	labeler            messageLabeler
 }
 
 // Publish updates the relevant publisher metrics and calls the wrapped publisher's Publish.
@@ -37,6 +38,7 @@ func (m PublisherPrometheusMetricsDecorator) Publish(topic string, messages ...*
 	if labels[labelKeyHandlerName] == "" {
 		labels[labelKeyHandlerName] = labelValueNoHandler
 	}
+	labels = m.labeler.addLabels(labels, messages[0])
 	start := time.Now()
 
 	defer func() {
@@ -50,7 +52,7 @@ func (m PublisherPrometheusMetricsDecorator) Publish(topic string, messages ...*
 		} else {
 			labels[labelSuccess] = "true"
 		}
-		m.publishTimeSeconds.With(labels).Observe(time.Since(start).Seconds())
+		m.labeler.observe(m.publishTimeSeconds.With(labels), time.Since(start).Seconds(), messages[0])
 	}()
 
 	for _, msg := range messages {
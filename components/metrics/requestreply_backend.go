@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+const (
+	labelKeyBackendMethod = "backend_method"
+	labelKeyCommandName   = "command_name"
+)
+
+var requestReplyBackendLabelKeys = []string{
+	labelKeyBackendMethod,
+	labelKeyCommandName,
+	labelSuccess,
+}
+
+// RequestReplyBackendMetrics records the calls of the request/reply backend, by method and command name.
+// Use it with requestreply.NewObservingBackendDecorator.
+type RequestReplyBackendMetrics struct {
+	calls    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// OnBackendCall implements requestreply.BackendCallObserver.
+func (m RequestReplyBackendMetrics) OnBackendCall(
+	_ context.Context,
+	call requestreply.BackendCall,
+	result requestreply.BackendCallResult,
+) {
+	labels := prometheus.Labels{
+		labelKeyBackendMethod: string(call.Method),
+		labelKeyCommandName:   call.CommandName,
+		labelSuccess:          strconv.FormatBool(result.Err == nil),
+	}
+
+	m.calls.With(labels).Inc()
+	m.duration.With(labels).Observe(result.Duration.Seconds())
+}
+
+// NewRequestReplyBackendMetrics returns a new RequestReplyBackendMetrics.
+func (b PrometheusMetricsBuilder) NewRequestReplyBackendMetrics() (RequestReplyBackendMetrics, error) {
+	var m RequestReplyBackendMetrics
+	var err error
+
+	m.calls, err = b.registerCounterVec(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "requestreply_backend_calls_total",
+			Help:      "The total number of calls of the request/reply backend",
+		},
+		requestReplyBackendLabelKeys,
+	))
+	if err != nil {
+		return RequestReplyBackendMetrics{}, errors.Wrap(err, "could not register request/reply backend calls metric")
+	}
+
+	m.duration, err = b.registerHistogramVec(prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "requestreply_backend_call_duration_seconds",
+			Help:      "The duration of the calls of the request/reply backend",
+			Buckets:   handlerExecutionTimeBuckets,
+		},
+		requestReplyBackendLabelKeys,
+	))
+	if err != nil {
+		return RequestReplyBackendMetrics{}, errors.Wrap(err, "could not register request/reply backend call duration metric")
+	}
+
+	return m, nil
+}
@@ -0,0 +1,41 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+func TestRequestReplyBackendMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	m, err := builder.NewRequestReplyBackendMetrics()
+	require.NoError(t, err)
+
+	call := requestreply.BackendCall{
+		Method:      requestreply.BackendMethodOnCommandProcessed,
+		CommandName: "cmd",
+	}
+
+	m.OnBackendCall(context.Background(), call, requestreply.BackendCallResult{})
+	m.OnBackendCall(context.Background(), call, requestreply.BackendCallResult{})
+	m.OnBackendCall(context.Background(), call, requestreply.BackendCallResult{Err: errors.New("failed")})
+
+	expected := `
+# HELP requestreply_backend_calls_total The total number of calls of the request/reply backend
+# TYPE requestreply_backend_calls_total counter
+requestreply_backend_calls_total{backend_method="OnCommandProcessed",command_name="cmd",success="false"} 1
+requestreply_backend_calls_total{backend_method="OnCommandProcessed",command_name="cmd",success="true"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "requestreply_backend_calls_total"))
+}
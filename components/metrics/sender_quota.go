@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const labelKeySender = "sender"
+
+var senderQuotaExceededLabelKeys = []string{
+	labelKeyHandlerName,
+	labelKeySender,
+}
+
+// SenderQuotaExceededCounter counts the messages exceeding the sender quotas, by handler and sender.
+// Use OnExceeded as middleware.SenderQuotaConfig.OnExceeded.
+//
+// Keep in mind that every sender is a separate time series.
+type SenderQuotaExceededCounter struct {
+	exceededMessages *prometheus.CounterVec
+	labeler          messageLabeler
+}
+
+// OnExceeded records the message exceeding the quota.
+func (c SenderQuotaExceededCounter) OnExceeded(msg *message.Message, sender string) {
+	labels := c.labeler.addLabels(prometheus.Labels{
+		labelKeyHandlerName: message.HandlerNameFromCtx(msg.Context()),
+		labelKeySender:      sender,
+	}, msg)
+
+	c.labeler.inc(c.exceededMessages.With(labels), msg)
+}
+
+// NewSenderQuotaExceededCounter returns a new SenderQuotaExceededCounter.
+func (b PrometheusMetricsBuilder) NewSenderQuotaExceededCounter() (SenderQuotaExceededCounter, error) {
+	labeler, err := b.labeler()
+	if err != nil {
+		return SenderQuotaExceededCounter{}, err
+	}
+
+	c := SenderQuotaExceededCounter{
+		labeler: labeler,
+	}
+
+	c.exceededMessages, err = b.registerCounterVec(prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: b.Namespace,
+			Subsystem: b.Subsystem,
+			Name:      "handler_sender_quota_exceeded_total",
+			Help:      "The total number of messages exceeding the sender quota of the handler",
+		},
+		labeler.labelKeys(senderQuotaExceededLabelKeys...),
+	))
+	if err != nil {
+		return SenderQuotaExceededCounter{}, errors.Wrap(err, "could not register sender quota exceeded metric")
+	}
+
+	return c, nil
+}
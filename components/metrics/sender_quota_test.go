@@ -0,0 +1,62 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/metrics"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(topic string, messages ...*message.Message) error {
+	return nil
+}
+
+func (nopPublisher) Close() error {
+	return nil
+}
+
+func TestSenderQuotaExceededCounter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	builder := metrics.NewPrometheusMetricsBuilder(registry, "", "")
+
+	counter, err := builder.NewSenderQuotaExceededCounter()
+	require.NoError(t, err)
+
+	quota, err := middleware.NewSenderQuota(middleware.SenderQuotaConfig{
+		SenderMetadataKey: "tenant",
+		Limit:             1,
+		Action:            middleware.SenderQuotaDeadLetter,
+		DeadLetterTopic:   "quota_exceeded",
+		Publisher:         nopPublisher{},
+		OnExceeded:        counter.OnExceeded,
+	})
+	require.NoError(t, err)
+
+	h := quota.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	for _, tenant := range []string{"noisy", "noisy", "noisy", "quiet"} {
+		msg := message.NewMessage("1", nil)
+		msg.Metadata.Set("tenant", tenant)
+
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	expected := `
+# HELP handler_sender_quota_exceeded_total The total number of messages exceeding the sender quota of the handler
+# TYPE handler_sender_quota_exceeded_total counter
+handler_sender_quota_exceeded_total{handler_name="",sender="noisy"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "handler_sender_quota_exceeded_total"))
+}
@@ -1,6 +1,9 @@
 package metrics
 
 import (
+	"context"
+	"time"
+
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -17,7 +20,10 @@ type SubscriberPrometheusMetricsDecorator struct {
 	message.Subscriber
 	subscriberName                  string
 	subscriberMessagesReceivedTotal *prometheus.CounterVec
+	subscriberAckLatencySeconds     *prometheus.HistogramVec
+	subscriberMessagesUnacked       *prometheus.GaugeVec
 	closing                         chan struct{}
+	labeler                         messageLabeler
 }
 
 func (s SubscriberPrometheusMetricsDecorator) recordMetrics(msg *message.Message) {
@@ -25,14 +31,9 @@ func (s SubscriberPrometheusMetricsDecorator) recordMetrics(msg *message.Message
 		return
 	}
 
+	received := time.Now()
 	ctx := msg.Context()
-	labels := labelsFromCtx(ctx, subscriberLabelKeys...)
-	if labels[labelKeySubscriberName] == "" {
-		labels[labelKeySubscriberName] = s.subscriberName
-	}
-	if labels[labelKeyHandlerName] == "" {
-		labels[labelKeyHandlerName] = labelValueNoHandler
-	}
+	labels := s.labeler.addLabels(subscriberLabels(ctx, s.subscriberName), msg)
 
 	go func() {
 		if subscribeAlreadyObserved(ctx) {
@@ -40,14 +41,36 @@ func (s SubscriberPrometheusMetricsDecorator) recordMetrics(msg *message.Message
 			return
 		}
 
+		unacked := s.subscriberMessagesUnacked.With(s.labeler.addLabels(subscriberLabels(ctx, s.subscriberName), msg))
+		unacked.Inc()
+		defer unacked.Dec()
+
 		select {
 		case <-msg.Acked():
 			labels[labelAcked] = "acked"
 		case <-msg.Nacked():
 			labels[labelAcked] = "nacked"
 		}
-		s.subscriberMessagesReceivedTotal.With(labels).Inc()
+		s.labeler.inc(s.subscriberMessagesReceivedTotal.With(labels), msg)
+		s.labeler.observe(s.subscriberAckLatencySeconds.With(labels), time.Since(received).Seconds(), msg)
 	}()
 
 	msg.SetContext(setSubscribeObservedToCtx(msg.Context()))
 }
+
+func subscriberLabels(ctx context.Context, subscriberName string) prometheus.Labels {
+	labels := labelsFromCtx(ctx, subscriberLabelKeys...)
+	if labels[labelKeySubscriberName] == "" {
+		labels[labelKeySubscriberName] = subscriberName
+	}
+	if labels[labelKeyHandlerName] == "" {
+		labels[labelKeyHandlerName] = labelValueNoHandler
+	}
+
+	return labels
+}
+
+// Unwrap returns the decorated subscriber.
+func (s SubscriberPrometheusMetricsDecorator) Unwrap() message.Subscriber {
+	return s.Subscriber
+}
@@ -0,0 +1,245 @@
+package requestreply
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// BackendDecorator wraps a Backend to add a cross-cutting behavior, like logging or retries,
+// to ListenForNotifications and OnCommandProcessed.
+//
+// The returned backend should implement BackendUnwrapper, so the optional interfaces
+// of the decorated backend (like OperationIDGenerator or ReplyGetter) are still used.
+type BackendDecorator[Result any] func(backend Backend[Result]) Backend[Result]
+
+// BackendUnwrapper is implemented by decorated backends. It returns the decorated backend.
+//
+// SendWithReplies, ObserveReplies, and the command handlers look for the optional interfaces of Backend
+// through the whole chain of decorators.
+type BackendUnwrapper[Result any] interface {
+	Unwrap() Backend[Result]
+}
+
+// DecorateBackend wraps the backend with the decorators.
+// Like with the router's middlewares, the first decorator is the outermost one,
+// so it's called first and sees the result of all the others.
+func DecorateBackend[Result any](backend Backend[Result], decorators ...BackendDecorator[Result]) Backend[Result] {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		backend = decorators[i](backend)
+	}
+
+	return backend
+}
+
+// backendAs returns the first backend in the chain of decorators implementing T.
+func backendAs[T any, Result any](backend Backend[Result]) (T, bool) {
+	for backend != nil {
+		if t, ok := backend.(T); ok {
+			return t, true
+		}
+
+		unwrapper, ok := backend.(BackendUnwrapper[Result])
+		if !ok {
+			break
+		}
+		backend = unwrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// BackendMethod is the method of Backend called.
+type BackendMethod string
+
+const (
+	BackendMethodListenForNotifications BackendMethod = "ListenForNotifications"
+	BackendMethodOnCommandProcessed     BackendMethod = "OnCommandProcessed"
+)
+
+// BackendCall describes the call of the Backend's method.
+type BackendCall struct {
+	Method  BackendMethod
+	Command any
+
+	// CommandName is the fully qualified name of the command's type.
+	CommandName string
+
+	// OperationID is empty if it's missing in the command message.
+	OperationID OperationID
+}
+
+// BackendCallResult is the result of the Backend's method call.
+type BackendCallResult struct {
+	Duration time.Duration
+
+	// Err is the error returned by the backend.
+	// The handler's error returned by OnCommandProcessed, so the command is nacked, is not a failure of the backend,
+	// and it's not set.
+	//
+	// For ListenForNotifications, it covers only starting to listen, not the replies.
+	Err error
+}
+
+// BackendCallObserver is called after each call of the Backend's methods.
+// It's implemented by metrics.RequestReplyBackendMetrics.
+type BackendCallObserver interface {
+	OnBackendCall(ctx context.Context, call BackendCall, result BackendCallResult)
+}
+
+// BackendSpan is the span of the Backend's method call (see BackendTracer).
+type BackendSpan interface {
+	End(result BackendCallResult)
+}
+
+// BackendTracer starts the spans of the Backend's method calls.
+// It allows integrating any tracing library, for example, OpenTelemetry.
+type BackendTracer interface {
+	// StartSpan starts the span of the call. The returned context is passed to the backend.
+	StartSpan(ctx context.Context, call BackendCall) (context.Context, BackendSpan)
+}
+
+// NewLoggingBackendDecorator returns a decorator logging the calls of the backend.
+// Successful calls are logged with the debug level, and failures with the error level.
+func NewLoggingBackendDecorator[Result any](logger watermill.LoggerAdapter) BackendDecorator[Result] {
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return newInstrumentedBackendDecorator[Result](func(ctx context.Context, call BackendCall) (context.Context, func(BackendCallResult)) {
+		fields := watermill.LogFields{
+			"backend_method": call.Method,
+			"operation_id":   call.OperationID,
+			"command_name":   call.CommandName,
+		}
+
+		logger.Trace("Calling request/reply backend", fields)
+
+		return ctx, func(result BackendCallResult) {
+			fields := fields.Add(watermill.LogFields{"duration": result.Duration})
+
+			if result.Err != nil {
+				logger.Error("Request/reply backend call failed", result.Err, fields)
+				return
+			}
+
+			logger.Debug("Request/reply backend call finished", fields)
+		}
+	})
+}
+
+// NewObservingBackendDecorator returns a decorator reporting the calls of the backend to the observer,
+// for example, to record metrics (see metrics.RequestReplyBackendMetrics).
+func NewObservingBackendDecorator[Result any](observer BackendCallObserver) BackendDecorator[Result] {
+	return newInstrumentedBackendDecorator[Result](func(ctx context.Context, call BackendCall) (context.Context, func(BackendCallResult)) {
+		return ctx, func(result BackendCallResult) {
+			observer.OnBackendCall(ctx, call, result)
+		}
+	})
+}
+
+// NewTracingBackendDecorator returns a decorator starting a span for each call of the backend.
+func NewTracingBackendDecorator[Result any](tracer BackendTracer) BackendDecorator[Result] {
+	return newInstrumentedBackendDecorator[Result](func(ctx context.Context, call BackendCall) (context.Context, func(BackendCallResult)) {
+		ctx, span := tracer.StartSpan(ctx, call)
+		return ctx, span.End
+	})
+}
+
+// instrumentFn is called before the Backend's method call.
+// The returned context is passed to the backend, and the returned function is called after the call.
+type instrumentFn func(ctx context.Context, call BackendCall) (context.Context, func(BackendCallResult))
+
+func newInstrumentedBackendDecorator[Result any](instrument instrumentFn) BackendDecorator[Result] {
+	return func(backend Backend[Result]) Backend[Result] {
+		return instrumentedBackend[Result]{
+			next:       backend,
+			instrument: instrument,
+		}
+	}
+}
+
+type instrumentedBackend[Result any] struct {
+	next       Backend[Result]
+	instrument instrumentFn
+}
+
+func (b instrumentedBackend[Result]) Unwrap() Backend[Result] {
+	return b.next
+}
+
+func (b instrumentedBackend[Result]) ListenForNotifications(
+	ctx context.Context,
+	params BackendListenForNotificationsParams,
+) (<-chan Reply[Result], error) {
+	ctx, done := b.instrument(ctx, listenForNotificationsCall(params))
+
+	start := time.Now()
+	replies, err := b.next.ListenForNotifications(ctx, params)
+	done(BackendCallResult{Duration: time.Since(start), Err: err})
+
+	return replies, err
+}
+
+func (b instrumentedBackend[Result]) OnCommandProcessed(ctx context.Context, params BackendOnCommandProcessedParams[Result]) error {
+	ctx, done := b.instrument(ctx, onCommandProcessedCall(params))
+
+	start := time.Now()
+	err := b.next.OnCommandProcessed(ctx, params)
+	done(BackendCallResult{Duration: time.Since(start), Err: backendErr(err, params)})
+
+	return err
+}
+
+func listenForNotificationsCall(params BackendListenForNotificationsParams) BackendCall {
+	return BackendCall{
+		Method:      BackendMethodListenForNotifications,
+		Command:     params.Command,
+		CommandName: commandName(params.Command),
+		OperationID: params.OperationID,
+	}
+}
+
+func onCommandProcessedCall[Result any](params BackendOnCommandProcessedParams[Result]) BackendCall {
+	call := BackendCall{
+		Method:      BackendMethodOnCommandProcessed,
+		Command:     params.Command,
+		CommandName: commandName(params.Command),
+	}
+	if params.CommandMessage != nil {
+		call.OperationID = OperationID(params.CommandMessage.Metadata.Get(OperationIDMetadataKey))
+	}
+
+	return call
+}
+
+// backendErr returns the error of OnCommandProcessed, if it's not the handler's error returned to nack the command.
+func backendErr[Result any](err error, params BackendOnCommandProcessedParams[Result]) error {
+	if isHandleErr(err, params.HandleErr) {
+		return nil
+	}
+
+	return err
+}
+
+// isHandleErr returns true if err is the handler's error.
+// Errors can't be always compared with ==, as it panics for uncomparable values
+// (for example, map- or slice-based validation errors).
+// Uncomparable errors of the same type and message are assumed to be the handler's error.
+func isHandleErr(err error, handleErr error) bool {
+	if err == nil || handleErr == nil {
+		return false
+	}
+	if reflect.TypeOf(err) != reflect.TypeOf(handleErr) {
+		return false
+	}
+
+	if reflect.ValueOf(err).Comparable() && reflect.ValueOf(handleErr).Comparable() {
+		return err == handleErr
+	}
+
+	return err.Error() == handleErr.Error()
+}
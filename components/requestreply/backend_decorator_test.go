@@ -0,0 +1,269 @@
+package requestreply_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type recordedBackendCall struct {
+	Call   requestreply.BackendCall
+	Result requestreply.BackendCallResult
+}
+
+type backendCallsRecorder struct {
+	lock  sync.Mutex
+	calls []recordedBackendCall
+}
+
+func (r *backendCallsRecorder) OnBackendCall(
+	_ context.Context,
+	call requestreply.BackendCall,
+	result requestreply.BackendCallResult,
+) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.calls = append(r.calls, recordedBackendCall{Call: call, Result: result})
+}
+
+func (r *backendCallsRecorder) Calls() []recordedBackendCall {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]recordedBackendCall(nil), r.calls...)
+}
+
+type spanCtxKey struct{}
+
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []string
+}
+
+func (r *recordingTracer) StartSpan(ctx context.Context, call requestreply.BackendCall) (context.Context, requestreply.BackendSpan) {
+	return context.WithValue(ctx, spanCtxKey{}, call.Method), recordedSpan{tracer: r, method: call.Method}
+}
+
+type recordedSpan struct {
+	tracer *recordingTracer
+	method requestreply.BackendMethod
+}
+
+func (s recordedSpan) End(result requestreply.BackendCallResult) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+
+	s.tracer.spans = append(s.tracer.spans, string(s.method))
+}
+
+func TestDecorateBackend(t *testing.T) {
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		DoNotAckOnCommandErrors: true,
+		GenerateOperationID: func(ctx context.Context, cmd any) (requestreply.OperationID, error) {
+			return requestreply.OperationID("op-" + cmd.(*TestCommand).ID), nil
+		},
+	})
+
+	recorder := &backendCallsRecorder{}
+	tracer := &recordingTracer{}
+
+	backend := requestreply.DecorateBackend[requestreply.NoResult](
+		ts.RequestReplyBackend,
+		requestreply.NewLoggingBackendDecorator[requestreply.NoResult](ts.Logger),
+		requestreply.NewObservingBackendDecorator[requestreply.NoResult](recorder),
+		requestreply.NewTracingBackendDecorator[requestreply.NoResult](tracer),
+	)
+
+	handlerErr := errors.New("handler error")
+	var handled sync.Once
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandler[TestCommand](
+			"test_handler",
+			backend,
+			func(ctx context.Context, cmd *TestCommand) error {
+				var err error
+				handled.Do(func() {
+					err = handlerErr
+				})
+				return err
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	reply, err := requestreply.SendWithReply[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		backend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.Error(t, reply.Error)
+
+	// the operation ID is generated by the decorated backend
+	assert.Equal(t, "op-1", reply.NotificationMessage.Metadata.Get(requestreply.OperationIDMetadataKey))
+
+	require.Eventually(t, func() bool {
+		return len(recorder.Calls()) >= 2
+	}, time.Second, time.Millisecond*10)
+
+	calls := recorder.Calls()
+
+	assert.Equal(t, requestreply.BackendMethodListenForNotifications, calls[0].Call.Method)
+	assert.EqualValues(t, "op-1", calls[0].Call.OperationID)
+	assert.NoError(t, calls[0].Result.Err)
+
+	assert.Equal(t, requestreply.BackendMethodOnCommandProcessed, calls[1].Call.Method)
+	assert.EqualValues(t, "op-1", calls[1].Call.OperationID)
+	assert.Contains(t, calls[1].Call.CommandName, "TestCommand")
+	// the handler's error is not a failure of the backend
+	assert.NoError(t, calls[1].Result.Err)
+
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	assert.Contains(t, tracer.spans, string(requestreply.BackendMethodListenForNotifications))
+	assert.Contains(t, tracer.spans, string(requestreply.BackendMethodOnCommandProcessed))
+}
+
+type namedBackend struct {
+	name  string
+	calls *[]string
+}
+
+func (b namedBackend) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[requestreply.NoResult], error) {
+	*b.calls = append(*b.calls, b.name)
+	return nil, nil
+}
+
+func (b namedBackend) OnCommandProcessed(
+	ctx context.Context,
+	params requestreply.BackendOnCommandProcessedParams[requestreply.NoResult],
+) error {
+	*b.calls = append(*b.calls, b.name)
+	return nil
+}
+
+type namedBackendDecorator struct {
+	namedBackend
+	next requestreply.Backend[requestreply.NoResult]
+}
+
+func (d namedBackendDecorator) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[requestreply.NoResult], error) {
+	_, _ = d.namedBackend.ListenForNotifications(ctx, params)
+	return d.next.ListenForNotifications(ctx, params)
+}
+
+func TestDecorateBackend_order(t *testing.T) {
+	var calls []string
+
+	decorator := func(name string) requestreply.BackendDecorator[requestreply.NoResult] {
+		return func(backend requestreply.Backend[requestreply.NoResult]) requestreply.Backend[requestreply.NoResult] {
+			return namedBackendDecorator{
+				namedBackend: namedBackend{name: name, calls: &calls},
+				next:         backend,
+			}
+		}
+	}
+
+	backend := requestreply.DecorateBackend[requestreply.NoResult](
+		namedBackend{name: "backend", calls: &calls},
+		decorator("first"),
+		decorator("second"),
+	)
+
+	_, err := backend.ListenForNotifications(context.Background(), requestreply.BackendListenForNotificationsParams{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"first", "second", "backend"}, calls)
+}
+
+func TestDecorateBackend_handler_error(t *testing.T) {
+	recorder := &backendCallsRecorder{}
+	backendErr := errors.New("backend error")
+	handlerErr := errors.New("handler error")
+
+	for _, returnedErr := range []error{nil, handlerErr, backendErr} {
+		backend := requestreply.NewObservingBackendDecorator[requestreply.NoResult](recorder)(
+			failingBackend{processedErr: returnedErr},
+		)
+
+		err := backend.OnCommandProcessed(context.Background(), requestreply.BackendOnCommandProcessedParams[requestreply.NoResult]{
+			CommandMessage: message.NewMessage("1", nil),
+			HandleErr:      handlerErr,
+		})
+		assert.Equal(t, returnedErr, err)
+	}
+
+	calls := recorder.Calls()
+	require.Len(t, calls, 3)
+
+	assert.NoError(t, calls[0].Result.Err)
+	assert.NoError(t, calls[1].Result.Err)
+	assert.Equal(t, backendErr, calls[2].Result.Err)
+}
+
+// validationErrors is an uncomparable error, like the validation errors of some libraries.
+type validationErrors map[string]string
+
+func (e validationErrors) Error() string {
+	return "invalid command"
+}
+
+func TestDecorateBackend_uncomparable_handler_error(t *testing.T) {
+	recorder := &backendCallsRecorder{}
+	handlerErr := validationErrors{"id": "missing"}
+
+	backend := requestreply.NewObservingBackendDecorator[requestreply.NoResult](recorder)(
+		failingBackend{processedErr: handlerErr},
+	)
+
+	var err error
+	require.NotPanics(t, func() {
+		err = backend.OnCommandProcessed(context.Background(), requestreply.BackendOnCommandProcessedParams[requestreply.NoResult]{
+			CommandMessage: message.NewMessage("1", nil),
+			HandleErr:      handlerErr,
+		})
+	})
+	assert.Equal(t, handlerErr, err)
+
+	calls := recorder.Calls()
+	require.Len(t, calls, 1)
+	assert.NoError(t, calls[0].Result.Err, "the handler's error is not the backend's failure")
+}
+
+type failingBackend struct {
+	listenErr    error
+	processedErr error
+}
+
+func (b failingBackend) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[requestreply.NoResult], error) {
+	return nil, b.listenErr
+}
+
+func (b failingBackend) OnCommandProcessed(
+	ctx context.Context,
+	params requestreply.BackendOnCommandProcessedParams[requestreply.NoResult],
+) error {
+	return b.processedErr
+}
@@ -3,6 +3,8 @@ package requestreply
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
@@ -15,6 +17,12 @@ import (
 type PubSubBackend[Result any] struct {
 	config    PubSubBackendConfig
 	marshaler BackendPubsubMarshaler[Result]
+
+	// replyAddresses are the addresses of the reply queues of listened operations, see ReplyQueues
+	replyAddresses *sync.Map
+
+	// subscriberPool is nil if PubSubBackendConfig.SubscriberPool is not set
+	subscriberPool *subscriberPool
 }
 
 // NewPubSubBackend creates a new PubSubBackend.
@@ -33,10 +41,26 @@ func NewPubSubBackend[Result any](
 		return nil, errors.New("marshaler cannot be nil")
 	}
 
-	return &PubSubBackend[Result]{
-		config:    config,
-		marshaler: marshaler,
-	}, nil
+	backend := &PubSubBackend[Result]{
+		config:         config,
+		marshaler:      marshaler,
+		replyAddresses: &sync.Map{},
+	}
+	if config.SubscriberPool != nil {
+		backend.subscriberPool = newSubscriberPool(*config.SubscriberPool, config.Clock, config.Logger)
+	}
+
+	return backend, nil
+}
+
+// Close closes the idle subscribers of PubSubBackendConfig.SubscriberPool.
+// It does nothing if the pool is not used.
+func (p PubSubBackend[Result]) Close() error {
+	if p.subscriberPool == nil {
+		return nil
+	}
+
+	return p.subscriberPool.Close()
 }
 
 type PubSubBackendSubscribeParams struct {
@@ -69,6 +93,10 @@ type PubSubBackendModifyNotificationMessageFn func(msg *message.Message, params
 
 type PubSubBackendOnListenForReplyFinishedFn func(ctx context.Context, params PubSubBackendSubscribeParams)
 
+type PubSubBackendGenerateOperationIDFn func(ctx context.Context, cmd any) (OperationID, error)
+
+type PubSubBackendValidateOperationIDFn func(ctx context.Context, params PubSubBackendPublishParams) error
+
 type ReplyPublishErrorHandler func(replyTopic string, notificationMsg *message.Message, err error) error
 
 type PubSubBackendConfig struct {
@@ -96,12 +124,95 @@ type PubSubBackendConfig struct {
 	// ReplyPublishErrorHandler if not nil will be invoked when sending the reply fails. If it returns an error
 	// the command will ba nacked.
 	ReplyPublishErrorHandler ReplyPublishErrorHandler
+
+	// Clock is used to measure ListenForReplyTimeout.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// ReplyStore if not nil is used to persist replies before they are published.
+	// Persisted replies can be queried later with GetReply, for example, after the caller was restarted
+	// before it received the reply.
+	//
+	// Only the replies of commands which are acked are persisted. Replies of handler errors are not persisted
+	// when AckCommandErrors is false, as the command is nacked and processed again.
+	ReplyStore ReplyStore
+
+	// HandlerInstanceID if not empty is added to the reply metadata (with HandlerInstanceIDMetadataKey key),
+	// so the caller can tell which replica handled the command (see Reply.HandlerInstanceID).
+	HandlerInstanceID string
+
+	// ReplyOnlyWhenAcked disables publishing the reply when the handler returns an error and the command is nacked.
+	//
+	// When commands are processed by a competing-consumer group, a nacked command is redelivered, often to
+	// another instance. Without this option, every attempt publishes a reply.
+	// With this option, only the instance which finally processed the command publishes the reply.
+	//
+	// It has no effect if AckCommandErrors is enabled, because all commands are acked then.
+	ReplyOnlyWhenAcked bool
+
+	// GenerateOperationID if not nil is used by SendWithReplies to generate the operation ID of the command,
+	// instead of a random UUID. Deriving it from business keys of the command (for example, order ID)
+	// makes the operation ID the same when the caller sends the command again.
+	// Operation ID provided with ContextWithOperationID takes precedence.
+	GenerateOperationID PubSubBackendGenerateOperationIDFn
+
+	// ValidateOperationID if not nil is called before the command is handled.
+	// If it returns an error, the handler is not called.
+	// If the error wraps ErrOperationReplayed, the command is acked and the stored reply is sent again
+	// (if ReplyStore is set). Other errors nack the command.
+	ValidateOperationID PubSubBackendValidateOperationIDFn
+
+	// ReplyQueues if not nil is used to receive replies on temporary reply queues native to the transport,
+	// instead of subscribing to topics generated by GenerateSubscribeTopic. See ReplyQueues.
+	// SubscriberConstructor and GenerateSubscribeTopic are optional then, and used only as a fallback
+	// when ReplyQueues returns ErrReplyQueuesNotSupported.
+	//
+	// Handlers publish the replies to the reply queue regardless of this option, if its address is set on the command.
+	// Keep in mind that replies published to reply queues are not received by ObserveReplies, unless ReplyStore is used.
+	ReplyQueues ReplyQueues
+
+	// RejectReplayedOperations enables rejecting commands with operation IDs that already have a reply in ReplyStore.
+	// The handler is not called for them, and the stored reply is sent again, so the caller receives it.
+	// Together with GenerateOperationID, it gives exactly-once replies at the application level.
+	//
+	// ReplyStore is required.
+	RejectReplayedOperations bool
+
+	// SubscriberPool if not nil enables reusing the subscribers created by SubscriberConstructor between
+	// the commands waiting for replies, instead of creating a new subscriber for every command.
+	// See SubscriberPoolConfig.
+	//
+	// Pooled subscribers are owned by the backend, so they must not be closed in OnListenForReplyFinished.
+	// Call PubSubBackend.Close to close the idle subscribers.
+	SubscriberPool *SubscriberPoolConfig
+
+	// LifecycleEvents if not nil enables publishing the lifecycle events of operations (sent, received by the handler,
+	// and replied) to a monitoring topic. See LifecycleEventsConfig.
+	//
+	// The events are published by the backends of both the callers and the handlers, if they have the option set.
+	LifecycleEvents *LifecycleEventsConfig
+
+	// NotifyCommandConsumed enables notifying the caller when the command is consumed by the handler,
+	// before the handler is called. The notification is published like the reply, with CommandConsumedMetadataKey set.
+	//
+	// On the caller's side, it sets ReplyTimeoutError.Phase, so the caller can tell if the command was never consumed
+	// (for example, because of a routing problem) or the handler is just slow.
+	// It should be enabled in the backends of both the callers and the handlers.
+	NotifyCommandConsumed bool
 }
 
 func (p *PubSubBackendConfig) setDefaults() {
 	if p.Logger == nil {
 		p.Logger = watermill.NopLogger{}
 	}
+	if p.Clock == nil {
+		p.Clock = watermill.RealClock{}
+	}
+	if p.SubscriberPool != nil {
+		pool := *p.SubscriberPool
+		pool.setDefaults()
+		p.SubscriberPool = &pool
+	}
 }
 
 func (p *PubSubBackendConfig) Validate() error {
@@ -110,15 +221,28 @@ func (p *PubSubBackendConfig) Validate() error {
 	if p.Publisher == nil {
 		err = multierror.Append(err, errors.New("publisher cannot be nil"))
 	}
-	if p.SubscriberConstructor == nil {
+	if p.SubscriberConstructor == nil && p.ReplyQueues == nil {
 		err = multierror.Append(err, errors.New("subscriber constructor cannot be nil"))
 	}
 	if p.GeneratePublishTopic == nil {
 		err = multierror.Append(err, errors.New("GeneratePublishTopic cannot be nil"))
 	}
-	if p.GenerateSubscribeTopic == nil {
+	if p.GenerateSubscribeTopic == nil && p.ReplyQueues == nil {
 		err = multierror.Append(err, errors.New("GenerateSubscribeTopic cannot be nil"))
 	}
+	if p.RejectReplayedOperations && p.ReplyStore == nil {
+		err = multierror.Append(err, errors.New("ReplyStore is required when RejectReplayedOperations is enabled"))
+	}
+	if p.SubscriberPool != nil {
+		if poolErr := p.SubscriberPool.Validate(); poolErr != nil {
+			err = multierror.Append(err, poolErr)
+		}
+	}
+	if p.LifecycleEvents != nil {
+		if lifecycleErr := p.LifecycleEvents.Validate(); lifecycleErr != nil {
+			err = multierror.Append(err, errors.Wrap(lifecycleErr, "invalid LifecycleEvents config"))
+		}
+	}
 
 	return err
 }
@@ -127,44 +251,34 @@ func (p PubSubBackend[Result]) ListenForNotifications(
 	ctx context.Context,
 	params BackendListenForNotificationsParams,
 ) (<-chan Reply[Result], error) {
-	start := time.Now()
+	start := p.config.Clock.Now()
 
 	replyContext := PubSubBackendSubscribeParams(params)
 
-	// this needs to be done before publishing the message to avoid race condition
-	notificationsSubscriber, err := p.config.SubscriberConstructor(replyContext)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot create request/reply notifications subscriber")
-	}
-
-	replyNotificationTopic, err := p.config.GenerateSubscribeTopic(replyContext)
-	if err != nil {
-		return nil, errors.Wrap(err, "cannot generate request/reply notifications topic")
-	}
+	ctx, cancel := context.WithCancel(ctx)
 
-	var cancel context.CancelFunc
+	var timeout <-chan time.Time
 	if p.config.ListenForReplyTimeout != nil {
-		ctx, cancel = context.WithTimeout(ctx, *p.config.ListenForReplyTimeout)
-	} else {
-		ctx, cancel = context.WithCancel(ctx)
+		timeout = p.config.Clock.After(*p.config.ListenForReplyTimeout)
 	}
 
-	notifyMsgs, err := notificationsSubscriber.Subscribe(ctx, replyNotificationTopic)
+	// this needs to be done before publishing the message to avoid race condition
+	notifyMsgs, replyAddress, releaseSubscriber, err := p.subscribeForNotifications(ctx, replyContext)
 	if err != nil {
 		cancel()
-		return nil, errors.Wrap(err, "cannot subscribe to request/reply notifications topic")
+		return nil, err
 	}
 
-	p.config.Logger.Debug(
-		"Subscribed to request/reply notifications topic",
-		watermill.LogFields{
-			"request_reply_topic": replyNotificationTopic,
-		},
-	)
+	if replyAddress != "" {
+		p.replyAddresses.Store(params.OperationID, replyAddress)
+	}
 
 	replyChan := make(chan Reply[Result], 1)
 
 	go func() {
+		if replyAddress != "" {
+			defer p.replyAddresses.Delete(params.OperationID)
+		}
 		defer func() {
 			if p.config.OnListenForReplyFinished == nil {
 				return
@@ -173,35 +287,60 @@ func (p PubSubBackend[Result]) ListenForNotifications(
 			p.config.OnListenForReplyFinished(ctx, replyContext)
 		}()
 		defer close(replyChan)
+		defer releaseSubscriber()
 		defer cancel()
 
+		consumed := false
+		timeoutErr := func(err error) ReplyTimeoutError {
+			return ReplyTimeoutError{
+				Duration: p.config.Clock.Now().Sub(start),
+				Err:      err,
+				Phase:    p.timeoutPhase(consumed),
+			}
+		}
+
 		for {
 			select {
 			case <-ctx.Done():
 				replyChan <- Reply[Result]{
-					Error: ReplyTimeoutError{time.Since(start), ctx.Err()},
+					Error: timeoutErr(ctx.Err()),
+				}
+				return
+			case <-timeout:
+				replyChan <- Reply[Result]{
+					Error: timeoutErr(context.DeadlineExceeded),
 				}
 				return
 			case notifyMsg, ok := <-notifyMsgs:
 				if !ok {
 					// subscriber is closed
 					replyChan <- Reply[Result]{
-						Error: ReplyTimeoutError{time.Since(start), fmt.Errorf("subscriber closed")},
+						Error: timeoutErr(fmt.Errorf("subscriber closed")),
 					}
 					return
 				}
 
+				if isCommandConsumedNotification(notifyMsg) {
+					if notifyMsg.Metadata.Get(OperationIDMetadataKey) == string(params.OperationID) {
+						consumed = true
+					}
+					notifyMsg.Ack()
+					continue
+				}
+
 				resp, ok, unmarshalErr := p.handleNotifyMsg(notifyMsg, string(params.OperationID), p.marshaler)
 				if unmarshalErr != nil {
 					replyChan <- Reply[Result]{
 						Error: ReplyUnmarshalError{unmarshalErr},
 					}
 				} else if ok {
-					replyChan <- Reply[Result]{
-						HandlerResult:       resp.HandlerResult,
-						Error:               resp.Error,
-						NotificationMessage: notifyMsg,
+					reply := Reply[Result]{
+						HandlerResult: resp.HandlerResult,
+						Error:         resp.Error,
 					}
+					setReplyMetadata(&reply, notifyMsg)
+
+					replyChan <- reply
 				}
 
 				// we assume that more messages may arrive (in case of fan-out commands handling) - we don't exit yet
@@ -212,9 +351,118 @@ func (p PubSubBackend[Result]) ListenForNotifications(
 	return replyChan, nil
 }
 
-const OperationIDMetadataKey = "_watermill_requestreply_op_id"
+// subscribeForNotifications subscribes to the reply queue if ReplyQueues is configured, or to the notifications topic.
+// The address of the reply queue is returned, if it's used.
+// release must be called after the subscription's context is canceled.
+func (p PubSubBackend[Result]) subscribeForNotifications(
+	ctx context.Context,
+	replyContext PubSubBackendSubscribeParams,
+) (messages <-chan *message.Message, address string, release func(), err error) {
+	release = func() {}
+
+	if p.config.ReplyQueues != nil {
+		address, notifyMsgs, err := p.config.ReplyQueues.SubscribeReplyQueue(ctx, replyContext)
+		if err == nil {
+			p.config.Logger.Debug(
+				"Subscribed to request/reply queue",
+				watermill.LogFields{
+					"request_reply_queue": address,
+				},
+			)
+			return notifyMsgs, address, release, nil
+		}
+		if !errors.Is(err, ErrReplyQueuesNotSupported) {
+			return nil, "", nil, errors.Wrap(err, "cannot subscribe to request/reply queue")
+		}
+		if p.config.SubscriberConstructor == nil || p.config.GenerateSubscribeTopic == nil {
+			return nil, "", nil, errors.Wrap(
+				err,
+				"cannot fall back to request/reply notifications topic, SubscriberConstructor and GenerateSubscribeTopic are required",
+			)
+		}
+
+		p.config.Logger.Debug("Reply queues not supported, falling back to request/reply notifications topic", nil)
+	}
+
+	replyNotificationTopic, err := p.config.GenerateSubscribeTopic(replyContext)
+	if err != nil {
+		return nil, "", nil, errors.Wrap(err, "cannot generate request/reply notifications topic")
+	}
+
+	newSubscriber := func() (message.Subscriber, error) {
+		return p.config.SubscriberConstructor(replyContext)
+	}
+
+	var notificationsSubscriber message.Subscriber
+	if p.subscriberPool != nil {
+		poolKey := p.config.SubscriberPool.Key(replyContext, replyNotificationTopic)
+		notificationsSubscriber, err = p.subscriberPool.Get(poolKey, newSubscriber)
+
+		if err == nil {
+			release = func() {
+				p.subscriberPool.Put(poolKey, notificationsSubscriber)
+			}
+		}
+	} else {
+		notificationsSubscriber, err = newSubscriber()
+	}
+	if err != nil {
+		return nil, "", nil, errors.Wrap(err, "cannot create request/reply notifications subscriber")
+	}
+
+	notifyMsgs, err := notificationsSubscriber.Subscribe(ctx, replyNotificationTopic)
+	if err != nil {
+		if p.subscriberPool != nil {
+			// the subscriber may be broken, so it's not returned to the pool
+			if closeErr := notificationsSubscriber.Close(); closeErr != nil {
+				p.config.Logger.Error("Cannot close pooled request/reply subscriber", closeErr, nil)
+			}
+		}
+
+		return nil, "", nil, errors.Wrap(err, "cannot subscribe to request/reply notifications topic")
+	}
+
+	p.config.Logger.Debug(
+		"Subscribed to request/reply notifications topic",
+		watermill.LogFields{
+			"request_reply_topic": replyNotificationTopic,
+		},
+	)
+
+	return notifyMsgs, "", release, nil
+}
+
+// ModifyCommandMessage implements CommandMessageModifier.
+// It sets the address of the reply queue on the command message, if ReplyQueues is used.
+func (p PubSubBackend[Result]) ModifyCommandMessage(
+	ctx context.Context,
+	params BackendListenForNotificationsParams,
+	msg *message.Message,
+) error {
+	if address, ok := p.replyAddresses.Load(params.OperationID); ok {
+		msg.Metadata.Set(ReplyToMetadataKey, address.(string))
+	}
+
+	return nil
+}
+
+const (
+	OperationIDMetadataKey       = "_watermill_requestreply_op_id"
+	HandlerInstanceIDMetadataKey = "_watermill_requestreply_handler_instance_id"
+
+	// HandlerDurationMetadataKey, ProcessedAtMetadataKey, and AttemptMetadataKey are the reply metadata keys
+	// of Reply.HandlerDuration (in nanoseconds), Reply.ProcessedAt (in RFC 3339 format), and Reply.Attempt.
+	HandlerDurationMetadataKey = "_watermill_requestreply_handler_duration"
+	ProcessedAtMetadataKey     = "_watermill_requestreply_processed_at"
+	AttemptMetadataKey         = "_watermill_requestreply_attempt"
+)
 
 func (p PubSubBackend[Result]) OnCommandProcessed(ctx context.Context, params BackendOnCommandProcessedParams[Result]) error {
+	if p.config.ReplyOnlyWhenAcked && !p.config.AckCommandErrors && params.HandleErr != nil {
+		p.config.Logger.Debug("Command will be nacked, skipping request reply", nil)
+		return params.HandleErr
+	}
+
 	p.config.Logger.Debug("Sending request reply", nil)
 
 	notificationMsg, err := p.marshaler.MarshalReply(params)
@@ -228,6 +476,10 @@ func (p PubSubBackend[Result]) OnCommandProcessed(ctx context.Context, params Ba
 		return err
 	}
 	notificationMsg.Metadata.Set(OperationIDMetadataKey, string(operationID))
+	if p.config.HandlerInstanceID != "" {
+		notificationMsg.Metadata.Set(HandlerInstanceIDMetadataKey, p.config.HandlerInstanceID)
+	}
+	setHandlerExecutionMetadata(notificationMsg, params)
 
 	if p.config.ModifyNotificationMessage != nil {
 		processedContext := PubSubBackendOnCommandProcessedParams{
@@ -243,24 +495,32 @@ func (p PubSubBackend[Result]) OnCommandProcessed(ctx context.Context, params Ba
 		}
 	}
 
-	replyTopic, err := p.config.GeneratePublishTopic(PubSubBackendPublishParams{
+	if p.config.ReplyStore != nil && p.commandAcked(params.HandleErr) {
+		if err := p.config.ReplyStore.StoreReply(ctx, operationID, notificationMsg); err != nil {
+			return errors.Wrap(err, "cannot store reply")
+		}
+	}
+
+	err = p.publishReply(PubSubBackendPublishParams{
 		Command:        params.Command,
 		CommandMessage: params.CommandMessage,
 		OperationID:    operationID,
-	})
+	}, notificationMsg)
 	if err != nil {
-		return errors.Wrap(err, "cannot generate request/reply notify topic")
+		return err
 	}
 
-	err = p.config.Publisher.Publish(replyTopic, notificationMsg)
-	if err != nil {
-		if p.config.ReplyPublishErrorHandler != nil {
-			err = p.config.ReplyPublishErrorHandler(replyTopic, notificationMsg, err)
-		}
+	replied := LifecycleEvent{
+		OperationID:     operationID,
+		Stage:           LifecycleStageReplied,
+		CommandName:     commandName(params.Command),
+		Attempt:         params.Attempt,
+		HandlerDuration: params.HandlerDuration,
 	}
-	if err != nil {
-		return errors.Wrap(err, "cannot publish command executed message")
+	if params.HandleErr != nil {
+		replied.Error = params.HandleErr.Error()
 	}
+	p.OnLifecycleEvent(ctx, replied)
 
 	if p.config.AckCommandErrors {
 		// we are ignoring handler error - message will be acked
@@ -272,6 +532,170 @@ func (p PubSubBackend[Result]) OnCommandProcessed(ctx context.Context, params Ba
 	}
 }
 
+// commandAcked returns true if the command is acked after the handler returned handleErr.
+func (p PubSubBackend[Result]) commandAcked(handleErr error) bool {
+	return handleErr == nil || p.config.AckCommandErrors
+}
+
+func (p PubSubBackend[Result]) publishReply(params PubSubBackendPublishParams, notificationMsg *message.Message) error {
+	replyTopic := params.CommandMessage.Metadata.Get(ReplyToMetadataKey)
+	if replyTopic == "" {
+		var err error
+		replyTopic, err = p.config.GeneratePublishTopic(params)
+		if err != nil {
+			return errors.Wrap(err, "cannot generate request/reply notify topic")
+		}
+	}
+
+	err := p.config.Publisher.Publish(replyTopic, notificationMsg)
+	if err != nil {
+		if p.config.ReplyPublishErrorHandler != nil {
+			err = p.config.ReplyPublishErrorHandler(replyTopic, notificationMsg, err)
+		}
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot publish command executed message")
+	}
+
+	return nil
+}
+
+// GenerateOperationID implements OperationIDGenerator.
+// If PubSubBackendConfig.GenerateOperationID is not set, a random UUID is returned.
+func (p PubSubBackend[Result]) GenerateOperationID(ctx context.Context, cmd any) (OperationID, error) {
+	if p.config.GenerateOperationID == nil {
+		return OperationID(watermill.NewUUID()), nil
+	}
+
+	return p.config.GenerateOperationID(ctx, cmd)
+}
+
+// ValidateOperation implements OperationValidator.
+// See PubSubBackendConfig.ValidateOperationID and PubSubBackendConfig.RejectReplayedOperations.
+func (p PubSubBackend[Result]) ValidateOperation(ctx context.Context, params BackendValidateOperationParams) error {
+	if p.config.ValidateOperationID == nil && !p.config.RejectReplayedOperations {
+		return nil
+	}
+
+	operationID, err := operationIDFromMetadata(params.CommandMessage)
+	if err != nil {
+		return err
+	}
+
+	publishParams := PubSubBackendPublishParams{
+		Command:        params.Command,
+		CommandMessage: params.CommandMessage,
+		OperationID:    operationID,
+	}
+
+	if p.config.ValidateOperationID != nil {
+		err = p.config.ValidateOperationID(ctx, publishParams)
+	}
+	if err == nil && p.config.RejectReplayedOperations {
+		err = p.checkReplayed(ctx, operationID)
+	}
+	if err == nil || !errors.Is(err, ErrOperationReplayed) {
+		return err
+	}
+
+	p.config.Logger.Debug("Operation already processed, skipping command", watermill.LogFields{
+		"operation_id": operationID,
+	})
+
+	if resendErr := p.resendStoredReply(ctx, publishParams); resendErr != nil {
+		return resendErr
+	}
+
+	return err
+}
+
+func (p PubSubBackend[Result]) checkReplayed(ctx context.Context, operationID OperationID) error {
+	notificationMsg, err := p.config.ReplyStore.GetReply(ctx, operationID)
+	if errors.Is(err, ErrReplyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get reply of operation %s", operationID)
+	}
+
+	// the command of the error reply was nacked, so it's redelivered to retry the handler
+	// (the reply may be stored before AckCommandErrors was disabled)
+	if !p.config.AckCommandErrors && notificationMsg.Metadata.Get(HasErrorMetadataKey) == "1" {
+		return nil
+	}
+
+	return errors.Wrapf(ErrOperationReplayed, "operation %s", operationID)
+}
+
+func (p PubSubBackend[Result]) resendStoredReply(ctx context.Context, params PubSubBackendPublishParams) error {
+	if p.config.ReplyStore == nil {
+		return nil
+	}
+
+	notificationMsg, err := p.config.ReplyStore.GetReply(ctx, params.OperationID)
+	if errors.Is(err, ErrReplyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot get reply of operation %s", params.OperationID)
+	}
+	notificationMsg.SetContext(ctx)
+
+	return p.publishReply(params, notificationMsg)
+}
+
+// GetReply returns the persisted reply of the operation.
+// It requires ReplyStore to be set in PubSubBackendConfig.
+//
+// If the reply is not stored (yet), the error is ErrReplyNotFound.
+func (p PubSubBackend[Result]) GetReply(ctx context.Context, operationID OperationID) (Reply[Result], error) {
+	if p.config.ReplyStore == nil {
+		return Reply[Result]{}, ErrReplyStoreNotConfigured
+	}
+
+	notificationMsg, err := p.config.ReplyStore.GetReply(ctx, operationID)
+	if err != nil {
+		return Reply[Result]{}, errors.Wrapf(err, "cannot get reply of operation %s", operationID)
+	}
+
+	reply, err := p.marshaler.UnmarshalReply(notificationMsg)
+	if err != nil {
+		return Reply[Result]{}, ReplyUnmarshalError{err}
+	}
+	setReplyMetadata(&reply, notificationMsg)
+
+	return reply, nil
+}
+
+func setHandlerExecutionMetadata[Result any](msg *message.Message, params BackendOnCommandProcessedParams[Result]) {
+	if params.HandlerDuration != 0 {
+		msg.Metadata.Set(HandlerDurationMetadataKey, strconv.FormatInt(int64(params.HandlerDuration), 10))
+	}
+	if !params.ProcessedAt.IsZero() {
+		msg.Metadata.Set(ProcessedAtMetadataKey, params.ProcessedAt.Format(time.RFC3339Nano))
+	}
+	if params.Attempt != 0 {
+		msg.Metadata.Set(AttemptMetadataKey, strconv.Itoa(params.Attempt))
+	}
+}
+
+// setReplyMetadata sets the fields of the reply sent in the notification message metadata.
+// Missing or invalid values are left empty, as the handler may not send them.
+func setReplyMetadata[Result any](reply *Reply[Result], notificationMsg *message.Message) {
+	reply.NotificationMessage = notificationMsg
+	reply.HandlerInstanceID = notificationMsg.Metadata.Get(HandlerInstanceIDMetadataKey)
+
+	if duration, err := strconv.ParseInt(notificationMsg.Metadata.Get(HandlerDurationMetadataKey), 10, 64); err == nil {
+		reply.HandlerDuration = time.Duration(duration)
+	}
+	if processedAt, err := time.Parse(time.RFC3339Nano, notificationMsg.Metadata.Get(ProcessedAtMetadataKey)); err == nil {
+		reply.ProcessedAt = processedAt
+	}
+	if attempt, err := strconv.Atoi(notificationMsg.Metadata.Get(AttemptMetadataKey)); err == nil {
+		reply.Attempt = attempt
+	}
+}
+
 func operationIDFromMetadata(msg *message.Message) (OperationID, error) {
 	operationID := msg.Metadata.Get(OperationIDMetadataKey)
 	if operationID == "" {
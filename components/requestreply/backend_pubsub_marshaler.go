@@ -56,3 +56,55 @@ func (m BackendPubsubJSONMarshaler[Result]) UnmarshalReply(msg *message.Message)
 
 	return reply, nil
 }
+
+// BackendPubsubJSONEnvelopeMarshaler marshals replies as JSON with an explicit schema for the result and the error.
+// In contrast to BackendPubsubJSONMarshaler, the error is sent in the payload instead of the metadata,
+// so the whole reply can be consumed by any service understanding JSON:
+//
+//	{"result": {"id": "123"}, "error": {"message": "some error"}}
+//
+// The "error" field is omitted when the handler didn't return an error.
+type BackendPubsubJSONEnvelopeMarshaler[Result any] struct{}
+
+type jsonReplyEnvelope[Result any] struct {
+	Result Result                  `json:"result"`
+	Error  *jsonReplyEnvelopeError `json:"error,omitempty"`
+}
+
+type jsonReplyEnvelopeError struct {
+	Message string `json:"message"`
+}
+
+func (m BackendPubsubJSONEnvelopeMarshaler[Result]) MarshalReply(
+	params BackendOnCommandProcessedParams[Result],
+) (*message.Message, error) {
+	envelope := jsonReplyEnvelope[Result]{
+		Result: params.HandlerResult,
+	}
+	if params.HandleErr != nil {
+		envelope.Error = &jsonReplyEnvelopeError{Message: params.HandleErr.Error()}
+	}
+
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal reply")
+	}
+
+	return message.NewMessage(watermill.NewUUID(), b), nil
+}
+
+func (m BackendPubsubJSONEnvelopeMarshaler[Result]) UnmarshalReply(msg *message.Message) (Reply[Result], error) {
+	var envelope jsonReplyEnvelope[Result]
+	if err := json.Unmarshal(msg.Payload, &envelope); err != nil {
+		return Reply[Result]{}, errors.Wrap(err, "cannot unmarshal reply")
+	}
+
+	reply := Reply[Result]{
+		HandlerResult: envelope.Result,
+	}
+	if envelope.Error != nil {
+		reply.Error = errors.New(envelope.Error.Message)
+	}
+
+	return reply, nil
+}
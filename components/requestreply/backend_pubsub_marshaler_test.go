@@ -0,0 +1,51 @@
+package requestreply_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+func TestBackendPubsubJSONEnvelopeMarshaler(t *testing.T) {
+	marshaler := requestreply.BackendPubsubJSONEnvelopeMarshaler[TestCommandResult]{}
+
+	t.Run("without_error", func(t *testing.T) {
+		msg, err := marshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResult]{
+			HandlerResult: TestCommandResult{ID: "123"},
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"result": {"id": "123"}}`, string(msg.Payload))
+
+		reply, err := marshaler.UnmarshalReply(msg)
+		require.NoError(t, err)
+		assert.Equal(t, TestCommandResult{ID: "123"}, reply.HandlerResult)
+		assert.NoError(t, reply.Error)
+	})
+
+	t.Run("with_error", func(t *testing.T) {
+		msg, err := marshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResult]{
+			HandlerResult: TestCommandResult{ID: "123"},
+			HandleErr:     errors.New("some error"),
+		})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"result": {"id": "123"}, "error": {"message": "some error"}}`, string(msg.Payload))
+
+		reply, err := marshaler.UnmarshalReply(msg)
+		require.NoError(t, err)
+		assert.Equal(t, TestCommandResult{ID: "123"}, reply.HandlerResult)
+		assert.EqualError(t, reply.Error, "some error")
+	})
+
+	t.Run("invalid_payload", func(t *testing.T) {
+		msg, err := marshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResult]{})
+		require.NoError(t, err)
+		msg.Payload = []byte("not json")
+
+		_, err = marshaler.UnmarshalReply(msg)
+		assert.Error(t, err)
+	})
+}
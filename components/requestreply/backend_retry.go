@@ -0,0 +1,174 @@
+package requestreply
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// BackendRetryConfig configures the decorator returned by NewRetryBackendDecorator.
+type BackendRetryConfig struct {
+	// MaxRetries is the maximum number of retries of a call. Defaults to 3.
+	MaxRetries int
+
+	// InitialInterval is the first interval between retries. Subsequent intervals are scaled by Multiplier.
+	// Defaults to 100ms.
+	InitialInterval time.Duration
+
+	// MaxInterval is the limit of the interval between retries. Defaults to 1s.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor by which the interval is multiplied between retries. Defaults to 2.
+	Multiplier float64
+
+	// ShouldRetry decides if the failed call should be retried.
+	// If not provided, all failures are retried.
+	ShouldRetry func(call BackendCall, err error) bool
+
+	// Clock is used to wait between retries. If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	Logger watermill.LoggerAdapter
+}
+
+func (c *BackendRetryConfig) setDefaults() {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.InitialInterval == 0 {
+		c.InitialInterval = 100 * time.Millisecond
+	}
+	if c.MaxInterval == 0 {
+		c.MaxInterval = time.Second
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = 2
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c BackendRetryConfig) Validate() error {
+	if c.MaxRetries < 0 {
+		return errors.New("MaxRetries must be non-negative")
+	}
+	if c.InitialInterval < 0 || c.MaxInterval < 0 {
+		return errors.New("retry intervals must be non-negative")
+	}
+	if c.Multiplier < 1 {
+		return errors.New("Multiplier must be at least 1")
+	}
+
+	return nil
+}
+
+// NewRetryBackendDecorator returns a decorator retrying the failed calls of the backend,
+// for example, when publishing the reply fails because of a temporary outage of the Pub/Sub.
+//
+// The handler's error returned by OnCommandProcessed, so the command is nacked, is never retried.
+// Keep in mind that OnCommandProcessed may publish the reply more than once if it fails after publishing,
+// for example, when PubSubBackendConfig.ReplyStore is used.
+func NewRetryBackendDecorator[Result any](config BackendRetryConfig) (BackendDecorator[Result], error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return func(backend Backend[Result]) Backend[Result] {
+		return retryBackend[Result]{
+			next:   backend,
+			config: config,
+		}
+	}, nil
+}
+
+type retryBackend[Result any] struct {
+	next   Backend[Result]
+	config BackendRetryConfig
+}
+
+func (b retryBackend[Result]) Unwrap() Backend[Result] {
+	return b.next
+}
+
+func (b retryBackend[Result]) ListenForNotifications(
+	ctx context.Context,
+	params BackendListenForNotificationsParams,
+) (<-chan Reply[Result], error) {
+	var replies <-chan Reply[Result]
+
+	err := b.retry(ctx, listenForNotificationsCall(params), func() error {
+		var err error
+		replies, err = b.next.ListenForNotifications(ctx, params)
+		return err
+	})
+
+	return replies, err
+}
+
+func (b retryBackend[Result]) OnCommandProcessed(ctx context.Context, params BackendOnCommandProcessedParams[Result]) error {
+	var err error
+
+	retryErr := b.retry(ctx, onCommandProcessedCall(params), func() error {
+		err = b.next.OnCommandProcessed(ctx, params)
+		return backendErr(err, params)
+	})
+	if retryErr != nil {
+		return retryErr
+	}
+
+	// the handler's error, if the command should be nacked
+	return err
+}
+
+func (b retryBackend[Result]) retry(ctx context.Context, call BackendCall, fn func() error) error {
+	err := fn()
+	if err == nil {
+		return nil
+	}
+
+	expBackoff := backoff.NewExponentialBackOff()
+	expBackoff.InitialInterval = b.config.InitialInterval
+	expBackoff.MaxInterval = b.config.MaxInterval
+	expBackoff.Multiplier = b.config.Multiplier
+	expBackoff.MaxElapsedTime = 0
+	expBackoff.Reset()
+
+	for retryNum := 1; retryNum <= b.config.MaxRetries; retryNum++ {
+		if b.config.ShouldRetry != nil && !b.config.ShouldRetry(call, err) {
+			return err
+		}
+
+		waitTime := expBackoff.NextBackOff()
+
+		b.config.Logger.Error("Request/reply backend call failed, retrying", err, watermill.LogFields{
+			"backend_method": call.Method,
+			"operation_id":   call.OperationID,
+			"retry_no":       retryNum,
+			"max_retries":    b.config.MaxRetries,
+			"wait_time":      waitTime,
+		})
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-b.config.Clock.After(waitTime):
+			// go on
+		}
+
+		err = fn()
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,170 @@
+package requestreply_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// flakyBackend fails the calls until failures are used up.
+type flakyBackend struct {
+	failures *int
+	calls    *int
+	err      error
+}
+
+func newFlakyBackend(failures int, err error) flakyBackend {
+	return flakyBackend{failures: &failures, calls: new(int), err: err}
+}
+
+func (b flakyBackend) call() error {
+	*b.calls++
+	if *b.failures > 0 {
+		*b.failures--
+		return b.err
+	}
+
+	return nil
+}
+
+func (b flakyBackend) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[requestreply.NoResult], error) {
+	if err := b.call(); err != nil {
+		return nil, err
+	}
+
+	return make(chan requestreply.Reply[requestreply.NoResult]), nil
+}
+
+func (b flakyBackend) OnCommandProcessed(
+	ctx context.Context,
+	params requestreply.BackendOnCommandProcessedParams[requestreply.NoResult],
+) error {
+	if err := b.call(); err != nil {
+		return err
+	}
+
+	return params.HandleErr
+}
+
+func newTestRetryDecorator(t *testing.T, config requestreply.BackendRetryConfig) requestreply.BackendDecorator[requestreply.NoResult] {
+	t.Helper()
+
+	config.InitialInterval = time.Millisecond
+	config.MaxInterval = time.Millisecond
+
+	decorator, err := requestreply.NewRetryBackendDecorator[requestreply.NoResult](config)
+	require.NoError(t, err)
+
+	return decorator
+}
+
+func TestNewRetryBackendDecorator(t *testing.T) {
+	backendErr := errors.New("backend error")
+	decorator := newTestRetryDecorator(t, requestreply.BackendRetryConfig{MaxRetries: 2})
+
+	t.Run("recovered", func(t *testing.T) {
+		flaky := newFlakyBackend(2, backendErr)
+
+		replies, err := decorator(flaky).ListenForNotifications(
+			context.Background(),
+			requestreply.BackendListenForNotificationsParams{},
+		)
+		require.NoError(t, err)
+		assert.NotNil(t, replies)
+		assert.Equal(t, 3, *flaky.calls)
+	})
+
+	t.Run("retries_exceeded", func(t *testing.T) {
+		flaky := newFlakyBackend(3, backendErr)
+
+		err := decorator(flaky).OnCommandProcessed(
+			context.Background(),
+			requestreply.BackendOnCommandProcessedParams[requestreply.NoResult]{CommandMessage: message.NewMessage("1", nil)},
+		)
+		assert.Equal(t, backendErr, err)
+		assert.Equal(t, 3, *flaky.calls)
+	})
+
+	t.Run("handler_error", func(t *testing.T) {
+		flaky := newFlakyBackend(1, backendErr)
+		handlerErr := errors.New("handler error")
+
+		err := decorator(flaky).OnCommandProcessed(
+			context.Background(),
+			requestreply.BackendOnCommandProcessedParams[requestreply.NoResult]{
+				CommandMessage: message.NewMessage("1", nil),
+				HandleErr:      handlerErr,
+			},
+		)
+		assert.Equal(t, handlerErr, err, "the handler's error should be returned, without retrying")
+		assert.Equal(t, 2, *flaky.calls)
+	})
+
+	t.Run("uncomparable_handler_error", func(t *testing.T) {
+		flaky := newFlakyBackend(0, backendErr)
+		handlerErr := validationErrors{"id": "missing"}
+
+		var err error
+		require.NotPanics(t, func() {
+			err = decorator(flaky).OnCommandProcessed(
+				context.Background(),
+				requestreply.BackendOnCommandProcessedParams[requestreply.NoResult]{
+					CommandMessage: message.NewMessage("1", nil),
+					HandleErr:      handlerErr,
+				},
+			)
+		})
+		assert.Equal(t, handlerErr, err)
+		assert.Equal(t, 1, *flaky.calls, "the handler's error should not be retried")
+	})
+}
+
+func TestNewRetryBackendDecorator_should_retry(t *testing.T) {
+	backendErr := errors.New("backend error")
+
+	decorator := newTestRetryDecorator(t, requestreply.BackendRetryConfig{
+		ShouldRetry: func(call requestreply.BackendCall, err error) bool {
+			return call.Method != requestreply.BackendMethodListenForNotifications
+		},
+	})
+
+	flaky := newFlakyBackend(1, backendErr)
+
+	_, err := decorator(flaky).ListenForNotifications(context.Background(), requestreply.BackendListenForNotificationsParams{})
+	assert.Equal(t, backendErr, err)
+	assert.Equal(t, 1, *flaky.calls)
+}
+
+func TestNewRetryBackendDecorator_context_canceled(t *testing.T) {
+	backendErr := errors.New("backend error")
+
+	decorator, err := requestreply.NewRetryBackendDecorator[requestreply.NoResult](requestreply.BackendRetryConfig{
+		InitialInterval: time.Hour,
+		MaxInterval:     time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	flaky := newFlakyBackend(1, backendErr)
+
+	_, err = decorator(flaky).ListenForNotifications(ctx, requestreply.BackendListenForNotificationsParams{})
+	assert.Equal(t, backendErr, err)
+	assert.Equal(t, 1, *flaky.calls)
+}
+
+func TestNewRetryBackendDecorator_invalid_config(t *testing.T) {
+	_, err := requestreply.NewRetryBackendDecorator[requestreply.NoResult](requestreply.BackendRetryConfig{Multiplier: 0.5})
+	assert.Error(t, err)
+}
@@ -0,0 +1,264 @@
+package requestreply
+
+import (
+	"context"
+	stdErrors "errors"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ErrOperationCancelled is the cause of the handler's context cancellation when the caller cancelled the operation.
+// It's also returned by the handlers wrapped with Cancellable when the operation was cancelled before they started.
+var ErrOperationCancelled = errors.New("operation cancelled by the caller")
+
+// CancellationsConfig configures Cancellations.
+type CancellationsConfig struct {
+	// Topic is the topic to which cancellation signals are published. It is required.
+	Topic string
+
+	// Publisher is used to publish cancellation signals. It's required to call Cancel.
+	Publisher message.Publisher
+
+	// Subscriber is used to receive cancellation signals. It's required to call AddHandlerToRouter.
+	//
+	// Every instance processing commands must receive all cancellation signals,
+	// so the subscriber must not share the consumer group with other instances.
+	Subscriber message.Subscriber
+
+	// Retention is how long the cancelled operations are remembered, so the commands received
+	// after the cancellation are not handled. The default is 1 hour.
+	Retention time.Duration
+
+	// Clock is used to expire the cancelled operations.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *CancellationsConfig) setDefaults() {
+	if c.Retention == 0 {
+		c.Retention = time.Hour
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c CancellationsConfig) Validate() error {
+	var err error
+
+	if c.Topic == "" {
+		err = stdErrors.Join(err, errors.New("missing Topic"))
+	}
+	if c.Publisher == nil && c.Subscriber == nil {
+		err = stdErrors.Join(err, errors.New("missing Publisher or Subscriber"))
+	}
+	if c.Retention < 0 {
+		err = stdErrors.Join(err, errors.New("Retention must not be negative"))
+	}
+
+	return err
+}
+
+// Cancellations allows the caller to abort the processing of commands sent with SendWithReply or SendWithReplies.
+//
+// The caller sends a cancellation signal with Cancel. On the processor side, the handlers wrapped with Cancellable
+// (or CancellableWithResult) have their context cancelled with ErrOperationCancelled as the cause,
+// if they are still running. Commands received after the cancellation are not handled.
+// Long-running handlers which don't watch the context can poll IsOperationCancelled instead.
+type Cancellations struct {
+	config CancellationsConfig
+
+	running   map[OperationID]map[*context.CancelCauseFunc]struct{}
+	cancelled map[OperationID]time.Time
+	lock      sync.Mutex
+}
+
+// NewCancellations creates a new Cancellations.
+func NewCancellations(config CancellationsConfig) (*Cancellations, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Cancellations{
+		config:    config,
+		running:   map[OperationID]map[*context.CancelCauseFunc]struct{}{},
+		cancelled: map[OperationID]time.Time{},
+	}, nil
+}
+
+// Cancel sends the cancellation signal of the operation to all processors.
+func (c *Cancellations) Cancel(ctx context.Context, operationID OperationID) error {
+	if c.config.Publisher == nil {
+		return errors.New("missing Publisher in config")
+	}
+	if operationID == "" {
+		return errors.New("missing operation ID")
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	msg.Metadata.Set(OperationIDMetadataKey, string(operationID))
+	msg.SetContext(ctx)
+
+	if err := c.config.Publisher.Publish(c.config.Topic, msg); err != nil {
+		return errors.Wrap(err, "cannot publish cancellation")
+	}
+
+	return nil
+}
+
+// AddHandlerToRouter adds the handler receiving cancellation signals to the router.
+func (c *Cancellations) AddHandlerToRouter(handlerName string, r *message.Router) (*message.Handler, error) {
+	if c.config.Subscriber == nil {
+		return nil, errors.New("missing Subscriber in config")
+	}
+
+	return r.AddNoPublisherHandler(handlerName, c.config.Topic, c.config.Subscriber, c.handleCancellation), nil
+}
+
+func (c *Cancellations) handleCancellation(msg *message.Message) error {
+	operationID, err := operationIDFromMetadata(msg)
+	if err != nil {
+		c.config.Logger.Error("Invalid cancellation signal, ignoring", err, watermill.LogFields{
+			"message_uuid": msg.UUID,
+		})
+		return nil
+	}
+
+	c.config.Logger.Debug("Cancelling operation", watermill.LogFields{
+		"operation_id": operationID,
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.config.Clock.Now()
+	c.removeExpired(now)
+	c.cancelled[operationID] = now
+
+	for cancel := range c.running[operationID] {
+		(*cancel)(ErrOperationCancelled)
+	}
+
+	return nil
+}
+
+func (c *Cancellations) removeExpired(now time.Time) {
+	for operationID, cancelledAt := range c.cancelled {
+		if now.Sub(cancelledAt) > c.config.Retention {
+			delete(c.cancelled, operationID)
+		}
+	}
+}
+
+// IsCancelled returns true if the operation was cancelled by the caller.
+func (c *Cancellations) IsCancelled(operationID OperationID) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.isCancelled(operationID)
+}
+
+func (c *Cancellations) isCancelled(operationID OperationID) bool {
+	cancelledAt, ok := c.cancelled[operationID]
+	return ok && c.config.Clock.Now().Sub(cancelledAt) <= c.config.Retention
+}
+
+// IsOperationCancelled returns true if the context was cancelled, because the caller cancelled the operation.
+func IsOperationCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrOperationCancelled)
+}
+
+// track returns the context cancelled when the operation is cancelled.
+// It returns ErrOperationCancelled if the operation was already cancelled.
+func (c *Cancellations) track(ctx context.Context, operationID OperationID) (context.Context, func(), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.isCancelled(operationID) {
+		return nil, nil, ErrOperationCancelled
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	if _, ok := c.running[operationID]; !ok {
+		c.running[operationID] = map[*context.CancelCauseFunc]struct{}{}
+	}
+	c.running[operationID][&cancel] = struct{}{}
+
+	return ctx, func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.running[operationID], &cancel)
+		if len(c.running[operationID]) == 0 {
+			delete(c.running, operationID)
+		}
+
+		cancel(nil)
+	}, nil
+}
+
+func (c *Cancellations) trackCommand(ctx context.Context) (context.Context, func(), error) {
+	originalMessage, err := originalCommandMsgFromCtx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	operationID, err := operationIDFromMetadata(originalMessage)
+	if err != nil {
+		// the command was sent without request-reply, so it can't be cancelled
+		return ctx, func() {}, nil
+	}
+
+	return c.track(ctx, operationID)
+}
+
+// Cancellable wraps the handler of NewCommandHandler, so it can be cancelled by the caller with Cancellations.Cancel.
+//
+// The handler's context is cancelled with ErrOperationCancelled as the cause (see IsOperationCancelled).
+// If the operation was cancelled before the command was received, the handler is not called
+// and ErrOperationCancelled is returned.
+func Cancellable[Command any](
+	c *Cancellations,
+	handleFunc func(ctx context.Context, cmd *Command) error,
+) func(ctx context.Context, cmd *Command) error {
+	return func(ctx context.Context, cmd *Command) error {
+		ctx, done, err := c.trackCommand(ctx)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		return handleFunc(ctx, cmd)
+	}
+}
+
+// CancellableWithResult is like Cancellable, but for the handlers of NewCommandHandlerWithResult.
+func CancellableWithResult[Command any, Result any](
+	c *Cancellations,
+	handleFunc func(ctx context.Context, cmd *Command) (Result, error),
+) func(ctx context.Context, cmd *Command) (Result, error) {
+	return func(ctx context.Context, cmd *Command) (Result, error) {
+		ctx, done, err := c.trackCommand(ctx)
+		if err != nil {
+			var result Result
+			return result, err
+		}
+		defer done()
+
+		return handleFunc(ctx, cmd)
+	}
+}
@@ -0,0 +1,155 @@
+package requestreply_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+func newTestCancellations(t *testing.T, ts TestServices[requestreply.NoResult]) *requestreply.Cancellations {
+	t.Helper()
+
+	cancellations, err := requestreply.NewCancellations(requestreply.CancellationsConfig{
+		Topic:      "cancellations",
+		Publisher:  ts.PubSub,
+		Subscriber: ts.PubSub,
+		Logger:     ts.Logger,
+	})
+	require.NoError(t, err)
+
+	_, err = cancellations.AddHandlerToRouter("cancellations", ts.Router)
+	require.NoError(t, err)
+
+	return cancellations
+}
+
+func TestCancellations_running_handler(t *testing.T) {
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{})
+	cancellations := newTestCancellations(t, ts)
+
+	handlerStarted := make(chan struct{})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandler[TestCommand](
+			"test_handler",
+			ts.RequestReplyBackend,
+			requestreply.Cancellable(cancellations, func(ctx context.Context, cmd *TestCommand) error {
+				close(handlerStarted)
+				<-ctx.Done()
+
+				assert.True(t, requestreply.IsOperationCancelled(ctx))
+				return context.Cause(ctx)
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		requestreply.ContextWithOperationID(context.Background(), operationID),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	select {
+	case <-handlerStarted:
+	case <-time.After(time.Second):
+		t.Fatal("handler not started")
+	}
+
+	require.NoError(t, cancellations.Cancel(context.Background(), operationID))
+
+	select {
+	case reply := <-replyCh:
+		require.Error(t, reply.Error)
+		assert.Contains(t, reply.Error.Error(), requestreply.ErrOperationCancelled.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	assert.True(t, cancellations.IsCancelled(operationID))
+	assert.False(t, cancellations.IsCancelled(requestreply.OperationID(watermill.NewUUID())))
+}
+
+func TestCancellations_cancelled_before_received(t *testing.T) {
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{})
+	cancellations := newTestCancellations(t, ts)
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandler[TestCommand](
+			"test_handler",
+			ts.RequestReplyBackend,
+			requestreply.Cancellable(cancellations, func(ctx context.Context, cmd *TestCommand) error {
+				t.Error("handler should not be called")
+				return nil
+			}),
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	require.NoError(t, cancellations.Cancel(context.Background(), operationID))
+	require.Eventually(t, func() bool {
+		return cancellations.IsCancelled(operationID)
+	}, time.Second, time.Millisecond*5)
+
+	reply, err := requestreply.SendWithReply[requestreply.NoResult](
+		requestreply.ContextWithOperationID(context.Background(), operationID),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.Error(t, reply.Error)
+	assert.Contains(t, reply.Error.Error(), requestreply.ErrOperationCancelled.Error())
+}
+
+func TestCancellations_retention(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{})
+
+	cancellations, err := requestreply.NewCancellations(requestreply.CancellationsConfig{
+		Topic:      "cancellations",
+		Publisher:  ts.PubSub,
+		Subscriber: ts.PubSub,
+		Retention:  time.Minute,
+		Clock:      clock,
+	})
+	require.NoError(t, err)
+
+	_, err = cancellations.AddHandlerToRouter("cancellations", ts.Router)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+	require.NoError(t, cancellations.Cancel(context.Background(), operationID))
+
+	require.Eventually(t, func() bool {
+		return cancellations.IsCancelled(operationID)
+	}, time.Second, time.Millisecond*5)
+
+	clock.Advance(time.Minute + time.Second)
+	assert.False(t, cancellations.IsCancelled(operationID))
+}
+
+func TestNewCancellations_invalid_config(t *testing.T) {
+	_, err := requestreply.NewCancellations(requestreply.CancellationsConfig{})
+	assert.ErrorContains(t, err, "missing Topic")
+	assert.ErrorContains(t, err, "missing Publisher or Subscriber")
+}
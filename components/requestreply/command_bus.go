@@ -68,6 +68,7 @@ func SendWithReply[Result any](
 // by exceeding the timeout set in the backend (if set).
 // Warning: It's important to cancel the function, because it's listening for the replies in the background.
 // Lack of cancelling the function can lead to subscriber leak.
+// With Go 1.23 or newer, you can use Replies instead, which cancels it when the loop exits.
 //
 // SendWithReplies can listen for handlers with results (NewCommandHandlerWithResult) and without results (NewCommandHandler).
 // If you are listening for handlers without results, you should pass `NoResult` or `struct{}` as `Result` generic type:
@@ -93,6 +94,10 @@ func SendWithReply[Result any](
 //   - when the handler returns an error, and backend is configured to nack the message on error
 //     (for the PubSubBackend, it depends on `PubSubBackendConfig.AckCommandErrors` option.),
 //   - when you are using fan-out mechanism and commands are handled multiple times,
+//
+// By default, a new operation ID is generated for each command.
+// You can provide your own operation ID with ContextWithOperationID,
+// or generate it in the backend (see OperationIDGenerator), for example from business keys of the command.
 func SendWithReplies[Result any](
 	ctx context.Context,
 	c CommandBus,
@@ -107,22 +112,60 @@ func SendWithReplies[Result any](
 		}
 	}()
 
-	operationID := watermill.NewUUID()
+	operationID, err := operationIDForCommand(ctx, backend, cmd)
+	if err != nil {
+		return nil, cancel, err
+	}
 
 	replyChan, err := backend.ListenForNotifications(ctx, BackendListenForNotificationsParams{
 		Command:     cmd,
-		OperationID: OperationID(operationID),
+		OperationID: operationID,
 	})
 	if err != nil {
 		return nil, cancel, errors.Wrap(err, "cannot listen for reply")
 	}
 
 	if err := c.SendWithModifiedMessage(ctx, cmd, func(m *message.Message) error {
-		m.Metadata.Set(OperationIDMetadataKey, operationID)
+		m.Metadata.Set(OperationIDMetadataKey, string(operationID))
+
+		if modifier, ok := backendAs[CommandMessageModifier](backend); ok {
+			return modifier.ModifyCommandMessage(ctx, BackendListenForNotificationsParams{
+				Command:     cmd,
+				OperationID: operationID,
+			}, m)
+		}
+
 		return nil
 	}); err != nil {
 		return nil, cancel, errors.Wrap(err, "cannot send command")
 	}
 
+	emitLifecycleEvent(ctx, backend, LifecycleEvent{
+		OperationID: operationID,
+		Stage:       LifecycleStageSent,
+		CommandName: commandName(cmd),
+	})
+
 	return replyChan, cancel, nil
 }
+
+func operationIDForCommand[Result any](ctx context.Context, backend Backend[Result], cmd any) (OperationID, error) {
+	if operationID, ok := OperationIDFromContext(ctx); ok {
+		return operationID, nil
+	}
+
+	generator, ok := backendAs[OperationIDGenerator](backend)
+	if !ok {
+		return OperationID(watermill.NewUUID()), nil
+	}
+
+	operationID, err := generator.GenerateOperationID(ctx, cmd)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot generate operation ID")
+	}
+	if operationID == "" {
+		return "", errors.New("generated operation ID is empty")
+	}
+
+	return operationID, nil
+}
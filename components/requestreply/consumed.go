@@ -0,0 +1,100 @@
+package requestreply
+
+import (
+	"context"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// CommandConsumedMetadataKey is set on the notifications sent when the command is consumed by the handler
+// (see PubSubBackendConfig.NotifyCommandConsumed). Such notifications carry no reply.
+const CommandConsumedMetadataKey = "_watermill_requestreply_command_consumed"
+
+// TimeoutPhase tells how far the operation got when listening for the reply timed out.
+type TimeoutPhase string
+
+const (
+	// TimeoutPhaseUnknown is used when the backend doesn't track the consumption of commands.
+	TimeoutPhaseUnknown TimeoutPhase = ""
+
+	// TimeoutPhaseNotConsumed means the command was never consumed by a handler.
+	// It usually points to a routing problem (for example, no handler subscribes to the topic), so retrying
+	// the command is unlikely to help.
+	TimeoutPhaseNotConsumed TimeoutPhase = "not_consumed"
+
+	// TimeoutPhaseHandling means the command was consumed, but the handler didn't reply in time.
+	// The handler may be just slow, so it may still process the command.
+	TimeoutPhaseHandling TimeoutPhase = "handling"
+)
+
+// CommandConsumptionNotifier is an optional interface of Backend.
+// If the backend implements it, NewCommandHandler and NewCommandHandlerWithResult call it
+// when the command is consumed, before the handler is called.
+type CommandConsumptionNotifier interface {
+	// OnCommandConsumed is called for every attempt of handling the command.
+	// Failures are not returned, as the notification must not break handling the command.
+	OnCommandConsumed(ctx context.Context, params BackendOnCommandConsumedParams)
+}
+
+type BackendOnCommandConsumedParams struct {
+	Command        any
+	CommandMessage *message.Message
+
+	Attempt int
+}
+
+func notifyCommandConsumed[Result any](ctx context.Context, backend Backend[Result], params BackendOnCommandConsumedParams) {
+	if notifier, ok := backendAs[CommandConsumptionNotifier](backend); ok {
+		notifier.OnCommandConsumed(ctx, params)
+	}
+}
+
+// OnCommandConsumed implements CommandConsumptionNotifier.
+// It publishes the notification with CommandConsumedMetadataKey to the caller, if PubSubBackendConfig.NotifyCommandConsumed is set.
+func (p PubSubBackend[Result]) OnCommandConsumed(ctx context.Context, params BackendOnCommandConsumedParams) {
+	if !p.config.NotifyCommandConsumed {
+		return
+	}
+
+	operationID, err := operationIDFromMetadata(params.CommandMessage)
+	if err != nil {
+		p.config.Logger.Error("Cannot send request/reply command consumed notification", err, nil)
+		return
+	}
+
+	notificationMsg := message.NewMessage(watermill.NewUUID(), nil)
+	notificationMsg.SetContext(ctx)
+	notificationMsg.Metadata.Set(OperationIDMetadataKey, string(operationID))
+	notificationMsg.Metadata.Set(CommandConsumedMetadataKey, "true")
+	if p.config.HandlerInstanceID != "" {
+		notificationMsg.Metadata.Set(HandlerInstanceIDMetadataKey, p.config.HandlerInstanceID)
+	}
+
+	err = p.publishReply(PubSubBackendPublishParams{
+		Command:        params.Command,
+		CommandMessage: params.CommandMessage,
+		OperationID:    operationID,
+	}, notificationMsg)
+	if err != nil {
+		p.config.Logger.Error("Cannot send request/reply command consumed notification", err, watermill.LogFields{
+			"operation_id": operationID,
+		})
+	}
+}
+
+func isCommandConsumedNotification(msg *message.Message) bool {
+	return msg.Metadata.Get(CommandConsumedMetadataKey) != ""
+}
+
+// timeoutPhase returns the phase of the operation when listening for the reply timed out.
+func (p PubSubBackend[Result]) timeoutPhase(consumed bool) TimeoutPhase {
+	if !p.config.NotifyCommandConsumed {
+		return TimeoutPhaseUnknown
+	}
+	if consumed {
+		return TimeoutPhaseHandling
+	}
+
+	return TimeoutPhaseNotConsumed
+}
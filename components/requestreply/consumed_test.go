@@ -0,0 +1,141 @@
+package requestreply_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+func receiveTimeoutError(t *testing.T, replyCh <-chan requestreply.Reply[requestreply.NoResult]) requestreply.ReplyTimeoutError {
+	t.Helper()
+
+	select {
+	case reply := <-replyCh:
+		var timeoutErr requestreply.ReplyTimeoutError
+		require.ErrorAs(t, reply.Error, &timeoutErr)
+		return timeoutErr
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reply")
+		return requestreply.ReplyTimeoutError{}
+	}
+}
+
+func TestPubSubBackend_NotifyCommandConsumed_handler_slow(t *testing.T) {
+	timeout := time.Millisecond * 100
+
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		ListenForReplyTimeout: &timeout,
+		NotifyCommandConsumed: true,
+		HandlerInstanceID:     "instance-1",
+	})
+
+	release := make(chan struct{})
+	t.Cleanup(func() {
+		close(release)
+	})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandler(
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) error {
+				<-release
+				return nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	timeoutErr := receiveTimeoutError(t, replyCh)
+	assert.Equal(t, requestreply.TimeoutPhaseHandling, timeoutErr.Phase)
+	assert.ErrorIs(t, timeoutErr.Err, context.DeadlineExceeded)
+	assert.Contains(t, timeoutErr.Error(), "(handling)")
+}
+
+func TestPubSubBackend_NotifyCommandConsumed_not_consumed(t *testing.T) {
+	timeout := time.Millisecond * 100
+
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		ListenForReplyTimeout: &timeout,
+		NotifyCommandConsumed: true,
+	})
+
+	// no handler subscribes to the commands topic
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	timeoutErr := receiveTimeoutError(t, replyCh)
+	assert.Equal(t, requestreply.TimeoutPhaseNotConsumed, timeoutErr.Phase)
+}
+
+func TestPubSubBackend_NotifyCommandConsumed_reply(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		NotifyCommandConsumed: true,
+	})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	// the consumed notification is not returned as a reply
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, reply.Error)
+	assert.Equal(t, TestCommandResult{ID: "1"}, reply.HandlerResult)
+}
+
+func TestPubSubBackend_timeout_phase_unknown(t *testing.T) {
+	timeout := time.Millisecond * 100
+
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		ListenForReplyTimeout: &timeout,
+	})
+
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	timeoutErr := receiveTimeoutError(t, replyCh)
+	assert.Equal(t, requestreply.TimeoutPhaseUnknown, timeoutErr.Phase)
+}
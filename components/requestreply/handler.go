@@ -2,6 +2,7 @@ package requestreply
 
 import (
 	"context"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill/components/cqrs"
 	"github.com/ThreeDotsLabs/watermill/message"
@@ -13,23 +14,20 @@ import (
 //
 // The logic if a command should be acked or not is based on the logic of the Backend.
 // For example, for the PubSubBackend, it depends on the `PubSubBackendConfig.AckCommandErrors` option.
+//
+// If the backend implements OperationValidator, the operation is validated before the handler is called.
+// If the backend implements LifecycleObserver, LifecycleStageReceived is reported before the handler is called.
+// If the backend implements CommandConsumptionNotifier, it's notified before the handler is called.
 func NewCommandHandler[Command any](
 	handlerName string,
 	backend Backend[struct{}],
 	handleFunc func(ctx context.Context, cmd *Command) error,
 ) cqrs.CommandHandler {
-	return cqrs.NewCommandHandler(handlerName, func(ctx context.Context, cmd *Command) error {
-		handlerErr := handleFunc(ctx, cmd)
+	attempts := newHandlerAttempts()
 
-		originalMessage, err := originalCommandMsgFromCtx(ctx)
-		if err != nil {
-			return err
-		}
-
-		return backend.OnCommandProcessed(ctx, BackendOnCommandProcessedParams[struct{}]{
-			Command:        cmd,
-			CommandMessage: originalMessage,
-			HandleErr:      handlerErr,
+	return cqrs.NewCommandHandler(handlerName, func(ctx context.Context, cmd *Command) error {
+		return handleCommand(ctx, backend, cmd, attempts, func() (struct{}, error) {
+			return struct{}{}, handleFunc(ctx, cmd)
 		})
 	})
 }
@@ -44,26 +42,76 @@ func NewCommandHandler[Command any](
 // For example, for the PubSubBackend, it depends on the `PubSubBackendConfig.AckCommandErrors` option.
 //
 // The reply is sent to the caller, even if the handler returns an error.
+//
+// If the backend implements OperationValidator, the operation is validated before the handler is called.
+// If the backend implements LifecycleObserver, LifecycleStageReceived is reported before the handler is called.
+// If the backend implements CommandConsumptionNotifier, it's notified before the handler is called.
 func NewCommandHandlerWithResult[Command any, Result any](
 	handlerName string,
 	backend Backend[Result],
 	handleFunc func(ctx context.Context, cmd *Command) (Result, error),
 ) cqrs.CommandHandler {
+	attempts := newHandlerAttempts()
+
 	return cqrs.NewCommandHandler(handlerName, func(ctx context.Context, cmd *Command) error {
-		resp, handlerErr := handleFunc(ctx, cmd)
-
-		originalMessage, err := originalCommandMsgFromCtx(ctx)
-		if err != nil {
-			return err
-		}
-
-		return backend.OnCommandProcessed(ctx, BackendOnCommandProcessedParams[Result]{
-			Command:        cmd,
-			CommandMessage: originalMessage,
-			HandlerResult:  resp,
-			HandleErr:      handlerErr,
+		return handleCommand(ctx, backend, cmd, attempts, func() (Result, error) {
+			return handleFunc(ctx, cmd)
+		})
+	})
+}
+
+func handleCommand[Result any](
+	ctx context.Context,
+	backend Backend[Result],
+	cmd any,
+	attempts *handlerAttempts,
+	handle func() (Result, error),
+) error {
+	originalMessage, err := originalCommandMsgFromCtx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if replayed, err := validateOperation(ctx, backend, cmd, originalMessage); err != nil || replayed {
+		return err
+	}
+
+	attempt := attempts.Next(originalMessage.UUID)
+
+	if operationID, err := operationIDFromMetadata(originalMessage); err == nil {
+		emitLifecycleEvent(ctx, backend, LifecycleEvent{
+			OperationID: operationID,
+			Stage:       LifecycleStageReceived,
+			CommandName: commandName(cmd),
+			Attempt:     attempt,
 		})
+	}
+
+	notifyCommandConsumed(ctx, backend, BackendOnCommandConsumedParams{
+		Command:        cmd,
+		CommandMessage: originalMessage,
+		Attempt:        attempt,
+	})
+
+	start := time.Now()
+	resp, handlerErr := handle()
+	processedAt := time.Now()
+
+	err = backend.OnCommandProcessed(ctx, BackendOnCommandProcessedParams[Result]{
+		Command:         cmd,
+		CommandMessage:  originalMessage,
+		HandlerResult:   resp,
+		HandleErr:       handlerErr,
+		HandlerDuration: processedAt.Sub(start),
+		ProcessedAt:     processedAt,
+		Attempt:         attempt,
 	})
+	if err == nil {
+		// the command will be acked, so it won't be redelivered
+		attempts.Done(originalMessage.UUID)
+	}
+
+	return err
 }
 
 func originalCommandMsgFromCtx(ctx context.Context) (*message.Message, error) {
@@ -79,3 +127,29 @@ func originalCommandMsgFromCtx(ctx context.Context) (*message.Message, error) {
 	}
 	return originalMessage, nil
 }
+
+// validateOperation returns true if the operation was replayed and the handler should be skipped.
+func validateOperation[Result any](
+	ctx context.Context,
+	backend Backend[Result],
+	cmd any,
+	originalMessage *message.Message,
+) (bool, error) {
+	validator, ok := backendAs[OperationValidator](backend)
+	if !ok {
+		return false, nil
+	}
+
+	err := validator.ValidateOperation(ctx, BackendValidateOperationParams{
+		Command:        cmd,
+		CommandMessage: originalMessage,
+	})
+	if errors.Is(err, ErrOperationReplayed) {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "invalid operation")
+	}
+
+	return false, nil
+}
@@ -0,0 +1,73 @@
+package requestreply
+
+import (
+	"sync"
+	"time"
+)
+
+// handlerAttemptsTTL is the time after the last attempt when the command's attempts are forgotten.
+// Commands are not always processed successfully by the same instance (for example, they are redelivered
+// to another instance, or acked by a middleware after an error), so the attempts can't be kept until then.
+const handlerAttemptsTTL = 10 * time.Minute
+
+// handlerAttempts counts the attempts of handling the commands, until they are processed successfully,
+// or handlerAttemptsTTL passes since the last attempt.
+type handlerAttempts struct {
+	attempts    map[string]handlerAttempt
+	lastCleanup time.Time
+	now         func() time.Time
+	lock        sync.Mutex
+}
+
+type handlerAttempt struct {
+	count       int
+	lastAttempt time.Time
+}
+
+func newHandlerAttempts() *handlerAttempts {
+	return &handlerAttempts{
+		attempts:    map[string]handlerAttempt{},
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+func (a *handlerAttempts) Next(messageUUID string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	now := a.now()
+	a.cleanup(now)
+
+	attempt := a.attempts[messageUUID]
+	if now.Sub(attempt.lastAttempt) >= handlerAttemptsTTL {
+		attempt = handlerAttempt{}
+	}
+
+	attempt.count++
+	attempt.lastAttempt = now
+	a.attempts[messageUUID] = attempt
+
+	return attempt.count
+}
+
+func (a *handlerAttempts) Done(messageUUID string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	delete(a.attempts, messageUUID)
+}
+
+// cleanup removes the expired attempts, so the attempts of commands not processed successfully don't use memory.
+func (a *handlerAttempts) cleanup(now time.Time) {
+	if now.Sub(a.lastCleanup) < handlerAttemptsTTL {
+		return
+	}
+
+	for messageUUID, attempt := range a.attempts {
+		if now.Sub(attempt.lastAttempt) >= handlerAttemptsTTL {
+			delete(a.attempts, messageUUID)
+		}
+	}
+	a.lastCleanup = now
+}
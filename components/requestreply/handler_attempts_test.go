@@ -0,0 +1,33 @@
+package requestreply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerAttempts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	attempts := newHandlerAttempts()
+	attempts.lastCleanup = now
+	attempts.now = func() time.Time {
+		return now
+	}
+
+	assert.Equal(t, 1, attempts.Next("1"))
+	assert.Equal(t, 2, attempts.Next("1"))
+
+	attempts.Done("1")
+	assert.Equal(t, 1, attempts.Next("1"), "attempts should be counted again after the command is processed")
+
+	// the command redelivered to another instance, never processed successfully here
+	assert.Equal(t, 1, attempts.Next("2"))
+
+	now = now.Add(handlerAttemptsTTL)
+	assert.Equal(t, 1, attempts.Next("1"), "expired attempts should be forgotten")
+
+	assert.Len(t, attempts.attempts, 1, "expired attempts should be removed")
+	assert.NotContains(t, attempts.attempts, "2")
+}
@@ -0,0 +1,256 @@
+// Package httpbridge exposes commands handled with request/reply as synchronous HTTP endpoints.
+//
+// For each HTTP request, the command is decoded from the request, sent with requestreply.SendWithReply,
+// and the handler's result or error is written as the HTTP response.
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+// DecodeError is returned when the command can't be decoded from the HTTP request.
+// By default, it's mapped to 400 Bad Request.
+type DecodeError struct {
+	Err error
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("cannot decode command: %s", e.Err)
+}
+
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeCommandFn decodes the command from the HTTP request.
+// The returned error is wrapped with DecodeError, unless it's already a DecodeError.
+type DecodeCommandFn func(r *http.Request) (any, error)
+
+// DecodeJSON returns a DecodeCommandFn unmarshaling the request's body as JSON to a new *Command.
+func DecodeJSON[Command any]() DecodeCommandFn {
+	return func(r *http.Request) (any, error) {
+		cmd := new(Command)
+		if err := json.NewDecoder(r.Body).Decode(cmd); err != nil {
+			return nil, err
+		}
+
+		return cmd, nil
+	}
+}
+
+// EncodeResultFn writes the handler's result of the successfully handled command as the HTTP response.
+type EncodeResultFn[Result any] func(w http.ResponseWriter, r *http.Request, reply requestreply.Reply[Result]) error
+
+// EncodeErrorFn writes the error as the HTTP response with the status code returned by Config.ErrorStatus.
+type EncodeErrorFn func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// Config configures the Handler.
+type Config[Result any] struct {
+	// CommandBus is used to send the commands. It's required.
+	CommandBus requestreply.CommandBus
+
+	// Backend is used to receive the replies. It's required.
+	Backend requestreply.Backend[Result]
+
+	// DecodeCommand decodes the command from the HTTP request (see DecodeJSON). It's required.
+	DecodeCommand DecodeCommandFn
+
+	// EncodeResult writes the handler's result as the HTTP response.
+	// If not provided, the result is written as JSON with SuccessStatus.
+	EncodeResult EncodeResultFn[Result]
+
+	// SuccessStatus is the status code of the response written by the default EncodeResult.
+	// Defaults to 200 OK.
+	SuccessStatus int
+
+	// ErrorStatus maps the error to the status code of the response.
+	// Keep in mind that the errors returned by the handlers are usually received as plain errors
+	// (only with the message), so they can't be matched by type.
+	// If not provided, DefaultErrorStatus is used.
+	ErrorStatus func(err error) int
+
+	// EncodeError writes the error as the HTTP response.
+	// If not provided, the error is written as JSON: {"error": "message"}.
+	// The message of errors with 5xx status codes is replaced with the status text, so internal details don't leak.
+	EncodeError EncodeErrorFn
+
+	// Timeout limits the time of waiting for the reply. If it's exceeded, 504 Gateway Timeout is returned.
+	// The request is canceled also when the client disconnects,
+	// or when the timeout of the backend is exceeded (for example, PubSubBackendConfig.ListenForReplyTimeout).
+	//
+	// This option is not required.
+	Timeout time.Duration
+
+	// OperationIDHeader is the name of the HTTP header with the operation ID of the command, for example, "Idempotency-Key".
+	// If the header is present, it's used as the operation ID (see requestreply.ContextWithOperationID),
+	// so the client can safely retry the request.
+	//
+	// This option is not required.
+	OperationIDHeader string
+
+	Logger watermill.LoggerAdapter
+}
+
+func (c *Config[Result]) setDefaults() {
+	if c.SuccessStatus == 0 {
+		c.SuccessStatus = http.StatusOK
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+	if c.EncodeResult == nil {
+		c.EncodeResult = encodeJSONResult[Result](c.SuccessStatus)
+	}
+	if c.ErrorStatus == nil {
+		c.ErrorStatus = DefaultErrorStatus
+	}
+	if c.EncodeError == nil {
+		c.EncodeError = encodeJSONError(c.Logger)
+	}
+}
+
+func (c Config[Result]) Validate() error {
+	if c.CommandBus == nil {
+		return errors.New("missing CommandBus")
+	}
+	if c.Backend == nil {
+		return errors.New("missing Backend")
+	}
+	if c.DecodeCommand == nil {
+		return errors.New("missing DecodeCommand")
+	}
+	if c.Timeout < 0 {
+		return errors.New("Timeout must not be negative")
+	}
+
+	return nil
+}
+
+// DefaultErrorStatus is the default mapping of errors to status codes:
+//   - DecodeError is 400 Bad Request,
+//   - requestreply.ReplyTimeoutError and exceeded deadline are 504 Gateway Timeout,
+//   - requestreply.ReplyUnmarshalError is 502 Bad Gateway,
+//   - all other errors, including the errors returned by the handler, are 500 Internal Server Error.
+func DefaultErrorStatus(err error) int {
+	switch {
+	case errors.As(err, &DecodeError{}):
+		return http.StatusBadRequest
+	case errors.As(err, &requestreply.ReplyTimeoutError{}), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &requestreply.ReplyUnmarshalError{}):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Handler is an http.Handler sending the command decoded from the request and writing the reply as the response.
+type Handler[Result any] struct {
+	config Config[Result]
+}
+
+// NewHandler creates a new Handler.
+func NewHandler[Result any](config Config[Result]) (*Handler[Result], error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Handler[Result]{config: config}, nil
+}
+
+func (h *Handler[Result]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply, err := h.handle(r)
+	if err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	if err := h.config.EncodeResult(w, r, reply); err != nil {
+		h.config.Logger.Error("Cannot write reply", err, watermill.LogFields{"path": r.URL.Path})
+	}
+}
+
+func (h *Handler[Result]) handle(r *http.Request) (requestreply.Reply[Result], error) {
+	cmd, err := h.config.DecodeCommand(r)
+	if err != nil {
+		if !errors.As(err, &DecodeError{}) {
+			err = DecodeError{Err: err}
+		}
+		return requestreply.Reply[Result]{}, err
+	}
+
+	ctx := r.Context()
+	if h.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.config.Timeout)
+		defer cancel()
+	}
+	if h.config.OperationIDHeader != "" {
+		if operationID := r.Header.Get(h.config.OperationIDHeader); operationID != "" {
+			ctx = requestreply.ContextWithOperationID(ctx, requestreply.OperationID(operationID))
+		}
+	}
+
+	reply, err := requestreply.SendWithReply[Result](ctx, h.config.CommandBus, h.config.Backend, cmd)
+	if err != nil {
+		return requestreply.Reply[Result]{}, err
+	}
+	if reply.Error != nil {
+		return requestreply.Reply[Result]{}, reply.Error
+	}
+
+	return reply, nil
+}
+
+func (h *Handler[Result]) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := h.config.ErrorStatus(err)
+
+	fields := watermill.LogFields{"path": r.URL.Path, "status": status}
+	if status >= http.StatusInternalServerError {
+		h.config.Logger.Error("Command request failed", err, fields)
+	} else {
+		h.config.Logger.Debug("Command request rejected", fields.Add(watermill.LogFields{"err": err}))
+	}
+
+	h.config.EncodeError(w, r, status, err)
+}
+
+func encodeJSONResult[Result any](status int) EncodeResultFn[Result] {
+	return func(w http.ResponseWriter, r *http.Request, reply requestreply.Reply[Result]) error {
+		return writeJSON(w, status, reply.HandlerResult)
+	}
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func encodeJSONError(logger watermill.LoggerAdapter) EncodeErrorFn {
+	return func(w http.ResponseWriter, r *http.Request, status int, err error) {
+		message := err.Error()
+		if status >= http.StatusInternalServerError {
+			message = http.StatusText(status)
+		}
+
+		if err := writeJSON(w, status, errorResponse{Error: message}); err != nil {
+			logger.Error("Cannot write error response", err, watermill.LogFields{"path": r.URL.Path})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	return json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,215 @@
+package httpbridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply/httpbridge"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type PlaceOrder struct {
+	ProductID string `json:"product_id"`
+}
+
+type OrderPlaced struct {
+	OrderID string `json:"order_id"`
+}
+
+type commandBusStub struct {
+	sent []any
+}
+
+func (c *commandBusStub) SendWithModifiedMessage(ctx context.Context, cmd any, modify func(*message.Message) error) error {
+	c.sent = append(c.sent, cmd)
+	return modify(message.NewMessage("1", nil))
+}
+
+// backendStub replies with reply, or doesn't reply at all if reply is nil.
+type backendStub struct {
+	reply *requestreply.Reply[OrderPlaced]
+
+	operationIDs []requestreply.OperationID
+}
+
+func (b *backendStub) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[OrderPlaced], error) {
+	b.operationIDs = append(b.operationIDs, params.OperationID)
+
+	replies := make(chan requestreply.Reply[OrderPlaced], 1)
+	if b.reply != nil {
+		replies <- *b.reply
+	}
+
+	return replies, nil
+}
+
+func (b *backendStub) OnCommandProcessed(ctx context.Context, params requestreply.BackendOnCommandProcessedParams[OrderPlaced]) error {
+	return nil
+}
+
+func newHandler(t *testing.T, config httpbridge.Config[OrderPlaced]) *httpbridge.Handler[OrderPlaced] {
+	t.Helper()
+
+	if config.CommandBus == nil {
+		config.CommandBus = &commandBusStub{}
+	}
+	if config.DecodeCommand == nil {
+		config.DecodeCommand = httpbridge.DecodeJSON[PlaceOrder]()
+	}
+
+	handler, err := httpbridge.NewHandler(config)
+	require.NoError(t, err)
+
+	return handler
+}
+
+func serve(handler http.Handler, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestHandler(t *testing.T) {
+	commandBus := &commandBusStub{}
+	backend := &backendStub{
+		reply: &requestreply.Reply[OrderPlaced]{HandlerResult: OrderPlaced{OrderID: "order-1"}},
+	}
+
+	handler := newHandler(t, httpbridge.Config[OrderPlaced]{
+		CommandBus:    commandBus,
+		Backend:       backend,
+		SuccessStatus: http.StatusCreated,
+	})
+
+	rec := serve(handler, `{"product_id": "product-1"}`, nil)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"order_id": "order-1"}`, rec.Body.String())
+
+	require.Len(t, commandBus.sent, 1)
+	assert.Equal(t, &PlaceOrder{ProductID: "product-1"}, commandBus.sent[0])
+}
+
+func TestHandler_errors(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		Body            string
+		Reply           *requestreply.Reply[OrderPlaced]
+		Config          httpbridge.Config[OrderPlaced]
+		ExpectedStatus  int
+		ExpectedMessage string
+	}{
+		{
+			Name:            "invalid_body",
+			Body:            `{`,
+			ExpectedStatus:  http.StatusBadRequest,
+			ExpectedMessage: "cannot decode command: unexpected EOF",
+		},
+		{
+			Name:            "handler_error",
+			Body:            `{}`,
+			Reply:           &requestreply.Reply[OrderPlaced]{Error: errors.New("out of stock")},
+			ExpectedStatus:  http.StatusInternalServerError,
+			ExpectedMessage: "Internal Server Error",
+		},
+		{
+			Name:  "mapped_handler_error",
+			Body:  `{}`,
+			Reply: &requestreply.Reply[OrderPlaced]{Error: errors.New("out of stock")},
+			Config: httpbridge.Config[OrderPlaced]{
+				ErrorStatus: func(err error) int {
+					if err.Error() == "out of stock" {
+						return http.StatusConflict
+					}
+					return httpbridge.DefaultErrorStatus(err)
+				},
+			},
+			ExpectedStatus:  http.StatusConflict,
+			ExpectedMessage: "out of stock",
+		},
+		{
+			Name: "reply_timeout",
+			Body: `{}`,
+			Reply: &requestreply.Reply[OrderPlaced]{
+				Error: requestreply.ReplyTimeoutError{Duration: time.Second, Err: context.DeadlineExceeded},
+			},
+			ExpectedStatus:  http.StatusGatewayTimeout,
+			ExpectedMessage: "Gateway Timeout",
+		},
+		{
+			Name: "timeout",
+			Body: `{}`,
+			Config: httpbridge.Config[OrderPlaced]{
+				Timeout: time.Millisecond * 10,
+			},
+			ExpectedStatus:  http.StatusGatewayTimeout,
+			ExpectedMessage: "Gateway Timeout",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			config := tc.Config
+			config.Backend = &backendStub{reply: tc.Reply}
+
+			rec := serve(newHandler(t, config), tc.Body, nil)
+
+			assert.Equal(t, tc.ExpectedStatus, rec.Code)
+
+			resp := map[string]string{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.Equal(t, tc.ExpectedMessage, resp["error"])
+		})
+	}
+}
+
+func TestHandler_operation_id_header(t *testing.T) {
+	backend := &backendStub{
+		reply: &requestreply.Reply[OrderPlaced]{HandlerResult: OrderPlaced{OrderID: "order-1"}},
+	}
+
+	handler := newHandler(t, httpbridge.Config[OrderPlaced]{
+		Backend:           backend,
+		OperationIDHeader: "Idempotency-Key",
+	})
+
+	rec := serve(handler, `{}`, http.Header{"Idempotency-Key": []string{"key-1"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = serve(handler, `{}`, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Len(t, backend.operationIDs, 2)
+	assert.Equal(t, requestreply.OperationID("key-1"), backend.operationIDs[0])
+	assert.NotEmpty(t, backend.operationIDs[1], "operation ID should be generated without the header")
+	assert.NotEqual(t, requestreply.OperationID("key-1"), backend.operationIDs[1])
+}
+
+func TestNewHandler_invalid_config(t *testing.T) {
+	_, err := httpbridge.NewHandler(httpbridge.Config[OrderPlaced]{
+		CommandBus: &commandBusStub{},
+		Backend:    &backendStub{},
+	})
+	assert.ErrorContains(t, err, "missing DecodeCommand")
+}
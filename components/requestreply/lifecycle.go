@@ -0,0 +1,141 @@
+package requestreply
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// LifecycleStageMetadataKey is the metadata key of the stage of lifecycle event messages.
+const LifecycleStageMetadataKey = "_watermill_requestreply_lifecycle_stage"
+
+// LifecycleStage is a stage of the request-reply operation.
+type LifecycleStage string
+
+const (
+	// LifecycleStageSent is emitted by the caller after the command is sent.
+	LifecycleStageSent LifecycleStage = "sent"
+
+	// LifecycleStageReceived is emitted by the handler before the command is handled.
+	// It's emitted again for every redelivery of the command.
+	LifecycleStageReceived LifecycleStage = "received"
+
+	// LifecycleStageReplied is emitted by the handler after the reply is sent.
+	LifecycleStageReplied LifecycleStage = "replied"
+)
+
+// LifecycleEvent describes a stage of the request-reply operation.
+// Events of the operation, emitted by different services, are correlated by OperationID.
+type LifecycleEvent struct {
+	OperationID OperationID    `json:"operation_id"`
+	Stage       LifecycleStage `json:"stage"`
+
+	// OccurredAt is the time when the stage occurred, in the clock of the emitting service.
+	OccurredAt time.Time `json:"occurred_at"`
+
+	// CommandName is the fully qualified name of the command's type.
+	CommandName string `json:"command_name,omitempty"`
+
+	// HandlerInstanceID identifies the handler's instance, for LifecycleStageReceived and LifecycleStageReplied.
+	HandlerInstanceID string `json:"handler_instance_id,omitempty"`
+
+	// Attempt is the number of the handler's attempt, for LifecycleStageReceived and LifecycleStageReplied.
+	Attempt int `json:"attempt,omitempty"`
+
+	// HandlerDuration is the time the handler was running, for LifecycleStageReplied.
+	HandlerDuration time.Duration `json:"handler_duration,omitempty"`
+
+	// Error is the error returned by the handler, for LifecycleStageReplied.
+	Error string `json:"error,omitempty"`
+}
+
+// LifecycleObserver is an optional interface of Backend.
+// If the backend implements it, SendWithReplies and the command handlers created with NewCommandHandler
+// and NewCommandHandlerWithResult report the lifecycle of operations to it.
+//
+// LifecycleStageReplied is emitted by the backend itself, as it's the one sending the reply.
+type LifecycleObserver interface {
+	// OnLifecycleEvent is called when the operation reaches the stage.
+	// OccurredAt is set by the backend. Failures are not returned, so monitoring doesn't break the operations.
+	OnLifecycleEvent(ctx context.Context, event LifecycleEvent)
+}
+
+// LifecycleEventsConfig configures publishing the lifecycle events of operations to a monitoring topic,
+// so an external system can build the audit trail of operations and measure the end-to-end latency across services.
+//
+// The events are published as JSON (see LifecycleEvent), with OperationIDMetadataKey and LifecycleStageMetadataKey
+// set in the metadata.
+type LifecycleEventsConfig struct {
+	// Topic is the monitoring topic. It is required.
+	Topic string
+
+	// Publisher publishes the events. If not provided, PubSubBackendConfig.Publisher is used.
+	Publisher message.Publisher
+}
+
+func (c LifecycleEventsConfig) Validate() error {
+	if c.Topic == "" {
+		return errors.New("missing Topic")
+	}
+
+	return nil
+}
+
+func emitLifecycleEvent[Result any](ctx context.Context, backend Backend[Result], event LifecycleEvent) {
+	if observer, ok := backendAs[LifecycleObserver](backend); ok {
+		observer.OnLifecycleEvent(ctx, event)
+	}
+}
+
+func commandName(cmd any) string {
+	if cmd == nil {
+		return ""
+	}
+
+	return cqrs.FullyQualifiedStructName(cmd)
+}
+
+// OnLifecycleEvent implements LifecycleObserver.
+// It publishes the event if PubSubBackendConfig.LifecycleEvents is set.
+func (p PubSubBackend[Result]) OnLifecycleEvent(ctx context.Context, event LifecycleEvent) {
+	config := p.config.LifecycleEvents
+	if config == nil {
+		return
+	}
+
+	event.OccurredAt = p.config.Clock.Now()
+	if event.Stage != LifecycleStageSent && event.HandlerInstanceID == "" {
+		event.HandlerInstanceID = p.config.HandlerInstanceID
+	}
+
+	logFields := watermill.LogFields{
+		"operation_id":    event.OperationID,
+		"lifecycle_stage": event.Stage,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		p.config.Logger.Error("Cannot marshal request/reply lifecycle event", err, logFields)
+		return
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), payload)
+	msg.SetContext(ctx)
+	msg.Metadata.Set(OperationIDMetadataKey, string(event.OperationID))
+	msg.Metadata.Set(LifecycleStageMetadataKey, string(event.Stage))
+
+	publisher := config.Publisher
+	if publisher == nil {
+		publisher = p.config.Publisher
+	}
+
+	if err := publisher.Publish(config.Topic, msg); err != nil {
+		p.config.Logger.Error("Cannot publish request/reply lifecycle event", err, logFields)
+	}
+}
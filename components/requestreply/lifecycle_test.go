@@ -0,0 +1,114 @@
+package requestreply_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestPubSubBackend_LifecycleEvents(t *testing.T) {
+	monitoringPubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		HandlerInstanceID: "instance-1",
+		LifecycleEvents: &requestreply.LifecycleEventsConfig{
+			Topic:     "requestreply_lifecycle",
+			Publisher: monitoringPubSub,
+		},
+	})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: "1"}, errors.New("some error")
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		requestreply.ContextWithOperationID(context.Background(), operationID),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.Error(t, reply.Error)
+
+	messages, err := monitoringPubSub.Subscribe(context.Background(), "requestreply_lifecycle")
+	require.NoError(t, err)
+
+	var events []requestreply.LifecycleEvent
+	for len(events) < 3 {
+		select {
+		case msg := <-messages:
+			assert.Equal(t, string(operationID), msg.Metadata.Get(requestreply.OperationIDMetadataKey))
+
+			var event requestreply.LifecycleEvent
+			require.NoError(t, json.Unmarshal(msg.Payload, &event))
+			assert.Equal(t, string(event.Stage), msg.Metadata.Get(requestreply.LifecycleStageMetadataKey))
+
+			events = append(events, event)
+			msg.Ack()
+		case <-time.After(time.Second):
+			t.Fatalf("expected 3 lifecycle events, got %d", len(events))
+		}
+	}
+
+	// the command may be received before the caller emits the sent event, so the order is not checked
+	stages := map[requestreply.LifecycleStage]requestreply.LifecycleEvent{}
+	for _, event := range events {
+		assert.Equal(t, operationID, event.OperationID)
+		assert.Equal(t, "requestreply_test.TestCommand", event.CommandName)
+		assert.False(t, event.OccurredAt.IsZero())
+		stages[event.Stage] = event
+	}
+	require.Len(t, stages, 3)
+
+	assert.Empty(t, stages[requestreply.LifecycleStageSent].HandlerInstanceID)
+
+	received := stages[requestreply.LifecycleStageReceived]
+	assert.Equal(t, "instance-1", received.HandlerInstanceID)
+	assert.Equal(t, 1, received.Attempt)
+
+	replied := stages[requestreply.LifecycleStageReplied]
+	assert.Equal(t, "instance-1", replied.HandlerInstanceID)
+	assert.Equal(t, "some error", replied.Error)
+	assert.False(t, replied.OccurredAt.Before(received.OccurredAt))
+}
+
+func TestPubSubBackend_LifecycleEvents_invalid_config(t *testing.T) {
+	_, err := requestreply.NewPubSubBackend[requestreply.NoResult](
+		requestreply.PubSubBackendConfig{
+			Publisher: gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+			SubscriberConstructor: func(params requestreply.PubSubBackendSubscribeParams) (message.Subscriber, error) {
+				return nil, nil
+			},
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply", nil
+			},
+			GenerateSubscribeTopic: func(params requestreply.PubSubBackendSubscribeParams) (string, error) {
+				return "reply", nil
+			},
+			LifecycleEvents: &requestreply.LifecycleEventsConfig{},
+		},
+		requestreply.BackendPubsubJSONMarshaler[requestreply.NoResult]{},
+	)
+	assert.ErrorContains(t, err, "invalid LifecycleEvents config")
+}
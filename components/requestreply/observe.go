@@ -0,0 +1,93 @@
+package requestreply
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ReplyGetter is an optional interface of Backend.
+// If the backend implements it, ObserveReplies returns the reply which was already sent, before listening for new ones.
+type ReplyGetter[Result any] interface {
+	// GetReply returns the reply of the operation.
+	// It returns an error wrapping ErrReplyNotFound if there is no reply (yet),
+	// and ErrReplyStoreNotConfigured if the backend can't keep replies.
+	GetReply(ctx context.Context, operationID OperationID) (Reply[Result], error)
+}
+
+// ObserveReplies listens for replies of the command with the provided operation ID, without sending it.
+// It is useful when the command was sent by another instance of the service, for example,
+// when a web frontend polls for the result of a command submitted via a different node.
+// The operation ID can be set when sending the command, with ContextWithOperationID.
+//
+// cmd is passed to the backend to generate the reply topic and to create the subscriber
+// (see PubSubBackendSubscribeParams), so it should be the sent command, or at least have the same type.
+//
+// If the backend implements ReplyGetter (PubSubBackend with ReplyStore), the reply sent before ObserveReplies
+// was called is returned first. Otherwise, only replies sent after ObserveReplies subscribed are received.
+//
+// Like SendWithReplies, it's important to call cancel, because it's listening for the replies in the background.
+func ObserveReplies[Result any](
+	ctx context.Context,
+	backend Backend[Result],
+	operationID OperationID,
+	cmd any,
+) (replyCh <-chan Reply[Result], cancel func(), err error) {
+	if operationID == "" {
+		return nil, func() {}, errors.New("missing operation ID")
+	}
+
+	ctx, cancel = context.WithCancel(ctx)
+
+	// subscribing before getting the stored reply, so the reply sent in between is not missed
+	replies, err := backend.ListenForNotifications(ctx, BackendListenForNotificationsParams{
+		Command:     cmd,
+		OperationID: operationID,
+	})
+	if err != nil {
+		cancel()
+		return nil, cancel, errors.Wrap(err, "cannot listen for reply")
+	}
+
+	getter, ok := backendAs[ReplyGetter[Result]](backend)
+	if !ok {
+		return replies, cancel, nil
+	}
+
+	storedReply, err := getter.GetReply(ctx, operationID)
+	if errors.Is(err, ErrReplyNotFound) || errors.Is(err, ErrReplyStoreNotConfigured) {
+		return replies, cancel, nil
+	}
+	if err != nil {
+		cancel()
+		return nil, cancel, errors.Wrap(err, "cannot get stored reply")
+	}
+
+	out := make(chan Reply[Result], 1)
+	out <- storedReply
+
+	go func() {
+		defer close(out)
+
+		for reply := range replies {
+			if isSameNotification(reply, storedReply) {
+				// the stored reply was published after we subscribed
+				continue
+			}
+
+			select {
+			case out <- reply:
+			case <-ctx.Done():
+				// draining replies, so the listening goroutine can exit
+			}
+		}
+	}()
+
+	return out, cancel, nil
+}
+
+func isSameNotification[Result any](reply Reply[Result], storedReply Reply[Result]) bool {
+	return reply.NotificationMessage != nil &&
+		storedReply.NotificationMessage != nil &&
+		reply.NotificationMessage.UUID == storedReply.NotificationMessage.UUID
+}
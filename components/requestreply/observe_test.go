@@ -0,0 +1,123 @@
+package requestreply_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestObserveReplies(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	replyCh, cancel, err := requestreply.ObserveReplies[TestCommandResult](
+		context.Background(),
+		ts.RequestReplyBackend,
+		operationID,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	// the command is sent by another instance, which only sets the operation ID
+	err = ts.CommandBus.SendWithModifiedMessage(context.Background(), &TestCommand{ID: "1"}, func(msg *message.Message) error {
+		msg.Metadata.Set(requestreply.OperationIDMetadataKey, string(operationID))
+		return nil
+	})
+	require.NoError(t, err)
+
+	select {
+	case reply := <-replyCh:
+		require.NoError(t, reply.Error)
+		assert.Equal(t, TestCommandResult{ID: "1"}, reply.HandlerResult)
+		assert.Equal(t, string(operationID), reply.NotificationMessage.Metadata.Get(requestreply.OperationIDMetadataKey))
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestObserveReplies_stored_reply(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		ReplyStore: requestreply.NewInMemoryReplyStore(),
+	})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	_, err = requestreply.SendWithReply[TestCommandResult](
+		requestreply.ContextWithOperationID(context.Background(), operationID),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+
+	replyCh, cancel, err := requestreply.ObserveReplies[TestCommandResult](
+		context.Background(),
+		ts.RequestReplyBackend,
+		operationID,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	select {
+	case reply := <-replyCh:
+		require.NoError(t, reply.Error)
+		assert.Equal(t, TestCommandResult{ID: "1"}, reply.HandlerResult)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	cancel()
+
+	for reply := range replyCh {
+		assert.IsType(t, requestreply.ReplyTimeoutError{}, reply.Error, "only the timeout reply should be received after cancel")
+	}
+}
+
+func TestObserveReplies_missing_operation_id(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	_, cancel, err := requestreply.ObserveReplies[TestCommandResult](
+		context.Background(),
+		ts.RequestReplyBackend,
+		"",
+		&TestCommand{ID: "1"},
+	)
+	defer cancel()
+	assert.EqualError(t, err, "missing operation ID")
+}
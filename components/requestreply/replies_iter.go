@@ -0,0 +1,49 @@
+//go:build go1.23
+
+package requestreply
+
+import (
+	"context"
+	"iter"
+)
+
+// Replies sends command to the command bus and returns an iterator over the replies of the command handler.
+// It works like SendWithReplies, but cleans up automatically: listening for the replies stops when the loop exits,
+// so the returned cancel function can't be forgotten.
+//
+//	for reply, err := range requestreply.Replies[SomeTypeReturnedByHandler](ctx, commandBus, backend, cmd) {
+//		if err != nil {
+//			// the command was not sent
+//			return err
+//		}
+//
+//		// handle reply, break when no more replies are expected
+//	}
+//
+// If the command can't be sent, the error is yielded once and the iteration ends.
+// The iteration also ends when the context is cancelled or the timeout set in the backend is exceeded,
+// after the backend's reply with ReplyTimeoutError (if it sends one).
+//
+// The command is sent when the iteration starts, not when Replies is called.
+// Every iteration sends the command again.
+func Replies[Result any](
+	ctx context.Context,
+	c CommandBus,
+	backend Backend[Result],
+	cmd any,
+) iter.Seq2[Reply[Result], error] {
+	return func(yield func(Reply[Result], error) bool) {
+		replyCh, cancel, err := SendWithReplies[Result](ctx, c, backend, cmd)
+		if err != nil {
+			yield(Reply[Result]{}, err)
+			return
+		}
+		defer cancel()
+
+		for reply := range replyCh {
+			if !yield(reply, nil) {
+				return
+			}
+		}
+	}
+}
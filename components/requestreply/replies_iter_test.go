@@ -0,0 +1,114 @@
+//go:build go1.23
+
+package requestreply_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestReplies(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		DoNotAckOnCommandErrors: true,
+	})
+
+	attempt := 0
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				attempt++
+				if attempt < 3 {
+					return TestCommandResult{}, fmt.Errorf("error %d", attempt)
+				}
+
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	backend := &listeningBackend[TestCommandResult]{Backend: ts.RequestReplyBackend}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var replies []requestreply.Reply[TestCommandResult]
+
+	for reply, err := range requestreply.Replies[TestCommandResult](ctx, ts.CommandBus, backend, &TestCommand{ID: "1"}) {
+		require.NoError(t, err)
+
+		replies = append(replies, reply)
+		if reply.Error == nil {
+			break
+		}
+	}
+
+	require.Len(t, replies, 3)
+	assert.EqualError(t, replies[0].Error, "error 1")
+	assert.EqualError(t, replies[1].Error, "error 2")
+	assert.NoError(t, replies[2].Error)
+	assert.Equal(t, TestCommandResult{ID: "1"}, replies[2].HandlerResult)
+
+	require.NotNil(t, backend.listenCtx)
+	select {
+	case <-backend.listenCtx.Done():
+		// ok, listening was cancelled when the loop exited
+	case <-time.After(time.Second):
+		t.Fatal("listening for replies was not cancelled")
+	}
+}
+
+func TestReplies_send_error(t *testing.T) {
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{})
+
+	sendErr := fmt.Errorf("send failed")
+
+	iterations := 0
+	for reply, err := range requestreply.Replies[requestreply.NoResult](
+		context.Background(),
+		failingCommandBus{err: sendErr},
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	) {
+		iterations++
+
+		assert.ErrorIs(t, err, sendErr)
+		assert.Empty(t, reply)
+	}
+
+	assert.Equal(t, 1, iterations)
+}
+
+type listeningBackend[Result any] struct {
+	requestreply.Backend[Result]
+	listenCtx context.Context
+}
+
+func (b *listeningBackend[Result]) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[Result], error) {
+	b.listenCtx = ctx
+	return b.Backend.ListenForNotifications(ctx, params)
+}
+
+type failingCommandBus struct {
+	err error
+}
+
+func (b failingCommandBus) SendWithModifiedMessage(ctx context.Context, cmd any, modify func(*message.Message) error) error {
+	return b.err
+}
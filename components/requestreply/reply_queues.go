@@ -0,0 +1,70 @@
+package requestreply
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ReplyToMetadataKey is the metadata key of the address of the caller's reply queue.
+// If it's set on the command message, PubSubBackend publishes the reply to this address
+// instead of the topic generated by PubSubBackendConfig.GeneratePublishTopic.
+const ReplyToMetadataKey = "_watermill_requestreply_reply_to"
+
+// ErrReplyQueuesNotSupported can be returned by ReplyQueues when the transport can't create reply queues
+// (for example, the broker doesn't allow exclusive queues for the user).
+// PubSubBackend falls back to subscribing to topics generated by PubSubBackendConfig.GenerateSubscribeTopic then.
+var ErrReplyQueuesNotSupported = errors.New("reply queues are not supported")
+
+// ReplyQueues is implemented by transports with native temporary reply queues,
+// like exclusive, auto-deleted queues or direct reply-to in RabbitMQ.
+//
+// A reply queue is created for every command sent with SendWithReply or SendWithReplies,
+// and its address is sent to the handler with the command (see ReplyToMetadataKey).
+// Replies don't have to be filtered by the operation ID on the caller side then,
+// and no reply topics need to be created upfront.
+type ReplyQueues interface {
+	// SubscribeReplyQueue creates a temporary reply queue exclusive to the caller and subscribes to it.
+	// The queue should be removed when ctx is done.
+	//
+	// It returns the address of the queue. PubSubBackendConfig.Publisher of the handler's side
+	// must deliver messages published to this address (used as the topic) to the queue.
+	SubscribeReplyQueue(ctx context.Context, params PubSubBackendSubscribeParams) (address string, messages <-chan *message.Message, err error)
+}
+
+// CommandMessageModifier is an optional interface of Backend.
+// If the backend implements it, SendWithReplies calls it after ListenForNotifications and before sending the command,
+// for example, to add the address of the reply queue to the command message.
+type CommandMessageModifier interface {
+	ModifyCommandMessage(ctx context.Context, params BackendListenForNotificationsParams, msg *message.Message) error
+}
+
+// TopicReplyQueues implements ReplyQueues with a new topic for every reply queue.
+// It's a reference implementation for transports where topics are cheap to create (like gochannel),
+// and for testing. Prefer the transport's native implementation when it's available.
+type TopicReplyQueues struct {
+	// Subscriber is used to subscribe to the reply topics. It is required.
+	Subscriber message.Subscriber
+
+	// TopicPrefix is the prefix of the reply topics. Topics are named "<TopicPrefix><UUID>".
+	TopicPrefix string
+}
+
+// SubscribeReplyQueue implements ReplyQueues.
+func (q TopicReplyQueues) SubscribeReplyQueue(ctx context.Context, params PubSubBackendSubscribeParams) (string, <-chan *message.Message, error) {
+	if q.Subscriber == nil {
+		return "", nil, errors.New("missing Subscriber")
+	}
+
+	address := q.TopicPrefix + watermill.NewUUID()
+
+	messages, err := q.Subscriber.Subscribe(ctx, address)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return address, messages, nil
+}
@@ -0,0 +1,137 @@
+package requestreply_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type unsupportedReplyQueues struct{}
+
+func (unsupportedReplyQueues) SubscribeReplyQueue(
+	ctx context.Context,
+	params requestreply.PubSubBackendSubscribeParams,
+) (string, <-chan *message.Message, error) {
+	return "", nil, requestreply.ErrReplyQueuesNotSupported
+}
+
+func TestPubSubBackend_ReplyQueues(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	replyTo := make(chan string, 1)
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				replyTo <- cqrs.OriginalMessageFromCtx(ctx).Metadata.Get(requestreply.ReplyToMetadataKey)
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	// the Pub/Sub used by the backend and the command bus
+	pubSub := ts.BackendConfig.Publisher.(*gochannel.GoChannel)
+
+	callerBackend, err := requestreply.NewPubSubBackend[TestCommandResult](
+		requestreply.PubSubBackendConfig{
+			Publisher: pubSub,
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply", nil
+			},
+			ReplyQueues: requestreply.TopicReplyQueues{
+				Subscriber:  pubSub,
+				TopicPrefix: "reply_queue_",
+			},
+			Logger: ts.Logger,
+		},
+		requestreply.BackendPubsubJSONMarshaler[TestCommandResult]{},
+	)
+	require.NoError(t, err)
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		callerBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, reply.Error)
+	assert.Equal(t, TestCommandResult{ID: "1"}, reply.HandlerResult)
+
+	address := <-replyTo
+	assert.True(t, strings.HasPrefix(address, "reply_queue_"), address)
+}
+
+func TestPubSubBackend_ReplyQueues_fallback(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				assert.Empty(t, cqrs.OriginalMessageFromCtx(ctx).Metadata.Get(requestreply.ReplyToMetadataKey))
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	config := ts.BackendConfig
+	config.ReplyQueues = unsupportedReplyQueues{}
+
+	callerBackend, err := requestreply.NewPubSubBackend[TestCommandResult](
+		config,
+		requestreply.BackendPubsubJSONMarshaler[TestCommandResult]{},
+	)
+	require.NoError(t, err)
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		callerBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, reply.Error)
+	assert.Equal(t, TestCommandResult{ID: "1"}, reply.HandlerResult)
+}
+
+func TestPubSubBackend_ReplyQueues_fallback_not_configured(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	callerBackend, err := requestreply.NewPubSubBackend[TestCommandResult](
+		requestreply.PubSubBackendConfig{
+			Publisher: ts.BackendConfig.Publisher,
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply", nil
+			},
+			ReplyQueues: unsupportedReplyQueues{},
+		},
+		requestreply.BackendPubsubJSONMarshaler[TestCommandResult]{},
+	)
+	require.NoError(t, err)
+
+	_, err = requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		callerBackend,
+		&TestCommand{ID: "1"},
+	)
+	assert.ErrorIs(t, err, requestreply.ErrReplyQueuesNotSupported)
+}
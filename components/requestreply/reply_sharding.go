@@ -0,0 +1,250 @@
+package requestreply
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ShardedReplyTopics spreads replies over a fixed number of reply topics, by hashing the operation ID.
+//
+// It's a middle ground between one reply topic per command (many topics on the broker)
+// and one global reply topic (every caller receives and skips all replies).
+// Use GeneratePublishTopic and GenerateSubscribeTopic in PubSubBackendConfig.
+type ShardedReplyTopics struct {
+	// Prefix is the prefix of the reply topics. Topics are named "<Prefix>_<shard>", for example, "replies_3".
+	Prefix string
+
+	// Shards is the number of reply topics.
+	//
+	// WARNING: The callers and the handlers must use the same number of shards,
+	// otherwise replies are published to topics nobody listens to.
+	// Change it only when no commands are in flight.
+	Shards int
+}
+
+// Validate returns the config's error, if any.
+func (s ShardedReplyTopics) Validate() error {
+	var err error
+
+	if s.Prefix == "" {
+		err = multierror.Append(err, errors.New("missing Prefix"))
+	}
+	if s.Shards <= 0 {
+		err = multierror.Append(err, errors.New("Shards must be greater than 0"))
+	}
+
+	return err
+}
+
+// Shard returns the shard of the operation ID, from 0 to Shards-1.
+func (s ShardedReplyTopics) Shard(operationID OperationID) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(operationID))
+
+	return int(h.Sum32() % uint32(s.Shards))
+}
+
+// Topic returns the reply topic of the operation ID.
+func (s ShardedReplyTopics) Topic(operationID OperationID) string {
+	return s.shardTopic(s.Shard(operationID))
+}
+
+// Topics returns all reply topics, for example, to create them on the broker upfront.
+func (s ShardedReplyTopics) Topics() []string {
+	topics := make([]string, 0, s.Shards)
+	for shard := 0; shard < s.Shards; shard++ {
+		topics = append(topics, s.shardTopic(shard))
+	}
+
+	return topics
+}
+
+// GeneratePublishTopic can be used as PubSubBackendConfig.GeneratePublishTopic.
+func (s ShardedReplyTopics) GeneratePublishTopic(params PubSubBackendPublishParams) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	return s.Topic(params.OperationID), nil
+}
+
+// GenerateSubscribeTopic can be used as PubSubBackendConfig.GenerateSubscribeTopic.
+func (s ShardedReplyTopics) GenerateSubscribeTopic(params PubSubBackendSubscribeParams) (string, error) {
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
+
+	return s.Topic(params.OperationID), nil
+}
+
+func (s ShardedReplyTopics) shardTopic(shard int) string {
+	return fmt.Sprintf("%s_%d", s.Prefix, shard)
+}
+
+// ShardedReplySubscriber shares one subscription of every reply topic between all commands waiting for replies,
+// and routes the replies to them by operation ID.
+//
+// Without it, every SendWithReply call subscribes to the reply topic on its own, and receives all replies
+// published to it. With ShardedReplyTopics, use ShardedReplySubscriber.SubscriberConstructor
+// as PubSubBackendConfig.SubscriberConstructor.
+//
+// The subscriber must deliver all messages of the reply topic to this instance of the service
+// (for example, use a consumer group unique per instance).
+// Replies for operations nobody waits for (for example, after the timeout) are acked and dropped.
+type ShardedReplySubscriber struct {
+	subscriber message.Subscriber
+	logger     watermill.LoggerAdapter
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	topics  map[string]struct{}
+	waiters map[OperationID]*replyWaiter
+	lock    sync.Mutex
+
+	subscriptionsWg sync.WaitGroup
+}
+
+type replyWaiter struct {
+	messages chan *message.Message
+	done     <-chan struct{}
+}
+
+// NewShardedReplySubscriber creates a new ShardedReplySubscriber.
+func NewShardedReplySubscriber(subscriber message.Subscriber, logger watermill.LoggerAdapter) (*ShardedReplySubscriber, error) {
+	if subscriber == nil {
+		return nil, errors.New("missing subscriber")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &ShardedReplySubscriber{
+		subscriber: subscriber,
+		logger:     logger,
+		ctx:        ctx,
+		cancel:     cancel,
+		topics:     map[string]struct{}{},
+		waiters:    map[OperationID]*replyWaiter{},
+	}, nil
+}
+
+// SubscriberConstructor can be used as PubSubBackendConfig.SubscriberConstructor.
+func (s *ShardedReplySubscriber) SubscriberConstructor(params PubSubBackendSubscribeParams) (message.Subscriber, error) {
+	return operationReplySubscriber{
+		sharded:     s,
+		operationID: params.OperationID,
+	}, nil
+}
+
+// Close closes all subscriptions of the reply topics.
+func (s *ShardedReplySubscriber) Close() error {
+	s.cancel()
+	err := s.subscriber.Close()
+
+	s.subscriptionsWg.Wait()
+
+	return err
+}
+
+func (s *ShardedReplySubscriber) subscribe(ctx context.Context, topic string, operationID OperationID) (<-chan *message.Message, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ctx.Err() != nil {
+		return nil, errors.New("subscriber is closed")
+	}
+
+	if _, ok := s.waiters[operationID]; ok {
+		return nil, errors.Errorf("already waiting for replies of operation %s", operationID)
+	}
+
+	if _, ok := s.topics[topic]; !ok {
+		messages, err := s.subscriber.Subscribe(s.ctx, topic)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot subscribe to %s", topic)
+		}
+		s.topics[topic] = struct{}{}
+
+		s.subscriptionsWg.Add(1)
+		go func() {
+			defer s.subscriptionsWg.Done()
+			s.routeReplies(topic, messages)
+		}()
+	}
+
+	waiter := &replyWaiter{
+		messages: make(chan *message.Message),
+		done:     ctx.Done(),
+	}
+	s.waiters[operationID] = waiter
+
+	go func() {
+		<-ctx.Done()
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if s.waiters[operationID] == waiter {
+			delete(s.waiters, operationID)
+		}
+	}()
+
+	return waiter.messages, nil
+}
+
+func (s *ShardedReplySubscriber) routeReplies(topic string, messages <-chan *message.Message) {
+	for msg := range messages {
+		operationID := OperationID(msg.Metadata.Get(OperationIDMetadataKey))
+
+		s.lock.Lock()
+		waiter, ok := s.waiters[operationID]
+		s.lock.Unlock()
+
+		if !ok {
+			s.logger.Trace("Nobody waits for the reply, dropping", watermill.LogFields{
+				"topic":        topic,
+				"operation_id": operationID,
+			})
+			msg.Ack()
+			continue
+		}
+
+		select {
+		case waiter.messages <- msg:
+			// the reply is acked by the backend
+		case <-waiter.done:
+			msg.Ack()
+		case <-s.ctx.Done():
+			msg.Nack()
+			return
+		}
+	}
+}
+
+// operationReplySubscriber receives replies of one operation from ShardedReplySubscriber.
+//
+// The channel returned by Subscribe is not closed when ctx is done, PubSubBackend stops reading from it then.
+type operationReplySubscriber struct {
+	sharded     *ShardedReplySubscriber
+	operationID OperationID
+}
+
+func (o operationReplySubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	return o.sharded.subscribe(ctx, topic, o.operationID)
+}
+
+// Close does nothing, the shared subscriptions are closed by ShardedReplySubscriber.Close.
+func (o operationReplySubscriber) Close() error {
+	return nil
+}
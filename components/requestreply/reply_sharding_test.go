@@ -0,0 +1,129 @@
+package requestreply_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestShardedReplyTopics(t *testing.T) {
+	topics := requestreply.ShardedReplyTopics{Prefix: "replies", Shards: 4}
+	require.NoError(t, topics.Validate())
+
+	assert.Equal(t, []string{"replies_0", "replies_1", "replies_2", "replies_3"}, topics.Topics())
+
+	usedShards := map[int]struct{}{}
+	for i := 0; i < 100; i++ {
+		operationID := requestreply.OperationID(watermill.NewUUID())
+
+		shard := topics.Shard(operationID)
+		assert.Equal(t, shard, topics.Shard(operationID), "shard must be deterministic")
+		assert.Equal(t, fmt.Sprintf("replies_%d", shard), topics.Topic(operationID))
+
+		usedShards[shard] = struct{}{}
+	}
+	assert.Len(t, usedShards, 4)
+
+	_, err := requestreply.ShardedReplyTopics{}.GeneratePublishTopic(requestreply.PubSubBackendPublishParams{})
+	assert.ErrorContains(t, err, "missing Prefix")
+	assert.ErrorContains(t, err, "Shards must be greater than 0")
+}
+
+func TestRequestReply_sharded_reply_topics(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	replySubscriber, err := requestreply.NewShardedReplySubscriber(pubSub, logger)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, replySubscriber.Close())
+	}()
+
+	replyTopics := requestreply.ShardedReplyTopics{Prefix: "replies", Shards: 3}
+
+	backend, err := requestreply.NewPubSubBackend[TestCommandResult](
+		requestreply.PubSubBackendConfig{
+			Publisher:              pubSub,
+			SubscriberConstructor:  replySubscriber.SubscriberConstructor,
+			GeneratePublishTopic:   replyTopics.GeneratePublishTopic,
+			GenerateSubscribeTopic: replyTopics.GenerateSubscribeTopic,
+			Logger:                 logger,
+		},
+		requestreply.BackendPubsubJSONMarshaler[TestCommandResult]{},
+	)
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	commandBus, err := cqrs.NewCommandBusWithConfig(pubSub, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		Logger:    logger,
+	})
+	require.NoError(t, err)
+
+	commandProcessor, err := cqrs.NewCommandProcessorWithConfig(router, cqrs.CommandProcessorConfig{
+		GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+			return "commands", nil
+		},
+		SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+			return pubSub, nil
+		},
+		Marshaler: cqrs.JSONMarshaler{},
+		Logger:    logger,
+	})
+	require.NoError(t, err)
+
+	err = commandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			backend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			reply, err := requestreply.SendWithReply[TestCommandResult](
+				context.Background(),
+				commandBus,
+				backend,
+				&TestCommand{ID: id},
+			)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, reply.Error)
+			assert.Equal(t, id, reply.HandlerResult.ID)
+		}(fmt.Sprintf("%d", i))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,68 @@
+package requestreply
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ErrReplyNotFound is returned by ReplyStore.GetReply when there is no reply for the operation.
+var ErrReplyNotFound = errors.New("reply not found")
+
+// ErrReplyStoreNotConfigured is returned by PubSubBackend.GetReply when PubSubBackendConfig.ReplyStore is not set.
+var ErrReplyStoreNotConfigured = errors.New("ReplyStore is not configured")
+
+// ReplyStore persists reply notification messages keyed by operation ID.
+//
+// When ReplyStore is set in PubSubBackendConfig, replies are stored before they are published.
+// It allows a caller that didn't receive the reply (for example, because it was restarted)
+// to query it later with PubSubBackend.GetReply.
+type ReplyStore interface {
+	// StoreReply stores the reply notification message of the operation.
+	// If the command is handled multiple times, the last reply overwrites the previous ones.
+	StoreReply(ctx context.Context, operationID OperationID, notificationMsg *message.Message) error
+
+	// GetReply returns the stored reply notification message of the operation.
+	// If there is no reply for the operation, ErrReplyNotFound is returned.
+	GetReply(ctx context.Context, operationID OperationID) (*message.Message, error)
+}
+
+// InMemoryReplyStore is a ReplyStore that keeps replies in memory.
+//
+// It's useful for tests and for a single-instance service.
+// Keep in mind that replies are never removed and are lost when the process exits.
+type InMemoryReplyStore struct {
+	replies map[OperationID]*message.Message
+	lock    sync.RWMutex
+}
+
+// NewInMemoryReplyStore creates a new InMemoryReplyStore.
+func NewInMemoryReplyStore() *InMemoryReplyStore {
+	return &InMemoryReplyStore{
+		replies: map[OperationID]*message.Message{},
+	}
+}
+
+func (s *InMemoryReplyStore) StoreReply(ctx context.Context, operationID OperationID, notificationMsg *message.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.replies[operationID] = notificationMsg.DeepCopy()
+
+	return nil
+}
+
+func (s *InMemoryReplyStore) GetReply(ctx context.Context, operationID OperationID) (*message.Message, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	msg, ok := s.replies[operationID]
+	if !ok {
+		return nil, ErrReplyNotFound
+	}
+
+	return msg.DeepCopy(), nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/ThreeDotsLabs/watermill/message"
 )
 
@@ -31,6 +33,24 @@ type Reply[Result any] struct {
 	//
 	// Warning: NotificationMessage is nil if a timeout occurs.
 	NotificationMessage *message.Message
+
+	// HandlerInstanceID identifies the instance that handled the command.
+	// It's present only if the instance has set it (for example, with PubSubBackendConfig.HandlerInstanceID).
+	HandlerInstanceID string
+
+	// HandlerDuration is the time the command handler was running, without the time spent in the transport.
+	// It allows observing the latency of the handler without correlating it with the handler's metrics.
+	HandlerDuration time.Duration
+
+	// ProcessedAt is the time when the command handler finished processing the command, in the handler's clock.
+	ProcessedAt time.Time
+
+	// Attempt is the number of the handler's attempt that produced the reply, starting from 1.
+	// Attempts are counted by the handler instance, so redeliveries to other instances are not counted.
+	//
+	// HandlerDuration, ProcessedAt, and Attempt are zero if the handler didn't send them
+	// (for example, it's running an older version of Watermill).
+	Attempt int
 }
 
 type Backend[Result any] interface {
@@ -38,6 +58,32 @@ type Backend[Result any] interface {
 	OnCommandProcessed(ctx context.Context, params BackendOnCommandProcessedParams[Result]) error
 }
 
+// OperationIDGenerator is an optional interface of Backend.
+// If the backend implements it, SendWithReplies uses it to generate operation IDs instead of random UUIDs.
+type OperationIDGenerator interface {
+	GenerateOperationID(ctx context.Context, cmd any) (OperationID, error)
+}
+
+// ErrOperationReplayed is returned by OperationValidator when the operation was already processed.
+var ErrOperationReplayed = errors.New("operation already processed")
+
+// OperationValidator is an optional interface of Backend.
+// If the backend implements it, NewCommandHandler and NewCommandHandlerWithResult validate the operation
+// before calling the handler.
+type OperationValidator interface {
+	// ValidateOperation is called before the command is handled.
+	//
+	// If it returns an error wrapping ErrOperationReplayed, the handler is not called and the command is acked.
+	// The backend is responsible for sending the reply of the replayed operation again, if needed.
+	// If it returns any other error, the handler is not called and the error is returned (so the command is nacked).
+	ValidateOperation(ctx context.Context, params BackendValidateOperationParams) error
+}
+
+type BackendValidateOperationParams struct {
+	Command        any
+	CommandMessage *message.Message
+}
+
 type BackendListenForNotificationsParams struct {
 	Command     any
 	OperationID OperationID
@@ -49,19 +95,51 @@ type BackendOnCommandProcessedParams[Result any] struct {
 
 	HandlerResult Result
 	HandleErr     error
+
+	// HandlerDuration, ProcessedAt, and Attempt describe the handler's execution,
+	// they are sent to the caller in the Reply.
+	HandlerDuration time.Duration
+	ProcessedAt     time.Time
+	Attempt         int
 }
 
 // OperationID is a unique identifier of a command.
 // It correlates commands with replies between the bus and the handler.
 type OperationID string
 
+type operationIDContextKey struct{}
+
+// ContextWithOperationID returns a context which makes SendWithReply and SendWithReplies
+// use the provided operation ID instead of generating a new one.
+// It takes precedence over OperationIDGenerator.
+//
+// It's useful together with PubSubBackendConfig.ReplyStore: the caller can save the operation ID before sending
+// the command and query the reply with PubSubBackend.GetReply after a restart.
+func ContextWithOperationID(ctx context.Context, operationID OperationID) context.Context {
+	return context.WithValue(ctx, operationIDContextKey{}, operationID)
+}
+
+// OperationIDFromContext returns the operation ID set with ContextWithOperationID.
+func OperationIDFromContext(ctx context.Context) (OperationID, bool) {
+	operationID, ok := ctx.Value(operationIDContextKey{}).(OperationID)
+	return operationID, ok && operationID != ""
+}
+
 // ReplyTimeoutError is returned when the reply timeout is exceeded.
 type ReplyTimeoutError struct {
 	Duration time.Duration
 	Err      error
+
+	// Phase tells if the command was consumed by the handler before the timeout,
+	// so the caller can decide if retrying makes sense (see PubSubBackendConfig.NotifyCommandConsumed).
+	Phase TimeoutPhase
 }
 
 func (e ReplyTimeoutError) Error() string {
+	if e.Phase != TimeoutPhaseUnknown {
+		return fmt.Sprintf("reply timeout after %s (%s): %s", e.Duration, e.Phase, e.Err)
+	}
+
 	return fmt.Sprintf("reply timeout after %s: %s", e.Duration, e.Err)
 }
 
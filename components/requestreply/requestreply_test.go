@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,6 +39,21 @@ type TestServicesConfig struct {
 	AssertNotificationMessage func(t *testing.T, msg *message.Message)
 
 	DoNotBlockPublishUntilSubscriberAck bool
+
+	Clock watermill.Clock
+
+	ReplyStore requestreply.ReplyStore
+
+	HandlerInstanceID  string
+	ReplyOnlyWhenAcked bool
+
+	GenerateOperationID      requestreply.PubSubBackendGenerateOperationIDFn
+	ValidateOperationID      requestreply.PubSubBackendValidateOperationIDFn
+	RejectReplayedOperations bool
+
+	LifecycleEvents *requestreply.LifecycleEventsConfig
+
+	NotifyCommandConsumed bool
 }
 
 func NewTestServices[Result any](t *testing.T, c TestServicesConfig) TestServices[Result] {
@@ -90,6 +106,18 @@ func NewTestServices[Result any](t *testing.T, c TestServicesConfig) TestService
 		},
 		AckCommandErrors:      !c.DoNotAckOnCommandErrors,
 		ListenForReplyTimeout: c.ListenForReplyTimeout,
+		Clock:                 c.Clock,
+		ReplyStore:            c.ReplyStore,
+		HandlerInstanceID:     c.HandlerInstanceID,
+		ReplyOnlyWhenAcked:    c.ReplyOnlyWhenAcked,
+
+		GenerateOperationID:      c.GenerateOperationID,
+		ValidateOperationID:      c.ValidateOperationID,
+		RejectReplayedOperations: c.RejectReplayedOperations,
+
+		LifecycleEvents: c.LifecycleEvents,
+
+		NotifyCommandConsumed: c.NotifyCommandConsumed,
 	}
 	backend, err := requestreply.NewPubSubBackend[Result](
 		backendConfig,
@@ -288,6 +316,55 @@ func TestRequestReply_with_result_no_error(t *testing.T) {
 	}
 }
 
+func TestRequestReply_GetReply(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		ReplyStore: requestreply.NewInMemoryReplyStore(),
+	})
+
+	expectedResult := TestCommandResult{ID: "123"}
+	expectedErr := errors.New("some error")
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return expectedResult, expectedErr
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	operationID := requestreply.OperationID(watermill.NewUUID())
+
+	_, err = ts.RequestReplyBackend.GetReply(context.Background(), operationID)
+	assert.ErrorIs(t, err, requestreply.ErrReplyNotFound)
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		requestreply.ContextWithOperationID(context.Background(), operationID),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, string(operationID), reply.NotificationMessage.Metadata.Get(requestreply.OperationIDMetadataKey))
+
+	storedReply, err := ts.RequestReplyBackend.GetReply(context.Background(), operationID)
+	require.NoError(t, err)
+	assert.EqualValues(t, expectedResult, storedReply.HandlerResult)
+	assert.EqualError(t, storedReply.Error, expectedErr.Error())
+	assert.Equal(t, string(operationID), storedReply.NotificationMessage.Metadata.Get(requestreply.OperationIDMetadataKey))
+}
+
+func TestRequestReply_GetReply_without_store(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	_, err := ts.RequestReplyBackend.GetReply(context.Background(), "1")
+	assert.EqualError(t, err, "ReplyStore is not configured")
+}
+
 func TestRequestReply_with_result_with_error(t *testing.T) {
 	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
 
@@ -443,6 +520,327 @@ func TestRequestReply_without_result_multiple_replies(t *testing.T) {
 	}
 }
 
+func TestRequestReply_handler_instance_id(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		HandlerInstanceID: "instance-1",
+	})
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "instance-1", reply.HandlerInstanceID)
+	assert.Equal(t, "instance-1", reply.NotificationMessage.Metadata.Get(requestreply.HandlerInstanceIDMetadataKey))
+}
+
+func TestRequestReply_handler_execution_metadata(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		DoNotAckOnCommandErrors: true,
+		ReplyOnlyWhenAcked:      true,
+	})
+
+	var attempts int32
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				if atomic.AddInt32(&attempts, 1) < 2 {
+					return TestCommandResult{}, errors.New("temporary error")
+				}
+
+				time.Sleep(time.Millisecond * 10)
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	sentAt := time.Now()
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, reply.Error)
+
+	assert.Equal(t, 2, reply.Attempt)
+	assert.GreaterOrEqual(t, reply.HandlerDuration, time.Millisecond*10)
+	assert.WithinRange(t, reply.ProcessedAt, sentAt, time.Now())
+
+	assert.Equal(t, "2", reply.NotificationMessage.Metadata.Get(requestreply.AttemptMetadataKey))
+	assert.NotEmpty(t, reply.NotificationMessage.Metadata.Get(requestreply.HandlerDurationMetadataKey))
+	assert.NotEmpty(t, reply.NotificationMessage.Metadata.Get(requestreply.ProcessedAtMetadataKey))
+
+	// attempts are counted per command
+	reply, err = requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "2"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reply.Attempt)
+}
+
+func TestRequestReply_reply_only_when_acked(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		DoNotAckOnCommandErrors: true,
+		ReplyOnlyWhenAcked:      true,
+	})
+
+	var attempts int32
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				if atomic.AddInt32(&attempts, 1) < 3 {
+					return TestCommandResult{}, errors.New("temporary error")
+				}
+				return TestCommandResult{ID: cmd.ID}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	replyCh, cancel, err := requestreply.SendWithReplies[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	select {
+	case reply := <-replyCh:
+		require.NoError(t, reply.Error)
+		assert.Equal(t, "1", reply.HandlerResult.ID)
+		assert.EqualValues(t, 3, atomic.LoadInt32(&attempts))
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+
+	select {
+	case reply := <-replyCh:
+		t.Fatalf("only one reply should be received, got: %#v", reply)
+	case <-time.After(time.Millisecond * 50):
+		// ok
+	}
+}
+
+func TestRequestReply_reject_replayed_operations(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		ReplyStore: requestreply.NewInMemoryReplyStore(),
+		GenerateOperationID: func(ctx context.Context, cmd any) (requestreply.OperationID, error) {
+			return requestreply.OperationID("test-command-" + cmd.(*TestCommand).ID), nil
+		},
+		RejectReplayedOperations: true,
+	})
+
+	var calls int32
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				return TestCommandResult{ID: fmt.Sprintf("%s-%d", cmd.ID, atomic.AddInt32(&calls, 1))}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	for i := 0; i < 3; i++ {
+		reply, err := requestreply.SendWithReply[TestCommandResult](
+			context.Background(),
+			ts.CommandBus,
+			ts.RequestReplyBackend,
+			&TestCommand{ID: "1"},
+		)
+		require.NoError(t, err)
+		require.NoError(t, reply.Error)
+
+		assert.Equal(t, "1-1", reply.HandlerResult.ID, "stored reply should be sent for replayed operation")
+		assert.Equal(t, "test-command-1", reply.NotificationMessage.Metadata.Get(requestreply.OperationIDMetadataKey))
+	}
+
+	reply, err := requestreply.SendWithReply[TestCommandResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "2"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "2-2", reply.HandlerResult.ID)
+
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+}
+
+func TestRequestReply_reject_replayed_operations_retry(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{
+		ReplyStore: requestreply.NewInMemoryReplyStore(),
+		GenerateOperationID: func(ctx context.Context, cmd any) (requestreply.OperationID, error) {
+			return requestreply.OperationID("test-command-" + cmd.(*TestCommand).ID), nil
+		},
+		RejectReplayedOperations: true,
+		DoNotAckOnCommandErrors:  true,
+	})
+
+	var calls int32
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+				call := atomic.AddInt32(&calls, 1)
+				if call == 1 {
+					return TestCommandResult{}, errors.New("temporary error")
+				}
+				return TestCommandResult{ID: fmt.Sprintf("%s-%d", cmd.ID, call)}, nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	ctx, cancelCtx := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelCtx()
+
+	replyCh, cancel, err := requestreply.SendWithReplies[TestCommandResult](
+		ctx,
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	defer cancel()
+
+	var replies []requestreply.Reply[TestCommandResult]
+	for reply := range replyCh {
+		replies = append(replies, reply)
+		if reply.Error == nil {
+			break
+		}
+	}
+
+	require.Len(t, replies, 2)
+	assert.EqualError(t, replies[0].Error, "temporary error")
+	assert.NoError(t, replies[1].Error)
+	assert.Equal(t, "1-2", replies[1].HandlerResult.ID, "redelivered command should be handled again")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+
+	storedReply, err := ts.RequestReplyBackend.GetReply(context.Background(), "test-command-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1-2", storedReply.HandlerResult.ID)
+}
+
+func TestRequestReply_validate_operation_id(t *testing.T) {
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		ValidateOperationID: func(ctx context.Context, params requestreply.PubSubBackendPublishParams) error {
+			if params.OperationID == "replayed" {
+				return requestreply.ErrOperationReplayed
+			}
+			return nil
+		},
+	})
+
+	var calls int32
+
+	err := ts.CommandProcessor.AddHandlers(
+		requestreply.NewCommandHandler[TestCommand](
+			"test_handler",
+			ts.RequestReplyBackend,
+			func(ctx context.Context, cmd *TestCommand) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	ts.RunRouter()
+
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		requestreply.ContextWithOperationID(context.Background(), "replayed"),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+
+	select {
+	case reply := <-replyCh:
+		t.Fatalf("no reply should be sent for replayed operation without ReplyStore, got: %#v", reply)
+	case <-time.After(time.Millisecond * 100):
+		// ok
+	}
+	cancel()
+
+	reply, err := requestreply.SendWithReply[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "2"},
+	)
+	require.NoError(t, err)
+	require.NoError(t, reply.Error)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestNewPubSubBackend_reject_replayed_operations_without_store(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := requestreply.NewPubSubBackend[requestreply.NoResult](
+		requestreply.PubSubBackendConfig{
+			Publisher: pubSub,
+			SubscriberConstructor: func(params requestreply.PubSubBackendSubscribeParams) (message.Subscriber, error) {
+				return pubSub, nil
+			},
+			GenerateSubscribeTopic: func(params requestreply.PubSubBackendSubscribeParams) (string, error) {
+				return "reply", nil
+			},
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply", nil
+			},
+			RejectReplayedOperations: true,
+		},
+		requestreply.BackendPubsubJSONMarshaler[requestreply.NoResult]{},
+	)
+	assert.ErrorContains(t, err, "ReplyStore is required when RejectReplayedOperations is enabled")
+}
+
 func TestRequestReply_timout(t *testing.T) {
 	timeout := time.Millisecond * 10
 
@@ -488,6 +886,48 @@ func TestRequestReply_timout(t *testing.T) {
 	}
 }
 
+func TestRequestReply_timeout_clock(t *testing.T) {
+	timeout := time.Minute
+	clock := watermill.NewFakeClock(time.Now())
+
+	ts := NewTestServices[requestreply.NoResult](t, TestServicesConfig{
+		ListenForReplyTimeout: &timeout,
+		Clock:                 clock,
+	})
+
+	replyCh, cancel, err := requestreply.SendWithReplies[requestreply.NoResult](
+		context.Background(),
+		ts.CommandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+	)
+	require.NoError(t, err)
+	require.NotNil(t, replyCh)
+	defer cancel()
+
+	clock.BlockUntilWaiters(1)
+
+	select {
+	case reply := <-replyCh:
+		t.Fatalf("reply should not be received before timeout: %#v", reply)
+	case <-time.After(time.Millisecond * 10):
+		// ok
+	}
+
+	clock.Advance(timeout)
+
+	select {
+	case reply := <-replyCh:
+		require.IsType(t, requestreply.ReplyTimeoutError{}, reply.Error)
+
+		replyTimeoutError := reply.Error.(requestreply.ReplyTimeoutError)
+		assert.Equal(t, context.DeadlineExceeded, replyTimeoutError.Err)
+		assert.Equal(t, timeout, replyTimeoutError.Duration)
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+}
+
 func TestRequestReply_context_cancellation(t *testing.T) {
 	ts := NewTestServices[struct{}](t, TestServicesConfig{})
 
@@ -0,0 +1,150 @@
+package requestreply
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ResultVersionMetadataKey is the metadata key of the reply containing the version of the result schema.
+const ResultVersionMetadataKey = "_watermill_requestreply_result_version"
+
+// ResultVersionDecoder unmarshals the reply with a result of a different version than the current one.
+type ResultVersionDecoder[Result any] func(msg *message.Message) (Reply[Result], error)
+
+// ConvertResultVersion returns a ResultVersionDecoder which unmarshals the reply with the result
+// of the Old type, using the marshaler, and converts it to the current Result type.
+//
+//	requestreply.ConvertResultVersion[OrderResultV1, OrderResult](
+//		requestreply.BackendPubsubJSONMarshaler[OrderResultV1]{},
+//		func(old OrderResultV1) (OrderResult, error) {
+//			return OrderResult{ID: old.OrderID}, nil
+//		},
+//	)
+func ConvertResultVersion[Old any, Result any](
+	marshaler BackendPubsubMarshaler[Old],
+	convert func(Old) (Result, error),
+) ResultVersionDecoder[Result] {
+	return func(msg *message.Message) (Reply[Result], error) {
+		oldReply, err := marshaler.UnmarshalReply(msg)
+		if err != nil {
+			return Reply[Result]{}, err
+		}
+
+		result, err := convert(oldReply.HandlerResult)
+		if err != nil {
+			return Reply[Result]{}, errors.Wrap(err, "cannot convert result")
+		}
+
+		return Reply[Result]{
+			HandlerResult: result,
+			Error:         oldReply.Error,
+		}, nil
+	}
+}
+
+// UnsupportedResultVersionError is returned when the reply has a result version without a decoder.
+type UnsupportedResultVersionError struct {
+	Version           string
+	SupportedVersions []string
+}
+
+func (e UnsupportedResultVersionError) Error() string {
+	return fmt.Sprintf(
+		"unsupported result version %q, supported versions: %s",
+		e.Version,
+		strings.Join(e.SupportedVersions, ", "),
+	)
+}
+
+// BackendPubsubVersionedMarshaler is a BackendPubsubMarshaler which encodes the result schema version in the reply
+// metadata (ResultVersionMetadataKey), and decodes replies of other versions with the registered decoders.
+//
+// It allows the result struct to evolve without breaking in-flight callers during rolling deploys:
+// the handler sends replies of its Version, and callers register Decoders for versions
+// which can be sent by handlers not yet (or already) upgraded.
+//
+// Replies without the version (sent by handlers not using BackendPubsubVersionedMarshaler)
+// are decoded with the decoder registered for the empty version, or with Marshaler if there's none.
+type BackendPubsubVersionedMarshaler[Result any] struct {
+	// Marshaler marshals and unmarshals replies of the current Version. It is required.
+	Marshaler BackendPubsubMarshaler[Result]
+
+	// Version is the version of the Result type. It is required.
+	Version string
+
+	// Decoders unmarshal replies of other versions, keyed by the version.
+	Decoders map[string]ResultVersionDecoder[Result]
+}
+
+func (m BackendPubsubVersionedMarshaler[Result]) MarshalReply(
+	params BackendOnCommandProcessedParams[Result],
+) (*message.Message, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	msg, err := m.Marshaler.MarshalReply(params)
+	if err != nil {
+		return nil, err
+	}
+	msg.Metadata.Set(ResultVersionMetadataKey, m.Version)
+
+	return msg, nil
+}
+
+func (m BackendPubsubVersionedMarshaler[Result]) UnmarshalReply(msg *message.Message) (Reply[Result], error) {
+	if err := m.validate(); err != nil {
+		return Reply[Result]{}, err
+	}
+
+	version := msg.Metadata.Get(ResultVersionMetadataKey)
+	if version == m.Version {
+		return m.Marshaler.UnmarshalReply(msg)
+	}
+
+	decoder, ok := m.Decoders[version]
+	if ok {
+		reply, err := decoder(msg)
+		if err != nil {
+			return Reply[Result]{}, errors.Wrapf(err, "cannot decode result version %q", version)
+		}
+		return reply, nil
+	}
+
+	if version == "" {
+		return m.Marshaler.UnmarshalReply(msg)
+	}
+
+	return Reply[Result]{}, UnsupportedResultVersionError{
+		Version:           version,
+		SupportedVersions: m.supportedVersions(),
+	}
+}
+
+func (m BackendPubsubVersionedMarshaler[Result]) validate() error {
+	if m.Marshaler == nil {
+		return errors.New("missing Marshaler")
+	}
+	if m.Version == "" {
+		return errors.New("missing Version")
+	}
+
+	return nil
+}
+
+func (m BackendPubsubVersionedMarshaler[Result]) supportedVersions() []string {
+	versions := []string{m.Version}
+	for version := range m.Decoders {
+		if version != "" {
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+
+	return versions
+}
@@ -0,0 +1,102 @@
+package requestreply_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+)
+
+type TestCommandResultV1 struct {
+	OrderID string `json:"order_id"`
+}
+
+type TestCommandResultV2 struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func TestBackendPubsubVersionedMarshaler(t *testing.T) {
+	handlerV1Marshaler := requestreply.BackendPubsubVersionedMarshaler[TestCommandResultV1]{
+		Marshaler: requestreply.BackendPubsubJSONMarshaler[TestCommandResultV1]{},
+		Version:   "1",
+	}
+
+	callerMarshaler := requestreply.BackendPubsubVersionedMarshaler[TestCommandResultV2]{
+		Marshaler: requestreply.BackendPubsubJSONMarshaler[TestCommandResultV2]{},
+		Version:   "2",
+		Decoders: map[string]requestreply.ResultVersionDecoder[TestCommandResultV2]{
+			"1": requestreply.ConvertResultVersion[TestCommandResultV1, TestCommandResultV2](
+				requestreply.BackendPubsubJSONMarshaler[TestCommandResultV1]{},
+				func(old TestCommandResultV1) (TestCommandResultV2, error) {
+					return TestCommandResultV2{ID: old.OrderID, Status: "unknown"}, nil
+				},
+			),
+		},
+	}
+
+	t.Run("current_version", func(t *testing.T) {
+		msg, err := callerMarshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResultV2]{
+			HandlerResult: TestCommandResultV2{ID: "123", Status: "paid"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "2", msg.Metadata.Get(requestreply.ResultVersionMetadataKey))
+
+		reply, err := callerMarshaler.UnmarshalReply(msg)
+		require.NoError(t, err)
+		assert.Equal(t, TestCommandResultV2{ID: "123", Status: "paid"}, reply.HandlerResult)
+	})
+
+	t.Run("older_version", func(t *testing.T) {
+		msg, err := handlerV1Marshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResultV1]{
+			HandlerResult: TestCommandResultV1{OrderID: "123"},
+			HandleErr:     errors.New("some error"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "1", msg.Metadata.Get(requestreply.ResultVersionMetadataKey))
+
+		reply, err := callerMarshaler.UnmarshalReply(msg)
+		require.NoError(t, err)
+		assert.Equal(t, TestCommandResultV2{ID: "123", Status: "unknown"}, reply.HandlerResult)
+		assert.EqualError(t, reply.Error, "some error")
+	})
+
+	t.Run("without_version", func(t *testing.T) {
+		msg, err := requestreply.BackendPubsubJSONMarshaler[TestCommandResultV2]{}.MarshalReply(
+			requestreply.BackendOnCommandProcessedParams[TestCommandResultV2]{
+				HandlerResult: TestCommandResultV2{ID: "123"},
+			},
+		)
+		require.NoError(t, err)
+
+		reply, err := callerMarshaler.UnmarshalReply(msg)
+		require.NoError(t, err)
+		assert.Equal(t, TestCommandResultV2{ID: "123"}, reply.HandlerResult)
+	})
+
+	t.Run("unsupported_version", func(t *testing.T) {
+		msg, err := callerMarshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResultV2]{})
+		require.NoError(t, err)
+		msg.Metadata.Set(requestreply.ResultVersionMetadataKey, "3")
+
+		_, err = callerMarshaler.UnmarshalReply(msg)
+
+		var versionErr requestreply.UnsupportedResultVersionError
+		require.ErrorAs(t, err, &versionErr)
+		assert.Equal(t, "3", versionErr.Version)
+		assert.Equal(t, []string{"1", "2"}, versionErr.SupportedVersions)
+		assert.EqualError(t, err, `unsupported result version "3", supported versions: 1, 2`)
+	})
+
+	t.Run("missing_version", func(t *testing.T) {
+		marshaler := requestreply.BackendPubsubVersionedMarshaler[TestCommandResultV2]{
+			Marshaler: requestreply.BackendPubsubJSONMarshaler[TestCommandResultV2]{},
+		}
+
+		_, err := marshaler.MarshalReply(requestreply.BackendOnCommandProcessedParams[TestCommandResultV2]{})
+		assert.EqualError(t, err, "missing Version")
+	})
+}
@@ -0,0 +1,179 @@
+package requestreply
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// GatherConfig configures when SendWithGather stops gathering the replies.
+// At least one of ExpectedReplies, Quorum, and Timeout must be set.
+type GatherConfig struct {
+	// ExpectedReplies is the number of replies after which gathering stops, for example, the number of shards.
+	// Disabled if 0.
+	ExpectedReplies int
+
+	// Quorum is the number of successful replies (without Error) after which gathering stops.
+	// Disabled if 0.
+	Quorum int
+
+	// Timeout is the maximum time of gathering. When it's exceeded, the replies gathered so far are returned.
+	// Disabled if 0.
+	Timeout time.Duration
+
+	// UniqueHandlerInstances makes SendWithGather gather only the first reply of every handler instance
+	// (see Reply.HandlerInstanceID), so the command redelivered to the same service is not counted twice.
+	// Replies without HandlerInstanceID are always gathered.
+	UniqueHandlerInstances bool
+
+	// Clock is used to measure Timeout.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (c *GatherConfig) setDefaults() {
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+}
+
+func (c GatherConfig) Validate() error {
+	var err error
+
+	if c.ExpectedReplies == 0 && c.Quorum == 0 && c.Timeout == 0 {
+		err = multierror.Append(err, errors.New("one of ExpectedReplies, Quorum, or Timeout must be set"))
+	}
+	if c.ExpectedReplies < 0 {
+		err = multierror.Append(err, errors.New("ExpectedReplies must not be negative"))
+	}
+	if c.Quorum < 0 {
+		err = multierror.Append(err, errors.New("Quorum must not be negative"))
+	}
+	if c.ExpectedReplies > 0 && c.Quorum > c.ExpectedReplies {
+		err = multierror.Append(err, errors.New("Quorum must not be greater than ExpectedReplies"))
+	}
+	if c.Timeout < 0 {
+		err = multierror.Append(err, errors.New("Timeout must not be negative"))
+	}
+
+	return err
+}
+
+// GatheredReplies are the replies gathered by SendWithGather.
+type GatheredReplies[Result any] struct {
+	// Replies are the gathered replies, in the order they were received.
+	// Replies with ReplyTimeoutError sent by the backend are not included.
+	Replies []Reply[Result]
+
+	// Successful is the number of replies without Error.
+	Successful int
+
+	// Complete is true if ExpectedReplies or Quorum was reached.
+	// It's false if gathering stopped because of Timeout or the backend stopped listening for the replies.
+	Complete bool
+}
+
+// Results returns the results of the successful replies.
+func (g GatheredReplies[Result]) Results() []Result {
+	results := make([]Result, 0, g.Successful)
+	for _, reply := range g.Replies {
+		if reply.Error == nil {
+			results = append(results, reply.HandlerResult)
+		}
+	}
+
+	return results
+}
+
+func (g GatheredReplies[Result]) complete(config GatherConfig) bool {
+	if config.ExpectedReplies > 0 && len(g.Replies) >= config.ExpectedReplies {
+		return true
+	}
+	if config.Quorum > 0 && g.Successful >= config.Quorum {
+		return true
+	}
+
+	return false
+}
+
+// SendWithGather sends the command to the command bus and gathers the replies of multiple handlers (scatter-gather),
+// for example, to query multiple shards or to collect bids.
+//
+// The command must be delivered to all handlers by the Pub/Sub, for example, by subscribing every handler service
+// with its own consumer group. Replies are gathered until ExpectedReplies or Quorum is reached, the Timeout is exceeded,
+// or the backend stops listening for the replies (for example, after PubSubBackendConfig.ListenForReplyTimeout).
+// Reaching the Timeout is not an error, check GatheredReplies.Complete instead.
+//
+// If the context is canceled, the replies gathered so far are returned with the error.
+func SendWithGather[Result any](
+	ctx context.Context,
+	c CommandBus,
+	backend Backend[Result],
+	cmd any,
+	config GatherConfig,
+) (GatheredReplies[Result], error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return GatheredReplies[Result]{}, errors.Wrap(err, "invalid config")
+	}
+
+	replyCh, cancel, err := SendWithReplies[Result](ctx, c, backend, cmd)
+	if err != nil {
+		return GatheredReplies[Result]{}, errors.Wrap(err, "SendWithReplies failed")
+	}
+	defer func() {
+		cancel()
+
+		// draining replies, so the listening goroutine can exit
+		go func() {
+			for range replyCh {
+			}
+		}()
+	}()
+
+	var timeout <-chan time.Time
+	if config.Timeout > 0 {
+		timeout = config.Clock.After(config.Timeout)
+	}
+
+	var gathered GatheredReplies[Result]
+	handlerInstances := map[string]struct{}{}
+
+	for !gathered.Complete {
+		select {
+		case <-ctx.Done():
+			return gathered, errors.Wrap(ctx.Err(), "context closed")
+		case <-timeout:
+			return gathered, nil
+		case reply, ok := <-replyCh:
+			if !ok {
+				return gathered, nil
+			}
+
+			var timeoutErr ReplyTimeoutError
+			if errors.As(reply.Error, &timeoutErr) {
+				// the backend stopped listening, the channel is closed next
+				continue
+			}
+
+			if config.UniqueHandlerInstances && reply.HandlerInstanceID != "" {
+				if _, ok := handlerInstances[reply.HandlerInstanceID]; ok {
+					continue
+				}
+				handlerInstances[reply.HandlerInstanceID] = struct{}{}
+			}
+
+			gathered.Replies = append(gathered.Replies, reply)
+			if reply.Error == nil {
+				gathered.Successful++
+			}
+			gathered.Complete = gathered.complete(config)
+		}
+	}
+
+	return gathered, nil
+}
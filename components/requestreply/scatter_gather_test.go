@@ -0,0 +1,277 @@
+package requestreply_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/cqrs"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+// repliesBackend returns the replies sent to the channel.
+type repliesBackend[Result any] struct {
+	requestreply.Backend[Result]
+	replies chan requestreply.Reply[Result]
+}
+
+func newRepliesBackend[Result any](replies ...requestreply.Reply[Result]) repliesBackend[Result] {
+	b := repliesBackend[Result]{replies: make(chan requestreply.Reply[Result], len(replies)+1)}
+	for _, reply := range replies {
+		b.replies <- reply
+	}
+
+	return b
+}
+
+func (b repliesBackend[Result]) ListenForNotifications(
+	ctx context.Context,
+	params requestreply.BackendListenForNotificationsParams,
+) (<-chan requestreply.Reply[Result], error) {
+	return b.replies, nil
+}
+
+type sentCommandBus struct{}
+
+func (sentCommandBus) SendWithModifiedMessage(ctx context.Context, cmd any, modify func(*message.Message) error) error {
+	return modify(message.NewMessage(watermill.NewUUID(), nil))
+}
+
+func bid(instanceID string, amount string) requestreply.Reply[TestCommandResult] {
+	return requestreply.Reply[TestCommandResult]{
+		HandlerResult:     TestCommandResult{ID: amount},
+		HandlerInstanceID: instanceID,
+	}
+}
+
+func failedBid(instanceID string) requestreply.Reply[TestCommandResult] {
+	return requestreply.Reply[TestCommandResult]{
+		Error:             requestreply.CommandHandlerError{Err: errors.New("no bid")},
+		HandlerInstanceID: instanceID,
+	}
+}
+
+func TestSendWithGather(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Config  requestreply.GatherConfig
+		Replies []requestreply.Reply[TestCommandResult]
+
+		ExpectedReplies  int
+		ExpectedResults  []TestCommandResult
+		ExpectedComplete bool
+	}{
+		{
+			Name:             "expected_replies",
+			Config:           requestreply.GatherConfig{ExpectedReplies: 2},
+			Replies:          []requestreply.Reply[TestCommandResult]{bid("a", "1"), failedBid("b"), bid("c", "3")},
+			ExpectedReplies:  2,
+			ExpectedResults:  []TestCommandResult{{ID: "1"}},
+			ExpectedComplete: true,
+		},
+		{
+			Name:             "quorum",
+			Config:           requestreply.GatherConfig{Quorum: 2},
+			Replies:          []requestreply.Reply[TestCommandResult]{bid("a", "1"), failedBid("b"), bid("c", "3"), bid("d", "4")},
+			ExpectedReplies:  3,
+			ExpectedResults:  []TestCommandResult{{ID: "1"}, {ID: "3"}},
+			ExpectedComplete: true,
+		},
+		{
+			Name:             "unique_handler_instances",
+			Config:           requestreply.GatherConfig{ExpectedReplies: 2, UniqueHandlerInstances: true},
+			Replies:          []requestreply.Reply[TestCommandResult]{bid("a", "1"), bid("a", "1"), bid("b", "2")},
+			ExpectedReplies:  2,
+			ExpectedResults:  []TestCommandResult{{ID: "1"}, {ID: "2"}},
+			ExpectedComplete: true,
+		},
+		{
+			Name:   "backend_timeout",
+			Config: requestreply.GatherConfig{ExpectedReplies: 3},
+			Replies: []requestreply.Reply[TestCommandResult]{
+				bid("a", "1"),
+				{Error: requestreply.ReplyTimeoutError{Duration: time.Second, Err: context.DeadlineExceeded}},
+			},
+			ExpectedReplies:  1,
+			ExpectedResults:  []TestCommandResult{{ID: "1"}},
+			ExpectedComplete: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			backend := newRepliesBackend(tc.Replies...)
+			if !tc.ExpectedComplete {
+				close(backend.replies)
+			}
+
+			gathered, err := requestreply.SendWithGather[TestCommandResult](
+				context.Background(),
+				sentCommandBus{},
+				backend,
+				&TestCommand{ID: "1"},
+				tc.Config,
+			)
+			require.NoError(t, err)
+
+			assert.Len(t, gathered.Replies, tc.ExpectedReplies)
+			assert.Equal(t, tc.ExpectedResults, gathered.Results())
+			assert.Equal(t, len(tc.ExpectedResults), gathered.Successful)
+			assert.Equal(t, tc.ExpectedComplete, gathered.Complete)
+		})
+	}
+}
+
+func TestSendWithGather_timeout(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+	backend := newRepliesBackend(bid("a", "1"))
+
+	type result struct {
+		gathered requestreply.GatheredReplies[TestCommandResult]
+		err      error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		gathered, err := requestreply.SendWithGather[TestCommandResult](
+			context.Background(),
+			sentCommandBus{},
+			backend,
+			&TestCommand{ID: "1"},
+			requestreply.GatherConfig{ExpectedReplies: 3, Timeout: time.Second, Clock: clock},
+		)
+		done <- result{gathered, err}
+	}()
+
+	clock.BlockUntilWaiters(1)
+	clock.Advance(time.Second)
+
+	select {
+	case r := <-done:
+		require.NoError(t, r.err)
+		assert.Len(t, r.gathered.Replies, 1)
+		assert.False(t, r.gathered.Complete)
+	case <-time.After(time.Second):
+		t.Fatal("SendWithGather should return after the timeout")
+	}
+}
+
+func TestSendWithGather_context_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	backend := newRepliesBackend(bid("a", "1"))
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+
+	gathered, err := requestreply.SendWithGather[TestCommandResult](
+		ctx,
+		sentCommandBus{},
+		backend,
+		&TestCommand{ID: "1"},
+		requestreply.GatherConfig{ExpectedReplies: 3},
+	)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, gathered.Replies, 1, "replies gathered before canceling should be returned")
+}
+
+func TestSendWithGather_invalid_config(t *testing.T) {
+	testCases := []requestreply.GatherConfig{
+		{},
+		{ExpectedReplies: -1},
+		{Quorum: 3, ExpectedReplies: 2},
+		{Timeout: -time.Second},
+	}
+
+	for i, config := range testCases {
+		config := config
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			_, err := requestreply.SendWithGather[TestCommandResult](
+				context.Background(),
+				sentCommandBus{},
+				newRepliesBackend[TestCommandResult](),
+				&TestCommand{ID: "1"},
+				config,
+			)
+			assert.ErrorContains(t, err, "invalid config")
+		})
+	}
+}
+
+func TestSendWithGather_multiple_handlers(t *testing.T) {
+	ts := NewTestServices[TestCommandResult](t, TestServicesConfig{})
+
+	// the commands are broadcast to all shards with a separate Pub/Sub
+	commandsPubSub := gochannel.NewGoChannel(gochannel.Config{}, ts.Logger)
+	commandBus, err := cqrs.NewCommandBusWithConfig(commandsPubSub, cqrs.CommandBusConfig{
+		GeneratePublishTopic: func(params cqrs.CommandBusGeneratePublishTopicParams) (string, error) {
+			return "commands", nil
+		},
+		Marshaler: ts.Marshaler,
+	})
+	require.NoError(t, err)
+
+	shards := []string{"shard_1", "shard_2", "shard_3"}
+
+	for _, shard := range shards {
+		shard := shard
+
+		// every shard is a separate service subscribed to the commands topic
+		processor, err := cqrs.NewCommandProcessorWithConfig(ts.Router, cqrs.CommandProcessorConfig{
+			GenerateSubscribeTopic: func(params cqrs.CommandProcessorGenerateSubscribeTopicParams) (string, error) {
+				return "commands", nil
+			},
+			SubscriberConstructor: func(params cqrs.CommandProcessorSubscriberConstructorParams) (message.Subscriber, error) {
+				return commandsPubSub, nil
+			},
+			Marshaler: ts.Marshaler,
+			Logger:    ts.Logger,
+		})
+		require.NoError(t, err)
+
+		err = processor.AddHandlers(
+			requestreply.NewCommandHandlerWithResult[TestCommand, TestCommandResult](
+				shard,
+				ts.RequestReplyBackend,
+				func(ctx context.Context, cmd *TestCommand) (TestCommandResult, error) {
+					return TestCommandResult{ID: shard}, nil
+				},
+			),
+		)
+		require.NoError(t, err)
+	}
+
+	ts.RunRouter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	gathered, err := requestreply.SendWithGather[TestCommandResult](
+		ctx,
+		commandBus,
+		ts.RequestReplyBackend,
+		&TestCommand{ID: "1"},
+		requestreply.GatherConfig{ExpectedReplies: len(shards), Timeout: time.Second * 5},
+	)
+	require.NoError(t, err)
+	require.True(t, gathered.Complete)
+
+	var repliedShards []string
+	for _, result := range gathered.Results() {
+		repliedShards = append(repliedShards, result.ID)
+	}
+	sort.Strings(repliedShards)
+
+	assert.Equal(t, shards, repliedShards)
+}
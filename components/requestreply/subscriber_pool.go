@@ -0,0 +1,193 @@
+package requestreply
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// OperationIDPlaceholder replaces the operation ID in the reply topic in the default SubscriberPoolConfig.Key.
+const OperationIDPlaceholder = "{operation_id}"
+
+// PubSubBackendSubscriberPoolKeyFn returns the key of the subscriber pool for the reply topic.
+type PubSubBackendSubscriberPoolKeyFn func(params PubSubBackendSubscribeParams, replyTopic string) string
+
+// SubscriberPoolConfig configures reusing the subscribers created by PubSubBackendConfig.SubscriberConstructor
+// between the commands waiting for replies.
+//
+// Without the pool, a new subscriber is created for every command sent with SendWithReply.
+// With many concurrent calls, it results in many connections to the broker being opened and closed.
+type SubscriberPoolConfig struct {
+	// Size is the maximum number of idle subscribers kept for one key.
+	// Subscribers released when the pool is full are closed.
+	// If not provided, 10 is used.
+	Size int
+
+	// IdleTTL is the time after which an idle subscriber is closed.
+	// If not provided, one minute is used.
+	IdleTTL time.Duration
+
+	// Key returns the key of the pool from which the subscriber is taken.
+	// The subscribers with the same key must be interchangeable.
+	//
+	// If not provided, the reply topic pattern is used: the reply topic
+	// with the operation ID replaced with OperationIDPlaceholder.
+	Key PubSubBackendSubscriberPoolKeyFn
+}
+
+func (c *SubscriberPoolConfig) setDefaults() {
+	if c.Size == 0 {
+		c.Size = 10
+	}
+	if c.IdleTTL == 0 {
+		c.IdleTTL = time.Minute
+	}
+	if c.Key == nil {
+		c.Key = func(params PubSubBackendSubscribeParams, replyTopic string) string {
+			if params.OperationID == "" {
+				return replyTopic
+			}
+
+			return strings.ReplaceAll(replyTopic, string(params.OperationID), OperationIDPlaceholder)
+		}
+	}
+}
+
+func (c SubscriberPoolConfig) Validate() error {
+	var err error
+
+	if c.Size < 0 {
+		err = multierror.Append(err, errors.New("SubscriberPool.Size must be positive"))
+	}
+	if c.IdleTTL < 0 {
+		err = multierror.Append(err, errors.New("SubscriberPool.IdleTTL must be positive"))
+	}
+
+	return err
+}
+
+type idleSubscriber struct {
+	subscriber message.Subscriber
+	idleSince  time.Time
+}
+
+// subscriberPool keeps the idle subscribers by the key.
+// Subscriptions are scoped to the context of a single call, the subscribers are reused for the next subscriptions.
+type subscriberPool struct {
+	config SubscriberPoolConfig
+	clock  watermill.Clock
+	logger watermill.LoggerAdapter
+
+	idle   map[string][]idleSubscriber
+	closed bool
+	lock   sync.Mutex
+}
+
+func newSubscriberPool(config SubscriberPoolConfig, clock watermill.Clock, logger watermill.LoggerAdapter) *subscriberPool {
+	return &subscriberPool{
+		config: config,
+		clock:  clock,
+		logger: logger,
+		idle:   map[string][]idleSubscriber{},
+	}
+}
+
+// Get returns an idle subscriber with the key, or a new subscriber created with newSubscriber.
+func (p *subscriberPool) Get(key string, newSubscriber func() (message.Subscriber, error)) (message.Subscriber, error) {
+	p.lock.Lock()
+	expired := p.removeExpired()
+
+	var subscriber message.Subscriber
+	if idle := p.idle[key]; len(idle) > 0 {
+		// the most recently used subscriber is taken, so the least recently used ones can expire
+		subscriber = idle[len(idle)-1].subscriber
+		p.idle[key] = idle[:len(idle)-1]
+	}
+	p.lock.Unlock()
+
+	p.closeSubscribers(expired)
+
+	if subscriber != nil {
+		p.logger.Trace("Reusing pooled request/reply subscriber", watermill.LogFields{"pool_key": key})
+		return subscriber, nil
+	}
+
+	return newSubscriber()
+}
+
+// Put returns the subscriber to the pool, or closes it if the pool is full or closed.
+func (p *subscriberPool) Put(key string, subscriber message.Subscriber) {
+	p.lock.Lock()
+	toClose := p.removeExpired()
+
+	if p.closed || len(p.idle[key]) >= p.config.Size {
+		toClose = append(toClose, subscriber)
+	} else {
+		p.idle[key] = append(p.idle[key], idleSubscriber{
+			subscriber: subscriber,
+			idleSince:  p.clock.Now(),
+		})
+	}
+	p.lock.Unlock()
+
+	p.closeSubscribers(toClose)
+}
+
+// Close closes the idle subscribers. Subscribers put back to the pool after Close are closed.
+func (p *subscriberPool) Close() error {
+	p.lock.Lock()
+	p.closed = true
+
+	var toClose []message.Subscriber
+	for key, idle := range p.idle {
+		for _, s := range idle {
+			toClose = append(toClose, s.subscriber)
+		}
+		delete(p.idle, key)
+	}
+	p.lock.Unlock()
+
+	var err error
+	for _, subscriber := range toClose {
+		if closeErr := subscriber.Close(); closeErr != nil {
+			err = multierror.Append(err, closeErr)
+		}
+	}
+
+	return err
+}
+
+// removeExpired removes the subscribers idle for longer than IdleTTL. It must be called with the lock held.
+func (p *subscriberPool) removeExpired() []message.Subscriber {
+	now := p.clock.Now()
+
+	var expired []message.Subscriber
+	for key, idle := range p.idle {
+		// subscribers are appended in the order of release, so the expired ones are at the beginning
+		i := 0
+		for ; i < len(idle) && now.Sub(idle[i].idleSince) >= p.config.IdleTTL; i++ {
+			expired = append(expired, idle[i].subscriber)
+		}
+
+		if i == len(idle) {
+			delete(p.idle, key)
+		} else {
+			p.idle[key] = idle[i:]
+		}
+	}
+
+	return expired
+}
+
+func (p *subscriberPool) closeSubscribers(subscribers []message.Subscriber) {
+	for _, subscriber := range subscribers {
+		if err := subscriber.Close(); err != nil {
+			p.logger.Error("Cannot close pooled request/reply subscriber", err, nil)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package requestreply_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/requestreply"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type poolTestSubscriber struct {
+	message.Subscriber
+
+	lock   *sync.Mutex
+	closed *int
+}
+
+func (s poolTestSubscriber) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	*s.closed++
+	return nil
+}
+
+type poolTestSubscribers struct {
+	pubSub *gochannel.GoChannel
+
+	lock    sync.Mutex
+	created int
+	closed  int
+}
+
+func (s *poolTestSubscribers) Constructor(params requestreply.PubSubBackendSubscribeParams) (message.Subscriber, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.created++
+	return poolTestSubscriber{Subscriber: s.pubSub, lock: &s.lock, closed: &s.closed}, nil
+}
+
+func (s *poolTestSubscribers) Counts() (created int, closed int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.created, s.closed
+}
+
+func newPoolTestBackend(t *testing.T, pool requestreply.SubscriberPoolConfig, clock watermill.Clock) (*requestreply.PubSubBackend[requestreply.NoResult], *poolTestSubscribers) {
+	t.Helper()
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	subscribers := &poolTestSubscribers{pubSub: pubSub}
+
+	backend, err := requestreply.NewPubSubBackend[requestreply.NoResult](
+		requestreply.PubSubBackendConfig{
+			Publisher:             pubSub,
+			SubscriberConstructor: subscribers.Constructor,
+			GenerateSubscribeTopic: func(params requestreply.PubSubBackendSubscribeParams) (string, error) {
+				return "reply_" + string(params.OperationID), nil
+			},
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply_" + string(params.OperationID), nil
+			},
+			Clock:          clock,
+			SubscriberPool: &pool,
+		},
+		requestreply.BackendPubsubJSONMarshaler[requestreply.NoResult]{},
+	)
+	require.NoError(t, err)
+
+	return backend, subscribers
+}
+
+// listen starts listening for the reply of a new operation, and returns the function finishing it.
+func listen(t *testing.T, backend *requestreply.PubSubBackend[requestreply.NoResult]) func() {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	replies, err := backend.ListenForNotifications(ctx, requestreply.BackendListenForNotificationsParams{
+		Command:     &TestCommand{ID: "1"},
+		OperationID: requestreply.OperationID(watermill.NewUUID()),
+	})
+	require.NoError(t, err)
+
+	return func() {
+		cancel()
+		for range replies {
+			// waiting until the listening is finished
+		}
+	}
+}
+
+func TestPubSubBackend_SubscriberPool_reuses_subscribers(t *testing.T) {
+	backend, subscribers := newPoolTestBackend(t, requestreply.SubscriberPoolConfig{}, nil)
+
+	for i := 0; i < 3; i++ {
+		finish := listen(t, backend)
+		finish()
+	}
+
+	created, closed := subscribers.Counts()
+	assert.Equal(t, 1, created)
+	assert.Equal(t, 0, closed)
+
+	require.NoError(t, backend.Close())
+
+	_, closed = subscribers.Counts()
+	assert.Equal(t, 1, closed)
+}
+
+func TestPubSubBackend_SubscriberPool_size(t *testing.T) {
+	backend, subscribers := newPoolTestBackend(t, requestreply.SubscriberPoolConfig{Size: 1}, nil)
+
+	finish1 := listen(t, backend)
+	finish2 := listen(t, backend)
+	finish1()
+	finish2()
+
+	created, closed := subscribers.Counts()
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 1, closed, "subscriber released to the full pool should be closed")
+
+	finish := listen(t, backend)
+	finish()
+
+	created, _ = subscribers.Counts()
+	assert.Equal(t, 2, created)
+}
+
+func TestPubSubBackend_SubscriberPool_idle_ttl(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	backend, subscribers := newPoolTestBackend(t, requestreply.SubscriberPoolConfig{IdleTTL: time.Minute}, clock)
+
+	finish := listen(t, backend)
+	finish()
+
+	clock.Advance(time.Minute)
+
+	finish = listen(t, backend)
+	finish()
+
+	created, closed := subscribers.Counts()
+	assert.Equal(t, 2, created)
+	assert.Equal(t, 1, closed, "expired subscriber should be closed")
+}
+
+func TestPubSubBackend_SubscriberPool_key(t *testing.T) {
+	var keys []string
+
+	backend, subscribers := newPoolTestBackend(t, requestreply.SubscriberPoolConfig{
+		Key: func(params requestreply.PubSubBackendSubscribeParams, replyTopic string) string {
+			keys = append(keys, replyTopic)
+			return replyTopic
+		},
+	}, nil)
+
+	for i := 0; i < 2; i++ {
+		finish := listen(t, backend)
+		finish()
+	}
+
+	require.Len(t, keys, 2)
+	assert.NotEqual(t, keys[0], keys[1])
+
+	created, _ := subscribers.Counts()
+	assert.Equal(t, 2, created, "subscribers with different keys should not be reused")
+}
+
+func TestPubSubBackend_SubscriberPool_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := requestreply.NewPubSubBackend[requestreply.NoResult](
+		requestreply.PubSubBackendConfig{
+			Publisher: pubSub,
+			SubscriberConstructor: func(params requestreply.PubSubBackendSubscribeParams) (message.Subscriber, error) {
+				return pubSub, nil
+			},
+			GenerateSubscribeTopic: func(params requestreply.PubSubBackendSubscribeParams) (string, error) {
+				return "reply", nil
+			},
+			GeneratePublishTopic: func(params requestreply.PubSubBackendPublishParams) (string, error) {
+				return "reply", nil
+			},
+			SubscriberPool: &requestreply.SubscriberPoolConfig{Size: -1},
+		},
+		requestreply.BackendPubsubJSONMarshaler[requestreply.NoResult]{},
+	)
+	assert.ErrorContains(t, err, "SubscriberPool.Size must be positive")
+}
@@ -0,0 +1,273 @@
+package statestore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DefaultKeyMetadataKey is the metadata key containing the entry's key, used when Config.KeyMetadataKey is empty.
+const DefaultKeyMetadataKey = "statestore_key"
+
+// ErrReadOnly is returned by Set and Delete when Store was created without a publisher.
+var ErrReadOnly = errors.New("state store is read-only, publisher is not provided")
+
+// Entry is the last value of the key.
+type Entry struct {
+	Key   string
+	Value []byte
+
+	// Message is the message which carried the value.
+	Message *message.Message
+}
+
+// OnUpdateFn is called after the entry of the key is updated or deleted in the view.
+type OnUpdateFn func(key string, value []byte, deleted bool)
+
+type Config struct {
+	// Topic is the topic with the entries' updates. It should be a compacted topic, if the Pub/Sub supports it.
+	Topic string
+
+	// KeyMetadataKey is the metadata key containing the entry's key.
+	// If empty, DefaultKeyMetadataKey is used.
+	KeyMetadataKey string
+
+	// OnUpdate is called after the view is updated. It's optional.
+	OnUpdate OnUpdateFn
+}
+
+func (c *Config) setDefaults() {
+	if c.KeyMetadataKey == "" {
+		c.KeyMetadataKey = DefaultKeyMetadataKey
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c Config) Validate() error {
+	if c.Topic == "" {
+		return errors.New("topic must not be empty")
+	}
+
+	return nil
+}
+
+// Store maintains a local, materialized key-value view of the topic.
+//
+// Every message on the topic is an update of one key (the key is stored in the message's metadata).
+// The last received value wins. A message with an empty payload (a tombstone) deletes the key.
+// It's the same semantics as in Kafka's compacted topics, so Store can be used like a KTable with any Pub/Sub.
+//
+// Keep in mind that the view is complete only if the subscriber receives all messages from the beginning of the topic
+// (for example, a Kafka subscriber with the oldest offset and a unique consumer group).
+// The order of updates of the same key must be preserved by the Pub/Sub.
+type Store struct {
+	subscriber message.Subscriber
+	publisher  message.Publisher
+	config     Config
+	logger     watermill.LoggerAdapter
+
+	entries     map[string]Entry
+	entriesLock sync.RWMutex
+
+	running     chan struct{}
+	runningOnce sync.Once
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	runWg     sync.WaitGroup
+}
+
+// NewStore creates a new Store.
+// Publisher is optional. If it's nil, the Store is read-only.
+func NewStore(
+	subscriber message.Subscriber,
+	publisher message.Publisher,
+	config Config,
+	logger watermill.LoggerAdapter,
+) (*Store, error) {
+	if subscriber == nil {
+		return nil, errors.New("missing subscriber")
+	}
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return &Store{
+		subscriber: subscriber,
+		publisher:  publisher,
+		config:     config,
+		logger: logger.With(watermill.LogFields{
+			"topic": config.Topic,
+		}),
+		entries: map[string]Entry{},
+		running: make(chan struct{}),
+		closing: make(chan struct{}),
+	}, nil
+}
+
+// Run subscribes to the topic and updates the view until the context is canceled or Close is called.
+func (s *Store) Run(ctx context.Context) error {
+	s.runWg.Add(1)
+	defer s.runWg.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages, err := s.subscriber.Subscribe(ctx, s.config.Topic)
+	if err != nil {
+		return errors.Wrapf(err, "cannot subscribe to %s", s.config.Topic)
+	}
+
+	s.runningOnce.Do(func() {
+		close(s.running)
+	})
+
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				s.logger.Debug("Subscriber closed, stopping state store", nil)
+				return nil
+			}
+
+			s.apply(msg)
+			msg.Ack()
+		case <-ctx.Done():
+			return nil
+		case <-s.closing:
+			return nil
+		}
+	}
+}
+
+// Running is closed when Store is subscribed to the topic.
+func (s *Store) Running() chan struct{} {
+	return s.running
+}
+
+// Close stops updating the view. The view can be still queried after Close.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+
+	s.runWg.Wait()
+
+	return nil
+}
+
+func (s *Store) apply(msg *message.Message) {
+	key := msg.Metadata.Get(s.config.KeyMetadataKey)
+	if key == "" {
+		s.logger.Error("Received message without key, skipping", nil, watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"key_metadata": s.config.KeyMetadataKey,
+		})
+		return
+	}
+
+	deleted := len(msg.Payload) == 0
+
+	s.entriesLock.Lock()
+	if deleted {
+		delete(s.entries, key)
+	} else {
+		s.entries[key] = Entry{
+			Key:     key,
+			Value:   msg.Payload,
+			Message: msg,
+		}
+	}
+	s.entriesLock.Unlock()
+
+	s.logger.Trace("State store entry updated", watermill.LogFields{
+		"key":          key,
+		"deleted":      deleted,
+		"message_uuid": msg.UUID,
+	})
+
+	if s.config.OnUpdate != nil {
+		s.config.OnUpdate(key, msg.Payload, deleted)
+	}
+}
+
+// Get returns the last value of the key.
+func (s *Store) Get(key string) (Entry, bool) {
+	s.entriesLock.RLock()
+	defer s.entriesLock.RUnlock()
+
+	entry, ok := s.entries[key]
+	return entry, ok
+}
+
+// Keys returns the sorted keys present in the view.
+func (s *Store) Keys() []string {
+	s.entriesLock.RLock()
+	defer s.entriesLock.RUnlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Snapshot returns a copy of all entries present in the view.
+func (s *Store) Snapshot() map[string]Entry {
+	s.entriesLock.RLock()
+	defer s.entriesLock.RUnlock()
+
+	snapshot := make(map[string]Entry, len(s.entries))
+	for key, entry := range s.entries {
+		snapshot[key] = entry
+	}
+
+	return snapshot
+}
+
+// Set publishes the new value of the key.
+// The view is updated when the message is received from the topic, not when Set returns.
+func (s *Store) Set(ctx context.Context, key string, value []byte) error {
+	if len(value) == 0 {
+		return errors.New("value must not be empty, use Delete to delete the key")
+	}
+
+	return s.publish(ctx, key, value)
+}
+
+// Delete publishes the tombstone (a message with an empty payload) of the key.
+// The view is updated when the message is received from the topic, not when Delete returns.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.publish(ctx, key, nil)
+}
+
+func (s *Store) publish(ctx context.Context, key string, value []byte) error {
+	if s.publisher == nil {
+		return ErrReadOnly
+	}
+	if key == "" {
+		return errors.New("key must not be empty")
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), value)
+	msg.Metadata.Set(s.config.KeyMetadataKey, key)
+	msg.SetContext(ctx)
+
+	if err := s.publisher.Publish(s.config.Topic, msg); err != nil {
+		return errors.Wrapf(err, "cannot publish update of key %s", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package statestore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/statestore"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type update struct {
+	Key     string
+	Value   string
+	Deleted bool
+}
+
+func TestStore(t *testing.T) {
+	logger := watermill.NewStdLogger(true, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true, BlockPublishUntilSubscriberAck: true}, logger)
+
+	// updates published before the store was started are part of the view
+	require.NoError(t, pubSub.Publish("users", newUpdate("1", "alice")))
+
+	updates := make(chan update, 10)
+
+	store, err := statestore.NewStore(pubSub, pubSub, statestore.Config{
+		Topic: "users",
+		OnUpdate: func(key string, value []byte, deleted bool) {
+			updates <- update{Key: key, Value: string(value), Deleted: deleted}
+		},
+	}, logger)
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, store.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+	<-store.Running()
+
+	readUpdates := func(count int) []update {
+		var received []update
+		for i := 0; i < count; i++ {
+			select {
+			case u := <-updates:
+				received = append(received, u)
+			case <-time.After(time.Second):
+				t.Fatal("timeout waiting for update")
+			}
+		}
+		return received
+	}
+
+	// waiting for the initial state, before publishing new updates
+	received := readUpdates(1)
+
+	ctx := context.Background()
+	require.NoError(t, store.Set(ctx, "2", []byte("bob")))
+	require.NoError(t, store.Set(ctx, "1", []byte("alice v2")))
+	require.NoError(t, store.Delete(ctx, "2"))
+
+	received = append(received, readUpdates(3)...)
+
+	assert.Equal(t, []update{
+		{Key: "1", Value: "alice"},
+		{Key: "2", Value: "bob"},
+		{Key: "1", Value: "alice v2"},
+		{Key: "2", Deleted: true},
+	}, received)
+
+	entry, ok := store.Get("1")
+	require.True(t, ok)
+	assert.Equal(t, "alice v2", string(entry.Value))
+
+	_, ok = store.Get("2")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"1"}, store.Keys())
+	assert.Len(t, store.Snapshot(), 1)
+}
+
+func TestStore_skips_messages_without_key(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+
+	updates := make(chan string, 10)
+
+	store, err := statestore.NewStore(pubSub, nil, statestore.Config{
+		Topic:          "users",
+		KeyMetadataKey: "user_id",
+		OnUpdate: func(key string, value []byte, deleted bool) {
+			updates <- key
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	withoutKey := message.NewMessage(watermill.NewUUID(), []byte("unknown"))
+	withKey := message.NewMessage(watermill.NewUUID(), []byte("alice"))
+	withKey.Metadata.Set("user_id", "1")
+
+	require.NoError(t, pubSub.Publish("users", withoutKey, withKey))
+
+	go func() {
+		assert.NoError(t, store.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, store.Close())
+	}()
+
+	select {
+	case key := <-updates:
+		assert.Equal(t, "1", key)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for update")
+	}
+
+	assert.Equal(t, []string{"1"}, store.Keys())
+}
+
+func TestStore_read_only(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	store, err := statestore.NewStore(pubSub, nil, statestore.Config{Topic: "users"}, nil)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, store.Set(context.Background(), "1", []byte("alice")), statestore.ErrReadOnly)
+	assert.ErrorIs(t, store.Delete(context.Background(), "1"), statestore.ErrReadOnly)
+}
+
+func TestNewStore_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := statestore.NewStore(pubSub, pubSub, statestore.Config{}, nil)
+	assert.ErrorContains(t, err, "topic must not be empty")
+
+	_, err = statestore.NewStore(nil, pubSub, statestore.Config{Topic: "users"}, nil)
+	assert.ErrorContains(t, err, "missing subscriber")
+}
+
+func newUpdate(key string, value string) *message.Message {
+	msg := message.NewMessage(watermill.NewUUID(), []byte(value))
+	msg.Metadata.Set(statestore.DefaultKeyMetadataKey, key)
+	return msg
+}
@@ -0,0 +1,255 @@
+// Package transactional provides Processor, which emulates exactly-once consume-transform-publish
+// pipelines on top of any pair of Pub/Subs, using an IdempotencyStore and deduplication keys.
+package transactional
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DeduplicationKeyMetadataKey is the metadata key containing the message's deduplication key.
+//
+// Processor sets it on all published messages, so the next Processor in the pipeline deduplicates them.
+const DeduplicationKeyMetadataKey = "deduplication_key"
+
+// DeduplicationKeyFn returns the deduplication key of the received message.
+// Messages with the same key are processed only once.
+type DeduplicationKeyFn func(msg *message.Message) (string, error)
+
+// DefaultDeduplicationKey returns the key from the DeduplicationKeyMetadataKey metadata,
+// or the message's UUID if it's not set.
+func DefaultDeduplicationKey(msg *message.Message) (string, error) {
+	if key := msg.Metadata.Get(DeduplicationKeyMetadataKey); key != "" {
+		return key, nil
+	}
+
+	return msg.UUID, nil
+}
+
+// Config holds the Processor's configuration options.
+type Config struct {
+	// HandlerName is the name of the router's handler. It's also a part of the outputs' deduplication keys,
+	// so the same message processed by two Processors produces outputs with different keys.
+	HandlerName string
+
+	SubscribeTopic string
+	PublishTopic   string
+
+	// Store keeps the state of processed messages. It's required.
+	Store IdempotencyStore
+
+	// DeduplicationKey returns the deduplication key of the received message.
+	// If nil, DefaultDeduplicationKey is used.
+	DeduplicationKey DeduplicationKeyFn
+
+	// StoreTimeout is the timeout of a single Store operation. Defaults to 10s.
+	StoreTimeout time.Duration
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *Config) setDefaults() {
+	if c.DeduplicationKey == nil {
+		c.DeduplicationKey = DefaultDeduplicationKey
+	}
+	if c.StoreTimeout == 0 {
+		c.StoreTimeout = time.Second * 10
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c Config) Validate() error {
+	var err error
+
+	if c.HandlerName == "" {
+		err = multierror.Append(err, errors.New("missing HandlerName"))
+	}
+	if c.SubscribeTopic == "" {
+		err = multierror.Append(err, errors.New("missing SubscribeTopic"))
+	}
+	if c.PublishTopic == "" {
+		err = multierror.Append(err, errors.New("missing PublishTopic"))
+	}
+	if c.Store == nil {
+		err = multierror.Append(err, errors.New("missing Store"))
+	}
+	if c.StoreTimeout < 0 {
+		err = multierror.Append(err, errors.New("StoreTimeout must not be negative"))
+	}
+
+	return err
+}
+
+// Processor consumes messages, handles them and publishes the produced messages
+// with effectively-once semantics: every received message affects the output topic once,
+// even if it's redelivered or the service crashes in the middle of processing.
+//
+// Most Pub/Subs don't support transactions spanning the consumer's ack and the producer's publish,
+// so Processor emulates them. Every received message is processed as follows:
+//
+//  1. The message's deduplication key is looked up in the Store. If it's completed, the message is acked and skipped.
+//  2. If there is a pending Record, its stored outputs are published again and the handler is not called.
+//  3. Otherwise, the handler is called. Its outputs get deterministic deduplication keys ("<key>/<handler name>/<index>"),
+//     and are saved in the Store as a pending Record before they are published.
+//  4. After all outputs are published, the Record is marked as completed and the message is acked.
+//
+// Failure modes:
+//
+//   - If the handler or saving the pending Record fails, the message is nacked and the handler is called again
+//     on redelivery. Side effects of the handler other than the outputs are not covered and must be idempotent.
+//   - If publishing fails or the service crashes after saving the pending Record, the same outputs (with the same UUIDs
+//     and deduplication keys) are published on redelivery. Outputs published before the failure are published twice,
+//     so the consumer of the output topic must deduplicate them by DeduplicationKeyMetadataKey
+//     (another Processor does it by default).
+//   - If marking the Record as completed fails or the service crashes before acking, the outputs are published
+//     again on the next delivery, with the same consequences as above.
+//   - If the same message is delivered to two instances at the same time, both may call the handler,
+//     and the outputs of the one which saved the Record later are published. The Store can prevent it
+//     by rejecting Save of a pending Record when another one exists.
+//   - If the Store loses Records (for example, because they expired before the message was redelivered),
+//     the message is processed again as a new one.
+type Processor struct {
+	config    Config
+	publisher message.Publisher
+	handler   message.HandlerFunc
+}
+
+// NewProcessor creates a new Processor and adds its handler to the router.
+// Messages are processed after the router is started.
+func NewProcessor(
+	router *message.Router,
+	subscriber message.Subscriber,
+	publisher message.Publisher,
+	handler message.HandlerFunc,
+	config Config,
+) (*Processor, error) {
+	if router == nil {
+		return nil, errors.New("missing router")
+	}
+	if subscriber == nil {
+		return nil, errors.New("missing subscriber")
+	}
+	if publisher == nil {
+		return nil, errors.New("missing publisher")
+	}
+	if handler == nil {
+		return nil, errors.New("missing handler")
+	}
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	p := &Processor{
+		config:    config,
+		publisher: publisher,
+		handler:   handler,
+	}
+
+	// outputs are published by the Processor, not by the router, to save the pending Record before publishing
+	router.AddNoPublisherHandler(
+		config.HandlerName,
+		config.SubscribeTopic,
+		subscriber,
+		p.handleMessage,
+	)
+
+	return p, nil
+}
+
+func (p *Processor) handleMessage(msg *message.Message) error {
+	key, err := p.config.DeduplicationKey(msg)
+	if err != nil {
+		return errors.Wrap(err, "cannot get deduplication key")
+	}
+
+	logFields := watermill.LogFields{
+		"message_uuid":      msg.UUID,
+		"deduplication_key": key,
+		"handler_name":      p.config.HandlerName,
+	}
+
+	record, found, err := p.getRecord(msg.Context(), key)
+	if err != nil {
+		return err
+	}
+
+	if found && record.Completed {
+		p.config.Logger.Debug("Message already processed, skipping", logFields)
+		return nil
+	}
+
+	if found {
+		p.config.Logger.Info("Message processing was not completed, publishing stored outputs", logFields)
+	} else {
+		outputs, err := p.handler(msg)
+		if err != nil {
+			return err
+		}
+
+		for i, output := range outputs {
+			output.Metadata.Set(DeduplicationKeyMetadataKey, p.outputDeduplicationKey(key, i))
+		}
+
+		record = Record{Outputs: outputs}
+		if err := p.saveRecord(msg.Context(), key, record); err != nil {
+			return errors.Wrap(err, "cannot save pending record")
+		}
+	}
+
+	if len(record.Outputs) > 0 {
+		outputs := make([]*message.Message, 0, len(record.Outputs))
+		for _, output := range record.Outputs {
+			output = output.Copy()
+			output.SetContext(msg.Context())
+			outputs = append(outputs, output)
+		}
+
+		if err := p.publisher.Publish(p.config.PublishTopic, outputs...); err != nil {
+			return errors.Wrap(err, "cannot publish outputs")
+		}
+	}
+
+	record.Completed = true
+	if err := p.saveRecord(msg.Context(), key, record); err != nil {
+		return errors.Wrap(err, "cannot save completed record")
+	}
+
+	return nil
+}
+
+func (p *Processor) outputDeduplicationKey(key string, index int) string {
+	return fmt.Sprintf("%s/%s/%d", key, p.config.HandlerName, index)
+}
+
+func (p *Processor) getRecord(ctx context.Context, key string) (Record, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.StoreTimeout)
+	defer cancel()
+
+	record, found, err := p.config.Store.Get(ctx, key)
+	if err != nil {
+		return Record{}, false, errors.Wrap(err, "cannot get record")
+	}
+
+	return record, found, nil
+}
+
+func (p *Processor) saveRecord(ctx context.Context, key string, record Record) error {
+	ctx, cancel := context.WithTimeout(ctx, p.config.StoreTimeout)
+	defer cancel()
+
+	return p.config.Store.Save(ctx, key, record)
+}
@@ -0,0 +1,242 @@
+package transactional_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/transactional"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func runRouter(t *testing.T, router *message.Router) {
+	t.Helper()
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+}
+
+// failingPublisher fails the first failures Publish calls.
+type failingPublisher struct {
+	message.Publisher
+
+	lock     sync.Mutex
+	failures int
+}
+
+func (p *failingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	if p.failures > 0 {
+		p.failures--
+		p.lock.Unlock()
+		return errors.New("publish failed")
+	}
+	p.lock.Unlock()
+
+	return p.Publisher.Publish(topic, messages...)
+}
+
+func splitHandler(calls *int32) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		atomic.AddInt32(calls, 1)
+
+		return []*message.Message{
+			message.NewMessage(watermill.NewUUID(), []byte(string(msg.Payload)+"1")),
+			message.NewMessage(watermill.NewUUID(), []byte(string(msg.Payload)+"2")),
+		}, nil
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	outputs, err := pubSub.Subscribe(context.Background(), "out")
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	var splitCalls, forwardCalls int32
+
+	_, err = transactional.NewProcessor(router, pubSub, pubSub, splitHandler(&splitCalls), transactional.Config{
+		HandlerName:    "split",
+		SubscribeTopic: "in",
+		PublishTopic:   "mid",
+		Store:          transactional.NewMemoryIdempotencyStore(transactional.MemoryIdempotencyStoreConfig{}),
+		Logger:         logger,
+	})
+	require.NoError(t, err)
+
+	_, err = transactional.NewProcessor(router, pubSub, pubSub, func(msg *message.Message) ([]*message.Message, error) {
+		atomic.AddInt32(&forwardCalls, 1)
+		return []*message.Message{message.NewMessage(watermill.NewUUID(), msg.Payload)}, nil
+	}, transactional.Config{
+		HandlerName:    "forward",
+		SubscribeTopic: "mid",
+		PublishTopic:   "out",
+		Store:          transactional.NewMemoryIdempotencyStore(transactional.MemoryIdempotencyStoreConfig{}),
+		Logger:         logger,
+	})
+	require.NoError(t, err)
+
+	runRouter(t, router)
+
+	input := message.NewMessage(watermill.NewUUID(), []byte("a"))
+	require.NoError(t, pubSub.Publish("in", input))
+
+	received, all := subscriber.BulkRead(outputs, 2, time.Second)
+	require.True(t, all)
+
+	var payloads, keys []string
+	for _, msg := range received {
+		payloads = append(payloads, string(msg.Payload))
+		keys = append(keys, msg.Metadata.Get(transactional.DeduplicationKeyMetadataKey))
+	}
+	assert.ElementsMatch(t, []string{"a1", "a2"}, payloads)
+	assert.ElementsMatch(t, []string{
+		input.UUID + "/split/0/forward/0",
+		input.UUID + "/split/1/forward/0",
+	}, keys)
+
+	// redelivered input is skipped
+	require.NoError(t, pubSub.Publish("in", input.Copy()))
+
+	// duplicate of an output of the first processor (for example, published again after a crash) is skipped
+	duplicate := message.NewMessage(watermill.NewUUID(), []byte("a1"))
+	duplicate.Metadata.Set(transactional.DeduplicationKeyMetadataKey, input.UUID+"/split/0")
+	require.NoError(t, pubSub.Publish("mid", duplicate))
+
+	received, _ = subscriber.BulkRead(outputs, 1, time.Millisecond*100)
+	assert.Empty(t, received)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&splitCalls))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&forwardCalls))
+}
+
+func TestProcessor_publishes_stored_outputs_after_failure(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	outputs, err := pubSub.Subscribe(context.Background(), "out")
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	var calls int32
+	store := transactional.NewMemoryIdempotencyStore(transactional.MemoryIdempotencyStoreConfig{})
+
+	_, err = transactional.NewProcessor(
+		router,
+		pubSub,
+		&failingPublisher{Publisher: pubSub, failures: 2},
+		splitHandler(&calls),
+		transactional.Config{
+			HandlerName:    "split",
+			SubscribeTopic: "in",
+			PublishTopic:   "out",
+			Store:          store,
+			Logger:         logger,
+		},
+	)
+	require.NoError(t, err)
+
+	runRouter(t, router)
+
+	input := message.NewMessage(watermill.NewUUID(), []byte("a"))
+	require.NoError(t, pubSub.Publish("in", input))
+
+	received, all := subscriber.BulkRead(outputs, 2, time.Second)
+	require.True(t, all)
+
+	record, found, err := store.Get(context.Background(), input.UUID)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.True(t, record.Completed)
+
+	// outputs published after redelivery are the ones stored when the message was handled for the first time
+	require.Len(t, record.Outputs, 2)
+	assert.ElementsMatch(
+		t,
+		[]string{record.Outputs[0].UUID, record.Outputs[1].UUID},
+		[]string{received[0].UUID, received[1].UUID},
+	)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestProcessor_custom_deduplication_key(t *testing.T) {
+	logger := watermill.NewStdLogger(false, false)
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	outputs, err := pubSub.Subscribe(context.Background(), "out")
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	var calls int32
+
+	_, err = transactional.NewProcessor(router, pubSub, pubSub, splitHandler(&calls), transactional.Config{
+		HandlerName:    "split",
+		SubscribeTopic: "in",
+		PublishTopic:   "out",
+		Store:          transactional.NewMemoryIdempotencyStore(transactional.MemoryIdempotencyStoreConfig{}),
+		DeduplicationKey: func(msg *message.Message) (string, error) {
+			return msg.Metadata.Get("order_id"), nil
+		},
+		Logger: logger,
+	})
+	require.NoError(t, err)
+
+	runRouter(t, router)
+
+	for i := 0; i < 2; i++ {
+		msg := message.NewMessage(watermill.NewUUID(), []byte("a"))
+		msg.Metadata.Set("order_id", "order-1")
+		require.NoError(t, pubSub.Publish("in", msg))
+	}
+
+	received, all := subscriber.BulkRead(outputs, 2, time.Second)
+	require.True(t, all)
+	var keys []string
+	for _, msg := range received {
+		keys = append(keys, msg.Metadata.Get(transactional.DeduplicationKeyMetadataKey))
+	}
+	assert.ElementsMatch(t, []string{"order-1/split/0", "order-1/split/1"}, keys)
+
+	received, _ = subscriber.BulkRead(outputs, 1, time.Millisecond*100)
+	assert.Empty(t, received)
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestNewProcessor_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	_, err = transactional.NewProcessor(router, pubSub, pubSub, splitHandler(new(int32)), transactional.Config{
+		HandlerName:    "split",
+		SubscribeTopic: "in",
+		PublishTopic:   "out",
+	})
+	assert.ErrorContains(t, err, "missing Store")
+}
@@ -0,0 +1,147 @@
+package transactional
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Record is the state of processing the message with the deduplication key, kept in IdempotencyStore.
+type Record struct {
+	// Outputs are the messages produced by the handler, exactly as they are published.
+	Outputs []*message.Message
+
+	// Completed is true when all Outputs were published.
+	Completed bool
+}
+
+// IdempotencyStore keeps the Records of processed messages by their deduplication keys.
+//
+// Processor relies on Save being durable once it returns: a Record which is lost
+// makes the message processed again, with new outputs.
+// All operations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Get returns the Record of the key. It returns false if the key is unknown.
+	Get(ctx context.Context, key string) (Record, bool, error)
+
+	// Save stores the Record of the key, replacing the previous one.
+	Save(ctx context.Context, key string, record Record) error
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore keeping Records in memory.
+//
+// The state **is not shared between instances** and is lost on restart,
+// so it's useful only for tests and services running a single instance that can accept duplicates after a restart.
+type MemoryIdempotencyStore struct {
+	retention time.Duration
+	clock     watermill.Clock
+
+	records     map[string]memoryRecord
+	recordsLock sync.Mutex
+}
+
+type memoryRecord struct {
+	record  Record
+	savedAt time.Time
+}
+
+// MemoryIdempotencyStoreConfig holds the MemoryIdempotencyStore's configuration options.
+type MemoryIdempotencyStoreConfig struct {
+	// Retention is the time for which completed Records are kept. If 0, Records are kept forever.
+	//
+	// It should be longer than the maximum time after which the message can be redelivered.
+	Retention time.Duration
+
+	// Clock is used to expire Records.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+// NewMemoryIdempotencyStore creates a new MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore(config MemoryIdempotencyStoreConfig) *MemoryIdempotencyStore {
+	if config.Clock == nil {
+		config.Clock = watermill.RealClock{}
+	}
+
+	return &MemoryIdempotencyStore{
+		retention: config.Retention,
+		clock:     config.Clock,
+		records:   map[string]memoryRecord{},
+	}
+}
+
+// Get returns the Record of the key. It returns false if the key is unknown.
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	s.recordsLock.Lock()
+	defer s.recordsLock.Unlock()
+
+	r, ok := s.records[key]
+	if !ok || s.expired(r) {
+		return Record{}, false, nil
+	}
+
+	return Record{
+		Outputs:   copyMessages(r.record.Outputs),
+		Completed: r.record.Completed,
+	}, true, nil
+}
+
+// Save stores the Record of the key, replacing the previous one.
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, record Record) error {
+	s.recordsLock.Lock()
+	defer s.recordsLock.Unlock()
+
+	s.removeExpired()
+
+	s.records[key] = memoryRecord{
+		record: Record{
+			Outputs:   copyMessages(record.Outputs),
+			Completed: record.Completed,
+		},
+		savedAt: s.clock.Now(),
+	}
+
+	return nil
+}
+
+// Len returns the number of stored Records.
+func (s *MemoryIdempotencyStore) Len() int {
+	s.recordsLock.Lock()
+	defer s.recordsLock.Unlock()
+
+	s.removeExpired()
+
+	return len(s.records)
+}
+
+func (s *MemoryIdempotencyStore) expired(r memoryRecord) bool {
+	if s.retention == 0 || !r.record.Completed {
+		return false
+	}
+
+	return s.clock.Now().Sub(r.savedAt) > s.retention
+}
+
+func (s *MemoryIdempotencyStore) removeExpired() {
+	for key, r := range s.records {
+		if s.expired(r) {
+			delete(s.records, key)
+		}
+	}
+}
+
+func copyMessages(messages []*message.Message) []*message.Message {
+	if messages == nil {
+		return nil
+	}
+
+	copied := make([]*message.Message, 0, len(messages))
+	for _, msg := range messages {
+		copied = append(copied, msg.Copy())
+	}
+
+	return copied
+}
@@ -0,0 +1,53 @@
+package transactional_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/transactional"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	store := transactional.NewMemoryIdempotencyStore(transactional.MemoryIdempotencyStoreConfig{
+		Retention: time.Minute,
+		Clock:     clock,
+	})
+
+	_, found, err := store.Get(ctx, "pending")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	output := message.NewMessage(watermill.NewUUID(), []byte("output"))
+	require.NoError(t, store.Save(ctx, "pending", transactional.Record{Outputs: []*message.Message{output}}))
+	require.NoError(t, store.Save(ctx, "completed", transactional.Record{Completed: true}))
+
+	record, found, err := store.Get(ctx, "pending")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.False(t, record.Completed)
+	require.Len(t, record.Outputs, 1)
+	assert.Equal(t, output.UUID, record.Outputs[0].UUID)
+	assert.NotSame(t, output, record.Outputs[0])
+
+	clock.Advance(time.Minute * 2)
+
+	// pending records don't expire, because their outputs were not published yet
+	_, found, err = store.Get(ctx, "pending")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	_, found, err = store.Get(ctx, "completed")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	assert.Equal(t, 1, store.Len())
+}
@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// rateLimiter spaces the requests evenly, so no more than the limit of requests per second are sent.
+type rateLimiter struct {
+	interval time.Duration
+	clock    watermill.Clock
+
+	next time.Time
+	lock sync.Mutex
+}
+
+func newRateLimiter(perSecond float64, clock watermill.Clock) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / perSecond),
+		clock:    clock,
+	}
+}
+
+// Wait blocks until the request can be sent or the context is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.lock.Lock()
+	now := l.clock.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	// the slot is reserved before waiting, so concurrent requests wait for the consecutive slots
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.lock.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-l.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of the webhook requests.
+const (
+	// IDHeader is the UUID of the delivered message.
+	// It's the same for all attempts, so the receiver can deduplicate the deliveries.
+	IDHeader = "Webhook-Id"
+
+	// TimestampHeader is the Unix time (in seconds) of the attempt, included in the signature.
+	TimestampHeader = "Webhook-Timestamp"
+
+	// SignatureHeader is the signature of the request (see Sign).
+	// It's set only if the endpoint has a Secret.
+	SignatureHeader = "Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of the webhook request: "sha256=" followed by the hex-encoded HMAC-SHA256
+// of the message ID, the timestamp (Unix time in seconds), and the payload, joined with dots.
+//
+// Signing the ID and the timestamp prevents replaying the payload as a different or a later delivery.
+func Sign(secret []byte, messageID string, timestamp time.Time, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(messageID))
+	mac.Write([]byte("."))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of the webhook request, for receivers of the webhooks.
+// messageID, timestamp, and signature are the values of IDHeader, TimestampHeader, and SignatureHeader.
+//
+// If tolerance is not 0, the signature is rejected if the timestamp differs from now by more than tolerance.
+func Verify(secret []byte, messageID string, timestamp string, payload []byte, signature string, tolerance time.Duration, now time.Time) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	signedAt := time.Unix(unix, 0)
+
+	if tolerance > 0 {
+		diff := now.Sub(signedAt)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return false
+		}
+	}
+
+	expected := Sign(secret, messageID, signedAt, payload)
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
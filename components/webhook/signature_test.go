@@ -0,0 +1,113 @@
+package webhook_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ThreeDotsLabs/watermill/components/webhook"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	signedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	payload := []byte(`{"id":"1"}`)
+
+	signature := webhook.Sign(secret, "id", signedAt, payload)
+
+	testCases := []struct {
+		Name      string
+		Secret    []byte
+		ID        string
+		Timestamp string
+		Payload   []byte
+		Signature string
+		Now       time.Time
+		Valid     bool
+	}{
+		{
+			Name:      "valid",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: timestamp,
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt.Add(time.Minute),
+			Valid:     true,
+		},
+		{
+			Name:      "wrong_secret",
+			Secret:    []byte("other"),
+			ID:        "id",
+			Timestamp: timestamp,
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt,
+		},
+		{
+			Name:      "changed_payload",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: timestamp,
+			Payload:   []byte(`{"id":"2"}`),
+			Signature: signature,
+			Now:       signedAt,
+		},
+		{
+			Name:      "changed_id",
+			Secret:    secret,
+			ID:        "other",
+			Timestamp: timestamp,
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt,
+		},
+		{
+			Name:      "changed_timestamp",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: strconv.FormatInt(signedAt.Unix()+1, 10),
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt,
+		},
+		{
+			Name:      "timestamp_outside_tolerance",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: timestamp,
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt.Add(time.Hour),
+		},
+		{
+			Name:      "invalid_timestamp",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: "yesterday",
+			Payload:   payload,
+			Signature: signature,
+			Now:       signedAt,
+		},
+		{
+			Name:      "missing_prefix",
+			Secret:    secret,
+			ID:        "id",
+			Timestamp: timestamp,
+			Payload:   payload,
+			Signature: signature[len("sha256="):],
+			Now:       signedAt,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			valid := webhook.Verify(tc.Secret, tc.ID, tc.Timestamp, tc.Payload, tc.Signature, time.Minute*5, tc.Now)
+			assert.Equal(t, tc.Valid, valid)
+		})
+	}
+}
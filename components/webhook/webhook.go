@@ -0,0 +1,463 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys set on the messages published to Endpoint.DeadLetterTopic.
+const (
+	EndpointMetadataKey   = "_watermill_webhook_endpoint"
+	URLMetadataKey        = "_watermill_webhook_url"
+	ErrorMetadataKey      = "_watermill_webhook_error"
+	AttemptsMetadataKey   = "_watermill_webhook_attempts"
+	StatusCodeMetadataKey = "_watermill_webhook_status_code"
+)
+
+// RetryPolicy configures retrying the failed deliveries to the endpoint with exponential backoff.
+//
+// Network errors and responses with 408, 429, and 5xx status codes are retried.
+// Other responses outside the 2xx range are not retried, as the request would be rejected again.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries (not counting the first attempt).
+	// If 0, 5 is used. Set to -1 to disable retries.
+	MaxRetries int
+
+	// InitialInterval is the delay before the first retry.
+	// If 0, one second is used.
+	InitialInterval time.Duration
+
+	// MaxInterval is the maximum delay between the retries.
+	// If 0, one minute is used.
+	MaxInterval time.Duration
+
+	// Multiplier is the factor by which the delay grows after every retry.
+	// If 0, 2 is used.
+	Multiplier float64
+}
+
+func (p *RetryPolicy) setDefaults() {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = 5
+	}
+	if p.InitialInterval == 0 {
+		p.InitialInterval = time.Second
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = time.Minute
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = 2
+	}
+}
+
+// Validate returns the policy's error, if any.
+func (p RetryPolicy) Validate() error {
+	var err error
+
+	if p.MaxRetries < -1 {
+		err = multierror.Append(err, errors.New("Retry.MaxRetries must be -1 or greater"))
+	}
+	if p.InitialInterval < 0 {
+		err = multierror.Append(err, errors.New("Retry.InitialInterval must not be negative"))
+	}
+	if p.MaxInterval < 0 {
+		err = multierror.Append(err, errors.New("Retry.MaxInterval must not be negative"))
+	}
+	if p.Multiplier < 0 {
+		err = multierror.Append(err, errors.New("Retry.Multiplier must not be negative"))
+	}
+
+	return err
+}
+
+// delay returns the delay before the retry, starting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := float64(p.InitialInterval)
+	for i := 1; i < retry && delay < float64(p.MaxInterval); i++ {
+		delay *= p.Multiplier
+	}
+
+	if delay > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+
+	return time.Duration(delay)
+}
+
+// Endpoint is an HTTP endpoint to which the messages from Topic are delivered.
+type Endpoint struct {
+	// Name identifies the endpoint in logs, handler names, and the dead letter metadata.
+	// It must be unique.
+	Name string
+
+	// Topic from which the messages are delivered to the endpoint.
+	// Many endpoints can subscribe to the same topic.
+	Topic string
+
+	// URL to which the message payloads are POSTed.
+	URL string
+
+	// Secret is used to sign the requests (see Sign). If empty, the requests are not signed.
+	Secret []byte
+
+	// Headers are added to every request.
+	Headers http.Header
+
+	// ContentType of the payloads. If empty, "application/json" is used.
+	ContentType string
+
+	// Timeout of a single request. If 0, 10 seconds is used.
+	Timeout time.Duration
+
+	// Retry configures retrying the failed deliveries.
+	Retry RetryPolicy
+
+	// RateLimit is the maximum number of requests per second sent to the endpoint, including retries.
+	// Disabled if 0.
+	RateLimit float64
+
+	// DeadLetterTopic is the topic to which the messages are published with Config.Publisher
+	// when the delivery fails after all retries. The messages are then acked.
+	//
+	// If empty, the messages are nacked, so they are redelivered by the subscriber.
+	DeadLetterTopic string
+}
+
+func (e *Endpoint) setDefaults() {
+	if e.ContentType == "" {
+		e.ContentType = "application/json"
+	}
+	if e.Timeout == 0 {
+		e.Timeout = time.Second * 10
+	}
+	e.Retry.setDefaults()
+}
+
+// Validate returns the endpoint's error, if any.
+func (e Endpoint) Validate() error {
+	var err error
+
+	if e.Name == "" {
+		err = multierror.Append(err, errors.New("missing Name"))
+	}
+	if e.Topic == "" {
+		err = multierror.Append(err, errors.New("missing Topic"))
+	}
+	if e.URL == "" {
+		err = multierror.Append(err, errors.New("missing URL"))
+	}
+	if e.Timeout < 0 {
+		err = multierror.Append(err, errors.New("Timeout must not be negative"))
+	}
+	if e.RateLimit < 0 {
+		err = multierror.Append(err, errors.New("RateLimit must not be negative"))
+	}
+	if retryErr := e.Retry.Validate(); retryErr != nil {
+		err = multierror.Append(err, retryErr)
+	}
+
+	return err
+}
+
+// SubscriberConstructorParams are passed to Config.SubscriberConstructor.
+type SubscriberConstructorParams struct {
+	Endpoint Endpoint
+}
+
+// SubscriberConstructorFn creates a subscriber for the endpoint.
+//
+// Every endpoint should have its own consumer group (if supported by the Pub/Sub),
+// so every endpoint receives all messages from the topic.
+type SubscriberConstructorFn func(params SubscriberConstructorParams) (message.Subscriber, error)
+
+type Config struct {
+	// Endpoints to which the messages are delivered.
+	Endpoints []Endpoint
+
+	// SubscriberConstructor is used to create a subscriber for every endpoint.
+	SubscriberConstructor SubscriberConstructorFn
+
+	// Publisher is used to publish the messages to Endpoint.DeadLetterTopic.
+	// It's required if any endpoint has DeadLetterTopic set.
+	Publisher message.Publisher
+
+	// HTTPClient is used to send the requests.
+	// If not provided, a new http.Client is used.
+	HTTPClient *http.Client
+
+	// Router is a router used by the dispatcher.
+	// If not provided, a new router will be created.
+	//
+	// If router is provided, it's not necessary to call `Dispatcher.Run()` if the router is started with `router.Run()`.
+	Router *message.Router
+
+	// Clock is used to wait between retries, for rate limiting, and for the signature timestamps.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (c *Config) setDefaults() {
+	for i := range c.Endpoints {
+		c.Endpoints[i].setDefaults()
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{}
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c Config) Validate() error {
+	if len(c.Endpoints) == 0 {
+		return errors.New("endpoints must not be empty")
+	}
+
+	var err error
+
+	if c.SubscriberConstructor == nil {
+		err = multierror.Append(err, errors.New("missing SubscriberConstructor"))
+	}
+
+	names := map[string]struct{}{}
+	for _, endpoint := range c.Endpoints {
+		if endpointErr := endpoint.Validate(); endpointErr != nil {
+			err = multierror.Append(err, errors.Wrapf(endpointErr, "invalid endpoint %s", endpoint.Name))
+		}
+		if endpoint.DeadLetterTopic != "" && c.Publisher == nil {
+			err = multierror.Append(err, errors.Errorf("Publisher is required for DeadLetterTopic of endpoint %s", endpoint.Name))
+		}
+
+		if _, ok := names[endpoint.Name]; ok {
+			err = multierror.Append(err, errors.Errorf("duplicated endpoint name %s", endpoint.Name))
+		}
+		names[endpoint.Name] = struct{}{}
+	}
+
+	return err
+}
+
+// Dispatcher delivers the messages from the topics to the HTTP endpoints as webhooks.
+//
+// Every message is POSTed to the endpoint with its payload as the body, and with IDHeader, TimestampHeader,
+// and SignatureHeader headers. Failed deliveries are retried with exponential backoff.
+// When all retries fail, the message is published to the endpoint's dead letter topic or nacked.
+//
+// Every endpoint is handled by its own router handler, so slow or failing endpoints don't block the others.
+type Dispatcher struct {
+	router *message.Router
+	config Config
+	logger watermill.LoggerAdapter
+}
+
+// NewDispatcher creates a new Dispatcher.
+func NewDispatcher(config Config, logger watermill.LoggerAdapter) (*Dispatcher, error) {
+	endpoints := make([]Endpoint, len(config.Endpoints))
+	copy(endpoints, config.Endpoints)
+	config.Endpoints = endpoints
+
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	router := config.Router
+	if router == nil {
+		var err error
+		router, err = message.NewRouter(message.RouterConfig{}, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot create a router")
+		}
+	}
+
+	d := &Dispatcher{
+		router: router,
+		config: config,
+		logger: logger,
+	}
+
+	for _, endpoint := range config.Endpoints {
+		subscriber, err := config.SubscriberConstructor(SubscriberConstructorParams{Endpoint: endpoint})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot create subscriber for endpoint %s", endpoint.Name)
+		}
+
+		router.AddNoPublisherHandler(
+			"webhook_"+endpoint.Name,
+			endpoint.Topic,
+			subscriber,
+			d.newEndpointHandler(endpoint),
+		)
+	}
+
+	return d, nil
+}
+
+// Run runs the dispatcher's handlers.
+// This call is blocking while the dispatcher is running.
+//
+// To stop Run() you should call Close() on the dispatcher.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	return d.router.Run(ctx)
+}
+
+// Close stops the dispatcher's handlers.
+func (d *Dispatcher) Close() error {
+	return d.router.Close()
+}
+
+// Running returns channel which is closed when the dispatcher is running.
+func (d *Dispatcher) Running() chan struct{} {
+	return d.router.Running()
+}
+
+// deliveryError is the error of a single delivery attempt.
+type deliveryError struct {
+	err        error
+	statusCode int
+	retryable  bool
+}
+
+func (e *deliveryError) Error() string {
+	return e.err.Error()
+}
+
+func (d *Dispatcher) newEndpointHandler(endpoint Endpoint) message.NoPublishHandlerFunc {
+	var limiter *rateLimiter
+	if endpoint.RateLimit > 0 {
+		limiter = newRateLimiter(endpoint.RateLimit, d.config.Clock)
+	}
+
+	logger := d.logger.With(watermill.LogFields{
+		"webhook_endpoint": endpoint.Name,
+		"webhook_url":      endpoint.URL,
+	})
+
+	return func(msg *message.Message) error {
+		ctx := msg.Context()
+		logFields := watermill.LogFields{"message_uuid": msg.UUID}
+
+		attempts := 0
+		for {
+			if limiter != nil {
+				if err := limiter.Wait(ctx); err != nil {
+					return err
+				}
+			}
+
+			attempts++
+			deliveryErr := d.deliver(ctx, endpoint, msg)
+			if deliveryErr == nil {
+				logger.Trace("Webhook delivered", logFields)
+				return nil
+			}
+
+			retriesLeft := endpoint.Retry.MaxRetries - (attempts - 1)
+			if !deliveryErr.retryable || retriesLeft <= 0 {
+				logger.Error("Webhook delivery failed", deliveryErr, logFields.Add(watermill.LogFields{
+					"attempts": attempts,
+				}))
+				return d.handleFailed(endpoint, msg, deliveryErr, attempts)
+			}
+
+			delay := endpoint.Retry.delay(attempts)
+			logger.Info("Webhook delivery failed, retrying", logFields.Add(watermill.LogFields{
+				"attempts":     attempts,
+				"err":          deliveryErr,
+				"retry_delay":  delay,
+				"retries_left": retriesLeft,
+			}))
+
+			select {
+			case <-d.config.Clock.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, endpoint Endpoint, msg *message.Message) *deliveryError {
+	ctx, cancel := context.WithTimeout(ctx, endpoint.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return &deliveryError{err: errors.Wrap(err, "cannot create request")}
+	}
+
+	for key, values := range endpoint.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	timestamp := d.config.Clock.Now()
+	req.Header.Set("Content-Type", endpoint.ContentType)
+	req.Header.Set(IDHeader, msg.UUID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	if len(endpoint.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, msg.UUID, timestamp, msg.Payload))
+	}
+
+	resp, err := d.config.HTTPClient.Do(req)
+	if err != nil {
+		return &deliveryError{err: errors.Wrap(err, "cannot send request"), retryable: true}
+	}
+	defer resp.Body.Close()
+
+	// the body is drained, so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return &deliveryError{
+		err:        errors.Errorf("unexpected response status %d", resp.StatusCode),
+		statusCode: resp.StatusCode,
+		retryable:  isRetryableStatus(resp.StatusCode),
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
+
+func (d *Dispatcher) handleFailed(endpoint Endpoint, msg *message.Message, deliveryErr *deliveryError, attempts int) error {
+	if endpoint.DeadLetterTopic == "" {
+		return errors.Wrapf(deliveryErr, "webhook delivery to endpoint %s failed", endpoint.Name)
+	}
+
+	deadLetter := msg.Copy()
+	deadLetter.Metadata.Set(EndpointMetadataKey, endpoint.Name)
+	deadLetter.Metadata.Set(URLMetadataKey, endpoint.URL)
+	deadLetter.Metadata.Set(ErrorMetadataKey, deliveryErr.Error())
+	deadLetter.Metadata.Set(AttemptsMetadataKey, strconv.Itoa(attempts))
+	if deliveryErr.statusCode != 0 {
+		deadLetter.Metadata.Set(StatusCodeMetadataKey, strconv.Itoa(deliveryErr.statusCode))
+	}
+
+	if err := d.config.Publisher.Publish(endpoint.DeadLetterTopic, deadLetter); err != nil {
+		return errors.Wrapf(err, "cannot publish message to dead letter topic %s", endpoint.DeadLetterTopic)
+	}
+
+	return nil
+}
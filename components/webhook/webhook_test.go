@@ -0,0 +1,338 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/webhook"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type receivedRequest struct {
+	header  http.Header
+	payload []byte
+}
+
+type testServer struct {
+	*httptest.Server
+
+	statusCodes []int
+
+	requests []receivedRequest
+	lock     sync.Mutex
+}
+
+// newTestServer returns a server responding with the statusCodes, and with 200 after they are used up.
+func newTestServer(t *testing.T, statusCodes ...int) *testServer {
+	s := &testServer{statusCodes: statusCodes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		s.lock.Lock()
+		s.requests = append(s.requests, receivedRequest{header: r.Header, payload: payload})
+		statusCode := http.StatusOK
+		if len(s.statusCodes) > 0 {
+			statusCode = s.statusCodes[0]
+			s.statusCodes = s.statusCodes[1:]
+		}
+		s.lock.Unlock()
+
+		w.WriteHeader(statusCode)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testServer) Requests() []receivedRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]receivedRequest(nil), s.requests...)
+}
+
+func runDispatcher(t *testing.T, pubSub *gochannel.GoChannel, config webhook.Config) {
+	t.Helper()
+
+	config.SubscriberConstructor = func(params webhook.SubscriberConstructorParams) (message.Subscriber, error) {
+		return pubSub, nil
+	}
+
+	dispatcher, err := webhook.NewDispatcher(config, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, dispatcher.Run(context.Background()))
+	}()
+	<-dispatcher.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, dispatcher.Close())
+	})
+}
+
+func fastRetry() webhook.RetryPolicy {
+	return webhook.RetryPolicy{
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond * 5,
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	orders := newTestServer(t)
+	audit := newTestServer(t)
+
+	secret := []byte("secret")
+
+	runDispatcher(t, pubSub, webhook.Config{
+		Endpoints: []webhook.Endpoint{
+			{
+				Name:    "orders",
+				Topic:   "orders",
+				URL:     orders.URL,
+				Secret:  secret,
+				Headers: http.Header{"X-Tenant": []string{"tenant-1"}},
+			},
+			{
+				Name:  "audit",
+				Topic: "orders",
+				URL:   audit.URL,
+			},
+		},
+	})
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte(`{"id":"1"}`))
+	require.NoError(t, pubSub.Publish("orders", msg))
+
+	require.Eventually(t, func() bool {
+		return len(orders.Requests()) == 1 && len(audit.Requests()) == 1
+	}, time.Second, time.Millisecond*10)
+
+	req := orders.Requests()[0]
+	assert.Equal(t, `{"id":"1"}`, string(req.payload))
+	assert.Equal(t, msg.UUID, req.header.Get(webhook.IDHeader))
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, "tenant-1", req.header.Get("X-Tenant"))
+	assert.True(t, webhook.Verify(
+		secret,
+		req.header.Get(webhook.IDHeader),
+		req.header.Get(webhook.TimestampHeader),
+		req.payload,
+		req.header.Get(webhook.SignatureHeader),
+		time.Minute,
+		time.Now(),
+	))
+
+	assert.Empty(t, audit.Requests()[0].header.Get(webhook.SignatureHeader), "endpoint without secret should not sign")
+}
+
+func TestDispatcher_retry(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	server := newTestServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	runDispatcher(t, pubSub, webhook.Config{
+		Endpoints: []webhook.Endpoint{
+			{
+				Name:  "endpoint",
+				Topic: "topic",
+				URL:   server.URL,
+				Retry: fastRetry(),
+			},
+		},
+	})
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+	require.NoError(t, pubSub.Publish("topic", msg))
+
+	require.Eventually(t, func() bool {
+		return len(server.Requests()) == 3
+	}, time.Second, time.Millisecond*10)
+
+	for _, req := range server.Requests() {
+		assert.Equal(t, msg.UUID, req.header.Get(webhook.IDHeader), "all attempts should have the same ID")
+	}
+}
+
+func TestDispatcher_dead_letter(t *testing.T) {
+	testCases := []struct {
+		Name               string
+		StatusCodes        []int
+		ExpectedAttempts   string
+		ExpectedStatusCode string
+	}{
+		{
+			Name:               "not_retryable",
+			StatusCodes:        []int{http.StatusBadRequest},
+			ExpectedAttempts:   "1",
+			ExpectedStatusCode: "400",
+		},
+		{
+			Name:               "retries_exhausted",
+			StatusCodes:        []int{500, 500, 500, 500, 500},
+			ExpectedAttempts:   "4",
+			ExpectedStatusCode: "500",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+			server := newTestServer(t, tc.StatusCodes...)
+
+			deadLetters, err := pubSub.Subscribe(context.Background(), "dead_letters")
+			require.NoError(t, err)
+
+			runDispatcher(t, pubSub, webhook.Config{
+				Endpoints: []webhook.Endpoint{
+					{
+						Name:            "endpoint",
+						Topic:           "topic",
+						URL:             server.URL,
+						Retry:           fastRetry(),
+						DeadLetterTopic: "dead_letters",
+					},
+				},
+				Publisher: pubSub,
+			})
+
+			msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+			require.NoError(t, pubSub.Publish("topic", msg))
+
+			select {
+			case deadLetter := <-deadLetters:
+				deadLetter.Ack()
+
+				assert.Equal(t, msg.UUID, deadLetter.UUID)
+				assert.Equal(t, "payload", string(deadLetter.Payload))
+				assert.Equal(t, "endpoint", deadLetter.Metadata.Get(webhook.EndpointMetadataKey))
+				assert.Equal(t, server.URL, deadLetter.Metadata.Get(webhook.URLMetadataKey))
+				assert.Equal(t, tc.ExpectedAttempts, deadLetter.Metadata.Get(webhook.AttemptsMetadataKey))
+				assert.Equal(t, tc.ExpectedStatusCode, deadLetter.Metadata.Get(webhook.StatusCodeMetadataKey))
+				assert.NotEmpty(t, deadLetter.Metadata.Get(webhook.ErrorMetadataKey))
+			case <-time.After(time.Second):
+				t.Fatal("dead letter not received")
+			}
+		})
+	}
+}
+
+func TestDispatcher_nack_without_dead_letter_topic(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	server := newTestServer(t, http.StatusBadRequest)
+
+	runDispatcher(t, pubSub, webhook.Config{
+		Endpoints: []webhook.Endpoint{
+			{
+				Name:  "endpoint",
+				Topic: "topic",
+				URL:   server.URL,
+				Retry: webhook.RetryPolicy{MaxRetries: -1},
+			},
+		},
+	})
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage(watermill.NewUUID(), []byte("payload"))))
+
+	// the nacked message is redelivered by the subscriber, and the second delivery succeeds
+	require.Eventually(t, func() bool {
+		return len(server.Requests()) == 2
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestDispatcher_rate_limit(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+
+	runDispatcher(t, pubSub, webhook.Config{
+		Endpoints: []webhook.Endpoint{
+			{
+				Name:      "endpoint",
+				Topic:     "topic",
+				URL:       server.URL,
+				RateLimit: 20,
+			},
+		},
+	})
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pubSub.Publish("topic", message.NewMessage(watermill.NewUUID(), nil)))
+	}
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&requests) == 5
+	}, time.Second*2, time.Millisecond*5)
+
+	// the first request is sent immediately, the next ones every 50ms
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*190)
+}
+
+func TestNewDispatcher_invalid_config(t *testing.T) {
+	subscriberConstructor := func(params webhook.SubscriberConstructorParams) (message.Subscriber, error) {
+		return nil, nil
+	}
+
+	testCases := []struct {
+		Name   string
+		Config webhook.Config
+	}{
+		{
+			Name:   "no_endpoints",
+			Config: webhook.Config{SubscriberConstructor: subscriberConstructor},
+		},
+		{
+			Name: "missing_url",
+			Config: webhook.Config{
+				Endpoints:             []webhook.Endpoint{{Name: "endpoint", Topic: "topic"}},
+				SubscriberConstructor: subscriberConstructor,
+			},
+		},
+		{
+			Name: "duplicated_name",
+			Config: webhook.Config{
+				Endpoints: []webhook.Endpoint{
+					{Name: "endpoint", Topic: "topic", URL: "http://localhost"},
+					{Name: "endpoint", Topic: "topic", URL: "http://localhost"},
+				},
+				SubscriberConstructor: subscriberConstructor,
+			},
+		},
+		{
+			Name: "dead_letter_topic_without_publisher",
+			Config: webhook.Config{
+				Endpoints: []webhook.Endpoint{
+					{Name: "endpoint", Topic: "topic", URL: "http://localhost", DeadLetterTopic: "dead_letters"},
+				},
+				SubscriberConstructor: subscriberConstructor,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := webhook.NewDispatcher(tc.Config, watermill.NopLogger{})
+			assert.Error(t, err)
+		})
+	}
+}
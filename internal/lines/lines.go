@@ -0,0 +1,45 @@
+// Package lines provides helpers for files storing newline-terminated records.
+package lines
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// TruncatePartial truncates the file after the last newline-terminated record, and returns the file's size.
+// It removes a partial record left by a process killed while appending to the file.
+func TruncatePartial(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	size := info.Size()
+	buf := make([]byte, 4096)
+
+	for end := size; end > 0; {
+		start := max(end-int64(len(buf)), 0)
+
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			end = start + int64(i) + 1
+			if end == size {
+				return size, nil
+			}
+			return end, f.Truncate(end)
+		}
+
+		end = start
+	}
+
+	if size == 0 {
+		return 0, nil
+	}
+
+	return 0, f.Truncate(0)
+}
@@ -0,0 +1,74 @@
+package lines_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/internal/lines"
+)
+
+func TestTruncatePartial(t *testing.T) {
+	longRecord := strings.Repeat("a", 10000) + "\n"
+
+	testCases := []struct {
+		Name     string
+		Content  string
+		Expected string
+	}{
+		{
+			Name:     "empty",
+			Content:  "",
+			Expected: "",
+		},
+		{
+			Name:     "complete_records",
+			Content:  "1\n2\n",
+			Expected: "1\n2\n",
+		},
+		{
+			Name:     "partial_record",
+			Content:  "1\n2\n{\"uuid\":\"3",
+			Expected: "1\n2\n",
+		},
+		{
+			Name:     "only_partial_record",
+			Content:  "{\"uuid\":\"1",
+			Expected: "",
+		},
+		{
+			Name:     "partial_record_longer_than_buffer",
+			Content:  "1\n" + strings.Repeat("b", 10000),
+			Expected: "1\n",
+		},
+		{
+			Name:     "record_longer_than_buffer",
+			Content:  longRecord + "partial",
+			Expected: longRecord,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file")
+			require.NoError(t, os.WriteFile(path, []byte(tc.Content), 0o600))
+
+			f, err := os.OpenFile(path, os.O_RDWR, 0o600)
+			require.NoError(t, err)
+			defer f.Close()
+
+			size, err := lines.TruncatePartial(f)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(tc.Expected), size)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, string(content))
+		})
+	}
+}
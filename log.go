@@ -172,17 +172,20 @@ type CapturedMessage struct {
 type CaptureLoggerAdapter struct {
 	captured map[LogLevel][]CapturedMessage
 	fields   LogFields
-	lock     sync.Mutex
+
+	// lock is shared with the loggers created with With, as they share captured
+	lock *sync.Mutex
 }
 
 func NewCaptureLogger() *CaptureLoggerAdapter {
 	return &CaptureLoggerAdapter{
 		captured: map[LogLevel][]CapturedMessage{},
+		lock:     &sync.Mutex{},
 	}
 }
 
 func (c *CaptureLoggerAdapter) With(fields LogFields) LoggerAdapter {
-	return &CaptureLoggerAdapter{captured: c.captured, fields: c.fields.Add(fields)}
+	return &CaptureLoggerAdapter{captured: c.captured, fields: c.fields.Add(fields), lock: c.lock}
 }
 
 func (c *CaptureLoggerAdapter) capture(msg CapturedMessage) {
@@ -0,0 +1,32 @@
+package watermill
+
+import (
+	"context"
+)
+
+type loggerCtxKey struct{}
+
+// ContextWithLogger returns a new context with the logger attached.
+// The router attaches a logger scoped to the handled message to the message's context.
+func ContextWithLogger(ctx context.Context, logger LoggerAdapter) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, logger)
+}
+
+// LoggerFromCtx returns the logger attached to the context with ContextWithLogger.
+// If there is no logger in the context, NopLogger is returned.
+//
+// In the router's handlers, it returns the router's logger with the handler name, the message UUID,
+// and the correlation ID (if the message has it) added to the fields:
+//
+//	func(msg *message.Message) error {
+//		watermill.LoggerFromCtx(msg.Context()).Info("Handling order", nil)
+//		// ...
+//	}
+func LoggerFromCtx(ctx context.Context) LoggerAdapter {
+	logger, ok := ctx.Value(loggerCtxKey{}).(LoggerAdapter)
+	if !ok {
+		return NopLogger{}
+	}
+
+	return logger
+}
@@ -0,0 +1,21 @@
+package watermill_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+func TestLoggerFromCtx(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+
+	ctx := watermill.ContextWithLogger(context.Background(), logger)
+	assert.Equal(t, logger, watermill.LoggerFromCtx(ctx))
+}
+
+func TestLoggerFromCtx_no_logger(t *testing.T) {
+	assert.Equal(t, watermill.NopLogger{}, watermill.LoggerFromCtx(context.Background()))
+}
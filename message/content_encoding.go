@@ -0,0 +1,200 @@
+package message
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ContentEncodingMetadataKey is the metadata key of the encoding of the message's payload.
+// If it's not set, the payload is not encoded (ContentEncodingIdentity).
+const ContentEncodingMetadataKey = "_watermill_content_encoding"
+
+// Content encodings known by Watermill.
+//
+// ContentEncodingIdentity and ContentEncodingGzip are registered in every ContentEncodings by default.
+// ContentEncodingZstd must be registered with ContentEncodings.Register, as it requires an external library.
+const (
+	ContentEncodingIdentity = "identity"
+	ContentEncodingGzip     = "gzip"
+	ContentEncodingZstd     = "zstd"
+)
+
+// SetContentEncoding sets the encoding of the message's payload, without encoding it.
+// Use ContentEncodings.Encode to encode the payload.
+func SetContentEncoding(msg *Message, encoding string) {
+	if encoding == "" || encoding == ContentEncodingIdentity {
+		delete(msg.Metadata, ContentEncodingMetadataKey)
+		return
+	}
+
+	msg.Metadata.Set(ContentEncodingMetadataKey, encoding)
+}
+
+// ContentEncoding returns the encoding of the message's payload.
+// It returns ContentEncodingIdentity if the encoding is not set.
+func ContentEncoding(msg *Message) string {
+	encoding := msg.Metadata.Get(ContentEncodingMetadataKey)
+	if encoding == "" {
+		return ContentEncodingIdentity
+	}
+
+	return encoding
+}
+
+// RequireIdentityEncoding returns UnsupportedContentEncodingError if the message's payload is encoded.
+// It's used by consumers which can't decode payloads, so they don't try to interpret encoded bytes.
+func RequireIdentityEncoding(msg *Message) error {
+	if encoding := ContentEncoding(msg); encoding != ContentEncodingIdentity {
+		return UnsupportedContentEncodingError{Encoding: encoding}
+	}
+
+	return nil
+}
+
+// UnsupportedContentEncodingError is returned when the payload's encoding is not registered
+// in ContentEncodings, or the consumer can't decode payloads at all.
+type UnsupportedContentEncodingError struct {
+	Encoding string
+}
+
+func (e UnsupportedContentEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q", e.Encoding)
+}
+
+// ContentEncoder encodes and decodes payloads.
+type ContentEncoder interface {
+	Encode(payload []byte) ([]byte, error)
+	Decode(payload []byte) ([]byte, error)
+}
+
+// ContentEncodings is a registry of content encodings, used to encode payloads on the producer side
+// and to decode them on the consumer side, based on ContentEncodingMetadataKey.
+//
+// It's safe for concurrent use.
+type ContentEncodings struct {
+	encoders map[string]ContentEncoder
+	lock     sync.RWMutex
+}
+
+// NewContentEncodings creates ContentEncodings with ContentEncodingIdentity and ContentEncodingGzip registered.
+func NewContentEncodings() *ContentEncodings {
+	return &ContentEncodings{
+		encoders: map[string]ContentEncoder{
+			ContentEncodingIdentity: identityEncoder{},
+			ContentEncodingGzip:     gzipEncoder{},
+		},
+	}
+}
+
+// DefaultContentEncodings are the content encodings used when no ContentEncodings is provided.
+var DefaultContentEncodings = NewContentEncodings()
+
+// Register registers the encoder of the encoding, replacing the existing one.
+func (e *ContentEncodings) Register(encoding string, encoder ContentEncoder) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.encoders[encoding] = encoder
+}
+
+// Supports returns true if the encoding is registered.
+func (e *ContentEncodings) Supports(encoding string) bool {
+	_, ok := e.encoder(encoding)
+	return ok
+}
+
+func (e *ContentEncodings) encoder(encoding string) (ContentEncoder, bool) {
+	if encoding == "" {
+		encoding = ContentEncodingIdentity
+	}
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	encoder, ok := e.encoders[encoding]
+	return encoder, ok
+}
+
+// Encode encodes the message's payload and sets ContentEncodingMetadataKey.
+// It returns an error if the payload is already encoded.
+func (e *ContentEncodings) Encode(msg *Message, encoding string) error {
+	if current := ContentEncoding(msg); current != ContentEncodingIdentity {
+		return errors.Errorf("payload is already encoded with %q", current)
+	}
+
+	encoder, ok := e.encoder(encoding)
+	if !ok {
+		return UnsupportedContentEncodingError{Encoding: encoding}
+	}
+
+	payload, err := encoder.Encode(msg.Payload)
+	if err != nil {
+		return errors.Wrapf(err, "cannot encode payload with %q", encoding)
+	}
+
+	msg.Payload = payload
+	SetContentEncoding(msg, encoding)
+
+	return nil
+}
+
+// DecodedPayload returns the message's payload decoded according to ContentEncodingMetadataKey.
+// The message is not modified.
+//
+// It returns UnsupportedContentEncodingError if the encoding is not registered.
+func (e *ContentEncodings) DecodedPayload(msg *Message) (Payload, error) {
+	encoding := ContentEncoding(msg)
+
+	encoder, ok := e.encoder(encoding)
+	if !ok {
+		return nil, UnsupportedContentEncodingError{Encoding: encoding}
+	}
+
+	payload, err := encoder.Decode(msg.Payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot decode payload with %q", encoding)
+	}
+
+	return payload, nil
+}
+
+type identityEncoder struct{}
+
+func (identityEncoder) Encode(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+func (identityEncoder) Decode(payload []byte) ([]byte, error) {
+	return payload, nil
+}
+
+type gzipEncoder struct{}
+
+func (gzipEncoder) Encode(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gzipEncoder) Decode(payload []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,91 @@
+package message_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type reverseEncoder struct{}
+
+func reverse(payload []byte) []byte {
+	reversed := make([]byte, len(payload))
+	for i, b := range payload {
+		reversed[len(payload)-1-i] = b
+	}
+	return reversed
+}
+
+func (reverseEncoder) Encode(payload []byte) ([]byte, error) {
+	return reverse(payload), nil
+}
+
+func (reverseEncoder) Decode(payload []byte) ([]byte, error) {
+	return reverse(payload), nil
+}
+
+func TestContentEncodings_gzip(t *testing.T) {
+	encodings := message.NewContentEncodings()
+
+	payload := bytes.Repeat([]byte("payload"), 100)
+	msg := message.NewMessage("1", payload)
+
+	require.NoError(t, encodings.Encode(msg, message.ContentEncodingGzip))
+	assert.Equal(t, message.ContentEncodingGzip, message.ContentEncoding(msg))
+	assert.Less(t, len(msg.Payload), len(payload))
+
+	decoded, err := encodings.DecodedPayload(msg)
+	require.NoError(t, err)
+	assert.Equal(t, message.Payload(payload), decoded)
+
+	// encoding twice is a mistake
+	assert.ErrorContains(t, encodings.Encode(msg, message.ContentEncodingGzip), `payload is already encoded with "gzip"`)
+}
+
+func TestContentEncodings_identity(t *testing.T) {
+	encodings := message.NewContentEncodings()
+
+	msg := message.NewMessage("1", []byte("payload"))
+	assert.Equal(t, message.ContentEncodingIdentity, message.ContentEncoding(msg))
+	assert.NoError(t, message.RequireIdentityEncoding(msg))
+
+	require.NoError(t, encodings.Encode(msg, message.ContentEncodingIdentity))
+	assert.Empty(t, msg.Metadata.Get(message.ContentEncodingMetadataKey))
+
+	decoded, err := encodings.DecodedPayload(msg)
+	require.NoError(t, err)
+	assert.Equal(t, message.Payload("payload"), decoded)
+}
+
+func TestContentEncodings_unsupported(t *testing.T) {
+	encodings := message.NewContentEncodings()
+	assert.False(t, encodings.Supports(message.ContentEncodingZstd))
+
+	msg := message.NewMessage("1", []byte("payload"))
+	err := encodings.Encode(msg, message.ContentEncodingZstd)
+	assert.Equal(t, message.UnsupportedContentEncodingError{Encoding: message.ContentEncodingZstd}, err)
+
+	message.SetContentEncoding(msg, message.ContentEncodingZstd)
+
+	_, err = encodings.DecodedPayload(msg)
+	assert.EqualError(t, err, `unsupported content encoding "zstd"`)
+	assert.ErrorAs(t, message.RequireIdentityEncoding(msg), &message.UnsupportedContentEncodingError{})
+}
+
+func TestContentEncodings_Register(t *testing.T) {
+	encodings := message.NewContentEncodings()
+	encodings.Register("reverse", reverseEncoder{})
+	assert.True(t, encodings.Supports("reverse"))
+
+	msg := message.NewMessage("1", []byte("payload"))
+	require.NoError(t, encodings.Encode(msg, "reverse"))
+	assert.Equal(t, message.Payload("daolyap"), msg.Payload)
+
+	decoded, err := encodings.DecodedPayload(msg)
+	require.NoError(t, err)
+	assert.Equal(t, message.Payload("payload"), decoded)
+}
@@ -11,6 +11,14 @@ func MessageTransformSubscriberDecorator(transform func(*Message)) SubscriberDec
 	if transform == nil {
 		panic("transform function is nil")
 	}
+	return messageTopicTransformSubscriberDecorator(func(msg *Message, topic string) {
+		transform(msg)
+	})
+}
+
+// messageTopicTransformSubscriberDecorator works like MessageTransformSubscriberDecorator,
+// but passes the subscribed topic to transform.
+func messageTopicTransformSubscriberDecorator(transform func(msg *Message, topic string)) SubscriberDecorator {
 	return func(sub Subscriber) (Subscriber, error) {
 		return &messageTransformSubscriberDecorator{
 			sub:       sub,
@@ -36,7 +44,7 @@ func MessageTransformPublisherDecorator(transform func(*Message)) PublisherDecor
 type messageTransformSubscriberDecorator struct {
 	sub Subscriber
 
-	transform   func(*Message)
+	transform   func(msg *Message, topic string)
 	subscribeWg sync.WaitGroup
 }
 
@@ -50,7 +58,7 @@ func (t *messageTransformSubscriberDecorator) Subscribe(ctx context.Context, top
 	t.subscribeWg.Add(1)
 	go func() {
 		for msg := range in {
-			t.transform(msg)
+			t.transform(msg, topic)
 			out <- msg
 		}
 		close(out)
@@ -60,6 +68,10 @@ func (t *messageTransformSubscriberDecorator) Subscribe(ctx context.Context, top
 	return out, nil
 }
 
+func (t *messageTransformSubscriberDecorator) Unwrap() Subscriber {
+	return t.sub
+}
+
 func (t *messageTransformSubscriberDecorator) Close() error {
 	err := t.sub.Close()
 
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"context"
 	"sync"
+	"sync/atomic"
+
+	"github.com/ThreeDotsLabs/watermill"
 )
 
 var closedchan = make(chan struct{})
@@ -43,7 +46,18 @@ type Message struct {
 	ackMutex    sync.Mutex
 	ackSentType ackType
 
+	// ackDelegate is set for copies created by CopyOnWrite.
+	// Acks and nacks of such copies are propagated to ackDelegate.
+	ackDelegate *Message
+
+	// frozen is a copy-on-write hint set with Freeze, it may be read concurrently
+	frozen atomic.Bool
+
 	ctx context.Context
+
+	// locals are the in-process values set with SetLocal, allocated on first use
+	locals     *localValues
+	localsLock sync.Mutex
 }
 
 // NewMessage creates a new Message with given uuid and payload.
@@ -87,6 +101,10 @@ func (m *Message) Equals(toCompare *Message) bool {
 // Ack is idempotent.
 // False is returned, if Nack is already sent.
 func (m *Message) Ack() bool {
+	if m.ackDelegate != nil {
+		return m.ackDelegate.Ack()
+	}
+
 	m.ackMutex.Lock()
 	defer m.ackMutex.Unlock()
 
@@ -113,6 +131,10 @@ func (m *Message) Ack() bool {
 // Nack is idempotent.
 // False is returned, if Ack is already sent.
 func (m *Message) Nack() bool {
+	if m.ackDelegate != nil {
+		return m.ackDelegate.Nack()
+	}
+
 	m.ackMutex.Lock()
 	defer m.ackMutex.Unlock()
 
@@ -145,6 +167,9 @@ func (m *Message) Nack() bool {
 //		// nack received
 //	}
 func (m *Message) Acked() <-chan struct{} {
+	if m.ackDelegate != nil {
+		return m.ackDelegate.Acked()
+	}
 	return m.ack
 }
 
@@ -159,6 +184,9 @@ func (m *Message) Acked() <-chan struct{} {
 //		// nack received
 //	}
 func (m *Message) Nacked() <-chan struct{} {
+	if m.ackDelegate != nil {
+		return m.ackDelegate.Nacked()
+	}
 	return m.noAck
 }
 
@@ -180,7 +208,10 @@ func (m *Message) SetContext(ctx context.Context) {
 }
 
 // Copy copies all message without Acks/Nacks.
-// The context is not propagated to the copy.
+// The context and the local values are not propagated to the copy.
+//
+// The payload is shared between the message and the copy. If you need to modify the payload, use DeepCopy.
+// The copy is never frozen.
 func (m *Message) Copy() *Message {
 	msg := NewMessage(m.UUID, m.Payload)
 	for k, v := range m.Metadata {
@@ -188,3 +219,62 @@ func (m *Message) Copy() *Message {
 	}
 	return msg
 }
+
+// CopyWithNewUUID works like Copy, but the copy has a newly generated UUID.
+func (m *Message) CopyWithNewUUID() *Message {
+	msg := m.Copy()
+	msg.UUID = watermill.NewUUID()
+	return msg
+}
+
+// DeepCopy copies all message without Acks/Nacks, including the payload bytes.
+// The context and the local values are not propagated to the copy.
+//
+// Contrary to Copy, modifying the payload of the copy doesn't affect the original message.
+func (m *Message) DeepCopy() *Message {
+	msg := m.Copy()
+	if m.Payload != nil {
+		msg.Payload = append(Payload{}, m.Payload...)
+	}
+	return msg
+}
+
+// Freeze marks the message as frozen.
+//
+// Frozen is only a copy-on-write hint, the immutability is not enforced: UUID, Metadata, and Payload
+// are exported fields, and can still be modified directly.
+// It's useful when the message is shared by multiple handlers or middlewares,
+// which should modify it only after calling CopyOnWrite.
+//
+// Freeze is idempotent, and safe to call from multiple goroutines.
+func (m *Message) Freeze() {
+	m.frozen.Store(true)
+}
+
+// IsFrozen returns true if Freeze was called on the message.
+func (m *Message) IsFrozen() bool {
+	return m.frozen.Load()
+}
+
+// CopyOnWrite returns a message that is safe to modify.
+//
+// If the message is not frozen, the message itself is returned.
+// Otherwise, a not frozen deep copy of the message is returned.
+// The copy keeps the message's context and shares the local values with the original message,
+// and Ack/Nack called on the copy are propagated to the original message.
+func (m *Message) CopyOnWrite() *Message {
+	if !m.frozen.Load() {
+		return m
+	}
+
+	msg := m.DeepCopy()
+	msg.ctx = m.ctx
+	msg.locals = m.localValues()
+	if m.ackDelegate != nil {
+		msg.ackDelegate = m.ackDelegate
+	} else {
+		msg.ackDelegate = m
+	}
+
+	return msg
+}
@@ -0,0 +1,59 @@
+package message
+
+import "sync"
+
+// localValues are the in-process values of the message, shared by the message and its copy-on-write copies.
+type localValues struct {
+	values map[any]any
+	lock   sync.RWMutex
+}
+
+// SetLocal attaches an in-process value to the message, for example, a database transaction started by a middleware
+// or the payload already unmarshaled to a struct.
+//
+// Contrary to Metadata, local values are never marshaled or published, so they can hold values that can't be
+// serialized, like pointers or connections. They are visible to all middlewares and the handler receiving the message,
+// without putting them into the context or globals.
+//
+// Like context keys, keys should be of unexported types to avoid collisions between packages.
+// Setting nil removes the value. SetLocal is safe for concurrent use.
+func (m *Message) SetLocal(key, value any) {
+	locals := m.localValues()
+
+	locals.lock.Lock()
+	defer locals.lock.Unlock()
+
+	if value == nil {
+		delete(locals.values, key)
+		return
+	}
+
+	locals.values[key] = value
+}
+
+// Local returns the in-process value set with SetLocal, or nil if it's not set.
+func (m *Message) Local(key any) any {
+	m.localsLock.Lock()
+	locals := m.locals
+	m.localsLock.Unlock()
+
+	if locals == nil {
+		return nil
+	}
+
+	locals.lock.RLock()
+	defer locals.lock.RUnlock()
+
+	return locals.values[key]
+}
+
+func (m *Message) localValues() *localValues {
+	m.localsLock.Lock()
+	defer m.localsLock.Unlock()
+
+	if m.locals == nil {
+		m.locals = &localValues{values: map[any]any{}}
+	}
+
+	return m.locals
+}
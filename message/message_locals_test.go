@@ -0,0 +1,75 @@
+package message_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type localKey string
+
+func TestMessage_Local(t *testing.T) {
+	msg := message.NewMessage("1", []byte("payload"))
+	assert.Nil(t, msg.Local(localKey("tx")))
+
+	type tx struct{ ID string }
+	msg.SetLocal(localKey("tx"), &tx{ID: "1"})
+	assert.Equal(t, &tx{ID: "1"}, msg.Local(localKey("tx")))
+
+	// the same key of a different type is a different value
+	assert.Nil(t, msg.Local("tx"))
+
+	msg.SetLocal(localKey("tx"), nil)
+	assert.Nil(t, msg.Local(localKey("tx")))
+}
+
+func TestMessage_Local_not_serialized(t *testing.T) {
+	msg := message.NewMessage("1", []byte("payload"))
+	msg.Metadata.Set("key", "value")
+	msg.SetLocal(localKey("tx"), "value")
+
+	assert.Nil(t, msg.Copy().Local(localKey("tx")))
+	assert.Nil(t, msg.DeepCopy().Local(localKey("tx")))
+	assert.Equal(t, message.Metadata{"key": "value"}, msg.Metadata)
+
+	encoded, err := json.Marshal(msg)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), "tx")
+}
+
+func TestMessage_Local_copy_on_write(t *testing.T) {
+	msg := message.NewMessage("1", []byte("payload"))
+	msg.SetLocal(localKey("before"), 1)
+	msg.Freeze()
+
+	copied := msg.CopyOnWrite()
+	require.NotSame(t, msg, copied)
+	assert.Equal(t, 1, copied.Local(localKey("before")))
+
+	copied.SetLocal(localKey("after"), 2)
+	assert.Equal(t, 2, msg.Local(localKey("after")), "locals are shared with the original message")
+}
+
+func TestMessage_Local_concurrent(t *testing.T) {
+	msg := message.NewMessage("1", nil)
+
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			msg.SetLocal(i, i)
+			_ = msg.Local(i)
+		}(i)
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, i, msg.Local(i))
+	}
+}
@@ -1,6 +1,8 @@
 package message_test
 
 import (
+	"context"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -123,6 +125,99 @@ func TestMessage_CopyMetadata(t *testing.T) {
 	assert.Equal(t, msgCopy.Metadata.Get("foo"), "bar", "did not expect changing source message's metadata to alter copy's metadata")
 }
 
+func TestMessage_CopyWithNewUUID(t *testing.T) {
+	msg := message.NewMessage("1", []byte("foo"))
+	msg.Metadata.Set("foo", "bar")
+	msgCopy := msg.CopyWithNewUUID()
+
+	assert.NotEqual(t, msg.UUID, msgCopy.UUID)
+	assert.NotEmpty(t, msgCopy.UUID)
+	assert.Equal(t, msg.Payload, msgCopy.Payload)
+	assert.Equal(t, msg.Metadata, msgCopy.Metadata)
+}
+
+func TestMessage_DeepCopy(t *testing.T) {
+	msg := message.NewMessage("1", []byte("foo"))
+	msg.Metadata.Set("foo", "bar")
+	msgCopy := msg.DeepCopy()
+
+	require.True(t, msg.Equals(msgCopy))
+
+	msg.Payload[0] = 'b'
+	msg.Metadata.Set("foo", "baz")
+
+	assert.Equal(t, "foo", string(msgCopy.Payload))
+	assert.Equal(t, "bar", msgCopy.Metadata.Get("foo"))
+
+	require.True(t, msg.Ack())
+	assertNoAck(t, msgCopy)
+}
+
+func TestMessage_CopyOnWrite_not_frozen(t *testing.T) {
+	msg := message.NewMessage("1", []byte("foo"))
+	assert.False(t, msg.IsFrozen())
+
+	assert.Same(t, msg, msg.CopyOnWrite())
+}
+
+func TestMessage_CopyOnWrite_frozen(t *testing.T) {
+	ctx := context.WithValue(context.Background(), "key", "value")
+
+	msg := message.NewMessage("1", []byte("foo"))
+	msg.Metadata.Set("foo", "bar")
+	msg.SetContext(ctx)
+	msg.Freeze()
+	require.True(t, msg.IsFrozen())
+
+	writable := msg.CopyOnWrite()
+	require.NotSame(t, msg, writable)
+	assert.False(t, writable.IsFrozen())
+	assert.True(t, msg.Equals(writable))
+	assert.Equal(t, ctx, writable.Context())
+
+	writable.Payload[0] = 'b'
+	writable.Metadata.Set("foo", "baz")
+
+	assert.Equal(t, "foo", string(msg.Payload))
+	assert.Equal(t, "bar", msg.Metadata.Get("foo"))
+
+	require.True(t, writable.Ack())
+	assertAcked(t, msg)
+	assertAcked(t, writable)
+	assert.False(t, msg.Nack())
+}
+
+func TestMessage_CopyOnWrite_frozen_nack(t *testing.T) {
+	msg := message.NewMessage("1", []byte("foo"))
+	msg.Freeze()
+
+	copyOfCopy := msg.CopyOnWrite().CopyOnWrite()
+	copyOfCopy.Freeze()
+
+	require.True(t, copyOfCopy.CopyOnWrite().Nack())
+	assertNacked(t, msg)
+}
+
+func TestMessage_Freeze_concurrent(t *testing.T) {
+	msg := message.NewMessage("1", []byte("foo"))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			msg.Freeze()
+		}()
+		go func() {
+			defer wg.Done()
+			_ = msg.CopyOnWrite()
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, msg.IsFrozen())
+}
+
 func assertAcked(t *testing.T, msg *message.Message) {
 	select {
 	case <-msg.Acked():
@@ -0,0 +1,21 @@
+package message
+
+// PartitionKeyMetadataKey is the metadata key of the message's partition key.
+// Messages with the same key should be delivered in order, so Pub/Subs with partitioning
+// (for example, Kafka) can use it to choose the partition.
+const PartitionKeyMetadataKey = "_watermill_partition_key"
+
+// SetPartitionKey sets the message's partition key. An empty key removes it.
+func SetPartitionKey(msg *Message, key string) {
+	if key == "" {
+		delete(msg.Metadata, PartitionKeyMetadataKey)
+		return
+	}
+
+	msg.Metadata.Set(PartitionKeyMetadataKey, key)
+}
+
+// PartitionKey returns the message's partition key, or an empty string if it's not set.
+func PartitionKey(msg *Message) string {
+	return msg.Metadata.Get(PartitionKeyMetadataKey)
+}
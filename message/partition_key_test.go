@@ -0,0 +1,22 @@
+package message_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestSetPartitionKey(t *testing.T) {
+	msg := message.NewMessage("1", nil)
+	assert.Empty(t, message.PartitionKey(msg))
+
+	message.SetPartitionKey(msg, "order-1")
+	assert.Equal(t, "order-1", message.PartitionKey(msg))
+	assert.Equal(t, "order-1", msg.Metadata.Get(message.PartitionKeyMetadataKey))
+
+	message.SetPartitionKey(msg, "")
+	assert.Empty(t, message.PartitionKey(msg))
+	assert.NotContains(t, msg.Metadata, message.PartitionKeyMetadataKey)
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -71,16 +73,70 @@ type SubscriberDecorator func(sub Subscriber) (Subscriber, error)
 type RouterConfig struct {
 	// CloseTimeout determines how long router should work for handlers when closing.
 	CloseTimeout time.Duration
+
+	// HandlerRestartPolicy if not nil is used to restart handlers whose subscriptions were closed unexpectedly.
+	// It can be overridden for a single handler with Handler.SetRestartPolicy.
+	// If nil, such handlers are stopped.
+	HandlerRestartPolicy *HandlerRestartPolicy
+
+	// TopicConcurrency configures the concurrency of handlers subscribing to the topics (see HandlerConcurrency).
+	// It can be overridden for a single handler with Handler.SetConcurrency.
+	// Handlers of topics not listed handle every message in a separate goroutine.
+	TopicConcurrency map[string]HandlerConcurrency
+
+	// ShutdownDrain if not nil forwards the messages received by the handlers, but not handled yet
+	// when the router is closing, to a parking topic (see ShutdownDrainPolicy).
+	// If nil, such messages are handled or nacked.
+	ShutdownDrain *ShutdownDrainPolicy
+
+	// StagedShutdown if not nil makes Close stop the intake of all handlers and drain them
+	// before flushing and closing the publishers (see StagedShutdownConfig).
+	// If nil, each handler closes its publisher when its subscription is closed.
+	StagedShutdown *StagedShutdownConfig
+
+	// DelayedNackClock is used to wait before nacking the messages nacked with AckControl.NackWithDelay,
+	// if the subscriber doesn't implement SubscriberWithDelayedNack.
+	// If not provided, watermill.RealClock is used.
+	DelayedNackClock watermill.Clock
 }
 
 func (c *RouterConfig) setDefaults() {
 	if c.CloseTimeout == 0 {
 		c.CloseTimeout = time.Second * 30
 	}
+	if c.HandlerRestartPolicy != nil {
+		policy := *c.HandlerRestartPolicy
+		policy.setDefaults()
+		c.HandlerRestartPolicy = &policy
+	}
+	if c.ShutdownDrain != nil {
+		policy := *c.ShutdownDrain
+		policy.setDefaults()
+		c.ShutdownDrain = &policy
+	}
+	if c.DelayedNackClock == nil {
+		c.DelayedNackClock = watermill.RealClock{}
+	}
 }
 
 // Validate returns Router configuration error, if any.
 func (c RouterConfig) Validate() error {
+	if c.HandlerRestartPolicy != nil {
+		if err := c.HandlerRestartPolicy.Validate(); err != nil {
+			return errors.Wrap(err, "invalid HandlerRestartPolicy")
+		}
+	}
+	for topic, concurrency := range c.TopicConcurrency {
+		if err := concurrency.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid TopicConcurrency of topic %s", topic)
+		}
+	}
+	if c.ShutdownDrain != nil {
+		if err := c.ShutdownDrain.Validate(); err != nil {
+			return errors.Wrap(err, "invalid ShutdownDrain")
+		}
+	}
+
 	return nil
 }
 
@@ -112,6 +168,8 @@ func NewRouter(config RouterConfig, logger watermill.LoggerAdapter) (*Router, er
 		closingInProgressCh: make(chan struct{}),
 		closedCh:            make(chan struct{}),
 
+		stagedShutdown: newStagedShutdown(config.StagedShutdown),
+
 		logger: logger,
 
 		running: make(chan struct{}),
@@ -122,6 +180,12 @@ type middleware struct {
 	Handler       HandlerMiddleware
 	HandlerName   string
 	IsRouterLevel bool
+
+	// Name is set only for middlewares added with AddNamedMiddleware.
+	Name string
+
+	// Constraints are set only for middlewares added with AddConstrainedMiddleware.
+	Constraints *MiddlewareConstraints
 }
 
 // Router is responsible for handling messages from subscribers using provided handler functions.
@@ -131,7 +195,9 @@ type middleware struct {
 type Router struct {
 	config RouterConfig
 
-	middlewares []middleware
+	middlewares        []middleware
+	middlewaresVersion int
+	middlewaresLock    sync.RWMutex
 
 	plugins []RouterPlugin
 
@@ -150,6 +216,9 @@ type Router struct {
 	closed              bool
 	closedLock          sync.Mutex
 
+	// stagedShutdown is nil if RouterConfig.StagedShutdown is not set
+	stagedShutdown *stagedShutdown
+
 	logger watermill.LoggerAdapter
 
 	publisherDecorators  []PublisherDecorator
@@ -167,24 +236,76 @@ func (r *Router) Logger() watermill.LoggerAdapter {
 // AddMiddleware adds a new middleware to the router.
 //
 // The order of middleware matters. Middleware added at the beginning is executed first.
+//
+// Middleware can be added while the router is running. It's applied to messages received after it was added.
 func (r *Router) AddMiddleware(m ...HandlerMiddleware) {
 	r.logger.Debug("Adding middleware", watermill.LogFields{"count": fmt.Sprintf("%d", len(m))})
 
-	r.addRouterLevelMiddleware(m...)
+	r.addRouterLevelMiddleware("", m...)
 }
 
-func (r *Router) addRouterLevelMiddleware(m ...HandlerMiddleware) {
+// AddNamedMiddleware adds a new middleware to the router, which can be later removed with RemoveMiddleware.
+// It works like AddMiddleware otherwise.
+//
+// It's useful for operational toggles, like enabling debug logging without restarting the service.
+func (r *Router) AddNamedMiddleware(name string, m ...HandlerMiddleware) {
+	r.logger.Debug("Adding named middleware", watermill.LogFields{
+		"count": fmt.Sprintf("%d", len(m)),
+		"name":  name,
+	})
+
+	r.addRouterLevelMiddleware(name, m...)
+}
+
+// RemoveMiddleware removes all middlewares added with AddNamedMiddleware with the provided name.
+// It returns false if there was no middleware with this name.
+//
+// Messages that are already processed are not affected. Keep in mind that removing a middleware rebuilds
+// the handlers' middleware chains, so state kept by middlewares between wrapping and calling the handler is lost.
+func (r *Router) RemoveMiddleware(name string) bool {
+	r.middlewaresLock.Lock()
+	defer r.middlewaresLock.Unlock()
+
+	middlewares := make([]middleware, 0, len(r.middlewares))
+	for _, m := range r.middlewares {
+		if m.Name != "" && m.Name == name {
+			continue
+		}
+		middlewares = append(middlewares, m)
+	}
+
+	if len(middlewares) == len(r.middlewares) {
+		return false
+	}
+
+	r.middlewares = middlewares
+	r.middlewaresVersion++
+
+	r.logger.Debug("Middleware removed", watermill.LogFields{"name": name})
+
+	return true
+}
+
+func (r *Router) addRouterLevelMiddleware(name string, m ...HandlerMiddleware) {
+	r.middlewaresLock.Lock()
+	defer r.middlewaresLock.Unlock()
+
 	for _, handlerMiddleware := range m {
 		middleware := middleware{
 			Handler:       handlerMiddleware,
 			HandlerName:   "",
 			IsRouterLevel: true,
+			Name:          name,
 		}
 		r.middlewares = append(r.middlewares, middleware)
 	}
+	r.middlewaresVersion++
 }
 
 func (r *Router) addHandlerLevelMiddleware(handlerName string, m ...HandlerMiddleware) {
+	r.middlewaresLock.Lock()
+	defer r.middlewaresLock.Unlock()
+
 	for _, handlerMiddleware := range m {
 		middleware := middleware{
 			Handler:       handlerMiddleware,
@@ -193,6 +314,16 @@ func (r *Router) addHandlerLevelMiddleware(handlerName string, m ...HandlerMiddl
 		}
 		r.middlewares = append(r.middlewares, middleware)
 	}
+	r.middlewaresVersion++
+}
+
+// currentMiddlewares returns the middlewares and the version of the middlewares list,
+// which is changed every time a middleware is added or removed.
+func (r *Router) currentMiddlewares() ([]middleware, int) {
+	r.middlewaresLock.RLock()
+	defer r.middlewaresLock.RUnlock()
+
+	return r.middlewares, r.middlewaresVersion
 }
 
 // AddPlugin adds a new plugin to the router.
@@ -261,9 +392,22 @@ func (r *Router) AddHandler(
 	publisher Publisher,
 	handlerFunc HandlerFunc,
 ) *Handler {
+	return r.addHandler(handlerName, []string{subscribeTopic}, subscriber, publishTopic, publisher, handlerFunc)
+}
+
+func (r *Router) addHandler(
+	handlerName string,
+	subscribeTopics []string,
+	subscriber Subscriber,
+	publishTopic string,
+	publisher Publisher,
+	handlerFunc HandlerFunc,
+) *Handler {
+	subscribeTopic := subscribeTopics[0]
+
 	r.logger.Info("Adding handler", watermill.LogFields{
 		"handler_name": handlerName,
-		"topic":        subscribeTopic,
+		"topic":        strings.Join(subscribeTopics, ","),
 	})
 
 	r.handlersLock.Lock()
@@ -275,13 +419,17 @@ func (r *Router) AddHandler(
 
 	publisherName, subscriberName := internal.StructName(publisher), internal.StructName(subscriber)
 
+	// checked before the publisher is decorated
+	flusher, _ := publisher.(PublisherWithFlush)
+
 	newHandler := &handler{
 		name:   handlerName,
-		logger: r.logger,
+		logger: newHandlerLogger(r.logger),
 
-		subscriber:     subscriber,
-		subscribeTopic: subscribeTopic,
-		subscriberName: subscriberName,
+		subscriber:      subscriber,
+		subscribeTopic:  subscribeTopic,
+		subscribeTopics: subscribeTopics,
+		subscriberName:  subscriberName,
 
 		publisher:     publisher,
 		publishTopic:  publishTopic,
@@ -296,6 +444,21 @@ func (r *Router) AddHandler(
 		routersCloseCh: r.closingInProgressCh,
 
 		startedCh: make(chan struct{}),
+
+		restartPolicy: r.config.HandlerRestartPolicy,
+		shutdownDrain: r.config.ShutdownDrain,
+		concurrency:   r.topicsConcurrency(subscribeTopics),
+		status:        HandlerStatus{State: HandlerStateNotStarted},
+
+		stagedShutdown: r.stagedShutdown,
+		flusher:        flusher,
+	}
+
+	middlewares, _ := r.currentMiddlewares()
+	if err := newHandler.validateMiddlewares(middlewares, false); err != nil {
+		r.logger.Error("Invalid middlewares of handler", err, watermill.LogFields{
+			"handler_name": handlerName,
+		})
 	}
 
 	r.handlersWg.Add(1)
@@ -397,14 +560,30 @@ func (r *Router) RunHandlers(ctx context.Context) error {
 
 	r.logger.Info("Running router handlers", watermill.LogFields{"count": len(r.handlers)})
 
-	for name, h := range r.handlers {
-		name := name
+	handlersToStart, err := r.handlersInStartOrder()
+	if err != nil {
+		return err
+	}
+
+	middlewares, _ := r.currentMiddlewares()
+	for _, h := range handlersToStart {
+		if err := h.validateMiddlewares(middlewares, true); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range handlersToStart {
+		name := h.name
 		h := h
 
-		if h.started {
+		if r.disabledAtSubscribe(ctx, h) {
 			continue
 		}
 
+		if err := h.waitUntilReady(ctx); err != nil {
+			return errors.Wrapf(err, "handler %s is not ready to start", name)
+		}
+
 		if err := r.decorateHandlerPublisher(h); err != nil {
 			return errors.Wrapf(err, "could not decorate publisher of handler %s", name)
 		}
@@ -414,43 +593,106 @@ func (r *Router) RunHandlers(ctx context.Context) error {
 
 		r.logger.Debug("Subscribing to topic", watermill.LogFields{
 			"subscriber_name": h.name,
-			"topic":           h.subscribeTopic,
+			"topic":           h.topicsLogField(),
 		})
 
 		ctx, cancel := context.WithCancel(ctx)
 
-		messages, err := h.subscriber.Subscribe(ctx, h.subscribeTopic)
+		messages, err := h.subscribe(ctx)
 		if err != nil {
 			cancel()
-			return errors.Wrapf(err, "cannot subscribe topic %s", h.subscribeTopic)
+			return err
 		}
 
 		h.messagesCh = messages
 		h.started = true
 		close(h.startedCh)
 
+		h.runningSince = time.Now()
+		if h.restartPolicy != nil {
+			h.runningSince = h.restartPolicy.Clock.Now()
+		}
+		h.setStatus(HandlerStatus{State: HandlerStateRunning})
+
 		h.stopFn = cancel
 		h.stopped = make(chan struct{})
 
 		go func() {
 			defer cancel()
 
-			h.run(ctx, r.middlewares)
+			h.run(ctx, r.currentMiddlewares)
 
 			r.handlersWg.Done()
 			r.logger.Info("Subscriber stopped", watermill.LogFields{
 				"subscriber_name": h.name,
-				"topic":           h.subscribeTopic,
+				"topic":           h.topicsLogField(),
 			})
 
 			r.handlersLock.Lock()
-			delete(r.handlers, name)
+			if r.handlers[name] == h {
+				// the handler may be already removed and replaced with RemoveHandler
+				delete(r.handlers, name)
+			}
 			r.handlersLock.Unlock()
 		}()
 	}
 	return nil
 }
 
+// handlersInStartOrder returns not started handlers sorted so that every handler
+// is started after the handlers declared with Handler.StartAfter.
+func (r *Router) handlersInStartOrder() ([]*handler, error) {
+	names := make([]string, 0, len(r.handlers))
+	for name, h := range r.handlers {
+		if !h.started {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var ordered []*handler
+	visited := map[string]bool{}
+	visiting := map[string]bool{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return errors.Errorf("handler %s has a circular start dependency", name)
+		}
+
+		h := r.handlers[name]
+		if h.started {
+			return nil
+		}
+
+		visiting[name] = true
+		for _, dependency := range h.startAfter {
+			if _, ok := r.handlers[dependency]; !ok {
+				return errors.Errorf("handler %s should start after unknown handler %s", name, dependency)
+			}
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		visited[name] = true
+
+		ordered = append(ordered, h)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
 // closeWhenAllHandlersStopped closed router, when all handlers has stopped,
 // because for example all subscriptions are closed.
 func (r *Router) closeWhenAllHandlersStopped(ctx context.Context) {
@@ -530,9 +772,14 @@ func (r *Router) Close() error {
 	r.logger.Info("Closing router", nil)
 	defer r.logger.Info("Router closed", nil)
 
-	close(r.closingInProgressCh)
 	defer close(r.closedCh)
 
+	if r.stagedShutdown != nil {
+		return r.closeInStages()
+	}
+
+	close(r.closingInProgressCh)
+
 	timeouted := r.waitForHandlers()
 	if timeouted {
 		return errors.New("router close timeout")
@@ -569,16 +816,21 @@ func (r *Router) IsClosed() bool {
 
 type handler struct {
 	name   string
-	logger watermill.LoggerAdapter
+	logger *handlerLogger
 
 	subscriber     Subscriber
 	subscribeTopic string
 	subscriberName string
 
+	// subscribeTopics are all topics of the handler, subscribeTopic is the first one
+	subscribeTopics []string
+
 	publisher     Publisher
 	publishTopic  string
 	publisherName string
 
+	publisherMiddlewares []PublisherMiddleware
+
 	handlerFunc HandlerFunc
 
 	runningHandlersWg     *sync.WaitGroup
@@ -589,17 +841,101 @@ type handler struct {
 	started   bool
 	startedCh chan struct{}
 
+	startAfter   []string
+	readinessFns []HandlerReadinessFunc
+
 	stopFn         context.CancelFunc
 	stopped        chan struct{}
 	routersCloseCh chan struct{}
+
+	restartPolicy *HandlerRestartPolicy
+	runningSince  time.Time
+
+	// shutdownDrain is nil if draining on shutdown is disabled
+	shutdownDrain *ShutdownDrainPolicy
+
+	// stagedShutdown is nil if the router's staged shutdown is disabled
+	stagedShutdown *stagedShutdown
+	// flusher is nil if the handler's publisher doesn't support flushing
+	flusher PublisherWithFlush
+
+	concurrency HandlerConcurrency
+
+	// priorityLanes is nil if the priority lanes are disabled
+	priorityLanes *HandlerPriorityLanes
+
+	// owner is the component which added the handler (see Handler.SetOwner)
+	owner string
+
+	// manualAck is true if messages are acked explicitly with AckControl (see Router.AddManualAckHandler)
+	manualAck bool
+	// delayedNacker is nil if the subscriber of the manual ack handler doesn't support delayed nack
+	delayedNacker SubscriberWithDelayedNack
+	// delayedNacks is nil if the handler is not a manual ack handler
+	delayedNacks *delayedNacks
+	// delayedNackClock is used to wait before nacking the messages in delayedNacks
+	delayedNackClock watermill.Clock
+
+	// featureFlag is nil if the handler is not gated with a feature flag
+	featureFlag        *HandlerFeatureFlag
+	featureFlagPolling bool
+
+	status     HandlerStatus
+	statusLock sync.Mutex
 }
 
-func (h *handler) run(ctx context.Context, middlewares []middleware) {
+func (h *handler) run(ctx context.Context, currentMiddlewares func() ([]middleware, int)) {
 	h.logger.Info("Starting handler", watermill.LogFields{
 		"subscriber_name": h.name,
-		"topic":           h.subscribeTopic,
+		"topic":           h.topicsLogField(),
 	})
 
+	middlewareHandler := &sharedMiddlewareHandler{
+		handler:            h,
+		currentMiddlewares: currentMiddlewares,
+	}
+	// the middlewares are built before receiving the first message
+	middlewareHandler.get()
+
+	go h.handleClose(ctx)
+
+	for {
+		if h.priorityLanes != nil {
+			h.runPriorityLanes(middlewareHandler)
+		} else if h.concurrency.Workers > 0 {
+			h.runWorkers(middlewareHandler)
+		} else {
+			for msg := range h.messagesCh {
+				h.runningHandlersWgLock.Lock()
+				h.runningHandlersWg.Add(1)
+				h.runningHandlersWgLock.Unlock()
+
+				go h.handleMessage(msg, middlewareHandler.get())
+			}
+		}
+
+		if !h.restart(ctx) {
+			break
+		}
+	}
+
+	if h.publisher != nil && !h.deferPublisherClose() {
+		h.closePublisher()
+	}
+
+	h.logger.Debug("Router handler stopped", nil)
+	close(h.stopped)
+}
+
+func (h *handler) closePublisher() {
+	h.logger.Debug("Waiting for publisher to close", nil)
+	if err := h.publisher.Close(); err != nil {
+		h.logger.Error("Failed to close publisher", err, nil)
+	}
+	h.logger.Debug("Publisher closed", nil)
+}
+
+func (h *handler) handlerFuncWithMiddlewares(middlewares []middleware) HandlerFunc {
 	middlewareHandler := h.handlerFunc
 	// first added middlewares should be executed first (so should be at the top of call stack)
 	for i := len(middlewares) - 1; i >= 0; i-- {
@@ -610,26 +946,50 @@ func (h *handler) run(ctx context.Context, middlewares []middleware) {
 		}
 	}
 
-	go h.handleClose(ctx)
+	if h.featureFlag != nil && h.featureFlag.Check == FeatureFlagCheckMessage {
+		middlewareHandler = h.featureFlagMiddleware(middlewareHandler)
+	}
 
-	for msg := range h.messagesCh {
-		h.runningHandlersWgLock.Lock()
-		h.runningHandlersWg.Add(1)
-		h.runningHandlersWgLock.Unlock()
+	return middlewareHandler
+}
 
-		go h.handleMessage(msg, middlewareHandler)
+// HandlerNotFoundError occurs when the router has no handler with the provided name.
+type HandlerNotFoundError struct {
+	HandlerName string
+}
+
+func (e HandlerNotFoundError) Error() string {
+	return fmt.Sprintf("handler %s not found", e.HandlerName)
+}
+
+// HandleMessage passes the message directly to the handler, bypassing its subscriber.
+// The message goes through the same middlewares as messages received from the subscriber,
+// and messages produced by the handler are published with the handler's publisher.
+//
+// The message is not acked or nacked; the handler's error is returned instead.
+// It's useful for dispatching messages to handlers running in the same process without a round-trip to the broker.
+func (r *Router) HandleMessage(handlerName string, msg *Message) error {
+	r.handlersLock.RLock()
+	h, ok := r.handlers[handlerName]
+	r.handlersLock.RUnlock()
+
+	if !ok {
+		return HandlerNotFoundError{handlerName}
 	}
 
-	if h.publisher != nil {
-		h.logger.Debug("Waiting for publisher to close", nil)
-		if err := h.publisher.Close(); err != nil {
-			h.logger.Error("Failed to close publisher", err, nil)
-		}
-		h.logger.Debug("Publisher closed", nil)
+	h.addHandlerContext(msg)
+	h.addMessageLogger(msg)
+
+	middlewares, _ := r.currentMiddlewares()
+
+	producedMessages, err := h.handlerFuncWithMiddlewares(middlewares)(msg)
+	if err != nil {
+		return err
 	}
 
-	h.logger.Debug("Router handler stopped", nil)
-	close(h.stopped)
+	h.addHandlerContext(producedMessages...)
+
+	return h.publishProducedMessages(producedMessages, h.logger, watermill.LogFields{"message_uuid": msg.UUID})
 }
 
 // Handler handles Messages.
@@ -641,6 +1001,8 @@ type Handler struct {
 // AddMiddleware adds new middleware to the specified handler in the router.
 //
 // The order of middleware matters. Middleware added at the beginning is executed first.
+//
+// Middleware can be added while the handler is running. It's applied to messages received after it was added.
 func (h *Handler) AddMiddleware(m ...HandlerMiddleware) {
 	handler := h.handler
 	handler.logger.Debug("Adding middleware to handler", watermill.LogFields{
@@ -656,6 +1018,47 @@ func (h *Handler) Started() chan struct{} {
 	return h.handler.startedCh
 }
 
+// HandlerReadinessFunc blocks until the handler can be started.
+// When it returns an error, the handler is not started and RunHandlers returns the error.
+type HandlerReadinessFunc func(ctx context.Context) error
+
+// StartAfter declares that the handler should subscribe to its topic only after the subscriptions
+// of the provided handlers are established.
+//
+// It's useful to avoid races when one handler produces messages consumed by another handler,
+// for example, when a reply consumer needs to subscribe before requests are published.
+//
+// StartAfter must be called before the handler is started.
+func (h *Handler) StartAfter(handlerNames ...string) {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	h.handler.startAfter = append(h.handler.startAfter, handlerNames...)
+}
+
+// StartWhen declares that the handler should subscribe to its topic only after readinessFn returns.
+// Multiple readiness functions can be added; they are called in the order they were added.
+//
+// StartWhen must be called before the handler is started.
+func (h *Handler) StartWhen(readinessFn HandlerReadinessFunc) {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	h.handler.readinessFns = append(h.handler.readinessFns, readinessFn)
+}
+
+func (h *handler) waitUntilReady(ctx context.Context) error {
+	for _, readinessFn := range h.readinessFns {
+		if err := readinessFn(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Stop stops the handler.
 // Stop is asynchronous.
 // You can check if handler was stopped with Stopped() function.
@@ -696,12 +1099,14 @@ func (r *Router) decorateHandlerSubscriber(h *handler) error {
 
 	// add values to message context to subscriber
 	// it goes before other decorators, so that they may take advantage of these values
-	messageTransform := func(msg *Message) {
+	messageTransform := func(msg *Message, topic string) {
 		if msg != nil {
 			h.addHandlerContext(msg)
+			h.addSubscribeTopicContext(msg, topic)
+			h.addMessageLogger(msg)
 		}
 	}
-	sub, err = MessageTransformSubscriberDecorator(messageTransform)(sub)
+	sub, err = messageTopicTransformSubscriberDecorator(messageTransform)(sub)
 	if err != nil {
 		return errors.Wrapf(err, "cannot wrap subscriber with context decorator")
 	}
@@ -743,6 +1148,10 @@ func (h *handler) addHandlerContext(messages ...*Message) {
 func (h *handler) handleClose(ctx context.Context) {
 	select {
 	case <-h.routersCloseCh:
+		if h.delayedNacks != nil {
+			h.delayedNacks.flush()
+		}
+
 		// for backward compatibility we are closing subscriber
 		h.logger.Debug("Waiting for subscriber to close", nil)
 		if err := h.subscriber.Close(); err != nil {
@@ -751,6 +1160,9 @@ func (h *handler) handleClose(ctx context.Context) {
 		h.logger.Debug("Subscriber closed", nil)
 	case <-ctx.Done():
 		// we are closing subscriber just when entire router is closed
+		if h.delayedNacks != nil {
+			h.delayedNacks.flush()
+		}
 	}
 	h.stopFn()
 }
@@ -758,40 +1170,59 @@ func (h *handler) handleClose(ctx context.Context) {
 func (h *handler) handleMessage(msg *Message, handler HandlerFunc) {
 	defer h.runningHandlersWg.Done()
 	msgFields := watermill.LogFields{"message_uuid": msg.UUID}
+	logger := h.logger.forMessage()
+
+	nack := msg.Nack
+	if h.manualAck {
+		// the message may be already settled (or nacked with delay) by the handler
+		nack = h.ackControl(msg).Nack
+	}
 
 	defer func() {
 		if recovered := recover(); recovered != nil {
-			h.logger.Error(
+			logger.Error(
 				"Panic recovered in handler. Stack: "+string(debug.Stack()),
 				errors.Errorf("%s", recovered),
 				msgFields,
 			)
-			msg.Nack()
+			nack()
 		}
 	}()
 
-	h.logger.Trace("Received message", msgFields)
+	logger.Trace("Received message", msgFields)
+
+	if h.drainIfClosing(msg, logger, msgFields) {
+		return
+	}
 
 	producedMessages, err := handler(msg)
 	if err != nil {
-		h.logger.Error("Handler returned error", err, msgFields)
-		msg.Nack()
+		logger.Error("Handler returned error", err, msgFields)
+		nack()
 		return
 	}
 
 	h.addHandlerContext(producedMessages...)
 
-	if err := h.publishProducedMessages(producedMessages, msgFields); err != nil {
-		h.logger.Error("Publishing produced messages failed", err, nil)
-		msg.Nack()
+	if err := h.publishProducedMessages(producedMessages, logger, msgFields); err != nil {
+		logger.Error("Publishing produced messages failed", err, nil)
+		nack()
+		return
+	}
+
+	if h.manualAck {
+		// the error may be swallowed by a middleware, but the message is not settled by the handler
+		if h.ackControl(msg).nackIfHandlerFailed() {
+			logger.Trace("Message nacked after the handler error", msgFields)
+		}
 		return
 	}
 
 	msg.Ack()
-	h.logger.Trace("Message acked", msgFields)
+	logger.Trace("Message acked", msgFields)
 }
 
-func (h *handler) publishProducedMessages(producedMessages Messages, msgFields watermill.LogFields) error {
+func (h *handler) publishProducedMessages(producedMessages Messages, logger watermill.LoggerAdapter, msgFields watermill.LogFields) error {
 	if len(producedMessages) == 0 {
 		return nil
 	}
@@ -800,15 +1231,17 @@ func (h *handler) publishProducedMessages(producedMessages Messages, msgFields w
 		return ErrOutputInNoPublisherHandler
 	}
 
-	h.logger.Trace("Sending produced messages", msgFields.Add(watermill.LogFields{
+	logger.Trace("Sending produced messages", msgFields.Add(watermill.LogFields{
 		"produced_messages_count": len(producedMessages),
 		"publish_topic":           h.publishTopic,
 	}))
 
+	publish := h.publishFunc()
+
 	for _, msg := range producedMessages {
-		if err := h.publisher.Publish(h.publishTopic, msg); err != nil {
+		if err := publish(h.publishTopic, msg); err != nil {
 			// todo - how to deal with it better/transactional/retry?
-			h.logger.Error("Cannot publish message", err, msgFields.Add(watermill.LogFields{
+			logger.Error("Cannot publish message", err, msgFields.Add(watermill.LogFields{
 				"not_sent_message": fmt.Sprintf("%#v", producedMessages),
 			}))
 
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// AuthorizationInput contains the data about the message evaluated by PolicyEvaluator.
+type AuthorizationInput struct {
+	HandlerName    string            `json:"handler_name"`
+	SubscribeTopic string            `json:"subscribe_topic"`
+	MessageUUID    string            `json:"message_uuid"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// PolicyEvaluator decides if the message can be consumed by the handler.
+type PolicyEvaluator interface {
+	// Evaluate returns true if the message is authorized.
+	// When an error is returned, the message is nacked.
+	Evaluate(ctx context.Context, input AuthorizationInput) (bool, error)
+}
+
+// PolicyEvaluatorFunc is a function implementing PolicyEvaluator.
+type PolicyEvaluatorFunc func(ctx context.Context, input AuthorizationInput) (bool, error)
+
+func (f PolicyEvaluatorFunc) Evaluate(ctx context.Context, input AuthorizationInput) (bool, error) {
+	return f(ctx, input)
+}
+
+// UnauthorizedError is returned by the Authorization middleware when the policy rejected the message.
+type UnauthorizedError struct {
+	HandlerName string
+	MessageUUID string
+}
+
+func (e UnauthorizedError) Error() string {
+	return fmt.Sprintf("message %s is not authorized to be handled by %s", e.MessageUUID, e.HandlerName)
+}
+
+// Authorization provides a middleware that evaluates a policy before the message is handled.
+//
+// Unauthorized messages are not passed to the handler and UnauthorizedError is returned.
+// To move unauthorized messages to a dead-letter topic, add PoisonQueueWithFilter before this middleware:
+//
+//	PoisonQueueWithFilter(publisher, "unauthorized", func(err error) bool {
+//		return errors.As(err, &middleware.UnauthorizedError{})
+//	})
+type Authorization struct {
+	// Evaluator decides if the message is authorized. It is required.
+	// Use OPAEvaluator to evaluate Open Policy Agent policies.
+	Evaluator PolicyEvaluator
+
+	// AckUnauthorized makes the middleware ack unauthorized messages instead of returning UnauthorizedError.
+	AckUnauthorized bool
+
+	Logger watermill.LoggerAdapter
+}
+
+// Middleware returns the Authorization middleware.
+func (a Authorization) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	if a.Evaluator == nil {
+		panic("missing Evaluator")
+	}
+
+	logger := a.Logger
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return func(msg *message.Message) ([]*message.Message, error) {
+		ctx := msg.Context()
+
+		input := AuthorizationInput{
+			HandlerName:    message.HandlerNameFromCtx(ctx),
+			SubscribeTopic: message.SubscribeTopicFromCtx(ctx),
+			MessageUUID:    msg.UUID,
+			Metadata:       msg.Metadata,
+		}
+
+		authorized, err := a.Evaluator.Evaluate(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot evaluate authorization policy")
+		}
+
+		if authorized {
+			return h(msg)
+		}
+
+		if a.AckUnauthorized {
+			logger.Info("Message is not authorized, acking", watermill.LogFields{
+				"message_uuid": msg.UUID,
+				"handler_name": input.HandlerName,
+			})
+			return nil, nil
+		}
+
+		return nil, UnauthorizedError{
+			HandlerName: input.HandlerName,
+			MessageUUID: msg.UUID,
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// OPAEvaluator is a PolicyEvaluator that queries an Open Policy Agent server using its Data API.
+//
+// AuthorizationInput is sent as the policy's input. The policy must return a boolean, for example:
+//
+//	package watermill.authz
+//
+//	default allow = false
+//
+//	allow {
+//		input.metadata.tenant_id == "trusted"
+//	}
+//
+// For the policy above, URL should be "http://localhost:8181/v1/data/watermill/authz/allow".
+type OPAEvaluator struct {
+	// URL is the address of the policy decision, for example "http://localhost:8181/v1/data/watermill/authz/allow".
+	URL string
+
+	// Client is used to query OPA. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+type opaRequest struct {
+	Input AuthorizationInput `json:"input"`
+}
+
+type opaResponse struct {
+	Result *bool `json:"result"`
+}
+
+// Evaluate queries OPA for the decision.
+// If the policy is undefined for the input, the message is not authorized.
+func (e OPAEvaluator) Evaluate(ctx context.Context, input AuthorizationInput) (bool, error) {
+	body, err := json.Marshal(opaRequest{Input: input})
+	if err != nil {
+		return false, errors.Wrap(err, "cannot marshal OPA input")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "cannot create OPA request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "cannot query OPA")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("unexpected OPA response status: %d", resp.StatusCode)
+	}
+
+	var decision opaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, errors.Wrap(err, "cannot decode OPA response")
+	}
+
+	if decision.Result == nil {
+		return false, nil
+	}
+
+	return *decision.Result, nil
+}
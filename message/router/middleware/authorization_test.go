@@ -0,0 +1,114 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func tenantPolicy(ctx context.Context, input middleware.AuthorizationInput) (bool, error) {
+	return input.Metadata["tenant_id"] == "trusted", nil
+}
+
+func TestAuthorization(t *testing.T) {
+	h := middleware.Authorization{
+		Evaluator: middleware.PolicyEvaluatorFunc(tenantPolicy),
+	}.Middleware(handlerFuncAlwaysOK)
+
+	authorizedMsg := message.NewMessage("1", nil)
+	authorizedMsg.Metadata.Set("tenant_id", "trusted")
+
+	msgs, err := h(authorizedMsg)
+	require.NoError(t, err)
+	assert.Equal(t, handlerFuncAlwaysOKMessages, msgs)
+
+	unauthorizedMsg := message.NewMessage("2", nil)
+	unauthorizedMsg.Metadata.Set("tenant_id", "other")
+
+	msgs, err = h(unauthorizedMsg)
+	assert.Empty(t, msgs)
+
+	var unauthorizedErr middleware.UnauthorizedError
+	require.ErrorAs(t, err, &unauthorizedErr)
+	assert.Equal(t, "2", unauthorizedErr.MessageUUID)
+}
+
+func TestAuthorization_AckUnauthorized(t *testing.T) {
+	handlerCalled := false
+
+	h := middleware.Authorization{
+		Evaluator:       middleware.PolicyEvaluatorFunc(tenantPolicy),
+		AckUnauthorized: true,
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handlerCalled = true
+		return nil, nil
+	})
+
+	msgs, err := h(message.NewMessage("1", nil))
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+	assert.False(t, handlerCalled)
+}
+
+func TestAuthorization_evaluator_error(t *testing.T) {
+	h := middleware.Authorization{
+		Evaluator: middleware.PolicyEvaluatorFunc(func(ctx context.Context, input middleware.AuthorizationInput) (bool, error) {
+			return false, errors.New("policy unavailable")
+		}),
+		AckUnauthorized: true,
+	}.Middleware(handlerFuncAlwaysOK)
+
+	_, err := h(message.NewMessage("1", nil))
+	assert.ErrorContains(t, err, "policy unavailable")
+}
+
+func TestOPAEvaluator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/data/watermill/authz/allow", r.URL.Path)
+
+		var req struct {
+			Input middleware.AuthorizationInput `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch req.Input.MessageUUID {
+		case "undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			allowed := req.Input.Metadata["tenant_id"] == "trusted"
+			_ = json.NewEncoder(w).Encode(map[string]bool{"result": allowed})
+		}
+	}))
+	defer server.Close()
+
+	evaluator := middleware.OPAEvaluator{URL: server.URL + "/v1/data/watermill/authz/allow"}
+
+	allowed, err := evaluator.Evaluate(context.Background(), middleware.AuthorizationInput{
+		MessageUUID: "1",
+		Metadata:    map[string]string{"tenant_id": "trusted"},
+	})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	allowed, err = evaluator.Evaluate(context.Background(), middleware.AuthorizationInput{
+		MessageUUID: "2",
+		Metadata:    map[string]string{"tenant_id": "other"},
+	})
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	allowed, err = evaluator.Evaluate(context.Background(), middleware.AuthorizationInput{
+		MessageUUID: "undefined",
+	})
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// BaggageMetadataKey is used to store the baggage in metadata.
+const BaggageMetadataKey = "baggage"
+
+// Baggage contains key-value entries propagated between services, in the same way as the W3C Baggage HTTP header.
+//
+// In metadata, it's stored in the W3C Baggage format, for example: "user_id=123,tenant=acme".
+// Properties of the entries (for example, "tenant=acme;prop") are not supported and are dropped when parsing.
+type Baggage map[string]string
+
+// ParseBaggage parses the baggage in the W3C Baggage format.
+// Invalid entries are skipped.
+func ParseBaggage(s string) Baggage {
+	baggage := Baggage{}
+
+	for _, member := range strings.Split(s, ",") {
+		member, _, _ = strings.Cut(member, ";")
+
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+
+		baggage[key] = value
+	}
+
+	return baggage
+}
+
+// String returns the baggage in the W3C Baggage format, with entries sorted by key.
+func (b Baggage) String() string {
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	members := make([]string, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, key+"="+url.PathEscape(b[key]))
+	}
+
+	return strings.Join(members, ",")
+}
+
+// MessageBaggage returns the baggage from the message's metadata.
+func MessageBaggage(msg *message.Message) Baggage {
+	return ParseBaggage(msg.Metadata.Get(BaggageMetadataKey))
+}
+
+// SetBaggage sets the baggage in the message's metadata, replacing the existing one.
+func SetBaggage(msg *message.Message, baggage Baggage) {
+	if len(baggage) == 0 {
+		delete(msg.Metadata, BaggageMetadataKey)
+		return
+	}
+
+	msg.Metadata.Set(BaggageMetadataKey, baggage.String())
+}
+
+// SetBaggageEntry sets a single baggage entry in the message's metadata, keeping other entries.
+func SetBaggageEntry(msg *message.Message, key string, value string) {
+	baggage := MessageBaggage(msg)
+	baggage[key] = value
+
+	SetBaggage(msg, baggage)
+}
+
+type baggageCtxKey struct{}
+
+// ContextWithBaggage returns a new context with the baggage, which is added by BaggagePublisherDecorator to published messages.
+func ContextWithBaggage(ctx context.Context, baggage Baggage) context.Context {
+	return context.WithValue(ctx, baggageCtxKey{}, baggage)
+}
+
+// BaggageFromContext returns the baggage stored in the context by ContextWithBaggage or the PropagateBaggage middleware.
+func BaggageFromContext(ctx context.Context) Baggage {
+	baggage, _ := ctx.Value(baggageCtxKey{}).(Baggage)
+	return baggage
+}
+
+// PropagateBaggage adds the baggage of the message received by handler to all messages produced by the handler.
+//
+// The baggage is also stored in the message's context (see BaggageFromContext),
+// so BaggagePublisherDecorator can add it to messages published within the handler with a publisher of your own.
+//
+// Entries already set on the produced messages are not overwritten.
+func PropagateBaggage(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		baggage := MessageBaggage(msg)
+		if len(baggage) == 0 {
+			return h(msg)
+		}
+
+		msg.SetContext(ContextWithBaggage(msg.Context(), baggage))
+
+		producedMessages, err := h(msg)
+
+		for _, producedMsg := range producedMessages {
+			mergeBaggage(producedMsg, baggage)
+		}
+
+		return producedMessages, err
+	}
+}
+
+// BaggagePublisherDecorator adds the baggage from the message's context to the published messages' metadata.
+//
+// Messages published within a handler must have the context of the received message
+// (for example, msg.SetContext(receivedMsg.Context())), and the handler must use the PropagateBaggage middleware.
+//
+// Entries already set on the published messages are not overwritten.
+func BaggagePublisherDecorator() message.PublisherDecorator {
+	return message.MessageTransformPublisherDecorator(func(msg *message.Message) {
+		mergeBaggage(msg, BaggageFromContext(msg.Context()))
+	})
+}
+
+func mergeBaggage(msg *message.Message, baggage Baggage) {
+	if len(baggage) == 0 {
+		return
+	}
+
+	msgBaggage := MessageBaggage(msg)
+	for key, value := range baggage {
+		if _, ok := msgBaggage[key]; !ok {
+			msgBaggage[key] = value
+		}
+	}
+
+	SetBaggage(msg, msgBaggage)
+}
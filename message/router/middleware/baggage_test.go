@@ -0,0 +1,98 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestParseBaggage(t *testing.T) {
+	baggage := middleware.ParseBaggage("user_id=123, tenant=acme;prop=1,invalid,=empty,name=John%20Doe")
+
+	assert.Equal(t, middleware.Baggage{
+		"user_id": "123",
+		"tenant":  "acme",
+		"name":    "John Doe",
+	}, baggage)
+
+	assert.Equal(t, "name=John%20Doe,tenant=acme,user_id=123", baggage.String())
+}
+
+func TestSetBaggageEntry(t *testing.T) {
+	msg := message.NewMessage("1", nil)
+
+	middleware.SetBaggageEntry(msg, "user_id", "123")
+	middleware.SetBaggageEntry(msg, "tenant", "acme, inc.")
+
+	assert.Equal(t, "tenant=acme%2C%20inc.,user_id=123", msg.Metadata.Get(middleware.BaggageMetadataKey))
+	assert.Equal(t, middleware.Baggage{"user_id": "123", "tenant": "acme, inc."}, middleware.MessageBaggage(msg))
+
+	middleware.SetBaggage(msg, nil)
+	_, ok := msg.Metadata[middleware.BaggageMetadataKey]
+	assert.False(t, ok)
+}
+
+func TestPropagateBaggage(t *testing.T) {
+	received := message.NewMessage("1", nil)
+	middleware.SetBaggageEntry(received, "user_id", "123")
+	middleware.SetBaggageEntry(received, "tenant", "acme")
+
+	pub := &publisherMock{}
+	decoratedPub, err := middleware.BaggagePublisherDecorator()(pub)
+	require.NoError(t, err)
+
+	h := middleware.PropagateBaggage(func(msg *message.Message) ([]*message.Message, error) {
+		assert.Equal(t, middleware.Baggage{"user_id": "123", "tenant": "acme"}, middleware.BaggageFromContext(msg.Context()))
+
+		published := message.NewMessage("2", nil)
+		published.SetContext(msg.Context())
+		if err := decoratedPub.Publish("topic", published); err != nil {
+			return nil, err
+		}
+
+		produced := message.NewMessage("3", nil)
+		middleware.SetBaggageEntry(produced, "tenant", "other")
+
+		return []*message.Message{produced}, nil
+	})
+
+	producedMessages, err := h(received)
+	require.NoError(t, err)
+
+	require.Len(t, pub.messages, 1)
+	assert.Equal(t, middleware.Baggage{"user_id": "123", "tenant": "acme"}, middleware.MessageBaggage(pub.messages[0]))
+
+	require.Len(t, producedMessages, 1)
+	assert.Equal(t, middleware.Baggage{"user_id": "123", "tenant": "other"}, middleware.MessageBaggage(producedMessages[0]))
+}
+
+func TestBaggagePublisherDecorator_without_baggage(t *testing.T) {
+	pub := &publisherMock{}
+	decoratedPub, err := middleware.BaggagePublisherDecorator()(pub)
+	require.NoError(t, err)
+
+	msg := message.NewMessage("1", nil)
+	msg.SetContext(context.Background())
+	require.NoError(t, decoratedPub.Publish("topic", msg))
+
+	_, ok := msg.Metadata[middleware.BaggageMetadataKey]
+	assert.False(t, ok)
+}
+
+type publisherMock struct {
+	messages []*message.Message
+}
+
+func (p *publisherMock) Publish(topic string, messages ...*message.Message) error {
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (p *publisherMock) Close() error {
+	return nil
+}
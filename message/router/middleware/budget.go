@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys of the processing budget consumed by the message and the messages which caused it.
+const (
+	BudgetElapsedMetadataKey = "_watermill_budget_elapsed"
+	BudgetCostMetadataKey    = "_watermill_budget_cost"
+
+	// BudgetExceededMetadataKey is set on messages rerouted to CostBudgetConfig.ExceededTopic,
+	// with BudgetExceededElapsed or BudgetExceededCost value.
+	BudgetExceededMetadataKey = "_watermill_budget_exceeded"
+)
+
+const (
+	BudgetExceededElapsed = "elapsed"
+	BudgetExceededCost    = "cost"
+)
+
+// Budget is the cumulative processing cost of the message, summed over all handlers
+// which handled the message or the messages which caused it (possibly in different services).
+type Budget struct {
+	// Elapsed is the total time of handling.
+	Elapsed time.Duration
+
+	// Cost is the total of the user-supplied cost units, see AddBudgetCost.
+	Cost float64
+}
+
+// MessageBudget returns the budget consumed by the message, stored in the metadata.
+// Invalid values are treated as 0.
+func MessageBudget(msg *message.Message) Budget {
+	var budget Budget
+
+	if elapsed, err := time.ParseDuration(msg.Metadata.Get(BudgetElapsedMetadataKey)); err == nil {
+		budget.Elapsed = elapsed
+	}
+	if cost, err := strconv.ParseFloat(msg.Metadata.Get(BudgetCostMetadataKey), 64); err == nil {
+		budget.Cost = cost
+	}
+
+	return budget
+}
+
+// SetMessageBudget sets the budget consumed by the message in the metadata.
+func SetMessageBudget(msg *message.Message, budget Budget) {
+	msg.Metadata.Set(BudgetElapsedMetadataKey, budget.Elapsed.String())
+	msg.Metadata.Set(BudgetCostMetadataKey, strconv.FormatFloat(budget.Cost, 'g', -1, 64))
+}
+
+type budgetCtxKey struct{}
+
+// budgetTracker tracks the budget consumed by the message being handled.
+type budgetTracker struct {
+	consumed Budget
+	start    time.Time
+	clock    watermill.Clock
+
+	cost     float64
+	costLock sync.Mutex
+}
+
+func (t *budgetTracker) AddCost(units float64) {
+	t.costLock.Lock()
+	defer t.costLock.Unlock()
+
+	t.cost += units
+}
+
+func (t *budgetTracker) Budget() Budget {
+	t.costLock.Lock()
+	defer t.costLock.Unlock()
+
+	return Budget{
+		Elapsed: t.consumed.Elapsed + t.clock.Now().Sub(t.start),
+		Cost:    t.consumed.Cost + t.cost,
+	}
+}
+
+// AddBudgetCost adds the cost units to the budget of the message handled with the CostBudget middleware.
+// ctx must be the context of the handled message (or derived from it). Otherwise, AddBudgetCost does nothing.
+//
+// The units are defined by the application, for example, the number of external API calls.
+func AddBudgetCost(ctx context.Context, units float64) {
+	if tracker, ok := ctx.Value(budgetCtxKey{}).(*budgetTracker); ok {
+		tracker.AddCost(units)
+	}
+}
+
+// BudgetFromContext returns the budget consumed so far by the message handled with the CostBudget middleware,
+// including the time of the current handling.
+func BudgetFromContext(ctx context.Context) (Budget, bool) {
+	tracker, ok := ctx.Value(budgetCtxKey{}).(*budgetTracker)
+	if !ok {
+		return Budget{}, false
+	}
+
+	return tracker.Budget(), true
+}
+
+// CostBudgetConfig configures the CostBudget middleware.
+type CostBudgetConfig struct {
+	// MaxElapsed is the maximum total time of handling the message and the messages which caused it.
+	// If 0, the elapsed time is recorded, but not enforced.
+	MaxElapsed time.Duration
+
+	// MaxCost is the maximum total of the cost units (see AddBudgetCost).
+	// If 0, the cost is recorded, but not enforced.
+	MaxCost float64
+
+	// ExceededTopic if not empty is the topic to which the messages exceeding the budget are published
+	// with Publisher, instead of being dropped.
+	ExceededTopic string
+
+	// Publisher is used to publish the messages to ExceededTopic. It is required if ExceededTopic is set.
+	Publisher message.Publisher
+
+	// OnExceeded is an optional function called for every message exceeding the budget,
+	// for example, to count them.
+	OnExceeded func(msg *message.Message, budget Budget)
+
+	// Clock is used to measure the time of handling.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *CostBudgetConfig) setDefaults() {
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c CostBudgetConfig) Validate() error {
+	if c.MaxElapsed < 0 {
+		return errors.New("MaxElapsed must not be negative")
+	}
+	if c.MaxCost < 0 {
+		return errors.New("MaxCost must not be negative")
+	}
+	if c.ExceededTopic != "" && c.Publisher == nil {
+		return errors.New("Publisher is required when ExceededTopic is set")
+	}
+
+	return nil
+}
+
+// CostBudget records the cumulative processing cost of messages in their metadata, and enforces the total budget.
+//
+// The budget consumed by the handled message is carried over to the messages produced by the handler,
+// so it's accumulated as the messages traverse the services. When a received message already exceeded the budget,
+// it's dropped (acked without handling) or rerouted to CostBudgetConfig.ExceededTopic.
+// It curbs runaway reprocessing loops, for example, two services reacting to each other's events.
+//
+// Messages published within the handler with a publisher of your own carry the budget if the publisher is decorated
+// with BudgetPublisherDecorator.
+type CostBudget struct {
+	config CostBudgetConfig
+}
+
+// NewCostBudget creates a new CostBudget middleware.
+func NewCostBudget(config CostBudgetConfig) (*CostBudget, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &CostBudget{config: config}, nil
+}
+
+// Middleware returns the CostBudget middleware.
+func (b *CostBudget) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		consumed := MessageBudget(msg)
+
+		if reason, exceeded := b.exceeded(consumed); exceeded {
+			return nil, b.handleExceeded(msg, consumed, reason)
+		}
+
+		tracker := &budgetTracker{
+			consumed: consumed,
+			start:    b.config.Clock.Now(),
+			clock:    b.config.Clock,
+		}
+		msg.SetContext(context.WithValue(msg.Context(), budgetCtxKey{}, tracker))
+
+		producedMessages, err := h(msg)
+
+		budget := tracker.Budget()
+		for _, producedMsg := range producedMessages {
+			SetMessageBudget(producedMsg, budget)
+		}
+
+		return producedMessages, err
+	}
+}
+
+func (b *CostBudget) exceeded(budget Budget) (string, bool) {
+	if b.config.MaxElapsed > 0 && budget.Elapsed >= b.config.MaxElapsed {
+		return BudgetExceededElapsed, true
+	}
+	if b.config.MaxCost > 0 && budget.Cost >= b.config.MaxCost {
+		return BudgetExceededCost, true
+	}
+
+	return "", false
+}
+
+func (b *CostBudget) handleExceeded(msg *message.Message, budget Budget, reason string) error {
+	logFields := watermill.LogFields{
+		"message_uuid":    msg.UUID,
+		"budget_exceeded": reason,
+		"budget_elapsed":  budget.Elapsed,
+		"budget_cost":     budget.Cost,
+	}
+
+	if b.config.OnExceeded != nil {
+		b.config.OnExceeded(msg, budget)
+	}
+
+	if b.config.ExceededTopic == "" {
+		b.config.Logger.Info("Dropping message exceeding the budget", logFields)
+		return nil
+	}
+
+	b.config.Logger.Info("Rerouting message exceeding the budget", logFields.Add(watermill.LogFields{
+		"topic": b.config.ExceededTopic,
+	}))
+
+	msg.Metadata.Set(BudgetExceededMetadataKey, reason)
+
+	if err := b.config.Publisher.Publish(b.config.ExceededTopic, msg); err != nil {
+		return errors.Wrap(err, "cannot publish message exceeding the budget")
+	}
+
+	return nil
+}
+
+// BudgetPublisherDecorator sets the budget consumed so far on the published messages,
+// if they are published within a handler with the CostBudget middleware.
+//
+// Messages published within a handler must have the context of the received message
+// (for example, msg.SetContext(receivedMsg.Context())).
+func BudgetPublisherDecorator() message.PublisherDecorator {
+	return message.MessageTransformPublisherDecorator(func(msg *message.Message) {
+		if budget, ok := BudgetFromContext(msg.Context()); ok {
+			SetMessageBudget(msg, budget)
+		}
+	})
+}
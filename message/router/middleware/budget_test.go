@@ -0,0 +1,169 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+type capturingPublisher struct {
+	published map[string][]*message.Message
+}
+
+func (p *capturingPublisher) Publish(topic string, messages ...*message.Message) error {
+	if p.published == nil {
+		p.published = map[string][]*message.Message{}
+	}
+	p.published[topic] = append(p.published[topic], messages...)
+	return nil
+}
+
+func (p *capturingPublisher) Close() error {
+	return nil
+}
+
+func TestCostBudget_accumulates_budget(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	budget, err := middleware.NewCostBudget(middleware.CostBudgetConfig{
+		MaxElapsed: time.Minute,
+		MaxCost:    100,
+		Clock:      clock,
+	})
+	require.NoError(t, err)
+
+	h := budget.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		clock.Advance(time.Millisecond * 500)
+		middleware.AddBudgetCost(msg.Context(), 2)
+		middleware.AddBudgetCost(msg.Context(), 1.5)
+
+		consumed, ok := middleware.BudgetFromContext(msg.Context())
+		require.True(t, ok)
+		assert.Equal(t, middleware.Budget{Elapsed: time.Millisecond * 1500, Cost: 4.5}, consumed)
+
+		return []*message.Message{message.NewMessage("produced", nil)}, nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	middleware.SetMessageBudget(msg, middleware.Budget{Elapsed: time.Second, Cost: 1})
+
+	produced, err := h(msg)
+	require.NoError(t, err)
+	require.Len(t, produced, 1)
+
+	assert.Equal(t, middleware.Budget{Elapsed: time.Millisecond * 1500, Cost: 4.5}, middleware.MessageBudget(produced[0]))
+}
+
+func TestCostBudget_drops_exceeded(t *testing.T) {
+	var exceeded []middleware.Budget
+
+	budget, err := middleware.NewCostBudget(middleware.CostBudgetConfig{
+		MaxElapsed: time.Second,
+		OnExceeded: func(msg *message.Message, budget middleware.Budget) {
+			exceeded = append(exceeded, budget)
+		},
+	})
+	require.NoError(t, err)
+
+	var handled []string
+	h := budget.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled = append(handled, msg.UUID)
+		return nil, nil
+	})
+
+	withinBudget := message.NewMessage("within", nil)
+	middleware.SetMessageBudget(withinBudget, middleware.Budget{Elapsed: time.Millisecond * 500})
+
+	exceededMsg := message.NewMessage("exceeded", nil)
+	middleware.SetMessageBudget(exceededMsg, middleware.Budget{Elapsed: time.Second, Cost: 1000})
+
+	noBudget := message.NewMessage("no_budget", nil)
+
+	for _, msg := range []*message.Message{withinBudget, exceededMsg, noBudget} {
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"within", "no_budget"}, handled)
+	assert.Equal(t, []middleware.Budget{{Elapsed: time.Second, Cost: 1000}}, exceeded)
+}
+
+func TestCostBudget_reroutes_exceeded(t *testing.T) {
+	publisher := &capturingPublisher{}
+
+	budget, err := middleware.NewCostBudget(middleware.CostBudgetConfig{
+		MaxCost:       5,
+		ExceededTopic: "budget_exceeded",
+		Publisher:     publisher,
+	})
+	require.NoError(t, err)
+
+	h := budget.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		t.Fatal("message exceeding the budget should not be handled")
+		return nil, nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	middleware.SetMessageBudget(msg, middleware.Budget{Cost: 5})
+
+	_, err = h(msg)
+	require.NoError(t, err)
+
+	require.Len(t, publisher.published["budget_exceeded"], 1)
+	rerouted := publisher.published["budget_exceeded"][0]
+	assert.Equal(t, "1", rerouted.UUID)
+	assert.Equal(t, middleware.BudgetExceededCost, rerouted.Metadata.Get(middleware.BudgetExceededMetadataKey))
+}
+
+func TestBudgetPublisherDecorator(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+	publisher := &capturingPublisher{}
+
+	decorated, err := middleware.BudgetPublisherDecorator()(publisher)
+	require.NoError(t, err)
+
+	budget, err := middleware.NewCostBudget(middleware.CostBudgetConfig{Clock: clock})
+	require.NoError(t, err)
+
+	h := budget.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		clock.Advance(time.Second)
+		middleware.AddBudgetCost(msg.Context(), 1)
+
+		published := message.NewMessage("published", nil)
+		published.SetContext(msg.Context())
+
+		return nil, decorated.Publish("topic", published)
+	})
+
+	_, err = h(message.NewMessage("1", nil))
+	require.NoError(t, err)
+
+	require.Len(t, publisher.published["topic"], 1)
+	assert.Equal(t, middleware.Budget{Elapsed: time.Second, Cost: 1}, middleware.MessageBudget(publisher.published["topic"][0]))
+
+	// messages published outside of handlers are not modified
+	require.NoError(t, decorated.Publish("topic", message.NewMessage("outside", nil)))
+	assert.Empty(t, publisher.published["topic"][1].Metadata.Get(middleware.BudgetElapsedMetadataKey))
+}
+
+func TestAddBudgetCost_without_middleware(t *testing.T) {
+	middleware.AddBudgetCost(context.Background(), 1)
+
+	_, ok := middleware.BudgetFromContext(context.Background())
+	assert.False(t, ok)
+}
+
+func TestNewCostBudget_invalid_config(t *testing.T) {
+	_, err := middleware.NewCostBudget(middleware.CostBudgetConfig{ExceededTopic: "exceeded"})
+	assert.ErrorContains(t, err, "Publisher is required when ExceededTopic is set")
+
+	_, err = middleware.NewCostBudget(middleware.CostBudgetConfig{MaxCost: -1})
+	assert.ErrorContains(t, err, "MaxCost must not be negative")
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ErrChaos is returned by the handlers failed by Chaos.
+var ErrChaos = errors.New("chaos: injected failure")
+
+// Chaos injects failures into the router, to test the resilience of the handlers
+// (retries, idempotency, timeouts) against the real router wiring.
+//
+// Every failure is injected with its own probability, from 0 (never) to 1 (always).
+// Use Middleware for handler failures and PublisherDecorator for dropped publishes:
+//
+//	chaos := middleware.Chaos{ErrorProbability: 0.1, DuplicateProbability: 0.05}
+//	router.AddMiddleware(chaos.Middleware)
+//	router.AddPublisherDecorators(chaos.PublisherDecorator())
+//
+// It should never be enabled in production.
+type Chaos struct {
+	// ErrorProbability is the probability of failing the handler with ErrChaos, without calling it.
+	ErrorProbability float64
+
+	// AckDelayProbability is the probability of delaying the ack (or nack) of the message by AckDelay,
+	// after the handler returns.
+	AckDelayProbability float64
+	AckDelay            time.Duration
+
+	// DuplicateProbability is the probability of calling the handler twice with the same message,
+	// like the transport redelivered it. Messages produced by both calls are returned.
+	DuplicateProbability float64
+
+	// DropPublishProbability is the probability of dropping the published message by PublisherDecorator,
+	// without returning an error, like it was lost by the broker.
+	DropPublishProbability float64
+
+	// Topics limits the failures to the topics. For Middleware, the topic is the handler's subscribe topic.
+	// If empty, failures are injected for all topics.
+	Topics []string
+
+	// Random returns a random number in [0, 1). It can be replaced for deterministic tests.
+	// If not provided, rand.Float64 is used.
+	Random func() float64
+
+	Logger watermill.LoggerAdapter
+}
+
+func (c Chaos) withDefaults() Chaos {
+	if c.Random == nil {
+		c.Random = rand.Float64
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+	return c
+}
+
+func (c Chaos) Validate() error {
+	probabilities := []struct {
+		name  string
+		value float64
+	}{
+		{"ErrorProbability", c.ErrorProbability},
+		{"AckDelayProbability", c.AckDelayProbability},
+		{"DuplicateProbability", c.DuplicateProbability},
+		{"DropPublishProbability", c.DropPublishProbability},
+	}
+	for _, p := range probabilities {
+		if p.value < 0 || p.value > 1 {
+			return errors.Errorf("%s must be between 0 and 1, got %v", p.name, p.value)
+		}
+	}
+
+	if c.AckDelayProbability > 0 && c.AckDelay <= 0 {
+		return errors.New("AckDelay must be positive when AckDelayProbability is set")
+	}
+
+	return nil
+}
+
+func (c Chaos) appliesToTopic(topic string) bool {
+	if len(c.Topics) == 0 {
+		return true
+	}
+
+	for _, t := range c.Topics {
+		if t == topic {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c Chaos) happens(probability float64) bool {
+	return probability > 0 && c.Random() < probability
+}
+
+// Middleware returns the Chaos middleware, injecting handler errors, delayed acks, and duplicated handler calls.
+// It panics if the config is invalid.
+func (c Chaos) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	if err := c.Validate(); err != nil {
+		panic(errors.Wrap(err, "invalid Chaos config"))
+	}
+	c = c.withDefaults()
+
+	return func(msg *message.Message) ([]*message.Message, error) {
+		if !c.appliesToTopic(message.SubscribeTopicFromCtx(msg.Context())) {
+			return h(msg)
+		}
+
+		fields := watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"handler_name": message.HandlerNameFromCtx(msg.Context()),
+		}
+
+		if c.happens(c.ErrorProbability) {
+			c.Logger.Info("Chaos: failing handler", fields)
+			return nil, ErrChaos
+		}
+
+		producedMessages, err := h(msg)
+
+		if err == nil && c.happens(c.DuplicateProbability) {
+			c.Logger.Info("Chaos: calling handler again", fields)
+
+			var duplicatedMessages []*message.Message
+			duplicatedMessages, err = h(msg)
+			producedMessages = append(producedMessages, duplicatedMessages...)
+		}
+
+		if c.happens(c.AckDelayProbability) {
+			c.Logger.Info("Chaos: delaying ack", fields.Add(watermill.LogFields{"delay": c.AckDelay}))
+
+			select {
+			case <-time.After(c.AckDelay):
+			case <-msg.Context().Done():
+			}
+		}
+
+		return producedMessages, err
+	}
+}
+
+// PublisherDecorator returns the Chaos publisher decorator, dropping published messages.
+func (c Chaos) PublisherDecorator() message.PublisherDecorator {
+	return func(pub message.Publisher) (message.Publisher, error) {
+		if err := c.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid Chaos config")
+		}
+
+		return chaosPublisher{
+			Publisher: pub,
+			chaos:     c.withDefaults(),
+		}, nil
+	}
+}
+
+type chaosPublisher struct {
+	message.Publisher
+	chaos Chaos
+}
+
+func (p chaosPublisher) Publish(topic string, messages ...*message.Message) error {
+	if !p.chaos.appliesToTopic(topic) {
+		return p.Publisher.Publish(topic, messages...)
+	}
+
+	published := make([]*message.Message, 0, len(messages))
+	for _, msg := range messages {
+		if p.chaos.happens(p.chaos.DropPublishProbability) {
+			p.chaos.Logger.Info("Chaos: dropping published message", watermill.LogFields{
+				"message_uuid": msg.UUID,
+				"topic":        topic,
+			})
+			continue
+		}
+
+		published = append(published, msg)
+	}
+
+	if len(published) == 0 {
+		return nil
+	}
+
+	return p.Publisher.Publish(topic, published...)
+}
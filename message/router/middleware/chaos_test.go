@@ -0,0 +1,171 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestChaos_error(t *testing.T) {
+	called := 0
+
+	h := middleware.Chaos{ErrorProbability: 1}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		called++
+		return nil, nil
+	})
+
+	_, err := h(message.NewMessage("1", nil))
+	assert.ErrorIs(t, err, middleware.ErrChaos)
+	assert.Equal(t, 0, called)
+}
+
+func TestChaos_duplicate(t *testing.T) {
+	called := 0
+
+	h := middleware.Chaos{DuplicateProbability: 1}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		called++
+		return []*message.Message{message.NewMessage(watermill.NewUUID(), nil)}, nil
+	})
+
+	produced, err := h(message.NewMessage("1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 2, called)
+	assert.Len(t, produced, 2)
+}
+
+func TestChaos_ack_delay(t *testing.T) {
+	h := middleware.Chaos{
+		AckDelayProbability: 1,
+		AckDelay:            time.Millisecond * 50,
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	start := time.Now()
+	_, err := h(message.NewMessage("1", nil))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*50)
+}
+
+func TestChaos_probability(t *testing.T) {
+	random := []float64{0.05, 0.5}
+
+	h := middleware.Chaos{
+		ErrorProbability: 0.1,
+		Random: func() float64 {
+			r := random[0]
+			random = random[1:]
+			return r
+		},
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	_, err := h(message.NewMessage("1", nil))
+	assert.ErrorIs(t, err, middleware.ErrChaos)
+
+	_, err = h(message.NewMessage("2", nil))
+	assert.NoError(t, err)
+}
+
+func TestChaos_invalid_config(t *testing.T) {
+	assert.Error(t, middleware.Chaos{ErrorProbability: 1.5}.Validate())
+	assert.Error(t, middleware.Chaos{AckDelayProbability: 0.5}.Validate())
+
+	assert.Panics(t, func() {
+		middleware.Chaos{DuplicateProbability: -1}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+			return nil, nil
+		})
+	})
+
+	_, err := middleware.Chaos{DropPublishProbability: 2}.PublisherDecorator()(gochannel.NewGoChannel(gochannel.Config{}, nil))
+	assert.Error(t, err)
+}
+
+func TestChaos_drop_publish(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+
+	pub, err := middleware.Chaos{
+		DropPublishProbability: 1,
+		Topics:                 []string{"dropped"},
+	}.PublisherDecorator()(pubSub)
+	require.NoError(t, err)
+
+	require.NoError(t, pub.Publish("dropped", message.NewMessage("1", nil)))
+	require.NoError(t, pub.Publish("kept", message.NewMessage("2", nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dropped, err := pubSub.Subscribe(ctx, "dropped")
+	require.NoError(t, err)
+	kept, err := pubSub.Subscribe(ctx, "kept")
+	require.NoError(t, err)
+
+	select {
+	case msg := <-kept:
+		assert.Equal(t, "2", msg.UUID)
+		msg.Ack()
+	case <-time.After(time.Second):
+		t.Fatal("message was not published")
+	}
+
+	select {
+	case msg := <-dropped:
+		t.Fatalf("message %s should be dropped", msg.UUID)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
+
+func TestChaos_topics(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddMiddleware(middleware.Chaos{
+		ErrorProbability: 1,
+		Topics:           []string{"failing"},
+	}.Middleware)
+
+	handled := make(chan string, 1)
+	for _, topic := range []string{"failing", "working"} {
+		topic := topic
+		router.AddNoPublisherHandler(topic, topic, pubSub, func(msg *message.Message) error {
+			handled <- topic
+			return nil
+		})
+	}
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	require.NoError(t, pubSub.Publish("failing", message.NewMessage(watermill.NewUUID(), nil)))
+	require.NoError(t, pubSub.Publish("working", message.NewMessage(watermill.NewUUID(), nil)))
+
+	select {
+	case topic := <-handled:
+		assert.Equal(t, "working", topic)
+	case <-time.After(time.Second):
+		t.Fatal("message not handled")
+	}
+
+	select {
+	case topic := <-handled:
+		t.Fatalf("handler of %s topic should not be called", topic)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ReplayablePublisher is a fallback publisher which keeps the messages, so they can be replayed
+// to the primary publisher when it recovers (for example, FileSpool).
+type ReplayablePublisher interface {
+	message.Publisher
+
+	// Replay calls publish with the kept messages of the topic, in the order they were published.
+	// Messages are removed when publish succeeds. When publish fails, Replay stops
+	// and returns the error, keeping the rest of the messages.
+	Replay(topic string, publish func(msg *message.Message) error) error
+}
+
+// CircuitBreakerPublisherConfig configures CircuitBreakerPublisherDecorator.
+type CircuitBreakerPublisherConfig struct {
+	// Settings are the settings of the circuit breaker of every topic.
+	// Name is set to the topic. OnStateChange, if set, is called after the decorator handles the change.
+	// Refer to the gobreaker documentation for the available settings.
+	Settings gobreaker.Settings
+
+	// Fallback receives the messages when the circuit of the topic is open (or the primary publisher fails).
+	// If it's a ReplayablePublisher, the messages are replayed to the primary publisher when the circuit closes.
+	//
+	// If not provided, publishing fails with gobreaker.ErrOpenState while the circuit is open.
+	Fallback message.Publisher
+
+	Logger watermill.LoggerAdapter
+}
+
+func (c *CircuitBreakerPublisherConfig) setDefaults() {
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// CircuitBreakerPublisherDecorator creates a publisher decorator that tracks publish failures per topic.
+// When the publisher keeps failing for a topic, the circuit of the topic opens, and messages are published
+// to the fallback instead, without calling the primary publisher, until the circuit's timeout passes.
+//
+// When the circuit closes again (and when the first message is published to the topic, as messages may be kept
+// from before the restart), messages kept by a ReplayablePublisher fallback are replayed in the background.
+// Keep in mind that new messages are published to the primary publisher during replaying,
+// so the order of messages is not preserved.
+// The circuit is checked on publishing, so if nothing is published to the topic, the replay is delayed until it is.
+func CircuitBreakerPublisherDecorator(config CircuitBreakerPublisherConfig) message.PublisherDecorator {
+	config.setDefaults()
+
+	return func(pub message.Publisher) (message.Publisher, error) {
+		return &circuitBreakerPublisher{
+			Publisher: pub,
+			config:    config,
+			breakers:  map[string]*gobreaker.CircuitBreaker{},
+			replaying: map[string]bool{},
+		}, nil
+	}
+}
+
+type circuitBreakerPublisher struct {
+	message.Publisher
+	config CircuitBreakerPublisherConfig
+
+	breakers     map[string]*gobreaker.CircuitBreaker
+	breakersLock sync.Mutex
+
+	replaying     map[string]bool
+	replayingLock sync.Mutex
+	replayWg      sync.WaitGroup
+}
+
+func (p *circuitBreakerPublisher) Publish(topic string, messages ...*message.Message) error {
+	err := p.publishPrimary(topic, messages...)
+	if err == nil {
+		return nil
+	}
+
+	if p.config.Fallback == nil {
+		return err
+	}
+
+	p.config.Logger.Debug("Publishing to fallback", watermill.LogFields{
+		"topic": topic,
+		"err":   err,
+	})
+
+	if fallbackErr := p.config.Fallback.Publish(topic, messages...); fallbackErr != nil {
+		return errors.Wrapf(fallbackErr, "cannot publish to fallback after primary publisher failure: %s", err)
+	}
+
+	return nil
+}
+
+func (p *circuitBreakerPublisher) publishPrimary(topic string, messages ...*message.Message) error {
+	_, err := p.breaker(topic).Execute(func() (interface{}, error) {
+		return nil, p.Publisher.Publish(topic, messages...)
+	})
+
+	return err
+}
+
+func (p *circuitBreakerPublisher) breaker(topic string) *gobreaker.CircuitBreaker {
+	p.breakersLock.Lock()
+	defer p.breakersLock.Unlock()
+
+	if cb, ok := p.breakers[topic]; ok {
+		return cb
+	}
+
+	settings := p.config.Settings
+	settings.Name = topic
+	settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		p.config.Logger.Info("Publisher circuit state changed", watermill.LogFields{
+			"topic": name,
+			"from":  from.String(),
+			"to":    to.String(),
+		})
+
+		if to == gobreaker.StateClosed {
+			// it's called with the circuit breaker locked, so it can't be used here
+			p.startReplay(name)
+		}
+
+		if p.config.Settings.OnStateChange != nil {
+			p.config.Settings.OnStateChange(name, from, to)
+		}
+	}
+
+	cb := gobreaker.NewCircuitBreaker(settings)
+	p.breakers[topic] = cb
+
+	// messages may be kept from before the restart
+	p.startReplay(topic)
+
+	return cb
+}
+
+func (p *circuitBreakerPublisher) startReplay(topic string) {
+	replayable, ok := p.config.Fallback.(ReplayablePublisher)
+	if !ok {
+		return
+	}
+
+	p.replayingLock.Lock()
+	defer p.replayingLock.Unlock()
+
+	if p.replaying[topic] {
+		return
+	}
+	p.replaying[topic] = true
+
+	p.replayWg.Add(1)
+	go func() {
+		defer p.replayWg.Done()
+		defer func() {
+			p.replayingLock.Lock()
+			delete(p.replaying, topic)
+			p.replayingLock.Unlock()
+		}()
+
+		err := replayable.Replay(topic, func(msg *message.Message) error {
+			return p.publishPrimary(topic, msg)
+		})
+		if err != nil {
+			p.config.Logger.Error("Cannot replay messages from fallback", err, watermill.LogFields{
+				"topic": topic,
+			})
+			return
+		}
+
+		p.config.Logger.Debug("Replayed messages from fallback", watermill.LogFields{
+			"topic": topic,
+		})
+	}()
+}
+
+// Close waits for the running replays and closes the primary publisher.
+// The fallback is not closed, as it may be shared.
+func (p *circuitBreakerPublisher) Close() error {
+	p.replayWg.Wait()
+	return p.Publisher.Close()
+}
@@ -0,0 +1,172 @@
+package middleware_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+type recordingPublisher struct {
+	fail      bool
+	calls     int
+	published map[string][]string
+	lock      sync.Mutex
+}
+
+func newRecordingPublisher() *recordingPublisher {
+	return &recordingPublisher{published: map[string][]string{}}
+}
+
+func (p *recordingPublisher) setFail(fail bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.fail = fail
+}
+
+func (p *recordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.calls++
+	if p.fail {
+		return errors.New("broker unavailable")
+	}
+
+	for _, msg := range messages {
+		p.published[topic] = append(p.published[topic], msg.UUID)
+	}
+	return nil
+}
+
+func (p *recordingPublisher) Published(topic string) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string(nil), p.published[topic]...)
+}
+
+func (p *recordingPublisher) Calls() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.calls
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+var testPublisherBreakerSettings = gobreaker.Settings{
+	MaxRequests: 1,
+	Timeout:     time.Millisecond * 50,
+	ReadyToTrip: func(counts gobreaker.Counts) bool {
+		return counts.ConsecutiveFailures >= 2
+	},
+}
+
+func TestCircuitBreakerPublisherDecorator_fallback_and_replay(t *testing.T) {
+	primary := newRecordingPublisher()
+
+	spool, err := middleware.NewFileSpool(t.TempDir())
+	require.NoError(t, err)
+
+	var stateChanges []gobreaker.State
+	stateChangesLock := sync.Mutex{}
+
+	settings := testPublisherBreakerSettings
+	settings.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		assert.Equal(t, "orders", name)
+
+		stateChangesLock.Lock()
+		defer stateChangesLock.Unlock()
+		stateChanges = append(stateChanges, to)
+	}
+
+	pub, err := middleware.CircuitBreakerPublisherDecorator(middleware.CircuitBreakerPublisherConfig{
+		Settings: settings,
+		Fallback: spool,
+	})(primary)
+	require.NoError(t, err)
+
+	primary.setFail(true)
+
+	for _, uuid := range []string{"1", "2", "3"} {
+		require.NoError(t, pub.Publish("orders", message.NewMessage(uuid, nil)))
+	}
+
+	// the circuit opened after two failures, so the third message was not published to the primary publisher
+	assert.Equal(t, 2, primary.Calls())
+
+	count, err := spool.Len("orders")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	primary.setFail(false)
+	time.Sleep(testPublisherBreakerSettings.Timeout * 2)
+
+	require.NoError(t, pub.Publish("orders", message.NewMessage("4", nil)))
+
+	assert.Eventually(t, func() bool {
+		return len(primary.Published("orders")) == 4
+	}, time.Second, time.Millisecond*10)
+
+	require.NoError(t, pub.Close())
+
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, primary.Published("orders"))
+
+	count, err = spool.Len("orders")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	stateChangesLock.Lock()
+	defer stateChangesLock.Unlock()
+	assert.Equal(t, []gobreaker.State{gobreaker.StateOpen, gobreaker.StateHalfOpen, gobreaker.StateClosed}, stateChanges)
+}
+
+func TestCircuitBreakerPublisherDecorator_per_topic(t *testing.T) {
+	primary := newRecordingPublisher()
+	fallback := newRecordingPublisher()
+
+	pub, err := middleware.CircuitBreakerPublisherDecorator(middleware.CircuitBreakerPublisherConfig{
+		Settings: testPublisherBreakerSettings,
+		Fallback: fallback,
+	})(primary)
+	require.NoError(t, err)
+
+	primary.setFail(true)
+	require.NoError(t, pub.Publish("orders", message.NewMessage("1", nil)))
+	require.NoError(t, pub.Publish("orders", message.NewMessage("2", nil)))
+
+	primary.setFail(false)
+	require.NoError(t, pub.Publish("orders", message.NewMessage("3", nil)))
+	require.NoError(t, pub.Publish("payments", message.NewMessage("4", nil)))
+
+	assert.Equal(t, []string{"1", "2", "3"}, fallback.Published("orders"))
+	assert.Equal(t, []string{"4"}, primary.Published("payments"))
+	assert.Empty(t, primary.Published("orders"))
+}
+
+func TestCircuitBreakerPublisherDecorator_without_fallback(t *testing.T) {
+	primary := newRecordingPublisher()
+
+	pub, err := middleware.CircuitBreakerPublisherDecorator(middleware.CircuitBreakerPublisherConfig{
+		Settings: testPublisherBreakerSettings,
+	})(primary)
+	require.NoError(t, err)
+
+	primary.setFail(true)
+	assert.Error(t, pub.Publish("orders", message.NewMessage("1", nil)))
+	assert.Error(t, pub.Publish("orders", message.NewMessage("2", nil)))
+
+	err = pub.Publish("orders", message.NewMessage("3", nil))
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Names of the middlewares used in message.MiddlewareConstraints.
+const (
+	RetryMiddlewareName         = "Retry"
+	DeduplicatorMiddlewareName  = "Deduplicator"
+	CorrelationIDMiddlewareName = "CorrelationID"
+)
+
+// ConstrainedCorrelationID is CorrelationID with message.MiddlewareConstraints,
+// so other middlewares can require it. Add it with Router.AddConstrainedMiddleware.
+var ConstrainedCorrelationID = message.NewConstrainedMiddleware(CorrelationID, message.MiddlewareConstraints{
+	Name: CorrelationIDMiddlewareName,
+})
+
+// MiddlewareConstraints implements message.ConstrainedMiddleware.
+func (r Retry) MiddlewareConstraints() message.MiddlewareConstraints {
+	return message.MiddlewareConstraints{Name: RetryMiddlewareName}
+}
+
+// MiddlewareConstraints implements message.ConstrainedMiddleware.
+func (p RetryPolicy) MiddlewareConstraints() message.MiddlewareConstraints {
+	return message.MiddlewareConstraints{Name: RetryMiddlewareName}
+}
+
+// MiddlewareConstraints implements message.ConstrainedMiddleware.
+//
+// Deduplicator must run before Retry, as retried messages would be dropped as duplicates otherwise.
+func (d *Deduplicator) MiddlewareConstraints() message.MiddlewareConstraints {
+	return message.MiddlewareConstraints{
+		Name:       DeduplicatorMiddlewareName,
+		RunsBefore: []string{RetryMiddlewareName},
+	}
+}
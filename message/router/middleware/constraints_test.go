@@ -0,0 +1,61 @@
+package middleware_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestMiddlewareConstraints_deduplicator_before_retry(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		Middlewares []message.ConstrainedMiddleware
+		ExpectedErr bool
+	}{
+		{
+			Name:        "valid",
+			Middlewares: []message.ConstrainedMiddleware{&middleware.Deduplicator{}, middleware.Retry{}},
+		},
+		{
+			Name:        "retry_policy",
+			Middlewares: []message.ConstrainedMiddleware{middleware.RetryPolicy{}, &middleware.Deduplicator{}},
+			ExpectedErr: true,
+		},
+		{
+			Name:        "misordered",
+			Middlewares: []message.ConstrainedMiddleware{middleware.Retry{}, &middleware.Deduplicator{}},
+			ExpectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+			require.NoError(t, err)
+
+			router.AddConstrainedMiddleware(tc.Middlewares...)
+			router.AddNoPublisherHandler(
+				"handler",
+				"topic",
+				gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+				func(msg *message.Message) error {
+					return nil
+				},
+			)
+
+			err = router.ValidateMiddlewares()
+			if tc.ExpectedErr {
+				assert.ErrorContains(t, err, "Deduplicator must run before Retry")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DuplicateDetector is a middleware that detects duplicate deliveries of messages, without dropping them (observe mode).
+// It's useful to measure how often the transport redelivers messages before enabling [Deduplicator].
+//
+// KeyFactory defaults to [MessageUUIDHasher], so redeliveries of the same message are detected.
+// Use [NewMessageHasherMetadata] to detect messages with the same business key,
+// or the same [MessageHasher] as the [Deduplicator] you plan to enable.
+//
+// Repository defaults to [NewMapExpiringKeyRepository] with one minute window,
+// so duplicates delivered to other instances are not detected.
+//
+// Errors of Repository are logged, and the message is handled anyway.
+//
+// Create it with NewDuplicateDetector, so the default Repository and the count of duplicates are created once,
+// and shared by all middlewares returned by Middleware. The router can build the handler's middlewares
+// more than once, and a DuplicateDetector literal creates them again for each build.
+type DuplicateDetector struct {
+	KeyFactory MessageHasher
+	Repository ExpiringKeyRepository
+
+	// Timeout is applied to repository operations. It defaults to one second.
+	Timeout time.Duration
+
+	// OnDuplicate is an optional function called for every duplicate,
+	// for example, to count them (see metrics.PrometheusMetricsBuilder.NewDuplicateDeliveriesCounter).
+	OnDuplicate func(msg *message.Message, key string)
+
+	// LogSampleEvery logs only every n-th duplicate. 0 or 1 logs every duplicate.
+	LogSampleEvery uint64
+
+	Logger watermill.LoggerAdapter
+
+	duplicates *atomic.Uint64
+}
+
+// NewDuplicateDetector returns the DuplicateDetector with the defaults and the state created once.
+func NewDuplicateDetector(d DuplicateDetector) *DuplicateDetector {
+	d = d.withDefaults()
+	return &d
+}
+
+func (d DuplicateDetector) withDefaults() DuplicateDetector {
+	if d.KeyFactory == nil {
+		d.KeyFactory = MessageUUIDHasher
+	}
+	if d.Repository == nil {
+		kr, err := NewMapExpiringKeyRepository(time.Minute)
+		if err != nil {
+			panic(err)
+		}
+		d.Repository = kr
+	}
+	if d.Timeout <= 0 {
+		d.Timeout = time.Second
+	}
+	if d.LogSampleEvery == 0 {
+		d.LogSampleEvery = 1
+	}
+	if d.Logger == nil {
+		d.Logger = watermill.NopLogger{}
+	}
+	if d.duplicates == nil {
+		d.duplicates = &atomic.Uint64{}
+	}
+	return d
+}
+
+// Middleware returns the DuplicateDetector middleware.
+func (d DuplicateDetector) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	d = d.withDefaults()
+
+	return func(msg *message.Message) ([]*message.Message, error) {
+		key, isDuplicate, err := d.isDuplicate(msg)
+		if err != nil {
+			d.Logger.Error("Cannot check if message is a duplicate", err, watermill.LogFields{
+				"message_uuid": msg.UUID,
+			})
+			return h(msg)
+		}
+
+		if isDuplicate {
+			if count := d.duplicates.Add(1); (count-1)%d.LogSampleEvery == 0 {
+				d.Logger.Info("Duplicate message delivery detected", watermill.LogFields{
+					"message_uuid":     msg.UUID,
+					"key":              key,
+					"handler_name":     message.HandlerNameFromCtx(msg.Context()),
+					"duplicates_total": count,
+				})
+			}
+			if d.OnDuplicate != nil {
+				d.OnDuplicate(msg, key)
+			}
+		}
+
+		return h(msg)
+	}
+}
+
+func (d DuplicateDetector) isDuplicate(msg *message.Message) (string, bool, error) {
+	key, err := d.KeyFactory(msg)
+	if err != nil {
+		return "", false, errors.Wrap(err, "cannot generate key")
+	}
+
+	ctx, cancel := context.WithTimeout(msg.Context(), d.Timeout)
+	defer cancel()
+
+	isDuplicate, err := d.Repository.IsDuplicate(ctx, key)
+	return key, isDuplicate, err
+}
+
+// MessageUUIDHasher uses the message's UUID as the key, so redeliveries of the same message are detected.
+func MessageUUIDHasher(m *message.Message) (string, error) {
+	if m.UUID == "" {
+		return "", errors.New("message has no UUID")
+	}
+	return m.UUID, nil
+}
+
+// NewMessageHasherMetadata uses the metadata value as the key, for example an idempotency key of the message.
+func NewMessageHasherMetadata(metadataKey string) MessageHasher {
+	return func(m *message.Message) (string, error) {
+		value := m.Metadata.Get(metadataKey)
+		if value == "" {
+			return "", errors.Errorf("message has no %s metadata", metadataKey)
+		}
+		return value, nil
+	}
+}
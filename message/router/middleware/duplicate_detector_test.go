@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestDuplicateDetector(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+
+	var handled []string
+	var duplicates []string
+
+	h := middleware.DuplicateDetector{
+		OnDuplicate: func(msg *message.Message, key string) {
+			duplicates = append(duplicates, key)
+		},
+		LogSampleEvery: 2,
+		Logger:         logger,
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled = append(handled, msg.UUID)
+		return nil, nil
+	})
+
+	for _, uuid := range []string{"1", "2", "1", "1", "2"} {
+		_, err := h(message.NewMessage(uuid, nil))
+		require.NoError(t, err)
+	}
+
+	// duplicates are handled as usual
+	assert.Equal(t, []string{"1", "2", "1", "1", "2"}, handled)
+	assert.Equal(t, []string{"1", "1", "2"}, duplicates)
+
+	// only the 1st and the 3rd duplicate are logged
+	assert.Len(t, logger.Captured()[watermill.InfoLogLevel], 2)
+}
+
+func TestDuplicateDetector_metadata_key(t *testing.T) {
+	var duplicates []string
+
+	h := middleware.DuplicateDetector{
+		KeyFactory: middleware.NewMessageHasherMetadata("idempotency_key"),
+		OnDuplicate: func(msg *message.Message, key string) {
+			duplicates = append(duplicates, msg.UUID)
+		},
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	first := message.NewMessage("1", nil)
+	first.Metadata.Set("idempotency_key", "key")
+	second := message.NewMessage("2", nil)
+	second.Metadata.Set("idempotency_key", "key")
+
+	for _, msg := range []*message.Message{first, second, message.NewMessage("3", nil)} {
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"2"}, duplicates)
+}
+
+func TestNewDuplicateDetector_state_shared_between_middlewares(t *testing.T) {
+	var duplicates []string
+
+	detector := middleware.NewDuplicateDetector(middleware.DuplicateDetector{
+		OnDuplicate: func(msg *message.Message, key string) {
+			duplicates = append(duplicates, key)
+		},
+	})
+
+	handler := func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	}
+
+	// the router builds the middlewares again, for example, when the handler's middlewares are updated
+	first := detector.Middleware(handler)
+	second := detector.Middleware(handler)
+
+	_, err := first(message.NewMessage("1", nil))
+	require.NoError(t, err)
+	_, err = second(message.NewMessage("1", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1"}, duplicates)
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/internal/lines"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// FileSpool is a ReplayablePublisher which appends the published messages to local files, one file per topic.
+// It can be used as CircuitBreakerPublisherConfig.Fallback, so messages are not lost while the broker is unavailable.
+//
+// Messages are kept until they are replayed, also between restarts of the process.
+// If the process is killed during replaying, already replayed messages are replayed again.
+// If the process is killed during publishing, the partially written message is dropped.
+// Keep in mind that the context of the messages is not kept.
+type FileSpool struct {
+	dir  string
+	lock sync.Mutex
+}
+
+type spooledMessage struct {
+	UUID     string           `json:"uuid"`
+	Metadata message.Metadata `json:"metadata"`
+	Payload  []byte           `json:"payload"`
+}
+
+// NewFileSpool creates a new FileSpool keeping the messages in dir. The directory is created if it doesn't exist.
+func NewFileSpool(dir string) (*FileSpool, error) {
+	if dir == "" {
+		return nil, errors.New("missing dir")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "cannot create spool directory")
+	}
+
+	return &FileSpool{dir: dir}, nil
+}
+
+func (s *FileSpool) path(topic string) string {
+	return filepath.Join(s.dir, url.PathEscape(topic)+".spool")
+}
+
+// Publish appends the messages to the file of the topic.
+func (s *FileSpool) Publish(topic string, messages ...*message.Message) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.OpenFile(s.path(topic), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "cannot open spool file")
+	}
+
+	// the partial message left by a killed process would corrupt the message appended after it
+	size, err := lines.TruncatePartial(f)
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot repair spool file")
+	}
+
+	// abort removes the partially written messages, so the file doesn't end with a corrupted message
+	abort := func(err error) error {
+		if truncateErr := f.Truncate(size); truncateErr != nil {
+			err = errors.Wrapf(err, "cannot truncate partial write: %s", truncateErr)
+		}
+		_ = f.Close()
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+
+	for _, msg := range messages {
+		if err := encoder.Encode(spooledMessage{
+			UUID:     msg.UUID,
+			Metadata: msg.Metadata,
+			Payload:  msg.Payload,
+		}); err != nil {
+			return abort(errors.Wrap(err, "cannot write message to spool file"))
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return abort(errors.Wrap(err, "cannot write messages to spool file"))
+	}
+	if err := f.Sync(); err != nil {
+		return abort(errors.Wrap(err, "cannot sync spool file"))
+	}
+
+	return f.Close()
+}
+
+// Replay implements ReplayablePublisher. Publishing to the topic is blocked during replaying.
+func (s *FileSpool) Replay(topic string, publish func(msg *message.Message) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	messages, err := s.read(topic)
+	if err != nil {
+		return err
+	}
+
+	for i, spooled := range messages {
+		msg := message.NewMessage(spooled.UUID, spooled.Payload)
+		msg.Metadata = spooled.Metadata
+		if msg.Metadata == nil {
+			msg.Metadata = make(message.Metadata)
+		}
+
+		if err := publish(msg); err != nil {
+			if writeErr := s.write(topic, messages[i:]); writeErr != nil {
+				return errors.Wrapf(writeErr, "cannot keep not replayed messages after publish error: %s", err)
+			}
+			return err
+		}
+	}
+
+	if err := os.Remove(s.path(topic)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "cannot remove spool file")
+	}
+
+	return nil
+}
+
+// Len returns the number of the kept messages of the topic.
+func (s *FileSpool) Len(topic string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	messages, err := s.read(topic)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(messages), nil
+}
+
+func (s *FileSpool) read(topic string) ([]spooledMessage, error) {
+	f, err := os.Open(s.path(topic))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open spool file")
+	}
+	defer f.Close()
+
+	var messages []spooledMessage
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// the line without the trailing newline is a partial message left by a killed process
+			return messages, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read spool file")
+		}
+
+		var spooled spooledMessage
+		if err := json.Unmarshal(line, &spooled); err != nil {
+			return nil, errors.Wrap(err, "cannot read spool file")
+		}
+		messages = append(messages, spooled)
+	}
+}
+
+func (s *FileSpool) write(topic string, messages []spooledMessage) error {
+	tmpPath := s.path(topic) + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "cannot create spool file")
+	}
+
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, spooled := range messages {
+		if err := encoder.Encode(spooled); err != nil {
+			_ = f.Close()
+			return errors.Wrap(err, "cannot write message to spool file")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot write messages to spool file")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close spool file")
+	}
+
+	// the file is replaced atomically, so messages are not lost if the process is killed
+	return os.Rename(tmpPath, s.path(topic))
+}
+
+// Close does nothing, as the messages are written synchronously.
+func (s *FileSpool) Close() error {
+	return nil
+}
@@ -0,0 +1,120 @@
+package middleware_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestFileSpool(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := middleware.NewFileSpool(dir)
+	require.NoError(t, err)
+
+	msg1 := message.NewMessage("1", []byte("payload-1"))
+	msg1.Metadata.Set("key", "value")
+	msg2 := message.NewMessage("2", []byte("payload-2"))
+	msg3 := message.NewMessage("3", []byte("payload-3"))
+
+	require.NoError(t, spool.Publish("orders/v1", msg1, msg2))
+	require.NoError(t, spool.Publish("orders/v1", msg3))
+	require.NoError(t, spool.Publish("other", message.NewMessage("4", nil)))
+
+	// messages are kept between restarts
+	spool, err = middleware.NewFileSpool(dir)
+	require.NoError(t, err)
+
+	count, err := spool.Len("orders/v1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	var replayed []*message.Message
+	publishErr := errors.New("broker unavailable")
+
+	err = spool.Replay("orders/v1", func(msg *message.Message) error {
+		if msg.UUID == "2" {
+			return publishErr
+		}
+		replayed = append(replayed, msg)
+		return nil
+	})
+	assert.ErrorIs(t, err, publishErr)
+
+	require.Len(t, replayed, 1)
+	assert.Equal(t, "1", replayed[0].UUID)
+	assert.Equal(t, message.Payload("payload-1"), replayed[0].Payload)
+	assert.Equal(t, "value", replayed[0].Metadata.Get("key"))
+
+	count, err = spool.Len("orders/v1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	replayed = nil
+	err = spool.Replay("orders/v1", func(msg *message.Message) error {
+		replayed = append(replayed, msg)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, replayed, 2)
+	assert.Equal(t, "2", replayed[0].UUID)
+	assert.Equal(t, "3", replayed[1].UUID)
+
+	count, err = spool.Len("orders/v1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = spool.Len("other")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestFileSpool_Replay_empty(t *testing.T) {
+	spool, err := middleware.NewFileSpool(t.TempDir())
+	require.NoError(t, err)
+
+	err = spool.Replay("topic", func(msg *message.Message) error {
+		t.Fatal("nothing should be replayed")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestFileSpool_partial_message(t *testing.T) {
+	dir := t.TempDir()
+
+	spool, err := middleware.NewFileSpool(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, spool.Publish("topic", message.NewMessage("1", []byte("payload-1"))))
+
+	// the process was killed while writing the second message
+	f, err := os.OpenFile(filepath.Join(dir, "topic.spool"), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"uuid":"2","meta`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	count, err := spool.Len("topic")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	require.NoError(t, spool.Publish("topic", message.NewMessage("3", []byte("payload-3"))))
+
+	var replayed []string
+	err = spool.Replay("topic", func(msg *message.Message) error {
+		replayed = append(replayed, msg.UUID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1", "3"}, replayed)
+}
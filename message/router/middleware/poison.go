@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"strconv"
+
 	"github.com/ThreeDotsLabs/watermill/message"
 	multierror "github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
@@ -15,13 +18,97 @@ const (
 	PoisonedTopicKey      = "topic_poisoned"
 	PoisonedHandlerKey    = "handler_poisoned"
 	PoisonedSubscriberKey = "subscriber_poisoned"
+
+	// PoisonedPayloadSizeKey and PoisonedPayloadPointerKey are set when the payload was truncated
+	// because it exceeded PoisonQueueConfig.MaxPayloadSize.
+	PoisonedPayloadSizeKey    = "payload_size_poisoned"
+	PoisonedPayloadPointerKey = "payload_pointer_poisoned"
 )
 
+// DefaultRedactedValue replaces the redacted values, if PoisonQueueConfig.RedactedValue is empty.
+const DefaultRedactedValue = "[REDACTED]"
+
+// PoisonPayloadStore stores full payloads of poisoned messages which exceed PoisonQueueConfig.MaxPayloadSize.
+type PoisonPayloadStore interface {
+	// Store stores the payload of the message and returns a pointer to it (for example, an object key or a URL).
+	Store(ctx context.Context, msg *message.Message) (string, error)
+}
+
+// PoisonQueueConfig configures PoisonQueueWithConfig.
+type PoisonQueueConfig struct {
+	// Topic is the topic to which poisoned messages are published. It is required.
+	Topic string
+
+	// ShouldGoToPoisonQueue decides which errors qualify for the poison queue.
+	// If not provided, all errors do.
+	ShouldGoToPoisonQueue func(err error) bool
+
+	// RedactFields are dot-separated paths of the JSON payload's fields (for example "user.email")
+	// whose values are replaced with RedactedValue before publishing to the poison topic.
+	// Paths going through arrays are applied to all elements.
+	//
+	// Payloads which are not valid JSON are replaced with RedactedValue as a whole.
+	RedactFields []string
+
+	// RedactMetadata are metadata keys whose values are replaced with RedactedValue.
+	RedactMetadata []string
+
+	// RedactedValue replaces the redacted values. If empty, DefaultRedactedValue is used.
+	RedactedValue string
+
+	// Redact is an optional hook called after RedactFields and RedactMetadata are applied,
+	// for redaction which can't be expressed with them. The message is a copy of the poisoned message
+	// and can be modified.
+	Redact func(msg *message.Message) error
+
+	// MaxPayloadSize if positive truncates payloads longer than MaxPayloadSize bytes.
+	// The original size is kept in the PoisonedPayloadSizeKey metadata.
+	MaxPayloadSize int
+
+	// PayloadStore if set stores the full (redacted) payload before it's truncated,
+	// and the pointer it returns is kept in the PoisonedPayloadPointerKey metadata.
+	// It's used only if MaxPayloadSize is set.
+	PayloadStore PoisonPayloadStore
+}
+
+func (c *PoisonQueueConfig) setDefaults() {
+	if c.ShouldGoToPoisonQueue == nil {
+		c.ShouldGoToPoisonQueue = func(err error) bool {
+			return true
+		}
+	}
+	if c.RedactedValue == "" {
+		c.RedactedValue = DefaultRedactedValue
+	}
+}
+
+func (c PoisonQueueConfig) Validate() error {
+	var err error
+
+	if c.Topic == "" {
+		err = multierror.Append(err, ErrInvalidPoisonQueueTopic)
+	}
+	if c.MaxPayloadSize < 0 {
+		err = multierror.Append(err, errors.New("MaxPayloadSize must not be negative"))
+	}
+	if c.PayloadStore != nil && c.MaxPayloadSize == 0 {
+		err = multierror.Append(err, errors.New("PayloadStore requires MaxPayloadSize"))
+	}
+
+	return err
+}
+
+func (c PoisonQueueConfig) transformsPayload() bool {
+	return len(c.RedactFields) > 0 || len(c.RedactMetadata) > 0 || c.Redact != nil || c.MaxPayloadSize > 0
+}
+
 type poisonQueue struct {
 	topic string
 	pub   message.Publisher
 
 	shouldGoToPoisonQueue func(err error) bool
+
+	config PoisonQueueConfig
 }
 
 // PoisonQueue provides a middleware that salvages unprocessable messages and published them on a separate topic.
@@ -58,6 +145,28 @@ func PoisonQueueWithFilter(pub message.Publisher, topic string, shouldGoToPoison
 	return pq.Middleware, nil
 }
 
+// PoisonQueueWithConfig is just like PoisonQueue, but configured with PoisonQueueConfig.
+// It allows redacting sensitive data and capping the size of messages published to the poison topic.
+//
+// Messages are redacted and truncated on a copy, so the original message is not affected.
+func PoisonQueueWithConfig(pub message.Publisher, config PoisonQueueConfig) (message.HandlerMiddleware, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid poison queue config")
+	}
+
+	pq := poisonQueue{
+		topic: config.Topic,
+		pub:   pub,
+
+		shouldGoToPoisonQueue: config.ShouldGoToPoisonQueue,
+
+		config: config,
+	}
+
+	return pq.Middleware, nil
+}
+
 func (pq poisonQueue) publishPoisonMessage(msg *message.Message, err error) error {
 	// no problems encountered, carry on
 	if err == nil {
@@ -70,10 +179,52 @@ func (pq poisonQueue) publishPoisonMessage(msg *message.Message, err error) erro
 	msg.Metadata.Set(PoisonedHandlerKey, message.HandlerNameFromCtx(msg.Context()))
 	msg.Metadata.Set(PoisonedSubscriberKey, message.SubscriberNameFromCtx(msg.Context()))
 
+	if pq.config.transformsPayload() {
+		poisonMsg, err := pq.preparePoisonMessage(msg)
+		if err != nil {
+			return err
+		}
+		msg = poisonMsg
+	}
+
 	// don't intercept error from publish. Can't help you if the publisher is down as well.
 	return pq.pub.Publish(pq.topic, msg)
 }
 
+func (pq poisonQueue) preparePoisonMessage(msg *message.Message) (*message.Message, error) {
+	poisonMsg := msg.DeepCopy()
+	poisonMsg.SetContext(msg.Context())
+
+	if len(pq.config.RedactFields) > 0 {
+		poisonMsg.Payload = redactJSONFields(poisonMsg.Payload, pq.config.RedactFields, pq.config.RedactedValue)
+	}
+	for _, key := range pq.config.RedactMetadata {
+		if _, ok := poisonMsg.Metadata[key]; ok {
+			poisonMsg.Metadata.Set(key, pq.config.RedactedValue)
+		}
+	}
+	if pq.config.Redact != nil {
+		if err := pq.config.Redact(poisonMsg); err != nil {
+			return nil, errors.Wrap(err, "cannot redact poisoned message")
+		}
+	}
+
+	if pq.config.MaxPayloadSize > 0 && len(poisonMsg.Payload) > pq.config.MaxPayloadSize {
+		if pq.config.PayloadStore != nil {
+			pointer, err := pq.config.PayloadStore.Store(poisonMsg.Context(), poisonMsg)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot store poisoned message payload")
+			}
+			poisonMsg.Metadata.Set(PoisonedPayloadPointerKey, pointer)
+		}
+
+		poisonMsg.Metadata.Set(PoisonedPayloadSizeKey, strconv.Itoa(len(poisonMsg.Payload)))
+		poisonMsg.Payload = poisonMsg.Payload[:pq.config.MaxPayloadSize]
+	}
+
+	return poisonMsg, nil
+}
+
 func (pq poisonQueue) Middleware(h message.HandlerFunc) message.HandlerFunc {
 	return func(msg *message.Message) (events []*message.Message, err error) {
 		defer func() {
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// redactJSONFields replaces the values of the fields at the dot-separated paths with redactedValue.
+// If the payload is not valid JSON, it's replaced with redactedValue as a whole,
+// as it's not possible to tell if it contains the sensitive fields.
+func redactJSONFields(payload []byte, paths []string, redactedValue string) []byte {
+	// numbers are decoded as json.Number, so they are not changed by the float64 conversion (for example, big IDs)
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return []byte(redactedValue)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		// trailing data after the JSON value
+		return []byte(redactedValue)
+	}
+
+	for _, path := range paths {
+		doc = redactPath(doc, strings.Split(path, "."), redactedValue)
+	}
+
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return []byte(redactedValue)
+	}
+
+	return redacted
+}
+
+func redactPath(value any, path []string, redactedValue string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		field, ok := v[path[0]]
+		if !ok {
+			return v
+		}
+		if len(path) == 1 {
+			v[path[0]] = redactedValue
+		} else {
+			v[path[0]] = redactPath(field, path[1:], redactedValue)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactPath(v[i], path, redactedValue)
+		}
+		return v
+	default:
+		return v
+	}
+}
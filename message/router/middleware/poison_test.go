@@ -243,3 +243,148 @@ func TestPoisonQueueWithFilter_non_poison_queue(t *testing.T) {
 	assert.Error(t, err)
 	require.Len(t, poisonPublisher.PopMessages(), 0)
 }
+
+type mockPayloadStore struct {
+	stored map[string][]byte
+}
+
+func (s *mockPayloadStore) Store(ctx context.Context, msg *message.Message) (string, error) {
+	pointer := "blob://poison/" + msg.UUID
+	s.stored[pointer] = msg.Payload
+	return pointer, nil
+}
+
+func TestPoisonQueueWithConfig_redaction(t *testing.T) {
+	poisonPublisher := mockPublisher{behaviour: BehaviourAlwaysOK}
+
+	poisonQueue, err := middleware.PoisonQueueWithConfig(&poisonPublisher, middleware.PoisonQueueConfig{
+		Topic:          topic,
+		RedactFields:   []string{"user.email", "items.card_number", "missing.field"},
+		RedactMetadata: []string{"authorization"},
+		Redact: func(msg *message.Message) error {
+			delete(msg.Metadata, "session_id")
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	payload := `{"user":{"email":"john@example.com","name":"John"},"items":[{"card_number":"4111","amount":1}]}`
+	msg := message.NewMessage("uuid", []byte(payload))
+	msg.Metadata.Set("authorization", "Bearer token")
+	msg.Metadata.Set("session_id", "session")
+
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(msg)
+	require.NoError(t, err)
+
+	poisonMsgs := poisonPublisher.PopMessages()
+	require.Len(t, poisonMsgs, 1)
+
+	assert.JSONEq(
+		t,
+		`{"user":{"email":"[REDACTED]","name":"John"},"items":[{"card_number":"[REDACTED]","amount":1}]}`,
+		string(poisonMsgs[0].Payload),
+	)
+	assert.Equal(t, "[REDACTED]", poisonMsgs[0].Metadata.Get("authorization"))
+	assert.Empty(t, poisonMsgs[0].Metadata.Get("session_id"))
+	assert.Equal(t, errFailed.Error(), poisonMsgs[0].Metadata.Get(middleware.ReasonForPoisonedKey))
+
+	// the original message is not modified
+	assert.Equal(t, payload, string(msg.Payload))
+	assert.Equal(t, "Bearer token", msg.Metadata.Get("authorization"))
+	assert.Equal(t, "session", msg.Metadata.Get("session_id"))
+}
+
+func TestPoisonQueueWithConfig_redaction_invalid_json(t *testing.T) {
+	poisonPublisher := mockPublisher{behaviour: BehaviourAlwaysOK}
+
+	poisonQueue, err := middleware.PoisonQueueWithConfig(&poisonPublisher, middleware.PoisonQueueConfig{
+		Topic:         topic,
+		RedactFields:  []string{"email"},
+		RedactedValue: "***",
+	})
+	require.NoError(t, err)
+
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(message.NewMessage("uuid", []byte("email=john@example.com")))
+	require.NoError(t, err)
+
+	poisonMsgs := poisonPublisher.PopMessages()
+	require.Len(t, poisonMsgs, 1)
+	assert.Equal(t, "***", string(poisonMsgs[0].Payload))
+}
+
+func TestPoisonQueueWithConfig_redaction_numbers(t *testing.T) {
+	poisonPublisher := mockPublisher{behaviour: BehaviourAlwaysOK}
+
+	poisonQueue, err := middleware.PoisonQueueWithConfig(&poisonPublisher, middleware.PoisonQueueConfig{
+		Topic:        topic,
+		RedactFields: []string{"email"},
+	})
+	require.NoError(t, err)
+
+	payload := `{"email":"john@example.com","id":12345678901234567890,"amount":0.1}`
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(message.NewMessage("uuid", []byte(payload)))
+	require.NoError(t, err)
+
+	poisonMsgs := poisonPublisher.PopMessages()
+	require.Len(t, poisonMsgs, 1)
+	assert.Equal(t, `{"amount":0.1,"email":"[REDACTED]","id":12345678901234567890}`, string(poisonMsgs[0].Payload))
+}
+
+func TestPoisonQueueWithConfig_redaction_trailing_data(t *testing.T) {
+	poisonPublisher := mockPublisher{behaviour: BehaviourAlwaysOK}
+
+	poisonQueue, err := middleware.PoisonQueueWithConfig(&poisonPublisher, middleware.PoisonQueueConfig{
+		Topic:         topic,
+		RedactFields:  []string{"email"},
+		RedactedValue: "***",
+	})
+	require.NoError(t, err)
+
+	payload := `{"email":"[REDACTED]"} {"email":"john@example.com"}`
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(message.NewMessage("uuid", []byte(payload)))
+	require.NoError(t, err)
+
+	poisonMsgs := poisonPublisher.PopMessages()
+	require.Len(t, poisonMsgs, 1)
+	assert.Equal(t, "***", string(poisonMsgs[0].Payload))
+}
+
+func TestPoisonQueueWithConfig_max_payload_size(t *testing.T) {
+	poisonPublisher := mockPublisher{behaviour: BehaviourAlwaysOK}
+	store := &mockPayloadStore{stored: map[string][]byte{}}
+
+	poisonQueue, err := middleware.PoisonQueueWithConfig(&poisonPublisher, middleware.PoisonQueueConfig{
+		Topic:          topic,
+		MaxPayloadSize: 4,
+		PayloadStore:   store,
+	})
+	require.NoError(t, err)
+
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(message.NewMessage("small", []byte("1234")))
+	require.NoError(t, err)
+	_, err = poisonQueue(handlerFuncAlwaysFailing)(message.NewMessage("big", []byte("1234567890")))
+	require.NoError(t, err)
+
+	poisonMsgs := poisonPublisher.PopMessages()
+	require.Len(t, poisonMsgs, 2)
+
+	assert.Equal(t, "1234", string(poisonMsgs[0].Payload))
+	assert.Empty(t, poisonMsgs[0].Metadata.Get(middleware.PoisonedPayloadSizeKey))
+	assert.Empty(t, poisonMsgs[0].Metadata.Get(middleware.PoisonedPayloadPointerKey))
+
+	assert.Equal(t, "1234", string(poisonMsgs[1].Payload))
+	assert.Equal(t, "10", poisonMsgs[1].Metadata.Get(middleware.PoisonedPayloadSizeKey))
+	assert.Equal(t, "blob://poison/big", poisonMsgs[1].Metadata.Get(middleware.PoisonedPayloadPointerKey))
+	assert.Equal(t, map[string][]byte{"blob://poison/big": []byte("1234567890")}, store.stored)
+}
+
+func TestPoisonQueueWithConfig_invalid_config(t *testing.T) {
+	_, err := middleware.PoisonQueueWithConfig(&mockPublisher{}, middleware.PoisonQueueConfig{})
+	assert.ErrorIs(t, err, middleware.ErrInvalidPoisonQueueTopic)
+
+	_, err = middleware.PoisonQueueWithConfig(&mockPublisher{}, middleware.PoisonQueueConfig{
+		Topic:        topic,
+		PayloadStore: &mockPayloadStore{},
+	})
+	assert.ErrorContains(t, err, "PayloadStore requires MaxPayloadSize")
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"context"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -12,6 +11,8 @@ import (
 
 // Retry provides a middleware that retries the handler if errors are returned.
 // The retry behaviour is configurable, with exponential backoff and maximum elapsed time.
+//
+// If you need retry budgets, decorrelated jitter, or classification of retryable errors, use RetryPolicy instead.
 type Retry struct {
 	// MaxRetries is maximum number of times a retry will be attempted.
 	MaxRetries int
@@ -33,6 +34,10 @@ type Retry struct {
 	OnRetryHook func(retryNum int, delay time.Duration)
 
 	Logger watermill.LoggerAdapter
+
+	// Clock is used to wait between retries and to measure MaxElapsedTime.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
 }
 
 // Middleware returns the Retry middleware.
@@ -43,18 +48,24 @@ func (r Retry) Middleware(h message.HandlerFunc) message.HandlerFunc {
 			return producedMessages, nil
 		}
 
+		clock := r.Clock
+		if clock == nil {
+			clock = watermill.RealClock{}
+		}
+
 		expBackoff := backoff.NewExponentialBackOff()
 		expBackoff.InitialInterval = r.InitialInterval
 		expBackoff.MaxInterval = r.MaxInterval
 		expBackoff.Multiplier = r.Multiplier
 		expBackoff.MaxElapsedTime = r.MaxElapsedTime
 		expBackoff.RandomizationFactor = r.RandomizationFactor
+		expBackoff.Clock = clock
 
 		ctx := msg.Context()
+
+		var maxElapsedTimeExceeded <-chan time.Time
 		if r.MaxElapsedTime > 0 {
-			var cancel func()
-			ctx, cancel = context.WithTimeout(ctx, r.MaxElapsedTime)
-			defer cancel()
+			maxElapsedTimeExceeded = clock.After(r.MaxElapsedTime)
 		}
 
 		retryNum := 1
@@ -65,7 +76,9 @@ func (r Retry) Middleware(h message.HandlerFunc) message.HandlerFunc {
 			select {
 			case <-ctx.Done():
 				return producedMessages, err
-			case <-time.After(waitTime):
+			case <-maxElapsedTimeExceeded:
+				return producedMessages, err
+			case <-clock.After(waitTime):
 				// go on
 			}
 
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// RetryAttempt describes a failed attempt of handling the message by RetryPolicy.
+type RetryAttempt struct {
+	// Number of the attempt, starting from 1 for the first call of the handler.
+	Number int
+	// Err is the error returned by the handler.
+	Err error
+	// Elapsed is the time elapsed since the first attempt started.
+	Elapsed time.Duration
+
+	// WillRetry is true if the handler will be called again.
+	WillRetry bool
+	// Delay is the time to wait before the next attempt. It's zero if WillRetry is false.
+	Delay time.Duration
+}
+
+// RetryPolicy provides a middleware that retries the handler if errors are returned.
+//
+// Compared to Retry, it limits the total time spent on handling the message (Budget),
+// waits between attempts using decorrelated jitter backoff, and allows to classify errors as non-retryable.
+//
+// Decorrelated jitter picks every delay randomly between BaseDelay and three times the previous delay
+// (capped by MaxDelay), which spreads the retries of many consumers failing at the same time.
+//
+// If neither MaxRetries nor Budget is set, the handler is retried until it succeeds or the message's context is done.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries (not counting the first attempt). Disabled if 0.
+	MaxRetries int
+
+	// Budget is the maximum total time of handling the message, including all attempts and delays. Disabled if 0.
+	// The next attempt is not started if its delay would exceed the budget.
+	Budget time.Duration
+
+	// BaseDelay is the minimum delay between attempts. If 0, attempts are retried without waiting.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum delay between attempts. Disabled if 0.
+	MaxDelay time.Duration
+
+	// IsRetryable decides if the handler should be retried after returning the error.
+	// If nil, all errors are retried.
+	IsRetryable func(err error) bool
+
+	// OnAttempt is an optional function that is called after each failed attempt.
+	OnAttempt func(attempt RetryAttempt)
+
+	Logger watermill.LoggerAdapter
+
+	// Clock is used to wait between attempts and to measure Budget.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+// Middleware returns the RetryPolicy middleware.
+func (p RetryPolicy) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		clock := p.Clock
+		if clock == nil {
+			clock = watermill.RealClock{}
+		}
+
+		start := clock.Now()
+		delay := p.BaseDelay
+
+		// inner middlewares (for example, Timeout) may replace the context, it's restored before each attempt
+		ctx := msg.Context()
+		defer msg.SetContext(ctx)
+
+		for attemptNum := 1; ; attemptNum++ {
+			msg.SetContext(ctx)
+
+			producedMessages, err := h(msg)
+			if err == nil {
+				return producedMessages, nil
+			}
+
+			attempt := RetryAttempt{
+				Number:    attemptNum,
+				Err:       err,
+				Elapsed:   clock.Now().Sub(start),
+				WillRetry: p.shouldRetry(attemptNum, err),
+			}
+
+			if attempt.WillRetry {
+				delay = p.nextDelay(delay)
+
+				if p.Budget > 0 && attempt.Elapsed+delay > p.Budget {
+					attempt.WillRetry = false
+				} else {
+					attempt.Delay = delay
+				}
+			}
+
+			if p.Logger != nil {
+				p.Logger.Error("Error occurred", err, watermill.LogFields{
+					"attempt_no":   attempt.Number,
+					"will_retry":   attempt.WillRetry,
+					"wait_time":    attempt.Delay,
+					"elapsed_time": attempt.Elapsed,
+				})
+			}
+			if p.OnAttempt != nil {
+				p.OnAttempt(attempt)
+			}
+
+			if !attempt.WillRetry {
+				return producedMessages, err
+			}
+
+			select {
+			case <-ctx.Done():
+				return producedMessages, err
+			case <-clock.After(attempt.Delay):
+				// go on
+			}
+		}
+	}
+}
+
+func (p RetryPolicy) shouldRetry(attemptNum int, err error) bool {
+	if p.MaxRetries > 0 && attemptNum > p.MaxRetries {
+		return false
+	}
+	if p.IsRetryable != nil && !p.IsRetryable(err) {
+		return false
+	}
+
+	return true
+}
+
+func (p RetryPolicy) nextDelay(previous time.Duration) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	if upper := previous * 3; upper > p.BaseDelay {
+		delay += time.Duration(rand.Int63n(int64(upper - p.BaseDelay)))
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,191 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestRetryPolicy_max_retries(t *testing.T) {
+	var attempts []middleware.RetryAttempt
+
+	policy := middleware.RetryPolicy{
+		MaxRetries: 2,
+		OnAttempt: func(attempt middleware.RetryAttempt) {
+			attempts = append(attempts, attempt)
+		},
+	}
+
+	runCount := 0
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		runCount++
+		return nil, errors.New("foo")
+	})
+
+	_, err := h(message.NewMessage("1", nil))
+	assert.EqualError(t, err, "foo")
+	assert.Equal(t, 3, runCount)
+
+	require.Len(t, attempts, 3)
+	for i, attempt := range attempts {
+		assert.Equal(t, i+1, attempt.Number)
+		assert.EqualError(t, attempt.Err, "foo")
+	}
+	assert.True(t, attempts[0].WillRetry)
+	assert.True(t, attempts[1].WillRetry)
+	assert.False(t, attempts[2].WillRetry)
+}
+
+func TestRetryPolicy_success(t *testing.T) {
+	policy := middleware.RetryPolicy{
+		MaxRetries: 5,
+	}
+
+	runCount := 0
+	producedMessages := message.Messages{message.NewMessage("2", nil)}
+
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		runCount++
+		if runCount < 3 {
+			return nil, errors.New("foo")
+		}
+		return producedMessages, nil
+	})
+
+	handlerMessages, err := h(message.NewMessage("1", nil))
+	require.NoError(t, err)
+	assert.Equal(t, 3, runCount)
+	assert.EqualValues(t, producedMessages, handlerMessages)
+}
+
+var errPermanent = errors.New("permanent")
+
+func TestRetryPolicy_is_retryable(t *testing.T) {
+	policy := middleware.RetryPolicy{
+		MaxRetries: 5,
+		IsRetryable: func(err error) bool {
+			return !errors.Is(err, errPermanent)
+		},
+	}
+
+	runCount := 0
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		runCount++
+		if runCount == 1 {
+			return nil, errors.New("temporary")
+		}
+		return nil, errors.Wrap(errPermanent, "failed")
+	})
+
+	_, err := h(message.NewMessage("1", nil))
+	assert.ErrorIs(t, err, errPermanent)
+	assert.Equal(t, 2, runCount)
+}
+
+func TestRetryPolicy_decorrelated_jitter(t *testing.T) {
+	baseDelay := time.Millisecond
+	maxDelay := time.Millisecond * 5
+
+	var delays []time.Duration
+
+	policy := middleware.RetryPolicy{
+		MaxRetries: 10,
+		BaseDelay:  baseDelay,
+		MaxDelay:   maxDelay,
+		OnAttempt: func(attempt middleware.RetryAttempt) {
+			if attempt.WillRetry {
+				delays = append(delays, attempt.Delay)
+			}
+		},
+	}
+
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, errors.New("foo")
+	})
+
+	_, err := h(message.NewMessage("1", nil))
+	require.Error(t, err)
+
+	require.Len(t, delays, 10)
+	previous := baseDelay
+	for i, delay := range delays {
+		assert.GreaterOrEqual(t, delay, baseDelay, "delay %d", i)
+		assert.LessOrEqual(t, delay, maxDelay, "delay %d", i)
+		assert.LessOrEqual(t, delay, previous*3, "delay %d", i)
+		previous = delay
+	}
+}
+
+func TestRetryPolicy_budget(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	policy := middleware.RetryPolicy{
+		Budget:    time.Minute,
+		BaseDelay: time.Second * 25,
+		MaxDelay:  time.Second * 25,
+		Clock:     clock,
+	}
+
+	runCount := 0
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		runCount++
+		return nil, errors.New("foo")
+	})
+
+	handlerErrCh := make(chan error, 1)
+	go func() {
+		_, err := h(message.NewMessage("1", nil))
+		handlerErrCh <- err
+	}()
+
+	for i := 0; i < 2; i++ {
+		clock.BlockUntilWaiters(1)
+		clock.Advance(time.Second * 25)
+	}
+
+	select {
+	case err := <-handlerErrCh:
+		assert.EqualError(t, err, "foo")
+		// 0s, 25s and 50s - the next attempt at 75s would exceed the budget
+		assert.Equal(t, 3, runCount)
+	case <-time.After(time.Second):
+		t.Fatal("handler should stop retrying when budget is exceeded")
+	}
+}
+
+func TestRetryPolicy_ctx_cancel(t *testing.T) {
+	policy := middleware.RetryPolicy{
+		BaseDelay: time.Hour,
+	}
+
+	h := policy.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, errors.New("foo")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	msg := message.NewMessage("1", nil)
+	msg.SetContext(ctx)
+
+	handlerErrCh := make(chan error, 1)
+	go func() {
+		_, err := h(msg)
+		handlerErrCh <- err
+	}()
+
+	cancel()
+
+	select {
+	case err := <-handlerErrCh:
+		assert.EqualError(t, err, "foo")
+	case <-time.After(time.Second):
+		t.Fatal("handler should stop retrying when context is canceled")
+	}
+}
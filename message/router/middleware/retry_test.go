@@ -200,3 +200,80 @@ func TestRetry_max_interval(t *testing.T) {
 		assert.True(t, delay <= maxInterval, "wait interval %d (%s) exceeds maxInterval (%s)", i, delay, maxInterval)
 	}
 }
+
+func TestRetry_clock(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	retry := middleware.Retry{
+		MaxRetries:      1,
+		InitialInterval: time.Hour,
+		Clock:           clock,
+	}
+
+	runCount := 0
+	h := retry.Middleware(func(msg *message.Message) (messages []*message.Message, e error) {
+		runCount++
+		if runCount == 1 {
+			return nil, errors.New("foo")
+		}
+
+		return nil, nil
+	})
+
+	handlerErrCh := make(chan error, 1)
+	go func() {
+		_, err := h(message.NewMessage("1", nil))
+		handlerErrCh <- err
+	}()
+
+	clock.BlockUntilWaiters(1)
+
+	select {
+	case <-handlerErrCh:
+		t.Fatal("handler should wait before retry")
+	default:
+		// ok
+	}
+
+	clock.Advance(time.Hour)
+
+	select {
+	case err := <-handlerErrCh:
+		assert.NoError(t, err)
+		assert.Equal(t, 2, runCount)
+	case <-time.After(time.Second):
+		t.Fatal("handler should be retried after advancing the clock")
+	}
+}
+
+func TestRetry_clock_max_elapsed(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	retry := middleware.Retry{
+		MaxRetries:      10,
+		InitialInterval: time.Hour,
+		MaxElapsedTime:  time.Minute,
+		Clock:           clock,
+	}
+
+	h := retry.Middleware(func(msg *message.Message) (messages []*message.Message, e error) {
+		return nil, errors.New("foo")
+	})
+
+	handlerErrCh := make(chan error, 1)
+	go func() {
+		_, err := h(message.NewMessage("1", nil))
+		handlerErrCh <- err
+	}()
+
+	// max elapsed time timer and backoff timer
+	clock.BlockUntilWaiters(2)
+	clock.Advance(time.Minute)
+
+	select {
+	case err := <-handlerErrCh:
+		assert.EqualError(t, err, "foo")
+	case <-time.After(time.Second):
+		t.Fatal("retrying should stop after max elapsed time")
+	}
+}
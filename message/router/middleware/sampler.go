@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// SamplingStrategy decides which messages are handled by the Sampler middleware.
+type SamplingStrategy string
+
+const (
+	// SamplingPercentage handles a random SamplerConfig.Percentage of messages.
+	SamplingPercentage SamplingStrategy = "percentage"
+
+	// SamplingEveryNth handles every SamplerConfig.EveryNth message, starting with the first one.
+	SamplingEveryNth SamplingStrategy = "every_nth"
+
+	// SamplingReservoir handles the first SamplerConfig.ReservoirSize messages of every key
+	// (see SamplerConfig.KeyMetadataKey) within SamplerConfig.ReservoirInterval.
+	// Unlike the other strategies, messages of rare keys are always handled, and only frequent keys are sampled.
+	SamplingReservoir SamplingStrategy = "reservoir"
+)
+
+// SamplerConfig configures the Sampler middleware.
+type SamplerConfig struct {
+	// Strategy is the sampling strategy. Defaults to SamplingPercentage.
+	Strategy SamplingStrategy
+
+	// Percentage is the percentage of messages handled with SamplingPercentage, from 0 to 100.
+	Percentage float64
+
+	// EveryNth is the sampling interval of SamplingEveryNth: 1 handles all messages, 10 every tenth message.
+	EveryNth int
+
+	// KeyMetadataKey is the metadata key of the message's key used by SamplingReservoir,
+	// for example, the customer ID. All messages without the key share one reservoir.
+	KeyMetadataKey string
+
+	// ReservoirSize is the number of messages of a key handled within ReservoirInterval with SamplingReservoir.
+	ReservoirSize int
+
+	// ReservoirInterval is the window of SamplingReservoir. Defaults to 1 minute.
+	ReservoirInterval time.Duration
+
+	// OnSkipped is an optional function called for every message skipped (acked without handling),
+	// for example, to count them.
+	OnSkipped func(msg *message.Message)
+
+	// Random returns a random number in [0, 1). It can be replaced for deterministic tests.
+	// If not provided, rand.Float64 is used.
+	Random func() float64
+
+	// Clock is used to measure the reservoir windows.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *SamplerConfig) setDefaults() {
+	if c.Strategy == "" {
+		c.Strategy = SamplingPercentage
+	}
+	if c.ReservoirInterval == 0 {
+		c.ReservoirInterval = time.Minute
+	}
+	if c.Random == nil {
+		c.Random = rand.Float64
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c SamplerConfig) Validate() error {
+	switch c.Strategy {
+	case SamplingPercentage:
+		if c.Percentage < 0 || c.Percentage > 100 {
+			return errors.New("Percentage must be between 0 and 100")
+		}
+	case SamplingEveryNth:
+		if c.EveryNth <= 0 {
+			return errors.New("EveryNth must be positive")
+		}
+	case SamplingReservoir:
+		if c.ReservoirSize <= 0 {
+			return errors.New("ReservoirSize must be positive")
+		}
+		if c.ReservoirInterval < 0 {
+			return errors.New("ReservoirInterval must not be negative")
+		}
+	default:
+		return errors.Errorf("unknown Strategy %s", c.Strategy)
+	}
+
+	return nil
+}
+
+type samplerReservoir struct {
+	start time.Time
+	count int
+}
+
+// Sampler handles only a sample of messages, and acks the rest without handling them.
+// It's useful for analytics-style consumers, where handling all messages is unnecessary and costly.
+//
+// The sampling is done per middleware instance, so it's not shared between handlers or service instances,
+// unless the same Sampler is used as the middleware of multiple handlers.
+type Sampler struct {
+	config SamplerConfig
+
+	lock        sync.Mutex
+	count       uint64
+	reservoirs  map[string]*samplerReservoir
+	lastCleanup time.Time
+}
+
+// NewSampler creates a new Sampler middleware.
+func NewSampler(config SamplerConfig) (*Sampler, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &Sampler{
+		config:      config,
+		reservoirs:  map[string]*samplerReservoir{},
+		lastCleanup: config.Clock.Now(),
+	}, nil
+}
+
+// Middleware returns the Sampler middleware.
+func (s *Sampler) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		if s.sampled(msg) {
+			return h(msg)
+		}
+
+		s.config.Logger.Trace("Skipping message not sampled", watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"strategy":     s.config.Strategy,
+		})
+		if s.config.OnSkipped != nil {
+			s.config.OnSkipped(msg)
+		}
+
+		return nil, nil
+	}
+}
+
+// sampled returns true if the message should be handled.
+func (s *Sampler) sampled(msg *message.Message) bool {
+	switch s.config.Strategy {
+	case SamplingEveryNth:
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		n := s.count
+		s.count++
+
+		return n%uint64(s.config.EveryNth) == 0
+	case SamplingReservoir:
+		return s.takeFromReservoir(msg.Metadata.Get(s.config.KeyMetadataKey))
+	default:
+		return s.config.Random()*100 < s.config.Percentage
+	}
+}
+
+func (s *Sampler) takeFromReservoir(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.config.Clock.Now()
+	s.cleanup(now)
+
+	reservoir, ok := s.reservoirs[key]
+	if !ok || now.Sub(reservoir.start) >= s.config.ReservoirInterval {
+		reservoir = &samplerReservoir{start: now}
+		s.reservoirs[key] = reservoir
+	}
+
+	if reservoir.count >= s.config.ReservoirSize {
+		return false
+	}
+	reservoir.count++
+
+	return true
+}
+
+// cleanup removes the expired reservoirs, so keys that are not used anymore don't use memory.
+func (s *Sampler) cleanup(now time.Time) {
+	if now.Sub(s.lastCleanup) < s.config.ReservoirInterval {
+		return
+	}
+
+	for key, reservoir := range s.reservoirs {
+		if now.Sub(reservoir.start) >= s.config.ReservoirInterval {
+			delete(s.reservoirs, key)
+		}
+	}
+	s.lastCleanup = now
+}
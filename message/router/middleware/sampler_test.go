@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func newSampledHandler(t *testing.T, config middleware.SamplerConfig) (message.HandlerFunc, *[]string, *int) {
+	t.Helper()
+
+	skipped := 0
+	config.OnSkipped = func(msg *message.Message) {
+		skipped++
+	}
+
+	sampler, err := middleware.NewSampler(config)
+	require.NoError(t, err)
+
+	var handled []string
+	h := sampler.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled = append(handled, msg.UUID)
+		return nil, nil
+	})
+
+	return h, &handled, &skipped
+}
+
+func TestSampler_percentage(t *testing.T) {
+	randoms := []float64{0.1, 0.5, 0.24, 0.99}
+
+	h, handled, skipped := newSampledHandler(t, middleware.SamplerConfig{
+		Percentage: 25,
+		Random: func() float64 {
+			r := randoms[0]
+			randoms = randoms[1:]
+			return r
+		},
+	})
+
+	for i := 1; i <= 4; i++ {
+		_, err := h(message.NewMessage(fmt.Sprint(i), nil))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"1", "3"}, *handled)
+	assert.Equal(t, 2, *skipped)
+}
+
+func TestSampler_every_nth(t *testing.T) {
+	h, handled, skipped := newSampledHandler(t, middleware.SamplerConfig{
+		Strategy: middleware.SamplingEveryNth,
+		EveryNth: 3,
+	})
+
+	for i := 1; i <= 7; i++ {
+		_, err := h(message.NewMessage(fmt.Sprint(i), nil))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"1", "4", "7"}, *handled)
+	assert.Equal(t, 4, *skipped)
+}
+
+func TestSampler_reservoir(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	h, handled, skipped := newSampledHandler(t, middleware.SamplerConfig{
+		Strategy:          middleware.SamplingReservoir,
+		KeyMetadataKey:    "tenant",
+		ReservoirSize:     2,
+		ReservoirInterval: time.Minute,
+		Clock:             clock,
+	})
+
+	messages := []*message.Message{
+		senderMessage("noisy-1", "noisy"),
+		senderMessage("noisy-2", "noisy"),
+		senderMessage("noisy-3", "noisy"),
+		senderMessage("rare-1", "rare"),
+		senderMessage("unknown-1", ""),
+	}
+	for _, msg := range messages {
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"noisy-1", "noisy-2", "rare-1", "unknown-1"}, *handled)
+	assert.Equal(t, 1, *skipped)
+
+	clock.Advance(time.Minute)
+
+	_, err := h(senderMessage("noisy-4", "noisy"))
+	require.NoError(t, err)
+	assert.Contains(t, *handled, "noisy-4", "the reservoir should be refilled in the next window")
+}
+
+func TestSamplerConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Config middleware.SamplerConfig
+	}{
+		{
+			Name:   "percentage_out_of_range",
+			Config: middleware.SamplerConfig{Percentage: 101},
+		},
+		{
+			Name:   "missing_every_nth",
+			Config: middleware.SamplerConfig{Strategy: middleware.SamplingEveryNth},
+		},
+		{
+			Name:   "missing_reservoir_size",
+			Config: middleware.SamplerConfig{Strategy: middleware.SamplingReservoir},
+		},
+		{
+			Name:   "unknown_strategy",
+			Config: middleware.SamplerConfig{Strategy: "unknown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := middleware.NewSampler(tc.Config)
+			assert.Error(t, err)
+		})
+	}
+}
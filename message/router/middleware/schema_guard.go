@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Metadata keys set on messages routed to the rejects topic by SchemaGuard.
+const (
+	SchemaValidationErrorKey = "schema_validation_error"
+	SchemaRejectedTopicKey   = "schema_rejected_topic"
+	SchemaRejectedHandlerKey = "schema_rejected_handler"
+)
+
+// SchemaValidator validates the message's payload.
+type SchemaValidator interface {
+	// Validate returns an error if the payload doesn't match the schema.
+	Validate(payload []byte) error
+}
+
+// SchemaValidatorFunc is a function implementing SchemaValidator.
+type SchemaValidatorFunc func(payload []byte) error
+
+func (f SchemaValidatorFunc) Validate(payload []byte) error {
+	return f(payload)
+}
+
+// SchemaValidationError is returned by the SchemaGuard middleware when the payload doesn't match the topic's schema.
+type SchemaValidationError struct {
+	Topic       string
+	MessageUUID string
+	Err         error
+}
+
+func (e SchemaValidationError) Error() string {
+	return fmt.Sprintf("message %s from topic %s doesn't match the schema: %s", e.MessageUUID, e.Topic, e.Err)
+}
+
+func (e SchemaValidationError) Unwrap() error {
+	return e.Err
+}
+
+// SchemaGuard provides a middleware that validates payloads of incoming messages against the schemas
+// of the topics they were received from, before the handler runs.
+// It protects consumers from regressions of producers.
+//
+// Invalid messages are published to RejectsTopic with the validation error in the SchemaValidationErrorKey metadata
+// and acked. If RejectsTopic is empty, SchemaValidationError is returned instead.
+//
+// Messages received from topics without a schema are passed to the handler.
+type SchemaGuard struct {
+	// Schemas are the schemas of the topics, keyed by the subscribe topic. It is required.
+	// Use jsonschema.Compile from the components/jsonschema package to validate payloads against a JSON Schema,
+	// or implement SchemaValidator with a JSON Schema library of your choice.
+	Schemas map[string]SchemaValidator
+
+	// RejectsTopic is the topic to which invalid messages are published. RejectsPublisher is required if it's set.
+	RejectsTopic     string
+	RejectsPublisher message.Publisher
+
+	Logger watermill.LoggerAdapter
+}
+
+// Middleware returns the SchemaGuard middleware.
+func (g SchemaGuard) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	if g.Schemas == nil {
+		panic("missing Schemas")
+	}
+	if g.RejectsTopic != "" && g.RejectsPublisher == nil {
+		panic("missing RejectsPublisher")
+	}
+
+	logger := g.Logger
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return func(msg *message.Message) ([]*message.Message, error) {
+		topic := message.SubscribeTopicFromCtx(msg.Context())
+
+		schema, ok := g.Schemas[topic]
+		if !ok {
+			return h(msg)
+		}
+
+		err := schema.Validate(msg.Payload)
+		if err == nil {
+			return h(msg)
+		}
+
+		validationErr := SchemaValidationError{
+			Topic:       topic,
+			MessageUUID: msg.UUID,
+			Err:         err,
+		}
+
+		if g.RejectsTopic == "" {
+			return nil, validationErr
+		}
+
+		logger.Info("Message doesn't match the schema, rejecting", watermill.LogFields{
+			"message_uuid":  msg.UUID,
+			"topic":         topic,
+			"rejects_topic": g.RejectsTopic,
+			"error":         err.Error(),
+		})
+
+		msg.Metadata.Set(SchemaValidationErrorKey, err.Error())
+		msg.Metadata.Set(SchemaRejectedTopicKey, topic)
+		msg.Metadata.Set(SchemaRejectedHandlerKey, message.HandlerNameFromCtx(msg.Context()))
+
+		if err := g.RejectsPublisher.Publish(g.RejectsTopic, msg); err != nil {
+			return nil, errors.Wrap(err, "cannot publish message to rejects topic")
+		}
+
+		return nil, nil
+	}
+}
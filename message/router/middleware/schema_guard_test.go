@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/components/jsonschema"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestSchemaGuard(t *testing.T) {
+	logger := watermill.NopLogger{}
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, logger)
+
+	rejects, err := pubSub.Subscribe(context.Background(), "rejects")
+	require.NoError(t, err)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	router.AddMiddleware(middleware.SchemaGuard{
+		Schemas: map[string]middleware.SchemaValidator{
+			"orders": jsonschema.MustCompile([]byte(`{
+				"type": "object",
+				"required": ["id", "amount", "items"],
+				"properties": {
+					"id": {"type": "string"},
+					"amount": {"type": "number"},
+					"items": {"type": "array", "minItems": 1}
+				}
+			}`)),
+		},
+		RejectsTopic:     "rejects",
+		RejectsPublisher: pubSub,
+	}.Middleware)
+
+	handled := make(chan string, 10)
+	handler := func(msg *message.Message) error {
+		handled <- msg.UUID
+		return nil
+	}
+
+	router.AddNoPublisherHandler("orders_handler", "orders", pubSub, handler)
+	router.AddNoPublisherHandler("other_handler", "other", pubSub, handler)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	require.NoError(t, pubSub.Publish("orders", message.NewMessage("valid", []byte(`{"id": "ord_1", "amount": 1, "items": [{"sku": "abc"}]}`))))
+	require.NoError(t, pubSub.Publish("orders", message.NewMessage("invalid", []byte(`{"id": "ord_1"}`))))
+	require.NoError(t, pubSub.Publish("other", message.NewMessage("without_schema", []byte(`not json`))))
+
+	received, all := subscriber.BulkRead(rejects, 1, time.Second)
+	require.True(t, all)
+
+	rejected := received[0]
+	assert.Equal(t, "invalid", rejected.UUID)
+	assert.Equal(t, "$: missing required property amount; $: missing required property items", rejected.Metadata.Get(middleware.SchemaValidationErrorKey))
+	assert.Equal(t, "orders", rejected.Metadata.Get(middleware.SchemaRejectedTopicKey))
+	assert.Equal(t, "orders_handler", rejected.Metadata.Get(middleware.SchemaRejectedHandlerKey))
+
+	var handledUUIDs []string
+	for i := 0; i < 2; i++ {
+		select {
+		case uuid := <-handled:
+			handledUUIDs = append(handledUUIDs, uuid)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for handled message")
+		}
+	}
+	assert.ElementsMatch(t, []string{"valid", "without_schema"}, handledUUIDs)
+}
+
+func TestSchemaGuard_without_rejects_topic(t *testing.T) {
+	schemaGuard := middleware.SchemaGuard{
+		Schemas: map[string]middleware.SchemaValidator{
+			"": middleware.SchemaValidatorFunc(func(payload []byte) error {
+				return assert.AnError
+			}),
+		},
+	}
+
+	_, err := schemaGuard.Middleware(handlerFuncAlwaysOK)(message.NewMessage("uuid", nil))
+
+	var validationErr middleware.SchemaValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, "uuid", validationErr.MessageUUID)
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -0,0 +1,247 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// SenderQuotaExceededMetadataKey is set on messages rerouted to SenderQuotaConfig.DeadLetterTopic,
+// with the sender exceeding the quota.
+const SenderQuotaExceededMetadataKey = "_watermill_sender_quota_exceeded"
+
+// SenderQuotaAction is the action taken for messages of senders exceeding their quota.
+type SenderQuotaAction string
+
+const (
+	// SenderQuotaThrottle delays handling the message until the sender's quota is available again.
+	SenderQuotaThrottle SenderQuotaAction = "throttle"
+
+	// SenderQuotaDeadLetter publishes the message to SenderQuotaConfig.DeadLetterTopic without handling it.
+	SenderQuotaDeadLetter SenderQuotaAction = "dead_letter"
+)
+
+// SenderQuotaConfig configures the SenderQuota middleware.
+type SenderQuotaConfig struct {
+	// SenderMetadataKey is the metadata key identifying the sender of the message, for example, the producer
+	// or the tenant. It is required. Messages without the key are not limited.
+	SenderMetadataKey string
+
+	// Limit is the maximum number of messages of every sender handled within Interval. It is required.
+	Limit int
+
+	// SenderLimits overrides Limit for the listed senders.
+	SenderLimits map[string]int
+
+	// Interval is the quota window. Defaults to 1s.
+	Interval time.Duration
+
+	// Action is the action taken for messages exceeding the quota. Defaults to SenderQuotaThrottle.
+	Action SenderQuotaAction
+
+	// DeadLetterTopic is the topic to which the messages exceeding the quota are published with Publisher.
+	// It is required with SenderQuotaDeadLetter.
+	DeadLetterTopic string
+
+	// Publisher is used to publish the messages to DeadLetterTopic. It is required with SenderQuotaDeadLetter.
+	Publisher message.Publisher
+
+	// OnExceeded is an optional function called for every message exceeding the quota,
+	// for example, to count them (see metrics.PrometheusMetricsBuilder.NewSenderQuotaExceededCounter).
+	OnExceeded func(msg *message.Message, sender string)
+
+	// Clock is used to measure the quota windows.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *SenderQuotaConfig) setDefaults() {
+	if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+	if c.Action == "" {
+		c.Action = SenderQuotaThrottle
+	}
+	if c.Clock == nil {
+		c.Clock = watermill.RealClock{}
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+func (c SenderQuotaConfig) Validate() error {
+	if c.SenderMetadataKey == "" {
+		return errors.New("missing SenderMetadataKey")
+	}
+	if c.Limit <= 0 {
+		return errors.New("Limit must be positive")
+	}
+	for sender, limit := range c.SenderLimits {
+		if limit <= 0 {
+			return errors.Errorf("limit of sender %s must be positive", sender)
+		}
+	}
+	if c.Interval < 0 {
+		return errors.New("Interval must not be negative")
+	}
+
+	switch c.Action {
+	case SenderQuotaThrottle:
+	case SenderQuotaDeadLetter:
+		if c.DeadLetterTopic == "" || c.Publisher == nil {
+			return errors.New("DeadLetterTopic and Publisher are required with SenderQuotaDeadLetter")
+		}
+	default:
+		return errors.Errorf("unknown Action %s", c.Action)
+	}
+
+	return nil
+}
+
+type senderWindow struct {
+	start time.Time
+	count int
+}
+
+// SenderQuota enforces the consumption quotas of the senders identified by the metadata (for example, producers
+// or tenants), so a single noisy sender can't starve the others on a shared consumer.
+//
+// Every sender can have Limit messages handled within a window of Interval. Messages exceeding the quota
+// are throttled until the next window, or published to the dead letter topic without handling.
+//
+// The quotas are counted per middleware instance, so they are not shared between handlers or service instances,
+// unless the same SenderQuota is used as the middleware of multiple handlers.
+type SenderQuota struct {
+	config SenderQuotaConfig
+
+	windows     map[string]*senderWindow
+	lastCleanup time.Time
+	lock        sync.Mutex
+}
+
+// NewSenderQuota creates a new SenderQuota middleware.
+func NewSenderQuota(config SenderQuotaConfig) (*SenderQuota, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	return &SenderQuota{
+		config:      config,
+		windows:     map[string]*senderWindow{},
+		lastCleanup: config.Clock.Now(),
+	}, nil
+}
+
+// Middleware returns the SenderQuota middleware.
+func (q *SenderQuota) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		sender := msg.Metadata.Get(q.config.SenderMetadataKey)
+		if sender == "" {
+			return h(msg)
+		}
+
+		wait, allowed := q.take(sender)
+		if allowed {
+			return h(msg)
+		}
+
+		if q.config.OnExceeded != nil {
+			q.config.OnExceeded(msg, sender)
+		}
+
+		logFields := watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"sender":       sender,
+		}
+
+		if q.config.Action == SenderQuotaDeadLetter {
+			return nil, q.deadLetter(msg, sender, logFields)
+		}
+
+		q.config.Logger.Trace("Throttling message exceeding the sender quota", logFields.Add(watermill.LogFields{
+			"wait": wait,
+		}))
+
+		for !allowed {
+			select {
+			case <-q.config.Clock.After(wait):
+			case <-msg.Context().Done():
+				return nil, errors.Wrap(msg.Context().Err(), "context closed while throttled by sender quota")
+			}
+
+			wait, allowed = q.take(sender)
+		}
+
+		return h(msg)
+	}
+}
+
+// take counts the message in the sender's window. If the quota is exceeded, it returns the time left
+// until the next window.
+func (q *SenderQuota) take(sender string) (time.Duration, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	now := q.config.Clock.Now()
+	q.cleanup(now)
+
+	window, ok := q.windows[sender]
+	if !ok || now.Sub(window.start) >= q.config.Interval {
+		window = &senderWindow{start: now}
+		q.windows[sender] = window
+	}
+
+	if window.count >= q.limit(sender) {
+		return window.start.Add(q.config.Interval).Sub(now), false
+	}
+
+	window.count++
+
+	return 0, true
+}
+
+// cleanup removes the expired windows, so senders that stopped sending don't use memory.
+func (q *SenderQuota) cleanup(now time.Time) {
+	if now.Sub(q.lastCleanup) < q.config.Interval {
+		return
+	}
+
+	for sender, window := range q.windows {
+		if now.Sub(window.start) >= q.config.Interval {
+			delete(q.windows, sender)
+		}
+	}
+	q.lastCleanup = now
+}
+
+func (q *SenderQuota) limit(sender string) int {
+	if limit, ok := q.config.SenderLimits[sender]; ok {
+		return limit
+	}
+
+	return q.config.Limit
+}
+
+func (q *SenderQuota) deadLetter(msg *message.Message, sender string, logFields watermill.LogFields) error {
+	q.config.Logger.Info("Rerouting message exceeding the sender quota", logFields.Add(watermill.LogFields{
+		"topic": q.config.DeadLetterTopic,
+	}))
+
+	msg.Metadata.Set(SenderQuotaExceededMetadataKey, sender)
+
+	if err := q.config.Publisher.Publish(q.config.DeadLetterTopic, msg); err != nil {
+		return errors.Wrap(err, "cannot publish message exceeding the sender quota")
+	}
+
+	return nil
+}
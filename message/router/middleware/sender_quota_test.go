@@ -0,0 +1,167 @@
+package middleware_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func senderMessage(uuid string, sender string) *message.Message {
+	msg := message.NewMessage(uuid, nil)
+	if sender != "" {
+		msg.Metadata.Set("tenant", sender)
+	}
+	return msg
+}
+
+func TestSenderQuota_dead_letter(t *testing.T) {
+	publisher := &capturingPublisher{}
+	exceeded := map[string]int{}
+
+	quota, err := middleware.NewSenderQuota(middleware.SenderQuotaConfig{
+		SenderMetadataKey: "tenant",
+		Limit:             2,
+		SenderLimits:      map[string]int{"premium": 3},
+		Interval:          time.Minute,
+		Action:            middleware.SenderQuotaDeadLetter,
+		DeadLetterTopic:   "quota_exceeded",
+		Publisher:         publisher,
+		OnExceeded: func(msg *message.Message, sender string) {
+			exceeded[sender]++
+		},
+		Clock: watermill.NewFakeClock(time.Now()),
+	})
+	require.NoError(t, err)
+
+	var handled []string
+	h := quota.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled = append(handled, msg.UUID)
+		return nil, nil
+	})
+
+	messages := []*message.Message{
+		senderMessage("noisy-1", "noisy"),
+		senderMessage("noisy-2", "noisy"),
+		senderMessage("noisy-3", "noisy"),
+		senderMessage("noisy-4", "noisy"),
+		senderMessage("quiet-1", "quiet"),
+		senderMessage("premium-1", "premium"),
+		senderMessage("premium-2", "premium"),
+		senderMessage("premium-3", "premium"),
+		senderMessage("premium-4", "premium"),
+		senderMessage("unknown-1", ""),
+		senderMessage("unknown-2", ""),
+		senderMessage("unknown-3", ""),
+	}
+	for _, msg := range messages {
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{
+		"noisy-1", "noisy-2",
+		"quiet-1",
+		"premium-1", "premium-2", "premium-3",
+		"unknown-1", "unknown-2", "unknown-3",
+	}, handled)
+	assert.Equal(t, map[string]int{"noisy": 2, "premium": 1}, exceeded)
+
+	deadLettered := publisher.published["quota_exceeded"]
+	require.Len(t, deadLettered, 3)
+	assert.Equal(t, "noisy-3", deadLettered[0].UUID)
+	assert.Equal(t, "noisy", deadLettered[0].Metadata.Get(middleware.SenderQuotaExceededMetadataKey))
+	assert.Equal(t, "premium-4", deadLettered[2].UUID)
+}
+
+func TestSenderQuota_throttle(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Now())
+
+	quota, err := middleware.NewSenderQuota(middleware.SenderQuotaConfig{
+		SenderMetadataKey: "tenant",
+		Limit:             1,
+		Interval:          time.Second,
+		Clock:             clock,
+	})
+	require.NoError(t, err)
+
+	handled := make(chan string, 3)
+	h := quota.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled <- msg.UUID
+		return nil, nil
+	})
+
+	_, err = h(senderMessage("noisy-1", "noisy"))
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h(senderMessage("noisy-2", "noisy"))
+		done <- err
+	}()
+
+	clock.BlockUntilWaiters(1)
+
+	// other senders are not throttled
+	_, err = h(senderMessage("quiet-1", "quiet"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "noisy-1", <-handled)
+	assert.Equal(t, "quiet-1", <-handled)
+
+	clock.Advance(time.Second)
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+		assert.Equal(t, "noisy-2", <-handled)
+	case <-time.After(time.Second):
+		t.Fatal("message should be handled in the next window")
+	}
+}
+
+func TestSenderQuota_throttle_context_canceled(t *testing.T) {
+	quota, err := middleware.NewSenderQuota(middleware.SenderQuotaConfig{
+		SenderMetadataKey: "tenant",
+		Limit:             1,
+		Interval:          time.Hour,
+	})
+	require.NoError(t, err)
+
+	h := quota.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	_, err = h(senderMessage("1", "noisy"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	msg := senderMessage("2", "noisy")
+	msg.SetContext(ctx)
+
+	_, err = h(msg)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSenderQuota_invalid_config(t *testing.T) {
+	testCases := []middleware.SenderQuotaConfig{
+		{Limit: 1},
+		{SenderMetadataKey: "tenant"},
+		{SenderMetadataKey: "tenant", Limit: 1, SenderLimits: map[string]int{"a": 0}},
+		{SenderMetadataKey: "tenant", Limit: 1, Action: middleware.SenderQuotaDeadLetter},
+		{SenderMetadataKey: "tenant", Limit: 1, Action: "drop"},
+	}
+
+	for _, config := range testCases {
+		_, err := middleware.NewSenderQuota(config)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ShardKeyFunc returns the key used to assign the message to a shard.
+type ShardKeyFunc func(msg *message.Message) string
+
+// ShardKeyFromMetadata returns a ShardKeyFunc which uses the value of the metadata key as the shard key.
+func ShardKeyFromMetadata(key string) ShardKeyFunc {
+	return func(msg *message.Message) string {
+		return msg.Metadata.Get(key)
+	}
+}
+
+// ShardingConfig holds the Sharding middleware's configuration options.
+type ShardingConfig struct {
+	// Shards is the number of workers processing messages.
+	// Messages from different shards are processed concurrently.
+	Shards int
+
+	// KeyFunc returns the key of the message. Messages with the same key are always processed by the same shard.
+	// Use ShardKeyFromMetadata to use a metadata value (for example, a tenant ID) as the key.
+	KeyFunc ShardKeyFunc
+
+	// QueueSize is the number of messages that can wait for processing in each shard.
+	// Defaults to 0, which means that the message waits until the shard's worker is ready to process it.
+	QueueSize int
+}
+
+// Validate returns the config's error, if any.
+func (c ShardingConfig) Validate() error {
+	if c.Shards < 1 {
+		return errors.New("shards must be greater than 0")
+	}
+	if c.KeyFunc == nil {
+		return errors.New("missing KeyFunc")
+	}
+	if c.QueueSize < 0 {
+		return errors.New("queue size must not be negative")
+	}
+
+	return nil
+}
+
+// Sharding provides a middleware that distributes messages by their key to a fixed number of in-process workers.
+//
+// Each worker (shard) processes its messages one by one, and all shards work concurrently.
+// It means that messages with the same key are never processed concurrently,
+// and they are processed in the order in which they reach the middleware.
+// It gives key-level ordering with bounded parallelism, without relying on the Pub/Sub's partitioning.
+//
+// Keep in mind that the message is acked or nacked by the router as usual, after the shard processed it.
+// Panics of the handler are recovered in the shard and returned as RecoveredPanicError.
+//
+// One Sharding instance can be used by multiple handlers. Close should be called when it's no longer used.
+type Sharding struct {
+	config ShardingConfig
+	shards []chan shardJob
+
+	closing   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+	workersWg sync.WaitGroup
+}
+
+type shardJob struct {
+	msg     *message.Message
+	handler message.HandlerFunc
+	result  chan shardJobResult
+}
+
+type shardJobResult struct {
+	messages []*message.Message
+	err      error
+}
+
+// ErrShardingClosed is returned by the Sharding middleware when a message is received after Close was called.
+var ErrShardingClosed = errors.New("sharding middleware is closed")
+
+// NewSharding creates a new Sharding middleware and starts its workers.
+func NewSharding(config ShardingConfig) (*Sharding, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
+	s := &Sharding{
+		config:  config,
+		shards:  make([]chan shardJob, config.Shards),
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	for i := range s.shards {
+		s.shards[i] = make(chan shardJob, config.QueueSize)
+
+		s.workersWg.Add(1)
+		go s.runWorker(s.shards[i])
+	}
+
+	return s, nil
+}
+
+func (s *Sharding) runWorker(jobs chan shardJob) {
+	defer s.workersWg.Done()
+
+	for {
+		select {
+		case job := <-jobs:
+			// the router can't recover panics of the shard's goroutine, so it would crash the process
+			messages, err := Recoverer(job.handler)(job.msg)
+			job.result <- shardJobResult{messages: messages, err: err}
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+func (s *Sharding) shardFor(msg *message.Message) chan shardJob {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s.config.KeyFunc(msg)))
+
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// Middleware returns the Sharding middleware.
+func (s *Sharding) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		job := shardJob{
+			msg:     msg,
+			handler: h,
+			result:  make(chan shardJobResult, 1),
+		}
+
+		select {
+		case s.shardFor(msg) <- job:
+		case <-msg.Context().Done():
+			return nil, errors.Wrap(msg.Context().Err(), "message context done before it was processed by shard")
+		case <-s.closing:
+			return nil, ErrShardingClosed
+		}
+
+		select {
+		case result := <-job.result:
+			return result.messages, result.err
+		case <-s.closed:
+			// the job could be processed just before closing
+			select {
+			case result := <-job.result:
+				return result.messages, result.err
+			default:
+				return nil, ErrShardingClosed
+			}
+		}
+	}
+}
+
+// Close stops the shards' workers after they finish processing the current messages.
+// Messages waiting in the queues are not processed and the middleware returns ErrShardingClosed for them.
+func (s *Sharding) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+		s.workersWg.Wait()
+		close(s.closed)
+	})
+	<-s.closed
+}
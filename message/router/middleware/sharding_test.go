@@ -0,0 +1,177 @@
+package middleware_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestShardingConfig_Validate(t *testing.T) {
+	_, err := middleware.NewSharding(middleware.ShardingConfig{
+		Shards:  0,
+		KeyFunc: middleware.ShardKeyFromMetadata("tenant"),
+	})
+	assert.ErrorContains(t, err, "shards must be greater than 0")
+
+	_, err = middleware.NewSharding(middleware.ShardingConfig{
+		Shards: 1,
+	})
+	assert.ErrorContains(t, err, "missing KeyFunc")
+}
+
+func TestSharding_same_key_processed_serially(t *testing.T) {
+	sharding, err := middleware.NewSharding(middleware.ShardingConfig{
+		Shards:  4,
+		KeyFunc: middleware.ShardKeyFromMetadata("tenant"),
+	})
+	require.NoError(t, err)
+	defer sharding.Close()
+
+	lock := sync.Mutex{}
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	processed := map[string][]string{}
+
+	h := sharding.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		tenant := msg.Metadata.Get("tenant")
+
+		lock.Lock()
+		running[tenant]++
+		if running[tenant] > maxRunning[tenant] {
+			maxRunning[tenant] = running[tenant]
+		}
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		lock.Lock()
+		running[tenant]--
+		processed[tenant] = append(processed[tenant], msg.UUID)
+		lock.Unlock()
+
+		return nil, nil
+	})
+
+	tenants := []string{"a", "b", "c", "d", "e"}
+	messagesPerTenant := 10
+
+	wg := sync.WaitGroup{}
+	for _, tenant := range tenants {
+		tenant := tenant
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// messages for one tenant are delivered in order
+			for i := 0; i < messagesPerTenant; i++ {
+				msg := message.NewMessage(fmt.Sprintf("%s-%d", tenant, i), nil)
+				msg.Metadata.Set("tenant", tenant)
+
+				_, err := h(msg)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tenant := range tenants {
+		assert.Equal(t, 1, maxRunning[tenant], "messages of tenant %s were processed concurrently", tenant)
+
+		var expected []string
+		for i := 0; i < messagesPerTenant; i++ {
+			expected = append(expected, fmt.Sprintf("%s-%d", tenant, i))
+		}
+		assert.Equal(t, expected, processed[tenant])
+	}
+}
+
+func TestSharding_different_shards_processed_concurrently(t *testing.T) {
+	sharding, err := middleware.NewSharding(middleware.ShardingConfig{
+		Shards: 2,
+		KeyFunc: func(msg *message.Message) string {
+			return msg.UUID
+		},
+	})
+	require.NoError(t, err)
+	defer sharding.Close()
+
+	// "1" and "2" are hashed to different shards
+	firstStarted := make(chan struct{})
+	releaseFirst := make(chan struct{})
+
+	h := sharding.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		if msg.UUID == "1" {
+			close(firstStarted)
+			<-releaseFirst
+		}
+		return []*message.Message{msg}, nil
+	})
+
+	go func() {
+		_, _ = h(message.NewMessage("1", nil))
+	}()
+	<-firstStarted
+
+	done := make(chan struct{})
+	go func() {
+		msgs, err := h(message.NewMessage("2", nil))
+		assert.NoError(t, err)
+		assert.Len(t, msgs, 1)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		// ok
+	case <-time.After(time.Second):
+		t.Fatal("message from another shard should not be blocked")
+	}
+
+	close(releaseFirst)
+}
+
+func TestSharding_Close(t *testing.T) {
+	sharding, err := middleware.NewSharding(middleware.ShardingConfig{
+		Shards:  1,
+		KeyFunc: middleware.ShardKeyFromMetadata("tenant"),
+	})
+	require.NoError(t, err)
+
+	sharding.Close()
+	sharding.Close()
+
+	_, err = sharding.Middleware(handlerFuncAlwaysOK)(message.NewMessage("1", nil))
+	assert.ErrorIs(t, err, middleware.ErrShardingClosed)
+}
+
+func TestSharding_handler_panic(t *testing.T) {
+	sharding, err := middleware.NewSharding(middleware.ShardingConfig{
+		Shards:  1,
+		KeyFunc: middleware.ShardKeyFromMetadata("tenant"),
+	})
+	require.NoError(t, err)
+	defer sharding.Close()
+
+	h := sharding.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		if msg.UUID == "panic" {
+			panic("bad message")
+		}
+		return nil, nil
+	})
+
+	_, err = h(message.NewMessage("panic", nil))
+	var panicErr middleware.RecoveredPanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "bad message", panicErr.V)
+
+	_, err = h(message.NewMessage("ok", nil))
+	assert.NoError(t, err, "the shard should still process messages after the panic")
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DropExpired is a middleware that drops (acks without handling) messages which expired
+// before they were handled. Expiry is read from message.ExpiresAtMetadataKey, see message.SetTTL.
+//
+// Messages without the expiry metadata are always handled.
+type DropExpired struct {
+	// OnExpired is an optional function called for every dropped message,
+	// for example, to count them (see metrics.PrometheusMetricsBuilder.NewExpiredMessagesCounter).
+	OnExpired func(msg *message.Message, expiredFor time.Duration)
+
+	// Clock is used to check if the message expired.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
+	Logger watermill.LoggerAdapter
+}
+
+// Middleware returns the DropExpired middleware.
+func (d DropExpired) Middleware(h message.HandlerFunc) message.HandlerFunc {
+	return func(msg *message.Message) ([]*message.Message, error) {
+		expiresAt, ok := message.ExpiresAt(msg)
+		if !ok {
+			return h(msg)
+		}
+
+		clock := d.Clock
+		if clock == nil {
+			clock = watermill.RealClock{}
+		}
+
+		now := clock.Now()
+		if now.Before(expiresAt) {
+			return h(msg)
+		}
+
+		expiredFor := now.Sub(expiresAt)
+
+		if d.Logger != nil {
+			d.Logger.Debug("Dropping expired message", watermill.LogFields{
+				"message_uuid": msg.UUID,
+				"expires_at":   expiresAt,
+				"expired_for":  expiredFor,
+			})
+		}
+		if d.OnExpired != nil {
+			d.OnExpired(msg, expiredFor)
+		}
+
+		return nil, nil
+	}
+}
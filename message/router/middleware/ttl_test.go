@@ -0,0 +1,49 @@
+package middleware_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/router/middleware"
+)
+
+func TestDropExpired(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var handled []string
+	var expired []string
+	var expiredFor []time.Duration
+
+	h := middleware.DropExpired{
+		OnExpired: func(msg *message.Message, d time.Duration) {
+			expired = append(expired, msg.UUID)
+			expiredFor = append(expiredFor, d)
+		},
+		Clock: watermill.NewFakeClock(now),
+	}.Middleware(func(msg *message.Message) ([]*message.Message, error) {
+		handled = append(handled, msg.UUID)
+		return nil, nil
+	})
+
+	noExpiry := message.NewMessage("no_expiry", nil)
+
+	valid := message.NewMessage("valid", nil)
+	message.SetExpiresAt(valid, now.Add(time.Second))
+
+	expiredMsg := message.NewMessage("expired", nil)
+	message.SetExpiresAt(expiredMsg, now.Add(-time.Minute))
+
+	for _, msg := range []*message.Message{noExpiry, valid, expiredMsg} {
+		_, err := h(msg)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"no_expiry", "valid"}, handled)
+	assert.Equal(t, []string{"expired"}, expired)
+	assert.Equal(t, []time.Duration{time.Minute}, expiredFor)
+}
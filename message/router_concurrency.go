@@ -0,0 +1,118 @@
+package message
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// HandlerConcurrency configures how many messages received by the handler are processed in parallel,
+// independently of the subscriber's configuration.
+//
+// Keep in mind that most subscribers don't deliver the next message before the previous one is acked.
+// The workers can process messages in parallel only if the subscriber delivers multiple messages
+// at the same time (for example, with prefetching or a buffered output channel).
+type HandlerConcurrency struct {
+	// Workers is the number of goroutines pulling messages from the handler's subscription.
+	// Every worker handles one message at a time, so no more than Workers messages are handled at the same time.
+	//
+	// If 0, every message is handled in a separate goroutine as soon as it's received.
+	// With 1 worker, messages are handled one by one, in the order they were received.
+	Workers int
+
+	// AllowOutOfOrder must be enabled when Workers is greater than 1, to explicitly confirm that
+	// messages can be handled in a different order than they were received.
+	AllowOutOfOrder bool
+}
+
+// Validate returns the configuration error, if any.
+func (c HandlerConcurrency) Validate() error {
+	if c.Workers < 0 {
+		return errors.New("Workers must not be negative")
+	}
+	if c.Workers > 1 && !c.AllowOutOfOrder {
+		return errors.New("more than one worker handles messages out of order, enable AllowOutOfOrder to confirm it")
+	}
+
+	return nil
+}
+
+// SetConcurrency sets the concurrency of the handler, overriding RouterConfig.TopicConcurrency.
+//
+// SetConcurrency must be called before the handler is started.
+func (h *Handler) SetConcurrency(concurrency HandlerConcurrency) error {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	if err := concurrency.Validate(); err != nil {
+		return errors.Wrap(err, "invalid concurrency")
+	}
+
+	h.handler.concurrency = concurrency
+
+	return nil
+}
+
+// runWorkers handles messages from the subscription with HandlerConcurrency.Workers goroutines,
+// until the subscription is closed.
+func (h *handler) runWorkers(middlewareHandler *sharedMiddlewareHandler) {
+	messages := h.messagesCh
+
+	h.runWorkerPool(h.concurrency.Workers, func() (*Message, bool) {
+		msg, ok := <-messages
+		return msg, ok
+	}, middlewareHandler)
+}
+
+// runWorkerPool handles messages returned by next with the workers goroutines, until next returns false.
+func (h *handler) runWorkerPool(workers int, next func() (*Message, bool), middlewareHandler *sharedMiddlewareHandler) {
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				msg, ok := next()
+				if !ok {
+					return
+				}
+
+				h.runningHandlersWgLock.Lock()
+				h.runningHandlersWg.Add(1)
+				h.runningHandlersWgLock.Unlock()
+
+				h.handleMessage(msg, middlewareHandler.get())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// sharedMiddlewareHandler builds the handler function with the middlewares once per version of the middlewares
+// (middlewares can be added or removed while the router is running), and shares it between the workers, so stateful middlewares (like deduplication, sampling, or rate limiting)
+// keep their state per handler, not per worker.
+type sharedMiddlewareHandler struct {
+	handler            *handler
+	currentMiddlewares func() ([]middleware, int)
+
+	lock        sync.Mutex
+	handlerFunc HandlerFunc
+	version     int
+}
+
+func (s *sharedMiddlewareHandler) get() HandlerFunc {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	middlewares, version := s.currentMiddlewares()
+	if s.handlerFunc == nil || version != s.version {
+		s.handlerFunc = s.handler.handlerFuncWithMiddlewares(middlewares)
+		s.version = version
+	}
+
+	return s.handlerFunc
+}
@@ -0,0 +1,194 @@
+package message_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// bufferedSubscriber delivers all messages at once, without waiting for acks.
+type bufferedSubscriber struct {
+	messages []*message.Message
+}
+
+func (s bufferedSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	out := make(chan *message.Message, len(s.messages))
+	for _, msg := range s.messages {
+		out <- msg
+	}
+
+	go func() {
+		<-ctx.Done()
+		close(out)
+	}()
+
+	return out, nil
+}
+
+func (s bufferedSubscriber) Close() error {
+	return nil
+}
+
+func newBufferedSubscriber(count int) bufferedSubscriber {
+	sub := bufferedSubscriber{}
+	for i := 0; i < count; i++ {
+		sub.messages = append(sub.messages, message.NewMessage(strconv.Itoa(i), nil))
+	}
+	return sub
+}
+
+func TestRouter_TopicConcurrency(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{
+		TopicConcurrency: map[string]message.HandlerConcurrency{
+			"topic": {Workers: 3, AllowOutOfOrder: true},
+		},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	var running, maxRunning, handled atomic.Int32
+	releaseHandlers := make(chan struct{})
+
+	router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(10), func(msg *message.Message) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			max := maxRunning.Load()
+			if current <= max || maxRunning.CompareAndSwap(max, current) {
+				break
+			}
+		}
+
+		<-releaseHandlers
+		handled.Add(1)
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	require.Eventually(t, func() bool {
+		return running.Load() == 3
+	}, time.Second, time.Millisecond)
+
+	// no more workers should pick up messages
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, int32(3), running.Load())
+
+	close(releaseHandlers)
+
+	require.Eventually(t, func() bool {
+		return handled.Load() == 10
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(3), maxRunning.Load())
+}
+
+func TestHandler_SetConcurrency_single_worker_keeps_order(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	lock := sync.Mutex{}
+	var received []string
+	done := make(chan struct{})
+
+	handler := router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(20), func(msg *message.Message) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		received = append(received, msg.UUID)
+		if len(received) == 20 {
+			close(done)
+		}
+		return nil
+	})
+	require.NoError(t, handler.SetConcurrency(message.HandlerConcurrency{Workers: 1}))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("messages not handled")
+	}
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		expected = append(expected, strconv.Itoa(i))
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, expected, received)
+}
+
+func TestHandlerConcurrency_Validate(t *testing.T) {
+	_, err := message.NewRouter(message.RouterConfig{
+		TopicConcurrency: map[string]message.HandlerConcurrency{
+			"topic": {Workers: 2},
+		},
+	}, watermill.NopLogger{})
+	assert.ErrorContains(t, err, "invalid TopicConcurrency of topic topic: more than one worker handles messages out of order")
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(0), func(msg *message.Message) error {
+		return nil
+	})
+	assert.ErrorContains(t, handler.SetConcurrency(message.HandlerConcurrency{Workers: -1}), "Workers must not be negative")
+}
+
+func TestRouter_TopicConcurrency_middlewares_shared_between_workers(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{
+		TopicConcurrency: map[string]message.HandlerConcurrency{
+			"topic": {Workers: 3, AllowOutOfOrder: true},
+		},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	var builds atomic.Int32
+	router.AddMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		builds.Add(1)
+		return h
+	})
+
+	var handled atomic.Int32
+	router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(10), func(msg *message.Message) error {
+		handled.Add(1)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+
+	assert.Eventually(t, func() bool {
+		return handled.Load() == 10
+	}, time.Second, time.Millisecond)
+
+	assert.EqualValues(t, 1, builds.Load(), "middlewares should be built once for all workers")
+}
@@ -0,0 +1,218 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// FeatureFlagProvider provides the state of the feature flags gating the handlers (see HandlerFeatureFlag).
+type FeatureFlagProvider interface {
+	// IsEnabled returns true if the flag is enabled.
+	IsEnabled(ctx context.Context, flag string) (bool, error)
+}
+
+// FeatureFlagCheck is the moment when the feature flag of the handler is checked.
+type FeatureFlagCheck string
+
+const (
+	// FeatureFlagCheckMessage checks the flag for every received message.
+	// Messages received while the flag is disabled are acked without handling,
+	// unless HandlerFeatureFlag.NackWhenDisabled is set.
+	FeatureFlagCheckMessage FeatureFlagCheck = "message"
+
+	// FeatureFlagCheckSubscribe checks the flag before the handler subscribes to its topics.
+	// While the flag is disabled, the handler is not subscribed, so the messages are kept by the broker
+	// (for example, in the handler's consumer group), and the flag is checked every PollInterval.
+	// The handler is started when the flag is enabled.
+	//
+	// Disabling the flag doesn't stop the running handler.
+	FeatureFlagCheckSubscribe FeatureFlagCheck = "subscribe"
+)
+
+// ErrHandlerDisabled is returned by the handler gated with HandlerFeatureFlag when the message is nacked
+// because the flag is disabled.
+var ErrHandlerDisabled = errors.New("handler is disabled by the feature flag")
+
+// HandlerFeatureFlag gates the handler with a feature flag, so rollouts of new consumers can be toggled without deploys.
+type HandlerFeatureFlag struct {
+	// Flag is the name of the flag passed to Provider.
+	Flag string
+
+	// Provider provides the state of the flag.
+	// EnvFeatureFlagProvider and FileFeatureFlagProvider are available, or you can implement your own
+	// for your feature flag service.
+	Provider FeatureFlagProvider
+
+	// Check is the moment when the flag is checked.
+	// If empty, FeatureFlagCheckMessage is used.
+	Check FeatureFlagCheck
+
+	// NackWhenDisabled makes the handler nack the messages received while the flag is disabled
+	// (with ErrHandlerDisabled), instead of acking them. It's used only with FeatureFlagCheckMessage.
+	//
+	// Keep in mind that most subscribers redeliver nacked messages immediately.
+	NackWhenDisabled bool
+
+	// PollInterval is the interval of checking the disabled flag. It's used only with FeatureFlagCheckSubscribe.
+	// If 0, 10 seconds is used.
+	PollInterval time.Duration
+}
+
+func (f *HandlerFeatureFlag) setDefaults() {
+	if f.Check == "" {
+		f.Check = FeatureFlagCheckMessage
+	}
+	if f.PollInterval == 0 {
+		f.PollInterval = time.Second * 10
+	}
+}
+
+// Validate returns the configuration error, if any.
+func (f HandlerFeatureFlag) Validate() error {
+	if f.Flag == "" {
+		return errors.New("missing Flag")
+	}
+	if f.Provider == nil {
+		return errors.New("missing Provider")
+	}
+	if f.Check != FeatureFlagCheckMessage && f.Check != FeatureFlagCheckSubscribe {
+		return errors.Errorf("unknown Check %s", f.Check)
+	}
+	if f.PollInterval < 0 {
+		return errors.New("PollInterval must not be negative")
+	}
+
+	return nil
+}
+
+// SetFeatureFlag gates the handler with the feature flag (see HandlerFeatureFlag).
+//
+// SetFeatureFlag must be called before the handler is started.
+func (h *Handler) SetFeatureFlag(flag HandlerFeatureFlag) error {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	flag.setDefaults()
+	if err := flag.Validate(); err != nil {
+		return errors.Wrap(err, "invalid feature flag")
+	}
+
+	h.handler.featureFlag = &flag
+
+	return nil
+}
+
+// featureFlagMiddleware checks the flag for every message, before other middlewares are executed.
+func (h *handler) featureFlagMiddleware(next HandlerFunc) HandlerFunc {
+	flag := h.featureFlag
+
+	return func(msg *Message) ([]*Message, error) {
+		enabled, err := flag.Provider.IsEnabled(msg.Context(), flag.Flag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot check feature flag %s", flag.Flag)
+		}
+		if enabled {
+			return next(msg)
+		}
+
+		h.logger.Trace("Skipping message, handler disabled by feature flag", watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"feature_flag": flag.Flag,
+			"nack":         flag.NackWhenDisabled,
+		})
+
+		if flag.NackWhenDisabled {
+			return nil, ErrHandlerDisabled
+		}
+
+		return nil, nil
+	}
+}
+
+// disabledAtSubscribe returns true if the handler should not be subscribed yet because of its feature flag.
+// If the flag is disabled, it starts polling the flag, and runs the handler when it's enabled.
+//
+// It must be called with the router's handlersLock held.
+func (r *Router) disabledAtSubscribe(ctx context.Context, h *handler) bool {
+	if h.featureFlag == nil || h.featureFlag.Check != FeatureFlagCheckSubscribe {
+		return false
+	}
+
+	logFields := watermill.LogFields{"feature_flag": h.featureFlag.Flag}
+
+	enabled, err := h.featureFlag.Provider.IsEnabled(ctx, h.featureFlag.Flag)
+	if err != nil {
+		h.logger.Error("Cannot check feature flag, handler not started", err, logFields)
+	}
+	if enabled {
+		return false
+	}
+
+	if !h.featureFlagPolling {
+		h.logger.Info("Handler disabled by feature flag, waiting until it's enabled", logFields)
+
+		h.featureFlagPolling = true
+		go r.pollFeatureFlag(ctx, h)
+	}
+
+	return true
+}
+
+func (r *Router) pollFeatureFlag(ctx context.Context, h *handler) {
+	logFields := watermill.LogFields{"feature_flag": h.featureFlag.Flag}
+
+	ticker := time.NewTicker(h.featureFlag.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			// the handler will never start, so the router doesn't wait for it when closing
+			r.handlersWg.Done()
+			return
+		case <-r.closingInProgressCh:
+			r.handlersWg.Done()
+			return
+		}
+
+		enabled, err := h.featureFlag.Provider.IsEnabled(ctx, h.featureFlag.Flag)
+		if err != nil {
+			h.logger.Error("Cannot check feature flag", err, logFields)
+			continue
+		}
+		if !enabled {
+			continue
+		}
+
+		h.logger.Info("Handler enabled by feature flag, starting", logFields)
+
+		r.handlersLock.Lock()
+		h.featureFlagPolling = false
+		select {
+		case <-r.closingInProgressCh:
+			r.handlersLock.Unlock()
+			r.handlersWg.Done()
+			return
+		default:
+		}
+		if r.handlers[h.name] != h {
+			// removed with RemoveHandler while waiting for the flag
+			r.handlersLock.Unlock()
+			r.handlersWg.Done()
+			return
+		}
+		r.handlersLock.Unlock()
+
+		if err := r.RunHandlers(ctx); err != nil {
+			h.logger.Error("Cannot start handler enabled by feature flag", err, logFields)
+		}
+
+		return
+	}
+}
@@ -0,0 +1,104 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EnvFeatureFlagProvider reads the feature flags from the environment variables.
+//
+// The variable name is Prefix followed by the flag name in upper case, with the characters other than
+// letters and digits replaced with "_". For example, the flag "new-consumer" is read from NEW_CONSUMER.
+// The values are parsed with strconv.ParseBool. Flags without the variable are disabled.
+type EnvFeatureFlagProvider struct {
+	// Prefix of the variable names, for example "FEATURE_".
+	Prefix string
+}
+
+// IsEnabled returns true if the flag is enabled.
+func (p EnvFeatureFlagProvider) IsEnabled(ctx context.Context, flag string) (bool, error) {
+	name := p.Prefix + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(flag))
+
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid value of %s", name)
+	}
+
+	return enabled, nil
+}
+
+// FileFeatureFlagProvider reads the feature flags from a JSON file with the states of the flags,
+// for example {"new-consumer": true}. Flags missing in the file are disabled.
+//
+// The file is read again when its modification time or size changes, so the flags can be toggled
+// by updating the file (for example, a mounted Kubernetes ConfigMap).
+type FileFeatureFlagProvider struct {
+	path string
+
+	flags   map[string]bool
+	modTime time.Time
+	size    int64
+	lock    sync.Mutex
+}
+
+// NewFileFeatureFlagProvider creates a new FileFeatureFlagProvider reading the file at path.
+// The file is read on the first check.
+func NewFileFeatureFlagProvider(path string) *FileFeatureFlagProvider {
+	return &FileFeatureFlagProvider{path: path}
+}
+
+// IsEnabled returns true if the flag is enabled.
+func (p *FileFeatureFlagProvider) IsEnabled(ctx context.Context, flag string) (bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if err := p.reloadIfChanged(); err != nil {
+		return false, err
+	}
+
+	return p.flags[flag], nil
+}
+
+func (p *FileFeatureFlagProvider) reloadIfChanged() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return errors.Wrap(err, "cannot stat feature flags file")
+	}
+
+	if p.flags != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return nil
+	}
+
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return errors.Wrap(err, "cannot read feature flags file")
+	}
+
+	flags := map[string]bool{}
+	if err := json.Unmarshal(b, &flags); err != nil {
+		return errors.Wrapf(err, "cannot unmarshal feature flags file %s", p.path)
+	}
+
+	p.flags = flags
+	p.modTime = info.ModTime()
+	p.size = info.Size()
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package message_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestEnvFeatureFlagProvider(t *testing.T) {
+	t.Setenv("FEATURE_NEW_CONSUMER", "true")
+	t.Setenv("FEATURE_OLD_CONSUMER", "0")
+	t.Setenv("FEATURE_BROKEN", "maybe")
+
+	provider := message.EnvFeatureFlagProvider{Prefix: "FEATURE_"}
+
+	enabled, err := provider.IsEnabled(context.Background(), "new-consumer")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	enabled, err = provider.IsEnabled(context.Background(), "old_consumer")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	enabled, err = provider.IsEnabled(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	_, err = provider.IsEnabled(context.Background(), "broken")
+	assert.Error(t, err)
+}
+
+func TestFileFeatureFlagProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"new-consumer": false}`), 0600))
+
+	provider := message.NewFileFeatureFlagProvider(path)
+
+	enabled, err := provider.IsEnabled(context.Background(), "new-consumer")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"new-consumer": true}`), 0600))
+	// the modification time may have a coarse resolution, so it's changed explicitly
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	enabled, err = provider.IsEnabled(context.Background(), "new-consumer")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+
+	enabled, err = provider.IsEnabled(context.Background(), "missing")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute*2)))
+
+	_, err = provider.IsEnabled(context.Background(), "new-consumer")
+	assert.Error(t, err)
+}
+
+func TestFileFeatureFlagProvider_missing_file(t *testing.T) {
+	provider := message.NewFileFeatureFlagProvider(filepath.Join(t.TempDir(), "missing.json"))
+
+	_, err := provider.IsEnabled(context.Background(), "new-consumer")
+	assert.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package message_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type featureFlagStub struct {
+	enabled atomic.Bool
+}
+
+func (s *featureFlagStub) IsEnabled(ctx context.Context, flag string) (bool, error) {
+	return s.enabled.Load(), nil
+}
+
+// channelSubscriber delivers the messages sent to the channel.
+type channelSubscriber struct {
+	messages chan *message.Message
+}
+
+func (s channelSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	out := make(chan *message.Message)
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-s.messages:
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s channelSubscriber) Close() error {
+	return nil
+}
+
+func requireAckedOrNacked(t *testing.T, msg *message.Message, expectAck bool) {
+	t.Helper()
+
+	select {
+	case <-msg.Acked():
+		assert.True(t, expectAck, "message should be nacked")
+	case <-msg.Nacked():
+		assert.False(t, expectAck, "message should be acked")
+	case <-time.After(time.Second):
+		t.Fatal("message not acked or nacked")
+	}
+}
+
+func TestHandler_SetFeatureFlag_message(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		NackWhenDisabled bool
+	}{
+		{Name: "ack"},
+		{Name: "nack", NackWhenDisabled: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+			require.NoError(t, err)
+
+			disabledMsg := message.NewMessage("disabled", nil)
+			enabledMsg := message.NewMessage("enabled", nil)
+			messages := make(chan *message.Message, 2)
+			messages <- disabledMsg
+
+			flags := &featureFlagStub{}
+			var handled atomic.Int64
+
+			handler := router.AddNoPublisherHandler(
+				"handler",
+				"topic",
+				channelSubscriber{messages},
+				func(msg *message.Message) error {
+					handled.Add(1)
+					assert.Equal(t, "enabled", msg.UUID)
+					return nil
+				},
+			)
+			require.NoError(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{
+				Flag:             "new_consumer",
+				Provider:         flags,
+				NackWhenDisabled: tc.NackWhenDisabled,
+			}))
+
+			go func() {
+				assert.NoError(t, router.Run(context.Background()))
+			}()
+			<-router.Running()
+			defer func() {
+				assert.NoError(t, router.Close())
+			}()
+
+			requireAckedOrNacked(t, disabledMsg, !tc.NackWhenDisabled)
+
+			flags.enabled.Store(true)
+			messages <- enabledMsg
+
+			requireAckedOrNacked(t, enabledMsg, true)
+			assert.EqualValues(t, 1, handled.Load())
+		})
+	}
+}
+
+func TestHandler_SetFeatureFlag_subscribe(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	flags := &featureFlagStub{}
+	handled := make(chan *message.Message, 1)
+
+	handler := router.AddNoPublisherHandler("gated", "topic", pubSub, func(msg *message.Message) error {
+		handled <- msg
+		return nil
+	})
+	require.NoError(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{
+		Flag:         "new_consumer",
+		Provider:     flags,
+		Check:        message.FeatureFlagCheckSubscribe,
+		PollInterval: time.Millisecond * 10,
+	}))
+
+	otherHandler := router.AddNoPublisherHandler("other", "other_topic", pubSub, func(msg *message.Message) error {
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	<-otherHandler.Started()
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+
+	select {
+	case <-handler.Started():
+		t.Fatal("disabled handler should not be started")
+	case <-handled:
+		t.Fatal("disabled handler should not handle messages")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	flags.enabled.Store(true)
+
+	select {
+	case msg := <-handled:
+		assert.Equal(t, "1", msg.UUID)
+	case <-time.After(time.Second):
+		t.Fatal("enabled handler should handle the message published while it was disabled")
+	}
+}
+
+func TestHandler_SetFeatureFlag_subscribe_close_disabled(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{CloseTimeout: time.Second}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("gated", "topic", newBufferedSubscriber(0), func(msg *message.Message) error {
+		return nil
+	})
+	require.NoError(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{
+		Flag:     "new_consumer",
+		Provider: &featureFlagStub{},
+		Check:    message.FeatureFlagCheckSubscribe,
+	}))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	start := time.Now()
+	assert.NoError(t, router.Close())
+	assert.Less(t, time.Since(start), time.Millisecond*500, "router should not wait for the disabled handler")
+}
+
+func TestHandler_SetFeatureFlag_invalid(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(0), func(msg *message.Message) error {
+		return nil
+	})
+
+	assert.Error(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{Provider: &featureFlagStub{}}))
+	assert.Error(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{Flag: "flag"}))
+	assert.Error(t, handler.SetFeatureFlag(message.HandlerFeatureFlag{
+		Flag:     "flag",
+		Provider: &featureFlagStub{},
+		Check:    "sometimes",
+	}))
+}
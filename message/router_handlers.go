@@ -0,0 +1,78 @@
+package message
+
+import (
+	"sort"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// SetOwner marks the handler as owned by the component which added it, for example, a CQRS processor.
+// It allows the component to find its handlers with Router.HandlersOwnedBy, when it's reconstructed
+// with the same router.
+func (h *Handler) SetOwner(owner string) {
+	h.router.handlersLock.Lock()
+	defer h.router.handlersLock.Unlock()
+
+	h.handler.owner = owner
+}
+
+// HandlerOwner returns the owner of the handler set with Handler.SetOwner.
+// ok is false if there is no handler with the name.
+func (r *Router) HandlerOwner(handlerName string) (owner string, ok bool) {
+	r.handlersLock.RLock()
+	defer r.handlersLock.RUnlock()
+
+	h, ok := r.handlers[handlerName]
+	if !ok {
+		return "", false
+	}
+
+	return h.owner, true
+}
+
+// HandlersOwnedBy returns the sorted names of the handlers owned by the owner (see Handler.SetOwner).
+func (r *Router) HandlersOwnedBy(owner string) []string {
+	r.handlersLock.RLock()
+	defer r.handlersLock.RUnlock()
+
+	var names []string
+	for name, h := range r.handlers {
+		if h.owner == owner {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// RemoveHandler removes the handler from the router.
+// If the handler is running, it's stopped, like with Handler.Stop. Stopping is asynchronous,
+// but the handler is removed immediately, so a new handler with the same name can be added.
+//
+// Keep in mind that the router is closed when all its running handlers have stopped.
+func (r *Router) RemoveHandler(handlerName string) error {
+	r.handlersLock.Lock()
+	defer r.handlersLock.Unlock()
+
+	h, ok := r.handlers[handlerName]
+	if !ok {
+		return HandlerNotFoundError{handlerName}
+	}
+
+	delete(r.handlers, handlerName)
+
+	switch {
+	case h.started:
+		h.stopFn()
+	case h.featureFlagPolling:
+		// the handler is uncounted by the feature flag's polling
+	default:
+		// handlers are counted when added, and uncounted when the running handler stops
+		r.handlersWg.Done()
+	}
+
+	r.logger.Info("Handler removed", watermill.LogFields{"handler_name": handlerName})
+
+	return nil
+}
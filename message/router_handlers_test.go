@@ -0,0 +1,91 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestRouter_HandlersOwnedBy(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("b", "topic", channelSubscriber{}, noopHandler).SetOwner("orders")
+	router.AddNoPublisherHandler("a", "topic", channelSubscriber{}, noopHandler).SetOwner("orders")
+	router.AddNoPublisherHandler("c", "topic", channelSubscriber{}, noopHandler)
+
+	assert.Equal(t, []string{"a", "b"}, router.HandlersOwnedBy("orders"))
+
+	owner, ok := router.HandlerOwner("a")
+	assert.True(t, ok)
+	assert.Equal(t, "orders", owner)
+
+	owner, ok = router.HandlerOwner("c")
+	assert.True(t, ok)
+	assert.Empty(t, owner)
+
+	_, ok = router.HandlerOwner("d")
+	assert.False(t, ok)
+}
+
+func TestRouter_RemoveHandler_not_started(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("removed", "topic", channelSubscriber{}, noopHandler)
+	require.NoError(t, router.RemoveHandler("removed"))
+
+	assert.ErrorAs(t, router.RemoveHandler("removed"), &message.HandlerNotFoundError{})
+	assert.Empty(t, router.Handlers())
+
+	// the same name can be used again
+	router.AddNoPublisherHandler("removed", "topic", channelSubscriber{}, noopHandler)
+}
+
+func TestRouter_RemoveHandler_running(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	removedMessages := make(chan *message.Message, 1)
+	keptMessages := make(chan *message.Message, 1)
+
+	removed := router.AddNoPublisherHandler("removed", "topic", channelSubscriber{removedMessages}, noopHandler)
+	router.AddNoPublisherHandler("kept", "topic", channelSubscriber{keptMessages}, noopHandler)
+
+	// channelSubscriber stops only when the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		assert.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+	defer cancel()
+
+	require.NoError(t, router.RemoveHandler("removed"))
+
+	select {
+	case <-removed.Stopped():
+	case <-time.After(time.Second):
+		t.Fatal("removed handler should be stopped")
+	}
+
+	replacedMessages := make(chan *message.Message, 1)
+	router.AddNoPublisherHandler("removed", "topic", channelSubscriber{replacedMessages}, noopHandler)
+	require.NoError(t, router.RunHandlers(ctx))
+
+	msg := message.NewMessage("1", nil)
+	replacedMessages <- msg
+	requireAckedOrNacked(t, msg, true)
+
+	assert.False(t, router.IsClosed())
+	assert.Len(t, router.Handlers(), 2)
+}
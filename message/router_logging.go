@@ -0,0 +1,172 @@
+package message
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// HandlerLogConfig overrides the logging of a single handler.
+// It can be changed at any time with Handler.SetLogConfig or Router.SetHandlerLogConfig.
+type HandlerLogConfig struct {
+	// Level is the minimum level of the handler's logs, for example, watermill.InfoLogLevel
+	// to silence the handler's debug and trace logs. Logs of the level are still filtered by the router's logger.
+	// If zero, all logs are passed to the router's logger.
+	Level watermill.LogLevel
+
+	// SampleEvery if greater than 1 logs the per-message debug and trace logs
+	// (like "Received message" and "Message acked") only for every SampleEvery-th message.
+	// Errors are always logged.
+	SampleEvery uint64
+}
+
+// Validate returns the config's error, if any.
+func (c HandlerLogConfig) Validate() error {
+	if c.Level > watermill.ErrorLogLevel {
+		return errors.Errorf("invalid log level %d", c.Level)
+	}
+
+	return nil
+}
+
+// SetLogConfig sets the log config of the handler. It can be called when the handler is already running.
+func (h *Handler) SetLogConfig(config HandlerLogConfig) error {
+	if err := config.Validate(); err != nil {
+		return errors.Wrap(err, "invalid log config")
+	}
+
+	h.handler.logger.config.Store(&config)
+
+	return nil
+}
+
+// LogConfig returns the log config of the handler.
+func (h *Handler) LogConfig() HandlerLogConfig {
+	return *h.handler.logger.config.Load()
+}
+
+// SetHandlerLogConfig sets the log config of the running handler.
+// See Handler.SetLogConfig.
+func (r *Router) SetHandlerLogConfig(handlerName string, config HandlerLogConfig) error {
+	r.handlersLock.RLock()
+	h, ok := r.handlers[handlerName]
+	r.handlersLock.RUnlock()
+
+	if !ok {
+		return HandlerNotFoundError{handlerName}
+	}
+
+	return (&Handler{router: r, handler: h}).SetLogConfig(config)
+}
+
+// handlerLogger applies HandlerLogConfig to the router's logger.
+type handlerLogger struct {
+	watermill.LoggerAdapter
+
+	config   atomic.Pointer[HandlerLogConfig]
+	messages atomic.Uint64
+}
+
+func newHandlerLogger(logger watermill.LoggerAdapter) *handlerLogger {
+	l := &handlerLogger{LoggerAdapter: logger}
+	l.config.Store(&HandlerLogConfig{})
+
+	return l
+}
+
+func (l *handlerLogger) enabled(level watermill.LogLevel) bool {
+	return level >= l.config.Load().Level
+}
+
+func (l *handlerLogger) Info(msg string, fields watermill.LogFields) {
+	if l.enabled(watermill.InfoLogLevel) {
+		l.LoggerAdapter.Info(msg, fields)
+	}
+}
+
+func (l *handlerLogger) Debug(msg string, fields watermill.LogFields) {
+	if l.enabled(watermill.DebugLogLevel) {
+		l.LoggerAdapter.Debug(msg, fields)
+	}
+}
+
+func (l *handlerLogger) Trace(msg string, fields watermill.LogFields) {
+	if l.enabled(watermill.TraceLogLevel) {
+		l.LoggerAdapter.Trace(msg, fields)
+	}
+}
+
+// forMessage returns the logger of the per-message logs of the next handled message.
+func (l *handlerLogger) forMessage() watermill.LoggerAdapter {
+	sampleEvery := l.config.Load().SampleEvery
+	if sampleEvery <= 1 {
+		return l
+	}
+
+	if (l.messages.Add(1)-1)%sampleEvery == 0 {
+		return l
+	}
+
+	return sampledOutLogger{l}
+}
+
+// sampledOutLogger drops debug and trace logs of messages which were not sampled.
+type sampledOutLogger struct {
+	watermill.LoggerAdapter
+}
+
+func (sampledOutLogger) Debug(msg string, fields watermill.LogFields) {}
+func (sampledOutLogger) Trace(msg string, fields watermill.LogFields) {}
+
+// With returns the logger with the fields added, which still applies HandlerLogConfig.Level of the handler.
+func (l *handlerLogger) With(fields watermill.LogFields) watermill.LoggerAdapter {
+	return scopedHandlerLogger{LoggerAdapter: l.LoggerAdapter.With(fields), handler: l}
+}
+
+// scopedHandlerLogger is the handler's logger with additional fields.
+type scopedHandlerLogger struct {
+	watermill.LoggerAdapter
+	handler *handlerLogger
+}
+
+func (l scopedHandlerLogger) Info(msg string, fields watermill.LogFields) {
+	if l.handler.enabled(watermill.InfoLogLevel) {
+		l.LoggerAdapter.Info(msg, fields)
+	}
+}
+
+func (l scopedHandlerLogger) Debug(msg string, fields watermill.LogFields) {
+	if l.handler.enabled(watermill.DebugLogLevel) {
+		l.LoggerAdapter.Debug(msg, fields)
+	}
+}
+
+func (l scopedHandlerLogger) Trace(msg string, fields watermill.LogFields) {
+	if l.handler.enabled(watermill.TraceLogLevel) {
+		l.LoggerAdapter.Trace(msg, fields)
+	}
+}
+
+func (l scopedHandlerLogger) With(fields watermill.LogFields) watermill.LoggerAdapter {
+	return scopedHandlerLogger{LoggerAdapter: l.LoggerAdapter.With(fields), handler: l.handler}
+}
+
+// correlationIDMetadataKey is the same as middleware.CorrelationIDMetadataKey,
+// which can't be imported by this package.
+const correlationIDMetadataKey = "correlation_id"
+
+// addMessageLogger adds the handler's logger scoped to the message to the message's context,
+// so it can be used by the handler with watermill.LoggerFromCtx.
+func (h *handler) addMessageLogger(msg *Message) {
+	fields := watermill.LogFields{
+		"handler_name": h.name,
+		"message_uuid": msg.UUID,
+	}
+	if correlationID := msg.Metadata.Get(correlationIDMetadataKey); correlationID != "" {
+		fields["correlation_id"] = correlationID
+	}
+
+	msg.SetContext(watermill.ContextWithLogger(msg.Context(), h.logger.With(fields)))
+}
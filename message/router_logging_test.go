@@ -0,0 +1,154 @@
+package message_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func countLogs(logger *watermill.CaptureLoggerAdapter, level watermill.LogLevel, msg string) int {
+	count := 0
+	for _, captured := range logger.Captured()[level] {
+		if captured.Msg == msg {
+			count++
+		}
+	}
+	return count
+}
+
+func TestHandler_SetLogConfig(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		Config           message.HandlerLogConfig
+		ExpectedReceived int
+	}{
+		{
+			Name:             "default",
+			Config:           message.HandlerLogConfig{},
+			ExpectedReceived: 6,
+		},
+		{
+			Name:             "sampling",
+			Config:           message.HandlerLogConfig{SampleEvery: 3},
+			ExpectedReceived: 2,
+		},
+		{
+			Name:             "level",
+			Config:           message.HandlerLogConfig{Level: watermill.InfoLogLevel},
+			ExpectedReceived: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			logger := watermill.NewCaptureLogger()
+			pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+			router, err := message.NewRouter(message.RouterConfig{}, logger)
+			require.NoError(t, err)
+
+			var handled int32
+			handler := router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+				atomic.AddInt32(&handled, 1)
+				return nil
+			})
+			require.NoError(t, handler.SetLogConfig(tc.Config))
+			assert.Equal(t, tc.Config, handler.LogConfig())
+
+			routerClosed := make(chan struct{})
+			go func() {
+				defer close(routerClosed)
+				assert.NoError(t, router.Run(context.Background()))
+			}()
+			<-router.Running()
+
+			for i := 0; i < 6; i++ {
+				require.NoError(t, pubSub.Publish("topic", message.NewMessage(watermill.NewUUID(), nil)))
+			}
+
+			assert.Eventually(t, func() bool {
+				return atomic.LoadInt32(&handled) == 6
+			}, time.Second, time.Millisecond*10)
+
+			require.NoError(t, router.Close())
+			<-routerClosed
+
+			assert.Equal(t, tc.ExpectedReceived, countLogs(logger, watermill.TraceLogLevel, "Received message"))
+			assert.Equal(t, tc.ExpectedReceived, countLogs(logger, watermill.TraceLogLevel, "Message acked"))
+		})
+	}
+}
+
+func TestRouter_SetHandlerLogConfig(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}), func(msg *message.Message) error {
+		return nil
+	})
+
+	config := message.HandlerLogConfig{Level: watermill.ErrorLogLevel, SampleEvery: 10}
+	require.NoError(t, router.SetHandlerLogConfig("handler", config))
+	assert.Equal(t, config, handler.LogConfig())
+
+	assert.ErrorAs(t, router.SetHandlerLogConfig("unknown", config), &message.HandlerNotFoundError{})
+	assert.ErrorContains(t, handler.SetLogConfig(message.HandlerLogConfig{Level: 10}), "invalid log level")
+}
+
+func TestRouter_message_logger(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	handled := make(chan struct{})
+	handler := router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		msgLogger := watermill.LoggerFromCtx(msg.Context())
+		msgLogger.Info("Handling message", watermill.LogFields{"foo": "bar"})
+		msgLogger.Debug("Silenced by log config", nil)
+
+		close(handled)
+		return nil
+	})
+	require.NoError(t, handler.SetLogConfig(message.HandlerLogConfig{Level: watermill.InfoLogLevel}))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	msg := message.NewMessage(watermill.NewUUID(), nil)
+	msg.Metadata.Set("correlation_id", "correlation-1")
+	require.NoError(t, pubSub.Publish("topic", msg))
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("message not handled")
+	}
+
+	assert.True(t, logger.Has(watermill.CapturedMessage{
+		Level: watermill.InfoLogLevel,
+		Fields: watermill.LogFields{
+			"handler_name":   "handler",
+			"message_uuid":   msg.UUID,
+			"correlation_id": "correlation-1",
+			"foo":            "bar",
+		},
+		Msg: "Handling message",
+	}))
+	assert.Equal(t, 0, countLogs(logger, watermill.DebugLogLevel, "Silenced by log config"))
+}
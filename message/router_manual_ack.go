@@ -0,0 +1,238 @@
+package message
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// ManualAckHandlerFunc is the function called when a message is received by the manual ack handler
+// (see Router.AddManualAckHandler).
+//
+// Contrary to HandlerFunc, the message is not acked when the function returns.
+// It must be acked or nacked explicitly with ack, which can be done after the function returns,
+// for example, to ack early and continue the processing in the background.
+//
+// When the function returns an error and the message was not acked nor nacked yet, it's nacked.
+// It's nacked also when a middleware (for example, PoisonQueue or IgnoreErrors) swallows the error,
+// as the router can't know if the middleware took care of the message.
+type ManualAckHandlerFunc func(msg *Message, ack *AckControl) error
+
+// SubscriberWithDelayedNack is an optional interface of Subscriber.
+// If the subscriber implements it, AckControl.NackWithDelay delegates the delay to the subscriber
+// (for example, by changing the visibility timeout of the message in the broker).
+// Otherwise, the message is held by the router and nacked after the delay.
+type SubscriberWithDelayedNack interface {
+	// NackWithDelay sends negative acknowledgement of the message, so it's redelivered not earlier than after delay.
+	// It returns false, if the message was already acked.
+	NackWithDelay(msg *Message, delay time.Duration) bool
+}
+
+// AckControl acks or nacks the message received by the manual ack handler.
+//
+// Only the first call settles the message. The following calls are no-ops, returning false
+// if they don't match the first one, like Message.Ack and Message.Nack.
+// All methods are safe to call from multiple goroutines.
+type AckControl struct {
+	msg *Message
+
+	// delayedNacker is nil if the subscriber doesn't support delayed nack
+	delayedNacker SubscriberWithDelayedNack
+	delayedNacks  *delayedNacks
+	clock         watermill.Clock
+
+	lock    sync.Mutex
+	settled ackType
+	// handlerFailed is true if the last call of the handler function returned an error
+	handlerFailed bool
+}
+
+// Ack sends message's acknowledgement.
+// False is returned, if the message was already nacked.
+func (c *AckControl) Ack() bool {
+	if first, ok := c.settle(ack); !first {
+		return ok
+	}
+
+	return c.msg.Ack()
+}
+
+// Nack sends message's negative acknowledgement.
+// False is returned, if the message was already acked.
+func (c *AckControl) Nack() bool {
+	if first, ok := c.settle(nack); !first {
+		return ok
+	}
+
+	return c.msg.Nack()
+}
+
+// NackWithDelay sends message's negative acknowledgement, so it's redelivered not earlier than after delay.
+// False is returned, if the message was already acked.
+//
+// If the subscriber doesn't implement SubscriberWithDelayedNack, the message is nacked after the delay
+// (measured with RouterConfig.DelayedNackClock),
+// or when the handler is stopped or the router is closing (before the subscriber is closed), whichever comes first. Until then, the message is not redelivered,
+// so subscribers delivering messages in order may not deliver the following messages.
+func (c *AckControl) NackWithDelay(delay time.Duration) bool {
+	if first, ok := c.settle(nack); !first {
+		return ok
+	}
+
+	if c.delayedNacker != nil {
+		return c.delayedNacker.NackWithDelay(c.msg, delay)
+	}
+
+	if delay <= 0 {
+		return c.msg.Nack()
+	}
+
+	c.delayedNacks.nackAfter(c.msg, c.clock.After(delay))
+
+	return true
+}
+
+// settle marks the message as settled with t, and returns true if it's the first call.
+// If the message was already settled, ok is true if it was settled with the same t.
+func (c *AckControl) settle(t ackType) (first bool, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.settled != noAckSent {
+		return false, c.settled == t
+	}
+
+	c.settled = t
+	return true, true
+}
+
+func (c *AckControl) setHandlerFailed(failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.handlerFailed = failed
+}
+
+// nackIfHandlerFailed nacks the message if the last call of the handler function returned an error,
+// and the message is not settled yet.
+func (c *AckControl) nackIfHandlerFailed() bool {
+	c.lock.Lock()
+	failed := c.handlerFailed && c.settled == noAckSent
+	c.lock.Unlock()
+
+	if !failed {
+		return false
+	}
+
+	return c.Nack()
+}
+
+// delayedNacks tracks the messages nacked with delay by the router,
+// so they are nacked before the handler's subscriber is closed.
+type delayedNacks struct {
+	lock    sync.Mutex
+	wg      sync.WaitGroup
+	flushCh chan struct{}
+	flushed bool
+}
+
+func newDelayedNacks() *delayedNacks {
+	return &delayedNacks{flushCh: make(chan struct{})}
+}
+
+// nackAfter nacks msg when after fires, or when the handler is closing.
+// If the handler is already closing, the message is nacked right away.
+func (d *delayedNacks) nackAfter(msg *Message, after <-chan time.Time) {
+	d.lock.Lock()
+	if d.flushed {
+		d.lock.Unlock()
+		msg.Nack()
+		return
+	}
+	d.wg.Add(1)
+	d.lock.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+
+		select {
+		case <-after:
+		case <-d.flushCh:
+		}
+		msg.Nack()
+	}()
+}
+
+// flush nacks all delayed messages right away, and waits until they are nacked.
+func (d *delayedNacks) flush() {
+	d.lock.Lock()
+	if !d.flushed {
+		d.flushed = true
+		close(d.flushCh)
+	}
+	d.lock.Unlock()
+
+	d.wg.Wait()
+}
+
+type ackControlKey struct{}
+
+// ackControl returns the AckControl of the message received by the manual ack handler.
+// The control is passed with message's local values, so it's available even if middlewares replace the message
+// with a copy made with CopyOnWrite.
+func (h *handler) ackControl(msg *Message) *AckControl {
+	if control, ok := msg.Local(ackControlKey{}).(*AckControl); ok {
+		return control
+	}
+
+	control := &AckControl{
+		msg:           msg,
+		delayedNacker: h.delayedNacker,
+		delayedNacks:  h.delayedNacks,
+		clock:         h.delayedNackClock,
+	}
+	msg.SetLocal(ackControlKey{}, control)
+
+	return control
+}
+
+// AddManualAckHandler adds a new handler, which acks or nacks messages explicitly with AckControl,
+// instead of the implicit ack when the handler returns without an error (see ManualAckHandlerFunc).
+// This handler cannot return messages, like the handler added with AddNoPublisherHandler.
+//
+// The router doesn't wait for messages acked in the background when closing.
+// Messages which are not acked nor nacked before the subscriber is closed are redelivered,
+// if the subscriber supports it. Messages nacked with delay by the router are nacked
+// before the subscriber is closed (see AckControl.NackWithDelay).
+//
+// handlerName must be unique. For now, it is used only for debugging.
+//
+// If handler is added while router is already running, you need to explicitly call RunHandlers().
+func (r *Router) AddManualAckHandler(
+	handlerName string,
+	subscribeTopic string,
+	subscriber Subscriber,
+	handlerFunc ManualAckHandlerFunc,
+) *Handler {
+	var newHandler *Handler
+
+	handlerFuncAdapter := func(msg *Message) ([]*Message, error) {
+		control := newHandler.handler.ackControl(msg)
+
+		err := handlerFunc(msg, control)
+		// middlewares may call the handler function again (for example, Retry), so only the last call counts
+		control.setHandlerFailed(err != nil)
+
+		return nil, err
+	}
+
+	newHandler = r.AddHandler(handlerName, subscribeTopic, subscriber, "", disabledPublisher{}, handlerFuncAdapter)
+	newHandler.handler.manualAck = true
+	newHandler.handler.delayedNacks = newDelayedNacks()
+	newHandler.handler.delayedNackClock = r.config.DelayedNackClock
+	// the subscriber is checked before it's decorated by the router
+	newHandler.handler.delayedNacker, _ = subscriber.(SubscriberWithDelayedNack)
+
+	return newHandler
+}
@@ -0,0 +1,261 @@
+package message_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type delayedNackSubscriber struct {
+	channelSubscriber
+
+	lock   sync.Mutex
+	delays map[string]time.Duration
+}
+
+func (s *delayedNackSubscriber) NackWithDelay(msg *message.Message, delay time.Duration) bool {
+	s.lock.Lock()
+	s.delays[msg.UUID] = delay
+	s.lock.Unlock()
+
+	return msg.Nack()
+}
+
+func runManualAckRouter(t *testing.T, subscriber message.Subscriber, handlerFunc message.ManualAckHandlerFunc) {
+	t.Helper()
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddManualAckHandler("handler", "topic", subscriber, handlerFunc)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+}
+
+func TestRouter_AddManualAckHandler_ack_in_background(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+	release := make(chan struct{})
+	returned := make(chan struct{})
+
+	runManualAckRouter(t, channelSubscriber{messages}, func(msg *message.Message, ack *message.AckControl) error {
+		go func() {
+			<-release
+			assert.True(t, ack.Ack())
+		}()
+		close(returned)
+		return nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+
+	<-returned
+	select {
+	case <-msg.Acked():
+		t.Fatal("message should not be acked when the handler returns")
+	case <-msg.Nacked():
+		t.Fatal("message should not be nacked when the handler returns")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	requireAckedOrNacked(t, msg, true)
+}
+
+func TestRouter_AddManualAckHandler_error(t *testing.T) {
+	messages := make(chan *message.Message, 2)
+
+	runManualAckRouter(t, channelSubscriber{messages}, func(msg *message.Message, ack *message.AckControl) error {
+		if msg.UUID == "acked" {
+			ack.Ack()
+		}
+		return errors.New("error")
+	})
+
+	notSettled := message.NewMessage("not_settled", nil)
+	acked := message.NewMessage("acked", nil)
+	messages <- notSettled
+	messages <- acked
+
+	requireAckedOrNacked(t, notSettled, false)
+	requireAckedOrNacked(t, acked, true)
+}
+
+func TestRouter_AddManualAckHandler_nack_with_delay(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+	ackAfterNack := make(chan bool, 1)
+
+	runManualAckRouter(t, channelSubscriber{messages}, func(msg *message.Message, ack *message.AckControl) error {
+		assert.True(t, ack.NackWithDelay(100*time.Millisecond))
+		ackAfterNack <- ack.Ack()
+		return nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+
+	assert.False(t, <-ackAfterNack, "ack should not be possible after nack")
+
+	select {
+	case <-msg.Nacked():
+		t.Fatal("message should not be nacked before the delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	requireAckedOrNacked(t, msg, false)
+}
+
+func TestRouter_AddManualAckHandler_nack_with_delay_clock(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	router, err := message.NewRouter(message.RouterConfig{DelayedNackClock: clock}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddManualAckHandler("handler", "topic", channelSubscriber{messages}, func(msg *message.Message, ack *message.AckControl) error {
+		assert.True(t, ack.NackWithDelay(time.Minute))
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+
+	clock.BlockUntilWaiters(1)
+
+	clock.Advance(time.Minute - time.Second)
+	select {
+	case <-msg.Nacked():
+		t.Fatal("message should not be nacked before the delay")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	requireAckedOrNacked(t, msg, false)
+}
+
+func TestRouter_AddManualAckHandler_nack_with_delay_supported_by_subscriber(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+	subscriber := &delayedNackSubscriber{
+		channelSubscriber: channelSubscriber{messages},
+		delays:            map[string]time.Duration{},
+	}
+
+	runManualAckRouter(t, subscriber, func(msg *message.Message, ack *message.AckControl) error {
+		ack.NackWithDelay(time.Hour)
+		return nil
+	})
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+
+	requireAckedOrNacked(t, msg, false)
+
+	subscriber.lock.Lock()
+	defer subscriber.lock.Unlock()
+	assert.Equal(t, map[string]time.Duration{"1": time.Hour}, subscriber.delays)
+}
+
+func TestRouter_AddManualAckHandler_error_swallowed_by_middleware(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddManualAckHandler("handler", "topic", channelSubscriber{messages}, func(msg *message.Message, ack *message.AckControl) error {
+		return errors.New("error")
+	})
+	handler.AddMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			_, _ = h(msg)
+			return nil, nil
+		}
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+
+	requireAckedOrNacked(t, msg, false)
+}
+
+// closeRecordingSubscriber records if the message was nacked before the subscriber was closed.
+type closeRecordingSubscriber struct {
+	channelSubscriber
+
+	msg                 *message.Message
+	nackedBeforeClosing chan bool
+}
+
+func (s closeRecordingSubscriber) Close() error {
+	select {
+	case <-s.msg.Nacked():
+		s.nackedBeforeClosing <- true
+	default:
+		s.nackedBeforeClosing <- false
+	}
+
+	return nil
+}
+
+func TestRouter_AddManualAckHandler_nack_with_delay_on_close(t *testing.T) {
+	messages := make(chan *message.Message, 1)
+	nackedWithDelay := make(chan struct{})
+
+	msg := message.NewMessage("1", nil)
+	subscriber := closeRecordingSubscriber{
+		channelSubscriber:   channelSubscriber{messages},
+		msg:                 msg,
+		nackedBeforeClosing: make(chan bool, 1),
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddManualAckHandler("handler", "topic", subscriber, func(msg *message.Message, ack *message.AckControl) error {
+		assert.True(t, ack.NackWithDelay(time.Hour))
+		close(nackedWithDelay)
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	messages <- msg
+	<-nackedWithDelay
+
+	require.NoError(t, router.Close())
+
+	assert.True(t, <-subscriber.nackedBeforeClosing, "message should be nacked before the subscriber is closed")
+}
@@ -0,0 +1,207 @@
+package message
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// MiddlewareConstraints declares how the middleware must be ordered relative to other middlewares of the handler.
+//
+// Middlewares are referenced by their names. Constraints referencing middlewares without constraints
+// (added with AddMiddleware) can't be checked, as they have no names.
+type MiddlewareConstraints struct {
+	// Name identifies the middleware in the constraints of other middlewares, for example "Retry".
+	Name string
+
+	// RunsBefore lists the middlewares that must be executed after this middleware (so must be added later).
+	// For example, a deduplicating middleware must run before "Retry", as it would drop the retried message otherwise.
+	RunsBefore []string
+
+	// RunsAfter lists the middlewares that must be executed before this middleware (so must be added earlier).
+	RunsAfter []string
+
+	// Requires lists the middlewares that must be used by the handler, for example "CorrelationID".
+	Requires []string
+}
+
+// ConstrainedMiddleware is a middleware with MiddlewareConstraints.
+// It's added with Router.AddConstrainedMiddleware or Handler.AddConstrainedMiddleware.
+type ConstrainedMiddleware interface {
+	Middleware(h HandlerFunc) HandlerFunc
+	MiddlewareConstraints() MiddlewareConstraints
+}
+
+type constrainedMiddleware struct {
+	middleware  HandlerMiddleware
+	constraints MiddlewareConstraints
+}
+
+// NewConstrainedMiddleware adds the constraints to the middleware.
+// It's useful for middlewares which are functions, for example middleware.CorrelationID.
+func NewConstrainedMiddleware(m HandlerMiddleware, constraints MiddlewareConstraints) ConstrainedMiddleware {
+	return constrainedMiddleware{
+		middleware:  m,
+		constraints: constraints,
+	}
+}
+
+func (m constrainedMiddleware) Middleware(h HandlerFunc) HandlerFunc {
+	return m.middleware(h)
+}
+
+func (m constrainedMiddleware) MiddlewareConstraints() MiddlewareConstraints {
+	return m.constraints
+}
+
+// MiddlewareConstraintsError is returned when the middlewares of the handler don't satisfy their constraints.
+type MiddlewareConstraintsError struct {
+	HandlerName string
+	Violations  []string
+}
+
+func (e MiddlewareConstraintsError) Error() string {
+	return fmt.Sprintf(
+		"middlewares of handler %s don't satisfy their constraints: %s",
+		e.HandlerName,
+		strings.Join(e.Violations, "; "),
+	)
+}
+
+// AddConstrainedMiddleware adds new middlewares with constraints to the router.
+// It works like AddMiddleware otherwise.
+//
+// The constraints are validated when a handler is added (violations are logged, as middlewares may still be added),
+// and before the handlers are started (violations are returned as MiddlewareConstraintsError).
+func (r *Router) AddConstrainedMiddleware(m ...ConstrainedMiddleware) {
+	r.logger.Debug("Adding constrained middleware", watermill.LogFields{"count": fmt.Sprintf("%d", len(m))})
+
+	r.middlewaresLock.Lock()
+	defer r.middlewaresLock.Unlock()
+
+	for _, constrained := range m {
+		constraints := constrained.MiddlewareConstraints()
+		r.middlewares = append(r.middlewares, middleware{
+			Handler:       constrained.Middleware,
+			IsRouterLevel: true,
+			Constraints:   &constraints,
+		})
+	}
+	r.middlewaresVersion++
+}
+
+// AddConstrainedMiddleware adds new middlewares with constraints to the specific handler.
+// See Router.AddConstrainedMiddleware.
+func (h *Handler) AddConstrainedMiddleware(m ...ConstrainedMiddleware) {
+	handler := h.handler
+	handler.logger.Debug("Adding constrained middleware to handler", watermill.LogFields{
+		"count":       fmt.Sprintf("%d", len(m)),
+		"handlerName": handler.name,
+	})
+
+	h.router.middlewaresLock.Lock()
+	defer h.router.middlewaresLock.Unlock()
+
+	for _, constrained := range m {
+		constraints := constrained.MiddlewareConstraints()
+		h.router.middlewares = append(h.router.middlewares, middleware{
+			Handler:     constrained.Middleware,
+			HandlerName: handler.name,
+			Constraints: &constraints,
+		})
+	}
+	h.router.middlewaresVersion++
+}
+
+// ValidateMiddlewares validates the constraints of the middlewares of all handlers.
+// It returns MiddlewareConstraintsError of the first handler (by name) violating the constraints.
+func (r *Router) ValidateMiddlewares() error {
+	r.handlersLock.RLock()
+	defer r.handlersLock.RUnlock()
+
+	middlewares, _ := r.currentMiddlewares()
+
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := r.handlers[name].validateMiddlewares(middlewares, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateMiddlewares checks the constraints of the handler's middlewares, in the order they are executed.
+// If checkRequired is false, the missing required middlewares are not reported.
+func (h *handler) validateMiddlewares(middlewares []middleware, checkRequired bool) error {
+	// positions of the named middlewares in the handler's chain
+	positions := map[string][]int{}
+	var constrained []middleware
+	var constrainedPositions []int
+
+	position := 0
+	for _, m := range middlewares {
+		if !m.IsRouterLevel && m.HandlerName != h.name {
+			continue
+		}
+
+		if m.Constraints != nil {
+			if m.Constraints.Name != "" {
+				positions[m.Constraints.Name] = append(positions[m.Constraints.Name], position)
+			}
+			constrained = append(constrained, m)
+			constrainedPositions = append(constrainedPositions, position)
+		}
+		position++
+	}
+
+	var violations []string
+
+	for i, m := range constrained {
+		name := m.Constraints.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", constrainedPositions[i])
+		}
+		current := constrainedPositions[i]
+
+		for _, other := range m.Constraints.RunsBefore {
+			for _, otherPosition := range positions[other] {
+				if otherPosition < current {
+					violations = append(violations, fmt.Sprintf("%s must run before %s", name, other))
+					break
+				}
+			}
+		}
+		for _, other := range m.Constraints.RunsAfter {
+			for _, otherPosition := range positions[other] {
+				if otherPosition > current {
+					violations = append(violations, fmt.Sprintf("%s must run after %s", name, other))
+					break
+				}
+			}
+		}
+		if checkRequired {
+			for _, required := range m.Constraints.Requires {
+				if len(positions[required]) == 0 {
+					violations = append(violations, fmt.Sprintf("%s requires %s", name, required))
+				}
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return MiddlewareConstraintsError{
+			HandlerName: h.name,
+			Violations:  violations,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func namedMiddleware(constraints message.MiddlewareConstraints) message.ConstrainedMiddleware {
+	return message.NewConstrainedMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		return h
+	}, constraints)
+}
+
+func noopHandler(msg *message.Message) error {
+	return nil
+}
+
+func TestRouter_ValidateMiddlewares(t *testing.T) {
+	testCases := []struct {
+		Name               string
+		RouterMiddlewares  []message.ConstrainedMiddleware
+		HandlerMiddlewares []message.ConstrainedMiddleware
+		ExpectedViolations []string
+	}{
+		{
+			Name: "no_constraints",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "A"}),
+			},
+		},
+		{
+			Name: "runs_before",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+				namedMiddleware(message.MiddlewareConstraints{Name: "A", RunsBefore: []string{"B"}}),
+			},
+			ExpectedViolations: []string{"A must run before B"},
+		},
+		{
+			Name: "runs_after",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "A", RunsAfter: []string{"B"}}),
+				namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+			},
+			ExpectedViolations: []string{"A must run after B"},
+		},
+		{
+			Name: "requires",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "A", Requires: []string{"B", "C"}}),
+				namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+			},
+			ExpectedViolations: []string{"A requires C"},
+		},
+		{
+			Name: "handler_level",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "A", RunsBefore: []string{"B"}, Requires: []string{"B"}}),
+			},
+			HandlerMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+			},
+		},
+		{
+			Name: "handler_level_misordered",
+			RouterMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "A", RunsAfter: []string{"B"}}),
+			},
+			HandlerMiddlewares: []message.ConstrainedMiddleware{
+				namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+			},
+			ExpectedViolations: []string{"A must run after B"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+			require.NoError(t, err)
+
+			router.AddConstrainedMiddleware(tc.RouterMiddlewares...)
+
+			pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+			router.AddNoPublisherHandler("handler", "topic", pubSub, noopHandler).
+				AddConstrainedMiddleware(tc.HandlerMiddlewares...)
+
+			// the middlewares of other handlers are not validated with the handler
+			router.AddNoPublisherHandler("other_handler", "topic", pubSub, noopHandler).
+				AddConstrainedMiddleware(namedMiddleware(message.MiddlewareConstraints{Name: "B"}))
+
+			err = router.ValidateMiddlewares()
+			if len(tc.ExpectedViolations) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var constraintsErr message.MiddlewareConstraintsError
+			require.ErrorAs(t, err, &constraintsErr)
+			assert.Equal(t, "handler", constraintsErr.HandlerName)
+			assert.Equal(t, tc.ExpectedViolations, constraintsErr.Violations)
+		})
+	}
+}
+
+func TestRouter_AddHandler_logs_misordered_middlewares(t *testing.T) {
+	logger := watermill.NewCaptureLogger()
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	router.AddConstrainedMiddleware(
+		namedMiddleware(message.MiddlewareConstraints{Name: "B"}),
+		namedMiddleware(message.MiddlewareConstraints{Name: "A", RunsBefore: []string{"B"}, Requires: []string{"C"}}),
+	)
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	router.AddNoPublisherHandler("handler", "topic", pubSub, noopHandler)
+
+	assert.True(t, logger.Has(watermill.CapturedMessage{
+		Level:  watermill.ErrorLogLevel,
+		Fields: watermill.LogFields{"handler_name": "handler"},
+		Msg:    "Invalid middlewares of handler",
+		Err: message.MiddlewareConstraintsError{
+			HandlerName: "handler",
+			// required middlewares may be added later, so they are not reported yet
+			Violations: []string{"A must run before B"},
+		},
+	}))
+}
+
+func TestRouter_Run_with_invalid_middlewares(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddConstrainedMiddleware(
+		namedMiddleware(message.MiddlewareConstraints{Name: "A", Requires: []string{"B"}}),
+	)
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	router.AddNoPublisherHandler("handler", "topic", pubSub, noopHandler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err = router.Run(ctx)
+	assert.ErrorContains(t, err, "A requires B")
+}
@@ -0,0 +1,140 @@
+package message
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// AddHandlerMultiTopic adds a new handler consuming messages from all subscribeTopics with the subscriber.
+// Messages of all topics are handled together, as they were received from one topic.
+// The topic of the message is available with SubscribeTopicFromCtx.
+//
+// It's useful for handlers which naturally consume several closely related topics
+// (for example, "orders.created" and "orders.updated").
+//
+// If the subscription of one of the topics is closed, the handler keeps consuming the other topics.
+// The handler is configured with TopicConcurrency of the first configured topic of subscribeTopics.
+// It works like AddHandler otherwise. It panics if subscribeTopics is empty.
+func (r *Router) AddHandlerMultiTopic(
+	handlerName string,
+	subscribeTopics []string,
+	subscriber Subscriber,
+	publishTopic string,
+	publisher Publisher,
+	handlerFunc HandlerFunc,
+) *Handler {
+	if len(subscribeTopics) == 0 {
+		panic("no subscribe topics provided for handler " + handlerName)
+	}
+
+	return r.addHandler(
+		handlerName,
+		append([]string(nil), subscribeTopics...),
+		subscriber,
+		publishTopic,
+		publisher,
+		handlerFunc,
+	)
+}
+
+// AddNoPublisherHandlerMultiTopic adds a new handler consuming messages from all subscribeTopics,
+// which cannot return messages. See AddHandlerMultiTopic and AddNoPublisherHandler.
+func (r *Router) AddNoPublisherHandlerMultiTopic(
+	handlerName string,
+	subscribeTopics []string,
+	subscriber Subscriber,
+	handlerFunc NoPublishHandlerFunc,
+) *Handler {
+	handlerFuncAdapter := func(msg *Message) ([]*Message, error) {
+		return nil, handlerFunc(msg)
+	}
+
+	return r.AddHandlerMultiTopic(handlerName, subscribeTopics, subscriber, "", disabledPublisher{}, handlerFuncAdapter)
+}
+
+// topicsConcurrency returns TopicConcurrency of the first configured topic.
+func (r *Router) topicsConcurrency(topics []string) HandlerConcurrency {
+	for _, topic := range topics {
+		if concurrency, ok := r.config.TopicConcurrency[topic]; ok {
+			return concurrency
+		}
+	}
+
+	return HandlerConcurrency{}
+}
+
+func (h *handler) isMultiTopic() bool {
+	return len(h.subscribeTopics) > 1
+}
+
+func (h *handler) topicsLogField() string {
+	if !h.isMultiTopic() {
+		return h.subscribeTopic
+	}
+
+	return strings.Join(h.subscribeTopics, ",")
+}
+
+// addSubscribeTopicContext sets the topic from which the message was received,
+// which may be different from subscribeTopic for multi-topic handlers.
+func (h *handler) addSubscribeTopicContext(msg *Message, topic string) {
+	if topic == "" || topic == h.subscribeTopic {
+		return
+	}
+
+	msg.SetContext(context.WithValue(msg.Context(), subscribeTopicKey, topic))
+}
+
+// subscribe subscribes to the handler's topics.
+// Messages of multiple topics are merged into one channel, which is closed when all subscriptions are closed.
+func (h *handler) subscribe(ctx context.Context) (<-chan *Message, error) {
+	if !h.isMultiTopic() {
+		messages, err := h.subscriber.Subscribe(ctx, h.subscribeTopic)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot subscribe topic %s", h.subscribeTopic)
+		}
+
+		return messages, nil
+	}
+
+	// canceled when subscribing to one of the topics fails, so the other subscriptions are closed
+	ctx, cancel := context.WithCancel(ctx)
+
+	channels := make([]<-chan *Message, 0, len(h.subscribeTopics))
+	for _, topic := range h.subscribeTopics {
+		messages, err := h.subscriber.Subscribe(ctx, topic)
+		if err != nil {
+			cancel()
+			return nil, errors.Wrapf(err, "cannot subscribe topic %s", topic)
+		}
+
+		channels = append(channels, messages)
+	}
+
+	merged := make(chan *Message)
+	wg := sync.WaitGroup{}
+	wg.Add(len(channels))
+
+	for _, messages := range channels {
+		messages := messages
+
+		go func() {
+			defer wg.Done()
+
+			for msg := range messages {
+				merged <- msg
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(merged)
+	}()
+
+	return merged, nil
+}
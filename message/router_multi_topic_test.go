@@ -0,0 +1,153 @@
+package message_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestRouter_AddHandlerMultiTopic(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	received := map[string][]string{}
+	lock := sync.Mutex{}
+
+	router.AddHandlerMultiTopic(
+		"handler",
+		[]string{"orders.created", "orders.updated"},
+		pubSub,
+		"orders.audit",
+		pubSub,
+		func(msg *message.Message) ([]*message.Message, error) {
+			topic := message.SubscribeTopicFromCtx(msg.Context())
+
+			lock.Lock()
+			received[topic] = append(received[topic], msg.UUID)
+			lock.Unlock()
+
+			return []*message.Message{message.NewMessage(watermill.NewUUID(), []byte(topic))}, nil
+		},
+	)
+
+	require.NoError(t, pubSub.Publish("orders.created", message.NewMessage("1", nil)))
+	require.NoError(t, pubSub.Publish("orders.updated", message.NewMessage("2", nil), message.NewMessage("3", nil)))
+
+	audit, err := pubSub.Subscribe(context.Background(), "orders.audit")
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	var auditTopics []string
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-audit:
+			auditTopics = append(auditTopics, string(msg.Payload))
+			msg.Ack()
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for produced messages")
+		}
+	}
+	sort.Strings(auditTopics)
+	assert.Equal(t, []string{"orders.created", "orders.updated", "orders.updated"}, auditTopics)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{"1"}, received["orders.created"])
+	assert.ElementsMatch(t, []string{"2", "3"}, received["orders.updated"])
+}
+
+func TestRouter_AddHandlerMultiTopic_subscribe_error(t *testing.T) {
+	sub := &failingTopicSubscriber{
+		Subscriber: gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+		failTopic:  "topic_b",
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandlerMultiTopic("handler", []string{"topic_a", "topic_b"}, sub, func(msg *message.Message) error {
+		return nil
+	})
+
+	err = router.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot subscribe topic topic_b")
+}
+
+func TestRouter_AddHandlerMultiTopic_no_topics(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	assert.Panics(t, func() {
+		router.AddNoPublisherHandlerMultiTopic("handler", nil, gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}), func(msg *message.Message) error {
+			return nil
+		})
+	})
+}
+
+func TestRouter_HandlerLag_multi_topic(t *testing.T) {
+	sub := topicLagSubscriber{
+		Subscriber: gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}),
+		lags: map[string]message.SubscriberLag{
+			"topic_a": {Messages: 3, OldestUnackedMessageAge: time.Second, ConsumeDelay: time.Second * 5},
+			"topic_b": {Messages: 4, OldestUnackedMessageAge: time.Second * 2, ConsumeDelay: time.Second},
+		},
+	}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandlerMultiTopic("handler", []string{"topic_a", "topic_b"}, sub, func(msg *message.Message) error {
+		return nil
+	})
+
+	lag, err := router.HandlerLag(context.Background(), "handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.SubscriberLag{
+		Messages:                7,
+		OldestUnackedMessageAge: time.Second * 2,
+		ConsumeDelay:            time.Second * 5,
+	}, lag)
+}
+
+type failingTopicSubscriber struct {
+	message.Subscriber
+	failTopic string
+}
+
+func (s *failingTopicSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	if topic == s.failTopic {
+		return nil, errors.New("cannot connect")
+	}
+
+	return s.Subscriber.Subscribe(ctx, topic)
+}
+
+type topicLagSubscriber struct {
+	message.Subscriber
+	lags map[string]message.SubscriberLag
+}
+
+func (s topicLagSubscriber) Lag(ctx context.Context, topic string) (message.SubscriberLag, error) {
+	return s.lags[topic], nil
+}
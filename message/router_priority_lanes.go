@@ -0,0 +1,249 @@
+package message
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MessagePriority is the priority of the message, used by HandlerPriorityLanes.
+type MessagePriority string
+
+const (
+	PriorityHigh   MessagePriority = "high"
+	PriorityNormal MessagePriority = "normal"
+	PriorityLow    MessagePriority = "low"
+)
+
+// DefaultPriorityMetadataKey is the default HandlerPriorityLanes.MetadataKey.
+const DefaultPriorityMetadataKey = "priority"
+
+// priorityLanesOrder is the order of the lanes, from the highest priority.
+var priorityLanesOrder = [...]MessagePriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// HandlerPriorityLanes splits the consumption of the handler into high, normal, and low priority lanes,
+// based on the message's metadata, so urgent messages aren't stuck behind a backlog of bulk messages
+// on the same topic.
+//
+// Received messages are buffered in their lanes, and the workers (see HandlerConcurrency.Workers) take them
+// from the lanes in the proportion of the weights. When a lane is empty, messages of the other lanes are handled.
+// With the default weights, out of every 7 handled messages, 4 are taken from the high lane, 2 from the normal lane,
+// and 1 from the low lane, if all lanes have messages.
+//
+// Like with HandlerConcurrency, the lanes help only if the subscriber delivers multiple messages
+// without waiting for the acks, because otherwise there's no backlog to reorder.
+// Messages are handled out of the order they were received.
+type HandlerPriorityLanes struct {
+	// MetadataKey is the metadata key with the priority of the message: "high", "normal", or "low".
+	// Messages without the priority or with an unknown priority are handled in the normal lane.
+	// If empty, DefaultPriorityMetadataKey is used.
+	MetadataKey string
+
+	// HighWeight, NormalWeight, and LowWeight are the weights of the lanes.
+	// The lane with weight 0 is handled only when the other lanes are empty.
+	// If all are 0, 4, 2, and 1 are used.
+	HighWeight   int
+	NormalWeight int
+	LowWeight    int
+
+	// BufferSize is the number of received messages buffered in every lane.
+	// When the lane of the received message is full, receiving messages is blocked.
+	// If 0, 100 is used.
+	BufferSize int
+}
+
+func (l *HandlerPriorityLanes) setDefaults() {
+	if l.MetadataKey == "" {
+		l.MetadataKey = DefaultPriorityMetadataKey
+	}
+	if l.HighWeight == 0 && l.NormalWeight == 0 && l.LowWeight == 0 {
+		l.HighWeight = 4
+		l.NormalWeight = 2
+		l.LowWeight = 1
+	}
+	if l.BufferSize == 0 {
+		l.BufferSize = 100
+	}
+}
+
+// Validate returns the configuration error, if any.
+func (l HandlerPriorityLanes) Validate() error {
+	if l.HighWeight < 0 || l.NormalWeight < 0 || l.LowWeight < 0 {
+		return errors.New("weights must not be negative")
+	}
+	if l.BufferSize < 0 {
+		return errors.New("BufferSize must not be negative")
+	}
+
+	return nil
+}
+
+func (l HandlerPriorityLanes) weights() [len(priorityLanesOrder)]int {
+	return [...]int{l.HighWeight, l.NormalWeight, l.LowWeight}
+}
+
+// SetPriorityLanes enables the priority lanes of the handler (see HandlerPriorityLanes).
+// Messages are handled with HandlerConcurrency.Workers workers, or with one worker if Workers is 0.
+//
+// SetPriorityLanes must be called before the handler is started.
+func (h *Handler) SetPriorityLanes(lanes HandlerPriorityLanes) error {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	if err := lanes.Validate(); err != nil {
+		return errors.Wrap(err, "invalid priority lanes")
+	}
+
+	h.handler.priorityLanes = &lanes
+
+	return nil
+}
+
+// runPriorityLanes receives messages from the subscription into the priority lanes,
+// and handles them with the workers until the subscription is closed and the lanes are drained.
+func (h *handler) runPriorityLanes(middlewareHandler *sharedMiddlewareHandler) {
+	workers := h.concurrency.Workers
+	if workers == 0 {
+		workers = 1
+	}
+
+	config := *h.priorityLanes
+	config.setDefaults()
+
+	lanes := newPriorityLanes(config)
+	go lanes.receive(h.messagesCh)
+
+	h.runWorkerPool(workers, lanes.next, middlewareHandler)
+}
+
+type priorityLanes struct {
+	metadataKey string
+
+	lanes [len(priorityLanesOrder)]chan *Message
+
+	// schedule is the weighted order of taking messages from the lanes
+	schedule     []int
+	scheduleNext int
+	scheduleLock sync.Mutex
+}
+
+func newPriorityLanes(config HandlerPriorityLanes) *priorityLanes {
+	l := &priorityLanes{
+		metadataKey: config.MetadataKey,
+		schedule:    weightedSchedule(config.weights()),
+	}
+	for i := range l.lanes {
+		l.lanes[i] = make(chan *Message, config.BufferSize)
+	}
+
+	return l
+}
+
+func (l *priorityLanes) receive(messages <-chan *Message) {
+	for msg := range messages {
+		l.lanes[l.laneOf(msg)] <- msg
+	}
+
+	for _, lane := range l.lanes {
+		close(lane)
+	}
+}
+
+func (l *priorityLanes) laneOf(msg *Message) int {
+	priority := MessagePriority(msg.Metadata.Get(l.metadataKey))
+
+	for i, lanePriority := range priorityLanesOrder {
+		if priority == lanePriority {
+			return i
+		}
+	}
+
+	// the normal lane
+	return 1
+}
+
+// next returns the next message to handle.
+// It returns false when all lanes are closed and drained.
+func (l *priorityLanes) next() (*Message, bool) {
+	preferred := -1
+
+	l.scheduleLock.Lock()
+	if len(l.schedule) > 0 {
+		preferred = l.schedule[l.scheduleNext]
+		l.scheduleNext = (l.scheduleNext + 1) % len(l.schedule)
+	}
+	l.scheduleLock.Unlock()
+
+	if preferred >= 0 {
+		select {
+		case msg, ok := <-l.lanes[preferred]:
+			if ok {
+				return msg, true
+			}
+		default:
+		}
+	}
+
+	// the preferred lane is empty, so the first non-empty lane (by priority) is used
+	for _, lane := range l.lanes {
+		select {
+		case msg, ok := <-lane:
+			if ok {
+				return msg, true
+			}
+		default:
+		}
+	}
+
+	// all lanes are empty, waiting for the first message of any lane
+	high, normal, low := l.lanes[0], l.lanes[1], l.lanes[2]
+	for high != nil || normal != nil || low != nil {
+		select {
+		case msg, ok := <-high:
+			if ok {
+				return msg, true
+			}
+			high = nil
+		case msg, ok := <-normal:
+			if ok {
+				return msg, true
+			}
+			normal = nil
+		case msg, ok := <-low:
+			if ok {
+				return msg, true
+			}
+			low = nil
+		}
+	}
+
+	return nil, false
+}
+
+// weightedSchedule returns the smooth weighted round-robin order of the lanes,
+// so the lanes are interleaved instead of taking all messages of a lane in a row.
+func weightedSchedule(weights [len(priorityLanesOrder)]int) []int {
+	total := 0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	schedule := make([]int, 0, total)
+	current := [len(priorityLanesOrder)]int{}
+
+	for i := 0; i < total; i++ {
+		selected := 0
+		for lane, weight := range weights {
+			current[lane] += weight
+			if current[lane] > current[selected] {
+				selected = lane
+			}
+		}
+
+		current[selected] -= total
+		schedule = append(schedule, selected)
+	}
+
+	return schedule
+}
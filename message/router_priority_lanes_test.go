@@ -0,0 +1,135 @@
+package message_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func newPrioritySubscriber(priorities ...message.MessagePriority) bufferedSubscriber {
+	sub := bufferedSubscriber{}
+	for i, priority := range priorities {
+		msg := message.NewMessage(strconv.Itoa(i), nil)
+		if priority != "" {
+			msg.Metadata.Set(message.DefaultPriorityMetadataKey, string(priority))
+		}
+		sub.messages = append(sub.messages, msg)
+	}
+	return sub
+}
+
+func repeatPriority(priority message.MessagePriority, count int) []message.MessagePriority {
+	priorities := make([]message.MessagePriority, count)
+	for i := range priorities {
+		priorities[i] = priority
+	}
+	return priorities
+}
+
+// runPriorityLanesHandler runs the handler with priority lanes and returns the priorities of the handled messages,
+// except the first one, which is handled before the lanes are filled.
+func runPriorityLanesHandler(t *testing.T, lanes message.HandlerPriorityLanes, priorities []message.MessagePriority) []message.MessagePriority {
+	t.Helper()
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	lock := sync.Mutex{}
+	var handled []message.MessagePriority
+	firstStarted := make(chan struct{})
+	releaseFirst := make(chan struct{})
+	done := make(chan struct{})
+
+	handler := router.AddNoPublisherHandler("handler", "topic", newPrioritySubscriber(priorities...), func(msg *message.Message) error {
+		lock.Lock()
+		first := handled == nil
+		handled = append(handled, message.MessagePriority(msg.Metadata.Get(message.DefaultPriorityMetadataKey)))
+		if len(handled) == len(priorities) {
+			close(done)
+		}
+		lock.Unlock()
+
+		if first {
+			close(firstStarted)
+			<-releaseFirst
+		}
+		return nil
+	})
+	require.NoError(t, handler.SetPriorityLanes(lanes))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	<-firstStarted
+	// waiting until the rest of the messages are received into the lanes
+	time.Sleep(time.Millisecond * 50)
+	close(releaseFirst)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("messages not handled")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	return handled[1:]
+}
+
+func TestHandler_SetPriorityLanes_high_priority_first(t *testing.T) {
+	priorities := append(repeatPriority(message.PriorityLow, 10), repeatPriority(message.PriorityHigh, 3)...)
+
+	handled := runPriorityLanesHandler(t, message.HandlerPriorityLanes{HighWeight: 1}, priorities)
+
+	// low lane has weight 0, so it's handled only when the high lane is empty
+	assert.Equal(t, repeatPriority(message.PriorityHigh, 3), handled[:3])
+	assert.Equal(t, repeatPriority(message.PriorityLow, 9), handled[3:])
+}
+
+func TestHandler_SetPriorityLanes_weighted(t *testing.T) {
+	priorities := append(repeatPriority(message.PriorityLow, 14), repeatPriority(message.PriorityHigh, 14)...)
+
+	handled := runPriorityLanesHandler(t, message.HandlerPriorityLanes{HighWeight: 2, LowWeight: 1}, priorities)
+
+	counts := map[message.MessagePriority]int{}
+	for _, priority := range handled[:9] {
+		counts[priority]++
+	}
+	assert.Equal(t, map[message.MessagePriority]int{message.PriorityHigh: 6, message.PriorityLow: 3}, counts)
+}
+
+func TestHandler_SetPriorityLanes_unknown_priority_is_normal(t *testing.T) {
+	priorities := append(repeatPriority("", 3), repeatPriority("urgent", 3)...)
+	priorities = append(priorities, repeatPriority(message.PriorityLow, 3)...)
+
+	handled := runPriorityLanesHandler(t, message.HandlerPriorityLanes{NormalWeight: 1}, priorities)
+
+	// messages without priority and with unknown priority are in the normal lane, handled before the low lane
+	assert.Equal(t, repeatPriority(message.PriorityLow, 3), handled[5:])
+}
+
+func TestHandlerPriorityLanes_Validate(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", newBufferedSubscriber(0), func(msg *message.Message) error {
+		return nil
+	})
+
+	assert.ErrorContains(t, handler.SetPriorityLanes(message.HandlerPriorityLanes{LowWeight: -1}), "weights must not be negative")
+	assert.ErrorContains(t, handler.SetPriorityLanes(message.HandlerPriorityLanes{BufferSize: -1}), "BufferSize must not be negative")
+}
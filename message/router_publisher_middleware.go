@@ -0,0 +1,38 @@
+package message
+
+// PublishFunc publishes the messages produced by the handler to the topic.
+type PublishFunc func(topic string, messages ...*Message) error
+
+// PublisherMiddleware wraps publishing the messages produced by the handler, like HandlerMiddleware wraps consuming.
+//
+// It can modify the outgoing messages, block them (by not calling next), or fan them out
+// (by calling next with multiple topics). When it returns an error, the consumed message is nacked.
+//
+// Contrary to PublisherDecorator, which wraps the publisher of all router's handlers,
+// it's added to a single handler (see Handler.AddPublisherMiddleware).
+type PublisherMiddleware func(next PublishFunc) PublishFunc
+
+// AddPublisherMiddleware adds publisher middleware to the handler.
+// It's called for every message produced by the handler, with the handler's publish topic.
+//
+// The order of middleware matters. Middleware added at the beginning is executed first.
+//
+// AddPublisherMiddleware must be called before the handler is started.
+func (h *Handler) AddPublisherMiddleware(m ...PublisherMiddleware) {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	h.handler.publisherMiddlewares = append(h.handler.publisherMiddlewares, m...)
+}
+
+// publishFunc returns the function publishing with the handler's publisher, wrapped with publisher middlewares.
+func (h *handler) publishFunc() PublishFunc {
+	publish := h.publisher.Publish
+
+	for i := len(h.publisherMiddlewares) - 1; i >= 0; i-- {
+		publish = h.publisherMiddlewares[i](publish)
+	}
+
+	return publish
+}
@@ -0,0 +1,126 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func runPublisherMiddlewareRouter(
+	t *testing.T,
+	publisher message.Publisher,
+	middlewares ...message.PublisherMiddleware,
+) chan<- *message.Message {
+	t.Helper()
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	messages := make(chan *message.Message)
+
+	handler := router.AddHandler(
+		"splitter",
+		"input",
+		channelSubscriber{messages},
+		"output",
+		publisher,
+		splitByComma,
+	)
+	handler.AddPublisherMiddleware(middlewares...)
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+
+	return messages
+}
+
+func TestHandler_AddPublisherMiddleware(t *testing.T) {
+	publisher := &parkingPublisher{}
+
+	var calls []string
+
+	messages := runPublisherMiddlewareRouter(
+		t,
+		publisher,
+		func(next message.PublishFunc) message.PublishFunc {
+			return func(topic string, messages ...*message.Message) error {
+				calls = append(calls, "first")
+				for _, msg := range messages {
+					msg.Metadata.Set("handler", message.HandlerNameFromCtx(msg.Context()))
+				}
+				return next(topic, messages...)
+			}
+		},
+		func(next message.PublishFunc) message.PublishFunc {
+			return func(topic string, messages ...*message.Message) error {
+				calls = append(calls, "second")
+
+				var published []*message.Message
+				for _, msg := range messages {
+					if string(msg.Payload) != "blocked" {
+						published = append(published, msg)
+					}
+				}
+				if len(published) == 0 {
+					return nil
+				}
+
+				if err := next(topic, published...); err != nil {
+					return err
+				}
+				return next("audit", published...)
+			}
+		},
+	)
+
+	msg := message.NewMessage("1", []byte("a,blocked,b"))
+	messages <- msg
+	requireAckedOrNacked(t, msg, true)
+
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+
+	assert.Equal(t, []string{"first", "second", "first", "second", "first", "second"}, calls)
+	assert.Equal(t, []string{"output", "audit", "output", "audit"}, publisher.topics)
+
+	var payloads []string
+	for _, published := range publisher.messages {
+		payloads = append(payloads, string(published.Payload))
+		assert.Equal(t, "splitter", published.Metadata.Get("handler"))
+	}
+	assert.Equal(t, []string{"a", "a", "b", "b"}, payloads)
+}
+
+func TestHandler_AddPublisherMiddleware_error_nacks(t *testing.T) {
+	publisher := &parkingPublisher{}
+
+	messages := runPublisherMiddlewareRouter(
+		t,
+		publisher,
+		func(next message.PublishFunc) message.PublishFunc {
+			return func(topic string, messages ...*message.Message) error {
+				return errors.New("blocked")
+			}
+		},
+	)
+
+	msg := message.NewMessage("1", []byte("a"))
+	messages <- msg
+	requireAckedOrNacked(t, msg, false)
+
+	publisher.lock.Lock()
+	defer publisher.lock.Unlock()
+	assert.Empty(t, publisher.messages)
+}
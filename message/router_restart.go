@@ -0,0 +1,219 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// HandlerRestartPolicy configures restarting handlers whose subscription was closed unexpectedly
+// (for example, because the subscriber lost the connection permanently), instead of stopping them.
+//
+// A handler is not restarted when it was stopped with Handler.Stop, or when the router is closing.
+// Between restarts, the handler is in HandlerStateCrashLoopBackoff, and it waits with an exponential backoff,
+// similar to containers restarted by an orchestrator.
+type HandlerRestartPolicy struct {
+	// MaxRestarts is the maximum number of consecutive restarts. When it's exceeded, the handler is stopped.
+	// Unlimited if 0.
+	MaxRestarts int
+
+	// InitialBackoff is the delay before the first restart. It's doubled with every consecutive restart.
+	// Defaults to 1s.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the maximum delay between restarts. Defaults to 1min.
+	MaxBackoff time.Duration
+
+	// ResetAfter is the time after which a running handler's consecutive restarts are reset.
+	// Defaults to 10min.
+	ResetAfter time.Duration
+
+	// Clock is used to wait between restarts.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (p *HandlerRestartPolicy) setDefaults() {
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = time.Second
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = time.Minute
+	}
+	if p.ResetAfter == 0 {
+		p.ResetAfter = time.Minute * 10
+	}
+	if p.Clock == nil {
+		p.Clock = watermill.RealClock{}
+	}
+}
+
+// Validate returns the policy's error, if any.
+func (p HandlerRestartPolicy) Validate() error {
+	if p.MaxRestarts < 0 {
+		return errors.New("MaxRestarts must not be negative")
+	}
+	if p.InitialBackoff < 0 || p.MaxBackoff < 0 || p.ResetAfter < 0 {
+		return errors.New("InitialBackoff, MaxBackoff and ResetAfter must not be negative")
+	}
+
+	return nil
+}
+
+func (p HandlerRestartPolicy) backoff(restarts int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 0; i < restarts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+
+	return backoff
+}
+
+// HandlerState is the state of the router's handler.
+type HandlerState string
+
+const (
+	// HandlerStateNotStarted means that the handler was added, but it's not running yet.
+	HandlerStateNotStarted HandlerState = "not_started"
+	// HandlerStateRunning means that the handler is subscribed and handles messages.
+	HandlerStateRunning HandlerState = "running"
+	// HandlerStateCrashLoopBackoff means that the handler's subscription was closed,
+	// and the handler waits to be restarted according to HandlerRestartPolicy.
+	HandlerStateCrashLoopBackoff HandlerState = "crash_loop_backoff"
+)
+
+// HandlerStatus describes the current state of the router's handler.
+type HandlerStatus struct {
+	State HandlerState
+
+	// Restarts is the number of consecutive restarts of the handler.
+	Restarts int
+
+	// NextRestartAt is the time of the next restart, when State is HandlerStateCrashLoopBackoff.
+	NextRestartAt time.Time
+
+	// LastRestartError is the error of the last failed restart attempt, if any.
+	LastRestartError error
+}
+
+// HandlerStatus returns the status of the handler.
+// Handlers which are stopped are removed from the router, so HandlerNotFoundError is returned for them.
+func (r *Router) HandlerStatus(handlerName string) (HandlerStatus, error) {
+	r.handlersLock.RLock()
+	h, ok := r.handlers[handlerName]
+	r.handlersLock.RUnlock()
+
+	if !ok {
+		return HandlerStatus{}, HandlerNotFoundError{handlerName}
+	}
+
+	return h.currentStatus(), nil
+}
+
+// SetRestartPolicy sets the restart policy of the handler, overriding RouterConfig.HandlerRestartPolicy.
+//
+// SetRestartPolicy must be called before the handler is started.
+func (h *Handler) SetRestartPolicy(policy HandlerRestartPolicy) error {
+	if h.handler.started {
+		panic("handler is already started")
+	}
+
+	policy.setDefaults()
+	if err := policy.Validate(); err != nil {
+		return errors.Wrap(err, "invalid restart policy")
+	}
+
+	h.handler.restartPolicy = &policy
+
+	return nil
+}
+
+func (h *handler) currentStatus() HandlerStatus {
+	h.statusLock.Lock()
+	defer h.statusLock.Unlock()
+
+	return h.status
+}
+
+func (h *handler) setStatus(status HandlerStatus) {
+	h.statusLock.Lock()
+	defer h.statusLock.Unlock()
+
+	h.status = status
+}
+
+// restart subscribes to the handler's topic again, after the subscription was closed.
+// It returns false if the handler should be stopped.
+func (h *handler) restart(ctx context.Context) bool {
+	policy := h.restartPolicy
+	if policy == nil || ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case <-h.routersCloseCh:
+		return false
+	default:
+	}
+
+	status := h.currentStatus()
+	if policy.Clock.Now().Sub(h.runningSince) >= policy.ResetAfter {
+		status.Restarts = 0
+	}
+
+	logFields := watermill.LogFields{
+		"handler_name": h.name,
+		"topic":        h.topicsLogField(),
+	}
+
+	for {
+		if policy.MaxRestarts > 0 && status.Restarts >= policy.MaxRestarts {
+			h.logger.Error("Handler exceeded max restarts, stopping", errors.New("subscription closed"), logFields.Add(watermill.LogFields{
+				"restarts": status.Restarts,
+			}))
+			return false
+		}
+
+		backoff := policy.backoff(status.Restarts)
+
+		status.State = HandlerStateCrashLoopBackoff
+		status.NextRestartAt = policy.Clock.Now().Add(backoff)
+		h.setStatus(status)
+
+		h.logger.Info("Subscription of handler closed, restarting", logFields.Add(watermill.LogFields{
+			"restarts":  status.Restarts,
+			"wait_time": backoff,
+		}))
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-policy.Clock.After(backoff):
+		}
+
+		status.Restarts++
+
+		messages, err := h.subscribe(ctx)
+		if err != nil {
+			h.logger.Error("Cannot restart handler", err, logFields)
+			status.LastRestartError = err
+			continue
+		}
+
+		h.messagesCh = messages
+		h.runningSince = policy.Clock.Now()
+
+		status.State = HandlerStateRunning
+		status.NextRestartAt = time.Time{}
+		h.setStatus(status)
+
+		return true
+	}
+}
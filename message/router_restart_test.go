@@ -0,0 +1,212 @@
+package message_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+type closableSubscription struct {
+	messages  chan *message.Message
+	closeOnce sync.Once
+}
+
+func (s *closableSubscription) close() {
+	s.closeOnce.Do(func() {
+		close(s.messages)
+	})
+}
+
+// restartableSubscriber creates a new subscription on every Subscribe call.
+type restartableSubscriber struct {
+	lock          sync.Mutex
+	subscriptions []*closableSubscription
+	failSubscribe bool
+}
+
+func (s *restartableSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.failSubscribe {
+		return nil, errors.New("cannot connect")
+	}
+
+	subscription := &closableSubscription{messages: make(chan *message.Message)}
+	s.subscriptions = append(s.subscriptions, subscription)
+
+	go func() {
+		<-ctx.Done()
+		subscription.close()
+	}()
+
+	return subscription.messages, nil
+}
+
+func (s *restartableSubscriber) current() *closableSubscription {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.subscriptions[len(s.subscriptions)-1]
+}
+
+func (s *restartableSubscriber) setFailSubscribe(fail bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failSubscribe = fail
+}
+
+func (s *restartableSubscriber) Close() error {
+	return nil
+}
+
+func TestRouter_HandlerRestartPolicy(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sub := &restartableSubscriber{}
+
+	router, err := message.NewRouter(message.RouterConfig{
+		HandlerRestartPolicy: &message.HandlerRestartPolicy{
+			MaxRestarts:    2,
+			InitialBackoff: time.Second,
+			Clock:          clock,
+		},
+	}, watermill.NewStdLogger(false, false))
+	require.NoError(t, err)
+
+	received := make(chan string)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", sub, func(msg *message.Message) error {
+		received <- msg.UUID
+		return nil
+	})
+
+	status, err := router.HandlerStatus("handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.HandlerStateNotStarted, status.State)
+
+	routerStopped := make(chan struct{})
+	go func() {
+		defer close(routerStopped)
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	sendMessage := func(uuid string) {
+		t.Helper()
+
+		msg := message.NewMessage(uuid, nil)
+		sub.current().messages <- msg
+
+		select {
+		case receivedUUID := <-received:
+			assert.Equal(t, uuid, receivedUUID)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+		<-msg.Acked()
+	}
+
+	sendMessage("1")
+
+	status, err = router.HandlerStatus("handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.HandlerStatus{State: message.HandlerStateRunning}, status)
+
+	// subscription closed unexpectedly
+	sub.current().close()
+	clock.BlockUntilWaiters(1)
+
+	status, err = router.HandlerStatus("handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.HandlerStateCrashLoopBackoff, status.State)
+	assert.Equal(t, 0, status.Restarts)
+	assert.Equal(t, clock.Now().Add(time.Second), status.NextRestartAt)
+
+	clock.Advance(time.Second)
+
+	assert.Eventually(t, func() bool {
+		status, err := router.HandlerStatus("handler")
+		return err == nil && status.State == message.HandlerStateRunning && status.Restarts == 1
+	}, time.Second, time.Millisecond*5)
+
+	sendMessage("2")
+
+	// the next restart fails, and the handler is stopped after MaxRestarts
+	sub.setFailSubscribe(true)
+	sub.current().close()
+	clock.BlockUntilWaiters(1)
+
+	status, err = router.HandlerStatus("handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.HandlerStateCrashLoopBackoff, status.State)
+	assert.Equal(t, clock.Now().Add(time.Second*2), status.NextRestartAt)
+
+	clock.Advance(time.Second * 2)
+
+	select {
+	case <-handler.Stopped():
+	case <-time.After(time.Second):
+		t.Fatal("handler not stopped")
+	}
+
+	// all handlers are stopped, so the router is closed
+	select {
+	case <-routerStopped:
+	case <-time.After(time.Second):
+		t.Fatal("router not stopped")
+	}
+
+	_, err = router.HandlerStatus("handler")
+	assert.ErrorAs(t, err, &message.HandlerNotFoundError{})
+}
+
+func TestRouter_HandlerRestartPolicy_not_applied_when_stopped(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sub := &restartableSubscriber{}
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	handler := router.AddNoPublisherHandler("handler", "topic", sub, func(msg *message.Message) error {
+		return nil
+	})
+	require.NoError(t, handler.SetRestartPolicy(message.HandlerRestartPolicy{Clock: clock}))
+
+	// a second handler keeps the router running
+	router.AddNoPublisherHandler("other_handler", "topic", &restartableSubscriber{}, func(msg *message.Message) error {
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	handler.Stop()
+
+	select {
+	case <-handler.Stopped():
+	case <-time.After(time.Second):
+		t.Fatal("handler not stopped")
+	}
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestRouterConfig_invalid_HandlerRestartPolicy(t *testing.T) {
+	_, err := message.NewRouter(message.RouterConfig{
+		HandlerRestartPolicy: &message.HandlerRestartPolicy{MaxRestarts: -1},
+	}, watermill.NopLogger{})
+	assert.ErrorContains(t, err, "MaxRestarts must not be negative")
+}
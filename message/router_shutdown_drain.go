@@ -0,0 +1,101 @@
+package message
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+const (
+	// DrainedHandlerMetadataKey is the metadata key of the name of the handler that received the drained message.
+	DrainedHandlerMetadataKey = "_watermill_drained_handler"
+	// DrainedTopicMetadataKey is the metadata key of the topic from which the drained message was received.
+	DrainedTopicMetadataKey = "_watermill_drained_topic"
+	// DrainedAtMetadataKey is the metadata key of the time when the message was drained (RFC 3339).
+	DrainedAtMetadataKey = "_watermill_drained_at"
+	// DrainedReasonMetadataKey is the metadata key of the reason why the message was drained.
+	DrainedReasonMetadataKey = "_watermill_drained_reason"
+)
+
+// DrainedReasonRouterClosed is the value of DrainedReasonMetadataKey for messages drained because the router was closing.
+const DrainedReasonRouterClosed = "router_closed"
+
+// ShutdownDrainPolicy configures forwarding the messages received by the handlers, but not handled yet
+// when the router is closing, to a parking topic.
+//
+// By default, such messages are handled or nacked, and the broker redelivers them after the restart.
+// With transports redelivering the messages slowly (for example, after a visibility timeout or an ack deadline),
+// it slows down redeploys. With ShutdownDrainPolicy, the messages are published to Topic and acked instead,
+// and they can be moved back to the original topic (see DrainedTopicMetadataKey), for example with the forwarder
+// or a router handler.
+//
+// Messages that are already being handled when the router starts closing are not affected.
+// If publishing fails, the message is nacked.
+type ShutdownDrainPolicy struct {
+	// Topic is the parking topic where the drained messages are published. It is required.
+	Topic string
+
+	// Publisher publishes the drained messages. It is required.
+	Publisher Publisher
+
+	// Clock is used to set DrainedAtMetadataKey.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+func (p *ShutdownDrainPolicy) setDefaults() {
+	if p.Clock == nil {
+		p.Clock = watermill.RealClock{}
+	}
+}
+
+// Validate returns the policy's error, if any.
+func (p ShutdownDrainPolicy) Validate() error {
+	if p.Topic == "" {
+		return errors.New("missing Topic")
+	}
+	if p.Publisher == nil {
+		return errors.New("missing Publisher")
+	}
+
+	return nil
+}
+
+// drainIfClosing publishes the message to the parking topic if the router is closing.
+// It returns true if the message was drained (or nacked after a failed publish) and must not be handled.
+func (h *handler) drainIfClosing(msg *Message, logger watermill.LoggerAdapter, msgFields watermill.LogFields) bool {
+	if h.shutdownDrain == nil {
+		return false
+	}
+
+	select {
+	case <-h.routersCloseCh:
+	default:
+		return false
+	}
+
+	policy := h.shutdownDrain
+
+	drained := msg.Copy()
+	drained.Metadata.Set(DrainedHandlerMetadataKey, h.name)
+	drained.Metadata.Set(DrainedTopicMetadataKey, SubscribeTopicFromCtx(msg.Context()))
+	drained.Metadata.Set(DrainedAtMetadataKey, policy.Clock.Now().UTC().Format(time.RFC3339Nano))
+	drained.Metadata.Set(DrainedReasonMetadataKey, DrainedReasonRouterClosed)
+
+	if err := policy.Publisher.Publish(policy.Topic, drained); err != nil {
+		logger.Error("Cannot drain message to parking topic, nacking", err, msgFields.Add(watermill.LogFields{
+			"parking_topic": policy.Topic,
+		}))
+		msg.Nack()
+		return true
+	}
+
+	msg.Ack()
+	logger.Trace("Message drained to parking topic", msgFields.Add(watermill.LogFields{
+		"parking_topic": policy.Topic,
+	}))
+
+	return true
+}
@@ -0,0 +1,168 @@
+package message_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// closeNotifyingSubscriber closes the closed channel when the router closes the subscriber.
+type closeNotifyingSubscriber struct {
+	bufferedSubscriber
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *closeNotifyingSubscriber) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+type parkingPublisher struct {
+	lock     sync.Mutex
+	topics   []string
+	messages []*message.Message
+	err      error
+}
+
+func (p *parkingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	for _, msg := range messages {
+		p.topics = append(p.topics, topic)
+		p.messages = append(p.messages, msg)
+	}
+
+	return nil
+}
+
+func (p *parkingPublisher) Close() error {
+	return nil
+}
+
+func (p *parkingPublisher) published() ([]string, []*message.Message) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string(nil), p.topics...), append([]*message.Message(nil), p.messages...)
+}
+
+func runRouterWithBlockedHandler(
+	t *testing.T,
+	publisher *parkingPublisher,
+) (sub *closeNotifyingSubscriber, handled <-chan *message.Message) {
+	t.Helper()
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	router, err := message.NewRouter(message.RouterConfig{
+		ShutdownDrain: &message.ShutdownDrainPolicy{
+			Topic:     "parking",
+			Publisher: publisher,
+			Clock:     watermill.NewFakeClock(now),
+		},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	sub = &closeNotifyingSubscriber{bufferedSubscriber: newBufferedSubscriber(5), closed: make(chan struct{})}
+	handledCh := make(chan *message.Message, 5)
+	handling := make(chan struct{}, 5)
+	release := make(chan struct{})
+
+	handler := router.AddNoPublisherHandler("handler", "topic", sub, func(msg *message.Message) error {
+		handling <- struct{}{}
+		<-release
+		handledCh <- msg
+		return nil
+	})
+	require.NoError(t, handler.SetConcurrency(message.HandlerConcurrency{Workers: 1}))
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	select {
+	case <-handling:
+	case <-time.After(time.Second):
+		t.Fatal("handler should receive the first message")
+	}
+
+	go func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	select {
+	case <-sub.closed:
+	case <-time.After(time.Second):
+		t.Fatal("router should close the subscriber")
+	}
+
+	// the first message was being handled when the router started closing
+	close(release)
+
+	return sub, handledCh
+}
+
+func TestRouter_ShutdownDrain(t *testing.T) {
+	publisher := &parkingPublisher{}
+	sub, handled := runRouterWithBlockedHandler(t, publisher)
+
+	for _, msg := range sub.messages {
+		requireAckedOrNacked(t, msg, true)
+	}
+
+	select {
+	case msg := <-handled:
+		assert.Equal(t, "0", msg.UUID)
+	case <-time.After(time.Second):
+		t.Fatal("message handled before closing should be handled")
+	}
+	assert.Empty(t, handled, "messages received after closing should not be handled")
+
+	topics, drained := publisher.published()
+	require.Len(t, drained, 4)
+
+	for i, msg := range drained {
+		assert.Equal(t, "parking", topics[i])
+		assert.Equal(t, sub.messages[i+1].UUID, msg.UUID)
+		assert.Equal(t, "handler", msg.Metadata.Get(message.DrainedHandlerMetadataKey))
+		assert.Equal(t, "topic", msg.Metadata.Get(message.DrainedTopicMetadataKey))
+		assert.Equal(t, "2024-01-02T03:04:05Z", msg.Metadata.Get(message.DrainedAtMetadataKey))
+		assert.Equal(t, message.DrainedReasonRouterClosed, msg.Metadata.Get(message.DrainedReasonMetadataKey))
+	}
+}
+
+func TestRouter_ShutdownDrain_publish_failed(t *testing.T) {
+	publisher := &parkingPublisher{err: assert.AnError}
+	sub, _ := runRouterWithBlockedHandler(t, publisher)
+
+	requireAckedOrNacked(t, sub.messages[0], true)
+	for _, msg := range sub.messages[1:] {
+		requireAckedOrNacked(t, msg, false)
+	}
+}
+
+func TestRouter_ShutdownDrain_invalid(t *testing.T) {
+	_, err := message.NewRouter(message.RouterConfig{
+		ShutdownDrain: &message.ShutdownDrainPolicy{Publisher: &parkingPublisher{}},
+	}, watermill.NopLogger{})
+	assert.ErrorContains(t, err, "missing Topic")
+
+	_, err = message.NewRouter(message.RouterConfig{
+		ShutdownDrain: &message.ShutdownDrainPolicy{Topic: "parking"},
+	}, watermill.NopLogger{})
+	assert.ErrorContains(t, err, "missing Publisher")
+}
@@ -0,0 +1,213 @@
+package message
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// SplitterFunc splits the message into multiple output messages.
+//
+// It must be deterministic: when the message is redelivered, it must return the same outputs, in the same order,
+// because outputs which were already published are identified by their position.
+type SplitterFunc func(msg *Message) ([]*Message, error)
+
+// SplitterConfig holds the configuration of the splitter handler (see Router.AddSplitterHandler).
+type SplitterConfig struct {
+	// MaxRetries is the maximum number of retries of publishing a single output. Disabled if 0.
+	MaxRetries int
+
+	// RetryDelay is the delay before the first retry of publishing an output. It's doubled with every retry.
+	// If 0, retries are done without waiting.
+	RetryDelay time.Duration
+
+	// MaxRetryDelay is the maximum delay between retries. Disabled if 0.
+	MaxRetryDelay time.Duration
+}
+
+// Validate returns the config's error, if any.
+func (c SplitterConfig) Validate() error {
+	if c.MaxRetries < 0 {
+		return errors.New("MaxRetries must not be negative")
+	}
+	if c.RetryDelay < 0 {
+		return errors.New("RetryDelay must not be negative")
+	}
+
+	return nil
+}
+
+// SplitterPublishError is returned by the splitter handler when some of the outputs were not published.
+type SplitterPublishError struct {
+	Failed int
+	Total  int
+	Err    error
+}
+
+func (e SplitterPublishError) Error() string {
+	return fmt.Sprintf("cannot publish %d of %d split messages: %s", e.Failed, e.Total, e.Err)
+}
+
+func (e SplitterPublishError) Unwrap() error {
+	return e.Err
+}
+
+// AddSplitterHandler adds a new handler which splits every received message into multiple output messages
+// published to publishTopic.
+//
+// In contrast to AddHandler, where produced messages are published all-or-nothing, every output is published
+// individually, with retries configured in SplitterConfig. The received message is acked only after all outputs
+// are published. If some of them fail, SplitterPublishError is returned and the message is nacked.
+// When the message is redelivered, only outputs which were not published yet are published again,
+// so other outputs are not duplicated.
+//
+// Outputs already published are remembered in memory by the message's UUID, until all outputs of the message
+// are published. They are not shared between instances of the service.
+//
+// handlerName must be unique. If handler is added while router is already running, you need to explicitly call RunHandlers().
+func (r *Router) AddSplitterHandler(
+	handlerName string,
+	subscribeTopic string,
+	subscriber Subscriber,
+	publishTopic string,
+	publisher Publisher,
+	splitterFunc SplitterFunc,
+	config SplitterConfig,
+) (*Handler, error) {
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid splitter config")
+	}
+	if publisher == nil {
+		return nil, errors.New("missing publisher")
+	}
+
+	s := &splitter{
+		config:    config,
+		split:     splitterFunc,
+		logger:    r.logger,
+		published: map[string]map[int]struct{}{},
+	}
+
+	h := r.AddHandler(handlerName, subscribeTopic, subscriber, publishTopic, publisher, func(msg *Message) ([]*Message, error) {
+		return nil, s.handle(s.handler, msg)
+	})
+	s.handler = h.handler
+
+	return h, nil
+}
+
+type splitter struct {
+	config  SplitterConfig
+	split   SplitterFunc
+	handler *handler
+	logger  watermill.LoggerAdapter
+
+	// published contains the indexes of outputs already published, by the UUID of the split message
+	published     map[string]map[int]struct{}
+	publishedLock sync.Mutex
+}
+
+func (s *splitter) handle(h *handler, msg *Message) error {
+	outputs, err := s.split(msg)
+	if err != nil {
+		return err
+	}
+
+	h.addHandlerContext(outputs...)
+
+	var publishErr error
+	failed := 0
+
+	for i, output := range outputs {
+		if s.isPublished(msg.UUID, i) {
+			continue
+		}
+
+		if err := s.publishWithRetries(h, msg, output); err != nil {
+			failed++
+			publishErr = err
+			continue
+		}
+
+		s.markPublished(msg.UUID, i)
+	}
+
+	if failed > 0 {
+		return SplitterPublishError{
+			Failed: failed,
+			Total:  len(outputs),
+			Err:    publishErr,
+		}
+	}
+
+	s.forget(msg.UUID)
+
+	return nil
+}
+
+func (s *splitter) publishWithRetries(h *handler, msg *Message, output *Message) error {
+	delay := s.config.RetryDelay
+
+	for attempt := 0; ; attempt++ {
+		err := h.publisher.Publish(h.publishTopic, output)
+		if err == nil {
+			return nil
+		}
+
+		logFields := watermill.LogFields{
+			"message_uuid":        msg.UUID,
+			"output_message_uuid": output.UUID,
+			"attempt":             attempt + 1,
+		}
+
+		if attempt >= s.config.MaxRetries {
+			s.logger.Error("Cannot publish split message", err, logFields)
+			return err
+		}
+
+		s.logger.Info("Cannot publish split message, retrying", logFields.Add(watermill.LogFields{
+			"error":     err.Error(),
+			"wait_time": delay,
+		}))
+
+		select {
+		case <-msg.Context().Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if s.config.MaxRetryDelay > 0 && delay > s.config.MaxRetryDelay {
+			delay = s.config.MaxRetryDelay
+		}
+	}
+}
+
+func (s *splitter) isPublished(msgUUID string, index int) bool {
+	s.publishedLock.Lock()
+	defer s.publishedLock.Unlock()
+
+	_, ok := s.published[msgUUID][index]
+	return ok
+}
+
+func (s *splitter) markPublished(msgUUID string, index int) {
+	s.publishedLock.Lock()
+	defer s.publishedLock.Unlock()
+
+	if _, ok := s.published[msgUUID]; !ok {
+		s.published[msgUUID] = map[int]struct{}{}
+	}
+	s.published[msgUUID][index] = struct{}{}
+}
+
+func (s *splitter) forget(msgUUID string) {
+	s.publishedLock.Lock()
+	defer s.publishedLock.Unlock()
+
+	delete(s.published, msgUUID)
+}
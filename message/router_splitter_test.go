@@ -0,0 +1,168 @@
+package message_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+// flakyPublisher fails publishing messages with the payload in failures, until their failures count is used up.
+type flakyPublisher struct {
+	lock      sync.Mutex
+	failures  map[string]int
+	published []string
+}
+
+func (p *flakyPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, msg := range messages {
+		payload := string(msg.Payload)
+		if p.failures[payload] > 0 {
+			p.failures[payload]--
+			return errors.Errorf("cannot publish %s", payload)
+		}
+		p.published = append(p.published, payload)
+	}
+
+	return nil
+}
+
+func (p *flakyPublisher) Close() error { return nil }
+
+func (p *flakyPublisher) Published() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string{}, p.published...)
+}
+
+func splitByComma(msg *message.Message) ([]*message.Message, error) {
+	var outputs []*message.Message
+	for _, part := range strings.Split(string(msg.Payload), ",") {
+		outputs = append(outputs, message.NewMessage(watermill.NewUUID(), []byte(part)))
+	}
+
+	return outputs, nil
+}
+
+func runSplitterRouter(
+	t *testing.T,
+	pubSub *gochannel.GoChannel,
+	publisher message.Publisher,
+	config message.SplitterConfig,
+	handlerErrors chan<- error,
+) {
+	t.Helper()
+
+	logger := watermill.NewStdLogger(false, false)
+
+	router, err := message.NewRouter(message.RouterConfig{}, logger)
+	require.NoError(t, err)
+
+	handler, err := router.AddSplitterHandler(
+		"splitter",
+		"input",
+		pubSub,
+		"output",
+		publisher,
+		splitByComma,
+		config,
+	)
+	require.NoError(t, err)
+
+	handler.AddMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			producedMessages, err := h(msg)
+			handlerErrors <- err
+			return producedMessages, err
+		}
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	t.Cleanup(func() {
+		assert.NoError(t, router.Close())
+	})
+}
+
+func TestRouter_AddSplitterHandler_retries_outputs(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	publisher := &flakyPublisher{failures: map[string]int{"b": 2}}
+	handlerErrors := make(chan error, 10)
+
+	runSplitterRouter(t, pubSub, publisher, message.SplitterConfig{
+		MaxRetries:    2,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: time.Millisecond,
+	}, handlerErrors)
+
+	require.NoError(t, pubSub.Publish("input", message.NewMessage(watermill.NewUUID(), []byte("a,b,c"))))
+
+	select {
+	case err := <-handlerErrors:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("message not handled")
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, publisher.Published())
+}
+
+func TestRouter_AddSplitterHandler_partial_failure(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	publisher := &flakyPublisher{failures: map[string]int{"b": 2}}
+	handlerErrors := make(chan error, 10)
+
+	runSplitterRouter(t, pubSub, publisher, message.SplitterConfig{}, handlerErrors)
+
+	require.NoError(t, pubSub.Publish("input", message.NewMessage(watermill.NewUUID(), []byte("a,b,c"))))
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-handlerErrors:
+			if i < 2 {
+				var splitterErr message.SplitterPublishError
+				require.ErrorAs(t, err, &splitterErr)
+				assert.Equal(t, 1, splitterErr.Failed)
+				assert.Equal(t, 3, splitterErr.Total)
+			} else {
+				assert.NoError(t, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message not redelivered")
+		}
+	}
+
+	// outputs published before the failure are not published again when the message is redelivered
+	assert.Equal(t, []string{"a", "c", "b"}, publisher.Published())
+}
+
+func TestRouter_AddSplitterHandler_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	_, err = router.AddSplitterHandler("splitter", "input", pubSub, "output", pubSub, splitByComma, message.SplitterConfig{
+		MaxRetries: -1,
+	})
+	assert.ErrorContains(t, err, "MaxRetries must not be negative")
+
+	_, err = router.AddSplitterHandler("splitter", "input", pubSub, "output", nil, splitByComma, message.SplitterConfig{})
+	assert.ErrorContains(t, err, "missing publisher")
+}
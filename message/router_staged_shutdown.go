@@ -0,0 +1,221 @@
+package message
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	sync_internal "github.com/ThreeDotsLabs/watermill/pubsub/sync"
+)
+
+// ShutdownStage is a stage of closing the router with StagedShutdownConfig.
+type ShutdownStage string
+
+const (
+	// ShutdownStageStopIntake stops receiving new messages: the subscribers of all handlers are closed.
+	// It finishes when all handlers stopped consuming their subscriptions.
+	ShutdownStageStopIntake ShutdownStage = "stop_intake"
+
+	// ShutdownStageDrainHandlers waits for the messages being handled, so their output messages are published.
+	ShutdownStageDrainHandlers ShutdownStage = "drain_handlers"
+
+	// ShutdownStageFlushPublishers flushes the handlers' publishers implementing PublisherWithFlush.
+	ShutdownStageFlushPublishers ShutdownStage = "flush_publishers"
+
+	// ShutdownStageClose closes the handlers' publishers.
+	ShutdownStageClose ShutdownStage = "close"
+)
+
+// PublisherWithFlush is a Publisher buffering the published messages, which can be flushed on demand.
+type PublisherWithFlush interface {
+	Publisher
+
+	// Flush publishes all buffered messages.
+	Flush(ctx context.Context) error
+}
+
+// StagedShutdownConfig configures closing the router in stages, in the order:
+// ShutdownStageStopIntake, ShutdownStageDrainHandlers, ShutdownStageFlushPublishers, and ShutdownStageClose.
+//
+// By default, each handler closes its publisher as soon as its subscription is closed, so the output
+// of messages still being handled (or of other handlers sharing the publisher) may be lost.
+// With the staged shutdown, publishers are closed only after all handlers are drained.
+//
+// RouterConfig.CloseTimeout is the limit for all stages. If it's exceeded while stopping the intake
+// or draining the handlers, the publishers are closed anyway, and Close returns an error.
+// Handlers stopped after the timeout close their publishers by themselves, when they stop.
+// Publishers of the handlers stopped before the router is closed are closed right away, as without this config.
+type StagedShutdownConfig struct {
+	// OnStageStarted is called when the stage starts.
+	//
+	// This option is not required.
+	OnStageStarted func(stage ShutdownStage)
+
+	// OnStageFinished is called when the stage finishes, with the error of the stage, if any.
+	//
+	// This option is not required.
+	OnStageFinished func(stage ShutdownStage, err error)
+}
+
+// stagedShutdown collects the handlers stopped by closing the router, so their publishers are closed
+// after all handlers are drained.
+type stagedShutdown struct {
+	config StagedShutdownConfig
+
+	lock     sync.Mutex
+	handlers []*handler
+
+	// handlersTaken is set when the handlers are taken to close their publishers.
+	// Handlers stopped later (after CloseTimeout) close their publishers by themselves.
+	handlersTaken bool
+}
+
+func newStagedShutdown(config *StagedShutdownConfig) *stagedShutdown {
+	if config == nil {
+		return nil
+	}
+
+	return &stagedShutdown{config: *config}
+}
+
+// deferPublisherClose returns true if the handler's publisher is closed by the staged shutdown of the router.
+func (h *handler) deferPublisherClose() bool {
+	if h.stagedShutdown == nil {
+		return false
+	}
+
+	select {
+	case <-h.routersCloseCh:
+	default:
+		// the handler is stopped while the router is running
+		return false
+	}
+
+	h.stagedShutdown.lock.Lock()
+	defer h.stagedShutdown.lock.Unlock()
+
+	if h.stagedShutdown.handlersTaken {
+		return false
+	}
+
+	h.stagedShutdown.handlers = append(h.stagedShutdown.handlers, h)
+
+	return true
+}
+
+// takeStoppedHandlers returns the stopped handlers, whose publishers are closed by the staged shutdown.
+func (s *stagedShutdown) takeStoppedHandlers() []*handler {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.handlersTaken = true
+
+	return append([]*handler(nil), s.handlers...)
+}
+
+func (s *stagedShutdown) runStage(stage ShutdownStage, logger watermill.LoggerAdapter, fn func() error) error {
+	logger.Debug("Starting router shutdown stage", watermill.LogFields{"stage": stage})
+	if s.config.OnStageStarted != nil {
+		s.config.OnStageStarted(stage)
+	}
+
+	err := fn()
+	if err != nil {
+		logger.Error("Router shutdown stage failed", err, watermill.LogFields{"stage": stage})
+	} else {
+		logger.Debug("Router shutdown stage finished", watermill.LogFields{"stage": stage})
+	}
+
+	if s.config.OnStageFinished != nil {
+		s.config.OnStageFinished(stage, err)
+	}
+
+	return err
+}
+
+// closeInStages closes the router with the staged shutdown. It's called by Close.
+func (r *Router) closeInStages() error {
+	s := r.stagedShutdown
+	deadline := time.Now().Add(r.config.CloseTimeout)
+
+	errTimeout := errors.New("router close timeout")
+
+	stopIntakeErr := s.runStage(ShutdownStageStopIntake, r.logger, func() error {
+		close(r.closingInProgressCh)
+
+		if sync_internal.WaitGroupTimeout(r.handlersWg, time.Until(deadline)) {
+			return errTimeout
+		}
+
+		return nil
+	})
+
+	var drainErr error
+	if stopIntakeErr == nil {
+		drainErr = s.runStage(ShutdownStageDrainHandlers, r.logger, func() error {
+			r.runningHandlersWgLock.Lock()
+			defer r.runningHandlersWgLock.Unlock()
+
+			if sync_internal.WaitGroupTimeout(r.runningHandlersWg, time.Until(deadline)) {
+				return errTimeout
+			}
+
+			return nil
+		})
+	}
+
+	handlers := s.takeStoppedHandlers()
+
+	var flushErr error
+	if stopIntakeErr == nil && drainErr == nil {
+		flushErr = s.runStage(ShutdownStageFlushPublishers, r.logger, func() error {
+			return r.flushPublishers(handlers, deadline)
+		})
+	}
+
+	_ = s.runStage(ShutdownStageClose, r.logger, func() error {
+		for _, h := range handlers {
+			h.closePublisher()
+		}
+		return nil
+	})
+
+	for _, err := range []error{stopIntakeErr, drainErr, flushErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) flushPublishers(handlers []*handler, deadline time.Time) error {
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var firstErr error
+	for _, h := range handlers {
+		flusher, ok := h.publisher.(PublisherWithFlush)
+		if !ok {
+			// the decorators don't forward Flush
+			flusher = h.flusher
+		}
+		if flusher == nil {
+			continue
+		}
+
+		if err := flusher.Flush(ctx); err != nil {
+			err = errors.Wrapf(err, "cannot flush publisher of handler %s", h.name)
+			r.logger.Error("Cannot flush publisher", err, watermill.LogFields{"handler_name": h.name})
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
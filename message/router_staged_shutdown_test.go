@@ -0,0 +1,271 @@
+package message_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// flushingPublisher records the published messages and the calls of Flush and Close.
+type flushingPublisher struct {
+	lock     sync.Mutex
+	messages []*message.Message
+	calls    []string
+	closed   bool
+}
+
+func (p *flushingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		return errors.New("publisher closed")
+	}
+
+	p.messages = append(p.messages, messages...)
+	p.calls = append(p.calls, "publish")
+
+	return nil
+}
+
+func (p *flushingPublisher) Flush(ctx context.Context) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.calls = append(p.calls, "flush")
+
+	return nil
+}
+
+func (p *flushingPublisher) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.closed = true
+	p.calls = append(p.calls, "close")
+
+	return nil
+}
+
+func (p *flushingPublisher) Calls() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return append([]string(nil), p.calls...)
+}
+
+type shutdownStagesRecorder struct {
+	lock   sync.Mutex
+	stages []string
+
+	// intakeStopped is closed when ShutdownStageStopIntake finishes
+	intakeStopped chan struct{}
+}
+
+func newShutdownStagesRecorder() *shutdownStagesRecorder {
+	return &shutdownStagesRecorder{intakeStopped: make(chan struct{})}
+}
+
+func (r *shutdownStagesRecorder) config() *message.StagedShutdownConfig {
+	return &message.StagedShutdownConfig{
+		OnStageStarted: func(stage message.ShutdownStage) {
+			r.record("started " + string(stage))
+		},
+		OnStageFinished: func(stage message.ShutdownStage, err error) {
+			if err != nil {
+				r.record("failed " + string(stage))
+			} else {
+				r.record("finished " + string(stage))
+			}
+			if stage == message.ShutdownStageStopIntake {
+				close(r.intakeStopped)
+			}
+		},
+	}
+}
+
+func (r *shutdownStagesRecorder) record(stage string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.stages = append(r.stages, stage)
+}
+
+func (r *shutdownStagesRecorder) Stages() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]string(nil), r.stages...)
+}
+
+func TestRouter_StagedShutdown(t *testing.T) {
+	stages := newShutdownStagesRecorder()
+
+	router, err := message.NewRouter(message.RouterConfig{
+		StagedShutdown: stages.config(),
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	messages := make(chan *message.Message, 1)
+	publisher := &flushingPublisher{}
+	handling := make(chan struct{})
+
+	router.AddHandler("handler", "in", channelSubscriber{messages}, "out", publisher, func(msg *message.Message) ([]*message.Message, error) {
+		close(handling)
+
+		// the output is published after the subscriber is closed
+		<-stages.intakeStopped
+
+		return []*message.Message{message.NewMessage("output", nil)}, nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	msg := message.NewMessage("1", nil)
+	messages <- msg
+	<-handling
+
+	require.NoError(t, router.Close())
+	requireAckedOrNacked(t, msg, true)
+
+	assert.Equal(t, []string{"publish", "flush", "close"}, publisher.Calls())
+	assert.Equal(t, []string{
+		"started stop_intake",
+		"finished stop_intake",
+		"started drain_handlers",
+		"finished drain_handlers",
+		"started flush_publishers",
+		"finished flush_publishers",
+		"started close",
+		"finished close",
+	}, stages.Stages())
+}
+
+func TestRouter_StagedShutdown_timeout(t *testing.T) {
+	stages := newShutdownStagesRecorder()
+
+	router, err := message.NewRouter(message.RouterConfig{
+		CloseTimeout:   time.Millisecond * 100,
+		StagedShutdown: stages.config(),
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	messages := make(chan *message.Message, 1)
+	publisher := &flushingPublisher{}
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	router.AddHandler("handler", "in", channelSubscriber{messages}, "out", publisher, func(msg *message.Message) ([]*message.Message, error) {
+		close(handling)
+		<-release
+		return nil, nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	messages <- message.NewMessage("1", nil)
+	<-handling
+
+	assert.EqualError(t, router.Close(), "router close timeout")
+
+	// the publishers are closed anyway, without flushing
+	assert.Equal(t, []string{"close"}, publisher.Calls())
+	assert.Equal(t, []string{
+		"started stop_intake",
+		"finished stop_intake",
+		"started drain_handlers",
+		"failed drain_handlers",
+		"started close",
+		"finished close",
+	}, stages.Stages())
+}
+
+func TestRouter_StagedShutdown_handler_stopped(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{
+		StagedShutdown: &message.StagedShutdownConfig{},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	stoppedPublisher := &flushingPublisher{}
+	stopped := router.AddHandler("stopped", "in", channelSubscriber{}, "out", stoppedPublisher, func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+	router.AddNoPublisherHandler("running", "in", channelSubscriber{}, noopHandler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		assert.NoError(t, router.Run(ctx))
+	}()
+	<-router.Running()
+
+	stopped.Stop()
+	<-stopped.Stopped()
+
+	// handlers stopped while the router is running close their publishers right away
+	assert.Equal(t, []string{"close"}, stoppedPublisher.Calls())
+}
+
+// lateSubscriber closes the subscription only when release is closed, regardless of the context.
+type lateSubscriber struct {
+	release chan struct{}
+}
+
+func (s lateSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	out := make(chan *message.Message)
+	go func() {
+		<-s.release
+		close(out)
+	}()
+
+	return out, nil
+}
+
+func (s lateSubscriber) Close() error {
+	return nil
+}
+
+func TestRouter_StagedShutdown_handler_stopped_after_timeout(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{
+		CloseTimeout:   time.Millisecond * 100,
+		StagedShutdown: &message.StagedShutdownConfig{},
+	}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	publisher := &flushingPublisher{}
+	release := make(chan struct{})
+
+	router.AddHandler("handler", "in", lateSubscriber{release}, "out", publisher, func(msg *message.Message) ([]*message.Message, error) {
+		return nil, nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+
+	assert.EqualError(t, router.Close(), "router close timeout")
+	assert.Empty(t, publisher.Calls(), "the handler is still running")
+
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"close"}, publisher.Calls())
+	}, time.Second, time.Millisecond*10, "the handler stopped after the timeout should close its publisher")
+}
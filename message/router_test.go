@@ -1421,3 +1421,217 @@ func TestRouter_stopping_all_handlers_logs_error(t *testing.T) {
 		logger.Captured(),
 	)
 }
+
+type subscribeOrderRecordingSubscriber struct {
+	message.Subscriber
+
+	lock   *sync.Mutex
+	topics *[]string
+}
+
+func (s subscribeOrderRecordingSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	s.lock.Lock()
+	*s.topics = append(*s.topics, topic)
+	s.lock.Unlock()
+
+	return s.Subscriber.Subscribe(ctx, topic)
+}
+
+func TestRouter_handlers_start_order(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	var subscribedTopics []string
+	sub := subscribeOrderRecordingSubscriber{
+		Subscriber: pubSub,
+		lock:       &sync.Mutex{},
+		topics:     &subscribedTopics,
+	}
+
+	r, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	noop := func(msg *message.Message) error { return nil }
+
+	aHandler := r.AddNoPublisherHandler("a", "a", sub, noop)
+	aHandler.StartAfter("b")
+
+	readinessCalled := false
+	bHandler := r.AddNoPublisherHandler("b", "b", sub, noop)
+	bHandler.StartAfter("c")
+	bHandler.StartWhen(func(ctx context.Context) error {
+		readinessCalled = true
+		return nil
+	})
+
+	r.AddNoPublisherHandler("c", "c", sub, noop)
+
+	go func() {
+		assert.NoError(t, r.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, r.Close())
+	}()
+
+	select {
+	case <-r.Running():
+	case <-time.After(time.Second):
+		t.Fatal("router not started")
+	}
+
+	assert.Equal(t, []string{"c", "b", "a"}, subscribedTopics)
+	assert.True(t, readinessCalled)
+}
+
+func TestRouter_handlers_start_order_errors(t *testing.T) {
+	testCases := []struct {
+		Name        string
+		AddHandlers func(r *message.Router, sub message.Subscriber)
+		ExpectedErr string
+	}{
+		{
+			Name: "unknown_dependency",
+			AddHandlers: func(r *message.Router, sub message.Subscriber) {
+				r.AddNoPublisherHandler("a", "a", sub, func(msg *message.Message) error { return nil }).
+					StartAfter("not_existing")
+			},
+			ExpectedErr: "handler a should start after unknown handler not_existing",
+		},
+		{
+			Name: "circular_dependency",
+			AddHandlers: func(r *message.Router, sub message.Subscriber) {
+				r.AddNoPublisherHandler("a", "a", sub, func(msg *message.Message) error { return nil }).
+					StartAfter("b")
+				r.AddNoPublisherHandler("b", "b", sub, func(msg *message.Message) error { return nil }).
+					StartAfter("a")
+			},
+			ExpectedErr: "handler a has a circular start dependency",
+		},
+		{
+			Name: "readiness_error",
+			AddHandlers: func(r *message.Router, sub message.Subscriber) {
+				r.AddNoPublisherHandler("a", "a", sub, func(msg *message.Message) error { return nil }).
+					StartWhen(func(ctx context.Context) error {
+						return errors.New("not ready")
+					})
+			},
+			ExpectedErr: "handler a is not ready to start: not ready",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+			defer func() {
+				assert.NoError(t, pubSub.Close())
+			}()
+
+			r, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+			require.NoError(t, err)
+
+			tc.AddHandlers(r, pubSub)
+
+			err = r.Run(context.Background())
+			assert.EqualError(t, err, tc.ExpectedErr)
+		})
+	}
+}
+
+func TestRouter_HandleMessage(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{OutputChannelBuffer: 1}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	r, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	middlewareCalled := false
+	r.AddMiddleware(func(h message.HandlerFunc) message.HandlerFunc {
+		return func(msg *message.Message) ([]*message.Message, error) {
+			middlewareCalled = true
+			assert.Equal(t, "handler", message.HandlerNameFromCtx(msg.Context()))
+			return h(msg)
+		}
+	})
+
+	r.AddHandler("handler", "in", pubSub, "out", pubSub, func(msg *message.Message) ([]*message.Message, error) {
+		if string(msg.Payload) == "fail" {
+			return nil, errors.New("handler failed")
+		}
+		return []*message.Message{message.NewMessage("produced", nil)}, nil
+	})
+
+	out, err := pubSub.Subscribe(context.Background(), "out")
+	require.NoError(t, err)
+
+	err = r.HandleMessage("handler", message.NewMessage("1", nil))
+	require.NoError(t, err)
+	assert.True(t, middlewareCalled)
+
+	produced, all := subscriber.BulkRead(out, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, "produced", produced[0].UUID)
+
+	err = r.HandleMessage("handler", message.NewMessage("2", []byte("fail")))
+	assert.EqualError(t, err, "handler failed")
+
+	err = r.HandleMessage("not_existing", message.NewMessage("3", nil))
+	assert.ErrorAs(t, err, &message.HandlerNotFoundError{})
+}
+
+func TestRouter_live_middleware_reconfiguration(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{BlockPublishUntilSubscriberAck: true}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	r, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	lock := sync.Mutex{}
+	var calls []string
+
+	recordingMiddleware := func(name string) message.HandlerMiddleware {
+		return func(h message.HandlerFunc) message.HandlerFunc {
+			return func(msg *message.Message) ([]*message.Message, error) {
+				lock.Lock()
+				calls = append(calls, name+":"+msg.UUID)
+				lock.Unlock()
+				return h(msg)
+			}
+		}
+	}
+
+	handler := r.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, r.Run(context.Background()))
+	}()
+	defer func() {
+		assert.NoError(t, r.Close())
+	}()
+	<-r.Running()
+
+	// BlockPublishUntilSubscriberAck makes Publish return after the message is handled
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+
+	r.AddNamedMiddleware("debug", recordingMiddleware("debug"))
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("2", nil)))
+
+	handler.AddMiddleware(recordingMiddleware("handler"))
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("3", nil)))
+
+	assert.True(t, r.RemoveMiddleware("debug"))
+	assert.False(t, r.RemoveMiddleware("debug"))
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("4", nil)))
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, []string{"debug:2", "debug:3", "handler:3", "handler:4"}, calls)
+}
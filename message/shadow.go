@@ -0,0 +1,200 @@
+package message
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// ShadowConfig holds the configuration of ShadowSubscriberDecorator.
+type ShadowConfig struct {
+	// Handler handles the copies of sampled messages. It's required.
+	// Errors returned by Handler are logged and don't affect the original message.
+	Handler NoPublishHandlerFunc
+
+	// SampleRate is the fraction of messages copied to Handler, from 0 (none) to 1 (all).
+	SampleRate float64
+
+	// Sample decides if the message is copied to Handler. If set, it's used instead of SampleRate.
+	Sample func(msg *Message) bool
+
+	// MaxConcurrency is the maximum number of copies handled at the same time.
+	// When it's reached, sampled messages are skipped, so a slow Handler never slows down the original subscriber.
+	// Defaults to 10.
+	MaxConcurrency int
+
+	// Timeout is the maximum duration of handling a single copy. Defaults to 30s.
+	Timeout time.Duration
+
+	// Logger instance used to log.
+	// If not provided, watermill.NopLogger is used.
+	Logger watermill.LoggerAdapter
+}
+
+func (c *ShadowConfig) setDefaults() {
+	if c.Sample == nil {
+		sampleRate := c.SampleRate
+		c.Sample = func(msg *Message) bool {
+			return rand.Float64() < sampleRate
+		}
+	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = 10
+	}
+	if c.Timeout == 0 {
+		c.Timeout = time.Second * 30
+	}
+	if c.Logger == nil {
+		c.Logger = watermill.NopLogger{}
+	}
+}
+
+// Validate returns the config's error, if any.
+func (c ShadowConfig) Validate() error {
+	if c.Handler == nil {
+		return errors.New("missing Handler")
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return errors.Errorf("SampleRate must be between 0 and 1, got %v", c.SampleRate)
+	}
+	if c.MaxConcurrency < 0 {
+		return errors.New("MaxConcurrency must not be negative")
+	}
+	if c.Timeout < 0 {
+		return errors.New("Timeout must not be negative")
+	}
+
+	return nil
+}
+
+// ShadowMetadataKey is set to "true" in the metadata of the copies handled by ShadowConfig.Handler.
+const ShadowMetadataKey = "_watermill_shadow"
+
+// ShadowSubscriberDecorator creates a subscriber decorator that copies a sample of received messages
+// to a secondary handler, for example, to test a new version of the handler against live traffic.
+//
+// The original messages are delivered unchanged, and only the subscriber's consumer acks or nacks them.
+// The copies are handled in the background: Handler can't ack or nack the original message,
+// its errors and panics are only logged, and sampled messages are skipped when MaxConcurrency is reached.
+//
+// Handler should not have side effects visible to other services (for example, it should write to a separate database),
+// because the copies are not redelivered and are handled regardless of the result of the original handler.
+func ShadowSubscriberDecorator(config ShadowConfig) SubscriberDecorator {
+	return func(sub Subscriber) (Subscriber, error) {
+		config.setDefaults()
+		if err := config.Validate(); err != nil {
+			return nil, errors.Wrap(err, "invalid shadow config")
+		}
+
+		return &shadowSubscriberDecorator{
+			Subscriber: sub,
+			config:     config,
+			semaphore:  make(chan struct{}, config.MaxConcurrency),
+		}, nil
+	}
+}
+
+type shadowSubscriberDecorator struct {
+	Subscriber
+	config ShadowConfig
+
+	semaphore  chan struct{}
+	handlersWg sync.WaitGroup
+	forwardWg  sync.WaitGroup
+}
+
+func (d *shadowSubscriberDecorator) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	in, err := d.Subscriber.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *Message)
+	d.forwardWg.Add(1)
+	go func() {
+		defer d.forwardWg.Done()
+		defer close(out)
+
+		for msg := range in {
+			if d.config.Sample(msg) {
+				d.shadow(topic, msg)
+			}
+			out <- msg
+		}
+	}()
+
+	return out, nil
+}
+
+func (d *shadowSubscriberDecorator) shadow(topic string, msg *Message) {
+	select {
+	case d.semaphore <- struct{}{}:
+	default:
+		d.config.Logger.Debug("Too many shadow messages in flight, skipping", watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"topic":        topic,
+		})
+		return
+	}
+
+	// the copy is made before the original message is delivered, so it's not affected by the original handler
+	shadowMsg := msg.DeepCopy()
+	shadowMsg.Metadata.Set(ShadowMetadataKey, "true")
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(msg.Context()), d.config.Timeout)
+	shadowMsg.SetContext(ctx)
+
+	d.handlersWg.Add(1)
+	go func() {
+		defer d.handlersWg.Done()
+		defer func() { <-d.semaphore }()
+		defer cancel()
+
+		logFields := watermill.LogFields{
+			"message_uuid": msg.UUID,
+			"topic":        topic,
+		}
+
+		if err := d.handle(shadowMsg); err != nil {
+			d.config.Logger.Error("Shadow handler failed", err, logFields)
+			return
+		}
+
+		d.config.Logger.Trace("Shadow message handled", logFields)
+	}()
+}
+
+func (d *shadowSubscriberDecorator) handle(msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("shadow handler panicked: %v", r)
+		}
+	}()
+
+	return d.config.Handler(msg)
+}
+
+func (d *shadowSubscriberDecorator) SubscribeInitialize(topic string) error {
+	initializer, ok := d.Subscriber.(SubscribeInitializer)
+	if !ok {
+		return nil
+	}
+
+	return initializer.SubscribeInitialize(topic)
+}
+
+// Close closes the subscriber and waits for the copies being handled.
+func (d *shadowSubscriberDecorator) Close() error {
+	err := d.Subscriber.Close()
+
+	d.forwardWg.Wait()
+	d.handlersWg.Wait()
+
+	return err
+}
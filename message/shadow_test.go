@@ -0,0 +1,144 @@
+package message_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestShadowSubscriberDecorator(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	var lock sync.Mutex
+	var shadowed []*message.Message
+
+	sub, err := message.ShadowSubscriberDecorator(message.ShadowConfig{
+		Handler: func(msg *message.Message) error {
+			lock.Lock()
+			shadowed = append(shadowed, msg)
+			lock.Unlock()
+
+			// changes and errors of the shadow handler don't affect the original message
+			msg.Payload = []byte("modified")
+			msg.Nack()
+			return errors.New("shadow failed")
+		},
+		SampleRate: 1,
+	})(pubSub)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, sub.Close())
+	}()
+
+	messages, err := sub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	published := []*message.Message{
+		message.NewMessage("1", []byte("a")),
+		message.NewMessage("2", []byte("b")),
+	}
+	go func() {
+		assert.NoError(t, pubSub.Publish("topic", published...))
+	}()
+
+	received, all := subscriber.BulkRead(messages, 2, time.Second)
+	require.True(t, all)
+
+	for _, msg := range received {
+		assert.Empty(t, msg.Metadata.Get(message.ShadowMetadataKey))
+	}
+
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(shadowed) == 2
+	}, time.Second, time.Millisecond*10)
+
+	assert.ElementsMatch(t, []string{"a", "b"}, []string{string(received[0].Payload), string(received[1].Payload)})
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, msg := range shadowed {
+		assert.Equal(t, "true", msg.Metadata.Get(message.ShadowMetadataKey))
+	}
+}
+
+func TestShadowSubscriberDecorator_sampling_and_concurrency(t *testing.T) {
+	// messages are delivered one by one, in the order of publishing
+	pubSub := gochannel.NewGoChannel(gochannel.Config{BlockPublishUntilSubscriberAck: true}, watermill.NopLogger{})
+
+	release := make(chan struct{})
+	shadowed := make(chan string, 10)
+
+	sub, err := message.ShadowSubscriberDecorator(message.ShadowConfig{
+		Handler: func(msg *message.Message) error {
+			shadowed <- msg.UUID
+			<-release
+			return nil
+		},
+		Sample: func(msg *message.Message) bool {
+			return msg.Metadata.Get("sample") == "true"
+		},
+		MaxConcurrency: 1,
+	})(pubSub)
+	require.NoError(t, err)
+
+	messages, err := sub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		for _, uuid := range []string{"1", "2", "3"} {
+			msg := message.NewMessage(uuid, nil)
+			if uuid != "2" {
+				msg.Metadata.Set("sample", "true")
+			}
+			assert.NoError(t, pubSub.Publish("topic", msg))
+		}
+	}()
+
+	// the original messages are delivered while the shadow handler is blocked
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-messages:
+			msg.Ack()
+		case <-time.After(time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+
+	close(release)
+	require.NoError(t, sub.Close())
+	close(shadowed)
+
+	var shadowedUUIDs []string
+	for uuid := range shadowed {
+		shadowedUUIDs = append(shadowedUUIDs, uuid)
+	}
+
+	// message 2 is not sampled and message 3 is skipped, because the first copy is still handled
+	assert.Equal(t, []string{"1"}, shadowedUUIDs)
+}
+
+func TestShadowSubscriberDecorator_invalid_config(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	_, err := message.ShadowSubscriberDecorator(message.ShadowConfig{SampleRate: 0.5})(pubSub)
+	assert.ErrorContains(t, err, "missing Handler")
+
+	_, err = message.ShadowSubscriberDecorator(message.ShadowConfig{
+		Handler:    func(msg *message.Message) error { return nil },
+		SampleRate: 2,
+	})(pubSub)
+	assert.ErrorContains(t, err, "SampleRate must be between 0 and 1, got 2")
+}
@@ -0,0 +1,250 @@
+package message
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+)
+
+// SubscriberLag describes how far behind the producers the subscriber of a topic is.
+type SubscriberLag struct {
+	// Messages is the number of messages of the topic not consumed by the subscriber yet.
+	// It's -1 if the subscriber can't tell it.
+	Messages int64
+
+	// OldestUnackedMessageAge is the age of the oldest message which was not acked yet.
+	// It's 0 if there are no unacked messages.
+	OldestUnackedMessageAge time.Duration
+
+	// ConsumeDelay is the time between producing and receiving the last received message.
+	// It's 0 if the subscriber can't tell it.
+	ConsumeDelay time.Duration
+}
+
+// SubscriberWithLag is an optional interface of Subscriber, implemented by transports which can report the lag.
+// Router.HandlerLag uses it to return the lag of the handler.
+type SubscriberWithLag interface {
+	Subscriber
+
+	// Lag returns the lag of the subscriber of the topic.
+	Lag(ctx context.Context, topic string) (SubscriberLag, error)
+}
+
+// SubscriberUnwrapper is implemented by subscriber decorators, to give access to the decorated subscriber.
+// It's used to find the optional interfaces (like SubscriberWithLag) of decorated subscribers.
+type SubscriberUnwrapper interface {
+	Unwrap() Subscriber
+}
+
+// ErrLagNotSupported is returned by Router.HandlerLag when the handler's subscriber doesn't implement SubscriberWithLag.
+var ErrLagNotSupported = errors.New("subscriber doesn't support lag reporting")
+
+// HandlerLag returns the lag of the handler's subscriber.
+//
+// The subscriber (or one of the subscribers it decorates, see SubscriberUnwrapper) must implement SubscriberWithLag.
+// For transports which can't report the lag, use LagTrackingSubscriberDecorator.
+//
+// For handlers of multiple topics (see AddHandlerMultiTopic), the lag of all topics is combined:
+// Messages are summed (or -1 if the lag of any topic is unknown), and the largest durations are returned.
+func (r *Router) HandlerLag(ctx context.Context, handlerName string) (SubscriberLag, error) {
+	r.handlersLock.RLock()
+	h, ok := r.handlers[handlerName]
+	r.handlersLock.RUnlock()
+
+	if !ok {
+		return SubscriberLag{}, HandlerNotFoundError{handlerName}
+	}
+
+	sub := h.subscriber
+	for sub != nil {
+		if withLag, ok := sub.(SubscriberWithLag); ok {
+			return topicsLag(ctx, withLag, h.subscribeTopics)
+		}
+
+		unwrapper, ok := sub.(SubscriberUnwrapper)
+		if !ok {
+			break
+		}
+		sub = unwrapper.Unwrap()
+	}
+
+	return SubscriberLag{}, ErrLagNotSupported
+}
+
+func topicsLag(ctx context.Context, sub SubscriberWithLag, topics []string) (SubscriberLag, error) {
+	var combined SubscriberLag
+
+	for i, topic := range topics {
+		lag, err := sub.Lag(ctx, topic)
+		if err != nil {
+			return SubscriberLag{}, err
+		}
+		if i == 0 {
+			combined = lag
+			continue
+		}
+
+		if combined.Messages < 0 || lag.Messages < 0 {
+			combined.Messages = -1
+		} else {
+			combined.Messages += lag.Messages
+		}
+		if lag.OldestUnackedMessageAge > combined.OldestUnackedMessageAge {
+			combined.OldestUnackedMessageAge = lag.OldestUnackedMessageAge
+		}
+		if lag.ConsumeDelay > combined.ConsumeDelay {
+			combined.ConsumeDelay = lag.ConsumeDelay
+		}
+	}
+
+	return combined, nil
+}
+
+// LagTrackingConfig configures LagTrackingSubscriberDecorator.
+type LagTrackingConfig struct {
+	// MessageTimestamp returns the time when the message was produced, for example from the metadata
+	// (see cqrs.StandardMetadataConfig.ProducedAt). It is required.
+	//
+	// Messages without the timestamp are tracked since they are received.
+	MessageTimestamp func(msg *Message) (time.Time, bool)
+
+	// Clock is used to measure the age of messages.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+}
+
+// LagTrackingSubscriberDecorator tracks the approximate lag of the subscriber on the client side,
+// for transports which can't report the lag natively.
+//
+// The lag is measured from the messages received by the subscriber and their production timestamps:
+// OldestUnackedMessageAge is the age of the oldest received message which was not acked yet,
+// and ConsumeDelay is the time since producing the last received message when it was received.
+// The number of messages waiting in the broker is unknown, so Messages is always -1.
+func LagTrackingSubscriberDecorator(config LagTrackingConfig) SubscriberDecorator {
+	if config.MessageTimestamp == nil {
+		panic("MessageTimestamp is required")
+	}
+	if config.Clock == nil {
+		config.Clock = watermill.RealClock{}
+	}
+
+	return func(sub Subscriber) (Subscriber, error) {
+		return &lagTrackingSubscriber{
+			sub:    sub,
+			config: config,
+			topics: map[string]*topicLag{},
+		}, nil
+	}
+}
+
+type topicLag struct {
+	inFlight     map[*Message]time.Time
+	consumeDelay time.Duration
+}
+
+type lagTrackingSubscriber struct {
+	sub    Subscriber
+	config LagTrackingConfig
+
+	topics map[string]*topicLag
+	lock   sync.Mutex
+}
+
+func (s *lagTrackingSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	in, err := s.sub.Subscribe(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan *Message)
+
+	go func() {
+		defer close(out)
+
+		for msg := range in {
+			s.received(topic, msg)
+
+			go s.waitForAck(ctx, topic, msg)
+
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (s *lagTrackingSubscriber) received(topic string, msg *Message) {
+	now := s.config.Clock.Now()
+
+	producedAt, ok := s.config.MessageTimestamp(msg)
+	if !ok {
+		producedAt = now
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	lag, ok := s.topics[topic]
+	if !ok {
+		lag = &topicLag{inFlight: map[*Message]time.Time{}}
+		s.topics[topic] = lag
+	}
+
+	lag.inFlight[msg] = producedAt
+	lag.consumeDelay = now.Sub(producedAt)
+}
+
+func (s *lagTrackingSubscriber) waitForAck(ctx context.Context, topic string, msg *Message) {
+	select {
+	case <-msg.Acked():
+	case <-msg.Nacked():
+	case <-ctx.Done():
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.topics[topic].inFlight, msg)
+}
+
+func (s *lagTrackingSubscriber) Lag(ctx context.Context, topic string) (SubscriberLag, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := SubscriberLag{Messages: -1}
+
+	lag, ok := s.topics[topic]
+	if !ok {
+		return result, nil
+	}
+
+	result.ConsumeDelay = lag.consumeDelay
+
+	var oldest time.Time
+	for _, producedAt := range lag.inFlight {
+		if oldest.IsZero() || producedAt.Before(oldest) {
+			oldest = producedAt
+		}
+	}
+	if !oldest.IsZero() {
+		result.OldestUnackedMessageAge = s.config.Clock.Now().Sub(oldest)
+	}
+
+	return result, nil
+}
+
+func (s *lagTrackingSubscriber) Unwrap() Subscriber {
+	return s.sub
+}
+
+func (s *lagTrackingSubscriber) Close() error {
+	return s.sub.Close()
+}
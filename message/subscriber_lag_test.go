@@ -0,0 +1,89 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestRouter_HandlerLag(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddSubscriberDecorators(message.LagTrackingSubscriberDecorator(message.LagTrackingConfig{
+		MessageTimestamp: func(msg *message.Message) (time.Time, bool) {
+			producedAt, err := time.Parse(time.RFC3339, msg.Metadata.Get("produced_at"))
+			return producedAt, err == nil
+		},
+		Clock: clock,
+	}))
+
+	handlerStarted := make(chan struct{})
+	releaseHandler := make(chan struct{})
+
+	router.AddNoPublisherHandler("handler", "topic", pubSub, func(msg *message.Message) error {
+		close(handlerStarted)
+		<-releaseHandler
+		return nil
+	})
+
+	go func() {
+		assert.NoError(t, router.Run(context.Background()))
+	}()
+	<-router.Running()
+	defer func() {
+		assert.NoError(t, router.Close())
+	}()
+
+	lag, err := router.HandlerLag(context.Background(), "handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.SubscriberLag{Messages: -1}, lag)
+
+	msg := message.NewMessage("1", nil)
+	msg.Metadata.Set("produced_at", clock.Now().Add(-time.Second*3).Format(time.RFC3339))
+	require.NoError(t, pubSub.Publish("topic", msg))
+
+	<-handlerStarted
+	clock.Advance(time.Second * 2)
+
+	lag, err = router.HandlerLag(context.Background(), "handler")
+	require.NoError(t, err)
+	assert.Equal(t, message.SubscriberLag{
+		Messages:                -1,
+		OldestUnackedMessageAge: time.Second * 5,
+		ConsumeDelay:            time.Second * 3,
+	}, lag)
+
+	close(releaseHandler)
+
+	assert.Eventually(t, func() bool {
+		lag, err := router.HandlerLag(context.Background(), "handler")
+		return err == nil && lag.OldestUnackedMessageAge == 0 && lag.ConsumeDelay == time.Second*3
+	}, time.Second, time.Millisecond*5)
+}
+
+func TestRouter_HandlerLag_not_supported(t *testing.T) {
+	router, err := message.NewRouter(message.RouterConfig{}, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	router.AddNoPublisherHandler("handler", "topic", gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{}), func(msg *message.Message) error {
+		return nil
+	})
+
+	_, err = router.HandlerLag(context.Background(), "handler")
+	assert.ErrorIs(t, err, message.ErrLagNotSupported)
+
+	_, err = router.HandlerLag(context.Background(), "unknown")
+	assert.ErrorAs(t, err, &message.HandlerNotFoundError{})
+}
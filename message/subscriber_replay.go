@@ -0,0 +1,38 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReplayPosition is the historical position of a topic from which SubscriberWithReplay starts.
+// The zero value starts from the oldest message available.
+type ReplayPosition struct {
+	// Offset is the number of the oldest messages of the topic to skip.
+	// The meaning of the offset depends on the transport (for example, a log offset or an index of the message).
+	Offset int64
+
+	// Since if not zero starts from the first message produced at or after it.
+	Since time.Time
+}
+
+// ReplayInfo describes the historical messages replayed by SubscriberWithReplay.
+type ReplayInfo struct {
+	// Messages is the number of historical messages which are sent before the messages produced after subscribing.
+	Messages int64
+}
+
+// SubscriberWithReplay is an optional interface of Subscriber, implemented by transports which can replay
+// the historical messages of a topic, for example, to rebuild projections.
+type SubscriberWithReplay interface {
+	Subscriber
+
+	// SubscribeFrom works like Subscribe, but it sends the historical messages of the topic from the position first.
+	// After all historical messages are sent, messages produced after subscribing are sent.
+	SubscribeFrom(ctx context.Context, topic string, position ReplayPosition) (<-chan *Message, ReplayInfo, error)
+}
+
+// ErrReplayNotSupported is returned when the subscriber doesn't implement SubscriberWithReplay.
+var ErrReplayNotSupported = errors.New("subscriber doesn't support replay")
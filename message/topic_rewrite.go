@@ -0,0 +1,79 @@
+package message
+
+import (
+	"context"
+)
+
+// TopicRewriteRules declares how topics are rewritten by TopicRewritePublisherDecorator
+// and TopicRewriteSubscriberDecorator.
+//
+// Mapping is applied first, and Prefix is added to the result.
+// For example, with Mapping {"orders": "orders_v2"} and Prefix "staging.", topic "orders" becomes "staging.orders_v2".
+type TopicRewriteRules struct {
+	// Prefix is added to every topic, for example, the name of the environment.
+	Prefix string
+
+	// Mapping maps topics to new names, for example, legacy topic names to the current ones.
+	// Topics not present in Mapping are not renamed.
+	Mapping map[string]string
+}
+
+// RewriteTopic returns the topic rewritten according to the rules.
+func (r TopicRewriteRules) RewriteTopic(topic string) string {
+	if mapped, ok := r.Mapping[topic]; ok {
+		topic = mapped
+	}
+
+	return r.Prefix + topic
+}
+
+// TopicRewritePublisherDecorator creates a publisher decorator that rewrites topics of published messages.
+//
+// It can be used to isolate environments (for example, staging and production) sharing one broker,
+// without changing topic generators in every service.
+// Use it together with TopicRewriteSubscriberDecorator configured with the same rules.
+func TopicRewritePublisherDecorator(rules TopicRewriteRules) PublisherDecorator {
+	return func(pub Publisher) (Publisher, error) {
+		return topicRewritePublisherDecorator{
+			Publisher: pub,
+			rules:     rules,
+		}, nil
+	}
+}
+
+// TopicRewriteSubscriberDecorator creates a subscriber decorator that rewrites topics of subscriptions.
+func TopicRewriteSubscriberDecorator(rules TopicRewriteRules) SubscriberDecorator {
+	return func(sub Subscriber) (Subscriber, error) {
+		return topicRewriteSubscriberDecorator{
+			Subscriber: sub,
+			rules:      rules,
+		}, nil
+	}
+}
+
+type topicRewritePublisherDecorator struct {
+	Publisher
+	rules TopicRewriteRules
+}
+
+func (d topicRewritePublisherDecorator) Publish(topic string, messages ...*Message) error {
+	return d.Publisher.Publish(d.rules.RewriteTopic(topic), messages...)
+}
+
+type topicRewriteSubscriberDecorator struct {
+	Subscriber
+	rules TopicRewriteRules
+}
+
+func (d topicRewriteSubscriberDecorator) Subscribe(ctx context.Context, topic string) (<-chan *Message, error) {
+	return d.Subscriber.Subscribe(ctx, d.rules.RewriteTopic(topic))
+}
+
+func (d topicRewriteSubscriberDecorator) SubscribeInitialize(topic string) error {
+	initializer, ok := d.Subscriber.(SubscribeInitializer)
+	if !ok {
+		return nil
+	}
+
+	return initializer.SubscribeInitialize(d.rules.RewriteTopic(topic))
+}
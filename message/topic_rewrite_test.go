@@ -0,0 +1,77 @@
+package message_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestTopicRewriteRules_RewriteTopic(t *testing.T) {
+	rules := message.TopicRewriteRules{
+		Prefix: "staging.",
+		Mapping: map[string]string{
+			"orders": "orders_v2",
+		},
+	}
+
+	assert.Equal(t, "staging.orders_v2", rules.RewriteTopic("orders"))
+	assert.Equal(t, "staging.invoices", rules.RewriteTopic("invoices"))
+
+	assert.Equal(t, "orders_v2", message.TopicRewriteRules{Mapping: rules.Mapping}.RewriteTopic("orders"))
+	assert.Equal(t, "invoices", message.TopicRewriteRules{}.RewriteTopic("invoices"))
+}
+
+func TestTopicRewriteDecorators(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{OutputChannelBuffer: 10},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	rules := message.TopicRewriteRules{
+		Prefix:  "staging.",
+		Mapping: map[string]string{"legacy_orders": "orders"},
+	}
+
+	pub, err := message.TopicRewritePublisherDecorator(rules)(pubSub)
+	require.NoError(t, err)
+
+	sub, err := message.TopicRewriteSubscriberDecorator(rules)(pubSub)
+	require.NoError(t, err)
+
+	rawMessages, err := pubSub.Subscribe(context.Background(), "staging.orders")
+	require.NoError(t, err)
+
+	decoratedMessages, err := sub.Subscribe(context.Background(), "orders")
+	require.NoError(t, err)
+
+	productionMessages, err := pubSub.Subscribe(context.Background(), "orders")
+	require.NoError(t, err)
+
+	require.NoError(t, pub.Publish("legacy_orders", message.NewMessage("1", nil)))
+
+	received, all := subscriber.BulkRead(rawMessages, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"1"}, received.IDs())
+
+	received, all = subscriber.BulkRead(decoratedMessages, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"1"}, received.IDs())
+
+	select {
+	case msg := <-productionMessages:
+		t.Fatalf("message %s should not be published to not prefixed topic", msg.UUID)
+	case <-time.After(time.Millisecond * 50):
+		// ok
+	}
+}
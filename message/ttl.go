@@ -0,0 +1,54 @@
+package message
+
+import (
+	"time"
+)
+
+// ExpiresAtMetadataKey is the metadata key of the time after which the message should not be processed,
+// in RFC 3339 format. It's set by SetTTL and SetExpiresAt.
+const ExpiresAtMetadataKey = "_watermill_expires_at"
+
+// SetTTL sets the message to expire after ttl from now.
+func SetTTL(msg *Message, ttl time.Duration) {
+	SetExpiresAt(msg, time.Now().Add(ttl))
+}
+
+// SetExpiresAt sets the message to expire at the given time.
+func SetExpiresAt(msg *Message, expiresAt time.Time) {
+	msg.Metadata.Set(ExpiresAtMetadataKey, expiresAt.UTC().Format(time.RFC3339Nano))
+}
+
+// ExpiresAt returns the time when the message expires.
+// It returns false if the message has no expiry or it's invalid.
+func ExpiresAt(msg *Message) (time.Time, bool) {
+	value := msg.Metadata.Get(ExpiresAtMetadataKey)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
+
+// RemainingTTL returns the time left until the message expires, never negative.
+// It returns false if the message has no expiry.
+//
+// Publishers of Pub/Subs with native message TTL (for example, the AMQP expiration property)
+// can use it to map the expiry to the native TTL.
+func RemainingTTL(msg *Message, now time.Time) (time.Duration, bool) {
+	expiresAt, ok := ExpiresAt(msg)
+	if !ok {
+		return 0, false
+	}
+
+	remaining := expiresAt.Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return remaining, true
+}
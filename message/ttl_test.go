@@ -0,0 +1,53 @@
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+func TestSetExpiresAt(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	msg := message.NewMessage("1", nil)
+	message.SetExpiresAt(msg, expiresAt)
+
+	got, ok := message.ExpiresAt(msg)
+	require.True(t, ok)
+	assert.True(t, expiresAt.Equal(got))
+
+	remaining, ok := message.RemainingTTL(msg, expiresAt.Add(-time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, remaining)
+
+	remaining, ok = message.RemainingTTL(msg, expiresAt.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, time.Duration(0), remaining)
+}
+
+func TestSetTTL(t *testing.T) {
+	msg := message.NewMessage("1", nil)
+	message.SetTTL(msg, time.Hour)
+
+	expiresAt, ok := message.ExpiresAt(msg)
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Second)
+}
+
+func TestExpiresAt_missing_or_invalid(t *testing.T) {
+	msg := message.NewMessage("1", nil)
+
+	_, ok := message.ExpiresAt(msg)
+	assert.False(t, ok)
+
+	_, ok = message.RemainingTTL(msg, time.Now())
+	assert.False(t, ok)
+
+	msg.Metadata.Set(message.ExpiresAtMetadataKey, "never")
+	_, ok = message.ExpiresAt(msg)
+	assert.False(t, ok)
+}
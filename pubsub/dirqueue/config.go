@@ -0,0 +1,114 @@
+package dirqueue
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FsyncPolicy decides when the written data is synced to the disk.
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the segment file before Publish returns.
+	// Published messages survive a crash of the machine, but publishing is the slowest.
+	FsyncAlways FsyncPolicy = "always"
+
+	// FsyncInterval syncs the segment files every PublisherConfig.FsyncInterval.
+	// Messages published in the last interval may be lost if the machine crashes.
+	FsyncInterval FsyncPolicy = "interval"
+
+	// FsyncNever leaves syncing to the operating system.
+	// Published messages survive a crash of the process, but not of the machine.
+	FsyncNever FsyncPolicy = "never"
+)
+
+func (p FsyncPolicy) validate() error {
+	switch p {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+		return nil
+	default:
+		return errors.Errorf("unknown FsyncPolicy %s", p)
+	}
+}
+
+// PublisherConfig holds the Publisher's configuration options.
+type PublisherConfig struct {
+	// Dir is the directory of topics. It's created if it doesn't exist. It is required.
+	Dir string
+
+	// SegmentMaxBytes is the size after which the topic's segment file is closed,
+	// and the following messages are written to a new one. Defaults to 64 MiB.
+	SegmentMaxBytes int64
+
+	// Fsync decides when the segment files are synced to the disk. Defaults to FsyncAlways.
+	Fsync FsyncPolicy
+
+	// FsyncInterval is the interval of syncing with FsyncInterval. Defaults to 1 second.
+	FsyncInterval time.Duration
+}
+
+func (c *PublisherConfig) setDefaults() {
+	if c.SegmentMaxBytes == 0 {
+		c.SegmentMaxBytes = 64 * 1024 * 1024
+	}
+	if c.Fsync == "" {
+		c.Fsync = FsyncAlways
+	}
+	if c.FsyncInterval == 0 {
+		c.FsyncInterval = time.Second
+	}
+}
+
+func (c PublisherConfig) Validate() error {
+	if c.Dir == "" {
+		return errors.New("missing Dir")
+	}
+	if c.SegmentMaxBytes < 0 {
+		return errors.New("SegmentMaxBytes must not be negative")
+	}
+	if c.FsyncInterval < 0 {
+		return errors.New("FsyncInterval must not be negative")
+	}
+
+	return c.Fsync.validate()
+}
+
+// SubscriberConfig holds the Subscriber's configuration options.
+type SubscriberConfig struct {
+	// Dir is the directory of topics. It's created if it doesn't exist. It is required.
+	Dir string
+
+	// ConsumerGroup is the name of the consumer group. Messages of the topic are delivered once to the group,
+	// even if there are multiple subscriptions, and the group's offset is persisted, so it continues
+	// after restarting. A new group starts with the oldest message of the topic.
+	//
+	// If empty, every subscription receives all messages of the topic, starting with the oldest one,
+	// and the offset is not persisted.
+	ConsumerGroup string
+
+	// PollInterval is the interval of checking for new messages, when all messages of the topic were consumed.
+	// Defaults to 100 milliseconds.
+	PollInterval time.Duration
+
+	// FsyncOffsets syncs the consumer group's offset to the disk after every ack.
+	// Otherwise, messages acked just before a crash of the machine may be redelivered.
+	FsyncOffsets bool
+}
+
+func (c *SubscriberConfig) setDefaults() {
+	if c.PollInterval == 0 {
+		c.PollInterval = 100 * time.Millisecond
+	}
+}
+
+func (c SubscriberConfig) Validate() error {
+	if c.Dir == "" {
+		return errors.New("missing Dir")
+	}
+	if c.PollInterval < 0 {
+		return errors.New("PollInterval must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package dirqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const offsetsDir = "offsets"
+
+// offset is the position of the next message to consume in the topic's segments.
+type offset struct {
+	Segment  uint64 `json:"segment"`
+	Position int64  `json:"position"`
+}
+
+// cursor reads the records of the topic for a consumer group.
+//
+// The subscription delivering the record holds the turn until it's acked,
+// so the records are delivered one by one, in the order of publishing.
+type cursor struct {
+	key        string
+	topicDir   string
+	offsetFile string // empty if the offset is not persisted
+	fsync      bool
+	refs       int
+
+	// turn is a lock, which can be waited for with select
+	turn chan struct{}
+
+	// committed is the offset of the first not acked record
+	committed offset
+
+	file    *os.File
+	reader  *bufio.Reader
+	partial []byte
+
+	// unacked is the record read, but not acked yet
+	unacked []byte
+}
+
+func acquireCursor(dir string, topic string, consumerGroup string, fsync bool) (*cursor, error) {
+	topicDir := topicDir(dir, topic)
+
+	if consumerGroup == "" {
+		return newCursor(topicDir, "", false)
+	}
+
+	key := topicDir + "\x00" + consumerGroup
+
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	if c, ok := shared.cursors[key]; ok {
+		c.refs++
+		return c, nil
+	}
+
+	offsetFile := filepath.Join(topicDir, offsetsDir, url.PathEscape(consumerGroup)+".json")
+
+	c, err := newCursor(topicDir, offsetFile, fsync)
+	if err != nil {
+		return nil, err
+	}
+
+	c.key = key
+	c.refs = 1
+	shared.cursors[key] = c
+
+	return c, nil
+}
+
+func newCursor(topicDir string, offsetFile string, fsync bool) (*cursor, error) {
+	c := &cursor{
+		topicDir:   topicDir,
+		offsetFile: offsetFile,
+		fsync:      fsync,
+		turn:       make(chan struct{}, 1),
+	}
+
+	if offsetFile != "" {
+		data, err := os.ReadFile(offsetFile)
+		if err == nil {
+			if err := json.Unmarshal(data, &c.committed); err != nil {
+				return nil, errors.Wrapf(err, "invalid offset file %s", offsetFile)
+			}
+			return c, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, errors.Wrap(err, "cannot read offset file")
+		}
+	}
+
+	// a new consumer group starts with the oldest segment
+	segments, err := listSegments(topicDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		c.committed.Segment = segments[0]
+	}
+
+	return c, nil
+}
+
+// read returns the first not acked record. ok is false if there is no record to consume yet.
+func (c *cursor) read() (record []byte, ok bool, err error) {
+	if c.unacked != nil {
+		return c.unacked, true, nil
+	}
+
+	for {
+		if c.file == nil {
+			opened, err := c.openSegment()
+			if err != nil || !opened {
+				return nil, false, err
+			}
+		}
+
+		// the next segment is created after all records were written to the current one,
+		// so it must be checked before reading to not miss the last records
+		nextExists, err := segmentExists(c.topicDir, c.committed.Segment+1)
+		if err != nil {
+			return nil, false, err
+		}
+
+		line, err := c.reader.ReadBytes('\n')
+		c.partial = append(c.partial, line...)
+
+		if err == io.EOF {
+			if len(c.partial) > 0 || !nextExists {
+				return nil, false, nil
+			}
+
+			c.closeSegment()
+			c.committed = offset{Segment: c.committed.Segment + 1}
+			continue
+		}
+		if err != nil {
+			return nil, false, errors.Wrap(err, "cannot read segment file")
+		}
+
+		c.unacked = c.partial
+		c.partial = nil
+
+		return c.unacked, true, nil
+	}
+}
+
+func (c *cursor) openSegment() (bool, error) {
+	f, err := os.Open(segmentFile(c.topicDir, c.committed.Segment))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot open segment file")
+	}
+
+	if _, err := f.Seek(c.committed.Position, io.SeekStart); err != nil {
+		_ = f.Close()
+		return false, errors.Wrap(err, "cannot seek segment file")
+	}
+
+	c.file = f
+	c.reader = bufio.NewReader(f)
+	c.partial = nil
+
+	return true, nil
+}
+
+func (c *cursor) closeSegment() {
+	if c.file != nil {
+		_ = c.file.Close()
+	}
+	c.file = nil
+	c.reader = nil
+	c.partial = nil
+}
+
+// ack commits the offset after the unacked record.
+func (c *cursor) ack() error {
+	c.committed.Position += int64(len(c.unacked))
+	c.unacked = nil
+
+	if c.offsetFile == "" {
+		return nil
+	}
+
+	return c.saveOffset()
+}
+
+// saveOffset replaces the offset file atomically, so it's not corrupted by a crash.
+func (c *cursor) saveOffset() error {
+	data, err := json.Marshal(c.committed)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal offset")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.offsetFile), 0o755); err != nil {
+		return errors.Wrap(err, "cannot create offsets directory")
+	}
+
+	tmpFile := c.offsetFile + ".tmp"
+
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot create offset file")
+	}
+
+	_, err = f.Write(data)
+	if err == nil && c.fsync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot write offset file")
+	}
+
+	if err := os.Rename(tmpFile, c.offsetFile); err != nil {
+		return errors.Wrap(err, "cannot replace offset file")
+	}
+
+	return nil
+}
+
+func releaseCursor(c *cursor) {
+	if c.key != "" {
+		shared.lock.Lock()
+		defer shared.lock.Unlock()
+
+		c.refs--
+		if c.refs > 0 {
+			return
+		}
+
+		delete(shared.cursors, c.key)
+	}
+
+	c.turn <- struct{}{}
+	defer func() { <-c.turn }()
+
+	c.closeSegment()
+}
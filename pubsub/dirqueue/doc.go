@@ -0,0 +1,12 @@
+// Package dirqueue is a Pub/Sub keeping messages in a local directory.
+//
+// Every topic is a directory of append-only segment files. Messages are stored as JSON lines,
+// and consumed by consumer groups, which track their offsets in the topic's directory as well.
+// It gives durability to small single-node apps and integration tests, without running a broker.
+//
+// The directory must be used by a single process. Publishers and subscribers of the same directory
+// in the same process share the topic files and consumer groups.
+//
+// All Pub/Sub implementations can be found at https://watermill.io/pubsubs/
+
+package dirqueue
@@ -0,0 +1,329 @@
+package dirqueue
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Publisher publishes messages to the topics in a local directory.
+type Publisher struct {
+	config PublisherConfig
+	logger watermill.LoggerAdapter
+
+	writers     map[string]*topicWriter
+	writersLock sync.Mutex
+
+	closed  bool
+	closing chan struct{}
+	syncWg  sync.WaitGroup
+}
+
+// NewPublisher creates a new Publisher.
+func NewPublisher(config PublisherConfig, logger watermill.LoggerAdapter) (*Publisher, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	dir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Dir")
+	}
+	config.Dir = dir
+
+	p := &Publisher{
+		config:  config,
+		logger:  logger,
+		writers: map[string]*topicWriter{},
+		closing: make(chan struct{}),
+	}
+
+	if config.Fsync == FsyncInterval {
+		p.syncWg.Add(1)
+		go p.syncPeriodically()
+	}
+
+	return p, nil
+}
+
+// Publish appends the messages to the topic's segment, and syncs it according to PublisherConfig.Fsync.
+// The messages are written at once, so they are not split between segments.
+func (p *Publisher) Publish(topic string, messages ...*message.Message) error {
+	w, err := p.writer(topic)
+	if err != nil {
+		return err
+	}
+
+	data, err := marshalRecords(messages, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := w.append(data, p.config.SegmentMaxBytes, p.config.Fsync == FsyncAlways); err != nil {
+		return errors.Wrapf(err, "cannot publish messages to topic %s", topic)
+	}
+
+	p.logger.Trace("Messages published", watermill.LogFields{
+		"topic":          topic,
+		"messages_count": len(messages),
+	})
+
+	return nil
+}
+
+func (p *Publisher) writer(topic string) (*topicWriter, error) {
+	p.writersLock.Lock()
+	defer p.writersLock.Unlock()
+
+	if p.closed {
+		return nil, errors.New("publisher is closed")
+	}
+
+	if w, ok := p.writers[topic]; ok {
+		return w, nil
+	}
+
+	w, err := acquireTopicWriter(p.config.Dir, topic)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open topic %s", topic)
+	}
+	p.writers[topic] = w
+
+	return w, nil
+}
+
+func (p *Publisher) syncPeriodically() {
+	defer p.syncWg.Done()
+
+	ticker := time.NewTicker(p.config.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.closing:
+			return
+		}
+
+		p.writersLock.Lock()
+		for topic, w := range p.writers {
+			if err := w.sync(); err != nil {
+				p.logger.Error("Cannot sync topic", err, watermill.LogFields{"topic": topic})
+			}
+		}
+		p.writersLock.Unlock()
+	}
+}
+
+// Close syncs and closes the topics' segments.
+func (p *Publisher) Close() error {
+	p.writersLock.Lock()
+	if p.closed {
+		p.writersLock.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.closing)
+	p.writersLock.Unlock()
+
+	p.syncWg.Wait()
+
+	var err error
+	for topic, w := range p.writers {
+		if releaseErr := releaseTopicWriter(w); releaseErr != nil {
+			err = multierror.Append(err, errors.Wrapf(releaseErr, "cannot close topic %s", topic))
+		}
+	}
+
+	return err
+}
+
+// Subscriber consumes messages from the topics in a local directory.
+type Subscriber struct {
+	config SubscriberConfig
+	logger watermill.LoggerAdapter
+
+	subscribeWg sync.WaitGroup
+
+	closed     bool
+	closedLock sync.Mutex
+	closing    chan struct{}
+}
+
+// NewSubscriber creates a new Subscriber.
+func NewSubscriber(config SubscriberConfig, logger watermill.LoggerAdapter) (*Subscriber, error) {
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	dir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid Dir")
+	}
+	config.Dir = dir
+
+	return &Subscriber{
+		config:  config,
+		logger:  logger,
+		closing: make(chan struct{}),
+	}, nil
+}
+
+// Subscribe returns the channel with messages of the topic.
+//
+// Messages are delivered one by one, in the order of publishing: the next message is delivered
+// when the previous one is acked. A nacked message is redelivered.
+func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	s.closedLock.Lock()
+	defer s.closedLock.Unlock()
+
+	if s.closed {
+		return nil, errors.New("subscriber is closed")
+	}
+
+	c, err := acquireCursor(s.config.Dir, topic, s.config.ConsumerGroup, s.config.FsyncOffsets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open topic %s", topic)
+	}
+
+	logger := s.logger.With(watermill.LogFields{
+		"topic":          topic,
+		"consumer_group": s.config.ConsumerGroup,
+	})
+
+	output := make(chan *message.Message)
+
+	s.subscribeWg.Add(1)
+	go func() {
+		defer s.subscribeWg.Done()
+		defer releaseCursor(c)
+		defer close(output)
+
+		s.consume(ctx, c, output, logger)
+	}()
+
+	return output, nil
+}
+
+func (s *Subscriber) consume(ctx context.Context, c *cursor, output chan<- *message.Message, logger watermill.LoggerAdapter) {
+	for {
+		if !s.consumeRecord(ctx, c, output, logger) {
+			return
+		}
+	}
+}
+
+// consumeRecord delivers the next record of the cursor, and returns false if the subscription is closed.
+func (s *Subscriber) consumeRecord(ctx context.Context, c *cursor, output chan<- *message.Message, logger watermill.LoggerAdapter) bool {
+	select {
+	case c.turn <- struct{}{}:
+		defer func() { <-c.turn }()
+	case <-s.closing:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+
+	for {
+		select {
+		case <-s.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
+		record, ok, err := c.read()
+		if err != nil {
+			logger.Error("Cannot read message", err, nil)
+		}
+		if ok {
+			return s.deliver(ctx, c, record, output, logger)
+		}
+
+		select {
+		case <-time.After(s.config.PollInterval):
+		case <-s.closing:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (s *Subscriber) deliver(ctx context.Context, c *cursor, record []byte, output chan<- *message.Message, logger watermill.LoggerAdapter) bool {
+	msg, err := unmarshalRecord(record)
+	if err != nil {
+		logger.Error("Skipping invalid message", err, nil)
+		return s.ack(c, logger)
+	}
+
+	logFields := watermill.LogFields{"message_uuid": msg.UUID}
+
+	msgCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	msg.SetContext(msgCtx)
+
+	select {
+	case output <- msg:
+		logger.Trace("Message sent to subscriber", logFields)
+	case <-s.closing:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+
+	select {
+	case <-msg.Acked():
+		logger.Trace("Message acked", logFields)
+		return s.ack(c, logger)
+	case <-msg.Nacked():
+		logger.Trace("Message nacked", logFields)
+		return true
+	case <-s.closing:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Subscriber) ack(c *cursor, logger watermill.LoggerAdapter) bool {
+	if err := c.ack(); err != nil {
+		logger.Error("Cannot commit offset", err, nil)
+	}
+
+	return true
+}
+
+// Close closes all subscriptions, and waits until they are closed.
+// Messages which were not acked are redelivered to the next subscription of the consumer group.
+func (s *Subscriber) Close() error {
+	s.closedLock.Lock()
+	defer s.closedLock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	close(s.closing)
+
+	s.subscribeWg.Wait()
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package dirqueue_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/dirqueue"
+	"github.com/ThreeDotsLabs/watermill/pubsub/tests"
+)
+
+func newPubSub(t *testing.T, dir string, consumerGroup string) (*dirqueue.Publisher, *dirqueue.Subscriber) {
+	t.Helper()
+
+	logger := watermill.NewStdLogger(true, false)
+
+	publisher, err := dirqueue.NewPublisher(dirqueue.PublisherConfig{
+		Dir:   dir,
+		Fsync: dirqueue.FsyncNever,
+	}, logger)
+	require.NoError(t, err)
+
+	sub, err := dirqueue.NewSubscriber(dirqueue.SubscriberConfig{
+		Dir:           dir,
+		ConsumerGroup: consumerGroup,
+		PollInterval:  10 * time.Millisecond,
+	}, logger)
+	require.NoError(t, err)
+
+	return publisher, sub
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	dir := t.TempDir()
+
+	tests.TestPubSub(
+		t,
+		tests.Features{
+			ConsumerGroups:                   true,
+			ExactlyOnceDelivery:              false,
+			GuaranteedOrder:                  true,
+			Persistent:                       true,
+			NewSubscriberReceivesOldMessages: true,
+		},
+		func(t *testing.T) (message.Publisher, message.Subscriber) {
+			return newPubSub(t, dir, "")
+		},
+		func(t *testing.T, consumerGroup string) (message.Publisher, message.Subscriber) {
+			return newPubSub(t, dir, consumerGroup)
+		},
+	)
+}
+
+func TestSubscriber_continues_after_restart(t *testing.T) {
+	dir := t.TempDir()
+	topic := "topic"
+
+	publisher, sub := newPubSub(t, dir, "group")
+	published := tests.PublishSimpleMessages(t, 10, publisher, topic)
+	require.NoError(t, publisher.Close())
+
+	messages, err := sub.Subscribe(context.Background(), topic)
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(messages, 4, time.Second)
+	require.True(t, all)
+	require.NoError(t, sub.Close())
+
+	tests.AssertAllMessagesReceived(t, published[:4], received)
+
+	_, sub = newPubSub(t, dir, "group")
+	defer sub.Close()
+
+	messages, err = sub.Subscribe(context.Background(), topic)
+	require.NoError(t, err)
+
+	received, all = subscriber.BulkRead(messages, 6, time.Second)
+	require.True(t, all)
+
+	tests.AssertAllMessagesReceived(t, published[4:], received)
+}
+
+func TestSubscriber_without_consumer_group(t *testing.T) {
+	dir := t.TempDir()
+	topic := "topic"
+
+	publisher, sub := newPubSub(t, dir, "")
+	defer sub.Close()
+
+	published := tests.PublishSimpleMessages(t, 5, publisher, topic)
+	require.NoError(t, publisher.Close())
+
+	for i := 0; i < 2; i++ {
+		messages, err := sub.Subscribe(context.Background(), topic)
+		require.NoError(t, err)
+
+		received, all := subscriber.BulkRead(messages, 5, time.Second)
+		require.True(t, all)
+
+		tests.AssertAllMessagesReceived(t, published, received)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, topic, "offsets"))
+	assert.ErrorIs(t, err, os.ErrNotExist, "offsets should not be persisted without a consumer group")
+}
+
+func TestPublisher_segments(t *testing.T) {
+	dir := t.TempDir()
+	topic := "topic"
+
+	publisher, err := dirqueue.NewPublisher(dirqueue.PublisherConfig{
+		Dir:             dir,
+		SegmentMaxBytes: 200,
+	}, nil)
+	require.NoError(t, err)
+
+	sub, err := dirqueue.NewSubscriber(dirqueue.SubscriberConfig{
+		Dir:           dir,
+		ConsumerGroup: "group",
+		PollInterval:  10 * time.Millisecond,
+	}, nil)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	messages, err := sub.Subscribe(context.Background(), topic)
+	require.NoError(t, err)
+
+	published := tests.PublishSimpleMessages(t, 20, publisher, topic)
+
+	received, all := subscriber.BulkRead(messages, 20, time.Second)
+	require.True(t, all)
+	tests.AssertAllMessagesReceived(t, published, received)
+	require.NoError(t, publisher.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, topic, "*.log"))
+	require.NoError(t, err)
+	assert.Greater(t, len(segments), 1)
+
+	for _, segment := range segments[:len(segments)-1] {
+		info, err := os.Stat(segment)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(200))
+	}
+}
+
+func TestPublisher_truncates_partial_record(t *testing.T) {
+	dir := t.TempDir()
+	topic := "topic"
+
+	publisher, sub := newPubSub(t, dir, "group")
+	defer sub.Close()
+
+	published := tests.PublishSimpleMessages(t, 2, publisher, topic)
+	require.NoError(t, publisher.Close())
+
+	// simulate a crash during writing
+	segment := filepath.Join(dir, topic, "00000000000000000000.log")
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"uuid":"partial`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	publisher, _ = newPubSub(t, dir, "group")
+	published = append(published, tests.PublishSimpleMessages(t, 1, publisher, topic)...)
+	require.NoError(t, publisher.Close())
+
+	messages, err := sub.Subscribe(context.Background(), topic)
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(messages, 3, time.Second)
+	require.True(t, all)
+	tests.AssertAllMessagesReceived(t, published, received)
+}
+
+func TestNewPublisher_invalid_config(t *testing.T) {
+	testCases := []dirqueue.PublisherConfig{
+		{},
+		{Dir: "dir", SegmentMaxBytes: -1},
+		{Dir: "dir", Fsync: "sometimes"},
+		{Dir: "dir", Fsync: dirqueue.FsyncInterval, FsyncInterval: -1},
+	}
+
+	for _, config := range testCases {
+		_, err := dirqueue.NewPublisher(config, nil)
+		assert.ErrorContains(t, err, "invalid config")
+	}
+
+	_, err := dirqueue.NewSubscriber(dirqueue.SubscriberConfig{}, nil)
+	assert.ErrorContains(t, err, "invalid config")
+}
@@ -0,0 +1,285 @@
+package dirqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/internal/lines"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+const segmentExt = ".log"
+
+type record struct {
+	UUID        string            `json:"uuid"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Payload     []byte            `json:"payload"`
+	PublishedAt time.Time         `json:"published_at"`
+}
+
+func marshalRecords(messages []*message.Message, publishedAt time.Time) ([]byte, error) {
+	var data []byte
+
+	for _, msg := range messages {
+		b, err := json.Marshal(record{
+			UUID:        msg.UUID,
+			Metadata:    msg.Metadata,
+			Payload:     msg.Payload,
+			PublishedAt: publishedAt,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot marshal message %s", msg.UUID)
+		}
+
+		data = append(data, b...)
+		data = append(data, '\n')
+	}
+
+	return data, nil
+}
+
+func unmarshalRecord(data []byte) (*message.Message, error) {
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal message")
+	}
+
+	msg := message.NewMessage(r.UUID, r.Payload)
+	for k, v := range r.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+
+	return msg, nil
+}
+
+func topicDir(dir string, topic string) string {
+	return filepath.Join(dir, url.PathEscape(topic))
+}
+
+func segmentFile(topicDir string, segment uint64) string {
+	return filepath.Join(topicDir, fmt.Sprintf("%020d%s", segment, segmentExt))
+}
+
+func segmentExists(topicDir string, segment uint64) (bool, error) {
+	_, err := os.Stat(segmentFile(topicDir, segment))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "cannot check segment file")
+	}
+
+	return true, nil
+}
+
+// listSegments returns the sequence numbers of the topic's segments, in ascending order.
+func listSegments(topicDir string) ([]uint64, error) {
+	files, err := filepath.Glob(filepath.Join(topicDir, "*"+segmentExt))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list segment files")
+	}
+
+	var segments []uint64
+	for _, file := range files {
+		segment, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(file), segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+
+	return segments, nil
+}
+
+// shared keeps the topic writers and consumer groups shared by publishers and subscribers
+// of the same directory in the process.
+var shared = struct {
+	lock    sync.Mutex
+	writers map[string]*topicWriter
+	cursors map[string]*cursor
+}{
+	writers: map[string]*topicWriter{},
+	cursors: map[string]*cursor{},
+}
+
+// topicWriter appends records to the last segment of the topic.
+type topicWriter struct {
+	topicDir string
+	refs     int
+
+	lock    sync.Mutex
+	file    *os.File
+	segment uint64
+	size    int64
+	dirty   bool
+}
+
+func acquireTopicWriter(dir string, topic string) (*topicWriter, error) {
+	topicDir := topicDir(dir, topic)
+
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	if w, ok := shared.writers[topicDir]; ok {
+		w.refs++
+		return w, nil
+	}
+
+	w, err := openTopicWriter(topicDir)
+	if err != nil {
+		return nil, err
+	}
+
+	w.refs = 1
+	shared.writers[topicDir] = w
+
+	return w, nil
+}
+
+func openTopicWriter(topicDir string) (*topicWriter, error) {
+	if err := os.MkdirAll(topicDir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "cannot create topic directory")
+	}
+
+	segments, err := listSegments(topicDir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &topicWriter{topicDir: topicDir}
+	if len(segments) > 0 {
+		w.segment = segments[len(segments)-1]
+	}
+
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// openSegment opens the current segment for appending.
+// A partially written record, left by a crash, is truncated.
+func (w *topicWriter) openSegment() error {
+	f, err := os.OpenFile(segmentFile(w.topicDir, w.segment), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "cannot open segment file")
+	}
+
+	size, err := lines.TruncatePartial(f)
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "cannot repair segment file")
+	}
+
+	w.file = f
+	w.size = size
+
+	return nil
+}
+
+// append writes the records to the current segment, opening a new one if maxBytes is exceeded.
+func (w *topicWriter) append(data []byte, maxBytes int64, fsync bool) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		return errors.New("topic writer is closed")
+	}
+
+	if w.size > 0 && w.size+int64(len(data)) > maxBytes {
+		if err := w.roll(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	if err != nil {
+		// the partially written records are removed, so the following records are not appended to them
+		if n > 0 {
+			if truncateErr := w.file.Truncate(w.size); truncateErr != nil {
+				return errors.Wrapf(err, "cannot write to segment file (cannot truncate partial write: %s)", truncateErr)
+			}
+		}
+		return errors.Wrap(err, "cannot write to segment file")
+	}
+	w.size += int64(n)
+
+	if fsync {
+		return w.syncLocked()
+	}
+
+	w.dirty = true
+	return nil
+}
+
+// roll closes the current segment and opens the next one.
+func (w *topicWriter) roll() error {
+	if err := w.file.Sync(); err != nil {
+		return errors.Wrap(err, "cannot sync segment file")
+	}
+	if err := w.file.Close(); err != nil {
+		return errors.Wrap(err, "cannot close segment file")
+	}
+
+	w.file = nil
+	w.segment++
+	w.dirty = false
+
+	return w.openSegment()
+}
+
+func (w *topicWriter) sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil || !w.dirty {
+		return nil
+	}
+
+	return w.syncLocked()
+}
+
+func (w *topicWriter) syncLocked() error {
+	if err := w.file.Sync(); err != nil {
+		return errors.Wrap(err, "cannot sync segment file")
+	}
+
+	w.dirty = false
+	return nil
+}
+
+func releaseTopicWriter(w *topicWriter) error {
+	shared.lock.Lock()
+	defer shared.lock.Unlock()
+
+	w.refs--
+	if w.refs > 0 {
+		return nil
+	}
+
+	delete(shared.writers, w.topicDir)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	err := w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+
+	return err
+}
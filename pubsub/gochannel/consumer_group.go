@@ -0,0 +1,70 @@
+package gochannel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// ConsumerGroupSubscriber returns a subscriber which subscribes to the GoChannel as a member of the consumer group.
+// See SubscribeWithConsumerGroup for details.
+//
+// It's useful when a subscriber is passed to a component expecting a message.Subscriber, for example, to message.Router.
+// Closing the returned subscriber closes only its subscriptions, GoChannel is not closed.
+func (g *GoChannel) ConsumerGroupSubscriber(consumerGroup string) message.Subscriber {
+	return &consumerGroupSubscriber{
+		pubSub:        g,
+		consumerGroup: consumerGroup,
+		closing:       make(chan struct{}),
+	}
+}
+
+type consumerGroupSubscriber struct {
+	pubSub        *GoChannel
+	consumerGroup string
+
+	subscriptionsWg sync.WaitGroup
+	closing         chan struct{}
+	closeOnce       sync.Once
+}
+
+func (s *consumerGroupSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	select {
+	case <-s.closing:
+		return nil, errors.New("subscriber closed")
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	messages, err := s.pubSub.SubscribeWithConsumerGroup(ctx, topic, s.consumerGroup)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s.subscriptionsWg.Add(1)
+	go func() {
+		defer s.subscriptionsWg.Done()
+
+		select {
+		case <-s.closing:
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	return messages, nil
+}
+
+func (s *consumerGroupSubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.subscriptionsWg.Wait()
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package gochannel
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// deliveryQueue delivers messages of a topic one by one, when DeterministicDelivery is enabled.
+type deliveryQueue struct {
+	lock    sync.Mutex
+	pending []func()
+	signal  chan struct{}
+}
+
+func newDeliveryQueue() *deliveryQueue {
+	return &deliveryQueue{signal: make(chan struct{}, 1)}
+}
+
+// push never blocks, so the handler can publish to the topic of the message being delivered.
+func (q *deliveryQueue) push(delivery func()) {
+	q.lock.Lock()
+	q.pending = append(q.pending, delivery)
+	q.lock.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (q *deliveryQueue) pop() (func(), bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if len(q.pending) == 0 {
+		return nil, false
+	}
+
+	delivery := q.pending[0]
+	q.pending[0] = nil
+	q.pending = q.pending[1:]
+
+	return delivery, true
+}
+
+func (q *deliveryQueue) run(closing chan struct{}) {
+	for {
+		for {
+			delivery, ok := q.pop()
+			if !ok {
+				break
+			}
+			delivery()
+		}
+
+		select {
+		case <-q.signal:
+		case <-closing:
+			return
+		}
+	}
+}
+
+func (g *GoChannel) topicDeliveryQueue(topic string) *deliveryQueue {
+	queue, loaded := g.deliveryQueues.LoadOrStore(topic, newDeliveryQueue())
+	if !loaded {
+		go queue.(*deliveryQueue).run(g.closing)
+	}
+
+	return queue.(*deliveryQueue)
+}
+
+// sendMessageDeterministically queues the message to be sent to the subscribers one by one,
+// in the order of deterministicOrder, waiting for the ack of every subscriber before sending it to the next one.
+func (g *GoChannel) sendMessageDeterministically(
+	topic string,
+	msg *message.Message,
+	subscribers []*subscriber,
+	logFields watermill.LogFields,
+	acked chan struct{},
+) {
+	subscribers = g.deterministicOrder(subscribers)
+
+	g.topicDeliveryQueue(topic).push(func() {
+		defer close(acked)
+
+		for _, s := range subscribers {
+			s.sendMessageToSubscriber(topic, msg, logFields)
+		}
+	})
+}
+
+// deterministicOrder sorts subscribers in the order of subscribing, and shuffles them with DeliverySeed (if set).
+func (g *GoChannel) deterministicOrder(subscribers []*subscriber) []*subscriber {
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].seq < subscribers[j].seq
+	})
+
+	if g.config.DeliverySeed == 0 {
+		return subscribers
+	}
+
+	g.deliveryRandLock.Lock()
+	defer g.deliveryRandLock.Unlock()
+
+	if g.deliveryRand == nil {
+		g.deliveryRand = rand.New(rand.NewSource(g.config.DeliverySeed))
+	}
+	g.deliveryRand.Shuffle(len(subscribers), func(i, j int) {
+		subscribers[i], subscribers[j] = subscribers[j], subscribers[i]
+	})
+
+	return subscribers
+}
+
+// sendPersistedMessagesDeterministically queues sending the persisted messages to the new subscriber,
+// so they are sent in order, before messages published later.
+func (g *GoChannel) sendPersistedMessagesDeterministically(s *subscriber, topic string, messages []PersistedMessage) {
+	g.topicDeliveryQueue(topic).push(func() {
+		for i := range messages {
+			msg := messages[i].Message
+			s.sendMessageToSubscriber(topic, msg, watermill.LogFields{"message_uuid": msg.UUID, "topic": topic})
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package gochannel_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+type deliveryLog struct {
+	lock       sync.Mutex
+	deliveries []string
+}
+
+func (l *deliveryLog) consume(name string, messages <-chan *message.Message) {
+	for msg := range messages {
+		l.lock.Lock()
+		l.deliveries = append(l.deliveries, name+":"+msg.UUID)
+		l.lock.Unlock()
+
+		msg.Ack()
+	}
+}
+
+func (l *deliveryLog) get() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return append([]string{}, l.deliveries...)
+}
+
+func deterministicDeliveries(t *testing.T, seed int64, subscribers []string, messagesCount int) []string {
+	t.Helper()
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		DeterministicDelivery: true,
+		DeliverySeed:          seed,
+	}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	log := &deliveryLog{}
+
+	for _, name := range subscribers {
+		messages, err := pubSub.Subscribe(context.Background(), "topic")
+		require.NoError(t, err)
+		go log.consume(name, messages)
+	}
+
+	for i := 0; i < messagesCount; i++ {
+		require.NoError(t, pubSub.Publish("topic", message.NewMessage(fmt.Sprintf("%d", i), nil)))
+	}
+
+	require.Eventually(t, func() bool {
+		return len(log.get()) == len(subscribers)*messagesCount
+	}, time.Second, time.Millisecond)
+
+	return log.get()
+}
+
+func TestDeterministicDelivery(t *testing.T) {
+	deliveries := deterministicDeliveries(t, 0, []string{"a", "b", "c"}, 3)
+
+	assert.Equal(t, []string{
+		"a:0", "b:0", "c:0",
+		"a:1", "b:1", "c:1",
+		"a:2", "b:2", "c:2",
+	}, deliveries)
+}
+
+func TestDeterministicDelivery_seed(t *testing.T) {
+	subscribers := []string{"a", "b", "c", "d"}
+
+	first := deterministicDeliveries(t, 42, subscribers, 10)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, deterministicDeliveries(t, 42, subscribers, 10), "the same seed should give the same order")
+	}
+
+	assert.NotEqual(t, deterministicDeliveries(t, 0, subscribers, 10), first, "subscribers should be shuffled")
+}
+
+func TestDeterministicDelivery_persistent(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		Persistent:            true,
+		DeterministicDelivery: true,
+	}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pubSub.Publish("topic", message.NewMessage(fmt.Sprintf("%d", i), nil)))
+	}
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("5", nil)))
+
+	received, all := subscriber.BulkRead(messages, 6, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, received.IDs())
+}
+
+func TestDeterministicDelivery_block_until_ack(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		DeterministicDelivery:          true,
+		BlockPublishUntilSubscriberAck: true,
+	}, watermill.NopLogger{})
+	defer func() {
+		assert.NoError(t, pubSub.Close())
+	}()
+
+	log := &deliveryLog{}
+	for _, name := range []string{"a", "b"} {
+		messages, err := pubSub.Subscribe(context.Background(), "topic")
+		require.NoError(t, err)
+		go log.consume(name, messages)
+	}
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	assert.Equal(t, []string{"a:1", "b:1"}, log.get(), "Publish should return after all subscribers acked")
+}
@@ -0,0 +1,41 @@
+package gochannel
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// DeliveryParams are the parameters of Config.BeforeDeliver and Config.AfterDeliver.
+type DeliveryParams struct {
+	// Topic is the topic to which the message was published.
+	// It may be different from the subscribed topic if EnableTopicWildcards is used.
+	Topic string
+
+	// Message is the copy of the message sent to the subscriber.
+	Message *message.Message
+
+	// ConsumerGroup is the consumer group of the subscriber, empty if it's not subscribed with a consumer group.
+	ConsumerGroup string
+
+	// Attempt is the number of the delivery of the message to the subscriber, starting from 1.
+	// It's increased when the message is redelivered after nack.
+	Attempt int
+}
+
+// TopicDeliveryLatency returns a Config.BeforeDeliver hook which delays the delivery of messages
+// of the topics by the given latency. Messages of other topics are delivered without delay.
+func TopicDeliveryLatency(latencies map[string]time.Duration) func(ctx context.Context, params DeliveryParams) {
+	return func(ctx context.Context, params DeliveryParams) {
+		latency, ok := latencies[params.Topic]
+		if !ok || latency <= 0 {
+			return
+		}
+
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+		}
+	}
+}
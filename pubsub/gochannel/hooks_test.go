@@ -0,0 +1,163 @@
+package gochannel_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestGoChannel_BeforeDeliver_blocks_delivery(t *testing.T) {
+	release := make(chan struct{})
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		BeforeDeliver: func(ctx context.Context, params gochannel.DeliveryParams) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+		},
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	}()
+
+	select {
+	case <-messages:
+		t.Fatal("message should not be delivered before release")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	close(release)
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "1", msg.UUID)
+		msg.Ack()
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+}
+
+func TestGoChannel_BeforeDeliver_close_unblocks(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		BeforeDeliver: func(ctx context.Context, params gochannel.DeliveryParams) {
+			<-ctx.Done()
+		},
+	}, watermill.NopLogger{})
+
+	_, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		_ = pubSub.Publish("topic", message.NewMessage("1", nil))
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	closed := make(chan struct{})
+	go func() {
+		assert.NoError(t, pubSub.Close())
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close should not be blocked by BeforeDeliver")
+	}
+}
+
+func TestGoChannel_AfterDeliver(t *testing.T) {
+	var deliveries []gochannel.DeliveryParams
+	lock := sync.Mutex{}
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		AfterDeliver: func(params gochannel.DeliveryParams) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			deliveries = append(deliveries, params)
+		},
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+	}()
+
+	msg := <-messages
+	msg.Nack()
+
+	redelivered := <-messages
+	redelivered.Ack()
+
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return len(deliveries) == 2
+	}, time.Second, time.Millisecond*10)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	assert.Equal(t, "topic", deliveries[0].Topic)
+	assert.Same(t, msg, deliveries[0].Message)
+	assert.Equal(t, 1, deliveries[0].Attempt)
+
+	assert.Same(t, redelivered, deliveries[1].Message)
+	assert.Equal(t, 2, deliveries[1].Attempt)
+}
+
+func TestTopicDeliveryLatency(t *testing.T) {
+	latency := time.Millisecond * 200
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		BeforeDeliver: gochannel.TopicDeliveryLatency(map[string]time.Duration{
+			"slow": latency,
+		}),
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	slow, err := pubSub.Subscribe(context.Background(), "slow")
+	require.NoError(t, err)
+	fast, err := pubSub.Subscribe(context.Background(), "fast")
+	require.NoError(t, err)
+
+	start := time.Now()
+	go func() {
+		assert.NoError(t, pubSub.Publish("slow", message.NewMessage("slow", nil)))
+	}()
+	go func() {
+		assert.NoError(t, pubSub.Publish("fast", message.NewMessage("fast", nil)))
+	}()
+
+	msg := <-fast
+	msg.Ack()
+	assert.Less(t, time.Since(start), latency)
+
+	msg = <-slow
+	msg.Ack()
+	assert.GreaterOrEqual(t, time.Since(start), latency)
+}
@@ -2,7 +2,10 @@ package gochannel
 
 import (
 	"context"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/lithammer/shortuuid/v3"
 	"github.com/pkg/errors"
@@ -21,11 +24,63 @@ type Config struct {
 	//
 	// All messages are persisted to the memory (simple slice),
 	// so be aware that with large amount of messages you can go out of the memory.
+	// To limit it, use PersistedMessagesTTL or MaxPersistedMessagesPerTopic.
+	//
+	// To persist messages also on the disk, use NewPersistentGoChannel.
 	Persistent bool
 
+	// PersistedMessagesTTL is the time after which persisted messages are dropped
+	// and are not sent to new subscribers. Disabled if 0.
+	PersistedMessagesTTL time.Duration
+
+	// MaxPersistedMessagesPerTopic is the maximum number of persisted messages of a topic.
+	// When it's exceeded, the oldest messages are dropped. Disabled if 0.
+	MaxPersistedMessagesPerTopic int
+
+	// Clock is used to measure PersistedMessagesTTL.
+	// If not provided, watermill.RealClock is used.
+	Clock watermill.Clock
+
 	// When true, Publish will block until subscriber Ack's the message.
 	// If there are no subscribers, Publish will not block (also when Persistent is true).
 	BlockPublishUntilSubscriberAck bool
+
+	// EnableTopicWildcards allows subscribing with wildcard topic patterns.
+	//
+	// Topics are split into tokens separated by ".".
+	// The "*" token matches exactly one token and the ">" token, allowed only at the end of the pattern,
+	// matches one or more remaining tokens.
+	// For example, "orders.*" matches "orders.created", but not "orders" or "orders.created.v2",
+	// while "orders.>" matches both "orders.created" and "orders.created.v2".
+	//
+	// When disabled, "*" and ">" are treated as regular characters of the topic.
+	EnableTopicWildcards bool
+
+	// DeterministicDelivery makes the delivery of messages reproducible, which is useful in tests.
+	//
+	// Messages of a topic are delivered one by one, in the order of publishing.
+	// Every message is sent to the subscribers one by one, in the order of subscribing (or shuffled with DeliverySeed):
+	// the next subscriber receives the message when the previous one acked it. Persisted messages are sent
+	// to the new subscriber in the order of publishing as well.
+	//
+	// Keep in mind that a handler which publishes to the topic it's subscribed to, with BlockPublishUntilSubscriberAck,
+	// would wait for the ack of its own message forever.
+	DeterministicDelivery bool
+
+	// DeliverySeed if not 0 shuffles the order of subscribers for every message when DeterministicDelivery is enabled.
+	// The same seed gives the same order for the same sequence of published messages and subscriptions.
+	DeliverySeed int64
+
+	// BeforeDeliver if not nil is called before every delivery of a message to a subscriber,
+	// including redeliveries after nack. The message is delivered when it returns, so it can block the delivery
+	// until a condition is met, or inject latency (see TopicDeliveryLatency).
+	//
+	// ctx is canceled when the subscriber is closed, and it must be respected by blocking hooks.
+	BeforeDeliver func(ctx context.Context, params DeliveryParams)
+
+	// AfterDeliver if not nil is called after the message was received by the subscriber,
+	// before it's acked or nacked. It allows asserting on messages in flight in tests.
+	AfterDeliver func(params DeliveryParams)
 }
 
 // GoChannel is the simplest Pub/Sub implementation.
@@ -48,8 +103,18 @@ type GoChannel struct {
 	closedLock sync.Mutex
 	closing    chan struct{}
 
-	persistedMessages     map[string][]*message.Message
+	persistedMessages     map[string][]PersistedMessage
 	persistedMessagesLock sync.RWMutex
+	store                 MessageStore
+
+	consumerGroupsOffsets sync.Map // map of *uint64
+
+	subscribersSeq   uint64
+	deliveryQueues   sync.Map // map of *deliveryQueue
+	deliveryRand     *rand.Rand
+	deliveryRandLock sync.Mutex
+
+	topicsCounters sync.Map // map of *topicCounters
 }
 
 // NewGoChannel creates new GoChannel Pub/Sub.
@@ -60,6 +125,9 @@ func NewGoChannel(config Config, logger watermill.LoggerAdapter) *GoChannel {
 	if logger == nil {
 		logger = watermill.NopLogger{}
 	}
+	if config.Clock == nil {
+		config.Clock = watermill.RealClock{}
+	}
 
 	return &GoChannel{
 		config: config,
@@ -72,10 +140,41 @@ func NewGoChannel(config Config, logger watermill.LoggerAdapter) *GoChannel {
 
 		closing: make(chan struct{}),
 
-		persistedMessages: map[string][]*message.Message{},
+		persistedMessages: map[string][]PersistedMessage{},
 	}
 }
 
+// NewPersistentGoChannel creates new persistent GoChannel Pub/Sub, which persists messages in the store,
+// so they survive process restarts. Messages persisted before are loaded from the store
+// and are sent to new subscribers, like messages published with the Persistent option.
+//
+// PersistedMessagesTTL and MaxPersistedMessagesPerTopic are applied to the messages loaded from the store as well.
+// Persistent is always enabled.
+func NewPersistentGoChannel(config Config, store MessageStore, logger watermill.LoggerAdapter) (*GoChannel, error) {
+	if store == nil {
+		return nil, errors.New("missing store")
+	}
+
+	config.Persistent = true
+
+	persistedMessages, err := store.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load persisted messages")
+	}
+
+	g := NewGoChannel(config, logger)
+	g.store = store
+
+	for topic, messages := range persistedMessages {
+		g.persistedMessages[topic] = messages
+		if err := g.applyRetention(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
 // Publish in GoChannel is NOT blocking until all consumers consume.
 // Messages will be send in background.
 //
@@ -98,12 +197,9 @@ func (g *GoChannel) Publish(topic string, messages ...*message.Message) error {
 	defer subLock.(*sync.Mutex).Unlock()
 
 	if g.config.Persistent {
-		g.persistedMessagesLock.Lock()
-		if _, ok := g.persistedMessages[topic]; !ok {
-			g.persistedMessages[topic] = make([]*message.Message, 0)
+		if err := g.persistMessages(topic, messagesToPublish); err != nil {
+			return err
 		}
-		g.persistedMessages[topic] = append(g.persistedMessages[topic], messagesToPublish...)
-		g.persistedMessagesLock.Unlock()
 	}
 
 	for i := range messagesToPublish {
@@ -113,6 +209,7 @@ func (g *GoChannel) Publish(topic string, messages ...*message.Message) error {
 		if err != nil {
 			return err
 		}
+		atomic.AddUint64(&g.topicCounters(topic).published, 1)
 
 		if g.config.BlockPublishUntilSubscriberAck {
 			g.waitForAckFromSubscribers(msg, ackedBySubscribers)
@@ -122,6 +219,67 @@ func (g *GoChannel) Publish(topic string, messages ...*message.Message) error {
 	return nil
 }
 
+func (g *GoChannel) persistMessages(topic string, messages message.Messages) error {
+	g.persistedMessagesLock.Lock()
+	defer g.persistedMessagesLock.Unlock()
+
+	publishedAt := g.config.Clock.Now()
+
+	persistedMessages := make([]PersistedMessage, len(messages))
+	for i, msg := range messages {
+		persistedMessages[i] = PersistedMessage{Message: msg, PublishedAt: publishedAt}
+	}
+
+	if g.store != nil {
+		if err := g.store.Append(topic, persistedMessages...); err != nil {
+			return errors.Wrap(err, "cannot persist messages")
+		}
+	}
+
+	g.persistedMessages[topic] = append(g.persistedMessages[topic], persistedMessages...)
+
+	// messages are already persisted, so they are sent even if compaction fails
+	if err := g.applyRetention(topic); err != nil {
+		g.logger.Error("Cannot apply retention of persisted messages", err, watermill.LogFields{"topic": topic})
+	}
+
+	return nil
+}
+
+// applyRetention drops persisted messages of the topic which exceeded PersistedMessagesTTL or MaxPersistedMessagesPerTopic.
+// persistedMessagesLock must be held by the caller.
+func (g *GoChannel) applyRetention(topic string) error {
+	messages := g.persistedMessages[topic]
+	dropped := 0
+
+	if g.config.PersistedMessagesTTL > 0 {
+		expiredBefore := g.config.Clock.Now().Add(-g.config.PersistedMessagesTTL)
+		for dropped < len(messages) && messages[dropped].PublishedAt.Before(expiredBefore) {
+			dropped++
+		}
+	}
+
+	if maxMessages := g.config.MaxPersistedMessagesPerTopic; maxMessages > 0 && len(messages)-dropped > maxMessages {
+		dropped = len(messages) - maxMessages
+	}
+
+	if dropped == 0 {
+		return nil
+	}
+
+	retained := make([]PersistedMessage, len(messages)-dropped)
+	copy(retained, messages[dropped:])
+	g.persistedMessages[topic] = retained
+
+	if g.store != nil {
+		if err := g.store.Compact(topic, retained); err != nil {
+			return errors.Wrap(err, "cannot compact persisted messages")
+		}
+	}
+
+	return nil
+}
+
 func (g *GoChannel) waitForAckFromSubscribers(msg *message.Message, ackedByConsumer <-chan struct{}) {
 	logFields := watermill.LogFields{"message_uuid": msg.UUID}
 	g.logger.Debug("Waiting for subscribers ack", logFields)
@@ -146,6 +304,13 @@ func (g *GoChannel) sendMessage(topic string, message *message.Message) (<-chan
 		return ackedBySubscribers, nil
 	}
 
+	subscribers = g.pickConsumerGroupsSubscribers(topic, subscribers)
+
+	if g.config.DeterministicDelivery {
+		g.sendMessageDeterministically(topic, message, subscribers, logFields, ackedBySubscribers)
+		return ackedBySubscribers, nil
+	}
+
 	go func(subscribers []*subscriber) {
 		wg := &sync.WaitGroup{}
 
@@ -154,7 +319,7 @@ func (g *GoChannel) sendMessage(topic string, message *message.Message) (<-chan
 
 			wg.Add(1)
 			go func() {
-				subscriber.sendMessageToSubscriber(message, logFields)
+				subscriber.sendMessageToSubscriber(topic, message, logFields)
 				wg.Done()
 			}()
 		}
@@ -169,13 +334,60 @@ func (g *GoChannel) sendMessage(topic string, message *message.Message) (<-chan
 // Subscribe returns channel to which all published messages are sent.
 // Messages are not persisted. If there are no subscribers and message is produced it will be gone.
 //
-// There are no consumer groups support etc. Every consumer will receive every produced message.
+// Every consumer will receive every produced message.
+// Use SubscribeWithConsumerGroup or ConsumerGroupSubscriber to share messages between subscribers.
+//
+// If EnableTopicWildcards is set in the config, topic can be a wildcard pattern (for example "orders.*").
 func (g *GoChannel) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	messages, _, err := g.subscribe(ctx, topic, "", message.ReplayPosition{})
+	return messages, err
+}
+
+// SubscribeFrom implements message.SubscriberWithReplay.
+// It works like Subscribe, but only the persisted messages from the position are sent to the subscriber.
+//
+// Offset is the number of the oldest persisted messages of the topic to skip (of every topic matching the pattern,
+// if EnableTopicWildcards is used). Since skips the messages published before it.
+//
+// Persistent must be enabled.
+func (g *GoChannel) SubscribeFrom(ctx context.Context, topic string, position message.ReplayPosition) (<-chan *message.Message, message.ReplayInfo, error) {
+	if !g.config.Persistent {
+		return nil, message.ReplayInfo{}, errors.New("replay requires Persistent to be enabled")
+	}
+	if position.Offset < 0 {
+		return nil, message.ReplayInfo{}, errors.New("replay offset must not be negative")
+	}
+
+	return g.subscribe(ctx, topic, "", position)
+}
+
+// SubscribeWithConsumerGroup works like Subscribe, but the subscriber is a member of the consumer group.
+//
+// Subscribers with the same consumer group share the messages of the topic: each message is sent to one of them
+// (round-robin). Every consumer group and every subscriber without a consumer group receive their own copy
+// of the message. It's similar to consumer groups in Kafka or queue groups in NATS.
+//
+// When Persistent is enabled, previously produced messages are sent only to the first subscriber of the group.
+func (g *GoChannel) SubscribeWithConsumerGroup(ctx context.Context, topic string, consumerGroup string) (<-chan *message.Message, error) {
+	if consumerGroup == "" {
+		return nil, errors.New("consumer group cannot be empty")
+	}
+
+	messages, _, err := g.subscribe(ctx, topic, consumerGroup, message.ReplayPosition{})
+	return messages, err
+}
+
+func (g *GoChannel) subscribe(
+	ctx context.Context,
+	topic string,
+	consumerGroup string,
+	position message.ReplayPosition,
+) (<-chan *message.Message, message.ReplayInfo, error) {
 	g.closedLock.Lock()
 
 	if g.closed {
 		g.closedLock.Unlock()
-		return nil, errors.New("Pub/Sub closed")
+		return nil, message.ReplayInfo{}, errors.New("Pub/Sub closed")
 	}
 
 	g.subscribersWg.Add(1)
@@ -192,6 +404,11 @@ func (g *GoChannel) Subscribe(ctx context.Context, topic string) (<-chan *messag
 		outputChannel: make(chan *message.Message, g.config.OutputChannelBuffer),
 		logger:        g.logger,
 		closing:       make(chan struct{}),
+		consumerGroup: consumerGroup,
+		seq:           atomic.AddUint64(&g.subscribersSeq, 1),
+		beforeDeliver: g.config.BeforeDeliver,
+		afterDeliver:  g.config.AfterDeliver,
+		onDelivered:   g.countDelivered,
 	}
 
 	go func(s *subscriber, g *GoChannel) {
@@ -221,30 +438,81 @@ func (g *GoChannel) Subscribe(ctx context.Context, topic string) (<-chan *messag
 
 		g.addSubscriber(topic, s)
 
-		return s.outputChannel, nil
+		return s.outputChannel, message.ReplayInfo{}, nil
+	}
+
+	// persisted messages of the consumer group were already sent to its first subscriber
+	var replay []topicPersistedMessages
+	if consumerGroup == "" || !g.hasConsumerGroupSubscribers(topic, consumerGroup) {
+		replay = g.persistedMessagesToReplay(topic, position)
+	}
+
+	var replayInfo message.ReplayInfo
+	for _, topicMessages := range replay {
+		replayInfo.Messages += int64(len(topicMessages.messages))
 	}
 
 	go func(s *subscriber) {
 		defer g.subscribersLock.Unlock()
 		defer subLock.(*sync.Mutex).Unlock()
 
-		g.persistedMessagesLock.RLock()
-		messages, ok := g.persistedMessages[topic]
-		g.persistedMessagesLock.RUnlock()
+		for _, topicMessages := range replay {
+			persistedTopic, messages := topicMessages.topic, topicMessages.messages
+
+			if g.config.DeterministicDelivery {
+				g.sendPersistedMessagesDeterministically(s, persistedTopic, messages)
+				continue
+			}
 
-		if ok {
 			for i := range messages {
-				msg := g.persistedMessages[topic][i]
-				logFields := watermill.LogFields{"message_uuid": msg.UUID, "topic": topic}
+				msg := messages[i].Message
+				logFields := watermill.LogFields{"message_uuid": msg.UUID, "topic": persistedTopic}
 
-				go s.sendMessageToSubscriber(msg, logFields)
+				go s.sendMessageToSubscriber(persistedTopic, msg, logFields)
 			}
 		}
 
 		g.addSubscriber(topic, s)
 	}(s)
 
-	return s.outputChannel, nil
+	return s.outputChannel, replayInfo, nil
+}
+
+type topicPersistedMessages struct {
+	topic    string
+	messages []PersistedMessage
+}
+
+// persistedMessagesToReplay returns the persisted messages from the position, which should be sent to a new subscriber of topic.
+func (g *GoChannel) persistedMessagesToReplay(topic string, position message.ReplayPosition) []topicPersistedMessages {
+	g.persistedMessagesLock.Lock()
+	defer g.persistedMessagesLock.Unlock()
+
+	var replay []topicPersistedMessages
+
+	for _, persistedTopic := range g.persistedTopicsMatching(topic) {
+		if err := g.applyRetention(persistedTopic); err != nil {
+			g.logger.Error("Cannot apply retention of persisted messages", err, watermill.LogFields{"topic": persistedTopic})
+		}
+
+		messages := g.persistedMessages[persistedTopic]
+
+		skipped := 0
+		if position.Offset > 0 {
+			skipped = int(min(position.Offset, int64(len(messages))))
+		}
+		if !position.Since.IsZero() {
+			for skipped < len(messages) && messages[skipped].PublishedAt.Before(position.Since) {
+				skipped++
+			}
+		}
+
+		if skipped < len(messages) {
+			replay = append(replay, topicPersistedMessages{topic: persistedTopic, messages: messages[skipped:]})
+		}
+	}
+
+	return replay
 }
 
 func (g *GoChannel) addSubscriber(topic string, s *subscriber) {
@@ -269,18 +537,89 @@ func (g *GoChannel) removeSubscriber(topic string, toRemove *subscriber) {
 }
 
 func (g *GoChannel) topicSubscribers(topic string) []*subscriber {
-	subscribers, ok := g.subscribers[topic]
-	if !ok {
-		return nil
-	}
+	subscribers := g.subscribers[topic]
 
 	// let's do a copy to avoid race conditions and deadlocks due to lock
 	subscribersCopy := make([]*subscriber, len(subscribers))
 	copy(subscribersCopy, subscribers)
 
+	if g.config.EnableTopicWildcards {
+		for pattern, patternSubscribers := range g.subscribers {
+			if pattern == topic || !isWildcardTopic(pattern) || !matchTopic(pattern, topic) {
+				continue
+			}
+			subscribersCopy = append(subscribersCopy, patternSubscribers...)
+		}
+	}
+
+	if len(subscribersCopy) == 0 {
+		return nil
+	}
+
 	return subscribersCopy
 }
 
+// pickConsumerGroupsSubscribers returns subscribers without a consumer group
+// and one subscriber of every consumer group.
+func (g *GoChannel) pickConsumerGroupsSubscribers(topic string, subscribers []*subscriber) []*subscriber {
+	var picked []*subscriber
+	var consumerGroups []string
+	consumerGroupsSubscribers := map[string][]*subscriber{}
+
+	for _, s := range subscribers {
+		if s.consumerGroup == "" {
+			picked = append(picked, s)
+			continue
+		}
+
+		if _, ok := consumerGroupsSubscribers[s.consumerGroup]; !ok {
+			consumerGroups = append(consumerGroups, s.consumerGroup)
+		}
+		consumerGroupsSubscribers[s.consumerGroup] = append(consumerGroupsSubscribers[s.consumerGroup], s)
+	}
+
+	for _, consumerGroup := range consumerGroups {
+		members := consumerGroupsSubscribers[consumerGroup]
+
+		offset, _ := g.consumerGroupsOffsets.LoadOrStore(topic+"\x00"+consumerGroup, new(uint64))
+		next := atomic.AddUint64(offset.(*uint64), 1) - 1
+
+		picked = append(picked, members[next%uint64(len(members))])
+	}
+
+	return picked
+}
+
+func (g *GoChannel) hasConsumerGroupSubscribers(topic string, consumerGroup string) bool {
+	for _, s := range g.subscribers[topic] {
+		if s.consumerGroup == consumerGroup {
+			return true
+		}
+	}
+
+	return false
+}
+
+// persistedTopicsMatching returns persisted topics which should be replayed to a subscriber of topic.
+// persistedMessagesLock must be held by the caller.
+func (g *GoChannel) persistedTopicsMatching(topic string) []string {
+	if !g.config.EnableTopicWildcards || !isWildcardTopic(topic) {
+		if _, ok := g.persistedMessages[topic]; ok {
+			return []string{topic}
+		}
+		return nil
+	}
+
+	var topics []string
+	for persistedTopic := range g.persistedMessages {
+		if matchTopic(topic, persistedTopic) {
+			topics = append(topics, persistedTopic)
+		}
+	}
+
+	return topics
+}
+
 func (g *GoChannel) isClosed() bool {
 	g.closedLock.Lock()
 	defer g.closedLock.Unlock()
@@ -320,6 +659,15 @@ type subscriber struct {
 	logger  watermill.LoggerAdapter
 	closed  bool
 	closing chan struct{}
+
+	consumerGroup string
+
+	// seq is the order of subscribing, used by DeterministicDelivery
+	seq uint64
+
+	beforeDeliver func(ctx context.Context, params DeliveryParams)
+	afterDeliver  func(params DeliveryParams)
+	onDelivered   func(topic string)
 }
 
 func (s *subscriber) Close() {
@@ -340,15 +688,34 @@ func (s *subscriber) Close() {
 	close(s.outputChannel)
 }
 
-func (s *subscriber) sendMessageToSubscriber(msg *message.Message, logFields watermill.LogFields) {
+func (s *subscriber) sendMessageToSubscriber(topic string, msg *message.Message, logFields watermill.LogFields) {
 	s.sending.Lock()
 	defer s.sending.Unlock()
 
 	ctx, cancelCtx := context.WithCancel(s.ctx)
 	defer cancelCtx()
 
+	hookCtx := ctx
+	if s.beforeDeliver != nil {
+		var cancelHookCtx context.CancelFunc
+		hookCtx, cancelHookCtx = context.WithCancel(ctx)
+		defer cancelHookCtx()
+
+		go func() {
+			select {
+			case <-s.closing:
+				cancelHookCtx()
+			case <-hookCtx.Done():
+			}
+		}()
+	}
+
+	attempt := 0
+
 SendToSubscriber:
 	for {
+		attempt++
+
 		// copy the message to prevent ack/nack propagation to other consumers
 		// also allows to make retries on a fresh copy of the original message
 		msgToSend := msg.Copy()
@@ -361,14 +728,39 @@ SendToSubscriber:
 			return
 		}
 
+		params := DeliveryParams{
+			Topic:         topic,
+			Message:       msgToSend,
+			ConsumerGroup: s.consumerGroup,
+			Attempt:       attempt,
+		}
+
+		if s.beforeDeliver != nil {
+			s.beforeDeliver(hookCtx, params)
+
+			select {
+			case <-s.closing:
+				s.logger.Trace("Closing, message discarded", logFields)
+				return
+			default:
+			}
+		}
+
 		select {
 		case s.outputChannel <- msgToSend:
 			s.logger.Trace("Sent message to subscriber", logFields)
+			if s.onDelivered != nil {
+				s.onDelivered(topic)
+			}
 		case <-s.closing:
 			s.logger.Trace("Closing, message discarded", logFields)
 			return
 		}
 
+		if s.afterDeliver != nil {
+			s.afterDeliver(params)
+		}
+
 		select {
 		case <-msgToSend.Acked():
 			s.logger.Trace("Message acked", logFields)
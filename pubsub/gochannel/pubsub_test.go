@@ -269,3 +269,181 @@ func testPublishSubscribeSubRace(t *testing.T) {
 		tests.AssertAllMessagesReceived(t, sentMessages, subMsgs)
 	}
 }
+
+func TestPublishSubscribe_topic_wildcards(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{
+			OutputChannelBuffer:  10,
+			EnableTopicWildcards: true,
+		},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	singleTokenMsgs, err := pubSub.Subscribe(context.Background(), "orders.*")
+	require.NoError(t, err)
+
+	remainingTokensMsgs, err := pubSub.Subscribe(context.Background(), "orders.>")
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("orders.created", message.NewMessage("1", nil)))
+	require.NoError(t, pubSub.Publish("orders.created.v2", message.NewMessage("2", nil)))
+	require.NoError(t, pubSub.Publish("orders", message.NewMessage("3", nil)))
+	require.NoError(t, pubSub.Publish("invoices.created", message.NewMessage("4", nil)))
+
+	received, all := subscriber.BulkRead(singleTokenMsgs, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"1"}, received.IDs())
+
+	received, all = subscriber.BulkRead(remainingTokensMsgs, 2, time.Second)
+	require.True(t, all)
+	assert.ElementsMatch(t, []string{"1", "2"}, received.IDs())
+
+	select {
+	case msg := <-singleTokenMsgs:
+		t.Fatalf("unexpected message %s received on wildcard subscription", msg.UUID)
+	case msg := <-remainingTokensMsgs:
+		t.Fatalf("unexpected message %s received on wildcard subscription", msg.UUID)
+	case <-time.After(time.Millisecond * 100):
+		// ok
+	}
+}
+
+func TestPublishSubscribe_topic_wildcards_persistent(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{
+			OutputChannelBuffer:  10,
+			Persistent:           true,
+			EnableTopicWildcards: true,
+		},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	require.NoError(t, pubSub.Publish("orders.created", message.NewMessage("1", nil)))
+	require.NoError(t, pubSub.Publish("orders.paid", message.NewMessage("2", nil)))
+	require.NoError(t, pubSub.Publish("invoices.created", message.NewMessage("3", nil)))
+
+	msgs, err := pubSub.Subscribe(context.Background(), "orders.*")
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(msgs, 2, time.Second)
+	require.True(t, all)
+	assert.ElementsMatch(t, []string{"1", "2"}, received.IDs())
+}
+
+func TestPublishSubscribe_topic_wildcards_disabled(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{OutputChannelBuffer: 10},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	msgs, err := pubSub.Subscribe(context.Background(), "orders.*")
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("orders.created", message.NewMessage("1", nil)))
+	require.NoError(t, pubSub.Publish("orders.*", message.NewMessage("2", nil)))
+
+	received, all := subscriber.BulkRead(msgs, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"2"}, received.IDs())
+}
+
+func TestPublishSubscribe_consumer_groups(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{OutputChannelBuffer: 100},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	group1Sub1, err := pubSub.SubscribeWithConsumerGroup(context.Background(), "topic", "group_1")
+	require.NoError(t, err)
+	group1Sub2, err := pubSub.SubscribeWithConsumerGroup(context.Background(), "topic", "group_1")
+	require.NoError(t, err)
+
+	group2Sub, err := pubSub.ConsumerGroupSubscriber("group_2").Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	noGroupSub, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	messagesCount := 10
+	sentMessages := tests.PublishSimpleMessages(t, messagesCount, pubSub, "topic")
+
+	group1Messages1, _ := subscriber.BulkRead(group1Sub1, messagesCount/2, time.Second)
+	group1Messages2, _ := subscriber.BulkRead(group1Sub2, messagesCount/2, time.Second)
+	assert.Len(t, group1Messages1, messagesCount/2, "messages should be distributed between group members")
+	assert.Len(t, group1Messages2, messagesCount/2, "messages should be distributed between group members")
+	tests.AssertAllMessagesReceived(t, sentMessages, append(group1Messages1, group1Messages2...))
+
+	group2Messages, all := subscriber.BulkRead(group2Sub, messagesCount, time.Second)
+	require.True(t, all)
+	tests.AssertAllMessagesReceived(t, sentMessages, group2Messages)
+
+	noGroupMessages, all := subscriber.BulkRead(noGroupSub, messagesCount, time.Second)
+	require.True(t, all)
+	tests.AssertAllMessagesReceived(t, sentMessages, noGroupMessages)
+}
+
+func TestPublishSubscribe_consumer_groups_persistent(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{OutputChannelBuffer: 100, Persistent: true},
+		watermill.NewStdLogger(true, true),
+	)
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+
+	firstMember, err := pubSub.SubscribeWithConsumerGroup(context.Background(), "topic", "group")
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(firstMember, 1, time.Second)
+	require.True(t, all)
+	assert.Equal(t, []string{"1"}, received.IDs())
+
+	secondMember, err := pubSub.SubscribeWithConsumerGroup(context.Background(), "topic", "group")
+	require.NoError(t, err)
+
+	select {
+	case msg := <-secondMember:
+		t.Fatalf("message %s was already consumed by the group", msg.UUID)
+	case <-time.After(time.Millisecond * 100):
+		// ok
+	}
+}
+
+func TestConsumerGroupSubscriber_Close(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NewStdLogger(true, true))
+	defer func() {
+		require.NoError(t, pubSub.Close())
+	}()
+
+	sub := pubSub.ConsumerGroupSubscriber("group")
+
+	messages, err := sub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	require.NoError(t, sub.Close())
+
+	select {
+	case _, ok := <-messages:
+		assert.False(t, ok, "messages channel should be closed")
+	case <-time.After(time.Second):
+		t.Fatal("messages channel was not closed")
+	}
+
+	// GoChannel is still usable
+	_, err = pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+}
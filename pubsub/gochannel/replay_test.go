@@ -0,0 +1,119 @@
+package gochannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func receiveUUIDs(t *testing.T, messages <-chan *message.Message, count int) []string {
+	t.Helper()
+
+	var uuids []string
+	for len(uuids) < count {
+		select {
+		case msg := <-messages:
+			uuids = append(uuids, msg.UUID)
+			msg.Ack()
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d messages, got %d", count, len(uuids))
+		}
+	}
+
+	return uuids
+}
+
+func TestGoChannel_SubscribeFrom(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		Persistent:            true,
+		DeterministicDelivery: true,
+		Clock:                 clock,
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	for _, uuid := range []string{"1", "2", "3"} {
+		require.NoError(t, pubSub.Publish("topic", message.NewMessage(uuid, nil)))
+		clock.Advance(time.Minute)
+	}
+
+	var _ message.SubscriberWithReplay = pubSub
+
+	testCases := []struct {
+		Name             string
+		Position         message.ReplayPosition
+		ExpectedMessages []string
+	}{
+		{
+			Name:             "beginning",
+			ExpectedMessages: []string{"1", "2", "3"},
+		},
+		{
+			Name:             "offset",
+			Position:         message.ReplayPosition{Offset: 2},
+			ExpectedMessages: []string{"3"},
+		},
+		{
+			Name:             "since",
+			Position:         message.ReplayPosition{Since: clock.Now().Add(-2 * time.Minute)},
+			ExpectedMessages: []string{"2", "3"},
+		},
+		{
+			Name:     "offset_after_end",
+			Position: message.ReplayPosition{Offset: 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			messages, info, err := pubSub.SubscribeFrom(ctx, "topic", tc.Position)
+			require.NoError(t, err)
+
+			assert.Equal(t, message.ReplayInfo{Messages: int64(len(tc.ExpectedMessages))}, info)
+			assert.Equal(t, tc.ExpectedMessages, receiveUUIDs(t, messages, len(tc.ExpectedMessages)))
+		})
+	}
+}
+
+func TestGoChannel_SubscribeFrom_live_messages(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		Persistent:            true,
+		DeterministicDelivery: true,
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil)))
+
+	messages, info, err := pubSub.SubscribeFrom(context.Background(), "topic", message.ReplayPosition{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Messages)
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("2", nil)))
+
+	assert.Equal(t, []string{"1", "2"}, receiveUUIDs(t, messages, 2))
+}
+
+func TestGoChannel_SubscribeFrom_not_persistent(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	_, _, err := pubSub.SubscribeFrom(context.Background(), "topic", message.ReplayPosition{})
+	assert.Error(t, err)
+}
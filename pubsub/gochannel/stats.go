@@ -0,0 +1,96 @@
+package gochannel
+
+import (
+	"sync/atomic"
+)
+
+// TopicStats are the statistics of a topic of GoChannel.
+type TopicStats struct {
+	// Subscribers is the number of active subscribers of the topic.
+	Subscribers int
+
+	// BufferedMessages is the number of messages waiting in the output channels of the topic's subscribers
+	// (see Config.OutputChannelBuffer).
+	BufferedMessages int
+
+	// PersistedMessages is the number of messages persisted for the topic, when Persistent is enabled.
+	PersistedMessages int
+
+	// Published is the number of messages published to the topic.
+	Published uint64
+
+	// Delivered is the number of messages delivered to the topic's subscribers, including redeliveries after nack.
+	// A message delivered to two subscribers is counted twice.
+	Delivered uint64
+}
+
+// Stats are the statistics of GoChannel, collected since it was created.
+type Stats struct {
+	// Topics are the statistics by topic.
+	// Subscribers and BufferedMessages are reported for the subscribed topics, which may be wildcard patterns
+	// if EnableTopicWildcards is used, while the other statistics are reported for the topics of the messages.
+	Topics map[string]TopicStats
+}
+
+// StatsProvider is implemented by Pub/Subs reporting their Stats, like GoChannel.
+// It allows debugging local pipelines and embedded-broker deployments
+// (see metrics.PrometheusMetricsBuilder.RegisterGoChannelMetrics).
+type StatsProvider interface {
+	Stats() Stats
+}
+
+type topicCounters struct {
+	published uint64
+	delivered uint64
+}
+
+func (g *GoChannel) topicCounters(topic string) *topicCounters {
+	counters, _ := g.topicsCounters.LoadOrStore(topic, &topicCounters{})
+	return counters.(*topicCounters)
+}
+
+func (g *GoChannel) countDelivered(topic string) {
+	atomic.AddUint64(&g.topicCounters(topic).delivered, 1)
+}
+
+// Stats returns the current statistics of the Pub/Sub.
+func (g *GoChannel) Stats() Stats {
+	stats := Stats{Topics: map[string]TopicStats{}}
+
+	g.subscribersLock.RLock()
+	for topic, subscribers := range g.subscribers {
+		if len(subscribers) == 0 {
+			continue
+		}
+
+		topicStats := stats.Topics[topic]
+		topicStats.Subscribers = len(subscribers)
+		for _, s := range subscribers {
+			topicStats.BufferedMessages += len(s.outputChannel)
+		}
+		stats.Topics[topic] = topicStats
+	}
+	g.subscribersLock.RUnlock()
+
+	g.persistedMessagesLock.RLock()
+	for topic, messages := range g.persistedMessages {
+		topicStats := stats.Topics[topic]
+		topicStats.PersistedMessages = len(messages)
+		stats.Topics[topic] = topicStats
+	}
+	g.persistedMessagesLock.RUnlock()
+
+	g.topicsCounters.Range(func(key, value any) bool {
+		topic := key.(string)
+		counters := value.(*topicCounters)
+
+		topicStats := stats.Topics[topic]
+		topicStats.Published = atomic.LoadUint64(&counters.published)
+		topicStats.Delivered = atomic.LoadUint64(&counters.delivered)
+		stats.Topics[topic] = topicStats
+
+		return true
+	})
+
+	return stats
+}
@@ -0,0 +1,85 @@
+package gochannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func TestGoChannel_Stats(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		OutputChannelBuffer: 10,
+		Persistent:          true,
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	require.NoError(t, pubSub.Publish("no_subscribers", message.NewMessage("1", nil)))
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return pubSub.Stats().Topics["topic"].Subscribers == 1
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("2", nil)))
+
+	require.Eventually(t, func() bool {
+		return pubSub.Stats().Topics["topic"].Delivered == 1
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, gochannel.TopicStats{
+		Subscribers:       1,
+		BufferedMessages:  1,
+		PersistedMessages: 1,
+		Published:         1,
+		Delivered:         1,
+	}, pubSub.Stats().Topics["topic"])
+
+	assert.Equal(t, gochannel.TopicStats{
+		PersistedMessages: 1,
+		Published:         1,
+	}, pubSub.Stats().Topics["no_subscribers"])
+
+	(<-messages).Nack()
+
+	(<-messages).Ack()
+
+	// the delivery is counted after the message is sent to the output channel
+	assert.Eventually(t, func() bool {
+		return pubSub.Stats().Topics["topic"].Delivered == 2
+	}, time.Second, time.Millisecond, "redelivery should be counted")
+	assert.Equal(t, 0, pubSub.Stats().Topics["topic"].BufferedMessages)
+}
+
+func TestGoChannel_Stats_wildcard_subscribers(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{
+		EnableTopicWildcards: true,
+	}, watermill.NopLogger{})
+	t.Cleanup(func() {
+		assert.NoError(t, pubSub.Close())
+	})
+
+	messages, err := pubSub.Subscribe(context.Background(), "orders.*")
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("orders.created", message.NewMessage("1", nil)))
+	(<-messages).Ack()
+
+	assert.Eventually(t, func() bool {
+		return pubSub.Stats().Topics["orders.created"].Delivered == 1
+	}, time.Second, time.Millisecond)
+
+	stats := pubSub.Stats()
+	assert.Equal(t, 1, stats.Topics["orders.*"].Subscribers)
+	assert.Equal(t, uint64(1), stats.Topics["orders.created"].Published)
+}
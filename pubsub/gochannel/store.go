@@ -0,0 +1,200 @@
+package gochannel
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// PersistedMessage is a message persisted by the persistent GoChannel.
+type PersistedMessage struct {
+	Message     *message.Message
+	PublishedAt time.Time
+}
+
+// MessageStore persists messages of the persistent GoChannel, so they survive process restarts.
+// See NewPersistentGoChannel.
+//
+// It's a minimal interface, which can be implemented with any embedded key-value store (for example, BoltDB or Pebble).
+// FileMessageStore is a simple implementation based on files in a local directory.
+type MessageStore interface {
+	// Load returns all persisted messages by topic, in the order in which they were published.
+	// It's called once, when GoChannel is created.
+	Load() (map[string][]PersistedMessage, error)
+
+	// Append persists messages published to the topic.
+	// Messages are not delivered to the subscribers if Append fails.
+	Append(topic string, messages ...PersistedMessage) error
+
+	// Compact replaces all persisted messages of the topic with the retained ones.
+	// It's called when messages are dropped because of Config.PersistedMessagesTTL or Config.MaxPersistedMessagesPerTopic.
+	Compact(topic string, retained []PersistedMessage) error
+}
+
+// FileMessageStore is a MessageStore keeping messages in a local directory, one file per topic.
+// Messages are stored as JSON lines.
+//
+// It's intended for development and simple single-node deployments. Compacting rewrites the whole topic file.
+type FileMessageStore struct {
+	dir  string
+	lock sync.Mutex
+}
+
+// NewFileMessageStore creates a new FileMessageStore. The directory is created if it doesn't exist.
+func NewFileMessageStore(dir string) (*FileMessageStore, error) {
+	if dir == "" {
+		return nil, errors.New("missing directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "cannot create directory")
+	}
+
+	return &FileMessageStore{dir: dir}, nil
+}
+
+const fileMessageStoreExt = ".jsonl"
+
+type fileStoreMessage struct {
+	UUID        string            `json:"uuid"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Payload     []byte            `json:"payload"`
+	PublishedAt time.Time         `json:"published_at"`
+}
+
+func (s *FileMessageStore) Load() (map[string][]PersistedMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+fileMessageStoreExt))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list topic files")
+	}
+
+	messages := map[string][]PersistedMessage{}
+
+	for _, file := range files {
+		topic, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(file), fileMessageStoreExt))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid topic file name %s", file)
+		}
+
+		topicMessages, err := s.readFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read messages of topic %s", topic)
+		}
+
+		messages[topic] = topicMessages
+	}
+
+	return messages, nil
+}
+
+func (s *FileMessageStore) readFile(file string) ([]PersistedMessage, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var messages []PersistedMessage
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		var stored fileStoreMessage
+		if err := json.Unmarshal(scanner.Bytes(), &stored); err != nil {
+			return nil, errors.Wrap(err, "cannot unmarshal message")
+		}
+
+		msg := message.NewMessage(stored.UUID, stored.Payload)
+		for k, v := range stored.Metadata {
+			msg.Metadata.Set(k, v)
+		}
+
+		messages = append(messages, PersistedMessage{
+			Message:     msg,
+			PublishedAt: stored.PublishedAt,
+		})
+	}
+
+	return messages, scanner.Err()
+}
+
+func (s *FileMessageStore) Append(topic string, messages ...PersistedMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f, err := os.OpenFile(s.topicFile(topic), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "cannot open topic file")
+	}
+
+	if err := writeMessages(f, messages); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *FileMessageStore) Compact(topic string, retained []PersistedMessage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	tmpFile := s.topicFile(topic) + ".tmp"
+
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot create topic file")
+	}
+
+	if err := writeMessages(f, retained); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "cannot close topic file")
+	}
+
+	if err := os.Rename(tmpFile, s.topicFile(topic)); err != nil {
+		return errors.Wrap(err, "cannot replace topic file")
+	}
+
+	return nil
+}
+
+func (s *FileMessageStore) topicFile(topic string) string {
+	return filepath.Join(s.dir, url.PathEscape(topic)+fileMessageStoreExt)
+}
+
+func writeMessages(f *os.File, messages []PersistedMessage) error {
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+
+	for _, msg := range messages {
+		err := encoder.Encode(fileStoreMessage{
+			UUID:        msg.Message.UUID,
+			Metadata:    msg.Message.Metadata,
+			Payload:     msg.Message.Payload,
+			PublishedAt: msg.PublishedAt,
+		})
+		if err != nil {
+			return errors.Wrap(err, "cannot marshal message")
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "cannot write messages")
+	}
+
+	return f.Sync()
+}
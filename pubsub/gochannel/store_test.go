@@ -0,0 +1,147 @@
+package gochannel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/message/subscriber"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+)
+
+func readUUIDs(t *testing.T, pubSub *gochannel.GoChannel, topic string, count int) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, err := pubSub.Subscribe(ctx, topic)
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(messages, count, time.Second)
+	require.True(t, all, "expected %d messages, received %d", count, len(received))
+
+	return received.IDs()
+}
+
+func TestNewPersistentGoChannel_restart(t *testing.T) {
+	dir := t.TempDir()
+	logger := watermill.NopLogger{}
+
+	store, err := gochannel.NewFileMessageStore(dir)
+	require.NoError(t, err)
+
+	pubSub, err := gochannel.NewPersistentGoChannel(gochannel.Config{}, store, logger)
+	require.NoError(t, err)
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+	msg.Metadata.Set("key", "value")
+
+	require.NoError(t, pubSub.Publish("orders/created", msg))
+	require.NoError(t, pubSub.Publish("orders/created", message.NewMessage("2", []byte("2"))))
+	require.NoError(t, pubSub.Publish("other", message.NewMessage("3", []byte("3"))))
+	require.NoError(t, pubSub.Close())
+
+	store, err = gochannel.NewFileMessageStore(dir)
+	require.NoError(t, err)
+
+	restartedPubSub, err := gochannel.NewPersistentGoChannel(gochannel.Config{}, store, logger)
+	require.NoError(t, err)
+	defer restartedPubSub.Close()
+
+	messages, err := restartedPubSub.Subscribe(context.Background(), "orders/created")
+	require.NoError(t, err)
+
+	received, all := subscriber.BulkRead(messages, 2, time.Second)
+	require.True(t, all)
+	assert.ElementsMatch(t, []string{msg.UUID, "2"}, received.IDs())
+
+	for _, receivedMsg := range received {
+		if receivedMsg.UUID == msg.UUID {
+			assert.Equal(t, "payload", string(receivedMsg.Payload))
+			assert.Equal(t, "value", receivedMsg.Metadata.Get("key"))
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"3"}, readUUIDs(t, restartedPubSub, "other", 1))
+}
+
+func TestNewPersistentGoChannel_max_messages_per_topic(t *testing.T) {
+	dir := t.TempDir()
+	config := gochannel.Config{MaxPersistedMessagesPerTopic: 2}
+
+	store, err := gochannel.NewFileMessageStore(dir)
+	require.NoError(t, err)
+
+	pubSub, err := gochannel.NewPersistentGoChannel(config, store, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	for _, uuid := range []string{"1", "2", "3"} {
+		require.NoError(t, pubSub.Publish("topic", message.NewMessage(uuid, nil)))
+	}
+
+	assert.ElementsMatch(t, []string{"2", "3"}, readUUIDs(t, pubSub, "topic", 2))
+	require.NoError(t, pubSub.Close())
+
+	persisted, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, persisted["topic"], 2, "store should be compacted")
+	assert.Equal(t, "2", persisted["topic"][0].Message.UUID)
+	assert.Equal(t, "3", persisted["topic"][1].Message.UUID)
+}
+
+func TestNewPersistentGoChannel_ttl(t *testing.T) {
+	clock := watermill.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config := gochannel.Config{
+		PersistedMessagesTTL: time.Hour,
+		Clock:                clock,
+	}
+
+	store, err := gochannel.NewFileMessageStore(t.TempDir())
+	require.NoError(t, err)
+
+	pubSub, err := gochannel.NewPersistentGoChannel(config, store, watermill.NopLogger{})
+	require.NoError(t, err)
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("expired", nil)))
+	clock.Advance(time.Minute * 45)
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("retained", nil)))
+	clock.Advance(time.Minute * 30)
+	require.NoError(t, pubSub.Close())
+
+	restartedPubSub, err := gochannel.NewPersistentGoChannel(config, store, watermill.NopLogger{})
+	require.NoError(t, err)
+	defer restartedPubSub.Close()
+
+	assert.Equal(t, []string{"retained"}, readUUIDs(t, restartedPubSub, "topic", 1))
+
+	persisted, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, persisted["topic"], 1)
+	assert.Equal(t, "retained", persisted["topic"][0].Message.UUID)
+}
+
+func TestGoChannel_max_persisted_messages_in_memory(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(
+		gochannel.Config{
+			Persistent:                   true,
+			MaxPersistedMessagesPerTopic: 1,
+		},
+		watermill.NopLogger{},
+	)
+	defer pubSub.Close()
+
+	require.NoError(t, pubSub.Publish("topic", message.NewMessage("1", nil), message.NewMessage("2", nil)))
+
+	assert.Equal(t, []string{"2"}, readUUIDs(t, pubSub, "topic", 1))
+}
+
+func TestNewPersistentGoChannel_missing_store(t *testing.T) {
+	_, err := gochannel.NewPersistentGoChannel(gochannel.Config{}, nil, watermill.NopLogger{})
+	assert.EqualError(t, err, "missing store")
+}
@@ -0,0 +1,43 @@
+package gochannel
+
+import (
+	"strings"
+)
+
+const (
+	topicTokenSeparator     = "."
+	singleTokenWildcard     = "*"
+	remainingTokensWildcard = ">"
+)
+
+// isWildcardTopic returns true if the topic contains any wildcard token.
+func isWildcardTopic(topic string) bool {
+	for _, token := range strings.Split(topic, topicTokenSeparator) {
+		if token == singleTokenWildcard || token == remainingTokensWildcard {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchTopic checks if the topic matches the wildcard pattern.
+// See Config.EnableTopicWildcards for the matching rules.
+func matchTopic(pattern string, topic string) bool {
+	patternTokens := strings.Split(pattern, topicTokenSeparator)
+	topicTokens := strings.Split(topic, topicTokenSeparator)
+
+	for i, patternToken := range patternTokens {
+		if patternToken == remainingTokensWildcard && i == len(patternTokens)-1 {
+			return len(topicTokens) > i
+		}
+		if i >= len(topicTokens) {
+			return false
+		}
+		if patternToken != singleTokenWildcard && patternToken != topicTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(topicTokens)
+}
@@ -0,0 +1,315 @@
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// RecordedMessage is a message published through MessageRecorder.
+type RecordedMessage struct {
+	Topic   string
+	Message *message.Message
+
+	// Sequence is the position of the message in all messages recorded by MessageRecorder, starting from 0.
+	Sequence int
+}
+
+// MessageRecorder is a publisher which records all published messages, to query and assert them in tests.
+//
+// If the underlying publisher is not nil, messages are published with it after they are recorded.
+// Use NewMessageRecorder with nil publisher to only record the messages,
+// or RecordingPublisherDecorator to record messages published by the router.
+type MessageRecorder struct {
+	pub message.Publisher
+
+	records []RecordedMessage
+	lock    sync.Mutex
+}
+
+// NewMessageRecorder creates a new MessageRecorder. pub is optional.
+func NewMessageRecorder(pub message.Publisher) *MessageRecorder {
+	return &MessageRecorder{pub: pub}
+}
+
+// RecordingPublisherDecorator returns a decorator which records messages of the decorated publishers in the recorder.
+func RecordingPublisherDecorator(recorder *MessageRecorder) message.PublisherDecorator {
+	return func(pub message.Publisher) (message.Publisher, error) {
+		return recordingPublisher{pub: pub, recorder: recorder}, nil
+	}
+}
+
+type recordingPublisher struct {
+	pub      message.Publisher
+	recorder *MessageRecorder
+}
+
+func (p recordingPublisher) Publish(topic string, messages ...*message.Message) error {
+	p.recorder.record(topic, messages)
+	return p.pub.Publish(topic, messages...)
+}
+
+func (p recordingPublisher) Close() error {
+	return p.pub.Close()
+}
+
+func (r *MessageRecorder) Publish(topic string, messages ...*message.Message) error {
+	r.record(topic, messages)
+
+	if r.pub == nil {
+		return nil
+	}
+
+	return r.pub.Publish(topic, messages...)
+}
+
+func (r *MessageRecorder) Close() error {
+	if r.pub == nil {
+		return nil
+	}
+
+	return r.pub.Close()
+}
+
+func (r *MessageRecorder) record(topic string, messages []*message.Message) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, msg := range messages {
+		r.records = append(r.records, RecordedMessage{
+			Topic: topic,
+			// the publisher or the Pub/Sub may modify the message later
+			Message:  msg.Copy(),
+			Sequence: len(r.records),
+		})
+	}
+}
+
+// Reset removes all recorded messages.
+func (r *MessageRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.records = nil
+}
+
+// Messages returns all messages published to the topic, in the order of publishing.
+func (r *MessageRecorder) Messages(topic string) message.Messages {
+	return r.Query().Topic(topic).Messages()
+}
+
+// Query returns a query over all recorded messages.
+// The query is a snapshot: messages recorded later are not included.
+func (r *MessageRecorder) Query() MessageQuery {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	records := make([]RecordedMessage, len(r.records))
+	copy(records, r.records)
+
+	return MessageQuery{records: records}
+}
+
+// WaitFor waits until the query returned by buildQuery has at least count messages, or the timeout is exceeded.
+// It returns the last query and true if the messages were recorded in time.
+func (r *MessageRecorder) WaitFor(buildQuery func(MessageQuery) MessageQuery, count int, timeout time.Duration) (MessageQuery, bool) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		q := buildQuery(r.Query())
+		if q.Count() >= count {
+			return q, true
+		}
+		if time.Now().After(deadline) {
+			return q, false
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// MessageQuery filters recorded messages. Filters are chained, like in SQL's WHERE:
+//
+//	recorder.Query().Topic("orders").WhereMetadata("tenant", "acme").WhereField("order.status", "paid").Count()
+//
+// Messages are always in the order of publishing.
+type MessageQuery struct {
+	records []RecordedMessage
+}
+
+// Where returns messages matching the filter.
+func (q MessageQuery) Where(filter func(RecordedMessage) bool) MessageQuery {
+	var records []RecordedMessage
+	for _, record := range q.records {
+		if filter(record) {
+			records = append(records, record)
+		}
+	}
+
+	return MessageQuery{records: records}
+}
+
+// Topic returns messages published to the topic.
+func (q MessageQuery) Topic(topic string) MessageQuery {
+	return q.Where(func(record RecordedMessage) bool {
+		return record.Topic == topic
+	})
+}
+
+// WhereMetadata returns messages with the metadata value.
+func (q MessageQuery) WhereMetadata(key string, value string) MessageQuery {
+	return q.Where(func(record RecordedMessage) bool {
+		v, ok := record.Message.Metadata[key]
+		return ok && v == value
+	})
+}
+
+// WhereField returns messages with JSON payload having the field with the value.
+// Path of nested fields is separated with dots, for example, "order.customer.id".
+// The value is compared after marshaling it to JSON, so numbers of any Go type can be used.
+// Messages with non-JSON payloads are skipped.
+func (q MessageQuery) WhereField(path string, value any) MessageQuery {
+	expected, err := normalizeJSON(value)
+	if err != nil {
+		return MessageQuery{}
+	}
+
+	return q.Where(func(record RecordedMessage) bool {
+		actual, ok := jsonField(record.Message.Payload, path)
+		return ok && reflect.DeepEqual(expected, actual)
+	})
+}
+
+// Count returns the number of messages.
+func (q MessageQuery) Count() int {
+	return len(q.records)
+}
+
+// Records returns the recorded messages.
+func (q MessageQuery) Records() []RecordedMessage {
+	return q.records
+}
+
+// Messages returns the messages.
+func (q MessageQuery) Messages() message.Messages {
+	messages := make(message.Messages, 0, len(q.records))
+	for _, record := range q.records {
+		messages = append(messages, record.Message)
+	}
+
+	return messages
+}
+
+// First returns the first published message. It returns false if there are no messages.
+func (q MessageQuery) First() (*message.Message, bool) {
+	if len(q.records) == 0 {
+		return nil, false
+	}
+
+	return q.records[0].Message, true
+}
+
+// Last returns the last published message. It returns false if there are no messages.
+func (q MessageQuery) Last() (*message.Message, bool) {
+	if len(q.records) == 0 {
+		return nil, false
+	}
+
+	return q.records[len(q.records)-1].Message, true
+}
+
+// AssertCount checks if the query has exactly expected messages.
+func (q MessageQuery) AssertCount(t *testing.T, expected int) bool {
+	t.Helper()
+
+	return assert.Equal(t, expected, q.Count(), "unexpected number of messages, got: %s", q.describe())
+}
+
+// AssertOrder checks if the messages with the UUIDs were published in this order.
+// Other messages are ignored.
+func (q MessageQuery) AssertOrder(t *testing.T, uuids ...string) bool {
+	t.Helper()
+
+	expected := map[string]struct{}{}
+	for _, uuid := range uuids {
+		expected[uuid] = struct{}{}
+	}
+
+	var actual []string
+	for _, record := range q.records {
+		if _, ok := expected[record.Message.UUID]; ok {
+			actual = append(actual, record.Message.UUID)
+		}
+	}
+
+	return assert.Equal(t, uuids, actual, "messages were not published in the expected order")
+}
+
+// AssertPublishedBefore checks if all messages of the query were published before any message of the later query.
+// Both queries must have messages.
+func (q MessageQuery) AssertPublishedBefore(t *testing.T, later MessageQuery) bool {
+	t.Helper()
+
+	if !assert.NotEmpty(t, q.records, "no messages in the query") || !assert.NotEmpty(t, later.records, "no messages in the later query") {
+		return false
+	}
+
+	lastSequence := q.records[len(q.records)-1].Sequence
+	firstLaterSequence := later.records[0].Sequence
+
+	return assert.Less(
+		t, lastSequence, firstLaterSequence,
+		"messages %s were not published before %s", q.describe(), later.describe(),
+	)
+}
+
+func (q MessageQuery) describe() string {
+	descriptions := make([]string, 0, len(q.records))
+	for _, record := range q.records {
+		descriptions = append(descriptions, record.Topic+"/"+record.Message.UUID)
+	}
+
+	return "[" + strings.Join(descriptions, ", ") + "]"
+}
+
+func normalizeJSON(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized any
+	if err := json.Unmarshal(b, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+func jsonField(payload []byte, path string) (any, bool) {
+	var value any
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, false
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		value, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,100 @@
+package tests_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"github.com/ThreeDotsLabs/watermill/pubsub/tests"
+)
+
+func newJSONMessage(uuid string, payload string, tenant string) *message.Message {
+	msg := message.NewMessage(uuid, []byte(payload))
+	msg.Metadata.Set("tenant", tenant)
+	return msg
+}
+
+func TestMessageRecorder(t *testing.T) {
+	recorder := tests.NewMessageRecorder(nil)
+
+	require.NoError(t, recorder.Publish(
+		"orders",
+		newJSONMessage("1", `{"order":{"id":1,"status":"placed"}}`, "acme"),
+		newJSONMessage("2", `{"order":{"id":2,"status":"placed"}}`, "other"),
+	))
+	require.NoError(t, recorder.Publish("payments", newJSONMessage("3", `{"order_id":1}`, "acme")))
+	require.NoError(t, recorder.Publish("orders", newJSONMessage("4", `{"order":{"id":1,"status":"paid"}}`, "acme")))
+	require.NoError(t, recorder.Publish("orders", newJSONMessage("5", `not json`, "acme")))
+
+	assert.Equal(t, []string{"1", "2", "4", "5"}, recorder.Messages("orders").IDs())
+
+	acmeOrders := recorder.Query().Topic("orders").WhereMetadata("tenant", "acme")
+	acmeOrders.AssertCount(t, 3)
+
+	paid := acmeOrders.WhereField("order.status", "paid")
+	paid.AssertCount(t, 1)
+	first, ok := paid.First()
+	require.True(t, ok)
+	assert.Equal(t, "4", first.UUID)
+
+	recorder.Query().WhereField("order.id", 1).AssertCount(t, 2)
+	recorder.Query().WhereField("order_id", int64(1)).AssertCount(t, 1)
+	recorder.Query().WhereField("order.missing", 1).AssertCount(t, 0)
+
+	last, ok := recorder.Query().Last()
+	require.True(t, ok)
+	assert.Equal(t, "5", last.UUID)
+
+	recorder.Query().AssertOrder(t, "1", "3", "4")
+
+	placed := recorder.Query().Topic("orders").WhereField("order.status", "placed")
+	placed.AssertPublishedBefore(t, recorder.Query().Topic("payments"))
+
+	recorder.Reset()
+	recorder.Query().AssertCount(t, 0)
+}
+
+func TestMessageRecorder_assertions_fail(t *testing.T) {
+	recorder := tests.NewMessageRecorder(nil)
+	require.NoError(t, recorder.Publish("topic", message.NewMessage("1", nil), message.NewMessage("2", nil)))
+
+	mockT := &testing.T{}
+
+	assert.False(t, recorder.Query().AssertCount(mockT, 1))
+	assert.False(t, recorder.Query().AssertOrder(mockT, "2", "1"))
+	assert.False(t, recorder.Query().Where(func(r tests.RecordedMessage) bool {
+		return r.Message.UUID == "2"
+	}).AssertPublishedBefore(mockT, recorder.Query().Topic("topic")))
+}
+
+func TestRecordingPublisherDecorator(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{Persistent: true}, watermill.NopLogger{})
+	recorder := tests.NewMessageRecorder(nil)
+
+	pub, err := tests.RecordingPublisherDecorator(recorder)(pubSub)
+	require.NoError(t, err)
+
+	messages, err := pubSub.Subscribe(context.Background(), "topic")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(time.Millisecond * 20)
+		assert.NoError(t, pub.Publish("topic", message.NewMessage("1", nil)))
+	}()
+
+	query, ok := recorder.WaitFor(func(q tests.MessageQuery) tests.MessageQuery {
+		return q.Topic("topic")
+	}, 1, time.Second)
+	require.True(t, ok)
+	query.AssertCount(t, 1)
+
+	msg := <-messages
+	assert.Equal(t, "1", msg.UUID)
+	msg.Ack()
+}
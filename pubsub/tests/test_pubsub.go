@@ -38,6 +38,8 @@ func TestPubSub(
 	pubSubConstructor PubSubConstructor,
 	consumerGroupPubSubConstructor ConsumerGroupPubSubConstructor,
 ) {
+	t.Logf("Pub/Sub features:\n%s", features.Matrix())
+
 	testFuncs := []struct {
 		Func        func(t *testing.T, tCtx TestContext, pubSubConstructor PubSubConstructor)
 		NotParallel bool
@@ -56,6 +58,9 @@ func TestPubSub(
 		{Func: TestMessageCtx},
 		{Func: TestSubscribeCtx},
 		{Func: TestNewSubscriberReceivesOldMessages},
+		{Func: TestPublishSubscribeInOrderPerKey},
+		{Func: TestRedeliveryAfterNackDelay},
+		{Func: TestDuplicatesDetection},
 		{
 			Func:        TestReconnect,
 			NotParallel: true,
@@ -121,6 +126,23 @@ type Features struct {
 	// NewSubscriberReceivesOldMessages should be set to true if messages are persisted even
 	// if they are already consumed (for example, like in Kafka).
 	NewSubscriberReceivesOldMessages bool
+
+	// GuaranteedOrderPerKey should be true, if order of messages with the same ordering key is guaranteed,
+	// even if the order of all messages is not (for example, when messages are partitioned by the key).
+	GuaranteedOrderPerKey bool
+
+	// SetOrderingKey sets the ordering key on the message, used when GuaranteedOrderPerKey is true.
+	// For example, it can set the metadata used by the Pub/Sub's partitioning.
+	// If nil, the ordering key is not set on the message, which is enough for Pub/Subs that order all messages.
+	SetOrderingKey func(msg *message.Message, key string)
+
+	// NackRedeliveryDelay should be set to the minimum delay of redelivering a nacked message,
+	// if the Pub/Sub supports backoff of redeliveries.
+	NackRedeliveryDelay time.Duration
+
+	// DuplicatesDetection should be true, if messages with the same UUID published more than once
+	// are delivered only once.
+	DuplicatesDetection bool
 }
 
 // RunOnlyFastTests returns true if -short flag was provided -race was not provided.
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// Matrix returns a human-readable table of the guarantees declared by the Features.
+// It's logged by TestPubSub, so it's easy to compare guarantees of different Pub/Subs.
+func (f Features) Matrix() string {
+	nackRedeliveryDelay := "-"
+	if f.NackRedeliveryDelay > 0 {
+		nackRedeliveryDelay = f.NackRedeliveryDelay.String()
+	}
+
+	rows := []struct {
+		Feature string
+		Value   string
+	}{
+		{"Consumer groups", formatFeature(f.ConsumerGroups)},
+		{"Exactly-once delivery", formatFeature(f.ExactlyOnceDelivery)},
+		{"Guaranteed order", formatFeature(f.GuaranteedOrder)},
+		{"Guaranteed order with single subscriber", formatFeature(f.GuaranteedOrderWithSingleSubscriber)},
+		{"Guaranteed order per key", formatFeature(f.GuaranteedOrderPerKey)},
+		{"Nack redelivery delay", nackRedeliveryDelay},
+		{"Duplicates detection", formatFeature(f.DuplicatesDetection)},
+		{"Persistent", formatFeature(f.Persistent)},
+		{"New subscriber receives old messages", formatFeature(f.NewSubscriberReceivesOldMessages)},
+		{"Requires single instance", formatFeature(f.RequireSingleInstance)},
+		{"Reconnect tested", formatFeature(len(f.RestartServiceCommand) > 0)},
+	}
+
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', 0)
+
+	for _, row := range rows {
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", row.Feature, row.Value)
+	}
+	_ = w.Flush()
+
+	return buf.String()
+}
+
+func formatFeature(supported bool) string {
+	if supported {
+		return "yes"
+	}
+	return "no"
+}
+
+// TestPublishSubscribeInOrderPerKey tests if messages with the same ordering key are received in the order they were published.
+// This test is skipped for Pub/Subs that don't support GuaranteedOrderPerKey feature.
+func TestPublishSubscribeInOrderPerKey(
+	t *testing.T,
+	tCtx TestContext,
+	pubSubConstructor PubSubConstructor,
+) {
+	if !tCtx.Features.GuaranteedOrderPerKey {
+		t.Skip("order per key is not guaranteed")
+	}
+
+	messagesCount := 1000
+	keysCount := 8
+	if testing.Short() {
+		messagesCount = 100
+	}
+
+	pub, sub := pubSubConstructor(t)
+	defer closePubSub(t, pub, sub)
+
+	topicName := testTopicName(tCtx.TestID)
+
+	if subscribeInitializer, ok := sub.(message.SubscribeInitializer); ok {
+		require.NoError(t, subscribeInitializer.SubscribeInitialize(topicName))
+	}
+
+	messages, err := sub.Subscribe(context.Background(), topicName)
+	require.NoError(t, err)
+
+	var messagesToPublish message.Messages
+	expectedOrder := map[string][]string{}
+
+	for i := 0; i < messagesCount; i++ {
+		key := strconv.Itoa(i % keysCount)
+
+		msg := message.NewMessage(watermill.NewUUID(), []byte(key))
+		if tCtx.Features.SetOrderingKey != nil {
+			tCtx.Features.SetOrderingKey(msg, key)
+		}
+
+		messagesToPublish = append(messagesToPublish, msg)
+		expectedOrder[key] = append(expectedOrder[key], msg.UUID)
+
+		require.NoError(t, publishWithRetry(pub, topicName, msg))
+	}
+
+	receivedMessages, all := bulkRead(tCtx, messages, len(messagesToPublish), defaultTimeout)
+	require.True(t, all, "not all messages received (%d of %d)", len(receivedMessages), len(messagesToPublish))
+
+	receivedOrder := map[string][]string{}
+	for _, msg := range receivedMessages {
+		key := string(msg.Payload)
+		receivedOrder[key] = append(receivedOrder[key], msg.UUID)
+	}
+
+	for key, ids := range expectedOrder {
+		assert.Equal(t, ids, receivedOrder[key], "messages with key %s received in different order", key)
+	}
+}
+
+// TestRedeliveryAfterNackDelay tests if the nacked message is redelivered, but not earlier than NackRedeliveryDelay.
+// This test is skipped for Pub/Subs without NackRedeliveryDelay feature.
+func TestRedeliveryAfterNackDelay(
+	t *testing.T,
+	tCtx TestContext,
+	pubSubConstructor PubSubConstructor,
+) {
+	if tCtx.Features.NackRedeliveryDelay <= 0 {
+		t.Skip("nack redelivery delay is not supported")
+	}
+
+	pub, sub := pubSubConstructor(t)
+	defer closePubSub(t, pub, sub)
+
+	topicName := testTopicName(tCtx.TestID)
+
+	if subscribeInitializer, ok := sub.(message.SubscribeInitializer); ok {
+		require.NoError(t, subscribeInitializer.SubscribeInitialize(topicName))
+	}
+
+	messages, err := sub.Subscribe(context.Background(), topicName)
+	require.NoError(t, err)
+
+	publishedMessages := PublishSimpleMessages(t, 1, pub, topicName)
+
+	var nackedAt time.Time
+
+	select {
+	case msg := <-messages:
+		require.Equal(t, publishedMessages[0].UUID, msg.UUID)
+		nackedAt = time.Now()
+		msg.Nack()
+	case <-time.After(defaultTimeout):
+		t.Fatal("message not received")
+	}
+
+	select {
+	case msg := <-messages:
+		redeliveryDelay := time.Since(nackedAt)
+
+		assert.Equal(t, publishedMessages[0].UUID, msg.UUID)
+		assert.GreaterOrEqual(
+			t,
+			redeliveryDelay,
+			tCtx.Features.NackRedeliveryDelay,
+			"message redelivered after %s, expected at least %s", redeliveryDelay, tCtx.Features.NackRedeliveryDelay,
+		)
+		msg.Ack()
+	case <-time.After(tCtx.Features.NackRedeliveryDelay + defaultTimeout):
+		t.Fatal("nacked message not redelivered")
+	}
+}
+
+// TestDuplicatesDetection tests if the message published more than once (with the same UUID) is received only once.
+// This test is skipped for Pub/Subs that don't support DuplicatesDetection feature.
+func TestDuplicatesDetection(
+	t *testing.T,
+	tCtx TestContext,
+	pubSubConstructor PubSubConstructor,
+) {
+	if !tCtx.Features.DuplicatesDetection {
+		t.Skip("duplicates detection is not supported")
+	}
+
+	pub, sub := pubSubConstructor(t)
+	defer closePubSub(t, pub, sub)
+
+	topicName := testTopicName(tCtx.TestID)
+
+	if subscribeInitializer, ok := sub.(message.SubscribeInitializer); ok {
+		require.NoError(t, subscribeInitializer.SubscribeInitialize(topicName))
+	}
+
+	messages, err := sub.Subscribe(context.Background(), topicName)
+	require.NoError(t, err)
+
+	id := watermill.NewUUID()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, publishWithRetry(pub, topicName, message.NewMessage(id, nil)))
+	}
+
+	otherMsg := message.NewMessage(watermill.NewUUID(), nil)
+	require.NoError(t, publishWithRetry(pub, topicName, otherMsg))
+
+	receivedCount := map[string]int{}
+
+	// waiting for all messages and for possible duplicates delivered after them
+	timeout := time.After(defaultTimeout)
+	var duplicatesTimeout <-chan time.Time
+
+ReadLoop:
+	for {
+		select {
+		case msg := <-messages:
+			receivedCount[msg.UUID]++
+			msg.Ack()
+
+			if duplicatesTimeout == nil && receivedCount[id] > 0 && receivedCount[otherMsg.UUID] > 0 {
+				duplicatesTimeout = time.After(time.Second)
+			}
+		case <-duplicatesTimeout:
+			break ReadLoop
+		case <-timeout:
+			t.Fatal("not all messages received")
+		}
+	}
+
+	assert.Equal(t, map[string]int{id: 1, otherMsg.UUID: 1}, receivedCount)
+}
@@ -13,6 +13,16 @@ func NewUUID() string {
 	return uuid.New().String()
 }
 
+// deterministicUUIDNamespace is the namespace of the UUIDs returned by NewDeterministicUUID.
+var deterministicUUIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://watermill.io"))
+
+// NewDeterministicUUID returns a UUID Version 5 derived from the key, so the same key always gives the same UUID.
+// It can be used to generate message UUIDs from business keys (for example, "OrderPlaced/" + orderID),
+// so the duplicates of the message can be detected by the consumers.
+func NewDeterministicUUID(key string) string {
+	return uuid.NewSHA1(deterministicUUIDNamespace, []byte(key)).String()
+}
+
 // NewShortUUID returns a new short UUID.
 func NewShortUUID() string {
 	return shortuuid.New()
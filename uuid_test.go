@@ -4,6 +4,10 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/ThreeDotsLabs/watermill"
 )
 
@@ -55,3 +59,14 @@ func TestShortUUID(t *testing.T) {
 func TestULID(t *testing.T) {
 	testuUniqness(t, watermill.NewULID)
 }
+
+func TestNewDeterministicUUID(t *testing.T) {
+	id := watermill.NewDeterministicUUID("OrderPlaced/1")
+
+	parsed, err := uuid.Parse(id)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), parsed.Version())
+
+	assert.Equal(t, id, watermill.NewDeterministicUUID("OrderPlaced/1"))
+	assert.NotEqual(t, id, watermill.NewDeterministicUUID("OrderPlaced/2"))
+}